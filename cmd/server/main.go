@@ -2,35 +2,84 @@ package main
 
 import (
 	"context"
+	"flag"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"api-gateway-backend/internal/api"
+	"api-gateway-backend/internal/api/ginadapter"
+	"api-gateway-backend/internal/audit"
+	"api-gateway-backend/internal/cache"
+	"api-gateway-backend/internal/client"
 	"api-gateway-backend/internal/config"
 	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/events"
+	"api-gateway-backend/internal/health"
 	"api-gateway-backend/internal/jobs"
+	"api-gateway-backend/internal/kafka"
 	"api-gateway-backend/internal/logger"
 	"api-gateway-backend/internal/redis"
+	"api-gateway-backend/internal/reporting"
+	"api-gateway-backend/internal/secrets"
+	"api-gateway-backend/internal/server"
+	"api-gateway-backend/internal/version"
+	"api-gateway-backend/internal/webhooks"
 
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
 )
 
+// restartReadyTimeout bounds how long a graceful restart waits for the new
+// process to load its config, connect and start serving
+const restartReadyTimeout = time.Minute
+
 func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or JSON config file, overridden by environment variables")
+	flag.Parse()
+
 	// Initialize logger
 	log := logger.New()
 
 	// Load configuration
-	cfg := config.Load()
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	log.SetLevelName(cfg.LogLevel)
+	log.RedactFields(cfg.LogRedact...)
+	log.WithFields(version.Get().Fields()).WithField("environment", cfg.Environment).Info("Starting api-gateway-backend")
+
+	// Report panics, server errors and job failures when a DSN is set
+	reporter, err := reporting.New(cfg.ErrorReporting, cfg.Environment)
+	if err != nil {
+		log.Fatalf("Failed to configure error reporting: %v", err)
+	}
+	defer reporter.Flush(5 * time.Second)
+
+	// Fetch credentials referenced in the configuration from the secrets
+	// manager, before anything connects with them
+	secretsProvider, err := secrets.NewProvider(cfg.Secrets)
+	if err != nil {
+		log.Fatalf("Failed to configure secrets provider: %v", err)
+	}
+	secretManager := secrets.NewManager(secretsProvider, time.Duration(cfg.Secrets.RefreshInterval)*time.Second, log)
+	if err := secretManager.Resolve(context.Background(), cfg); err != nil {
+		log.Fatalf("Failed to fetch secrets: %v", err)
+	}
 
 	// Initialize database
-	db, err := database.New(cfg.Database)
+	db, err := database.New(cfg.Database, log)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer db.Close()
+	if cfg.Jobs.Outbox {
+		db.EnableOutbox()
+	}
 
 	// Initialize Redis
 	rdb, err := redis.New(cfg.Redis)
@@ -39,10 +88,37 @@ func main() {
 	}
 	defer rdb.Close()
 
-	// Initialize background jobs
-	jobManager := jobs.New(db, rdb, cfg.ExternalAPI, log)
-	jobManager.Start()
-	defer jobManager.Stop()
+	// Initialize external API clients
+	upstreams, err := client.NewRegistry(cfg.ExternalAPI, cache.NewRedis(rdb, log), log)
+	if err != nil {
+		log.Fatalf("Failed to configure external APIs: %v", err)
+	}
+	if cfg.ExternalAPI.Mode == config.ExternalAPIMock {
+		log.Info("External APIs are mocked with fixture data")
+	}
+
+	// Apply rotated secrets to the clients using them
+	secretsCtx, stopSecrets := context.WithCancel(context.Background())
+	defer stopSecrets()
+	watchSecrets(secretManager, cfg, db, rdb, upstreams)
+	go secretManager.Run(secretsCtx)
+
+	// Initialize outbound webhooks, published by syncs and the API
+	notifier := webhooks.NewNotifier(db, cfg.Webhooks, log)
+
+	// Initialize background jobs, producing their changes to Kafka if
+	// brokers are configured
+	jobManager := jobs.New(db, rdb, upstreams, notifier, reporter, cfg.Jobs, log)
+	if producer := kafka.NewProducer(cfg.Kafka); producer != nil {
+		defer producer.Close()
+		jobManager.ProduceTo(producer)
+	}
+
+	// Relay live item events from Redis to this instance's /ws clients
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	defer stopHub()
+	hub := events.NewHub(log)
+	go hub.Run(hubCtx, rdb)
 
 	// Set Gin mode
 	if cfg.Environment == "production" {
@@ -50,29 +126,161 @@ func main() {
 	}
 
 	// Initialize API routes
-	router := api.NewRouter(db, rdb, cfg.ExternalAPI, log)
+	handler, err := api.NewHandler(db, rdb, jobManager, notifier, hub, reporter, cfg, log)
+	if err != nil {
+		log.Fatalf("Failed to initialize API handler: %v", err)
+	}
+	router := ginadapter.NewRouter(handler)
+
+	// Keep checking dependencies in the background, and track upstream API
+	// calls alongside them
+	upstreams.Observe(func(name string, err error, latency time.Duration) {
+		handler.Dependencies().Record(health.Upstream(name), err, latency)
+	})
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	defer stopMonitor()
+	go handler.MonitorDependencies(monitorCtx)
+	// Client IPs, for rate limits and IP filtering, come from forwarding
+	// headers only when set by a trusted proxy
+	if err := router.SetTrustedProxies(cfg.IPFilter.TrustedProxies); err != nil {
+		log.Fatalf("Failed to set trusted proxies: %v", err)
+	}
+
+	// Warm the cache now and after every sync, then start the jobs so the
+	// startup sync warms it too
+	jobManager.AfterSync(handler.WarmCache)
+	warmCtx, stopWarming := context.WithTimeout(context.Background(), time.Minute)
+	defer stopWarming()
+	go handler.WarmCache(warmCtx)
+	jobManager.Start()
+
+	// Apply changes to the config file's runtime settings without a restart
+	configWatcher := config.NewWatcher(*configPath, cfg, time.Duration(cfg.ReloadInterval)*time.Second, log)
+	// Only a changed log_level overrides one set through /admin/loglevel
+	logLevel := cfg.LogLevel
+	configWatcher.Subscribe(config.SubscriberFunc(func(cfg *config.Config) {
+		if cfg.LogLevel != logLevel {
+			logLevel = cfg.LogLevel
+			log.SetLevelName(logLevel)
+		}
+	}))
+	configWatcher.Subscribe(jobManager)
+	configWatcher.Subscribe(handler)
+	configWatcher.Subscribe(audit.NewRecorder(db, log).ConfigChanges(*configPath, cfg))
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	go configWatcher.Run(watchCtx)
 
 	// Create HTTP server
 	srv := &http.Server{
-		Addr:         ":" + cfg.Port,
-		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:           ":" + cfg.Port,
+		Handler:        api.Compress(router, cfg.Compression),
+		ReadTimeout:    15 * time.Second,
+		WriteTimeout:   15 * time.Second,
+		IdleTimeout:    60 * time.Second,
+		MaxHeaderBytes: cfg.HTTP.MaxHeaderBytes,
+	}
+
+	// Serve HTTPS, with HTTP/2, when TLS is enabled
+	var tlsCerts *server.TLS
+	if cfg.TLS.Enabled {
+		tlsCerts, err = server.NewTLS(cfg.TLS)
+		if err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+		srv.TLSConfig = tlsCerts.Config
 	}
 
+	// Reuse the listeners handed off by a previous process, if any
+	addrs := []string{srv.Addr}
+	if cfg.GRPC.Enabled {
+		addrs = append(addrs, ":"+cfg.GRPC.Port)
+	}
+	if tlsCerts != nil && cfg.TLS.RedirectPort != "" {
+		addrs = append(addrs, ":"+cfg.TLS.RedirectPort)
+	}
+	lns, err := server.Listen(addrs...)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", strings.Join(addrs, ", "), err)
+	}
+	nextLn := lns[1:]
+
 	// Start server in a goroutine
 	go func() {
-		log.Infof("Server starting on port %s", cfg.Port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if tlsCerts != nil {
+			log.Infof("HTTPS server starting on port %s", cfg.Port)
+			err = srv.ServeTLS(lns[0], "", "")
+		} else {
+			log.Infof("Server starting on port %s", cfg.Port)
+			err = srv.Serve(lns[0])
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
 
-	// Wait for interrupt signal to gracefully shutdown the server
+	// Serve gRPC for internal services on its own port
+	var grpcSrv *grpc.Server
+	if cfg.GRPC.Enabled {
+		grpcSrv = handler.NewGRPCServer()
+		grpcLn := nextLn[0]
+		nextLn = nextLn[1:]
+		go func() {
+			log.Infof("gRPC server starting on port %s", cfg.GRPC.Port)
+			if err := grpcSrv.Serve(grpcLn); err != nil {
+				log.Fatalf("Failed to start gRPC server: %v", err)
+			}
+		}()
+	}
+
+	// Redirect plain HTTP to HTTPS on a second port
+	var redirectSrv *http.Server
+	if tlsCerts != nil && cfg.TLS.RedirectPort != "" {
+		redirectSrv = &http.Server{
+			Handler:      tlsCerts.RedirectHandler(cfg.Port),
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 5 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		}
+		redirectLn := nextLn[0]
+		go func() {
+			log.Infof("HTTP redirect server starting on port %s", cfg.TLS.RedirectPort)
+			if err := redirectSrv.Serve(redirectLn); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to start HTTP redirect server: %v", err)
+			}
+		}()
+	}
+
+	// A parent that handed its listeners off waits for this before draining
+	if err := server.Ready(); err != nil {
+		log.WithError(err).Warn("Failed to report readiness to the previous process")
+	}
+
+	// Wait for interrupt signal to gracefully shutdown the server.
+	// SIGUSR2 hands the listening sockets to a new process first, so
+	// a restart does not drop connections. SIGHUP reloads the config
+	// file straight away.
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR2, syscall.SIGHUP)
+	for sig := range quit {
+		if sig == syscall.SIGHUP {
+			_ = configWatcher.Reload()
+			continue
+		}
+		if sig != syscall.SIGUSR2 {
+			break
+		}
+
+		proc, err := server.Restart(restartReadyTimeout, lns...)
+		if err != nil {
+			log.WithError(err).Error("Graceful restart failed, continuing to serve")
+			continue
+		}
+
+		log.WithField("pid", proc.Pid).Info("Listener handed off to new process")
+		break
+	}
 	log.Info("Shutting down server...")
 
 	// Give outstanding requests a deadline for completion
@@ -82,6 +290,55 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	if redirectSrv != nil {
+		redirectSrv.Shutdown(ctx)
+	}
+	if grpcSrv != nil {
+		stopGRPC(ctx, grpcSrv)
+	}
+
+	// Websocket connections are hijacked, so Shutdown doesn't wait for
+	// them; closing the hub ends them
+	stopHub()
+	hub.Close()
+
+	// Let a running sync finish within the same deadline
+	if err := jobManager.Stop(ctx); err != nil {
+		log.WithError(err).Warn("Background jobs did not stop cleanly")
+	}
 
 	log.Info("Server exited")
-}
\ No newline at end of file
+}
+
+// stopGRPC lets in-flight RPCs finish, cancelling any still running when
+// ctx is done
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		srv.Stop()
+	}
+}
+
+// watchSecrets applies rotated secrets to the database, Redis and upstream
+// clients. New connections and requests use the new values.
+func watchSecrets(m *secrets.Manager, cfg *config.Config, db *database.DB, rdb *redis.Client, upstreams *client.Registry) {
+	m.Watch(cfg.Database.PasswordRef, db.SetPassword)
+	m.Watch(cfg.Redis.PasswordRef, rdb.SetPassword)
+	for _, name := range upstreams.Names() {
+		u, err := upstreams.Get(name)
+		if err != nil {
+			continue
+		}
+		upstream := cfg.ExternalAPI.Upstreams[name]
+		m.Watch(upstream.AuthTokenRef, u.SetAuthToken)
+		m.Watch(upstream.AuthPasswordRef, u.SetAuthPassword)
+		m.Watch(upstream.OAuthClientSecretRef, u.SetOAuthClientSecret)
+	}
+}
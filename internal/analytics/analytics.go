@@ -0,0 +1,327 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/redis"
+	"api-gateway-backend/internal/tenant"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// dayBucketTTL lets day buckets expire once they leave the window
+	dayBucketTTL = (StatusWindowDays + 1) * 24 * time.Hour
+
+	dayLayout = "2006-01-02"
+
+	// reconcileAttempts bounds how often a tenant's reconcile is retried
+	// when orders are recorded while it reads the database
+	reconcileAttempts = 5
+)
+
+// keys are the Redis keys of one tenant's aggregates. A tenant's keys share
+// a hash tag, {analytics} for the default tenant and {analytics:<id>} for
+// others, so the MULTI blocks that update them stay on one slot when
+// running against Redis Cluster.
+type keys struct {
+	// Per-day hashes of status -> order count / total amount
+	dayCount  string // prefix, followed by the day
+	dayAmount string // prefix, followed by the day
+
+	// Hash of customer_id -> order count, and sorted set of customer_id
+	// scored by total spend, so the top spenders are read without loading
+	// every customer
+	customerCount string
+	customerSpend string
+
+	// reconciledAt marks the aggregates as seeded from the database
+	reconciledAt string
+	// recorded is bumped by every RecordOrder, so a reconcile can tell
+	// that an order was recorded while it read the database
+	recorded string
+}
+
+// keysFor returns the keys of the aggregates of the tenant of ctx
+func keysFor(ctx context.Context) keys {
+	tag := "{analytics}"
+	if id := tenant.From(ctx); id != tenant.Default {
+		tag = "{analytics:" + id + "}"
+	}
+	return keys{
+		dayCount:      tag + ":orders:count:",
+		dayAmount:     tag + ":orders:amount:",
+		customerCount: tag + ":customers:count",
+		customerSpend: tag + ":customers:top",
+		reconciledAt:  tag + ":reconciled_at",
+		recorded:      tag + ":recorded",
+	}
+}
+
+// StatusWindowDays is how many UTC calendar days of per-status order totals
+// are kept; longer summaries must be read from the database
+const StatusWindowDays = 30
+
+// ErrNotReady is returned when the aggregates have not been reconciled yet
+var ErrNotReady = errors.New("analytics aggregates not reconciled")
+
+// errReconcileContended is returned when orders keep being recorded while
+// a tenant's reconcile reads the database
+var errReconcileContended = errors.New("orders kept being recorded during the reconcile")
+
+// Store keeps order analytics aggregates in Redis, per tenant. Aggregates
+// are incremented on order writes and periodically reconciled against the
+// database, so reads never have to run GROUP BY queries. Until a tenant's
+// aggregates are first reconciled, reads return ErrNotReady and callers
+// query the database.
+type Store struct {
+	db    database.Store
+	redis *redis.Client
+}
+
+// New creates a new analytics store
+func New(db database.Store, rdb *redis.Client) *Store {
+	return &Store{
+		db:    db,
+		redis: rdb,
+	}
+}
+
+// RecordOrder increments the aggregates of the tenant of ctx for a newly
+// written order
+func (s *Store) RecordOrder(ctx context.Context, order *database.Order) error {
+	k := keysFor(ctx)
+	day := order.CreatedAt.UTC().Format(dayLayout)
+
+	_, err := s.redis.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.HIncrBy(ctx, k.dayCount+day, order.Status, 1)
+		pipe.HIncrByFloat(ctx, k.dayAmount+day, order.Status, order.Amount)
+		pipe.Expire(ctx, k.dayCount+day, dayBucketTTL)
+		pipe.Expire(ctx, k.dayAmount+day, dayBucketTTL)
+		pipe.HIncrBy(ctx, k.customerCount, order.CustomerID, 1)
+		pipe.ZIncrBy(ctx, k.customerSpend, order.Amount, order.CustomerID)
+		pipe.Incr(ctx, k.recorded)
+		return nil
+	})
+	return err
+}
+
+// Reconcile rebuilds every tenant's aggregates from the database,
+// correcting any drift from missed or duplicated increments. A tenant that
+// fails doesn't stop the others; the first error is returned.
+func (s *Store) Reconcile(ctx context.Context) error {
+	tenants, err := s.db.ListOrderTenants(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list tenants with orders: %w", err)
+	}
+	// The default tenant's aggregates are ready even before it has orders
+	if !slices.Contains(tenants, tenant.Default) {
+		tenants = append(tenants, tenant.Default)
+	}
+
+	var firstErr error
+	for _, id := range tenants {
+		if err := s.reconcileTenant(tenant.With(ctx, id)); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("tenant %s: %w", id, err)
+		}
+	}
+	return firstErr
+}
+
+// reconcileTenant rebuilds the aggregates of the tenant of ctx. They are
+// replaced under WATCH of the tenant's recorded counter, so an order
+// recorded after the database was read, whose increments the rebuilt
+// aggregates would overwrite, aborts the swap and the tenant is read
+// again. Orders are recorded after they are committed, so any recorded
+// before the WATCH are in what the database returns.
+func (s *Store) reconcileTenant(ctx context.Context) error {
+	k := keysFor(ctx)
+	for attempt := 0; attempt < reconcileAttempts; attempt++ {
+		err := s.redis.Watch(ctx, func(tx *goredis.Tx) error {
+			return s.rebuild(ctx, tx, k)
+		}, k.recorded)
+		if !errors.Is(err, goredis.TxFailedErr) {
+			return err
+		}
+	}
+	return errReconcileContended
+}
+
+// rebuild reads the totals of the tenant of ctx from the database and
+// replaces its aggregates with them in one MULTI within tx
+func (s *Store) rebuild(ctx context.Context, tx *goredis.Tx, k keys) error {
+	daily, err := s.db.GetDailyOrderStatusTotals(ctx, StatusWindowDays)
+	if err != nil {
+		return fmt.Errorf("failed to load daily order totals: %w", err)
+	}
+
+	customers, err := s.db.GetCustomerTotals(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load customer totals: %w", err)
+	}
+
+	dayCounts := make(map[string]map[string]interface{})
+	dayAmounts := make(map[string]map[string]interface{})
+	for _, total := range daily {
+		day := total.Day.Format(dayLayout)
+		if dayCounts[day] == nil {
+			dayCounts[day] = make(map[string]interface{})
+			dayAmounts[day] = make(map[string]interface{})
+		}
+		dayCounts[day][total.Status] = total.OrderCount
+		dayAmounts[day][total.Status] = total.TotalAmount
+	}
+
+	customerCounts := make(map[string]interface{}, len(customers))
+	customerSpend := make([]goredis.Z, 0, len(customers))
+	for _, customer := range customers {
+		customerCounts[customer.CustomerID] = customer.OrderCount
+		customerSpend = append(customerSpend, goredis.Z{Score: customer.TotalSpend, Member: customer.CustomerID})
+	}
+
+	_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, day := range windowDays(time.Now()) {
+			pipe.Del(ctx, k.dayCount+day, k.dayAmount+day)
+		}
+		for day, counts := range dayCounts {
+			pipe.HSet(ctx, k.dayCount+day, counts)
+			pipe.HSet(ctx, k.dayAmount+day, dayAmounts[day])
+			pipe.Expire(ctx, k.dayCount+day, dayBucketTTL)
+			pipe.Expire(ctx, k.dayAmount+day, dayBucketTTL)
+		}
+
+		pipe.Del(ctx, k.customerCount, k.customerSpend)
+		if len(customers) > 0 {
+			pipe.HSet(ctx, k.customerCount, customerCounts)
+			pipe.ZAdd(ctx, k.customerSpend, customerSpend...)
+		}
+
+		pipe.Set(ctx, k.reconciledAt, time.Now().Unix(), 0)
+		return nil
+	})
+	return err
+}
+
+// OrderStatusSummary returns order count and total amount by status over
+// the last days calendar days, including today, summed from the per-day
+// buckets. days may not exceed StatusWindowDays.
+func (s *Store) OrderStatusSummary(ctx context.Context, days int) ([]database.OrderStatusSummary, error) {
+	if days <= 0 || days > StatusWindowDays {
+		return nil, fmt.Errorf("order status window must be between 1 and %d days", StatusWindowDays)
+	}
+	if err := s.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	k := keysFor(ctx)
+	buckets := windowDays(time.Now())[:days]
+	countCmds := make([]*goredis.MapStringStringCmd, len(buckets))
+	amountCmds := make([]*goredis.MapStringStringCmd, len(buckets))
+	_, err := s.redis.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i, day := range buckets {
+			countCmds[i] = pipe.HGetAll(ctx, k.dayCount+day)
+			amountCmds[i] = pipe.HGetAll(ctx, k.dayAmount+day)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byStatus := make(map[string]*database.OrderStatusSummary)
+	for i := range buckets {
+		for status, count := range countCmds[i].Val() {
+			summary := byStatus[status]
+			if summary == nil {
+				summary = &database.OrderStatusSummary{Status: status}
+				byStatus[status] = summary
+			}
+			n, _ := strconv.Atoi(count)
+			amount, _ := strconv.ParseFloat(amountCmds[i].Val()[status], 64)
+			summary.OrderCount += n
+			summary.TotalAmount += amount
+		}
+	}
+
+	summaries := make([]database.OrderStatusSummary, 0, len(byStatus))
+	for _, summary := range byStatus {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].TotalAmount > summaries[j].TotalAmount
+	})
+
+	return summaries, nil
+}
+
+// TopCustomers returns the limit customers with the highest all-time spend.
+// The aggregates are not bucketed by time, so windowed queries must go to
+// the database.
+func (s *Store) TopCustomers(ctx context.Context, limit int) ([]database.TopCustomer, error) {
+	if err := s.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	stop := int64(limit) - 1
+	if limit <= 0 {
+		stop = -1
+	}
+	k := keysFor(ctx)
+	top, err := s.redis.ZRevRangeWithScores(ctx, k.customerSpend, 0, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(top) == 0 {
+		return []database.TopCustomer{}, nil
+	}
+
+	ids := make([]string, len(top))
+	for i, z := range top {
+		ids[i], _ = z.Member.(string)
+	}
+	counts, err := s.redis.HMGet(ctx, k.customerCount, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	customers := make([]database.TopCustomer, len(top))
+	for i, z := range top {
+		customers[i] = database.TopCustomer{CustomerID: ids[i], TotalSpend: z.Score}
+		if count, ok := counts[i].(string); ok {
+			customers[i].OrderCount, _ = strconv.Atoi(count)
+		}
+	}
+	return customers, nil
+}
+
+// ready checks that the aggregates of the tenant of ctx have been
+// reconciled at least once
+func (s *Store) ready(ctx context.Context) error {
+	exists, err := s.redis.Exists(ctx, keysFor(ctx).reconciledAt)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotReady
+	}
+	return nil
+}
+
+// windowDays returns the day bucket names in the status window ending at
+// now. Days are UTC calendar days, like the window the database is asked
+// for, whatever the time zone of this process or of the database server.
+func windowDays(now time.Time) []string {
+	now = now.UTC()
+	days := make([]string, StatusWindowDays)
+	for i := range days {
+		days[i] = now.AddDate(0, 0, -i).Format(dayLayout)
+	}
+	return days
+}
@@ -0,0 +1,20 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowDays(t *testing.T) {
+	// Late evening in New York is already the next day in UTC, where the
+	// window the database is asked for starts
+	newYork := time.FixedZone("EST", -5*60*60)
+	days := windowDays(time.Date(2024, 3, 1, 21, 30, 0, 0, newYork))
+
+	assert.Len(t, days, StatusWindowDays)
+	assert.Equal(t, "2024-03-02", days[0])
+	assert.Equal(t, "2024-03-01", days[1])
+	assert.Equal(t, "2024-02-02", days[StatusWindowDays-1])
+}
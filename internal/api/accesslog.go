@@ -0,0 +1,61 @@
+package api
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// accessLog logs one structured line per request once it has been handled,
+// through the request-scoped entry so the line carries the request ID. The
+// logger's formatter makes these JSON in production. Probe requests are
+// logged at debug level so they don't drown out traffic.
+func (h *Handler) accessLog() HandlerFunc {
+	return func(c Context) {
+		start := time.Now()
+
+		c.Next()
+
+		w := c.Writer()
+		size := w.Size()
+		if size < 0 {
+			size = 0
+		}
+		fields := logrus.Fields{
+			"method":     c.Request().Method,
+			"path":       c.Request().URL.Path,
+			"status":     w.Status(),
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes":      size,
+			"client_ip":  c.ClientIP(),
+			"user_agent": c.Request().UserAgent(),
+		}
+		if key, ok := ClientAPIKey(c); ok {
+			fields["api_key_id"] = key.ID
+			fields["api_key_name"] = key.Name
+		}
+		if client, ok := SigningClient(c); ok {
+			fields["signing_client"] = client.ID
+		}
+		if id, ok := Tenant(c); ok {
+			fields["tenant"] = id
+		}
+		if claims, ok := Claims(c); ok {
+			if sub, err := claims.GetSubject(); err == nil && sub != "" {
+				fields["user"] = sub
+			}
+		}
+
+		entry := h.log(c).WithFields(fields)
+		switch path := c.Request().URL.Path; {
+		case path == "/healthz" || path == "/readyz":
+			entry.Debug("Request handled")
+		case w.Status() >= 500:
+			entry.Error("Request failed")
+		case w.Status() >= 400:
+			entry.Warn("Request rejected")
+		default:
+			entry.Info("Request handled")
+		}
+	}
+}
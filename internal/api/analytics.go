@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/cache"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/response"
+)
+
+const (
+	defaultItemStatsDays = 30
+	maxItemStatsDays     = 365
+
+	defaultItemStatsUsers = 10
+	maxItemStatsUsers     = 100
+)
+
+// analyticsCacheNamespace holds every cached analytics query result. It
+// deliberately excludes the {analytics...} aggregate keys.
+const analyticsCacheNamespace = "analytics"
+
+// orderStatusCacheKeyFor returns the cache key for an order status summary.
+// Preset periods are keyed by name, and a custom period without an end by
+// "open", since those bounds move with the clock. New orders invalidate
+// the cache anyway.
+func orderStatusCacheKeyFor(period reportPeriod) string {
+	params := url.Values{}
+	params.Set("period", period.Name)
+	if period.Name == customSummaryPeriod {
+		params.Set("from", period.From.UTC().Format(time.RFC3339))
+		to := "open"
+		if !period.openEnded {
+			to = period.To.UTC().Format(time.RFC3339)
+		}
+		params.Set("to", to)
+	}
+	if period.GroupBy != "" {
+		params.Set("group_by", period.GroupBy)
+	}
+	return "analytics:orders:status:" + params.Encode()
+}
+
+// topCustomersCacheKeyFor returns the cache key for a top customers query
+func topCustomersCacheKeyFor(filter database.TopCustomersFilter) string {
+	params := url.Values{}
+	params.Set("limit", strconv.Itoa(filter.Limit))
+	if filter.CreatedFrom != nil {
+		params.Set("from", filter.CreatedFrom.UTC().Format(time.RFC3339))
+	}
+	if filter.CreatedTo != nil {
+		params.Set("to", filter.CreatedTo.UTC().Format(time.RFC3339))
+	}
+	return "analytics:customers:top:" + params.Encode()
+}
+
+// customerDetailCacheKeyFor returns the cache key for a customer's detail.
+// since is a month start, so the key holds for the whole month.
+func customerDetailCacheKeyFor(customerID string, since time.Time) string {
+	params := url.Values{}
+	params.Set("id", customerID)
+	params.Set("since", since.Format("2006-01-02"))
+	return "analytics:customers:detail:" + params.Encode()
+}
+
+// itemStatsCacheKeyFor returns the cache key for item statistics. It is in
+// the items namespace, which syncs bump when items change.
+func itemStatsCacheKeyFor(filter database.ItemStatsFilter) string {
+	params := url.Values{}
+	params.Set("days", strconv.Itoa(filter.Days))
+	params.Set("users", strconv.Itoa(filter.Users))
+	return "items:stats:" + params.Encode()
+}
+
+// getItemStats handles GET /api/v1/analytics/items: item counts in total,
+// by user and created per day, and how long ago data was last synced. See
+// itemStats for where the counts come from; sync recency is always current.
+func (h *Handler) getItemStats(c Context) {
+	ctx := c.Request().Context()
+
+	filter, err := parseItemStatsFilter(c)
+	if err != nil {
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", err))
+		return
+	}
+
+	stats, cached, err := h.itemStats(ctx, filter)
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to get item statistics")
+		abortWithError(c, apierror.Internal("failed to retrieve item statistics", err))
+		return
+	}
+
+	// A failed lookup leaves last_sync null rather than failing the counts
+	lastSync := H{"succeeded_at": nil, "age_seconds": nil}
+	source := &response.Cache{Hit: cached}
+	if at, err := h.jobManager.LastSuccessfulSync(ctx); err != nil {
+		h.log(c).WithError(err).Warn("Failed to get last sync time")
+	} else if !at.IsZero() {
+		lastSync = H{"succeeded_at": at, "age_seconds": int64(time.Since(at).Seconds())}
+		source.SyncedAt = &at
+	}
+
+	c.Header("X-Cache", cacheStatus(cached))
+	respond(c, http.StatusOK, reply{
+		Data: stats,
+		Meta: response.Meta{Cache: source, Filters: H{"days": filter.Days, "users": filter.Users}},
+		V1:   H{"last_sync": lastSync},
+	})
+}
+
+// parseItemStatsFilter reads the days and users of an item statistics query
+func parseItemStatsFilter(c Context) (database.ItemStatsFilter, error) {
+	filter := database.ItemStatsFilter{Days: defaultItemStatsDays, Users: defaultItemStatsUsers}
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxItemStatsDays {
+			return filter, fmt.Errorf("days must be between 1 and %d", maxItemStatsDays)
+		}
+		filter.Days = n
+	}
+	if v := c.Query("users"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxItemStatsUsers {
+			return filter, fmt.Errorf("users must be between 1 and %d", maxItemStatsUsers)
+		}
+		filter.Users = n
+	}
+	return filter, nil
+}
+
+// loadAnalytics decodes the analytics result cached at key into dest,
+// calling load on a miss. A ttl of zero disables caching, so every call
+// loads; the result is still decoded through JSON so dest is filled the
+// same way either way. It reports whether the value was cached.
+func (h *Handler) loadAnalytics(ctx context.Context, key string, ttl time.Duration, dest interface{}, load func(ctx context.Context) (interface{}, error)) (bool, error) {
+	if ttl > 0 {
+		return h.cache.GetOrLoad(ctx, h.versions.Key(ctx, key), ttl, dest, load)
+	}
+
+	value, err := load(ctx)
+	if err != nil {
+		return false, err
+	}
+	if e, ok := value.(cache.Expiring); ok {
+		value = e.Value
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	return false, json.Unmarshal(data, dest)
+}
+
+// invalidateAnalytics retires cached analytics results after orders change
+func (h *Handler) invalidateAnalytics(c Context) {
+	version, err := h.versions.Bump(c.Request().Context(), analyticsCacheNamespace)
+	if err != nil {
+		h.log(c).WithError(err).Warn("Failed to invalidate analytics cache")
+		return
+	}
+	h.log(c).WithField("version", version).Debug("Invalidated analytics cache")
+}
+
+// cacheStatus is the X-Cache header value for a result
+func cacheStatus(cached bool) string {
+	if cached {
+		return "HIT"
+	}
+	return "MISS"
+}
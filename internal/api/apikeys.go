@@ -0,0 +1,256 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/audit"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/health"
+	"api-gateway-backend/internal/rbac"
+	"api-gateway-backend/internal/response"
+	"api-gateway-backend/internal/tenant"
+)
+
+const (
+	// apiKeyHeader carries the client API key
+	apiKeyHeader = "X-API-Key"
+
+	// apiKeyContextKey is the context key holding the authenticated key
+	apiKeyContextKey = "api_key"
+
+	// apiKeyCachePrefix namespaces cached key lookups by hash
+	apiKeyCachePrefix = "apikeys:"
+)
+
+// createAPIKeyRequest is the body of POST /admin/api-keys
+type createAPIKeyRequest struct {
+	Name     string   `json:"name" validate:"required,max=255"`
+	Roles    []string `json:"roles,omitempty" validate:"max=20,dive,required"` // empty for the default roles
+	TenantID string   `json:"tenant_id,omitempty"`                             // empty for the caller's tenant
+}
+
+// createdAPIKey is a new API key with the raw key, which is only ever
+// shown in the response that creates it
+type createdAPIKey struct {
+	*database.APIKey
+	Key string `json:"key"`
+}
+
+// normalize trims the name and roles
+func (r *createAPIKeyRequest) normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+	r.TenantID = strings.TrimSpace(r.TenantID)
+	for i, role := range r.Roles {
+		r.Roles[i] = strings.TrimSpace(role)
+	}
+}
+
+// unknownRoles returns a field error for each role that isn't configured
+func (r *createAPIKeyRequest) unknownRoles(roles rbac.Roles) []FieldError {
+	var fields []FieldError
+	for i, role := range r.Roles {
+		if _, ok := roles[role]; !ok {
+			field := fmt.Sprintf("roles[%d]", i)
+			fields = append(fields, FieldError{Field: field, Rule: "role", Message: fmt.Sprintf("%s: unknown role %q", field, role)})
+		}
+	}
+	return fields
+}
+
+// invalidTenant returns a field error if the tenant isn't a valid ID
+func (r *createAPIKeyRequest) invalidTenant() []FieldError {
+	if r.TenantID == "" || tenant.Valid(r.TenantID) {
+		return nil
+	}
+	return []FieldError{{Field: "tenant_id", Rule: "tenant", Message: fmt.Sprintf("tenant_id: invalid tenant %q", r.TenantID)}}
+}
+
+// authenticateAPIKey validates the X-API-Key header and stores the key on
+// the context for downstream handlers. It reports whether the request may
+// proceed, having aborted it otherwise.
+func (h *Handler) authenticateAPIKey(c Context) bool {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	key, err := h.lookupAPIKey(ctx, c.GetHeader(apiKeyHeader))
+	if errors.Is(err, database.ErrNotFound) {
+		abortWithError(c, errUnauthorized.Wrap(errors.New("invalid API key")))
+		return false
+	}
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to look up API key")
+		abortWithError(c, apierror.Internal("failed to authenticate", errors.New("API key lookup failed")))
+		return false
+	}
+
+	c.Set(apiKeyContextKey, key)
+	return true
+}
+
+// lookupAPIKey resolves a raw key to an active API key, caching hits in
+// Redis unless it's known to be down
+func (h *Handler) lookupAPIKey(ctx context.Context, raw string) (*database.APIKey, error) {
+	hash := hashAPIKey(raw)
+	cacheKey := apiKeyCachePrefix + hash
+
+	if h.deps.Down(health.Redis) {
+		return h.db.GetAPIKeyByHash(ctx, hash)
+	}
+
+	var key database.APIKey
+	if err := h.redis.GetJSON(ctx, cacheKey, &key); err == nil {
+		key.KeyHash = hash
+		return &key, nil
+	}
+
+	found, err := h.db.GetAPIKeyByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(h.cfg.Auth.APIKeyCacheTTL) * time.Second
+	if err := h.redis.SetJSON(ctx, cacheKey, found, ttl); err != nil {
+		h.logger.FromContext(ctx).WithError(err).Warn("Failed to cache API key")
+	}
+
+	return found, nil
+}
+
+// ClientAPIKey returns the API key that authenticated the request, if any
+func ClientAPIKey(c Context) (*database.APIKey, bool) {
+	value, ok := c.Get(apiKeyContextKey)
+	if !ok {
+		return nil, false
+	}
+	key, ok := value.(*database.APIKey)
+	return key, ok
+}
+
+// createAPIKey handles POST /admin/api-keys. The plaintext key is only
+// returned in this response.
+func (h *Handler) createAPIKey(c Context) {
+	var req createAPIKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if fields := append(req.unknownRoles(h.cfg.RBAC.Roles), req.invalidTenant()...); len(fields) > 0 {
+		abortWithFieldErrors(c, fields)
+		return
+	}
+
+	raw, err := generateAPIKey()
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to generate API key")
+		abortWithError(c, apierror.Internal("failed to create API key", err))
+		return
+	}
+
+	key := &database.APIKey{
+		Name:      req.Name,
+		KeyPrefix: raw[:10],
+		KeyHash:   hashAPIKey(raw),
+		Roles:     req.Roles,
+		TenantID:  req.TenantID,
+	}
+	owner := keyTenant(c)
+	if key.TenantID == "" {
+		key.TenantID = orDefaultTenant(owner)
+	}
+	if owner != "" && key.TenantID != owner {
+		abortWithError(c, errForbidden.Wrap(fmt.Errorf("cannot issue API keys for tenant %s", key.TenantID)))
+		return
+	}
+	if err := h.db.CreateAPIKey(c.Request().Context(), key); err != nil {
+		h.log(c).WithError(err).Error("Failed to store API key")
+		abortWithError(c, apierror.Internal("failed to create API key", err))
+		return
+	}
+
+	h.log(c).WithFields(map[string]interface{}{
+		"id":   key.ID,
+		"name": key.Name,
+	}).Info("API key issued")
+	h.audit(c, audit.ActionAPIKeyCreate, "api_key:"+strconv.FormatInt(key.ID, 10), nil, key)
+	respond(c, http.StatusCreated, reply{
+		Data: createdAPIKey{APIKey: key, Key: raw},
+		V1:   H{"data": key, "key": raw},
+	})
+}
+
+// listAPIKeys handles GET /admin/api-keys
+func (h *Handler) listAPIKeys(c Context) {
+	keys, err := h.db.ListAPIKeys(c.Request().Context(), keyTenant(c))
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to list API keys")
+		abortWithError(c, apierror.Internal("failed to retrieve API keys", err))
+		return
+	}
+
+	respond(c, http.StatusOK, reply{Data: keys, Meta: response.Meta{Pagination: &response.Pagination{Count: len(keys)}}})
+}
+
+// revokeAPIKey handles DELETE /admin/api-keys/:id
+func (h *Handler) revokeAPIKey(c Context) {
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid API key id", errors.New("id must be a positive integer")))
+		return
+	}
+
+	key, err := h.db.RevokeAPIKey(ctx, id, keyTenant(c))
+	if errors.Is(err, database.ErrNotFound) {
+		abortWithError(c, apierror.NotFound(apierror.CodeAPIKeyNotFound, "API key not found", fmt.Errorf("no active API key with id %d", id)))
+		return
+	}
+	if err != nil {
+		h.log(c).WithError(err).WithField("id", id).Error("Failed to revoke API key")
+		abortWithError(c, apierror.Internal("failed to revoke API key", err))
+		return
+	}
+
+	// Drop the cached lookup so the key stops working immediately
+	if err := h.redis.Del(ctx, apiKeyCachePrefix+key.KeyHash).Err(); err != nil {
+		h.log(c).WithError(err).Warn("Failed to evict revoked API key from cache")
+	}
+
+	h.log(c).WithField("id", id).Info("API key revoked")
+	h.audit(c, audit.ActionAPIKeyRevoke, "api_key:"+strconv.FormatInt(id, 10), H{"revoked_at": nil}, H{"revoked_at": key.RevokedAt})
+	respond(c, http.StatusOK, reply{Data: key})
+}
+
+// keyTenant returns the tenant whose API keys the request may manage: with
+// the admin token any tenant's, shown as "", and otherwise, as with RBAC,
+// only those of the tenant the request acts for
+func keyTenant(c Context) string {
+	if _, ok := c.Get(adminTokenKey); ok {
+		return ""
+	}
+	return tenant.From(c.Request().Context())
+}
+
+// generateAPIKey returns a new random API key
+func generateAPIKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "gw_" + hex.EncodeToString(b), nil
+}
+
+// hashAPIKey returns the hex SHA-256 of a raw key
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/audit"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/response"
+)
+
+const (
+	// actorKey is the context key for who is acting, set by middleware
+	// that authenticates something other than a client
+	actorKey = "actor"
+
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// actor names who made the current request for the audit log: the admin,
+// the webhook sender, an API key by ID or a JWT subject
+func actor(c Context) string {
+	if value, ok := c.Get(actorKey); ok {
+		if name, ok := value.(string); ok {
+			return name
+		}
+	}
+	if key, ok := ClientAPIKey(c); ok {
+		return "api_key:" + strconv.FormatInt(key.ID, 10)
+	}
+	if client, ok := SigningClient(c); ok {
+		return "client:" + client.ID
+	}
+	if claims, ok := Claims(c); ok {
+		if sub, err := claims.GetSubject(); err == nil && sub != "" {
+			return "user:" + sub
+		}
+	}
+	return "anonymous"
+}
+
+// audit records that the current request performed action on target,
+// changing it from before to after; either may be nil
+func (h *Handler) audit(c Context, action, target string, before, after interface{}) {
+	h.auditLog.Record(c.Request().Context(), &database.AuditEvent{
+		Action:    action,
+		Actor:     actor(c),
+		RequestID: RequestID(c),
+		Target:    target,
+		Changes:   audit.Diff(before, after),
+	})
+}
+
+// listAuditEvents handles GET /admin/audit
+func (h *Handler) listAuditEvents(c Context) {
+	ctx := c.Request().Context()
+
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", err))
+		return
+	}
+
+	events, err := h.db.ListAuditEvents(ctx, filter)
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to list audit events")
+		abortWithError(c, apierror.Internal("failed to retrieve audit events", err))
+		return
+	}
+
+	respond(c, http.StatusOK, reply{Data: events, Meta: response.Meta{Pagination: page(len(events), filter.Limit, filter.Offset)}})
+}
+
+// parseAuditFilter builds an audit filter from the request query parameters
+func parseAuditFilter(c Context) (database.AuditFilter, error) {
+	filter := database.AuditFilter{
+		Action: c.Query("action"),
+		Actor:  c.Query("actor"),
+		Target: c.Query("target"),
+		Limit:  defaultAuditLimit,
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return database.AuditFilter{}, fmt.Errorf("from: %w", err)
+		}
+		filter.From = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseEndTimeParam(v)
+		if err != nil {
+			return database.AuditFilter{}, fmt.Errorf("to: %w", err)
+		}
+		filter.To = &t
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxAuditLimit {
+			return database.AuditFilter{}, fmt.Errorf("limit must be between 1 and %d", maxAuditLimit)
+		}
+		filter.Limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return database.AuditFilter{}, fmt.Errorf("offset must be a non-negative integer")
+		}
+		filter.Offset = n
+	}
+	return filter, nil
+}
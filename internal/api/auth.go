@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/oidc"
+	"api-gateway-backend/internal/signing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// claimsKey is the context key holding validated JWT claims
+const claimsKey = "jwt_claims"
+
+// jwtAuthenticator validates bearer tokens against the configured key,
+// algorithm, issuer and audience, or those of an OIDC provider
+type jwtAuthenticator struct {
+	parser *jwt.Parser
+	key    func(ctx context.Context, token *jwt.Token) (interface{}, error)
+}
+
+// newJWTAuthenticator loads the verification key for the configured algorithm
+func newJWTAuthenticator(cfg config.AuthConfig) (*jwtAuthenticator, error) {
+	var key interface{}
+	switch cfg.JWTAlgorithm {
+	case "HS256":
+		if cfg.JWTSecret == "" {
+			return nil, fmt.Errorf("JWT_SECRET is required for HS256")
+		}
+		key = []byte(cfg.JWTSecret)
+	case "RS256":
+		pem, err := os.ReadFile(cfg.JWTPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT public key: %w", err)
+		}
+		key, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWT public key: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.JWTAlgorithm)
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{cfg.JWTAlgorithm}),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.JWTIssuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.JWTIssuer))
+	}
+	if cfg.JWTAudience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.JWTAudience))
+	}
+
+	return &jwtAuthenticator{
+		parser: jwt.NewParser(opts...),
+		key:    func(context.Context, *jwt.Token) (interface{}, error) { return key, nil },
+	}, nil
+}
+
+// oidcAlgorithms are the asymmetric algorithms OIDC providers sign with.
+// HS256 isn't accepted, as it would be keyed with a public key.
+var oidcAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// newOIDCAuthenticator validates tokens issued by the configured OpenID
+// Connect provider for the configured audience, verified with the key the
+// token's kid header names among the provider's published keys
+func newOIDCAuthenticator(cfg config.AuthConfig) *jwtAuthenticator {
+	provider := oidc.NewProvider(cfg.OIDCIssuer, time.Duration(cfg.OIDCJWKSCacheTTL)*time.Second)
+	return &jwtAuthenticator{
+		parser: jwt.NewParser(
+			jwt.WithValidMethods(oidcAlgorithms),
+			jwt.WithExpirationRequired(),
+			jwt.WithIssuer(cfg.OIDCIssuer),
+			jwt.WithAudience(cfg.OIDCAudience),
+		),
+		key: func(ctx context.Context, token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			if kid == "" {
+				return nil, errors.New("token has no kid header")
+			}
+			return provider.Key(ctx, kid)
+		},
+	}
+}
+
+// authenticate rejects requests without a valid bearer token and exposes
+// the token's claims to downstream handlers via Claims. It reports whether
+// the request may proceed, having aborted it otherwise.
+func (a *jwtAuthenticator) authenticate(c Context) bool {
+	header := c.GetHeader("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		c.Header("WWW-Authenticate", `Bearer`)
+		abortWithError(c, errUnauthorized.Wrap(errors.New("missing bearer token")))
+		return false
+	}
+
+	claims, err := a.validate(c.Request().Context(), token)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		abortWithError(c, errUnauthorized.Wrap(errors.New("invalid token")))
+		return false
+	}
+
+	c.Set(claimsKey, claims)
+	return true
+}
+
+// validate parses token and checks its signature and claims
+func (a *jwtAuthenticator) validate(ctx context.Context, token string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return a.key(ctx, t)
+	}); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// authenticate accepts a signed request, an API key or a JWT, depending on
+// which are enabled. A request naming a signing client or presenting an API
+// key is checked against that client or key only. The request then acts
+// for the tenant its credentials belong to; see enterTenant.
+func (h *Handler) authenticate() HandlerFunc {
+	apiKeys := h.cfg.Auth.APIKeysEnabled
+	var signers map[string]*config.SigningClient
+	if h.cfg.Auth.SigningEnabled {
+		signers = newSigningClients(h.cfg.Auth.SigningClients)
+	}
+
+	return func(c Context) {
+		var ok bool
+		switch {
+		case signers != nil && c.GetHeader(signing.ClientHeader) != "":
+			ok = h.authenticateSignature(c, signers)
+		case apiKeys && c.GetHeader(apiKeyHeader) != "":
+			ok = h.authenticateAPIKey(c)
+		case h.jwtAuth != nil:
+			ok = h.jwtAuth.authenticate(c)
+		case apiKeys:
+			abortWithError(c, errUnauthorized.Wrap(errors.New("missing API key")))
+		case signers != nil:
+			abortWithError(c, errUnauthorized.Wrap(errors.New("missing request signature")))
+		default:
+			ok = true
+		}
+		if ok && h.enterTenant(c) {
+			c.Next()
+		}
+	}
+}
+
+// adminAuth guards admin routes with the configured admin token, which
+// holds every permission. With RBAC enabled, a request without the token
+// may instead authenticate as on /api/v1, and then needs the route's admin
+// permission. Otherwise admin routes are unavailable when no token is
+// configured.
+func (h *Handler) adminAuth() HandlerFunc {
+	authenticate := h.authenticate()
+
+	return func(c Context) {
+		if h.cfg.RBAC.Enabled && c.GetHeader("X-Admin-Token") == "" {
+			authenticate(c)
+			return
+		}
+		if h.cfg.Auth.AdminToken == "" {
+			abortWithError(c, errForbidden.Wrap(errors.New("admin API is disabled")))
+			return
+		}
+
+		if !h.hasAdminToken(c) {
+			abortWithError(c, errUnauthorized.Wrap(errors.New("invalid admin token")))
+			return
+		}
+
+		c.Set(actorKey, "admin")
+		c.Set(adminTokenKey, true)
+		c.Next()
+	}
+}
+
+// hasAdminToken reports whether the request carries the configured admin
+// token; never when none is configured
+func (h *Handler) hasAdminToken(c Context) bool {
+	token := []byte(h.cfg.Auth.AdminToken)
+	return len(token) > 0 && subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Admin-Token")), token) == 1
+}
+
+// Claims returns the JWT claims of the authenticated request, if any
+func Claims(c Context) (jwt.MapClaims, bool) {
+	value, ok := c.Get(claimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := value.(jwt.MapClaims)
+	return claims, ok
+}
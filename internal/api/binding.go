@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/webhooks"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// validate checks request bodies against their `validate` struct tags.
+// Fields are reported by their JSON names.
+var validate = newValidator()
+
+// FieldError describes one invalid field of a request body
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// normalizer is implemented by request bodies that tidy their fields, e.g.
+// trimming spaces, before they're validated
+type normalizer interface {
+	normalize()
+}
+
+// bindJSON decodes the request body into dest, normalizes and validates
+// it. If the body is malformed or invalid it aborts with 400 INVALID_REQUEST,
+// listing each invalid field under details.fields, or with 413 if it's too
+// large, and returns false.
+func bindJSON(c Context, dest interface{}) bool {
+	if err := json.NewDecoder(c.Request().Body).Decode(dest); err != nil {
+		abortWithError(c, bodyReadError(err))
+		return false
+	}
+	if n, ok := dest.(normalizer); ok {
+		n.normalize()
+	}
+
+	fields := validateRequest(dest)
+	if len(fields) == 0 {
+		return true
+	}
+	abortWithFieldErrors(c, fields)
+	return false
+}
+
+// abortWithFieldErrors aborts with 400 INVALID_REQUEST, listing fields
+// under details.fields, for checks a validate tag can't express
+func abortWithFieldErrors(c Context, fields []FieldError) {
+	abortWithError(c, apierror.BadRequest(apierror.CodeInvalidRequest, "invalid request body", errors.New(fields[0].Message)).
+		WithDetails(H{"fields": fields}))
+}
+
+// validateRequest returns the fields of v that break their validate tags
+func validateRequest(v interface{}) []FieldError {
+	err := validate.Struct(v)
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+
+	fields := make([]FieldError, len(errs))
+	for i, fe := range errs {
+		// Drop the struct name from e.g. createWebhookRequest.events[0]
+		_, field, _ := strings.Cut(fe.Namespace(), ".")
+		fields[i] = FieldError{Field: field, Rule: fe.Tag(), Message: fieldErrorMessage(field, fe)}
+	}
+	return fields
+}
+
+// fieldErrorMessage explains a broken rule in the style of the API's other
+// error messages
+func fieldErrorMessage(field string, fe validator.FieldError) string {
+	param := fe.Param()
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "max", "min":
+		bound := "at most"
+		if fe.Tag() == "min" {
+			bound = "at least"
+		}
+		switch fe.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("%s must be %s %s characters", field, bound, param)
+		case reflect.Slice, reflect.Map:
+			return fmt.Sprintf("%s must have %s %s entries", field, bound, param)
+		}
+		return fmt.Sprintf("%s must be %s %s", field, bound, param)
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, param)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", field, strings.Join(strings.Fields(param), ", "))
+	case "http_url":
+		return field + " must be an absolute http or https URL"
+	case "log_level":
+		return fmt.Sprintf("%s must be one of %s, got %q", field, strings.Join(logger.Levels, ", "), fe.Value())
+	case "webhook_event":
+		return fmt.Sprintf("%s: unknown event %q, expected one of %s", field, fe.Value(), strings.Join(webhooks.EventTypes, ", "))
+	}
+	return fmt.Sprintf("%s is invalid (%s)", field, fe.Tag())
+}
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	v.RegisterValidation("log_level", func(fl validator.FieldLevel) bool {
+		return validLogLevel(fl.Field().String())
+	})
+	v.RegisterValidation("webhook_event", func(fl validator.FieldLevel) bool {
+		return isWebhookEventType(fl.Field().String())
+	})
+	return v
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/audit"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/events"
+)
+
+// maxBulkItems caps the records of one bulk import
+const maxBulkItems = 5000
+
+// Bulk import record statuses
+const (
+	bulkCreated = "created"
+	bulkUpdated = "updated"
+	bulkFailed  = "failed"
+)
+
+// bulkItem is a record of a POST /api/v1/items/bulk body
+type bulkItem struct {
+	ExternalID string `json:"external_id" validate:"required,max=255"`
+	Title      string `json:"title" validate:"required,max=500"`
+	Body       string `json:"body"`
+	UserID     int    `json:"user_id" validate:"gte=0"`
+}
+
+// normalize trims the external ID
+func (r *bulkItem) normalize() {
+	r.ExternalID = strings.TrimSpace(r.ExternalID)
+}
+
+// bulkResult reports what a bulk import did with one record. Index is the
+// record's position in the body, from 0.
+type bulkResult struct {
+	Index      int          `json:"index"`
+	ExternalID string       `json:"external_id,omitempty"`
+	Status     string       `json:"status"`
+	Reason     string       `json:"reason,omitempty"`
+	Fields     []FieldError `json:"fields,omitempty"`
+}
+
+// bulkImport is the outcome of a bulk import: how many records were
+// created, updated or failed, and the result of each
+type bulkImport struct {
+	Created int          `json:"created"`
+	Updated int          `json:"updated"`
+	Failed  int          `json:"failed"`
+	Results []bulkResult `json:"results"`
+}
+
+// importItems handles POST /api/v1/items/bulk, for backfills without the
+// upstream. The body is a JSON array of items or, with Content-Type
+// application/x-ndjson, one item per line. Each record is validated on its
+// own and the valid ones are upserted through the batch path; the response
+// reports every record as created, updated or failed with the reason.
+func (h *Handler) importItems(c Context) {
+	ctx := c.Request().Context()
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	var records []json.RawMessage
+	var err error
+	if mediaType == ndjsonContentType {
+		records, err = readNDJSONRecords(c.Request().Body)
+	} else {
+		records, err = readJSONRecords(c.Request().Body)
+	}
+	if err != nil {
+		abortWithError(c, bodyReadError(err))
+		return
+	}
+
+	results, items := parseBulkItems(records)
+	if len(items) > 0 {
+		before, err := events.Snapshot(ctx, h.db, items)
+		if err != nil {
+			h.log(c).WithError(err).Error("Failed to load items for bulk import")
+			abortWithError(c, apierror.Internal("failed to import items", err))
+			return
+		}
+		if err := h.db.UpsertItemsBatch(ctx, items, h.cfg.Jobs.SyncBatchSize); err != nil {
+			h.log(c).WithError(err).WithField("count", len(items)).Error("Failed to upsert bulk import")
+			abortWithError(c, apierror.Internal("failed to import items", err))
+			return
+		}
+
+		for i := range results {
+			if results[i].Status != bulkFailed {
+				results[i].Status = bulkCreated
+				if _, ok := before[results[i].ExternalID]; ok {
+					results[i].Status = bulkUpdated
+				}
+			}
+		}
+
+		h.invalidateItems(c)
+		// With the outbox, the relay job publishes the items' events
+		if !h.cfg.Jobs.Outbox {
+			h.publishItemEvents(c, before, items)
+		}
+	}
+
+	counts := map[string]int{bulkCreated: 0, bulkUpdated: 0, bulkFailed: 0}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+	if len(items) > 0 {
+		h.audit(c, audit.ActionItemsImport, "items", nil, H{"created": counts[bulkCreated], "updated": counts[bulkUpdated]})
+	}
+
+	h.log(c).WithField("created", counts[bulkCreated]).
+		WithField("updated", counts[bulkUpdated]).
+		WithField("failed", counts[bulkFailed]).
+		Info("Imported items")
+	summary := bulkImport{Created: counts[bulkCreated], Updated: counts[bulkUpdated], Failed: counts[bulkFailed], Results: results}
+	respond(c, http.StatusOK, reply{
+		Data: summary,
+		V1:   H{"data": results, "created": summary.Created, "updated": summary.Updated, "failed": summary.Failed},
+	})
+}
+
+// parseBulkItems decodes and validates each record, returning a result per
+// record and the items to upsert. Records that fail, including repeats of
+// an external ID earlier in the body, are marked failed with the reason.
+func parseBulkItems(records []json.RawMessage) ([]bulkResult, []database.Item) {
+	results := make([]bulkResult, len(records))
+	items := make([]database.Item, 0, len(records))
+	seen := make(map[string]int, len(records))
+
+	for i, record := range records {
+		results[i] = bulkResult{Index: i}
+
+		var req bulkItem
+		if err := json.Unmarshal(record, &req); err != nil {
+			results[i].Status, results[i].Reason = bulkFailed, fmt.Sprintf("invalid item: %v", err)
+			continue
+		}
+		req.normalize()
+		results[i].ExternalID = req.ExternalID
+
+		if fields := validateRequest(&req); len(fields) > 0 {
+			results[i].Status, results[i].Reason, results[i].Fields = bulkFailed, fields[0].Message, fields
+			continue
+		}
+		if first, ok := seen[req.ExternalID]; ok {
+			results[i].Status, results[i].Reason = bulkFailed, fmt.Sprintf("duplicate of the item at index %d", first)
+			continue
+		}
+		seen[req.ExternalID] = i
+
+		items = append(items, database.Item{
+			ExternalID: req.ExternalID,
+			Title:      req.Title,
+			Body:       req.Body,
+			UserID:     req.UserID,
+		})
+	}
+	return results, items
+}
+
+// readJSONRecords splits a JSON array into its elements. The array must be
+// well-formed, as a syntax error leaves no way to find the next record.
+func readJSONRecords(r io.Reader) ([]json.RawMessage, error) {
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('[') {
+		return nil, errors.New("body must be a JSON array of items")
+	}
+
+	var records []json.RawMessage
+	for dec.More() {
+		if len(records) == maxBulkItems {
+			return nil, tooManyBulkItems()
+		}
+		var record json.RawMessage
+		if err := dec.Decode(&record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON array")
+	}
+	return records, nil
+}
+
+// readNDJSONRecords splits NDJSON into its lines, skipping blank ones. A
+// malformed line only fails its own record.
+func readNDJSONRecords(r io.Reader) ([]json.RawMessage, error) {
+	reader := bufio.NewReader(r)
+	var records []json.RawMessage
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if len(records) == maxBulkItems {
+				return nil, tooManyBulkItems()
+			}
+			records = append(records, json.RawMessage(line))
+		}
+		if err == io.EOF {
+			return records, nil
+		}
+	}
+}
+
+// tooManyBulkItems is the error for a bulk import over maxBulkItems
+func tooManyBulkItems() error {
+	return fmt.Errorf("at most %d items per import", maxBulkItems)
+}
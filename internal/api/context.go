@@ -0,0 +1,75 @@
+package api
+
+import (
+	"io"
+	"net/http"
+)
+
+// HandlerFunc handles a request through the router-agnostic Context.
+// Middleware is a HandlerFunc that calls Next to continue the chain.
+type HandlerFunc func(Context)
+
+// Context is the request/response surface handlers and middleware are
+// written against, so they do not depend on a specific HTTP framework
+type Context interface {
+	// Request returns the incoming HTTP request
+	Request() *http.Request
+	// SetRequest replaces the request, e.g. to attach context values
+	SetRequest(r *http.Request)
+	// Writer returns the response writer for the current request
+	Writer() ResponseWriter
+	// TeeBody copies the response body written from now on to w as well
+	TeeBody(w io.Writer)
+	// Param returns the value of a path parameter (e.g. ":id")
+	Param(key string) string
+	// Query returns the first value of a URL query parameter
+	Query(key string) string
+	// ClientIP returns the client's IP address
+	ClientIP() string
+	// GetHeader returns a request header value
+	GetHeader(key string) string
+	// Header sets a response header
+	Header(key, value string)
+	// Set stores a request-scoped value
+	Set(key string, value interface{})
+	// Get retrieves a request-scoped value
+	Get(key string) (interface{}, bool)
+	// JSON writes obj as a JSON response with the given status code
+	JSON(code int, obj interface{})
+	// Status sets the response status code
+	Status(code int)
+	// Next runs the remaining handlers in the chain
+	Next()
+	// Abort prevents pending handlers in the chain from running
+	Abort()
+	// AbortWithStatus aborts the chain and writes the status code
+	AbortWithStatus(code int)
+	// AbortWithStatusJSON aborts the chain and writes obj as JSON
+	AbortWithStatusJSON(code int, obj interface{})
+	// IsAborted reports whether the chain was aborted
+	IsAborted() bool
+}
+
+// ResponseWriter extends http.ResponseWriter with the state middleware
+// needs to inspect after the handler has run
+type ResponseWriter interface {
+	http.ResponseWriter
+	// Status returns the response status code
+	Status() int
+	// Size returns the number of body bytes written
+	Size() int
+	// Written reports whether the response header has been written
+	Written() bool
+}
+
+// Router registers handlers on an underlying HTTP router. Paths use
+// ":name" for a single-segment parameter and "*name" for a trailing
+// wildcard, regardless of the adapter.
+type Router interface {
+	// Use adds middleware applied to every route on this router
+	Use(middleware ...HandlerFunc)
+	// Group returns a sub-router for the given path prefix
+	Group(prefix string, middleware ...HandlerFunc) Router
+	// Handle registers handlers for a method and path
+	Handle(method, path string, handlers ...HandlerFunc)
+}
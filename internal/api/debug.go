@@ -0,0 +1,118 @@
+package api
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// startedAt is when the process started, for the uptime in runtime stats
+var startedAt = time.Now()
+
+// recentGCPauses is how many of the latest GC pauses runtime stats list
+const recentGCPauses = 10
+
+// runtimeStats is the body of GET /admin/debug/runtime
+type runtimeStats struct {
+	GoVersion     string    `json:"go_version"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+	Goroutines    int       `json:"goroutines"`
+	NumCPU        int       `json:"num_cpu"`
+	GOMAXPROCS    int       `json:"gomaxprocs"`
+	Heap          heapStats `json:"heap"`
+	GC            gcStats   `json:"gc"`
+}
+
+// heapStats are the memory figures from runtime.MemStats, in bytes
+type heapStats struct {
+	Alloc      uint64 `json:"alloc_bytes"`
+	TotalAlloc uint64 `json:"total_alloc_bytes"`
+	Sys        uint64 `json:"sys_bytes"`
+	InUse      uint64 `json:"inuse_bytes"`
+	Idle       uint64 `json:"idle_bytes"`
+	Released   uint64 `json:"released_bytes"`
+	Objects    uint64 `json:"objects"`
+}
+
+// gcStats describe garbage collection since the process started
+type gcStats struct {
+	NumGC          uint32     `json:"num_gc"`
+	NextGCBytes    uint64     `json:"next_gc_bytes"`
+	LastGC         *time.Time `json:"last_gc,omitempty"`
+	PauseTotalMS   float64    `json:"pause_total_ms"`
+	RecentPausesMS []float64  `json:"recent_pauses_ms"` // most recent first
+	CPUFraction    float64    `json:"cpu_fraction"`
+}
+
+// getRuntimeStats handles GET /admin/debug/runtime. Reading the stats
+// briefly stops the world, so it isn't meant for frequent polling.
+func (h *Handler) getRuntimeStats(c Context) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	stats := runtimeStats{
+		GoVersion:     runtime.Version(),
+		UptimeSeconds: time.Since(startedAt).Seconds(),
+		Goroutines:    runtime.NumGoroutine(),
+		NumCPU:        runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		Heap: heapStats{
+			Alloc:      m.HeapAlloc,
+			TotalAlloc: m.TotalAlloc,
+			Sys:        m.HeapSys,
+			InUse:      m.HeapInuse,
+			Idle:       m.HeapIdle,
+			Released:   m.HeapReleased,
+			Objects:    m.HeapObjects,
+		},
+		GC: gcStats{
+			NumGC:          m.NumGC,
+			NextGCBytes:    m.NextGC,
+			PauseTotalMS:   float64(m.PauseTotalNs) / 1e6,
+			RecentPausesMS: []float64{},
+			CPUFraction:    m.GCCPUFraction,
+		},
+	}
+	if m.LastGC > 0 {
+		last := time.Unix(0, int64(m.LastGC)).UTC()
+		stats.GC.LastGC = &last
+	}
+	// PauseNs is a circular buffer whose latest entry is at (NumGC+255)%256
+	for i := uint32(0); i < m.NumGC && i < recentGCPauses; i++ {
+		pause := m.PauseNs[(m.NumGC-1-i)%uint32(len(m.PauseNs))]
+		stats.GC.RecentPausesMS = append(stats.GC.RecentPausesMS, float64(pause)/1e6)
+	}
+
+	respond(c, http.StatusOK, reply{Data: stats})
+}
+
+// serveExpvar handles GET /admin/debug/vars, the variables published
+// through the expvar package (memstats and cmdline by default)
+func (h *Handler) serveExpvar(c Context) {
+	expvar.Handler().ServeHTTP(c.Writer(), c.Request())
+}
+
+// servePprof handles /admin/debug/pprof/*profile. The profile is taken
+// from the path rather than the URL prefix net/http/pprof expects, so it
+// works under any mount point. CPU profiles and traces run for ?seconds=,
+// which must stay below the server's write timeout.
+func (h *Handler) servePprof(c Context) {
+	w, r := c.Writer(), c.Request()
+	switch name := strings.Trim(c.Param("profile"), "/"); name {
+	case "":
+		pprof.Index(w, r)
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Handler(name).ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"api-gateway-backend/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// debugLogHeader asks for the request to be captured, e.g. X-Debug-Log: true
+const debugLogHeader = "X-Debug-Log"
+
+// debugLog logs the headers and bodies of a request and its response, up
+// to DEBUG_LOG_MAX_BODY_SIZE bytes of each body, for diagnosing client and
+// upstream integrations. A DEBUG_LOG_SAMPLE_RATE share of requests is
+// captured while DEBUG_LOG_ENABLED is set, and an admin can capture any
+// request by sending the X-Debug-Log header with the admin token. Headers
+// and query parameters named like redacted log fields or listed in
+// DEBUG_LOG_REDACT_HEADERS are logged as [REDACTED]. The settings are read
+// per request so a config reload can change them.
+func (h *Handler) debugLog() HandlerFunc {
+	return func(c Context) {
+		cfg := h.live.Load().debugLog
+		if !h.captureRequest(c, cfg) {
+			c.Next()
+			return
+		}
+
+		// Bodies are captured as the handler reads and writes them
+		r := c.Request()
+		requestBody := &limitedBuffer{limit: cfg.MaxBodySize}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = teeReadCloser{Reader: io.TeeReader(r.Body, requestBody), Closer: r.Body}
+		}
+		responseBody := &limitedBuffer{limit: cfg.MaxBodySize}
+		c.TeeBody(responseBody)
+		start := time.Now()
+
+		c.Next()
+
+		w := c.Writer()
+		h.log(c).WithFields(logrus.Fields{
+			"method":           r.Method,
+			"path":             r.URL.Path,
+			"query":            h.debugValues(r.URL.Query(), cfg),
+			"status":           w.Status(),
+			"latency_ms":       float64(time.Since(start).Microseconds()) / 1000,
+			"request_headers":  h.debugValues(r.Header, cfg),
+			"request_body":     requestBody.text(r.Header),
+			"response_headers": h.debugValues(w.Header(), cfg),
+			"response_body":    responseBody.text(w.Header()),
+		}).Info("Request captured")
+	}
+}
+
+// captureRequest reports whether the request is to be captured: when an
+// admin asks for it, or when it's sampled
+func (h *Handler) captureRequest(c Context, cfg config.DebugLogConfig) bool {
+	if cfg.HeaderEnabled {
+		if on, err := strconv.ParseBool(c.GetHeader(debugLogHeader)); err == nil && on && h.hasAdminToken(c) {
+			return true
+		}
+	}
+	// Probes would only crowd out the traffic worth capturing
+	if path := c.Request().URL.Path; path == "/healthz" || path == "/readyz" {
+		return false
+	}
+	return cfg.Enabled && rand.Float64() < cfg.SampleRate
+}
+
+// debugValues returns headers or query parameters with each one's values
+// joined into one string and sensitive values replaced with [REDACTED]
+func (h *Handler) debugValues(params map[string][]string, cfg config.DebugLogConfig) map[string]string {
+	logged := make(map[string]string, len(params))
+	for name, values := range params {
+		if h.redacted(name, cfg) {
+			logged[name] = "[REDACTED]"
+			continue
+		}
+		logged[name] = strings.Join(values, ", ")
+	}
+	return logged
+}
+
+// redacted reports whether the values of a header or query parameter are
+// kept out of the log
+func (h *Handler) redacted(name string, cfg config.DebugLogConfig) bool {
+	for _, redacted := range cfg.RedactHeaders {
+		if strings.EqualFold(name, redacted) {
+			return true
+		}
+	}
+	return h.logger.Redacted(name)
+}
+
+// limitedBuffer keeps the first limit bytes written to it and counts the
+// rest
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+	total int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+// text returns the captured body for the log, noting how much was left
+// out. Encoded or binary bodies are only described.
+func (b *limitedBuffer) text(header http.Header) string {
+	if b.total == 0 {
+		return ""
+	}
+	if enc := header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		return fmt.Sprintf("[%d bytes, %s encoded]", b.total, enc)
+	}
+	body := b.buf.Bytes()
+	if b.total > len(body) {
+		// The limit may have cut the last character short
+		for i := 1; i < utf8.UTFMax && len(body) > 0 && !utf8.Valid(body); i++ {
+			body = body[:len(body)-1]
+		}
+	}
+	if !utf8.Valid(body) {
+		return fmt.Sprintf("[%d bytes of binary data]", b.total)
+	}
+	if b.total > len(body) {
+		return fmt.Sprintf("%s... [%d more bytes]", body, b.total-len(body))
+	}
+	return string(body)
+}
+
+// teeReadCloser is a request body read through a tee
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/response"
+)
+
+const (
+	// apiErrorKey is the context key an aborted request's error is stored under
+	apiErrorKey = "api_error"
+
+	// reportedKey marks a request whose failure was already reported
+	reportedKey = "error_reported"
+)
+
+var (
+	errUnauthorized = apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	errForbidden    = apierror.New(http.StatusForbidden, apierror.CodeForbidden, "forbidden")
+)
+
+// abortWithError stops the handler chain and records err for renderErrors
+func abortWithError(c Context, err *apierror.Error) {
+	c.Set(apiErrorKey, err)
+	c.Abort()
+}
+
+// renderErrors writes the error recorded by abortWithError as the standard
+// error body: {"error", "code", "message", "details", "request_id"}, or the
+// response.Envelope's error for version 2 requests. It runs
+// after the rest of the chain, so handlers and middleware only describe the
+// failure and every endpoint reports it the same way. Server errors are
+// also sent to the error reporter.
+func (h *Handler) renderErrors() HandlerFunc {
+	return func(c Context) {
+		c.Next()
+
+		value, ok := c.Get(apiErrorKey)
+		if !ok {
+			return
+		}
+		err, ok := value.(*apierror.Error)
+		if !ok {
+			return
+		}
+		if _, reported := c.Get(reportedKey); err.Status >= http.StatusInternalServerError && !reported {
+			h.reporter.CaptureError(err, c.Request(), h.reportTags(c, err))
+		}
+		if c.Writer().Written() {
+			return
+		}
+
+		if requestedVersion(c) == apiV2 {
+			c.JSON(err.Status, response.Failure(err, RequestID(c)))
+			return
+		}
+		body := err.Body()
+		if id := RequestID(c); id != "" {
+			body["request_id"] = id
+		}
+		c.JSON(err.Status, body)
+	}
+}
+
+// recoverPanics turns a panic in a handler into a 500 response and reports
+// it, with the panic's stack, to the error reporter
+func (h *Handler) recoverPanics() HandlerFunc {
+	return func(c Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+
+			err := apierror.Internal("internal server error", fmt.Errorf("panic: %v", r))
+			h.log(c).WithField("panic", r).Error("Handler panicked")
+			h.reporter.CapturePanic(r, c.Request(), h.reportTags(c, err))
+			c.Set(reportedKey, true)
+			abortWithError(c, err)
+		}()
+
+		c.Next()
+	}
+}
+
+// reportTags describes the request err happened in for the error reporter
+func (h *Handler) reportTags(c Context, err *apierror.Error) map[string]string {
+	tags := map[string]string{
+		"request_id": RequestID(c),
+		"code":       string(err.Code),
+		"status":     strconv.Itoa(err.Status),
+	}
+	if key, ok := ClientAPIKey(c); ok {
+		tags["api_key_id"] = strconv.FormatInt(key.ID, 10)
+	}
+	return tags
+}
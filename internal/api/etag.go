@@ -0,0 +1,56 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"api-gateway-backend/internal/database"
+)
+
+// itemsETag derives a weak ETag for items served under cacheKey in API
+// version. Every write to an item bumps its updated_at, so the count and
+// newest update change whenever the listing does, without re-serializing
+// the payload. Each version's body has its own ETag, so a cached body of
+// one is never revalidated for the other.
+func itemsETag(version int, cacheKey string, items ...database.Item) string {
+	var newest int64
+	for _, item := range items {
+		if ts := item.UpdatedAt.UnixNano(); ts > newest {
+			newest = ts
+		}
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("v%d|%s|%d|%d", version, cacheKey, len(items), newest)))
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified sets the ETag header and, when the request's If-None-Match
+// already names it, writes 304 Not Modified and reports true
+func notModified(c Context, etag string) bool {
+	c.Header("ETag", etag)
+
+	header := c.GetHeader("If-None-Match")
+	if header == "" || !etagMatches(header, etag) {
+		return false
+	}
+
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// etagMatches applies the weak comparison If-None-Match requires
+func etagMatches(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
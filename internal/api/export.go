@@ -0,0 +1,400 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/database"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	csvContentType    = "text/csv; charset=utf-8"
+	ndjsonContentType = "application/x-ndjson"
+	xlsxContentType   = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	// flushRows is how many CSV or NDJSON rows are buffered before being sent
+	flushRows = 500
+
+	// exportTimeout bounds an export streamed from the database, which can
+	// run much longer than a JSON page; see itemsTimeout
+	exportTimeout = 5 * time.Minute
+)
+
+// exportFormat is the representation of a tabular response
+type exportFormat string
+
+const (
+	formatJSON   exportFormat = "json"
+	formatCSV    exportFormat = "csv"
+	formatNDJSON exportFormat = "ndjson"
+	formatXLSX   exportFormat = "xlsx"
+)
+
+// Columns of each export, in row order
+var (
+	itemColumns         = []string{"id", "external_id", "title", "body", "user_id", "created_at", "updated_at", "deleted_at"}
+	orderStatusColumns  = []string{"period_start", "status", "order_count", "total_amount"}
+	topCustomersColumns = []string{"rank", "customer_id", "total_spend", "order_count"}
+)
+
+// parseExportFormat picks the response format from ?format=, falling back
+// to the first of JSON, CSV, NDJSON or XLSX named in the Accept header
+func parseExportFormat(c Context) (exportFormat, error) {
+	switch v := c.Query("format"); v {
+	case "":
+	case string(formatJSON), string(formatCSV), string(formatNDJSON), string(formatXLSX):
+		return exportFormat(v), nil
+	default:
+		return "", fmt.Errorf("format must be one of json, csv, ndjson, xlsx")
+	}
+
+	for _, accept := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return formatJSON, nil
+		case "text/csv":
+			return formatCSV, nil
+		case ndjsonContentType:
+			return formatNDJSON, nil
+		case xlsxContentType:
+			return formatXLSX, nil
+		}
+	}
+	return formatJSON, nil
+}
+
+// tableWriter writes an export's rows to the response. Response headers
+// are only sent with the first bytes of the body, so a failure before then
+// can still be reported as a JSON error.
+type tableWriter interface {
+	// WriteRow writes one row, with values in column order
+	WriteRow(values ...interface{}) error
+	// Close sends what is left of the export
+	Close() error
+	// Discard abandons the export, releasing its resources
+	Discard()
+}
+
+// newTableWriter starts an export of columns. CSV and XLSX are downloaded
+// as name.<format> and begin with a header row; NDJSON is streamed inline
+// with one object per row.
+func newTableWriter(c Context, format exportFormat, name string, columns []string) (tableWriter, error) {
+	w := &exportResponse{c: c, contentType: csvContentType, filename: name + ".csv"}
+	switch format {
+	case formatXLSX:
+		w.contentType, w.filename = xlsxContentType, name+".xlsx"
+		return newXLSXTableWriter(w, name, columns)
+	case formatNDJSON:
+		w.contentType, w.filename = ndjsonContentType, ""
+		return &ndjsonTableWriter{w: bufio.NewWriter(w), out: w, columns: columns}, nil
+	}
+
+	tw := &csvTableWriter{w: csv.NewWriter(w), out: w}
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column
+	}
+	return tw, tw.WriteRow(header...)
+}
+
+// exportResponse writes an export to the response, setting its headers
+// before the first write. An empty filename sends the export inline.
+type exportResponse struct {
+	c           Context
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (w *exportResponse) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.c.Header("Content-Type", w.contentType)
+		if w.filename != "" {
+			w.c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", w.filename))
+		}
+		w.c.Status(http.StatusOK)
+	}
+	return w.c.Writer().Write(p)
+}
+
+// flush sends what has been written so far to the client
+func (w *exportResponse) flush() {
+	if w.started {
+		http.NewResponseController(w.c.Writer()).Flush()
+	}
+}
+
+// csvTableWriter streams rows as CSV, flushing every flushRows rows
+type csvTableWriter struct {
+	w    *csv.Writer
+	out  *exportResponse
+	rows int
+}
+
+func (t *csvTableWriter) WriteRow(values ...interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = csvValue(v)
+	}
+	if err := t.w.Write(record); err != nil {
+		return err
+	}
+
+	t.rows++
+	if t.rows%flushRows == 0 {
+		t.w.Flush()
+		t.out.flush()
+	}
+	return t.w.Error()
+}
+
+func (t *csvTableWriter) Close() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+func (t *csvTableWriter) Discard() {}
+
+// csvValue formats a cell. Text starting with a formula character is
+// prefixed with ' so spreadsheets don't evaluate data as a formula.
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ndjsonTableWriter streams rows as newline-delimited JSON objects keyed
+// by column, in column order, flushing every flushRows rows
+type ndjsonTableWriter struct {
+	w       *bufio.Writer
+	out     *exportResponse
+	columns []string
+	line    bytes.Buffer
+	rows    int
+}
+
+func (t *ndjsonTableWriter) WriteRow(values ...interface{}) error {
+	t.line.Reset()
+	t.line.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			t.line.WriteByte(',')
+		}
+		key, err := json.Marshal(t.columns[i])
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		t.line.Write(key)
+		t.line.WriteByte(':')
+		t.line.Write(value)
+	}
+	t.line.WriteString("}\n")
+	if _, err := t.w.Write(t.line.Bytes()); err != nil {
+		return err
+	}
+
+	t.rows++
+	if t.rows%flushRows == 0 {
+		if err := t.w.Flush(); err != nil {
+			return err
+		}
+		t.out.flush()
+	}
+	return nil
+}
+
+func (t *ndjsonTableWriter) Close() error {
+	return t.w.Flush()
+}
+
+func (t *ndjsonTableWriter) Discard() {}
+
+// xlsxTableWriter writes rows to a single-sheet workbook. The zip container
+// can only be sent once complete, so rows are streamed to a temporary file
+// once they outgrow memory and the workbook is sent on Close.
+type xlsxTableWriter struct {
+	out   *exportResponse
+	file  *excelize.File
+	sheet *excelize.StreamWriter
+	rows  int
+}
+
+func newXLSXTableWriter(out *exportResponse, name string, columns []string) (*xlsxTableWriter, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName(file.GetSheetName(0), name); err != nil {
+		file.Close()
+		return nil, err
+	}
+	sheet, err := file.NewStreamWriter(name)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	t := &xlsxTableWriter{out: out, file: file, sheet: sheet}
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column
+	}
+	if err := t.WriteRow(header...); err != nil {
+		t.Discard()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *xlsxTableWriter) WriteRow(values ...interface{}) error {
+	for i, v := range values {
+		switch v := v.(type) {
+		case time.Time:
+			values[i] = v.UTC()
+		case *time.Time:
+			if v == nil {
+				values[i] = nil
+			} else {
+				values[i] = v.UTC()
+			}
+		}
+	}
+
+	t.rows++
+	cell, err := excelize.CoordinatesToCellName(1, t.rows)
+	if err != nil {
+		return err
+	}
+	return t.sheet.SetRow(cell, values)
+}
+
+func (t *xlsxTableWriter) Close() error {
+	defer t.file.Close()
+	if err := t.sheet.Flush(); err != nil {
+		return err
+	}
+	_, err := t.file.WriteTo(t.out)
+	return err
+}
+
+func (t *xlsxTableWriter) Discard() {
+	t.file.Close()
+}
+
+// itemsTimeout is the timeout of GET /api/v1/items: exports get
+// exportTimeout, pages of JSON listTimeout
+func itemsTimeout(c Context) time.Duration {
+	if format, err := parseExportFormat(c); err == nil && format != formatJSON {
+		return exportTimeout
+	}
+	return listTimeout
+}
+
+// exportItems streams the items matching filter as CSV, NDJSON or XLSX.
+// Rows are read straight from the database rather than the cached listing,
+// so large item sets are never held in memory.
+func (h *Handler) exportItems(c Context, format exportFormat, filter database.ItemFilter) {
+	ctx := c.Request().Context()
+
+	// Let the download outlast the server's write timeout, where the
+	// response writer supports it
+	http.NewResponseController(c.Writer()).SetWriteDeadline(time.Now().Add(exportTimeout))
+
+	w, err := newTableWriter(c, format, "items", itemColumns)
+	if err == nil {
+		err = h.db.StreamItems(ctx, filter, func(item database.Item) error {
+			return w.WriteRow(item.ID, item.ExternalID, item.Title, item.Body, item.UserID, item.CreatedAt, item.UpdatedAt, item.DeletedAt)
+		})
+		err = finishTable(w, err)
+	}
+	h.finishExport(c, "items", err)
+}
+
+// exportOrderStatusSummary writes an order status summary as CSV, NDJSON or XLSX
+func (h *Handler) exportOrderStatusSummary(c Context, format exportFormat, period reportPeriod, summaries []database.OrderStatusSummary) {
+	w, err := newTableWriter(c, format, "order-status-"+period.Name, orderStatusColumns)
+	if err == nil {
+		for _, s := range summaries {
+			if err = w.WriteRow(s.PeriodStart, s.Status, s.OrderCount, s.TotalAmount); err != nil {
+				break
+			}
+		}
+		err = finishTable(w, err)
+	}
+	h.finishExport(c, "order status summary", err)
+}
+
+// exportTopCustomers writes a top customers ranking as CSV, NDJSON or XLSX
+func (h *Handler) exportTopCustomers(c Context, format exportFormat, customers []database.TopCustomer) {
+	w, err := newTableWriter(c, format, "top-customers", topCustomersColumns)
+	if err == nil {
+		for i, customer := range customers {
+			if err = w.WriteRow(i+1, customer.CustomerID, customer.TotalSpend, customer.OrderCount); err != nil {
+				break
+			}
+		}
+		err = finishTable(w, err)
+	}
+	h.finishExport(c, "top customers", err)
+}
+
+// finishTable closes w, or discards it if writing failed
+func finishTable(w tableWriter, err error) error {
+	if err != nil {
+		w.Discard()
+		return err
+	}
+	return w.Close()
+}
+
+// finishExport reports a failed export. Once rows have been sent the
+// status can't change, so the truncated download is only logged.
+func (h *Handler) finishExport(c Context, name string, err error) {
+	if err == nil {
+		return
+	}
+	if c.Writer().Written() {
+		h.log(c).WithError(err).Errorf("Export of %s failed after rows were sent", name)
+		return
+	}
+	h.log(c).WithError(err).Errorf("Failed to export %s", name)
+	abortWithError(c, apierror.Internal("failed to export "+name, err))
+}
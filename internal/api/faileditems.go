@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/audit"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/response"
+)
+
+const (
+	defaultFailedItemsLimit = 20
+	maxFailedItemsLimit     = 100
+)
+
+// listFailedItems handles GET /admin/failed-items, the items given up on
+// after their upserts kept failing
+func (h *Handler) listFailedItems(c Context) {
+	limit, offset := defaultFailedItemsLimit, 0
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxFailedItemsLimit {
+			abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", fmt.Errorf("limit must be between 1 and %d", maxFailedItemsLimit)))
+			return
+		}
+		limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", errors.New("offset must be a non-negative integer")))
+			return
+		}
+		offset = n
+	}
+
+	items, err := h.db.ListFailedItems(c.Request().Context(), limit, offset)
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to list failed items")
+		abortWithError(c, apierror.Internal("failed to retrieve failed items", err))
+		return
+	}
+
+	respond(c, http.StatusOK, reply{Data: items, Meta: response.Meta{Pagination: page(len(items), limit, offset)}})
+}
+
+// requeueFailedItem handles POST /admin/failed-items/:id/requeue. The item
+// leaves the dead letters and is retried by the next item retry run.
+func (h *Handler) requeueFailedItem(c Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid failed item id", errors.New("id must be a positive integer")))
+		return
+	}
+
+	item, err := h.jobManager.RequeueFailedItem(c.Request().Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		abortWithError(c, apierror.NotFound(apierror.CodeFailedItemNotFound, "failed item not found", fmt.Errorf("no failed item with id %d", id)))
+		return
+	}
+	if err != nil {
+		h.log(c).WithError(err).WithField("id", id).Error("Failed to requeue failed item")
+		abortWithError(c, apierror.Internal("failed to requeue failed item", err))
+		return
+	}
+
+	h.log(c).WithFields(map[string]interface{}{
+		"id":          id,
+		"external_id": item.ExternalID,
+	}).Info("Failed item requeued")
+	h.audit(c, audit.ActionFailedItemRequeue, "failed_item:"+strconv.FormatInt(id, 10), nil, H{"external_id": item.ExternalID, "tenant_id": item.TenantID})
+	respond(c, http.StatusAccepted, reply{Data: item, Meta: response.Meta{Message: "item requeued"}})
+}
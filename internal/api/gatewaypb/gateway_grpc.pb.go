@@ -0,0 +1,276 @@
+// Gateway exposes items, order analytics and syncs over gRPC for internal
+// services. It serves the same data, caches and validation as /api/v1.
+//
+// Regenerate the Go code with `make proto`.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: gateway/v1/gateway.proto
+
+package gatewaypb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Gateway_ListItems_FullMethodName             = "/gateway.v1.Gateway/ListItems"
+	Gateway_GetItem_FullMethodName               = "/gateway.v1.Gateway/GetItem"
+	Gateway_GetOrderStatusSummary_FullMethodName = "/gateway.v1.Gateway/GetOrderStatusSummary"
+	Gateway_GetTopCustomers_FullMethodName       = "/gateway.v1.Gateway/GetTopCustomers"
+	Gateway_Sync_FullMethodName                  = "/gateway.v1.Gateway/Sync"
+)
+
+// GatewayClient is the client API for Gateway service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type GatewayClient interface {
+	// ListItems returns synced items, like GET /api/v1/items
+	ListItems(ctx context.Context, in *ListItemsRequest, opts ...grpc.CallOption) (*ListItemsResponse, error)
+	// GetItem returns one item, like GET /api/v1/items/:id
+	GetItem(ctx context.Context, in *GetItemRequest, opts ...grpc.CallOption) (*GetItemResponse, error)
+	// GetOrderStatusSummary returns order counts and totals by status, like
+	// GET /api/v1/analytics/orders/status
+	GetOrderStatusSummary(ctx context.Context, in *GetOrderStatusSummaryRequest, opts ...grpc.CallOption) (*GetOrderStatusSummaryResponse, error)
+	// GetTopCustomers ranks customers by spend, like
+	// GET /api/v1/analytics/customers/top
+	GetTopCustomers(ctx context.Context, in *GetTopCustomersRequest, opts ...grpc.CallOption) (*GetTopCustomersResponse, error)
+	// Sync runs a data sync, like POST /api/v1/sync
+	Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*SyncResponse, error)
+}
+
+type gatewayClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGatewayClient(cc grpc.ClientConnInterface) GatewayClient {
+	return &gatewayClient{cc}
+}
+
+func (c *gatewayClient) ListItems(ctx context.Context, in *ListItemsRequest, opts ...grpc.CallOption) (*ListItemsResponse, error) {
+	out := new(ListItemsResponse)
+	err := c.cc.Invoke(ctx, Gateway_ListItems_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gatewayClient) GetItem(ctx context.Context, in *GetItemRequest, opts ...grpc.CallOption) (*GetItemResponse, error) {
+	out := new(GetItemResponse)
+	err := c.cc.Invoke(ctx, Gateway_GetItem_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gatewayClient) GetOrderStatusSummary(ctx context.Context, in *GetOrderStatusSummaryRequest, opts ...grpc.CallOption) (*GetOrderStatusSummaryResponse, error) {
+	out := new(GetOrderStatusSummaryResponse)
+	err := c.cc.Invoke(ctx, Gateway_GetOrderStatusSummary_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gatewayClient) GetTopCustomers(ctx context.Context, in *GetTopCustomersRequest, opts ...grpc.CallOption) (*GetTopCustomersResponse, error) {
+	out := new(GetTopCustomersResponse)
+	err := c.cc.Invoke(ctx, Gateway_GetTopCustomers_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gatewayClient) Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*SyncResponse, error) {
+	out := new(SyncResponse)
+	err := c.cc.Invoke(ctx, Gateway_Sync_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GatewayServer is the server API for Gateway service.
+// All implementations must embed UnimplementedGatewayServer
+// for forward compatibility
+type GatewayServer interface {
+	// ListItems returns synced items, like GET /api/v1/items
+	ListItems(context.Context, *ListItemsRequest) (*ListItemsResponse, error)
+	// GetItem returns one item, like GET /api/v1/items/:id
+	GetItem(context.Context, *GetItemRequest) (*GetItemResponse, error)
+	// GetOrderStatusSummary returns order counts and totals by status, like
+	// GET /api/v1/analytics/orders/status
+	GetOrderStatusSummary(context.Context, *GetOrderStatusSummaryRequest) (*GetOrderStatusSummaryResponse, error)
+	// GetTopCustomers ranks customers by spend, like
+	// GET /api/v1/analytics/customers/top
+	GetTopCustomers(context.Context, *GetTopCustomersRequest) (*GetTopCustomersResponse, error)
+	// Sync runs a data sync, like POST /api/v1/sync
+	Sync(context.Context, *SyncRequest) (*SyncResponse, error)
+	mustEmbedUnimplementedGatewayServer()
+}
+
+// UnimplementedGatewayServer must be embedded to have forward compatible implementations.
+type UnimplementedGatewayServer struct {
+}
+
+func (UnimplementedGatewayServer) ListItems(context.Context, *ListItemsRequest) (*ListItemsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListItems not implemented")
+}
+func (UnimplementedGatewayServer) GetItem(context.Context, *GetItemRequest) (*GetItemResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetItem not implemented")
+}
+func (UnimplementedGatewayServer) GetOrderStatusSummary(context.Context, *GetOrderStatusSummaryRequest) (*GetOrderStatusSummaryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrderStatusSummary not implemented")
+}
+func (UnimplementedGatewayServer) GetTopCustomers(context.Context, *GetTopCustomersRequest) (*GetTopCustomersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTopCustomers not implemented")
+}
+func (UnimplementedGatewayServer) Sync(context.Context, *SyncRequest) (*SyncResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sync not implemented")
+}
+func (UnimplementedGatewayServer) mustEmbedUnimplementedGatewayServer() {}
+
+// UnsafeGatewayServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GatewayServer will
+// result in compilation errors.
+type UnsafeGatewayServer interface {
+	mustEmbedUnimplementedGatewayServer()
+}
+
+func RegisterGatewayServer(s grpc.ServiceRegistrar, srv GatewayServer) {
+	s.RegisterService(&Gateway_ServiceDesc, srv)
+}
+
+func _Gateway_ListItems_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListItemsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GatewayServer).ListItems(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gateway_ListItems_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GatewayServer).ListItems(ctx, req.(*ListItemsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gateway_GetItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GatewayServer).GetItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gateway_GetItem_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GatewayServer).GetItem(ctx, req.(*GetItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gateway_GetOrderStatusSummary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrderStatusSummaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GatewayServer).GetOrderStatusSummary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gateway_GetOrderStatusSummary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GatewayServer).GetOrderStatusSummary(ctx, req.(*GetOrderStatusSummaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gateway_GetTopCustomers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTopCustomersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GatewayServer).GetTopCustomers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gateway_GetTopCustomers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GatewayServer).GetTopCustomers(ctx, req.(*GetTopCustomersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gateway_Sync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GatewayServer).Sync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gateway_Sync_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GatewayServer).Sync(ctx, req.(*SyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Gateway_ServiceDesc is the grpc.ServiceDesc for Gateway service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Gateway_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gateway.v1.Gateway",
+	HandlerType: (*GatewayServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListItems",
+			Handler:    _Gateway_ListItems_Handler,
+		},
+		{
+			MethodName: "GetItem",
+			Handler:    _Gateway_GetItem_Handler,
+		},
+		{
+			MethodName: "GetOrderStatusSummary",
+			Handler:    _Gateway_GetOrderStatusSummary_Handler,
+		},
+		{
+			MethodName: "GetTopCustomers",
+			Handler:    _Gateway_GetTopCustomers_Handler,
+		},
+		{
+			MethodName: "Sync",
+			Handler:    _Gateway_Sync_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "gateway/v1/gateway.proto",
+}
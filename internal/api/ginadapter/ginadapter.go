@@ -0,0 +1,123 @@
+package ginadapter
+
+import (
+	"io"
+	"net/http"
+
+	"api-gateway-backend/internal/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewRouter creates a new Gin engine with all API routes registered
+func NewRouter(h *api.Handler) *gin.Engine {
+	router := gin.New()
+
+	// Requests are logged by the API's access log middleware
+	router.Use(gin.Recovery())
+
+	h.Register(New(router))
+
+	return router
+}
+
+// New adapts a Gin router (engine or group) to api.Router
+func New(r gin.IRouter) api.Router {
+	return &router{r: r}
+}
+
+// Wrap converts an api.HandlerFunc to a gin.HandlerFunc
+func Wrap(h api.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h(&ginContext{c: c})
+	}
+}
+
+// wrapAll converts a handler chain to Gin handlers
+func wrapAll(handlers []api.HandlerFunc) []gin.HandlerFunc {
+	wrapped := make([]gin.HandlerFunc, len(handlers))
+	for i, h := range handlers {
+		wrapped[i] = Wrap(h)
+	}
+	return wrapped
+}
+
+// router implements api.Router on top of gin.IRouter
+type router struct {
+	r gin.IRouter
+}
+
+func (r *router) Use(middleware ...api.HandlerFunc) {
+	r.r.Use(wrapAll(middleware)...)
+}
+
+func (r *router) Group(prefix string, middleware ...api.HandlerFunc) api.Router {
+	return &router{r: r.r.Group(prefix, wrapAll(middleware)...)}
+}
+
+func (r *router) Handle(method, path string, handlers ...api.HandlerFunc) {
+	r.r.Handle(method, path, wrapAll(handlers)...)
+}
+
+// ginContext implements api.Context on top of *gin.Context
+type ginContext struct {
+	c *gin.Context
+}
+
+func (g *ginContext) Request() *http.Request { return g.c.Request }
+
+func (g *ginContext) SetRequest(r *http.Request) { g.c.Request = r }
+
+func (g *ginContext) Writer() api.ResponseWriter { return g.c.Writer }
+
+func (g *ginContext) TeeBody(w io.Writer) {
+	g.c.Writer = &teeWriter{ResponseWriter: g.c.Writer, tee: w}
+}
+
+func (g *ginContext) Param(key string) string { return g.c.Param(key) }
+
+func (g *ginContext) Query(key string) string { return g.c.Query(key) }
+
+func (g *ginContext) ClientIP() string { return g.c.ClientIP() }
+
+func (g *ginContext) GetHeader(key string) string { return g.c.GetHeader(key) }
+
+func (g *ginContext) Header(key, value string) { g.c.Header(key, value) }
+
+func (g *ginContext) Set(key string, value interface{}) { g.c.Set(key, value) }
+
+func (g *ginContext) Get(key string) (interface{}, bool) { return g.c.Get(key) }
+
+func (g *ginContext) JSON(code int, obj interface{}) { g.c.JSON(code, obj) }
+
+func (g *ginContext) Status(code int) { g.c.Status(code) }
+
+func (g *ginContext) Next() { g.c.Next() }
+
+func (g *ginContext) Abort() { g.c.Abort() }
+
+func (g *ginContext) AbortWithStatus(code int) { g.c.AbortWithStatus(code) }
+
+func (g *ginContext) AbortWithStatusJSON(code int, obj interface{}) {
+	g.c.AbortWithStatusJSON(code, obj)
+}
+
+func (g *ginContext) IsAborted() bool { return g.c.IsAborted() }
+
+// teeWriter copies the body written through a Gin writer to tee
+type teeWriter struct {
+	gin.ResponseWriter
+	tee io.Writer
+}
+
+func (w *teeWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.tee.Write(b[:n])
+	return n, err
+}
+
+func (w *teeWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	io.WriteString(w.tee, s[:n])
+	return n, err
+}
@@ -0,0 +1,92 @@
+package ginadapter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api-gateway-backend/internal/api"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestRouter(register func(api.Router)) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	register(New(engine))
+
+	return engine
+}
+
+func TestRouter_ParamsAndContextValues(t *testing.T) {
+	router := setupTestRouter(func(r api.Router) {
+		v1 := r.Group("/api/v1", func(c api.Context) {
+			c.Set("user", "alice")
+			c.Next()
+		})
+		v1.Handle(http.MethodGet, "/items/:id", func(c api.Context) {
+			user, _ := c.Get("user")
+			c.JSON(http.StatusOK, api.H{
+				"id":   c.Param("id"),
+				"sort": c.Query("sort"),
+				"user": user,
+			})
+		})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/items/42?sort=asc", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "42", response["id"])
+	assert.Equal(t, "asc", response["sort"])
+	assert.Equal(t, "alice", response["user"])
+}
+
+func TestRouter_MiddlewareAbort(t *testing.T) {
+	called := false
+	router := setupTestRouter(func(r api.Router) {
+		r.Use(func(c api.Context) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+		})
+		r.Handle(http.MethodGet, "/health", func(c api.Context) {
+			called = true
+		})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/health", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.False(t, called)
+}
+
+func TestRouter_TeeBody(t *testing.T) {
+	var body strings.Builder
+	router := setupTestRouter(func(r api.Router) {
+		r.Use(func(c api.Context) {
+			c.TeeBody(&body)
+			c.Next()
+		})
+		r.Handle(http.MethodGet, "/health", func(c api.Context) {
+			c.JSON(http.StatusOK, api.H{"status": "ok"})
+		})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/health", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, w.Body.String(), body.String())
+}
@@ -0,0 +1,521 @@
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/jobs"
+	"api-gateway-backend/internal/rbac"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+const (
+	graphqlPath = "/graphql"
+
+	maxItemsPageLimit = 500
+)
+
+// graphqlSDL is the schema served at /graphql
+//
+//go:embed schema.graphql
+var graphqlSDL string
+
+// graphqlRequest is the body of POST /graphql
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// newGraphQLSchema parses schema.graphql against resolvers backed by h
+func newGraphQLSchema(h *Handler) (*graphql.Schema, error) {
+	opts := []graphql.SchemaOpt{graphql.Logger(graphqlPanicLogger{h})}
+	if h.cfg.GraphQL.MaxDepth > 0 {
+		opts = append(opts, graphql.MaxDepth(h.cfg.GraphQL.MaxDepth))
+	}
+	return graphql.ParseSchema(graphqlSDL, &graphqlResolver{h: h}, opts...)
+}
+
+// graphql handles POST /graphql. Query and resolver errors are reported in
+// the response's errors list with a 200, as GraphQL clients expect; only
+// an unreadable request is rejected outright.
+func (h *Handler) graphql(c Context) {
+	ctx := c.Request().Context()
+
+	var req graphqlRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		abortWithError(c, bodyReadError(err))
+		return
+	}
+	if req.Query == "" {
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidRequest, "invalid request body", errors.New("query is required")))
+		return
+	}
+
+	// Resolvers check the permissions of the fields they serve
+	ctx = withPrincipal(ctx, h.principalOf(c))
+	resp := h.graphqlSchema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	c.JSON(http.StatusOK, resp)
+}
+
+// graphqlPanicLogger logs resolver panics, which the schema reports as
+// query errors
+type graphqlPanicLogger struct {
+	h *Handler
+}
+
+// LogPanic implements graphql-go's log.Logger
+func (l graphqlPanicLogger) LogPanic(ctx context.Context, value interface{}) {
+	l.h.logger.FromContext(ctx).WithField("panic", value).Error("GraphQL resolver panicked")
+}
+
+// graphqlError reports an API error in a GraphQL response, with its code
+// under extensions
+type graphqlError struct {
+	err *apierror.Error
+}
+
+// Error implements the error interface
+func (e graphqlError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the API error
+func (e graphqlError) Unwrap() error {
+	return e.err
+}
+
+// Extensions implements graphql-go's resolver error extensions
+func (e graphqlError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.err.Code}
+}
+
+// invalidArgument reports a rejected query argument
+func invalidArgument(err error) error {
+	return graphqlError{apierror.BadRequest(apierror.CodeInvalidParameter, "invalid argument", err)}
+}
+
+// graphqlResolver resolves the Query and Mutation root fields
+type graphqlResolver struct {
+	h *Handler
+}
+
+// authorize checks that the request holds perm, which field needs
+func (r *graphqlResolver) authorize(ctx context.Context, perm, field string) error {
+	if err := r.h.authorize(ctx, principalFrom(ctx), perm, "graphql "+field); err != nil {
+		return graphqlError{err}
+	}
+	return nil
+}
+
+// itemFilterInput is the ItemFilter input
+type itemFilterInput struct {
+	UserID      *int32
+	ExternalID  *string
+	CreatedFrom *graphql.Time
+	CreatedTo   *graphql.Time
+}
+
+// Items serves a page of items like GET /api/v1/items, from the cached
+// listing for the filter
+func (r *graphqlResolver) Items(ctx context.Context, args struct {
+	Filter *itemFilterInput
+	Sort   *string
+	Limit  int32
+	Offset int32
+}) (*itemPageResolver, error) {
+	if err := r.authorize(ctx, rbac.ItemsRead, "items"); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var filter database.ItemFilter
+	if f := args.Filter; f != nil {
+		if f.UserID != nil {
+			userID := int(*f.UserID)
+			filter.UserID = &userID
+		}
+		filter.ExternalID = stringValue(f.ExternalID)
+		filter.CreatedFrom = timeValue(f.CreatedFrom)
+		filter.CreatedTo = timeValue(f.CreatedTo)
+	}
+	if err := setItemSort(&filter, stringValue(args.Sort)); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	limit, offset := int(args.Limit), int(args.Offset)
+	if limit <= 0 || limit > maxItemsPageLimit {
+		return nil, invalidArgument(fmt.Errorf("limit must be between 1 and %d", maxItemsPageLimit))
+	}
+	if offset < 0 {
+		return nil, invalidArgument(errors.New("offset must be a non-negative integer"))
+	}
+
+	items, cached, err := r.h.listItems(ctx, filter)
+	if err != nil {
+		r.h.logger.FromContext(ctx).WithError(err).Error("Failed to get items from database")
+		return nil, graphqlError{apierror.Internal("failed to retrieve items", err)}
+	}
+
+	page := &itemPageResolver{total: len(items), cached: cached}
+	if offset < len(items) {
+		page.items = items[offset:min(offset+limit, len(items))]
+	}
+	return page, nil
+}
+
+// Item serves one item like GET /api/v1/items/:id
+func (r *graphqlResolver) Item(ctx context.Context, args struct{ ID graphql.ID }) (*itemResolver, error) {
+	if err := r.authorize(ctx, rbac.ItemsRead, "item"); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	id, err := parseGraphQLID(args.ID)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	item, _, err := r.h.findItem(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		r.h.logger.FromContext(ctx).WithError(err).WithField("id", id).Error("Failed to get item from database")
+		return nil, graphqlError{apierror.Internal("failed to retrieve item", err)}
+	}
+	return &itemResolver{item}, nil
+}
+
+// orderFilterInput is the OrderFilter input
+type orderFilterInput struct {
+	Status     *string
+	CustomerID *string
+	From       *graphql.Time
+	To         *graphql.Time
+}
+
+// Orders serves orders like GET /api/v1/orders
+func (r *graphqlResolver) Orders(ctx context.Context, args struct {
+	Filter *orderFilterInput
+	Limit  int32
+	Offset int32
+}) ([]*orderResolver, error) {
+	if err := r.authorize(ctx, rbac.OrdersRead, "orders"); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// newOrderFilter would take a limit of 0 to mean the default
+	if args.Limit <= 0 {
+		return nil, invalidArgument(fmt.Errorf("limit must be between 1 and %d", maxOrdersLimit))
+	}
+	f := args.Filter
+	if f == nil {
+		f = &orderFilterInput{}
+	}
+	filter, err := newOrderFilter(stringValue(f.Status), stringValue(f.CustomerID), timeValue(f.From), timeValue(f.To), int(args.Limit), int(args.Offset))
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	orders, err := r.h.db.GetOrders(ctx, filter)
+	if err != nil {
+		r.h.logger.FromContext(ctx).WithError(err).Error("Failed to get orders")
+		return nil, graphqlError{apierror.Internal("failed to retrieve orders", err)}
+	}
+
+	resolvers := make([]*orderResolver, len(orders))
+	for i, order := range orders {
+		resolvers[i] = &orderResolver{order}
+	}
+	return resolvers, nil
+}
+
+// Order serves one order like GET /api/v1/orders/:id
+func (r *graphqlResolver) Order(ctx context.Context, args struct{ ID graphql.ID }) (*orderResolver, error) {
+	if err := r.authorize(ctx, rbac.OrdersRead, "order"); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	id, err := parseGraphQLID(args.ID)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	order, err := r.h.db.GetOrderByID(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		r.h.logger.FromContext(ctx).WithError(err).WithField("id", id).Error("Failed to get order")
+		return nil, graphqlError{apierror.Internal("failed to retrieve order", err)}
+	}
+	return &orderResolver{*order}, nil
+}
+
+// OrderStatusSummary serves GET /api/v1/analytics/orders/status
+func (r *graphqlResolver) OrderStatusSummary(ctx context.Context, args struct {
+	Period  *string
+	From    *graphql.Time
+	To      *graphql.Time
+	GroupBy *string
+}) (*orderStatusReportResolver, error) {
+	if err := r.authorize(ctx, rbac.AnalyticsRead, "orderStatusSummary"); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	period, filter, err := newOrderStatusFilter(stringValue(args.Period), timeValue(args.From), timeValue(args.To), stringValue(args.GroupBy), time.Now())
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	summaries, _, cached, err := r.h.orderStatusSummary(ctx, period, filter)
+	if err != nil {
+		r.h.logger.FromContext(ctx).WithError(err).Error("Failed to get order status summary")
+		return nil, graphqlError{apierror.Internal("failed to retrieve order status summary", err)}
+	}
+	return &orderStatusReportResolver{summaries: summaries, period: period, cached: cached}, nil
+}
+
+// TopCustomers serves GET /api/v1/analytics/customers/top
+func (r *graphqlResolver) TopCustomers(ctx context.Context, args struct {
+	Limit *int32
+	From  *graphql.Time
+	To    *graphql.Time
+}) (*topCustomersReportResolver, error) {
+	if err := r.authorize(ctx, rbac.AnalyticsRead, "topCustomers"); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// newTopCustomersFilter takes a limit of 0 to mean the default
+	limit := 0
+	if args.Limit != nil {
+		if *args.Limit <= 0 {
+			return nil, invalidArgument(fmt.Errorf("limit must be between 1 and %d", maxTopCustomersLimit))
+		}
+		limit = int(*args.Limit)
+	}
+	filter, err := newTopCustomersFilter(limit, timeValue(args.From), timeValue(args.To))
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	customers, cached, err := r.h.topCustomers(ctx, filter)
+	if err != nil {
+		r.h.logger.FromContext(ctx).WithError(err).Error("Failed to get top customers")
+		return nil, graphqlError{apierror.Internal("failed to retrieve top customers", err)}
+	}
+	return &topCustomersReportResolver{customers: customers, limit: filter.Limit, cached: cached}, nil
+}
+
+// Sync runs a sync like POST /api/v1/sync, queuing it when async is set
+func (r *graphqlResolver) Sync(ctx context.Context, args struct{ Async bool }) (*syncResultResolver, error) {
+	if err := r.authorize(ctx, rbac.SyncTrigger, "sync"); err != nil {
+		return nil, err
+	}
+	log := r.h.logger.FromContext(ctx)
+
+	if args.Async {
+		job, err := r.h.jobManager.StartSync(ctx)
+		if err != nil {
+			log.WithError(err).Error("Failed to start async sync")
+			return nil, graphqlError{apierror.Internal("failed to start sync", err)}
+		}
+		log.WithField("job_id", job.ID).Info("Async sync requested")
+		return &syncResultResolver{jobID: &job.ID, status: job.Status}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Minute)
+	defer cancel()
+
+	log.Info("Manual sync requested")
+	err := r.h.jobManager.SyncDataManual(ctx)
+	if errors.Is(err, jobs.ErrSyncInProgress) {
+		return nil, graphqlError{apierror.New(http.StatusConflict, apierror.CodeSyncInProgress, "sync already in progress").Wrap(err)}
+	}
+	if err != nil {
+		log.WithError(err).Error("Manual sync failed")
+		return nil, graphqlError{apierror.Internal("sync failed", err)}
+	}
+	return &syncResultResolver{status: jobs.SyncSucceeded}, nil
+}
+
+// itemResolver resolves Item
+type itemResolver struct {
+	item database.Item
+}
+
+func (r *itemResolver) ID() graphql.ID          { return formatGraphQLID(r.item.ID) }
+func (r *itemResolver) ExternalID() string      { return r.item.ExternalID }
+func (r *itemResolver) Title() string           { return r.item.Title }
+func (r *itemResolver) Body() string            { return r.item.Body }
+func (r *itemResolver) UserID() int32           { return int32(r.item.UserID) }
+func (r *itemResolver) CreatedAt() graphql.Time { return graphql.Time{Time: r.item.CreatedAt} }
+func (r *itemResolver) UpdatedAt() graphql.Time { return graphql.Time{Time: r.item.UpdatedAt} }
+
+// itemPageResolver resolves ItemPage
+type itemPageResolver struct {
+	items  []database.Item
+	total  int
+	cached bool
+}
+
+func (r *itemPageResolver) Items() []*itemResolver {
+	resolvers := make([]*itemResolver, len(r.items))
+	for i, item := range r.items {
+		resolvers[i] = &itemResolver{item}
+	}
+	return resolvers
+}
+
+func (r *itemPageResolver) TotalCount() int32 { return int32(r.total) }
+func (r *itemPageResolver) Cached() bool      { return r.cached }
+
+// orderResolver resolves Order
+type orderResolver struct {
+	order database.Order
+}
+
+func (r *orderResolver) ID() graphql.ID          { return formatGraphQLID(r.order.ID) }
+func (r *orderResolver) CustomerID() string      { return r.order.CustomerID }
+func (r *orderResolver) Amount() float64         { return r.order.Amount }
+func (r *orderResolver) Status() string          { return r.order.Status }
+func (r *orderResolver) CreatedAt() graphql.Time { return graphql.Time{Time: r.order.CreatedAt} }
+
+// orderStatusSummaryResolver resolves OrderStatusSummary
+type orderStatusSummaryResolver struct {
+	summary database.OrderStatusSummary
+}
+
+func (r *orderStatusSummaryResolver) PeriodStart() *graphql.Time {
+	if r.summary.PeriodStart == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *r.summary.PeriodStart}
+}
+
+func (r *orderStatusSummaryResolver) Status() string       { return r.summary.Status }
+func (r *orderStatusSummaryResolver) OrderCount() int32    { return int32(r.summary.OrderCount) }
+func (r *orderStatusSummaryResolver) TotalAmount() float64 { return r.summary.TotalAmount }
+
+// reportPeriodResolver resolves ReportPeriod
+type reportPeriodResolver struct {
+	period reportPeriod
+}
+
+func (r *reportPeriodResolver) Name() string       { return r.period.Name }
+func (r *reportPeriodResolver) From() graphql.Time { return graphql.Time{Time: r.period.From} }
+func (r *reportPeriodResolver) To() graphql.Time   { return graphql.Time{Time: r.period.To} }
+
+func (r *reportPeriodResolver) GroupBy() *string {
+	if r.period.GroupBy == "" {
+		return nil
+	}
+	return &r.period.GroupBy
+}
+
+// orderStatusReportResolver resolves OrderStatusReport
+type orderStatusReportResolver struct {
+	summaries []database.OrderStatusSummary
+	period    reportPeriod
+	cached    bool
+}
+
+func (r *orderStatusReportResolver) Summaries() []*orderStatusSummaryResolver {
+	resolvers := make([]*orderStatusSummaryResolver, len(r.summaries))
+	for i, summary := range r.summaries {
+		resolvers[i] = &orderStatusSummaryResolver{summary}
+	}
+	return resolvers
+}
+
+func (r *orderStatusReportResolver) Period() *reportPeriodResolver {
+	return &reportPeriodResolver{r.period}
+}
+func (r *orderStatusReportResolver) Cached() bool { return r.cached }
+
+// topCustomerResolver resolves TopCustomer
+type topCustomerResolver struct {
+	customer database.TopCustomer
+}
+
+func (r *topCustomerResolver) CustomerID() string  { return r.customer.CustomerID }
+func (r *topCustomerResolver) TotalSpend() float64 { return r.customer.TotalSpend }
+func (r *topCustomerResolver) OrderCount() int32   { return int32(r.customer.OrderCount) }
+
+// topCustomersReportResolver resolves TopCustomersReport
+type topCustomersReportResolver struct {
+	customers []database.TopCustomer
+	limit     int
+	cached    bool
+}
+
+func (r *topCustomersReportResolver) Customers() []*topCustomerResolver {
+	resolvers := make([]*topCustomerResolver, len(r.customers))
+	for i, customer := range r.customers {
+		resolvers[i] = &topCustomerResolver{customer}
+	}
+	return resolvers
+}
+
+func (r *topCustomersReportResolver) Limit() int32 { return int32(r.limit) }
+func (r *topCustomersReportResolver) Cached() bool { return r.cached }
+
+// syncResultResolver resolves SyncResult
+type syncResultResolver struct {
+	jobID  *string
+	status string
+}
+
+func (r *syncResultResolver) JobID() *string { return r.jobID }
+func (r *syncResultResolver) Status() string { return r.status }
+
+// parseGraphQLID parses a numeric database ID
+func parseGraphQLID(id graphql.ID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, errors.New("id must be a positive integer")
+	}
+	return n, nil
+}
+
+// formatGraphQLID formats a database ID
+func formatGraphQLID(id int64) graphql.ID {
+	return graphql.ID(strconv.FormatInt(id, 10))
+}
+
+// stringValue returns *s, or "" for an omitted argument
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// timeValue converts an optional Time argument
+func timeValue(t *graphql.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := t.Time
+	return &v
+}
@@ -0,0 +1,396 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway-backend/internal/api/gatewaypb"
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/jobs"
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/rbac"
+	"api-gateway-backend/internal/tenant"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// grpcRequestIDKey carries the request ID in gRPC metadata, like
+	// X-Request-ID over HTTP
+	grpcRequestIDKey = "x-request-id"
+
+	// grpcErrorDomain is the ErrorInfo domain of gRPC errors, whose reason
+	// is the same code /api/v1 returns
+	grpcErrorDomain = "api-gateway-backend"
+)
+
+// grpcCodes maps the HTTP statuses of API errors to gRPC codes
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.Aborted,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+	http.StatusInternalServerError: codes.Internal,
+}
+
+// grpcPermissions maps each gRPC method to the permission it requires,
+// the same as its HTTP route's
+var grpcPermissions = map[string]string{
+	gatewaypb.Gateway_ListItems_FullMethodName:             rbac.ItemsRead,
+	gatewaypb.Gateway_GetItem_FullMethodName:               rbac.ItemsRead,
+	gatewaypb.Gateway_GetOrderStatusSummary_FullMethodName: rbac.AnalyticsRead,
+	gatewaypb.Gateway_GetTopCustomers_FullMethodName:       rbac.AnalyticsRead,
+	gatewaypb.Gateway_Sync_FullMethodName:                  rbac.SyncTrigger,
+}
+
+// NewGRPCServer returns a gRPC server exposing the Gateway service from
+// proto/gateway/v1. It authenticates like /api/v1 and serves the same
+// caches and aggregates through the handler's queries.
+func (h *Handler) NewGRPCServer() *grpc.Server {
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(h.grpcRequestID(), h.grpcAuthenticate(), h.grpcAuthorize()))
+	gatewaypb.RegisterGatewayServer(s, &grpcGateway{h: h})
+	return s
+}
+
+// grpcRequestID honors an incoming x-request-id (or generates one), echoes
+// it in the response header and attaches it to the context, then logs the
+// call. Panics are recovered and reported as Internal.
+func (h *Handler) grpcRequestID() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		id := metadataValue(ctx, grpcRequestIDKey)
+		if !validRequestID(id) {
+			id = logger.NewRequestID()
+		}
+		grpc.SetHeader(ctx, metadata.Pairs(grpcRequestIDKey, id))
+		ctx = logger.ContextWithRequestID(ctx, id)
+		log := h.logger.FromContext(ctx).WithField("method", info.FullMethod)
+
+		start := time.Now()
+		defer func() {
+			if r := recover(); r != nil {
+				log.WithField("panic", r).Error("gRPC handler panicked")
+				h.reporter.CapturePanic(r, nil, map[string]string{"request_id": id, "method": info.FullMethod})
+				err = grpcError(apierror.Internal("internal error", fmt.Errorf("panic: %v", r)))
+			}
+			log.WithFields(map[string]interface{}{
+				"code":     status.Code(err).String(),
+				"duration": time.Since(start).String(),
+			}).Info("gRPC request")
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// grpcAuthenticate accepts an x-api-key or a bearer token in the
+// authorization metadata, as authenticate does for HTTP requests, and
+// attaches who the call acts as, and with tenancy enabled the tenant it
+// acts for, to its context
+func (h *Handler) grpcAuthenticate() grpc.UnaryServerInterceptor {
+	apiKeys := h.cfg.Auth.APIKeysEnabled
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		p := principal{actor: "anonymous"}
+		tenantID := tenant.Default
+		key := metadataValue(ctx, strings.ToLower(apiKeyHeader))
+		switch {
+		case apiKeys && key != "":
+			lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			found, err := h.lookupAPIKey(lookupCtx, key)
+			cancel()
+			if errors.Is(err, database.ErrNotFound) {
+				return nil, grpcError(errUnauthorized.Wrap(errors.New("invalid API key")))
+			}
+			if err != nil {
+				h.logger.FromContext(ctx).WithError(err).Error("Failed to look up API key")
+				return nil, grpcError(apierror.Internal("failed to authenticate", errors.New("API key lookup failed")))
+			}
+			p = principal{actor: "api_key:" + strconv.FormatInt(found.ID, 10), roles: found.Roles}
+			tenantID = orDefaultTenant(found.TenantID)
+		case h.jwtAuth != nil:
+			token, ok := strings.CutPrefix(metadataValue(ctx, "authorization"), "Bearer ")
+			if !ok || token == "" {
+				return nil, grpcError(errUnauthorized.Wrap(errors.New("missing bearer token")))
+			}
+			claims, err := h.jwtAuth.validate(ctx, token)
+			if err != nil {
+				return nil, grpcError(errUnauthorized.Wrap(errors.New("invalid token")))
+			}
+			p.roles = claimRoles(claims, h.cfg.RBAC.JWTClaim)
+			if sub, err := claims.GetSubject(); err == nil && sub != "" {
+				p.actor = "user:" + sub
+			}
+			if h.cfg.Tenancy.Enabled {
+				if tenantID, err = h.claimTenant(claims); err != nil {
+					return nil, grpcError(errForbidden.Wrap(err))
+				}
+			}
+		case apiKeys:
+			return nil, grpcError(errUnauthorized.Wrap(errors.New("missing API key")))
+		case h.cfg.Auth.SigningEnabled:
+			// Signatures cover HTTP requests only, so gRPC has no credentials
+			return nil, grpcError(errUnauthorized.Wrap(errors.New("gRPC calls need an API key or bearer token when requests are signed")))
+		}
+
+		if h.cfg.Tenancy.Enabled {
+			ctx = tenant.With(ctx, tenantID)
+		}
+		return handler(withPrincipal(ctx, p), req)
+	}
+}
+
+// grpcAuthorize refuses calls whose roles don't grant the method's
+// permission, as require does for HTTP routes
+func (h *Handler) grpcAuthorize() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if perm, ok := grpcPermissions[info.FullMethod]; ok {
+			if err := h.authorize(ctx, principalFrom(ctx), perm, "grpc "+info.FullMethod); err != nil {
+				return nil, grpcError(err)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// grpcGateway implements gatewaypb.GatewayServer
+type grpcGateway struct {
+	gatewaypb.UnimplementedGatewayServer
+	h *Handler
+}
+
+// ListItems serves items like GET /api/v1/items
+func (g *grpcGateway) ListItems(ctx context.Context, req *gatewaypb.ListItemsRequest) (*gatewaypb.ListItemsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	filter := database.ItemFilter{ExternalID: req.GetExternalId()}
+	if req.UserId != nil {
+		userID := int(req.GetUserId())
+		filter.UserID = &userID
+	}
+	var err error
+	if filter.CreatedFrom, err = timeParam("created_from", req.GetCreatedFrom()); err != nil {
+		return nil, grpcError(apierror.BadRequest(apierror.CodeInvalidParameter, "invalid request parameter", err))
+	}
+	if filter.CreatedTo, err = timeParam("created_to", req.GetCreatedTo()); err != nil {
+		return nil, grpcError(apierror.BadRequest(apierror.CodeInvalidParameter, "invalid request parameter", err))
+	}
+	if err := setItemSort(&filter, req.GetSort()); err != nil {
+		return nil, grpcError(apierror.BadRequest(apierror.CodeInvalidParameter, "invalid request parameter", err))
+	}
+
+	items, cached, err := g.h.listItems(ctx, filter)
+	if err != nil {
+		g.h.logger.FromContext(ctx).WithError(err).Error("Failed to get items from database")
+		return nil, grpcError(apierror.Internal("failed to retrieve items", err))
+	}
+
+	resp := &gatewaypb.ListItemsResponse{Items: make([]*gatewaypb.Item, len(items)), Cached: cached}
+	for i, item := range items {
+		resp.Items[i] = itemToProto(item)
+	}
+	return resp, nil
+}
+
+// GetItem serves one item like GET /api/v1/items/:id
+func (g *grpcGateway) GetItem(ctx context.Context, req *gatewaypb.GetItemRequest) (*gatewaypb.GetItemResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	id := req.GetId()
+	if id <= 0 {
+		return nil, grpcError(apierror.BadRequest(apierror.CodeInvalidParameter, "invalid item id", errors.New("id must be a positive integer")))
+	}
+
+	item, cached, err := g.h.findItem(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, grpcError(apierror.NotFound(apierror.CodeItemNotFound, "item not found", fmt.Errorf("no item with id %d", id)))
+	}
+	if err != nil {
+		g.h.logger.FromContext(ctx).WithError(err).WithField("id", id).Error("Failed to get item from database")
+		return nil, grpcError(apierror.Internal("failed to retrieve item", err))
+	}
+
+	return &gatewaypb.GetItemResponse{Item: itemToProto(item), Cached: cached}, nil
+}
+
+// GetOrderStatusSummary serves GET /api/v1/analytics/orders/status
+func (g *grpcGateway) GetOrderStatusSummary(ctx context.Context, req *gatewaypb.GetOrderStatusSummaryRequest) (*gatewaypb.GetOrderStatusSummaryResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	from, err := timeParam("from", req.GetFrom())
+	if err != nil {
+		return nil, grpcError(apierror.BadRequest(apierror.CodeInvalidParameter, "invalid request parameter", err))
+	}
+	to, err := timeParam("to", req.GetTo())
+	if err != nil {
+		return nil, grpcError(apierror.BadRequest(apierror.CodeInvalidParameter, "invalid request parameter", err))
+	}
+	period, filter, err := newOrderStatusFilter(req.GetPeriod(), from, to, req.GetGroupBy(), time.Now())
+	if err != nil {
+		return nil, grpcError(apierror.BadRequest(apierror.CodeInvalidParameter, "invalid request parameter", err))
+	}
+
+	summaries, _, cached, err := g.h.orderStatusSummary(ctx, period, filter)
+	if err != nil {
+		g.h.logger.FromContext(ctx).WithError(err).Error("Failed to get order status summary")
+		return nil, grpcError(apierror.Internal("failed to retrieve order status summary", err))
+	}
+
+	resp := &gatewaypb.GetOrderStatusSummaryResponse{
+		Summaries: make([]*gatewaypb.OrderStatusSummary, len(summaries)),
+		Period: &gatewaypb.ReportPeriod{
+			Name:    period.Name,
+			From:    timestamppb.New(period.From),
+			To:      timestamppb.New(period.To),
+			GroupBy: period.GroupBy,
+		},
+		Cached: cached,
+	}
+	for i, s := range summaries {
+		summary := &gatewaypb.OrderStatusSummary{
+			Status:      s.Status,
+			OrderCount:  int32(s.OrderCount),
+			TotalAmount: s.TotalAmount,
+		}
+		if s.PeriodStart != nil {
+			summary.PeriodStart = timestamppb.New(*s.PeriodStart)
+		}
+		resp.Summaries[i] = summary
+	}
+	return resp, nil
+}
+
+// GetTopCustomers serves GET /api/v1/analytics/customers/top
+func (g *grpcGateway) GetTopCustomers(ctx context.Context, req *gatewaypb.GetTopCustomersRequest) (*gatewaypb.GetTopCustomersResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	from, err := timeParam("from", req.GetFrom())
+	if err != nil {
+		return nil, grpcError(apierror.BadRequest(apierror.CodeInvalidParameter, "invalid request parameter", err))
+	}
+	to, err := timeParam("to", req.GetTo())
+	if err != nil {
+		return nil, grpcError(apierror.BadRequest(apierror.CodeInvalidParameter, "invalid request parameter", err))
+	}
+	filter, err := newTopCustomersFilter(int(req.GetLimit()), from, to)
+	if err != nil {
+		return nil, grpcError(apierror.BadRequest(apierror.CodeInvalidParameter, "invalid request parameter", err))
+	}
+
+	customers, cached, err := g.h.topCustomers(ctx, filter)
+	if err != nil {
+		g.h.logger.FromContext(ctx).WithError(err).Error("Failed to get top customers")
+		return nil, grpcError(apierror.Internal("failed to retrieve top customers", err))
+	}
+
+	resp := &gatewaypb.GetTopCustomersResponse{
+		Customers: make([]*gatewaypb.TopCustomer, len(customers)),
+		Limit:     int32(filter.Limit),
+		Cached:    cached,
+	}
+	for i, c := range customers {
+		resp.Customers[i] = &gatewaypb.TopCustomer{
+			CustomerId: c.CustomerID,
+			TotalSpend: c.TotalSpend,
+			OrderCount: int32(c.OrderCount),
+		}
+	}
+	return resp, nil
+}
+
+// Sync runs a sync like POST /api/v1/sync, queuing it when async is set
+func (g *grpcGateway) Sync(ctx context.Context, req *gatewaypb.SyncRequest) (*gatewaypb.SyncResponse, error) {
+	log := g.h.logger.FromContext(ctx)
+
+	if req.GetAsync() {
+		job, err := g.h.jobManager.StartSync(ctx)
+		if err != nil {
+			log.WithError(err).Error("Failed to start async sync")
+			return nil, grpcError(apierror.Internal("failed to start sync", err))
+		}
+		log.WithField("job_id", job.ID).Info("Async sync requested")
+		return &gatewaypb.SyncResponse{JobId: job.ID, Status: job.Status}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Minute)
+	defer cancel()
+
+	log.Info("Manual sync requested")
+	err := g.h.jobManager.SyncDataManual(ctx)
+	if errors.Is(err, jobs.ErrSyncInProgress) {
+		return nil, grpcError(apierror.New(http.StatusConflict, apierror.CodeSyncInProgress, "sync already in progress").Wrap(err))
+	}
+	if err != nil {
+		log.WithError(err).Error("Manual sync failed")
+		return nil, grpcError(apierror.Internal("sync failed", err))
+	}
+
+	return &gatewaypb.SyncResponse{Status: jobs.SyncSucceeded}, nil
+}
+
+// grpcError converts an API error to a gRPC status. Its code is carried as
+// the reason of an ErrorInfo detail.
+func grpcError(err *apierror.Error) error {
+	code, ok := grpcCodes[err.Status]
+	if !ok {
+		code = codes.Unknown
+	}
+
+	st := status.New(code, err.Error())
+	if detailed, detailErr := st.WithDetails(&errdetails.ErrorInfo{Reason: string(err.Code), Domain: grpcErrorDomain}); detailErr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// metadataValue returns the first value of an incoming metadata key
+func metadataValue(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// timeParam converts an optional timestamp field
+func timeParam(name string, ts *timestamppb.Timestamp) (*time.Time, error) {
+	if ts == nil {
+		return nil, nil
+	}
+	if err := ts.CheckValid(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	t := ts.AsTime()
+	return &t, nil
+}
+
+// itemToProto converts an item to its protobuf message
+func itemToProto(item database.Item) *gatewaypb.Item {
+	return &gatewaypb.Item{
+		Id:         item.ID,
+		ExternalId: item.ExternalID,
+		Title:      item.Title,
+		Body:       item.Body,
+		UserId:     int32(item.UserID),
+		CreatedAt:  timestamppb.New(item.CreatedAt),
+		UpdatedAt:  timestamppb.New(item.UpdatedAt),
+	}
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"api-gateway-backend/internal/health"
+	"api-gateway-backend/internal/metrics"
+	"api-gateway-backend/internal/response"
+	"api-gateway-backend/internal/version"
+)
+
+// buildInfo is the running build, included in probe responses
+var buildInfo = version.Get()
+
+// dependencyCheck is the result of one readiness check
+type dependencyCheck struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// syncCheck reports how fresh the synced data is
+type syncCheck struct {
+	Status      string     `json:"status"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	AgeSeconds  float64    `json:"age_seconds,omitempty"`
+	LatencyMS   float64    `json:"latency_ms"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// liveness handles GET /healthz. It only reports that the process is up and
+// serving, without touching dependencies, so a dependency outage never gets
+// the pod restarted.
+func (h *Handler) liveness(c Context) {
+	respond(c, http.StatusOK, reply{Data: H{"status": "alive", "build": buildInfo}, Flat: true})
+}
+
+// getVersion handles GET /version
+func (h *Handler) getVersion(c Context) {
+	respond(c, http.StatusOK, reply{Data: buildInfo})
+}
+
+// serveMetrics handles GET /metrics, in the Prometheus text format
+func (h *Handler) serveMetrics(c Context) {
+	c.Header("Content-Type", metrics.ContentType)
+	c.Status(http.StatusOK)
+	if err := metrics.Default.Write(c.Writer()); err != nil {
+		h.logger.FromContext(c.Request().Context()).WithError(err).Warn("Failed to write metrics")
+	}
+}
+
+// defaultHealthCheckTimeout applies when no positive check timeout is
+// configured
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// readinessReport is the outcome of one round of readiness checks
+type readinessReport struct {
+	Database  dependencyCheck
+	Redis     dependencyCheck
+	Sync      syncCheck
+	CheckedAt time.Time
+}
+
+// readiness handles GET /readyz. The instance is ready when MySQL responds.
+// Redis being down, an upstream API known to be down or stale synced data
+// are reported as degraded but keep the instance in rotation: requests
+// skip the cache while Redis is down, and every replica shares the same
+// data and upstreams. Load balancers probe every few seconds, so check
+// results are reused for HEALTH_CHECK_CACHE_TTL and cached reports say so.
+func (h *Handler) readiness(c Context) {
+	report, cached := h.checkReadiness()
+
+	status, code := "ready", http.StatusOK
+	switch {
+	case report.Database.Status != "up":
+		status, code = "not_ready", http.StatusServiceUnavailable
+	case report.Redis.Status != "up" || h.upstreamDown() || report.Sync.Status == "stale":
+		status = "degraded"
+	}
+
+	respond(c, code, reply{
+		Data: H{
+			"status": status,
+			"checks": H{
+				"database": report.Database,
+				"redis":    report.Redis,
+				"sync":     report.Sync,
+			},
+			"dependencies": h.deps.Statuses(),
+			"checked_at":   report.CheckedAt,
+			"build":        buildInfo,
+		},
+		Meta: response.Meta{Cache: &response.Cache{Hit: cached}},
+		V1:   H{"cached": cached},
+		Flat: true,
+	})
+}
+
+// upstreamDown reports whether any upstream API is known to be down
+func (h *Handler) upstreamDown() bool {
+	for _, dep := range h.deps.Statuses() {
+		if strings.HasPrefix(dep.Name, health.Upstream("")) && dep.State == health.StateDown {
+			return true
+		}
+	}
+	return false
+}
+
+// Dependencies returns the recent check outcomes of the handler's
+// dependencies, which upstream API calls can be recorded in too
+func (h *Handler) Dependencies() *health.Tracker {
+	return h.deps
+}
+
+// MonitorDependencies runs the readiness checks every
+// HEALTH_CHECK_INTERVAL until ctx is done, so an outage is noticed, and
+// recovery too, without waiting for a probe. It returns at once if the
+// interval is 0.
+func (h *Handler) MonitorDependencies(ctx context.Context) {
+	interval := time.Duration(h.cfg.Health.CheckInterval) * time.Second
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.refreshReadiness()
+		}
+	}
+}
+
+// checkReadiness returns the last readiness report while it's within the
+// cache TTL, and otherwise runs the checks, once for all concurrent probes.
+// It reports whether the report was reused.
+func (h *Handler) checkReadiness() (readinessReport, bool) {
+	ttl := time.Duration(h.cfg.Health.CheckCacheTTL) * time.Second
+	if report := h.readyReport.Load(); report != nil && time.Since(report.CheckedAt) < ttl {
+		return *report, true
+	}
+	return h.refreshReadiness(), false
+}
+
+// refreshReadiness runs the readiness checks, once for all concurrent
+// callers, and keeps the report for probes to reuse
+func (h *Handler) refreshReadiness() readinessReport {
+	v, _, _ := h.readyChecks.Do("readiness", func() (interface{}, error) {
+		report := h.runReadinessChecks()
+		h.readyReport.Store(&report)
+		return report, nil
+	})
+	return v.(readinessReport)
+}
+
+// runReadinessChecks checks MySQL, Redis and the sync concurrently, each
+// under its own timeout, so a hung dependency only fails its own check.
+// The checks don't use a request's context, as their results are shared.
+func (h *Handler) runReadinessChecks() readinessReport {
+	timeout := time.Duration(h.cfg.Health.CheckTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	withTimeout := func(check func(ctx context.Context)) func() {
+		return func() {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			check(ctx)
+		}
+	}
+
+	report := readinessReport{CheckedAt: time.Now().UTC()}
+	var wg sync.WaitGroup
+	for _, check := range []func(){
+		withTimeout(func(ctx context.Context) {
+			report.Database = h.checkDependency(health.Database, func() error { return h.db.PingContext(ctx) })
+		}),
+		withTimeout(func(ctx context.Context) {
+			report.Redis = h.checkDependency(health.Redis, func() error { return h.redis.Ping(ctx).Err() })
+		}),
+		withTimeout(func(ctx context.Context) {
+			report.Sync = h.checkSync(ctx)
+		}),
+	} {
+		wg.Add(1)
+		go func(check func()) {
+			defer wg.Done()
+			check()
+		}(check)
+	}
+	wg.Wait()
+
+	if report.Database.Error != "" {
+		h.logger.WithField("error", report.Database.Error).Error("Database readiness check failed")
+	}
+	if report.Redis.Error != "" {
+		h.logger.WithField("error", report.Redis.Error).Error("Redis readiness check failed")
+	}
+	return report
+}
+
+// checkDependency runs a ping, records its outcome in the dependency's
+// history and returns it with its latency
+func (h *Handler) checkDependency(name string, ping func() error) dependencyCheck {
+	start := time.Now()
+	err := ping()
+	h.deps.Record(name, err, time.Since(start))
+	check := dependencyCheck{
+		Status:    "up",
+		LatencyMS: latencyMS(start),
+	}
+	if err != nil {
+		check.Status = "down"
+		check.Error = err.Error()
+	}
+	return check
+}
+
+// latencyMS returns the milliseconds since start
+func latencyMS(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
+
+// checkSync reports whether the last successful sync is within the
+// configured freshness window
+func (h *Handler) checkSync(ctx context.Context) syncCheck {
+	start := time.Now()
+	last, err := h.jobManager.LastSuccessfulSync(ctx)
+	if err != nil {
+		return syncCheck{Status: "unknown", LatencyMS: latencyMS(start), Error: err.Error()}
+	}
+	if last.IsZero() {
+		return syncCheck{Status: "unknown", LatencyMS: latencyMS(start)}
+	}
+
+	age := time.Since(last)
+	check := syncCheck{
+		Status:      "fresh",
+		LastSuccess: &last,
+		AgeSeconds:  age.Round(time.Second).Seconds(),
+		LatencyMS:   latencyMS(start),
+	}
+	if age > time.Duration(h.cfg.Health.SyncStaleAfter)*time.Second {
+		check.Status = "stale"
+	}
+	return check
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/health"
+	"api-gateway-backend/internal/tenant"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// idempotencyKeyHeader lets clients retry a POST without repeating it
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader marks a response replayed for a repeated key
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	idempotencyKeyPrefix     = "idempotency:"
+	maxIdempotencyKeyLength  = 255
+	maxIdempotentRequestSize = 1 << 20
+
+	// idempotencyLockTTL bounds how long a request holds its key, so a key
+	// is freed for retries if the instance dies mid-request. It outlasts
+	// the 3 minute manual sync timeout.
+	idempotencyLockTTL = 5 * time.Minute
+)
+
+// replayedHeaders are the response headers stored with a response and
+// replayed with it
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// idempotentResponse is what's stored under an idempotency key: the
+// fingerprint of the request that used it and, once that request is
+// handled, its response. Status is 0 while it's still in progress.
+type idempotentResponse struct {
+	Fingerprint string            `json:"fingerprint"`
+	Status      int               `json:"status,omitempty"`
+	Header      map[string]string `json:"header,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+}
+
+// idempotency honors an Idempotency-Key header on POST requests. The first
+// request with a key runs and its response is stored for the configured
+// TTL; repeats of it get that response back, marked Idempotent-Replayed,
+// without running again. A repeat while the first is still running gets
+// 409, and reusing a key for a different request gets 422. Responses that
+// aren't written by the handler, i.e. errors, and 5xx responses aren't
+// stored, so retrying after a failure runs the request again.
+func (h *Handler) idempotency() HandlerFunc {
+	ttl := time.Duration(h.cfg.Idempotency.TTL) * time.Second
+
+	return func(c Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" || !h.cfg.Idempotency.Enabled || c.Request().Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		if !validIdempotencyKey(key) {
+			abortWithError(c, apierror.BadRequest(apierror.CodeInvalidRequest, "invalid Idempotency-Key header",
+				fmt.Errorf("must be 1 to %d printable ASCII characters", maxIdempotencyKeyLength)))
+			return
+		}
+		if h.deps.Down(health.Redis) {
+			h.log(c).Warn("Redis is down, handling request without an idempotency check")
+			c.Next()
+			return
+		}
+
+		fingerprint, err := requestFingerprint(c)
+		if err != nil {
+			abortWithError(c, bodyReadError(err))
+			return
+		}
+
+		ctx := context.WithoutCancel(c.Request().Context())
+		redisKey := idempotencyKeyPrefix + idempotencyScope(c) + ":" + key
+		pending, _ := json.Marshal(idempotentResponse{Fingerprint: fingerprint})
+		acquired, err := h.redis.SetNX(ctx, redisKey, string(pending), idempotencyLockTTL).Result()
+		if err != nil {
+			h.log(c).WithError(err).Warn("Idempotency check failed, handling request")
+			c.Next()
+			return
+		}
+		if !acquired {
+			h.replayIdempotent(c, redisKey, fingerprint)
+			return
+		}
+
+		var body bytes.Buffer
+		c.TeeBody(&body)
+		c.Next()
+
+		w := c.Writer()
+		if !w.Written() || w.Status() >= http.StatusInternalServerError {
+			if err := h.redis.Del(ctx, redisKey).Err(); err != nil {
+				h.log(c).WithError(err).Warn("Failed to release idempotency key")
+			}
+			return
+		}
+		stored := idempotentResponse{Fingerprint: fingerprint, Status: w.Status(), Header: map[string]string{}, Body: body.Bytes()}
+		for _, name := range replayedHeaders {
+			if v := w.Header().Get(name); v != "" {
+				stored.Header[name] = v
+			}
+		}
+		if err := h.redis.SetJSON(ctx, redisKey, stored, ttl); err != nil {
+			h.log(c).WithError(err).Warn("Failed to store idempotent response")
+		}
+	}
+}
+
+// replayIdempotent answers a request whose key is already taken
+func (h *Handler) replayIdempotent(c Context, redisKey, fingerprint string) {
+	var stored idempotentResponse
+	err := h.redis.GetJSON(c.Request().Context(), redisKey, &stored)
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		h.log(c).WithError(err).Error("Failed to read idempotent response")
+		abortWithError(c, apierror.Internal("failed to check Idempotency-Key", err))
+		return
+	}
+
+	switch {
+	case err == nil && stored.Fingerprint != fingerprint:
+		abortWithError(c, apierror.New(http.StatusUnprocessableEntity, apierror.CodeIdempotencyReused, "Idempotency-Key already used").
+			Wrap(errors.New("the key was used for a request with a different method, path or body")))
+	case err != nil || stored.Status == 0:
+		// Missing means the first request just finished without storing
+		// a response; the client should retry either way
+		abortWithError(c, apierror.New(http.StatusConflict, apierror.CodeRequestInProgress, "request in progress").
+			Wrap(errors.New("a request with this Idempotency-Key is still being handled")))
+	default:
+		for name, value := range stored.Header {
+			c.Header(name, value)
+		}
+		c.Header(idempotentReplayedHeader, "true")
+		c.Status(stored.Status)
+		c.Writer().Write(stored.Body)
+		c.Abort()
+	}
+}
+
+// requestFingerprint hashes the method, path, query and body of the
+// request, putting the body back for the handler
+func requestFingerprint(c Context) (string, error) {
+	r := c.Request()
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxIdempotentRequestSize+1))
+		if err != nil {
+			return "", err
+		}
+		if len(body) > maxIdempotentRequestSize {
+			return "", &http.MaxBytesError{Limit: maxIdempotentRequestSize}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	sum := sha256.New()
+	fmt.Fprintf(sum, "%s %s\n", r.Method, r.URL.RequestURI())
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// idempotencyScope keeps clients' keys apart: they're per API key or JWT
+// subject, or per IP address for unauthenticated clients, within the
+// request's tenant
+func idempotencyScope(c Context) string {
+	scope := "ip:" + c.ClientIP()
+	if a := actor(c); a != "anonymous" {
+		scope = a
+	}
+	if id := tenant.From(c.Request().Context()); id != tenant.Default {
+		scope = id + "/" + scope
+	}
+	return scope
+}
+
+// validIdempotencyKey accepts non-empty, bounded, printable ASCII keys
+func validIdempotencyKey(key string) bool {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"api-gateway-backend/internal/config"
+)
+
+// ipFilter holds parsed allow and deny lists
+type ipFilter struct {
+	allow, deny []netip.Prefix
+	routes      []ipFilterRoute
+}
+
+// ipFilterRoute holds the lists for requests under prefix
+type ipFilterRoute struct {
+	prefix      string
+	allow, deny []netip.Prefix
+}
+
+// newIPFilter parses the allow and deny lists of cfg
+func newIPFilter(cfg config.IPFilterConfig) (*ipFilter, error) {
+	f := &ipFilter{}
+	var err error
+	if f.allow, err = parsePrefixes(cfg.Allow); err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+	if f.deny, err = parsePrefixes(cfg.Deny); err != nil {
+		return nil, fmt.Errorf("deny: %w", err)
+	}
+	for _, r := range cfg.Routes {
+		route := ipFilterRoute{prefix: strings.TrimSuffix(r.Prefix, "/")}
+		if route.allow, err = parsePrefixes(r.Allow); err != nil {
+			return nil, fmt.Errorf("route %q allow: %w", r.Prefix, err)
+		}
+		if route.deny, err = parsePrefixes(r.Deny); err != nil {
+			return nil, fmt.Errorf("route %q deny: %w", r.Prefix, err)
+		}
+		f.routes = append(f.routes, route)
+	}
+	return f, nil
+}
+
+// allowed reports whether ip may make a request to path: it must pass the
+// global lists and those of every route whose prefix path is under
+func (f *ipFilter) allowed(ip netip.Addr, path string) bool {
+	if !permits(f.allow, f.deny, ip) {
+		return false
+	}
+	for _, r := range f.routes {
+		if underPrefix(path, r.prefix) && !permits(r.allow, r.deny, ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// permits reports whether ip is outside deny and, if allow isn't empty,
+// inside allow. An unparseable address is only permitted without an
+// allow list.
+func permits(allow, deny []netip.Prefix, ip netip.Addr) bool {
+	if containsAddr(deny, ip) {
+		return false
+	}
+	return len(allow) == 0 || containsAddr(allow, ip)
+}
+
+// containsAddr reports whether any of prefixes contains ip
+func containsAddr(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// underPrefix reports whether path is prefix or below it
+func underPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// parsePrefixes parses IP addresses and CIDR ranges; an address is a range
+// of one
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", entry)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// clientAddr parses the client IP of the request, the zero Addr if it
+// isn't one
+func clientAddr(c Context) netip.Addr {
+	addr, err := netip.ParseAddr(c.ClientIP())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// ipFilter refuses requests from addresses outside the configured allow
+// lists or inside the deny lists with 403 FORBIDDEN. The client address
+// comes from X-Forwarded-For or X-Real-IP only when the connection is from
+// a trusted proxy. The lists are read per request, so a config reload
+// changes them.
+func (h *Handler) ipFilter() HandlerFunc {
+	return func(c Context) {
+		f := h.live.Load().ipFilter
+		if f == nil {
+			c.Next()
+			return
+		}
+		ip := clientAddr(c)
+		if !f.allowed(ip, c.Request().URL.Path) {
+			h.log(c).WithField("client_ip", c.ClientIP()).Warn("Refused request from filtered IP address")
+			abortWithError(c, errForbidden.Wrap(errors.New("requests from this IP address are not allowed")))
+			return
+		}
+		c.Next()
+	}
+}
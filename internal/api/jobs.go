@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/audit"
+	"api-gateway-backend/internal/jobs"
+	"api-gateway-backend/internal/response"
+)
+
+// listJobs handles GET /admin/jobs
+func (h *Handler) listJobs(c Context) {
+	list, err := h.jobManager.Jobs(c.Request().Context())
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to list jobs")
+		abortWithError(c, apierror.Internal("failed to retrieve jobs", err))
+		return
+	}
+
+	respond(c, http.StatusOK, reply{Data: list})
+}
+
+// getJobsLeader handles GET /admin/jobs/leader
+func (h *Handler) getJobsLeader(c Context) {
+	status, err := h.jobManager.Leader(c.Request().Context())
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to read jobs leader")
+		abortWithError(c, apierror.Internal("failed to retrieve jobs leader", err))
+		return
+	}
+
+	respond(c, http.StatusOK, reply{Data: status})
+}
+
+// runJob handles POST /admin/jobs/:name/run. The run starts on this
+// instance in the background, even if the job is paused.
+func (h *Handler) runJob(c Context) {
+	name := c.Param("name")
+	if !h.jobAction(c, name, h.jobManager.RunJob(name), "start") {
+		return
+	}
+
+	h.log(c).WithField("job", name).Info("Job run requested")
+	h.audit(c, audit.ActionJobRun, "job:"+name, nil, nil)
+	respond(c, http.StatusAccepted, reply{Meta: response.Meta{Message: "job started"}})
+}
+
+// pauseJob handles POST /admin/jobs/:name/pause
+func (h *Handler) pauseJob(c Context) {
+	name := c.Param("name")
+	if !h.jobAction(c, name, h.jobManager.PauseJob(c.Request().Context(), name), "pause") {
+		return
+	}
+
+	h.log(c).WithField("job", name).Info("Job paused")
+	h.audit(c, audit.ActionJobPause, "job:"+name, H{"paused": false}, H{"paused": true})
+	respond(c, http.StatusOK, reply{Meta: response.Meta{Message: "job paused"}})
+}
+
+// resumeJob handles POST /admin/jobs/:name/resume
+func (h *Handler) resumeJob(c Context) {
+	name := c.Param("name")
+	if !h.jobAction(c, name, h.jobManager.ResumeJob(c.Request().Context(), name), "resume") {
+		return
+	}
+
+	h.log(c).WithField("job", name).Info("Job resumed")
+	h.audit(c, audit.ActionJobResume, "job:"+name, H{"paused": true}, H{"paused": false})
+	respond(c, http.StatusOK, reply{Meta: response.Meta{Message: "job resumed"}})
+}
+
+// jobAction aborts with the error of an action on the job called name, if
+// any, and reports whether it succeeded
+func (h *Handler) jobAction(c Context, name string, err error, action string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, jobs.ErrJobNotFound):
+		abortWithError(c, apierror.NotFound(apierror.CodeJobNotFound, "job not found", fmt.Errorf("no job named %s", name)))
+	default:
+		h.log(c).WithError(err).WithField("job", name).Error("Failed to " + action + " job")
+		abortWithError(c, apierror.Internal("failed to "+action+" job", err))
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"api-gateway-backend/internal/apierror"
+)
+
+// Route timeouts, set with the timeout middleware in Register. Routes
+// without their own use http.request_timeout.
+const (
+	// syncTimeout covers a full manual sync of every upstream resource
+	syncTimeout = 3 * time.Minute
+	// importTimeout covers a bulk import of up to maxBulkItems items
+	importTimeout = time.Minute
+	// listTimeout covers pages of items and GraphQL queries
+	listTimeout = 30 * time.Second
+	// quickTimeout covers lookups answered from Redis: probes, sync job
+	// status and analytics
+	quickTimeout = 5 * time.Second
+)
+
+// maxBodySize rejects request bodies over limit bytes with 413
+// REQUEST_TOO_LARGE: up front when Content-Length declares one, otherwise
+// once a handler reads past the limit. 0 disables the limit.
+func maxBodySize(limit int64) HandlerFunc {
+	if limit <= 0 {
+		return func(c Context) { c.Next() }
+	}
+
+	return func(c Context) {
+		r := c.Request()
+		if r.ContentLength > limit {
+			abortWithError(c, bodyTooLarge(limit))
+			return
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(c.Writer(), r.Body, limit)
+		}
+		c.Next()
+	}
+}
+
+// bodyTooLarge is the error for a request body over limit bytes
+func bodyTooLarge(limit int64) *apierror.Error {
+	return apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeRequestTooLarge, "request body too large").
+		Wrap(fmt.Errorf("request bodies are limited to %d bytes", limit))
+}
+
+// bodyReadError reports a failure reading or decoding the request body:
+// 413 if it ran past the limit set by maxBodySize, 400 otherwise
+func bodyReadError(err error) *apierror.Error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return bodyTooLarge(tooLarge.Limit)
+	}
+	return apierror.BadRequest(apierror.CodeInvalidRequest, "invalid request body", err)
+}
+
+// timeout bounds the rest of the chain to d; see timeoutFunc
+func timeout(d time.Duration) HandlerFunc {
+	return timeoutFunc(func(Context) time.Duration { return d })
+}
+
+// timeoutFunc bounds the rest of the chain to the duration d picks for the
+// request, 0 leaving it unbounded. Handlers pass the request's context to
+// MySQL, Redis and the upstream, so once it expires they fail with 504
+// UPSTREAM_TIMEOUT through apierror.Internal; a handler that gives up
+// without responding gets the same.
+func timeoutFunc(d func(Context) time.Duration) HandlerFunc {
+	return func(c Context) {
+		limit := d(c)
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), limit)
+		defer cancel()
+		c.SetRequest(c.Request().WithContext(ctx))
+		c.Next()
+
+		if _, failed := c.Get(apiErrorKey); failed || c.Writer().Written() || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return
+		}
+		abortWithError(c, apierror.New(http.StatusGatewayTimeout, apierror.CodeUpstreamTimeout, "request timed out").
+			Wrap(fmt.Errorf("the request did not complete within %s", limit)))
+	}
+}
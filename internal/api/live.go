@@ -0,0 +1,223 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/events"
+	"api-gateway-backend/internal/tenant"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// liveUpdatesPath is the websocket endpoint for live item events
+	liveUpdatesPath = "/ws"
+
+	// liveWriteTimeout bounds each websocket write, so a stalled client
+	// can't hold its connection open
+	liveWriteTimeout = 10 * time.Second
+
+	// maxLiveUserIDs caps the user filter of one connection
+	maxLiveUserIDs = 100
+)
+
+// Messages sent to live clients besides item events
+const (
+	liveSubscribed = "subscribed"
+	liveHeartbeat  = "heartbeat"
+	liveError      = "error"
+)
+
+// liveClientMessage is a message sent by a live client. "subscribe"
+// replaces the connection's user filter; no user IDs means every user.
+type liveClientMessage struct {
+	Action  string `json:"action"`
+	UserIDs []int  `json:"user_ids"`
+}
+
+// liveServerMessage is a control message sent to a live client
+type liveServerMessage struct {
+	Type      string    `json:"type"`
+	UserIDs   []int     `json:"user_ids,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// liveItems handles GET /ws, upgrading to a websocket that streams item
+// created and updated events. ?user_id=1,2 limits the stream to those
+// users' items.
+func (h *Handler) liveItems(c Context) {
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		abortWithError(c, apierror.New(http.StatusUpgradeRequired, apierror.CodeInvalidRequest, "websocket upgrade required").Wrap(errors.New("missing Upgrade: websocket header")))
+		return
+	}
+
+	userIDs, err := parseUserIDs(c.Query("user_id"))
+	if err != nil {
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid user_id parameter", err))
+		return
+	}
+
+	if h.liveConns != nil {
+		select {
+		case h.liveConns <- struct{}{}:
+			defer func() { <-h.liveConns }()
+		default:
+			c.Header("Retry-After", strconv.Itoa(h.cfg.Concurrency.RetryAfter))
+			abortWithError(c, apierror.New(http.StatusServiceUnavailable, apierror.CodeServiceOverloaded, "service overloaded").Wrap(errors.New("too many live connections")))
+			return
+		}
+	}
+
+	log := h.log(c)
+	server := websocket.Server{
+		// Clients authenticate like any other API request, so any origin
+		// may connect, as with the CORS policy
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			log.WithField("user_ids", userIDs).Info("Live client connected")
+			h.serveLiveItems(ws, userIDs)
+			log.Info("Live client disconnected")
+		},
+	}
+	server.ServeHTTP(c.Writer(), c.Request())
+}
+
+// serveLiveItems streams events to ws until the client disconnects, falls
+// behind or the hub closes
+func (h *Handler) serveLiveItems(ws *websocket.Conn, userIDs []int) {
+	defer ws.Close()
+
+	// The server's read and write timeouts still apply to the hijacked
+	// connection; live connections set their own per write
+	ws.SetDeadline(time.Time{})
+
+	sub := h.hub.Subscribe(tenant.From(ws.Request().Context()), userIDs)
+	defer h.hub.Unsubscribe(sub)
+
+	// Client messages are read on their own goroutine; replies are handed
+	// back so only this goroutine writes
+	replies := make(chan liveServerMessage)
+	done := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(done)
+		for {
+			var msg liveClientMessage
+			var reply liveServerMessage
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				var syntaxErr *json.SyntaxError
+				var typeErr *json.UnmarshalTypeError
+				if !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr) {
+					return
+				}
+				reply = liveServerMessage{Type: liveError, Message: "invalid message"}
+			} else {
+				reply = h.handleLiveMessage(sub, msg)
+			}
+
+			select {
+			case replies <- reply:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(h.liveHeartbeat())
+	defer heartbeat.Stop()
+
+	send := func(v interface{}) bool {
+		ws.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+		return websocket.JSON.Send(ws, v) == nil
+	}
+
+	if !send(liveServerMessage{Type: liveSubscribed, UserIDs: sub.UserIDs(), Timestamp: time.Now().UTC()}) {
+		return
+	}
+	for {
+		var msg interface{}
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			msg = event
+		case reply := <-replies:
+			reply.Timestamp = time.Now().UTC()
+			msg = reply
+		case <-heartbeat.C:
+			msg = liveServerMessage{Type: liveHeartbeat, Timestamp: time.Now().UTC()}
+		case <-done:
+			return
+		}
+		if !send(msg) {
+			return
+		}
+	}
+}
+
+// handleLiveMessage applies a client message and returns the reply
+func (h *Handler) handleLiveMessage(sub *events.Subscription, msg liveClientMessage) liveServerMessage {
+	switch msg.Action {
+	case "subscribe":
+		if len(msg.UserIDs) > maxLiveUserIDs {
+			return liveServerMessage{Type: liveError, Message: fmt.Sprintf("at most %d user_ids", maxLiveUserIDs)}
+		}
+		sub.SetUserIDs(msg.UserIDs)
+		return liveServerMessage{Type: liveSubscribed, UserIDs: sub.UserIDs()}
+	default:
+		return liveServerMessage{Type: liveError, Message: fmt.Sprintf("unknown action %q", msg.Action)}
+	}
+}
+
+// liveHeartbeat returns the interval between heartbeats
+func (h *Handler) liveHeartbeat() time.Duration {
+	if interval := time.Duration(h.cfg.WebSocket.HeartbeatInterval) * time.Second; interval > 0 {
+		return interval
+	}
+	return 30 * time.Second
+}
+
+// publishItemEvents publishes live update events for the written items
+// that changed. Live updates are best effort and never fail the request.
+func (h *Handler) publishItemEvents(c Context, before map[string]database.Item, written []database.Item) {
+	published, err := events.PublishChanges(c.Request().Context(), h.db, h.redis, before, written)
+	if err != nil {
+		h.log(c).WithError(err).Warn("Failed to publish item events")
+		return
+	}
+	if published > 0 {
+		h.log(c).WithField("count", published).Debug("Published item events")
+	}
+}
+
+// parseUserIDs parses a comma-separated list of user IDs
+func parseUserIDs(raw string) ([]int, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(raw, ",")
+	if len(parts) > maxLiveUserIDs {
+		return nil, fmt.Errorf("at most %d user IDs", maxLiveUserIDs)
+	}
+	ids := make([]int, 0, len(parts))
+	for _, p := range parts {
+		id, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("user_id must be a comma-separated list of positive integers, got %q", p)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"api-gateway-backend/internal/audit"
+	"api-gateway-backend/internal/logger"
+)
+
+// logLevelRequest is the body of PUT /admin/loglevel
+type logLevelRequest struct {
+	Level string `json:"level" validate:"log_level"`
+}
+
+// normalize lower-cases the level
+func (r *logLevelRequest) normalize() {
+	r.Level = strings.ToLower(strings.TrimSpace(r.Level))
+}
+
+// getLogLevel handles GET /admin/loglevel
+func (h *Handler) getLogLevel(c Context) {
+	respond(c, http.StatusOK, reply{Data: logLevelRequest{Level: h.logger.LevelName()}})
+}
+
+// setLogLevel handles PUT /admin/loglevel. The level applies to the whole
+// process until it's changed again, the configured log_level changes on a
+// config reload, or the server restarts.
+func (h *Handler) setLogLevel(c Context) {
+	var req logLevelRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	level := req.Level
+
+	previous := h.logger.LevelName()
+	h.log(c).WithField("previous", previous).WithField("level", level).Warn("Changing log level")
+	h.logger.SetLevelName(level)
+	h.audit(c, audit.ActionLogLevelSet, "log_level", logLevelRequest{Level: previous}, logLevelRequest{Level: level})
+
+	respond(c, http.StatusOK, reply{Data: logLevelRequest{Level: level}})
+}
+
+// validLogLevel reports whether name is one of logger.Levels
+func validLogLevel(name string) bool {
+	for _, level := range logger.Levels {
+		if name == level {
+			return true
+		}
+	}
+	return false
+}
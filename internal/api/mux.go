@@ -0,0 +1,353 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"api-gateway-backend/internal/apierror"
+)
+
+// abortIndex is larger than any realistic handler chain
+const abortIndex = 1 << 30
+
+// Mux is a net/http adapter for Router. It implements http.Handler, so it
+// can be served directly or mounted in another router such as chi.
+type Mux struct {
+	middleware     []HandlerFunc
+	routes         []*route
+	trustedProxies []netip.Prefix
+}
+
+// remoteIPHeaders name the client when set by a trusted proxy, in the
+// order they're read; the same as Gin's defaults
+var remoteIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+
+// route is a registered method/path pattern and its handler chain
+type route struct {
+	method   string
+	segments []string
+	handlers []HandlerFunc
+}
+
+// NewMux creates a new net/http router adapter
+func NewMux() *Mux {
+	return &Mux{}
+}
+
+// SetTrustedProxies sets the IP addresses and CIDR ranges of the proxies
+// whose X-Forwarded-For and X-Real-IP headers ClientIP believes. By
+// default none are, and ClientIP is the connection's remote address.
+func (m *Mux) SetTrustedProxies(proxies []string) error {
+	prefixes, err := parsePrefixes(proxies)
+	if err != nil {
+		return err
+	}
+	m.trustedProxies = prefixes
+	return nil
+}
+
+// Use adds middleware applied to every request, including unmatched ones
+func (m *Mux) Use(middleware ...HandlerFunc) {
+	m.middleware = append(m.middleware, middleware...)
+}
+
+// Group returns a sub-router for the given path prefix
+func (m *Mux) Group(prefix string, middleware ...HandlerFunc) Router {
+	return &muxGroup{mux: m, prefix: prefix, middleware: middleware}
+}
+
+// Handle registers handlers for a method and path
+func (m *Mux) Handle(method, path string, handlers ...HandlerFunc) {
+	m.routes = append(m.routes, &route{
+		method:   method,
+		segments: splitPath(path),
+		handlers: handlers,
+	})
+}
+
+// ServeHTTP dispatches the request to the matching route
+func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c := &muxContext{
+		writer:         &responseWriter{ResponseWriter: w, status: http.StatusOK},
+		request:        r,
+		trustedProxies: m.trustedProxies,
+		index:          -1,
+	}
+
+	handlers := append([]HandlerFunc{}, m.middleware...)
+	if rt, params := m.match(r.Method, r.URL.Path); rt != nil {
+		c.params = params
+		handlers = append(handlers, rt.handlers...)
+	} else {
+		handlers = append(handlers, func(c Context) {
+			abortWithError(c, apierror.New(http.StatusNotFound, apierror.CodeRouteNotFound, "not found"))
+		})
+	}
+	c.handlers = handlers
+
+	c.Next()
+	c.writer.WriteHeaderNow()
+}
+
+// match finds the first route matching method and path
+func (m *Mux) match(method, path string) (*route, map[string]string) {
+	segments := splitPath(path)
+	for _, rt := range m.routes {
+		if rt.method != method {
+			continue
+		}
+		if params, ok := matchSegments(rt.segments, segments); ok {
+			return rt, params
+		}
+	}
+	return nil, nil
+}
+
+// matchSegments matches path segments against a route pattern
+func matchSegments(pattern, segments []string) (map[string]string, bool) {
+	params := make(map[string]string)
+	for i, p := range pattern {
+		if strings.HasPrefix(p, "*") {
+			params[p[1:]] = "/" + strings.Join(segments[i:], "/")
+			return params, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		switch {
+		case strings.HasPrefix(p, ":"):
+			params[p[1:]] = segments[i]
+		case p != segments[i]:
+			return nil, false
+		}
+	}
+	return params, len(pattern) == len(segments)
+}
+
+// splitPath splits a URL path into non-empty segments
+func splitPath(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// muxGroup is a path-prefixed view of a Mux
+type muxGroup struct {
+	mux        *Mux
+	prefix     string
+	middleware []HandlerFunc
+}
+
+// Use adds middleware to routes registered on this group afterwards
+func (g *muxGroup) Use(middleware ...HandlerFunc) {
+	g.middleware = append(g.middleware, middleware...)
+}
+
+// Group returns a nested sub-router
+func (g *muxGroup) Group(prefix string, middleware ...HandlerFunc) Router {
+	return &muxGroup{
+		mux:        g.mux,
+		prefix:     g.prefix + prefix,
+		middleware: append(append([]HandlerFunc{}, g.middleware...), middleware...),
+	}
+}
+
+// Handle registers handlers under the group prefix and middleware
+func (g *muxGroup) Handle(method, path string, handlers ...HandlerFunc) {
+	chain := append(append([]HandlerFunc{}, g.middleware...), handlers...)
+	g.mux.Handle(method, g.prefix+path, chain...)
+}
+
+// muxContext implements Context on top of net/http
+type muxContext struct {
+	writer         *responseWriter
+	request        *http.Request
+	trustedProxies []netip.Prefix
+	params         map[string]string
+	keys           map[string]interface{}
+	handlers       []HandlerFunc
+	index          int
+}
+
+func (c *muxContext) Request() *http.Request { return c.request }
+
+func (c *muxContext) SetRequest(r *http.Request) { c.request = r }
+
+func (c *muxContext) Writer() ResponseWriter { return c.writer }
+
+func (c *muxContext) TeeBody(w io.Writer) {
+	if c.writer.tee != nil {
+		w = io.MultiWriter(c.writer.tee, w)
+	}
+	c.writer.tee = w
+}
+
+func (c *muxContext) Param(key string) string { return c.params[key] }
+
+func (c *muxContext) Query(key string) string { return c.request.URL.Query().Get(key) }
+
+// ClientIP returns the remote address, or for a connection from a trusted
+// proxy the client it forwarded the request for
+func (c *muxContext) ClientIP() string {
+	host, _, err := net.SplitHostPort(c.request.RemoteAddr)
+	if err != nil {
+		host = c.request.RemoteAddr
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil || !containsAddr(c.trustedProxies, remote.Unmap()) {
+		return host
+	}
+	for _, header := range remoteIPHeaders {
+		if ip, ok := forwardedClientIP(c.request.Header.Get(header), c.trustedProxies); ok {
+			return ip
+		}
+	}
+	return host
+}
+
+// forwardedClientIP walks a comma-separated list of addresses from the
+// nearest hop back and returns the first that isn't a trusted proxy, or
+// the furthest if they all are. Clients can prepend any addresses they
+// like, so those before the first untrusted hop are ignored.
+func forwardedClientIP(header string, trusted []netip.Prefix) (string, bool) {
+	if header == "" {
+		return "", false
+	}
+	hops := strings.Split(header, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		addr, err := netip.ParseAddr(hop)
+		if err != nil {
+			return "", false
+		}
+		if i == 0 || !containsAddr(trusted, addr.Unmap()) {
+			return hop, true
+		}
+	}
+	return "", false
+}
+
+func (c *muxContext) GetHeader(key string) string { return c.request.Header.Get(key) }
+
+func (c *muxContext) Header(key, value string) {
+	if value == "" {
+		c.writer.Header().Del(key)
+		return
+	}
+	c.writer.Header().Set(key, value)
+}
+
+func (c *muxContext) Set(key string, value interface{}) {
+	if c.keys == nil {
+		c.keys = make(map[string]interface{})
+	}
+	c.keys[key] = value
+}
+
+func (c *muxContext) Get(key string) (interface{}, bool) {
+	value, ok := c.keys[key]
+	return value, ok
+}
+
+func (c *muxContext) JSON(code int, obj interface{}) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		c.writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	c.writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	c.writer.WriteHeader(code)
+	c.writer.Write(data)
+}
+
+func (c *muxContext) Status(code int) { c.writer.WriteHeader(code) }
+
+func (c *muxContext) Next() {
+	c.index++
+	for c.index < len(c.handlers) {
+		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+func (c *muxContext) Abort() { c.index = abortIndex }
+
+func (c *muxContext) AbortWithStatus(code int) {
+	c.Status(code)
+	c.writer.WriteHeaderNow()
+	c.Abort()
+}
+
+func (c *muxContext) AbortWithStatusJSON(code int, obj interface{}) {
+	c.Abort()
+	c.JSON(code, obj)
+}
+
+func (c *muxContext) IsAborted() bool { return c.index >= abortIndex }
+
+// responseWriter records the status code and body size of a response.
+// Like Gin's writer, the header is only sent on the first body write so
+// handlers can still adjust headers after choosing a status.
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	size    int
+	written bool
+	tee     io.Writer // also receives the body, if set
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if !w.written {
+		w.status = code
+	}
+}
+
+// WriteHeaderNow sends the pending status code if not already sent
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.written {
+		w.written = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	if w.tee != nil {
+		w.tee.Write(b[:n])
+	}
+	return n, err
+}
+
+// Hijack lets websocket upgrades take over the connection
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	w.written = true
+	return h.Hijack()
+}
+
+// Unwrap lets http.ResponseController flush the response or extend its
+// write deadline
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) Status() int { return w.status }
+
+func (w *responseWriter) Size() int { return w.size }
+
+func (w *responseWriter) Written() bool { return w.written }
@@ -0,0 +1,923 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/audit"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/events"
+	"api-gateway-backend/internal/health"
+	"api-gateway-backend/internal/jobs"
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/openapi"
+	"api-gateway-backend/internal/response"
+	"api-gateway-backend/internal/signing"
+	"api-gateway-backend/internal/version"
+	"api-gateway-backend/internal/webhooks"
+)
+
+// swaggerUIVersion pins the Swagger UI assets served by /docs
+const swaggerUIVersion = "5.17.14"
+
+// swaggerUIPage renders the Swagger UI against /openapi.json
+var swaggerUIPage = fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>API Gateway Backend</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`, swaggerUIVersion)
+
+// serveOpenAPI handles GET /openapi.json
+func serveOpenAPI(doc *openapi.Document) HandlerFunc {
+	return func(c Context) {
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// swaggerUI handles GET /docs
+func (h *Handler) swaggerUI(c Context) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	c.Writer().Write([]byte(swaggerUIPage))
+}
+
+// buildOpenAPI documents every route registered by Register. Schemas are
+// derived from the types handlers encode, and a test checks that each
+// registered route is documented, so the spec tracks the code.
+func (h *Handler) buildOpenAPI() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:   "API Gateway Backend",
+		Version: "1.0.0",
+		Description: "Syncs posts from an external API into MySQL, serves them with Redis caching, " +
+			"and exposes order analytics. Responses are documented as version 1 bodies; send Accept-Version: 2 to receive every JSON response, " +
+			"errors included, in the Envelope instead, with the documented data under data and counts, filters and cache status under meta.",
+	})
+
+	item := doc.Define("Item", database.Item{})
+	order := doc.Define("Order", database.Order{})
+	statusSummary := doc.Define("OrderStatusSummary", database.OrderStatusSummary{})
+	period := doc.Define("ReportPeriod", reportPeriod{})
+	topCustomer := doc.Define("TopCustomer", database.TopCustomer{})
+	itemStats := doc.Define("ItemStats", database.ItemStats{})
+	customerDetail := doc.Define("CustomerDetail", database.CustomerDetail{})
+	apiKey := doc.Define("APIKey", database.APIKey{})
+	syncRun := doc.Define("SyncRun", database.SyncRun{})
+	syncJob := doc.Define("SyncJob", jobs.SyncJob{})
+	createOrder := doc.Define("CreateOrderRequest", createOrderRequest{})
+	searchResult := doc.Define("SearchResult", searchResult{})
+	bulkItem := doc.Define("BulkItem", bulkItem{})
+	bulkResult := doc.Define("BulkItemResult", bulkResult{})
+	createAPIKey := doc.Define("CreateAPIKeyRequest", createAPIKeyRequest{})
+	webhookPayload := doc.Define("ItemsWebhookPayload", itemsWebhookPayload{})
+	webhookSub := doc.Define("WebhookSubscription", database.WebhookSubscription{})
+	webhookDelivery := doc.Define("WebhookDelivery", database.WebhookDelivery{})
+	createWebhook := doc.Define("CreateWebhookRequest", createWebhookRequest{})
+	logLevel := doc.Define("LogLevel", logLevelRequest{})
+	auditEvent := doc.Define("AuditEvent", database.AuditEvent{})
+	runtimeStats := doc.Define("RuntimeStats", runtimeStats{})
+	leaderStatus := doc.Define("LeaderStatus", jobs.LeaderStatus{})
+	jobInfo := doc.Define("Job", jobs.JobInfo{})
+	failedItem := doc.Define("FailedItem", database.FailedItem{})
+	buildInfo := doc.Define("BuildInfo", version.Info{})
+	itemEvent := doc.Define("ItemEvent", events.ItemEvent{})
+
+	codes := make([]string, 0, len(apierror.Codes()))
+	for _, code := range apierror.Codes() {
+		codes = append(codes, string(code))
+	}
+	doc.Components.Schemas["Error"] = openapi.Object(map[string]*openapi.Schema{
+		"error":      openapi.String("Short summary of the failure"),
+		"code":       {Type: "string", Enum: codes, Description: "Machine-readable error code"},
+		"message":    openapi.String("Underlying cause"),
+		"details":    {Type: "object", Description: "Structured details, when available"},
+		"request_id": openapi.String("ID to quote when reporting the problem"),
+	}, "message", "details", "request_id")
+	doc.Components.Schemas["Envelope"] = openapi.Object(map[string]*openapi.Schema{
+		"data": {Description: "The result, absent for failures and responses with only a message"},
+		"meta": openapi.Object(map[string]*openapi.Schema{
+			"pagination": doc.Define("Pagination", response.Pagination{}),
+			"cache":      doc.Define("CacheStatus", response.Cache{}),
+			"filters":    {Type: "object", Description: "Query parameters the data was selected by"},
+			"message":    openapi.String(""),
+			"request_id": openapi.String("ID to quote when reporting a problem"),
+			"timestamp":  openapi.DateTime(""),
+		}, "pagination", "cache", "filters", "message"),
+		"error": doc.Define("EnvelopeError", response.Error{}),
+	}, "data", "error")
+
+	// Security schemes, required on /api/v1 only when enabled
+	var apiSecurity []openapi.SecurityRequirement
+	if h.cfg.Auth.APIKeysEnabled {
+		doc.Components.SecuritySchemes["ApiKeyAuth"] = openapi.SecurityScheme{Type: "apiKey", In: "header", Name: apiKeyHeader}
+		apiSecurity = append(apiSecurity, openapi.SecurityRequirement{"ApiKeyAuth": {}})
+	}
+	if h.cfg.Auth.JWTEnabled {
+		doc.Components.SecuritySchemes["BearerAuth"] = openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+		apiSecurity = append(apiSecurity, openapi.SecurityRequirement{"BearerAuth": {}})
+	}
+	if h.cfg.Auth.OIDCEnabled {
+		doc.Components.SecuritySchemes["OpenID"] = openapi.SecurityScheme{
+			Type:             "openIdConnect",
+			OpenIDConnectURL: strings.TrimSuffix(h.cfg.Auth.OIDCIssuer, "/") + "/.well-known/openid-configuration",
+		}
+		apiSecurity = append(apiSecurity, openapi.SecurityRequirement{"OpenID": {}})
+	}
+	if h.cfg.Auth.SigningEnabled {
+		doc.Components.SecuritySchemes["SignedRequest"] = openapi.SecurityScheme{
+			Type: "apiKey", In: "header", Name: signing.SignatureHeader,
+			Description: "sha256= followed by the hex HMAC-SHA256, under the client's secret, of the X-Signature-Timestamp, method, " +
+				"path with query string and hex SHA-256 of the body, one per line. The client is named in X-Signature-Client.",
+		}
+		apiSecurity = append(apiSecurity, openapi.SecurityRequirement{"SignedRequest": {}})
+	}
+	doc.Components.SecuritySchemes["AdminToken"] = openapi.SecurityScheme{Type: "apiKey", In: "header", Name: "X-Admin-Token"}
+	adminSecurity := []openapi.SecurityRequirement{{"AdminToken": {}}}
+
+	// Probes and documentation
+	doc.Add(http.MethodGet, "/healthz", &openapi.Operation{
+		Summary:     "Liveness probe",
+		OperationID: "liveness",
+		Tags:        []string{"health"},
+		Responses: map[string]openapi.Response{
+			"200": jsonResponse("The process is serving", openapi.Object(map[string]*openapi.Schema{
+				"status":    openapi.String("Always \"alive\""),
+				"build":     buildInfo,
+				"timestamp": openapi.DateTime(""),
+			})),
+		},
+	})
+	readiness := openapi.Object(map[string]*openapi.Schema{
+		"status":       {Type: "string", Enum: []string{"ready", "degraded", "not_ready"}},
+		"checks":       {Type: "object", Description: "Per-dependency results for database, redis and sync, each with its status and latency_ms"},
+		"dependencies": openapi.ArrayOf(doc.Define("DependencyStatus", health.Status{})),
+		"cached":       openapi.Boolean("Whether the results were reused from an earlier probe"),
+		"checked_at":   openapi.DateTime("When the checks ran"),
+		"build":        buildInfo,
+		"timestamp":    openapi.DateTime(""),
+	})
+	doc.Add(http.MethodGet, "/readyz", &openapi.Operation{
+		Summary: "Readiness probe",
+		Description: "Checks MySQL, Redis and sync freshness concurrently, reusing the results for a few seconds, and reports the recent checks of each dependency, " +
+			"upstream API calls included. Only MySQL being down makes the instance not ready; Redis down, an upstream API down or stale synced data is reported as degraded.",
+		OperationID: "readiness",
+		Tags:        []string{"health"},
+		Responses: map[string]openapi.Response{
+			"200": jsonResponse("Ready or degraded", readiness),
+			"503": jsonResponse("MySQL is down", readiness),
+		},
+	})
+	doc.Add(http.MethodGet, "/version", &openapi.Operation{
+		Summary:     "Build information",
+		Description: "The version, git commit and build time of the running binary.",
+		OperationID: "getVersion",
+		Tags:        []string{"health"},
+		Responses: map[string]openapi.Response{
+			"200": jsonResponse("The running build", dataEnvelope(buildInfo, nil)),
+		},
+	})
+	doc.Add(http.MethodGet, "/metrics", &openapi.Operation{
+		Summary:     "Prometheus metrics",
+		Description: "Database query durations and errors by query, in the Prometheus text exposition format.",
+		OperationID: "getMetrics",
+		Tags:        []string{"health"},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The metrics", Content: map[string]openapi.MediaType{"text/plain": {Schema: openapi.String("")}}},
+		},
+	})
+	doc.Add(http.MethodGet, "/openapi.json", &openapi.Operation{
+		Summary:     "OpenAPI document",
+		OperationID: "getOpenAPI",
+		Tags:        []string{"docs"},
+		Responses: map[string]openapi.Response{
+			"200": jsonResponse("This document", &openapi.Schema{Type: "object"}),
+		},
+	})
+	doc.Add(http.MethodGet, "/docs", &openapi.Operation{
+		Summary:     "Swagger UI",
+		OperationID: "getDocs",
+		Tags:        []string{"docs"},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "HTML page", Content: map[string]openapi.MediaType{"text/html": {Schema: openapi.String("")}}},
+		},
+	})
+
+	// Sync
+	doc.Add(http.MethodPost, "/api/v1/sync", &openapi.Operation{
+		Summary:     "Sync data from the external API",
+		Description: "Blocks until the sync finishes, or with async=true returns 202 and a job to poll.",
+		OperationID: "syncData",
+		Tags:        []string{"sync"},
+		Security:    apiSecurity,
+		Parameters: []openapi.Parameter{
+			queryParam("async", openapi.Boolean("Run in the background and return a job ID")),
+			idempotencyKey,
+		},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("Sync completed", openapi.Object(map[string]*openapi.Schema{
+				"message":   openapi.String(""),
+				"timestamp": openapi.DateTime(""),
+			})),
+			"202": {
+				Description: "Sync queued",
+				Headers:     map[string]openapi.Header{"Location": {Schema: openapi.String("URL of the sync job")}},
+				Content: openapi.JSON(openapi.Object(map[string]*openapi.Schema{
+					"message":    openapi.String(""),
+					"job_id":     openapi.String(""),
+					"status":     openapi.String(""),
+					"status_url": openapi.String(""),
+					"timestamp":  openapi.DateTime(""),
+				})),
+			},
+		}, http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusInternalServerError, http.StatusGatewayTimeout),
+	})
+	doc.Add(http.MethodGet, "/api/v1/sync/history", &openapi.Operation{
+		Summary:     "List past sync runs",
+		OperationID: "listSyncHistory",
+		Tags:        []string{"sync"},
+		Security:    apiSecurity,
+		Parameters: []openapi.Parameter{
+			queryParam("limit", openapi.Integer("1-"+strconv.Itoa(maxSyncHistoryLimit)+", default "+strconv.Itoa(defaultSyncHistoryLimit))),
+			queryParam("offset", openapi.Integer("Number of runs to skip")),
+		},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("Sync runs, most recent first", pageEnvelope(syncRun)),
+		}, http.StatusBadRequest, http.StatusInternalServerError),
+	})
+	doc.Add(http.MethodGet, "/api/v1/sync/:id", &openapi.Operation{
+		Summary:     "Get an async sync job",
+		OperationID: "getSyncJob",
+		Tags:        []string{"sync"},
+		Security:    apiSecurity,
+		Parameters:  []openapi.Parameter{pathParam("id", openapi.String("Sync job ID"))},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("The sync job", dataEnvelope(syncJob, nil)),
+		}, http.StatusNotFound, http.StatusInternalServerError),
+	})
+
+	// Items
+	doc.Add(http.MethodGet, "/api/v1/items", &openapi.Operation{
+		Summary:     "List items",
+		OperationID: "listItems",
+		Tags:        []string{"items"},
+		Security:    apiSecurity,
+		Parameters: []openapi.Parameter{
+			queryParam("user_id", openapi.Integer("")),
+			queryParam("external_id", openapi.String("")),
+			queryParam("created_from", openapi.String("RFC 3339 timestamp or YYYY-MM-DD date")),
+			queryParam("created_to", openapi.String("RFC 3339 timestamp or YYYY-MM-DD date")),
+			queryParam("sort", openapi.String("Column to sort by; prefix with - for descending")),
+			queryParam("include_deleted", openapi.Boolean("Also return items the upstream no longer has, with deleted_at set")),
+			formatParam,
+			ifNoneMatch,
+		},
+		Responses: withErrors(withExports(withETag(map[string]openapi.Response{
+			"200": cachedResponse("Items", dataEnvelope(openapi.ArrayOf(item), map[string]*openapi.Schema{
+				"count":  openapi.Integer(""),
+				"cached": openapi.Boolean("Whether the response was served from cache"),
+			})),
+		})), http.StatusBadRequest, http.StatusInternalServerError),
+	})
+	doc.Add(http.MethodGet, "/api/v1/items/:id", &openapi.Operation{
+		Summary:     "Get an item",
+		OperationID: "getItem",
+		Tags:        []string{"items"},
+		Security:    apiSecurity,
+		Parameters:  []openapi.Parameter{pathParam("id", openapi.Integer("Item ID")), ifNoneMatch},
+		Responses: withErrors(withETag(map[string]openapi.Response{
+			"200": cachedResponse("The item", dataEnvelope(item, map[string]*openapi.Schema{
+				"cached": openapi.Boolean("Whether the response was served from cache"),
+			})),
+		}), http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+	})
+
+	doc.Add(http.MethodGet, "/api/v1/items/search", &openapi.Operation{
+		Summary: "Search items",
+		Description: "Full-text search over item titles and bodies, most relevant first. Highlights are HTML-escaped, " +
+			"with each match of a search term wrapped in " + highlightOpen + "; the body highlight is a snippet around its first match.",
+		OperationID: "searchItems",
+		Tags:        []string{"items"},
+		Security:    apiSecurity,
+		Parameters: []openapi.Parameter{
+			{Name: "q", In: "query", Required: true, Schema: openapi.String("Search terms, at most " + strconv.Itoa(maxSearchQuery) + " characters")},
+			queryParam("limit", openapi.Integer("1-"+strconv.Itoa(maxSearchLimit)+", default "+strconv.Itoa(defaultSearchLimit))),
+			queryParam("offset", openapi.Integer("Number of results to skip")),
+		},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("Matching items", dataEnvelope(openapi.ArrayOf(searchResult), map[string]*openapi.Schema{
+				"count":  openapi.Integer("Number of results in this page"),
+				"query":  openapi.String(""),
+				"limit":  openapi.Integer(""),
+				"offset": openapi.Integer(""),
+			})),
+		}, http.StatusBadRequest, http.StatusInternalServerError),
+	})
+	bulkBody := openapi.JSON(openapi.ArrayOf(bulkItem))
+	bulkBody[ndjsonContentType] = openapi.MediaType{Schema: openapi.String("One BulkItem JSON object per line")}
+	doc.Add(http.MethodPost, "/api/v1/items/bulk", &openapi.Operation{
+		Summary: "Import items",
+		Description: "Upserts up to " + strconv.Itoa(maxBulkItems) + " items without the upstream, e.g. for backfills. " +
+			"Each record is validated on its own; invalid records and repeated external IDs are reported as failed " +
+			"while the rest are imported.",
+		OperationID: "importItems",
+		Tags:        []string{"items"},
+		Security:    apiSecurity,
+		Parameters:  []openapi.Parameter{idempotencyKey},
+		RequestBody: &openapi.RequestBody{Required: true, Content: bulkBody},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("What became of each record, in order", dataEnvelope(openapi.ArrayOf(bulkResult), map[string]*openapi.Schema{
+				"created": openapi.Integer("Records imported as new items"),
+				"updated": openapi.Integer("Records that updated existing items"),
+				"failed":  openapi.Integer("Records not imported"),
+			})),
+		}, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusInternalServerError),
+	})
+
+	// Orders
+	doc.Add(http.MethodGet, "/api/v1/orders", &openapi.Operation{
+		Summary:     "List orders",
+		OperationID: "listOrders",
+		Tags:        []string{"orders"},
+		Security:    apiSecurity,
+		Parameters: []openapi.Parameter{
+			queryParam("status", &openapi.Schema{Type: "string", Enum: []string{"PENDING", "PAID", "CANCELLED"}}),
+			queryParam("customer_id", openapi.String("")),
+			queryParam("from", openapi.String("RFC 3339 timestamp or YYYY-MM-DD date")),
+			queryParam("to", openapi.String("RFC 3339 timestamp or YYYY-MM-DD date")),
+			queryParam("limit", openapi.Integer("1-"+strconv.Itoa(maxOrdersLimit)+", default "+strconv.Itoa(defaultOrdersLimit))),
+			queryParam("offset", openapi.Integer("Number of orders to skip")),
+		},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("Orders", pageEnvelope(order)),
+		}, http.StatusBadRequest, http.StatusInternalServerError),
+	})
+	doc.Add(http.MethodPost, "/api/v1/orders", &openapi.Operation{
+		Summary:     "Create an order",
+		OperationID: "createOrder",
+		Tags:        []string{"orders"},
+		Security:    apiSecurity,
+		Parameters:  []openapi.Parameter{idempotencyKey},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(createOrder)},
+		Responses: withErrors(map[string]openapi.Response{
+			"201": jsonResponse("The created order", dataEnvelope(order, nil)),
+		}, http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusInternalServerError),
+	})
+	doc.Add(http.MethodGet, "/api/v1/orders/:id", &openapi.Operation{
+		Summary:     "Get an order",
+		OperationID: "getOrder",
+		Tags:        []string{"orders"},
+		Security:    apiSecurity,
+		Parameters:  []openapi.Parameter{pathParam("id", openapi.Integer("Order ID"))},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("The order", dataEnvelope(order, nil)),
+		}, http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+	})
+
+	// Analytics
+	doc.Add(http.MethodGet, "/api/v1/analytics/orders/status", &openapi.Operation{
+		Summary:     "Orders and total amount by status over a period",
+		Description: "Ungrouped 7d and 30d summaries are served from the analytics aggregates; other periods and time series query the database.",
+		OperationID: "getOrderStatusSummary",
+		Tags:        []string{"analytics"},
+		Security:    apiSecurity,
+		Parameters: []openapi.Parameter{
+			queryParam("period", &openapi.Schema{Type: "string", Enum: []string{"7d", "30d", "90d", "custom"}, Description: "Calendar days including today, or custom (default 30d, or custom when from/to are set)"}),
+			queryParam("from", openapi.String("Start of a custom period; RFC 3339 timestamp or YYYY-MM-DD date")),
+			queryParam("to", openapi.String("End of a custom period (default now); RFC 3339 timestamp or YYYY-MM-DD date")),
+			queryParam("group_by", &openapi.Schema{Type: "string", Enum: []string{database.GroupByDay, database.GroupByWeek}, Description: "Return a time series with one row per period_start and status"}),
+			formatParam,
+		},
+		Responses: withErrors(withExports(map[string]openapi.Response{
+			"200": cachedResponse("Totals per status", dataEnvelope(openapi.ArrayOf(statusSummary), map[string]*openapi.Schema{
+				"period":      period,
+				"computed_at": openapi.DateTime("When the order stats the totals were read from were computed; absent for live totals"),
+			})),
+		}), http.StatusBadRequest, http.StatusInternalServerError),
+	})
+	doc.Add(http.MethodGet, "/api/v1/analytics/customers/top", &openapi.Operation{
+		Summary:     "Top customers by total spend",
+		Description: "All-time rankings are served from the analytics aggregates; a from/to window queries the database.",
+		OperationID: "getTopCustomers",
+		Tags:        []string{"analytics"},
+		Security:    apiSecurity,
+		Parameters: []openapi.Parameter{
+			queryParam("limit", openapi.Integer("Customers to return, 1-100 (default 5)")),
+			queryParam("from", openapi.String("Only count orders created at or after; RFC 3339 timestamp or YYYY-MM-DD date")),
+			queryParam("to", openapi.String("Only count orders created at or before; RFC 3339 timestamp or YYYY-MM-DD date")),
+			formatParam,
+		},
+		Responses: withErrors(withExports(map[string]openapi.Response{
+			"200": cachedResponse("Top customers", dataEnvelope(openapi.ArrayOf(topCustomer), map[string]*openapi.Schema{
+				"limit": openapi.Integer(""),
+			})),
+		}), http.StatusBadRequest, http.StatusInternalServerError),
+	})
+	doc.Add(http.MethodGet, "/api/v1/analytics/customers/:id", &openapi.Operation{
+		Summary:     "Customer order summary",
+		Description: "Lifetime spend, order count, average order value and totals per status of one customer, with their spend per calendar month. Months without orders are left out.",
+		OperationID: "getCustomerDetail",
+		Tags:        []string{"analytics"},
+		Security:    apiSecurity,
+		Parameters: []openapi.Parameter{
+			pathParam("id", openapi.String("Customer ID")),
+			queryParam("months", openapi.Integer("Calendar months of spend including the current one, 1-"+strconv.Itoa(maxCustomerMonths)+" (default "+strconv.Itoa(defaultCustomerMonths)+")")),
+		},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": cachedResponse("Customer order summary", dataEnvelope(customerDetail, map[string]*openapi.Schema{
+				"since": openapi.DateTime("Start of the first month of spend"),
+			})),
+		}, http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+	})
+	doc.Add(http.MethodGet, "/api/v1/analytics/items", &openapi.Operation{
+		Summary: "Item statistics",
+		Description: "Live and deleted item counts, live items per user and items created per day, cached briefly until a sync changes items. " +
+			"last_sync is read on every request.",
+		OperationID: "getItemStats",
+		Tags:        []string{"analytics"},
+		Security:    apiSecurity,
+		Parameters: []openapi.Parameter{
+			queryParam("days", openapi.Integer("Calendar days of daily counts including today, 1-"+strconv.Itoa(maxItemStatsDays)+" (default "+strconv.Itoa(defaultItemStatsDays)+")")),
+			queryParam("users", openapi.Integer("Users with the most items to list, 1-"+strconv.Itoa(maxItemStatsUsers)+" (default "+strconv.Itoa(defaultItemStatsUsers)+")")),
+		},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": cachedResponse("Item statistics", dataEnvelope(itemStats, map[string]*openapi.Schema{
+				"last_sync": openapi.Object(map[string]*openapi.Schema{
+					"succeeded_at": openapi.DateTime("When data was last synced without errors, null if never"),
+					"age_seconds":  openapi.Integer("Seconds since then, null if never"),
+				}),
+				"days":  openapi.Integer(""),
+				"users": openapi.Integer(""),
+			})),
+		}, http.StatusBadRequest, http.StatusInternalServerError),
+	})
+
+	// Admin
+	doc.Add(http.MethodGet, "/admin/api-keys", &openapi.Operation{
+		Summary:     "List API keys",
+		OperationID: "listAPIKeys",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("API keys", dataEnvelope(openapi.ArrayOf(apiKey), map[string]*openapi.Schema{
+				"count": openapi.Integer(""),
+			})),
+		}, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError),
+	})
+	doc.Add(http.MethodPost, "/admin/api-keys", &openapi.Operation{
+		Summary:     "Issue an API key",
+		Description: "The plaintext key is only returned in this response.",
+		OperationID: "createAPIKey",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(createAPIKey)},
+		Responses: withErrors(map[string]openapi.Response{
+			"201": jsonResponse("The issued key", dataEnvelope(apiKey, map[string]*openapi.Schema{
+				"key": openapi.String("Plaintext API key"),
+			})),
+		}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError),
+	})
+	doc.Add(http.MethodDelete, "/admin/api-keys/:id", &openapi.Operation{
+		Summary:     "Revoke an API key",
+		OperationID: "revokeAPIKey",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		Parameters:  []openapi.Parameter{pathParam("id", openapi.Integer("API key ID"))},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("The revoked key", dataEnvelope(apiKey, nil)),
+		}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError),
+	})
+
+	doc.Add(http.MethodGet, "/admin/webhooks", &openapi.Operation{
+		Summary:     "List webhook subscriptions",
+		OperationID: "listWebhooks",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("Webhook subscriptions, newest first", dataEnvelope(openapi.ArrayOf(webhookSub), map[string]*openapi.Schema{
+				"count": openapi.Integer(""),
+			})),
+		}, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError),
+	})
+	doc.Add(http.MethodPost, "/admin/webhooks", &openapi.Operation{
+		Summary: "Subscribe a URL to data change events",
+		Description: "Events: " + strings.Join(webhooks.EventTypes, ", ") + "; omit events to receive all of them. " +
+			"Deliveries are POSTed with X-Webhook-Signature, sha256= followed by the hex HMAC-SHA256 of \"<X-Webhook-Timestamp>.<body>\" under the secret, " +
+			"which is generated when omitted and only returned in this response.",
+		OperationID: "createWebhook",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(createWebhook)},
+		Responses: withErrors(map[string]openapi.Response{
+			"201": jsonResponse("The subscription", dataEnvelope(webhookSub, map[string]*openapi.Schema{
+				"secret": openapi.String("Signing secret"),
+			})),
+		}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError),
+	})
+	doc.Add(http.MethodDelete, "/admin/webhooks/:id", &openapi.Operation{
+		Summary:     "Disable a webhook subscription",
+		Description: "Pending deliveries are cancelled; the delivery log is kept.",
+		OperationID: "disableWebhook",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		Parameters:  []openapi.Parameter{pathParam("id", openapi.Integer("Subscription ID"))},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("The disabled subscription", dataEnvelope(webhookSub, nil)),
+		}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError),
+	})
+	doc.Add(http.MethodGet, "/admin/webhooks/:id/deliveries", &openapi.Operation{
+		Summary:     "List a subscription's deliveries",
+		OperationID: "listWebhookDeliveries",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		Parameters: []openapi.Parameter{
+			pathParam("id", openapi.Integer("Subscription ID")),
+			queryParam("limit", openapi.Integer("1-"+strconv.Itoa(maxWebhookDeliveriesLimit)+", default "+strconv.Itoa(defaultWebhookDeliveriesLimit))),
+			queryParam("offset", openapi.Integer("Number of deliveries to skip")),
+		},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("Deliveries, most recent first", pageEnvelope(webhookDelivery)),
+		}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError),
+	})
+
+	doc.Add(http.MethodGet, "/admin/loglevel", &openapi.Operation{
+		Summary:     "Get the log level",
+		OperationID: "getLogLevel",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("The current log level", dataEnvelope(logLevel, nil)),
+		}, http.StatusUnauthorized, http.StatusForbidden),
+	})
+	doc.Add(http.MethodPut, "/admin/loglevel", &openapi.Operation{
+		Summary: "Change the log level",
+		Description: "Level is one of " + strings.Join(logger.Levels, ", ") + ". It applies until changed again, " +
+			"the configured log_level changes on a config reload, or the server restarts.",
+		OperationID: "setLogLevel",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(logLevel)},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("The new log level", dataEnvelope(logLevel, nil)),
+		}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden),
+	})
+	doc.Add(http.MethodGet, "/admin/audit", &openapi.Operation{
+		Summary: "List audit events",
+		Description: "Mutating actions, newest first: sync triggers, created orders, applied items webhooks, item imports, " +
+			"API key and webhook changes, log level changes and config reloads. Changes maps each changed field " +
+			"to its old and new value; credentials are recorded as " + audit.Redacted + ".",
+		OperationID: "listAuditEvents",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		Parameters: []openapi.Parameter{
+			queryParam("action", openapi.String("Only this action, e.g. "+audit.ActionOrderCreate)),
+			queryParam("actor", openapi.String("Only this actor: admin, webhook, config, api_key:<id> or user:<subject>")),
+			queryParam("target", openapi.String("Only this target, e.g. order:42")),
+			queryParam("from", openapi.String("RFC 3339 timestamp or YYYY-MM-DD date, inclusive")),
+			queryParam("to", openapi.String("RFC 3339 timestamp or YYYY-MM-DD date, inclusive")),
+			queryParam("limit", openapi.Integer("1-"+strconv.Itoa(maxAuditLimit)+", default "+strconv.Itoa(defaultAuditLimit))),
+			queryParam("offset", openapi.Integer("Number of events to skip")),
+		},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("Audit events, most recent first", pageEnvelope(auditEvent)),
+		}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError),
+	})
+
+	doc.Add(http.MethodGet, "/admin/jobs", &openapi.Operation{
+		Summary: "List background jobs",
+		Description: "Registered jobs with their schedule, next run by the schedule, and last run on any instance. " +
+			"Paused jobs have no next run.",
+		OperationID: "listJobs",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("Jobs in registration order", dataEnvelope(openapi.ArrayOf(jobInfo), nil)),
+		}, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError),
+	})
+	jobMessage := openapi.Object(map[string]*openapi.Schema{
+		"message":   openapi.String(""),
+		"timestamp": openapi.DateTime(""),
+	})
+	for _, op := range []struct{ action, summary, description, id, status string }{
+		{"run", "Run a job now", "Starts a run on this instance in the background, even if the job is paused. " +
+			"It takes the job's lock like a scheduled run, so it is skipped if another instance holds it.", "runJob", "202"},
+		{"pause", "Pause a job", "Scheduled and startup runs are skipped on every instance until the job is resumed.", "pauseJob", "200"},
+		{"resume", "Resume a job", "The job runs on its schedule again.", "resumeJob", "200"},
+	} {
+		doc.Add(http.MethodPost, "/admin/jobs/:name/"+op.action, &openapi.Operation{
+			Summary:     op.summary,
+			Description: op.description,
+			OperationID: op.id,
+			Tags:        []string{"admin"},
+			Security:    adminSecurity,
+			Parameters:  []openapi.Parameter{pathParam("name", openapi.String("Job name, e.g. sync"))},
+			Responses: withErrors(map[string]openapi.Response{
+				op.status: jsonResponse("Done", jobMessage),
+			}, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError),
+		})
+	}
+	doc.Add(http.MethodGet, "/admin/jobs/leader", &openapi.Operation{
+		Summary: "Get the jobs leader",
+		Description: "The instance that holds the leader lease and runs the background job scheduler. " +
+			"Without leader election every instance runs its own scheduler and reports itself.",
+		OperationID: "getJobsLeader",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("The leading instance", dataEnvelope(leaderStatus, nil)),
+		}, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError),
+	})
+	doc.Add(http.MethodGet, "/admin/failed-items", &openapi.Operation{
+		Summary: "List failed items",
+		Description: "Dead letters: synced items whose upsert kept failing through every retry and was given up on. " +
+			"An item given up on again replaces its earlier dead letter.",
+		OperationID: "listFailedItems",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		Parameters: []openapi.Parameter{
+			queryParam("limit", openapi.Integer("1-"+strconv.Itoa(maxFailedItemsLimit)+", default "+strconv.Itoa(defaultFailedItemsLimit))),
+			queryParam("offset", openapi.Integer("Number of items to skip")),
+		},
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("Failed items, most recently given up on first", pageEnvelope(failedItem)),
+		}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError),
+	})
+	doc.Add(http.MethodPost, "/admin/failed-items/:id/requeue", &openapi.Operation{
+		Summary:     "Requeue a failed item",
+		Description: "Moves the item back to the retry queue with a fresh set of attempts; the next item retry run upserts it.",
+		OperationID: "requeueFailedItem",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		Parameters:  []openapi.Parameter{pathParam("id", openapi.Integer("Failed item ID"))},
+		Responses: withErrors(map[string]openapi.Response{
+			"202": jsonResponse("The requeued item", dataEnvelope(failedItem, map[string]*openapi.Schema{
+				"message": openapi.String(""),
+			})),
+		}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError),
+	})
+
+	doc.Add(http.MethodGet, "/admin/debug/runtime", &openapi.Operation{
+		Summary:     "Get runtime statistics",
+		Description: "Goroutine count, heap usage and garbage collection pauses of this instance.",
+		OperationID: "getRuntimeStats",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		Responses: withErrors(map[string]openapi.Response{
+			"200": jsonResponse("Runtime statistics", dataEnvelope(runtimeStats, nil)),
+		}, http.StatusUnauthorized, http.StatusForbidden),
+	})
+	doc.Add(http.MethodGet, "/admin/debug/vars", &openapi.Operation{
+		Summary:     "Get expvar variables",
+		Description: "The variables published through Go's expvar package, including memstats and cmdline.",
+		OperationID: "getExpvar",
+		Tags:        []string{"admin"},
+		Security:    adminSecurity,
+		Responses: withErrors(map[string]openapi.Response{
+			"200": {Description: "The variables as a JSON object"},
+		}, http.StatusUnauthorized, http.StatusForbidden),
+	})
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		doc.Add(method, "/admin/debug/pprof/*profile", &openapi.Operation{
+			Summary: "Get a pprof profile",
+			Description: "Go's net/http/pprof handlers, for use with go tool pprof. An empty profile lists the available ones; " +
+				"profile and trace run for ?seconds=, which must be below the server's 15 second write timeout.",
+			OperationID: "pprof" + strings.ToUpper(method[:1]) + strings.ToLower(method[1:]),
+			Tags:        []string{"admin"},
+			Security:    adminSecurity,
+			Parameters:  []openapi.Parameter{pathParam("profile", openapi.String("Profile name, e.g. heap, goroutine, profile or trace"))},
+			Responses: withErrors(map[string]openapi.Response{
+				"200": {Description: "The profile, in the format pprof chooses for it"},
+			}, http.StatusUnauthorized, http.StatusForbidden),
+		})
+	}
+
+	// Webhooks, only mounted when a signing secret is configured
+	if h.cfg.Webhooks.Secret != "" {
+		doc.Add(http.MethodPost, "/api/v1/webhooks/items", &openapi.Operation{
+			Summary: "Push item updates from the upstream",
+			Description: "Authenticated by X-Webhook-Signature, sha256= followed by the hex HMAC-SHA256 of \"<X-Webhook-Timestamp>.<body>\". " +
+				"Items are upserted immediately; a redelivered event id is acknowledged without being applied again.",
+			OperationID: "itemsWebhook",
+			Tags:        []string{"webhooks"},
+			Parameters: []openapi.Parameter{
+				{Name: webhooks.SignatureHeader, In: "header", Required: true, Schema: openapi.String("sha256=<hex HMAC-SHA256>")},
+				{Name: webhooks.TimestampHeader, In: "header", Required: true, Schema: openapi.Integer("Unix time the delivery was signed")},
+			},
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(webhookPayload)},
+			Responses: withErrors(map[string]openapi.Response{
+				"200": jsonResponse("The delivery was applied or was a duplicate", openapi.Object(map[string]*openapi.Schema{
+					"event_id":  openapi.String(""),
+					"duplicate": openapi.Boolean("The event had already been applied"),
+					"count":     openapi.Integer("Items in the delivery"),
+					"timestamp": openapi.DateTime(""),
+				})),
+			}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusRequestEntityTooLarge, http.StatusInternalServerError, http.StatusServiceUnavailable),
+		})
+	}
+
+	// Live updates, only mounted when enabled
+	if h.cfg.WebSocket.Enabled && h.hub != nil {
+		doc.Add(http.MethodGet, liveUpdatesPath, &openapi.Operation{
+			Summary: "Stream live item updates over a websocket",
+			Description: "Upgrades to a websocket that receives an ItemEvent JSON message whenever an item is created or changed by a sync or webhook, " +
+				"plus {\"type\":\"heartbeat\"} messages while idle. " +
+				"Send {\"action\":\"subscribe\",\"user_ids\":[1,2]} to change the user filter; an empty list receives every user's items.",
+			OperationID: "liveItems",
+			Tags:        []string{"items"},
+			Security:    apiSecurity,
+			Parameters: []openapi.Parameter{
+				queryParam("user_id", openapi.String("Comma-separated user IDs to receive items for, all users if omitted")),
+			},
+			Responses: withErrors(map[string]openapi.Response{
+				"101": jsonResponse("Switched to the websocket protocol; messages are item events", itemEvent),
+			}, http.StatusBadRequest, http.StatusUpgradeRequired, http.StatusServiceUnavailable),
+		})
+	}
+
+	// GraphQL, only mounted when enabled
+	if h.cfg.GraphQL.Enabled {
+		doc.Add(http.MethodPost, graphqlPath, &openapi.Operation{
+			Summary: "Run a GraphQL query or mutation",
+			Description: "Queries items, orders and analytics, and triggers syncs, over the same data and caches as /api/v1. " +
+				"The schema is available by introspection. Query and resolver errors are returned with a 200 in the errors list, " +
+				"each with the API error code under extensions.code.",
+			OperationID: "graphql",
+			Tags:        []string{"graphql"},
+			Security:    apiSecurity,
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.Object(map[string]*openapi.Schema{
+				"query":         openapi.String("GraphQL document"),
+				"operationName": openapi.String("Operation to run when the document has several"),
+				"variables":     {Type: "object", Description: "Values for the operation's variables"},
+			}, "operationName", "variables"))},
+			Responses: withErrors(map[string]openapi.Response{
+				"200": jsonResponse("The GraphQL result", openapi.Object(map[string]*openapi.Schema{
+					"data":   {Type: "object", Description: "Selected fields, absent if the query was invalid"},
+					"errors": openapi.ArrayOf(&openapi.Schema{Type: "object", Description: "Query and resolver errors, with message, path and extensions.code"}),
+				}, "data", "errors")),
+			}, http.StatusBadRequest),
+		})
+	}
+
+	// Proxy passthrough, only mounted when routes are configured
+	if len(h.cfg.Proxy.Routes) > 0 {
+		prefixes := make([]string, 0, len(h.cfg.Proxy.Routes))
+		for _, route := range h.cfg.Proxy.Routes {
+			prefixes = append(prefixes, "/proxy"+route.Prefix+" → "+strings.Join(route.Targets, " | "))
+		}
+		for _, method := range proxyMethods {
+			doc.Add(method, "/proxy/*path", &openapi.Operation{
+				Summary:     "Forward a request to a configured service",
+				Description: "The route prefix is stripped and the rest of the request is passed through unchanged. Routes: " + strings.Join(prefixes, ", "),
+				OperationID: "proxy" + strings.ToUpper(method[:1]) + strings.ToLower(method[1:]),
+				Tags:        []string{"proxy"},
+				Security:    apiSecurity,
+				Parameters:  []openapi.Parameter{pathParam("path", openapi.String("Route prefix and path on the target service"))},
+				Responses: withErrors(map[string]openapi.Response{
+					"default": {Description: "The target service's response"},
+				}, http.StatusNotFound, http.StatusBadGateway, http.StatusGatewayTimeout),
+			})
+		}
+	}
+
+	// Middleware failures apply to every /api/v1, /proxy, /ws and /graphql route
+	v1Errors := []int{http.StatusServiceUnavailable}
+	if len(apiSecurity) > 0 {
+		v1Errors = append(v1Errors, http.StatusUnauthorized)
+	}
+	if h.cfg.RateLimit.Enabled {
+		v1Errors = append(v1Errors, http.StatusTooManyRequests)
+	}
+	for path, item := range doc.Paths {
+		if strings.HasPrefix(path, "/api/v1/webhooks/") {
+			continue
+		}
+		if strings.HasPrefix(path, "/api/v1/") || strings.HasPrefix(path, "/proxy/") || path == liveUpdatesPath || path == graphqlPath {
+			for _, op := range item {
+				withErrors(op.Responses, v1Errors...)
+			}
+		}
+	}
+
+	return doc
+}
+
+// dataEnvelope is the {"data", "timestamp"} body shared by API responses,
+// plus any endpoint-specific fields
+func dataEnvelope(data *openapi.Schema, extra map[string]*openapi.Schema) *openapi.Schema {
+	properties := map[string]*openapi.Schema{
+		"data":      data,
+		"timestamp": openapi.DateTime(""),
+	}
+	for name, schema := range extra {
+		properties[name] = schema
+	}
+	return openapi.Object(properties)
+}
+
+// pageEnvelope is the envelope of paginated list responses
+func pageEnvelope(item *openapi.Schema) *openapi.Schema {
+	return dataEnvelope(openapi.ArrayOf(item), map[string]*openapi.Schema{
+		"count":  openapi.Integer("Number of results in this page"),
+		"limit":  openapi.Integer(""),
+		"offset": openapi.Integer(""),
+	})
+}
+
+// jsonResponse documents a JSON response
+func jsonResponse(description string, schema *openapi.Schema) openapi.Response {
+	return openapi.Response{Description: description, Content: openapi.JSON(schema)}
+}
+
+// cachedResponse documents a JSON response carrying the X-Cache header
+func cachedResponse(description string, schema *openapi.Schema) openapi.Response {
+	r := jsonResponse(description, schema)
+	r.Headers = map[string]openapi.Header{
+		"X-Cache": {Description: "HIT or MISS", Schema: openapi.String("")},
+	}
+	return r
+}
+
+// ifNoneMatch documents the conditional request header ETag endpoints honour
+var ifNoneMatch = openapi.Parameter{
+	Name:        "If-None-Match",
+	In:          "header",
+	Description: "ETag from a previous response; a match returns 304 with no body",
+	Schema:      openapi.String(""),
+}
+
+// idempotencyKey documents the header POST endpoints under /api/v1 honour
+var idempotencyKey = openapi.Parameter{
+	Name: idempotencyKeyHeader,
+	In:   "header",
+	Description: "Client-chosen key, e.g. a UUID, making retries safe: a repeat of a handled request gets its response " +
+		"back with Idempotent-Replayed: true instead of running again. Errors aren't stored, so retrying after one runs the request again.",
+	Schema: openapi.String(""),
+}
+
+// withETag adds the ETag header to the 200 response and documents the 304
+// returned when If-None-Match still matches
+func withETag(responses map[string]openapi.Response) map[string]openapi.Response {
+	etag := openapi.Header{Description: "Weak validator for the response body", Schema: openapi.String("")}
+	ok := responses["200"]
+	if ok.Headers == nil {
+		ok.Headers = make(map[string]openapi.Header)
+	}
+	ok.Headers["ETag"] = etag
+	responses["200"] = ok
+	responses["304"] = openapi.Response{
+		Description: "Not Modified",
+		Headers:     map[string]openapi.Header{"ETag": etag},
+	}
+	return responses
+}
+
+// formatParam documents the format of endpoints with CSV, NDJSON and XLSX
+// exports
+var formatParam = queryParam("format", &openapi.Schema{
+	Type:        "string",
+	Enum:        []string{string(formatJSON), string(formatCSV), string(formatNDJSON), string(formatXLSX)},
+	Description: "Response format (default json); text/csv, application/x-ndjson or the XLSX media type in Accept also select an export",
+})
+
+// withExports adds the CSV, NDJSON and XLSX exports to the 200 response.
+// Their rows are the data array's fields.
+func withExports(responses map[string]openapi.Response) map[string]openapi.Response {
+	ok := responses["200"]
+	ok.Content["text/csv"] = openapi.MediaType{Schema: openapi.String("Header row, then one row per result")}
+	ok.Content[ndjsonContentType] = openapi.MediaType{Schema: openapi.String("One JSON object per line and result")}
+	ok.Content[xlsxContentType] = openapi.MediaType{Schema: &openapi.Schema{Type: "string", Format: "binary"}}
+	responses["200"] = ok
+	return responses
+}
+
+// withErrors documents the standard error body for each status
+func withErrors(responses map[string]openapi.Response, statuses ...int) map[string]openapi.Response {
+	for _, status := range statuses {
+		responses[strconv.Itoa(status)] = jsonResponse(http.StatusText(status), openapi.Ref("Error"))
+	}
+	return responses
+}
+
+// queryParam documents an optional query parameter
+func queryParam(name string, schema *openapi.Schema) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Schema: schema}
+}
+
+// pathParam documents a path parameter
+func pathParam(name string, schema *openapi.Schema) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "path", Required: true, Schema: schema}
+}
@@ -0,0 +1,366 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/audit"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/response"
+)
+
+const (
+	defaultOrdersLimit = 50
+	maxOrdersLimit     = 500
+
+	defaultTopCustomersLimit = 5
+	maxTopCustomersLimit     = 100
+
+	defaultCustomerMonths = 12
+	maxCustomerMonths     = 60
+
+	defaultSummaryPeriod = "30d"
+	customSummaryPeriod  = "custom"
+)
+
+// presetPeriods maps order status summary periods to their length in
+// calendar days, including today
+var presetPeriods = map[string]int{
+	"7d":  7,
+	"30d": 30,
+	"90d": 90,
+}
+
+// reportPeriod is the time range an order status summary covers, echoed in
+// the response so dashboards can label it
+type reportPeriod struct {
+	Name    string    `json:"name"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	GroupBy string    `json:"group_by,omitempty"`
+
+	openEnded bool // a custom period without to, which runs up to now
+}
+
+// createOrderRequest is the body of POST /api/v1/orders
+type createOrderRequest struct {
+	CustomerID string     `json:"customer_id" validate:"required,max=36"`
+	Amount     float64    `json:"amount" validate:"gt=0"`
+	Status     string     `json:"status" validate:"omitempty,oneof=PENDING PAID CANCELLED"`
+	CreatedAt  *time.Time `json:"created_at"`
+}
+
+// normalize trims the customer ID and upper-cases the status
+func (r *createOrderRequest) normalize() {
+	r.CustomerID = strings.TrimSpace(r.CustomerID)
+	r.Status = strings.ToUpper(r.Status)
+}
+
+// listOrders handles GET /api/v1/orders
+func (h *Handler) listOrders(c Context) {
+	ctx := c.Request().Context()
+
+	filter, err := parseOrderFilter(c)
+	if err != nil {
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", err))
+		return
+	}
+
+	orders, err := h.db.GetOrders(ctx, filter)
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to get orders")
+		abortWithError(c, apierror.Internal("failed to retrieve orders", err))
+		return
+	}
+
+	respond(c, http.StatusOK, reply{Data: orders, Meta: response.Meta{Pagination: page(len(orders), filter.Limit, filter.Offset)}})
+}
+
+// getOrder handles GET /api/v1/orders/:id
+func (h *Handler) getOrder(c Context) {
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid order id", errors.New("id must be a positive integer")))
+		return
+	}
+
+	order, err := h.db.GetOrderByID(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		abortWithError(c, apierror.NotFound(apierror.CodeOrderNotFound, "order not found", fmt.Errorf("no order with id %d", id)))
+		return
+	}
+	if err != nil {
+		h.log(c).WithError(err).WithField("id", id).Error("Failed to get order")
+		abortWithError(c, apierror.Internal("failed to retrieve order", err))
+		return
+	}
+
+	respond(c, http.StatusOK, reply{Data: order})
+}
+
+// createOrder handles POST /api/v1/orders
+func (h *Handler) createOrder(c Context) {
+	ctx := c.Request().Context()
+
+	var req createOrderRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	order := req.toOrder()
+	if err := h.db.CreateOrder(ctx, order); err != nil {
+		h.log(c).WithError(err).Error("Failed to create order")
+		abortWithError(c, apierror.Internal("failed to create order", err))
+		return
+	}
+
+	// Keep the analytics aggregates current; reconcile corrects any miss
+	if err := h.analytics.RecordOrder(ctx, order); err != nil {
+		h.log(c).WithError(err).WithField("id", order.ID).Warn("Failed to record order in analytics")
+	}
+	h.invalidateAnalytics(c)
+
+	h.log(c).WithField("id", order.ID).Info("Order created")
+	h.audit(c, audit.ActionOrderCreate, "order:"+strconv.FormatInt(order.ID, 10), nil, order)
+	respond(c, http.StatusCreated, reply{Data: order})
+}
+
+// toOrder converts a validated request to an order, PENDING unless the
+// request says otherwise
+func (r *createOrderRequest) toOrder() *database.Order {
+	order := &database.Order{
+		CustomerID: r.CustomerID,
+		Amount:     r.Amount,
+		Status:     r.Status,
+	}
+	if order.Status == "" {
+		order.Status = "PENDING"
+	}
+	if r.CreatedAt != nil {
+		order.CreatedAt = *r.CreatedAt
+	}
+	return order
+}
+
+// parseOrderFilter builds an order filter from the request query parameters
+func parseOrderFilter(c Context) (database.OrderFilter, error) {
+	var from, to *time.Time
+	if v := c.Query("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return database.OrderFilter{}, fmt.Errorf("from: %w", err)
+		}
+		from = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseEndTimeParam(v)
+		if err != nil {
+			return database.OrderFilter{}, fmt.Errorf("to: %w", err)
+		}
+		to = &t
+	}
+
+	limit := 0
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return database.OrderFilter{}, fmt.Errorf("limit must be between 1 and %d", maxOrdersLimit)
+		}
+		limit = n
+	}
+
+	offset := 0
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return database.OrderFilter{}, fmt.Errorf("offset must be a non-negative integer")
+		}
+		offset = n
+	}
+
+	return newOrderFilter(c.Query("status"), c.Query("customer_id"), from, to, limit, offset)
+}
+
+// newOrderFilter validates an order listing query. status is matched
+// case-insensitively, and a zero limit means the default.
+func newOrderFilter(status, customerID string, from, to *time.Time, limit, offset int) (database.OrderFilter, error) {
+	filter := database.OrderFilter{
+		CustomerID:  customerID,
+		CreatedFrom: from,
+		CreatedTo:   to,
+		Limit:       defaultOrdersLimit,
+	}
+
+	if status != "" {
+		status = strings.ToUpper(status)
+		if !database.OrderStatuses[status] {
+			return filter, fmt.Errorf("status must be one of PENDING, PAID, CANCELLED")
+		}
+		filter.Status = status
+	}
+
+	if limit != 0 {
+		if limit < 0 || limit > maxOrdersLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxOrdersLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if offset < 0 {
+		return filter, fmt.Errorf("offset must be a non-negative integer")
+	}
+	filter.Offset = offset
+
+	return filter, nil
+}
+
+// parseTopCustomersFilter builds a top customers filter from request query
+// parameters
+func parseTopCustomersFilter(query url.Values) (database.TopCustomersFilter, error) {
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return database.TopCustomersFilter{}, fmt.Errorf("limit must be between 1 and %d", maxTopCustomersLimit)
+		}
+		limit = n
+	}
+
+	var from, to *time.Time
+	if v := query.Get("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return database.TopCustomersFilter{}, fmt.Errorf("from: %w", err)
+		}
+		from = &t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := parseEndTimeParam(v)
+		if err != nil {
+			return database.TopCustomersFilter{}, fmt.Errorf("to: %w", err)
+		}
+		to = &t
+	}
+
+	return newTopCustomersFilter(limit, from, to)
+}
+
+// newTopCustomersFilter validates a top customers query. A zero limit
+// means the default.
+func newTopCustomersFilter(limit int, from, to *time.Time) (database.TopCustomersFilter, error) {
+	filter := database.TopCustomersFilter{Limit: defaultTopCustomersLimit, CreatedFrom: from, CreatedTo: to}
+
+	if limit != 0 {
+		if limit < 0 || limit > maxTopCustomersLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxTopCustomersLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if from != nil && to != nil && to.Before(*from) {
+		return filter, fmt.Errorf("to must not be before from")
+	}
+
+	return filter, nil
+}
+
+// parseOrderStatusFilter builds an order status filter from request query
+// parameters
+func parseOrderStatusFilter(query url.Values, now time.Time) (reportPeriod, database.OrderStatusFilter, error) {
+	var from, to *time.Time
+	if v := query.Get("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return reportPeriod{}, database.OrderStatusFilter{}, fmt.Errorf("from: %w", err)
+		}
+		from = &t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := parseEndTimeParam(v)
+		if err != nil {
+			return reportPeriod{}, database.OrderStatusFilter{}, fmt.Errorf("to: %w", err)
+		}
+		to = &t
+	}
+
+	return newOrderStatusFilter(query.Get("period"), from, to, query.Get("group_by"), now)
+}
+
+// newOrderStatusFilter validates an order status summary query. period is
+// one of presetPeriods or custom; from/to are only accepted with custom,
+// which is implied when period is empty.
+func newOrderStatusFilter(name string, from, to *time.Time, groupBy string, now time.Time) (reportPeriod, database.OrderStatusFilter, error) {
+	var filter database.OrderStatusFilter
+
+	if name == "" {
+		name = defaultSummaryPeriod
+		if from != nil || to != nil {
+			name = customSummaryPeriod
+		}
+	}
+	period := reportPeriod{Name: name, To: now}
+
+	if days, ok := presetPeriods[name]; ok {
+		if from != nil || to != nil {
+			return period, filter, fmt.Errorf("from and to require period=custom")
+		}
+		// Preset periods are UTC calendar days, like the analytics buckets
+		year, month, day := now.UTC().Date()
+		period.From = time.Date(year, month, day-(days-1), 0, 0, 0, 0, time.UTC)
+	} else if name == customSummaryPeriod {
+		if from == nil {
+			return period, filter, fmt.Errorf("from is required for period=custom")
+		}
+		period.From = *from
+		if to != nil {
+			period.To = *to
+		} else {
+			period.openEnded = true
+		}
+		if period.To.Before(period.From) {
+			return period, filter, fmt.Errorf("to must not be before from")
+		}
+	} else {
+		return period, filter, fmt.Errorf("period must be one of 7d, 30d, 90d, custom")
+	}
+
+	switch groupBy {
+	case "", database.GroupByDay, database.GroupByWeek:
+		period.GroupBy = groupBy
+	default:
+		return period, filter, fmt.Errorf("group_by must be day or week")
+	}
+
+	start, end := period.From, period.To
+	filter.CreatedFrom = &start
+	filter.CreatedTo = &end
+	filter.GroupBy = period.GroupBy
+	return period, filter, nil
+}
+
+// parseCustomerDetailParams reads the customer ID and the months of spend
+// to return, counting the current one, as the start of the first month
+func parseCustomerDetailParams(c Context, now time.Time) (string, time.Time, error) {
+	customerID := strings.TrimSpace(c.Param("id"))
+	if customerID == "" || len(customerID) > 36 {
+		return "", time.Time{}, errors.New("customer id must be 1 to 36 characters")
+	}
+
+	months := defaultCustomerMonths
+	if v := c.Query("months"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxCustomerMonths {
+			return "", time.Time{}, fmt.Errorf("months must be between 1 and %d", maxCustomerMonths)
+		}
+		months = n
+	}
+	return customerID, time.Date(now.Year(), now.Month()-time.Month(months-1), 1, 0, 0, 0, 0, now.Location()), nil
+}
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sort"
+	"strings"
+	"time"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/client"
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/signing"
+)
+
+// proxyRetryBaseDelay is the wait before the first proxy retry, doubled
+// per attempt
+const proxyRetryBaseDelay = 100 * time.Millisecond
+
+// proxyMethods are the methods /proxy routes accept. OPTIONS is answered
+// by the CORS middleware before it reaches the proxy.
+var proxyMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// gatewayCredentialHeaders authenticate callers to the gateway itself and
+// are never forwarded upstream, whatever a route's header rewrites say
+var gatewayCredentialHeaders = []string{
+	apiKeyHeader,
+	"Authorization",
+	"X-Admin-Token",
+	signing.ClientHeader,
+	signing.TimestampHeader,
+	signing.SignatureHeader,
+	idempotencyKeyHeader,
+}
+
+// proxyErrorKey is the request context key the ReverseProxy error handler
+// reports transport failures under, so they are rendered like any other
+// API error
+type proxyErrorKey struct{}
+
+// proxyRoute forwards requests under one path prefix to a target service
+type proxyRoute struct {
+	name     string
+	segments []string // prefix below /proxy
+	timeout  time.Duration
+	proxy    *httputil.ReverseProxy
+}
+
+// newProxyRoutes builds the configured proxy routes, longest prefix first
+// so the most specific route wins
+func newProxyRoutes(routes []config.ProxyRoute) ([]*proxyRoute, error) {
+	built := make([]*proxyRoute, 0, len(routes))
+	for _, cfg := range routes {
+		balancer, err := client.NewBalancer(cfg.Name, cfg.Targets, cfg.Balancer)
+		if err != nil {
+			return nil, fmt.Errorf("proxy route %q: %w", cfg.Name, err)
+		}
+
+		var transport http.RoundTripper = &client.BalancedTransport{
+			Balancer: balancer,
+			Next:     http.DefaultTransport.(*http.Transport).Clone(),
+		}
+		if limiter := client.NewLimiter(cfg.Name, cfg.RateLimit); limiter != nil {
+			transport = &client.ThrottledTransport{Limiter: limiter, Next: transport}
+		}
+		built = append(built, &proxyRoute{
+			name:     cfg.Name,
+			segments: splitPath(cfg.Prefix),
+			timeout:  time.Duration(cfg.Timeout) * time.Second,
+			proxy: &httputil.ReverseProxy{
+				Rewrite:      rewriteProxyRequest(cfg),
+				Transport:    &retryTransport{next: transport, maxRetries: cfg.MaxRetries},
+				ErrorHandler: reportProxyError,
+			},
+		})
+	}
+
+	sort.SliceStable(built, func(i, j int) bool {
+		return len(built[i].segments) > len(built[j].segments)
+	})
+	return built, nil
+}
+
+// rewriteProxyRequest strips the gateway's credentials and applies the
+// route's header rewrites, which may set credentials of the upstream's own.
+// The target is chosen per attempt by the balanced transport, so the
+// outgoing Host is left to it. Inbound X-Forwarded-* headers are replaced
+// rather than trusted.
+func rewriteProxyRequest(cfg config.ProxyRoute) func(*httputil.ProxyRequest) {
+	return func(pr *httputil.ProxyRequest) {
+		pr.Out.Host = ""
+		pr.SetXForwarded()
+
+		if id := logger.RequestIDFromContext(pr.In.Context()); id != "" {
+			pr.Out.Header.Set("X-Request-ID", id)
+		}
+		for _, name := range gatewayCredentialHeaders {
+			pr.Out.Header.Del(name)
+		}
+		for _, name := range cfg.RemoveHeaders {
+			pr.Out.Header.Del(name)
+		}
+		for name, value := range cfg.SetHeaders {
+			pr.Out.Header.Set(name, value)
+		}
+	}
+}
+
+// reportProxyError hands a failed round trip back to the proxy handler
+func reportProxyError(w http.ResponseWriter, r *http.Request, err error) {
+	if report, ok := r.Context().Value(proxyErrorKey{}).(*error); ok {
+		*report = err
+	}
+}
+
+// matchProxyRoute finds the route for path, the part of the request path
+// below /proxy, and returns it with the path left after its prefix
+func matchProxyRoute(routes []*proxyRoute, path string) (*proxyRoute, string) {
+	segments := splitPath(path)
+	for _, route := range routes {
+		if len(segments) < len(route.segments) {
+			continue
+		}
+		matched := true
+		for i, s := range route.segments {
+			if segments[i] != s {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		rest := "/" + strings.Join(segments[len(route.segments):], "/")
+		if strings.HasSuffix(path, "/") && rest != "/" {
+			rest += "/"
+		}
+		return route, rest
+	}
+	return nil, ""
+}
+
+// proxy handles /proxy/*path by forwarding the request, minus the route
+// prefix, to the route with the longest matching prefix
+func (h *Handler) proxy(c Context) {
+	path := c.Param("path")
+	route, rest := matchProxyRoute(h.proxyRoutes, path)
+	if route == nil {
+		abortWithError(c, apierror.NotFound(apierror.CodeRouteNotFound, "not found", fmt.Errorf("no proxy route for %s", path)))
+		return
+	}
+
+	ctx := c.Request().Context()
+	if route.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, route.timeout)
+		defer cancel()
+	}
+	var proxyErr error
+	ctx = context.WithValue(ctx, proxyErrorKey{}, &proxyErr)
+
+	req := c.Request().Clone(ctx)
+	req.URL.Path = rest
+	req.URL.RawPath = ""
+
+	route.proxy.ServeHTTP(c.Writer(), req)
+	if proxyErr == nil {
+		return
+	}
+
+	log := h.log(c).WithError(proxyErr).WithField("proxy_route", route.name)
+	switch {
+	case errors.Is(proxyErr, context.DeadlineExceeded):
+		log.Warn("Proxied request timed out")
+		abortWithError(c, apierror.New(http.StatusGatewayTimeout, apierror.CodeUpstreamTimeout, "upstream timed out").Wrap(proxyErr))
+	case errors.Is(proxyErr, context.Canceled):
+		c.Abort()
+	case errors.Is(proxyErr, client.ErrThrottled):
+		log.Warn("Proxied request held back by the route's rate limit")
+		abortWithError(c, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited, "upstream rate limit reached").Wrap(proxyErr))
+	default:
+		log.Warn("Proxied request failed")
+		abortWithError(c, apierror.New(http.StatusBadGateway, apierror.CodeUpstreamError, "upstream request failed").Wrap(proxyErr))
+	}
+}
+
+// retryTransport retries idempotent requests without a body when the
+// target is unreachable or answers 502, 503 or 504. Each retry is balanced
+// again, so it can land on a different backend.
+type retryTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.maxRetries <= 0 || !replayable(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.maxRetries || !retryableProxyResult(req.Context(), resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			// Drain the body so the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(proxyRetryBaseDelay << uint(attempt)):
+		}
+	}
+}
+
+// replayable reports whether req can safely be sent again
+func replayable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return req.Body == nil || req.Body == http.NoBody
+	}
+	return false
+}
+
+// retryableProxyResult reports whether a round trip failed in a way worth
+// retrying
+func retryableProxyResult(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, client.ErrThrottled)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
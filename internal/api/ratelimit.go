@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/health"
+)
+
+const (
+	// rateLimitKeyPrefix namespaces per-client token buckets in Redis
+	rateLimitKeyPrefix = "ratelimit:"
+
+	// authFailureKeyPrefix namespaces the per-IP buckets of failed
+	// authentication attempts
+	authFailureKeyPrefix = rateLimitKeyPrefix + "authfail:ip:"
+)
+
+// rateLimit applies a Redis-backed token bucket per client, keyed by API key
+// when the request is authenticated with one and by client IP otherwise.
+// Redis failures fail open so a cache outage doesn't take the API down. The
+// limits are read per request so a config reload can change them.
+func (h *Handler) rateLimit() HandlerFunc {
+	return func(c Context) {
+		cfg, rate, burst, ok := h.rateLimits()
+		if !ok {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), time.Second)
+		defer cancel()
+
+		result, err := h.redis.AllowRate(ctx, rateLimitKeyPrefix+rateLimitClient(c), rate, burst)
+		if err != nil {
+			h.log(c).WithError(err).Warn("Rate limit check failed, allowing request")
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			abortWithError(c, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited, "rate limit exceeded").Wrap(fmt.Errorf("limit of %d requests per minute exceeded", cfg.RequestsPerMinute)))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// limitAuthFailures throttles failed authentication per client IP, so API
+// keys and tokens can't be guessed faster than the rate limit allows. It
+// runs in front of authenticate: each 401 takes a token from the IP's
+// bucket, and once the bucket is empty the IP's requests are refused
+// before their credentials are checked. Requests that authenticate don't
+// count against it, and Redis failures fail open as for rateLimit.
+func (h *Handler) limitAuthFailures() HandlerFunc {
+	return func(c Context) {
+		cfg, rate, burst, ok := h.rateLimits()
+		if !ok {
+			c.Next()
+			return
+		}
+		key := authFailureKeyPrefix + c.ClientIP()
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), time.Second)
+		result, err := h.redis.PeekRate(ctx, key, rate, burst)
+		cancel()
+		if err != nil {
+			h.log(c).WithError(err).Warn("Authentication failure limit check failed, allowing request")
+			c.Next()
+			return
+		}
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			abortWithError(c, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited, "too many failed authentication attempts").Wrap(fmt.Errorf("limit of %d failed authentications per minute exceeded", cfg.RequestsPerMinute)))
+			return
+		}
+
+		c.Next()
+
+		value, _ := c.Get(apiErrorKey)
+		if failure, ok := value.(*apierror.Error); !ok || failure.Status != http.StatusUnauthorized {
+			return
+		}
+		// The request may be over, but its failure still counts
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(c.Request().Context()), time.Second)
+		defer cancel()
+		if _, err := h.redis.AllowRate(ctx, key, rate, burst); err != nil {
+			h.log(c).WithError(err).Warn("Failed to count authentication failure")
+		}
+	}
+}
+
+// rateLimits returns the live rate limit settings as a token bucket's
+// refill rate per second and capacity. It reports false when rate limiting
+// is off, or when Redis is known to be down, so requests fail open at once
+// rather than wait on it.
+func (h *Handler) rateLimits() (cfg config.RateLimitConfig, rate float64, burst int, ok bool) {
+	cfg = h.live.Load().rateLimit
+	if !cfg.Enabled || cfg.RequestsPerMinute <= 0 || h.deps.Down(health.Redis) {
+		return cfg, 0, 0, false
+	}
+
+	burst = cfg.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	return cfg, float64(cfg.RequestsPerMinute) / 60, burst, true
+}
+
+// rateLimitClient identifies the client a request is counted against
+func rateLimitClient(c Context) string {
+	if key, ok := ClientAPIKey(c); ok {
+		return "key:" + strconv.FormatInt(key.ID, 10)
+	}
+	if client, ok := SigningClient(c); ok {
+		return "client:" + client.ID
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/audit"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/logger"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// adminTokenKey is the context key set when a request authenticated with
+// the admin token, which holds every permission
+const adminTokenKey = "admin_token"
+
+// principalKey is the context.Context key of the principal a request acts
+// as, for permission checks made below the HTTP layer such as in GraphQL
+// resolvers
+type principalKey struct{}
+
+// principal is who a request acts as and the roles they hold
+type principal struct {
+	actor string
+	roles []string
+	admin bool
+}
+
+// principalOf returns who the request acts as: the admin, or an API key,
+// signing client or JWT subject with its roles
+func (h *Handler) principalOf(c Context) principal {
+	p := principal{actor: actor(c)}
+	if _, ok := c.Get(adminTokenKey); ok {
+		p.admin = true
+	} else if key, ok := ClientAPIKey(c); ok {
+		p.roles = key.Roles
+	} else if client, ok := SigningClient(c); ok {
+		p.roles = client.Roles
+	} else if claims, ok := Claims(c); ok {
+		p.roles = claimRoles(claims, h.cfg.RBAC.JWTClaim)
+	}
+	return p
+}
+
+// claimRoles reads a JWT's roles from claim, either a list of strings or a
+// single space or comma separated string
+func claimRoles(claims jwt.MapClaims, claim string) []string {
+	switch v := claims[claim].(type) {
+	case string:
+		return strings.FieldsFunc(v, func(r rune) bool { return r == ' ' || r == ',' })
+	case []interface{}:
+		roles := make([]string, 0, len(v))
+		for _, role := range v {
+			if s, ok := role.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	}
+	return nil
+}
+
+// withPrincipal attaches p to ctx
+func withPrincipal(ctx context.Context, p principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// principalFrom returns the principal attached to ctx, an anonymous one if
+// none is
+func principalFrom(ctx context.Context) principal {
+	p, ok := ctx.Value(principalKey{}).(principal)
+	if !ok {
+		return principal{actor: "anonymous"}
+	}
+	return p
+}
+
+// requireShared guards routes managing data every tenant shares, such as
+// webhook subscriptions, which receive every tenant's item changes. With
+// tenancy enabled only the admin token may use them; otherwise they
+// require perm.
+func (h *Handler) requireShared(perm string) HandlerFunc {
+	require := h.require(perm)
+	return func(c Context) {
+		if _, ok := c.Get(adminTokenKey); !ok && h.cfg.Tenancy.Enabled {
+			abortWithError(c, errForbidden.Wrap(errors.New("only the admin token may manage data shared by every tenant")))
+			return
+		}
+		require(c)
+	}
+}
+
+// require refuses requests whose roles don't grant perm; see authorize
+func (h *Handler) require(perm string) HandlerFunc {
+	return func(c Context) {
+		r := c.Request()
+		if err := h.authorize(r.Context(), h.principalOf(c), perm, r.Method+" "+r.URL.Path); err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorize returns nil if p holds perm, through its roles or the default
+// roles if it has none, or if RBAC is disabled. Otherwise the denial is
+// logged and recorded in the audit log against target, and returned as
+// 403 FORBIDDEN.
+func (h *Handler) authorize(ctx context.Context, p principal, perm, target string) *apierror.Error {
+	cfg := h.cfg.RBAC
+	if !cfg.Enabled || p.admin {
+		return nil
+	}
+	roles := p.roles
+	if len(roles) == 0 {
+		roles = cfg.DefaultRoles
+	}
+	if cfg.Roles.Allows(roles, perm) {
+		return nil
+	}
+
+	h.logger.FromContext(ctx).WithFields(map[string]interface{}{
+		"actor":      p.actor,
+		"roles":      roles,
+		"permission": perm,
+		"target":     target,
+	}).Warn("Permission denied")
+	h.auditLog.Record(ctx, &database.AuditEvent{
+		Action:    audit.ActionAccessDenied,
+		Actor:     p.actor,
+		RequestID: logger.RequestIDFromContext(ctx),
+		Target:    target,
+		Changes:   database.Changes{"permission": {To: perm}},
+	})
+	return errForbidden.Wrap(fmt.Errorf("missing permission %s", perm))
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"api-gateway-backend/internal/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// requestIDHeader carries the request ID in requests and responses
+	requestIDHeader = "X-Request-ID"
+
+	// requestIDKey and loggerKey are context keys for the request ID and
+	// the request-scoped log entry
+	requestIDKey = "request_id"
+	loggerKey    = "logger"
+
+	// maxRequestIDLength bounds honored incoming request IDs
+	maxRequestIDLength = 128
+)
+
+// requestID honors an incoming X-Request-ID (or generates one), echoes it in
+// the response, and attaches it to the request context and a request-scoped
+// log entry so every log line for the request can be correlated
+func (h *Handler) requestID() HandlerFunc {
+	return func(c Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = logger.NewRequestID()
+		}
+
+		c.Header(requestIDHeader, id)
+		c.SetRequest(c.Request().WithContext(logger.ContextWithRequestID(c.Request().Context(), id)))
+		c.Set(requestIDKey, id)
+		c.Set(loggerKey, h.logger.WithField("request_id", id))
+
+		c.Next()
+	}
+}
+
+// log returns the request-scoped log entry
+func (h *Handler) log(c Context) *logrus.Entry {
+	return requestLog(c, h.logger)
+}
+
+// requestLog returns the request-scoped log entry, falling back to the
+// request context when the request ID middleware hasn't run
+func requestLog(c Context, fallback *logger.Logger) *logrus.Entry {
+	if value, ok := c.Get(loggerKey); ok {
+		if entry, ok := value.(*logrus.Entry); ok {
+			return entry
+		}
+	}
+	return fallback.FromContext(c.Request().Context())
+}
+
+// RequestID returns the ID of the current request
+func RequestID(c Context) string {
+	value, _ := c.Get(requestIDKey)
+	id, _ := value.(string)
+	return id
+}
+
+// validRequestID accepts non-empty, bounded, printable ASCII IDs so client
+// input can't inject into logs or headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
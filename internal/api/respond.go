@@ -0,0 +1,113 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/response"
+)
+
+const (
+	// apiVersionHeader selects the response format of a request
+	apiVersionHeader = "Accept-Version"
+
+	// apiVersionKey is the context key the selected version is stored under
+	apiVersionKey = "api_version"
+)
+
+// API versions clients select with Accept-Version. Version 1 bodies are
+// the ones each endpoint has always returned; version 2 wraps every JSON
+// response in the response.Envelope.
+const (
+	apiV1 = 1
+	apiV2 = 2
+)
+
+var errUnsupportedVersion = apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest, "unsupported API version")
+
+// apiVersion reads the version a request asks for from Accept-Version,
+// defaulting to 1, and rejects versions that don't exist
+func apiVersion() HandlerFunc {
+	return func(c Context) {
+		c.Writer().Header().Add("Vary", apiVersionHeader)
+
+		switch v := strings.TrimPrefix(strings.TrimSpace(c.GetHeader(apiVersionHeader)), "v"); v {
+		case "", "1":
+			c.Set(apiVersionKey, apiV1)
+		case "2":
+			c.Set(apiVersionKey, apiV2)
+		default:
+			abortWithError(c, errUnsupportedVersion.WithDetails(H{"supported": []string{"1", "2"}}))
+			return
+		}
+		c.Next()
+	}
+}
+
+// requestedVersion returns the API version the current request selected
+func requestedVersion(c Context) int {
+	value, _ := c.Get(apiVersionKey)
+	if v, ok := value.(int); ok {
+		return v
+	}
+	return apiV1
+}
+
+// reply is a successful JSON response, described once for both versions
+type reply struct {
+	Data interface{}
+	Meta response.Meta
+	// V1 holds fields version 1 bodies carry besides data, timestamp,
+	// pagination, filters and message, replacing any of those by name
+	V1 H
+	// Flat marks a version 1 body with the fields of Data, an H, at the
+	// top level rather than under "data"
+	Flat bool
+}
+
+// respond writes r with status in the version the request asked for
+func respond(c Context, status int, r reply) {
+	envelope := response.OK(r.Data, r.Meta)
+	if requestedVersion(c) == apiV2 {
+		envelope.Meta.RequestID = RequestID(c)
+		c.JSON(status, envelope)
+		return
+	}
+	c.JSON(status, r.v1Body(envelope.Meta))
+}
+
+// v1Body returns the version 1 body of r: data, timestamp, pagination
+// counts, filters and message at the top level, then r.V1
+func (r reply) v1Body(meta response.Meta) H {
+	body := H{"timestamp": meta.Timestamp}
+	if fields, ok := r.Data.(H); ok && r.Flat {
+		for name, value := range fields {
+			body[name] = value
+		}
+	} else if r.Data != nil {
+		body["data"] = r.Data
+	}
+	if p := meta.Pagination; p != nil {
+		body["count"] = p.Count
+		if p.Limit > 0 {
+			body["limit"] = p.Limit
+			body["offset"] = p.Offset
+		}
+	}
+	for name, value := range meta.Filters {
+		body[name] = value
+	}
+	if meta.Message != "" {
+		body["message"] = meta.Message
+	}
+	for name, value := range r.V1 {
+		body[name] = value
+	}
+	return body
+}
+
+// page describes a page of count results from limit and offset
+func page(count, limit, offset int) *response.Pagination {
+	return &response.Pagination{Count: count, Limit: limit, Offset: offset}
+}
@@ -2,210 +2,558 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"api-gateway-backend/internal/analytics"
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/audit"
+	"api-gateway-backend/internal/cache"
 	"api-gateway-backend/internal/config"
 	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/events"
+	"api-gateway-backend/internal/health"
 	"api-gateway-backend/internal/jobs"
 	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/rbac"
 	"api-gateway-backend/internal/redis"
+	"api-gateway-backend/internal/reporting"
+	"api-gateway-backend/internal/response"
+	"api-gateway-backend/internal/webhooks"
 
-	"github.com/gin-gonic/gin"
+	"github.com/graph-gophers/graphql-go"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
-	itemsCacheKey = "items:all"
-	itemsCacheTTL = 5 * time.Minute
+	itemsCacheNamespace = "items"
+	itemsCacheKey       = "items:all"
+	itemsCacheTTL       = 5 * time.Minute
+
+	defaultSyncHistoryLimit = 20
+	maxSyncHistoryLimit     = 100
 )
 
+// H is a shortcut for JSON response bodies
+type H map[string]interface{}
+
+// JobRunner is the part of the background job manager used by the API.
+// main passes its own jobs.Manager so the process runs a single scheduler.
+type JobRunner interface {
+	SyncDataManual(ctx context.Context) error
+	StartSync(ctx context.Context) (*jobs.SyncJob, error)
+	GetSyncJob(ctx context.Context, id string) (*jobs.SyncJob, error)
+	LastSuccessfulSync(ctx context.Context) (time.Time, error)
+	Leader(ctx context.Context) (*jobs.LeaderStatus, error)
+	Jobs(ctx context.Context) ([]jobs.JobInfo, error)
+	RunJob(name string) error
+	PauseJob(ctx context.Context, name string) error
+	ResumeJob(ctx context.Context, name string) error
+	RequeueFailedItem(ctx context.Context, id int64) (*database.FailedItem, error)
+}
+
+// analyticsStore records and serves precomputed order analytics
+type analyticsStore interface {
+	RecordOrder(ctx context.Context, order *database.Order) error
+	OrderStatusSummary(ctx context.Context, days int) ([]database.OrderStatusSummary, error)
+	TopCustomers(ctx context.Context, limit int) ([]database.TopCustomer, error)
+}
+
 // Handler contains dependencies for API handlers
 type Handler struct {
-	db        *database.DB
-	redis     *redis.Client
-	jobManager *jobs.Manager
-	logger    *logger.Logger
-}
+	db         database.Store
+	redis      redis.CacheClient
+	jobManager JobRunner
+	analytics  analyticsStore
+	cache      cache.Cache
+	versions   *cache.Versions
+	logger     *logger.Logger
+	reporter   reporting.Reporter
+	auditLog   *audit.Recorder
+	cfg        *config.Config
+	jwtAuth    *jwtAuthenticator
+	webhooks   webhookPublisher // nil disables outbound webhooks
+	hub        *events.Hub      // nil disables /ws live updates
+	liveConns  chan struct{}    // open /ws connections, nil for no limit
 
-// NewRouter creates a new Gin router with all routes
-func NewRouter(db *database.DB, rdb *redis.Client, apiCfg config.ExternalAPIConfig, log *logger.Logger) *gin.Engine {
-	router := gin.New()
+	graphqlSchema *graphql.Schema // nil when /graphql is disabled
 
-	// Middleware
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
-	router.Use(corsMiddleware())
+	proxyRoutes []*proxyRoute
+	warmTargets []warmTarget // nil disables cache warming
+
+	// The last readiness checks, shared by probes until they expire
+	readyReport atomic.Pointer[readinessReport]
+	readyChecks singleflight.Group
+	deps        *health.Tracker // recent check outcomes of each dependency
+
+	live atomic.Pointer[liveSettings] // settings a config reload can change
+}
 
-	// Initialize handler
-	jobManager := jobs.New(db, rdb, apiCfg, log)
+// liveSettings are the settings read per request rather than from cfg, so
+// ApplyConfig can change them while serving
+type liveSettings struct {
+	rateLimit       config.RateLimitConfig
+	debugLog        config.DebugLogConfig
+	orderStatusTTL  time.Duration
+	topCustomersTTL time.Duration
+	itemStatsTTL    time.Duration
+	negativeTTL     time.Duration
+	ipFilter        *ipFilter
+}
+
+// NewHandler creates a new API handler that triggers syncs through
+// jobManager, publishes item changes through notifier, streams them to
+// live clients from hub and reports panics and server errors to reporter.
+// notifier, hub and reporter may be nil.
+func NewHandler(db database.Store, rdb *redis.Client, jobManager JobRunner, notifier *webhooks.Notifier, hub *events.Hub, reporter reporting.Reporter, cfg *config.Config, log *logger.Logger) (*Handler, error) {
+	if reporter == nil {
+		reporter = reporting.Nop{}
+	}
 	h := &Handler{
 		db:         db,
 		redis:      rdb,
 		jobManager: jobManager,
+		hub:        hub,
+		analytics:  analytics.New(db, rdb),
 		logger:     log,
+		reporter:   reporter,
+		auditLog:   audit.NewRecorder(db, log),
+		cfg:        cfg,
+		deps:       health.NewTracker(cfg.Health.HistorySize, cfg.Health.DownAfter),
+	}
+	h.ApplyConfig(cfg)
+	if cfg.WebSocket.MaxConnections > 0 {
+		h.liveConns = make(chan struct{}, cfg.WebSocket.MaxConnections)
+	}
+
+	if notifier != nil {
+		h.webhooks = notifier
+	}
+
+	// Skip Redis while the health checks know it's down, and keep a local
+	// copy of cached values to serve from meanwhile
+	redisDown := func() bool { return h.deps.Down(health.Redis) }
+	h.cache = cache.NewGuarded(cache.NewRedis(rdb, log).WithJitter(cfg.Cache.TTLJitter), redisDown, log)
+	h.versions = cache.NewVersions(rdb, log).WithGuard(redisDown)
+	if cfg.Cache.LocalSize > 0 {
+		h.cache = cache.NewFallback(h.cache, cache.NewLRU(cfg.Cache.LocalSize, log), log)
 	}
 
-	// Health check
-	router.GET("/health", h.healthCheck)
+	if cfg.Auth.JWTEnabled {
+		auth, err := newJWTAuthenticator(cfg.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure JWT auth: %w", err)
+		}
+		h.jwtAuth = auth
+	}
+	if cfg.Auth.OIDCEnabled {
+		h.jwtAuth = newOIDCAuthenticator(cfg.Auth)
+	}
+
+	if cfg.GraphQL.Enabled {
+		schema, err := newGraphQLSchema(h)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse GraphQL schema: %w", err)
+		}
+		h.graphqlSchema = schema
+	}
+
+	proxyRoutes, err := newProxyRoutes(cfg.Proxy.Routes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure proxy routes: %w", err)
+	}
+	h.proxyRoutes = proxyRoutes
+
+	if cfg.Cache.WarmEnabled {
+		warmTargets, err := newWarmTargets(cfg.Cache.WarmTargets)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure cache warming: %w", err)
+		}
+		h.warmTargets = warmTargets
+	}
+
+	return h, nil
+}
+
+// ApplyConfig applies the rate limit, debug logging, cache TTLs and IP
+// allow and deny lists of a reloaded configuration to subsequent requests
+func (h *Handler) ApplyConfig(cfg *config.Config) {
+	filter, err := newIPFilter(cfg.IPFilter)
+	if err != nil {
+		// Validate rejects such a config before it gets here
+		h.logger.WithError(err).Error("Invalid IP filter, keeping the current one")
+		if prev := h.live.Load(); prev != nil {
+			filter = prev.ipFilter
+		}
+	}
+	h.live.Store(&liveSettings{
+		rateLimit:       cfg.RateLimit,
+		debugLog:        cfg.DebugLog,
+		orderStatusTTL:  time.Duration(cfg.Cache.OrderStatusTTL) * time.Second,
+		topCustomersTTL: time.Duration(cfg.Cache.TopCustomersTTL) * time.Second,
+		itemStatsTTL:    time.Duration(cfg.Cache.ItemStatsTTL) * time.Second,
+		negativeTTL:     time.Duration(cfg.Cache.NegativeTTL) * time.Second,
+		ipFilter:        filter,
+	})
+}
+
+// Register registers all routes and middleware on the given router
+func (h *Handler) Register(router Router) {
+	limits := h.cfg.Concurrency
+	// Routes without a timeout of their own
+	std := timeout(time.Duration(h.cfg.HTTP.RequestTimeout) * time.Second)
+
+	// Middleware
+	router.Use(h.requestID())
+	router.Use(h.accessLog())
+	router.Use(h.debugLog())
+	router.Use(h.renderErrors())
+	router.Use(apiVersion())
+	router.Use(h.recoverPanics())
+	router.Use(h.ipFilter())
+	router.Use(corsMiddleware())
+	router.Use(maxBodySize(int64(h.cfg.HTTP.MaxBodySize)))
+	// Live connections are long-lived and capped separately, so they
+	// don't hold request slots
+	router.Use(exceptPath(liveUpdatesPath, concurrencyLimit(limits.MaxInFlight, limits, h.logger)))
+
+	// Liveness and readiness probes
+	router.Handle(http.MethodGet, "/healthz", h.liveness)
+	router.Handle(http.MethodGet, "/readyz", timeout(quickTimeout), h.readiness)
+	router.Handle(http.MethodGet, "/version", h.getVersion)
+	router.Handle(http.MethodGet, "/metrics", h.serveMetrics)
+
+	// API documentation
+	router.Handle(http.MethodGet, "/openapi.json", serveOpenAPI(h.buildOpenAPI()))
+	router.Handle(http.MethodGet, "/docs", h.swaggerUI)
 
 	// API routes
-	v1 := router.Group("/api/v1")
+	v1 := router.Group("/api/v1",
+		concurrencyLimit(limits.MaxInFlightAPI, limits, h.logger),
+		h.limitAuthFailures(),
+		h.authenticate(),
+		h.rateLimit(),
+		h.idempotency(),
+	)
 	{
-		v1.POST("/sync", h.syncData)
-		v1.GET("/items", h.getItems)
-		v1.GET("/analytics/orders/status", h.getOrderStatusSummary)
-		v1.GET("/analytics/customers/top", h.getTopCustomers)
+		v1.Handle(http.MethodPost, "/sync", h.require(rbac.SyncTrigger), timeout(syncTimeout), h.syncData)
+		v1.Handle(http.MethodGet, "/sync/history", h.require(rbac.SyncRead), std, h.listSyncHistory)
+		v1.Handle(http.MethodGet, "/sync/:id", h.require(rbac.SyncRead), timeout(quickTimeout), h.getSyncJob)
+		v1.Handle(http.MethodGet, "/items", h.require(rbac.ItemsRead), timeoutFunc(itemsTimeout), h.getItems)
+		// Before /items/:id, which would match it too
+		v1.Handle(http.MethodGet, "/items/search", h.require(rbac.ItemsRead), std, h.searchItems)
+		v1.Handle(http.MethodGet, "/items/:id", h.require(rbac.ItemsRead), std, h.getItem)
+		v1.Handle(http.MethodPost, "/items/bulk", h.require(rbac.ItemsWrite), timeout(importTimeout), h.importItems)
+		v1.Handle(http.MethodGet, "/orders", h.require(rbac.OrdersRead), std, h.listOrders)
+		v1.Handle(http.MethodPost, "/orders", h.require(rbac.OrdersWrite), std, h.createOrder)
+		v1.Handle(http.MethodGet, "/orders/:id", h.require(rbac.OrdersRead), std, h.getOrder)
+
+		analytics := v1.Group("/analytics",
+			concurrencyLimit(limits.MaxInFlightAnalytics, limits, h.logger),
+			h.require(rbac.AnalyticsRead),
+			timeout(quickTimeout),
+		)
+		analytics.Handle(http.MethodGet, "/orders/status", h.getOrderStatusSummary)
+		analytics.Handle(http.MethodGet, "/customers/top", h.getTopCustomers)
+		analytics.Handle(http.MethodGet, "/customers/:id", h.getCustomerDetail)
+		analytics.Handle(http.MethodGet, "/items", h.getItemStats)
+	}
+
+	// Webhooks from the upstream authenticate with a signature rather
+	// than API credentials, so they sit outside the /api/v1 group's auth
+	if h.cfg.Webhooks.Secret != "" {
+		webhooks := router.Group("/api/v1/webhooks", concurrencyLimit(limits.MaxInFlightAPI, limits, h.logger))
+		webhooks.Handle(http.MethodPost, "/items", std, h.itemsWebhook)
+	}
+
+	// Flexible queries over the same data as /api/v1
+	if h.graphqlSchema != nil {
+		router.Handle(http.MethodPost, graphqlPath,
+			concurrencyLimit(limits.MaxInFlightAPI, limits, h.logger),
+			h.limitAuthFailures(),
+			h.authenticate(),
+			h.rateLimit(),
+			timeout(listTimeout),
+			h.graphql,
+		)
+	}
+
+	// Live item updates over a websocket
+	if h.cfg.WebSocket.Enabled && h.hub != nil {
+		router.Handle(http.MethodGet, liveUpdatesPath, h.limitAuthFailures(), h.authenticate(), h.rateLimit(), h.require(rbac.ItemsRead), h.liveItems)
+	}
+
+	// Passthrough routes to other services
+	if len(h.proxyRoutes) > 0 {
+		proxy := router.Group("/proxy",
+			concurrencyLimit(limits.MaxInFlightAPI, limits, h.logger),
+			h.limitAuthFailures(),
+			h.authenticate(),
+			h.rateLimit(),
+			h.require(rbac.ProxyUse),
+		)
+		for _, method := range proxyMethods {
+			proxy.Handle(method, "/*path", h.proxy)
+		}
 	}
 
-	return router
+	// Admin routes
+	admin := router.Group("/admin", h.limitAuthFailures(), h.adminAuth())
+	{
+		admin.Handle(http.MethodGet, "/api-keys", h.require(rbac.AdminAPIKeys), std, h.listAPIKeys)
+		admin.Handle(http.MethodPost, "/api-keys", h.require(rbac.AdminAPIKeys), std, h.createAPIKey)
+		admin.Handle(http.MethodDelete, "/api-keys/:id", h.require(rbac.AdminAPIKeys), std, h.revokeAPIKey)
+		admin.Handle(http.MethodGet, "/webhooks", h.requireShared(rbac.AdminWebhooks), std, h.listWebhooks)
+		admin.Handle(http.MethodPost, "/webhooks", h.requireShared(rbac.AdminWebhooks), std, h.createWebhook)
+		admin.Handle(http.MethodDelete, "/webhooks/:id", h.requireShared(rbac.AdminWebhooks), std, h.disableWebhook)
+		admin.Handle(http.MethodGet, "/webhooks/:id/deliveries", h.requireShared(rbac.AdminWebhooks), std, h.listWebhookDeliveries)
+		admin.Handle(http.MethodGet, "/loglevel", h.require(rbac.AdminLogLevel), std, h.getLogLevel)
+		admin.Handle(http.MethodPut, "/loglevel", h.require(rbac.AdminLogLevel), std, h.setLogLevel)
+		admin.Handle(http.MethodGet, "/audit", h.require(rbac.AdminAudit), std, h.listAuditEvents)
+		admin.Handle(http.MethodGet, "/jobs", h.require(rbac.AdminJobs), timeout(quickTimeout), h.listJobs)
+		admin.Handle(http.MethodGet, "/jobs/leader", h.require(rbac.AdminJobs), timeout(quickTimeout), h.getJobsLeader)
+		admin.Handle(http.MethodPost, "/jobs/:name/run", h.require(rbac.AdminJobs), timeout(quickTimeout), h.runJob)
+		admin.Handle(http.MethodPost, "/jobs/:name/pause", h.require(rbac.AdminJobs), timeout(quickTimeout), h.pauseJob)
+		admin.Handle(http.MethodPost, "/jobs/:name/resume", h.require(rbac.AdminJobs), timeout(quickTimeout), h.resumeJob)
+		admin.Handle(http.MethodGet, "/failed-items", h.require(rbac.AdminJobs), std, h.listFailedItems)
+		admin.Handle(http.MethodPost, "/failed-items/:id/requeue", h.require(rbac.AdminJobs), std, h.requeueFailedItem)
+
+		// Performance triage. Profiles and traces run for as long as their
+		// seconds parameter asks, so pprof has no timeout.
+		admin.Handle(http.MethodGet, "/debug/runtime", h.require(rbac.AdminDebug), std, h.getRuntimeStats)
+		admin.Handle(http.MethodGet, "/debug/vars", h.require(rbac.AdminDebug), std, h.serveExpvar)
+		admin.Handle(http.MethodGet, "/debug/pprof/*profile", h.require(rbac.AdminDebug), h.servePprof)
+		admin.Handle(http.MethodPost, "/debug/pprof/*profile", h.require(rbac.AdminDebug), h.servePprof)
+	}
 }
 
-// healthCheck returns the health status of the service
-func (h *Handler) healthCheck(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
-	defer cancel()
+// syncData handles POST /api/v1/sync. With ?async=true it returns 202 and
+// a job ID to poll at GET /api/v1/sync/:id instead of blocking.
+func (h *Handler) syncData(c Context) {
+	async := false
+	if raw := c.Query("async"); raw != "" {
+		var err error
+		if async, err = strconv.ParseBool(raw); err != nil {
+			abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid async parameter", err))
+			return
+		}
+	}
+	if async {
+		h.startSync(c)
+		return
+	}
+
+	ctx := c.Request().Context()
 
-	// Check database connection
-	if err := h.db.PingContext(ctx); err != nil {
-		h.logger.WithError(err).Error("Database health check failed")
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"status": "unhealthy",
-			"error":  "database connection failed",
-		})
+	h.log(c).Info("Manual sync requested")
+
+	err := h.jobManager.SyncDataManual(ctx)
+	if errors.Is(err, jobs.ErrSyncInProgress) {
+		abortWithError(c, apierror.New(http.StatusConflict, apierror.CodeSyncInProgress, "sync already in progress").Wrap(err))
 		return
 	}
+	if err != nil {
+		h.log(c).WithError(err).Error("Manual sync failed")
+		abortWithError(c, apierror.Internal("sync failed", err))
+		return
+	}
+
+	h.audit(c, audit.ActionSync, "", nil, H{"async": false})
+	respond(c, http.StatusOK, reply{Meta: response.Meta{Message: "sync completed successfully"}})
+}
 
-	// Check Redis connection
-	if err := h.redis.Ping(ctx).Err(); err != nil {
-		h.logger.WithError(err).Error("Redis health check failed")
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"status": "unhealthy",
-			"error":  "redis connection failed",
-		})
+// startSync queues an asynchronous sync run
+func (h *Handler) startSync(c Context) {
+	job, err := h.jobManager.StartSync(c.Request().Context())
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to start async sync")
+		abortWithError(c, apierror.Internal("failed to start sync", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":    "healthy",
-		"timestamp": time.Now().UTC(),
+	h.log(c).WithField("job_id", job.ID).Info("Async sync requested")
+	h.audit(c, audit.ActionSync, "sync_job:"+job.ID, nil, H{"async": true})
+
+	statusURL := "/api/v1/sync/" + job.ID
+	c.Header("Location", statusURL)
+	respond(c, http.StatusAccepted, reply{
+		Data: H{"job_id": job.ID, "status": job.Status, "status_url": statusURL},
+		Meta: response.Meta{Message: "sync started"},
+		Flat: true,
 	})
 }
 
-// syncData handles POST /api/v1/sync
-func (h *Handler) syncData(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Minute)
-	defer cancel()
-
-	h.logger.Info("Manual sync requested")
+// getSyncJob handles GET /api/v1/sync/:id
+func (h *Handler) getSyncJob(c Context) {
+	ctx := c.Request().Context()
 
-	if err := h.jobManager.SyncDataManual(ctx); err != nil {
-		h.logger.WithError(err).Error("Manual sync failed")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "sync failed",
-			"message": err.Error(),
-		})
+	id := c.Param("id")
+	job, err := h.jobManager.GetSyncJob(ctx, id)
+	if errors.Is(err, jobs.ErrSyncJobNotFound) {
+		abortWithError(c, apierror.NotFound(apierror.CodeSyncJobNotFound, "sync job not found", fmt.Errorf("no sync job with id %s", id)))
+		return
+	}
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to get sync job")
+		abortWithError(c, apierror.Internal("failed to retrieve sync job", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "sync completed successfully",
-		"timestamp": time.Now().UTC(),
-	})
+	respond(c, http.StatusOK, reply{Data: job})
 }
 
-// getItems handles GET /api/v1/items with Redis caching
-func (h *Handler) getItems(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
-	defer cancel()
+// listSyncHistory handles GET /api/v1/sync/history
+func (h *Handler) listSyncHistory(c Context) {
+	ctx := c.Request().Context()
 
-	// Try to get from cache first
-	var items []database.Item
-	if err := h.redis.GetJSON(ctx, itemsCacheKey, &items); err == nil {
-		h.logger.Debug("Items served from cache")
-		c.Header("X-Cache", "HIT")
-		c.JSON(http.StatusOK, gin.H{
-			"data":      items,
-			"count":     len(items),
-			"cached":    true,
-			"timestamp": time.Now().UTC(),
-		})
+	limit, offset := defaultSyncHistoryLimit, 0
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxSyncHistoryLimit {
+			abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", fmt.Errorf("limit must be between 1 and %d", maxSyncHistoryLimit)))
+			return
+		}
+		limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", errors.New("offset must be a non-negative integer")))
+			return
+		}
+		offset = n
+	}
+
+	runs, err := h.db.ListSyncRuns(ctx, limit, offset)
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to get sync history")
+		abortWithError(c, apierror.Internal("failed to retrieve sync history", err))
 		return
 	}
 
-	// Cache miss - get from database
-	items, err := h.db.GetAllItems()
+	respond(c, http.StatusOK, reply{Data: runs, Meta: response.Meta{Pagination: page(len(runs), limit, offset)}})
+}
+
+// getOrderStatusSummary handles GET /api/v1/analytics/orders/status, as
+// JSON, CSV, NDJSON or XLSX. See orderStatusSummary for where results come from.
+func (h *Handler) getOrderStatusSummary(c Context) {
+	ctx := c.Request().Context()
+
+	format, err := parseExportFormat(c)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to get items from database")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "failed to retrieve items",
-			"message": err.Error(),
-		})
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", err))
+		return
+	}
+	period, filter, err := parseOrderStatusFilter(c.Request().URL.Query(), time.Now())
+	if err != nil {
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", err))
 		return
 	}
 
-	// Store in cache for next time
-	if err := h.redis.SetJSON(ctx, itemsCacheKey, items, itemsCacheTTL); err != nil {
-		h.logger.WithError(err).Warn("Failed to cache items")
+	summaries, computedAt, cached, err := h.orderStatusSummary(ctx, period, filter)
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to get order status summary")
+		abortWithError(c, apierror.Internal("failed to retrieve order status summary", err))
+		return
 	}
 
-	h.logger.WithField("count", len(items)).Debug("Items served from database")
-	c.Header("X-Cache", "MISS")
-	c.JSON(http.StatusOK, gin.H{
-		"data":      items,
-		"count":     len(items),
-		"cached":    false,
-		"timestamp": time.Now().UTC(),
-	})
+	c.Header("X-Cache", cacheStatus(cached))
+	if format != formatJSON {
+		h.exportOrderStatusSummary(c, format, period, summaries)
+		return
+	}
+	r := reply{Data: summaries, Meta: response.Meta{
+		Cache:   &response.Cache{Hit: cached},
+		Filters: H{"period": period},
+	}}
+	if computedAt != nil {
+		at := computedAt.UTC()
+		r.Meta.Cache.ComputedAt = &at
+		r.V1 = H{"computed_at": at}
+	}
+	respond(c, http.StatusOK, r)
 }
 
-// getOrderStatusSummary handles GET /api/v1/analytics/orders/status
-func (h *Handler) getOrderStatusSummary(c *gin.Context) {
-	summaries, err := h.db.GetOrderStatusSummary()
+// getTopCustomers handles GET /api/v1/analytics/customers/top, as JSON,
+// CSV, NDJSON or XLSX. See topCustomers for where results come from.
+func (h *Handler) getTopCustomers(c Context) {
+	ctx := c.Request().Context()
+
+	format, err := parseExportFormat(c)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to get order status summary")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "failed to retrieve order status summary",
-			"message": err.Error(),
-		})
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", err))
+		return
+	}
+	filter, err := parseTopCustomersFilter(c.Request().URL.Query())
+	if err != nil {
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data":      summaries,
-		"timestamp": time.Now().UTC(),
-	})
+	customers, cached, err := h.topCustomers(ctx, filter)
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to get top customers")
+		abortWithError(c, apierror.Internal("failed to retrieve top customers", err))
+		return
+	}
+
+	c.Header("X-Cache", cacheStatus(cached))
+	if format != formatJSON {
+		h.exportTopCustomers(c, format, customers)
+		return
+	}
+	respond(c, http.StatusOK, reply{Data: customers, Meta: response.Meta{
+		Cache:   &response.Cache{Hit: cached},
+		Filters: H{"limit": filter.Limit},
+	}})
 }
 
-// getTopCustomers handles GET /api/v1/analytics/customers/top
-func (h *Handler) getTopCustomers(c *gin.Context) {
-	customers, err := h.db.GetTopCustomers()
+// getCustomerDetail handles GET /api/v1/analytics/customers/:id. See
+// customerDetail for where results come from.
+func (h *Handler) getCustomerDetail(c Context) {
+	ctx := c.Request().Context()
+
+	customerID, since, err := parseCustomerDetailParams(c, time.Now())
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to get top customers")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "failed to retrieve top customers",
-			"message": err.Error(),
-		})
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data":      customers,
-		"timestamp": time.Now().UTC(),
-	})
+	detail, cached, err := h.customerDetail(ctx, customerID, since)
+	if errors.Is(err, database.ErrNotFound) {
+		abortWithError(c, apierror.NotFound(apierror.CodeCustomerNotFound, "customer not found", fmt.Errorf("no orders for customer %q", customerID)))
+		return
+	}
+	if err != nil {
+		h.log(c).WithError(err).WithField("customer_id", customerID).Error("Failed to get customer detail")
+		abortWithError(c, apierror.Internal("failed to retrieve customer detail", err))
+		return
+	}
+
+	c.Header("X-Cache", cacheStatus(cached))
+	respond(c, http.StatusOK, reply{Data: detail, Meta: response.Meta{
+		Cache:   &response.Cache{Hit: cached},
+		Filters: H{"since": since},
+	}})
 }
 
 // corsMiddleware adds CORS headers
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
+func corsMiddleware() HandlerFunc {
+	return func(c Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-API-Key, X-Request-ID, Accept-Version")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
 
-		if c.Request.Method == "OPTIONS" {
+		if c.Request().Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
@@ -1,147 +1,693 @@
 package api
 
 import (
-	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"api-gateway-backend/internal/analytics"
+	"api-gateway-backend/internal/api/gatewaypb"
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/audit"
+	"api-gateway-backend/internal/cache"
 	"api-gateway-backend/internal/config"
 	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/events"
+	"api-gateway-backend/internal/health"
+	"api-gateway-backend/internal/jobs"
 	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/metrics"
+	"api-gateway-backend/internal/rbac"
+	"api-gateway-backend/internal/redis"
+	"api-gateway-backend/internal/reporting"
+	"api-gateway-backend/internal/signing"
+	"api-gateway-backend/internal/tenant"
+	"api-gateway-backend/internal/version"
+	"api-gateway-backend/internal/webhooks"
 
-	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/net/websocket"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// MockDB is a mock implementation of database operations
+// MockDB is a mock implementation of database.Store
 type MockDB struct {
 	mock.Mock
 }
 
+var _ database.Store = (*MockDB)(nil)
+
 func (m *MockDB) Close() error {
 	args := m.Called()
 	return args.Error(0)
 }
 
-func (m *MockDB) PingContext(ctx interface{}) error {
+func (m *MockDB) PingContext(ctx context.Context) error {
 	args := m.Called(ctx)
 	return args.Error(0)
 }
 
-func (m *MockDB) UpsertItem(item *database.Item) error {
-	args := m.Called(item)
+func (m *MockDB) UpsertItem(ctx context.Context, item *database.Item) error {
+	args := m.Called(ctx, item)
 	return args.Error(0)
 }
 
-func (m *MockDB) GetAllItems() ([]database.Item, error) {
-	args := m.Called()
+func (m *MockDB) UpsertItemsBatch(ctx context.Context, items []database.Item, chunkSize int) error {
+	args := m.Called(ctx, items, chunkSize)
+	return args.Error(0)
+}
+
+func (m *MockDB) SearchItems(ctx context.Context, search database.ItemSearch) ([]database.ItemMatch, error) {
+	args := m.Called(ctx, search)
+	matches, _ := args.Get(0).([]database.ItemMatch)
+	return matches, args.Error(1)
+}
+
+func (m *MockDB) SoftDeleteMissingItems(ctx context.Context, seen []string) ([]string, error) {
+	args := m.Called(ctx, seen)
+	deleted, _ := args.Get(0).([]string)
+	return deleted, args.Error(1)
+}
+
+func (m *MockDB) SaveFailedItem(ctx context.Context, item *database.FailedItem) error {
+	args := m.Called(ctx, item)
+	return args.Error(0)
+}
+
+func (m *MockDB) ListFailedItems(ctx context.Context, limit, offset int) ([]database.FailedItem, error) {
+	args := m.Called(ctx, limit, offset)
+	items, _ := args.Get(0).([]database.FailedItem)
+	return items, args.Error(1)
+}
+
+func (m *MockDB) GetFailedItem(ctx context.Context, id int64) (*database.FailedItem, error) {
+	args := m.Called(ctx, id)
+	item, _ := args.Get(0).(*database.FailedItem)
+	return item, args.Error(1)
+}
+
+func (m *MockDB) DeleteFailedItem(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockDB) ListOutboxEvents(ctx context.Context, limit int) ([]database.OutboxEvent, error) {
+	args := m.Called(ctx, limit)
+	events, _ := args.Get(0).([]database.OutboxEvent)
+	return events, args.Error(1)
+}
+
+func (m *MockDB) DeleteOutboxEvents(ctx context.Context, ids []int64) error {
+	args := m.Called(ctx, ids)
+	return args.Error(0)
+}
+
+func (m *MockDB) WithTx(ctx context.Context, fn func(tx *database.Tx) error) error {
+	args := m.Called(ctx, fn)
+	return args.Error(0)
+}
+
+func (m *MockDB) GetAllItems(ctx context.Context) ([]database.Item, error) {
+	args := m.Called(ctx)
 	return args.Get(0).([]database.Item), args.Error(1)
 }
 
-func (m *MockDB) GetOrderStatusSummary() ([]database.OrderStatusSummary, error) {
-	args := m.Called()
+func (m *MockDB) GetItems(ctx context.Context, filter database.ItemFilter) ([]database.Item, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]database.Item), args.Error(1)
+}
+
+// StreamItems passes the items the expectation returns to fn one by one
+func (m *MockDB) StreamItems(ctx context.Context, filter database.ItemFilter, fn func(database.Item) error) error {
+	args := m.Called(ctx, filter)
+	items, _ := args.Get(0).([]database.Item)
+	for _, item := range items {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockDB) GetItemByID(ctx context.Context, id int64) (*database.Item, error) {
+	args := m.Called(ctx, id)
+	item, _ := args.Get(0).(*database.Item)
+	return item, args.Error(1)
+}
+
+func (m *MockDB) GetItemsByExternalIDs(ctx context.Context, externalIDs []string) ([]database.Item, error) {
+	args := m.Called(ctx, externalIDs)
+	items, _ := args.Get(0).([]database.Item)
+	return items, args.Error(1)
+}
+
+func (m *MockDB) UpsertUsers(ctx context.Context, users []database.User, chunkSize int) error {
+	args := m.Called(ctx, users, chunkSize)
+	return args.Error(0)
+}
+
+func (m *MockDB) UpsertComments(ctx context.Context, comments []database.Comment, chunkSize int) error {
+	args := m.Called(ctx, comments, chunkSize)
+	return args.Error(0)
+}
+
+func (m *MockDB) UpsertTodos(ctx context.Context, todos []database.Todo, chunkSize int) error {
+	args := m.Called(ctx, todos, chunkSize)
+	return args.Error(0)
+}
+
+func (m *MockDB) ApplyItemsWebhook(ctx context.Context, eventID string, items []database.Item) (bool, error) {
+	args := m.Called(ctx, eventID, items)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDB) CreateWebhookSubscription(ctx context.Context, sub *database.WebhookSubscription) error {
+	args := m.Called(ctx, sub)
+	return args.Error(0)
+}
+
+func (m *MockDB) ListWebhookSubscriptions(ctx context.Context) ([]database.WebhookSubscription, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]database.WebhookSubscription), args.Error(1)
+}
+
+func (m *MockDB) DisableWebhookSubscription(ctx context.Context, id int64) (*database.WebhookSubscription, error) {
+	args := m.Called(ctx, id)
+	sub, _ := args.Get(0).(*database.WebhookSubscription)
+	return sub, args.Error(1)
+}
+
+func (m *MockDB) EnqueueWebhookDeliveries(ctx context.Context, deliveries []database.WebhookDelivery) error {
+	args := m.Called(ctx, deliveries)
+	return args.Error(0)
+}
+
+func (m *MockDB) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]database.WebhookDelivery, error) {
+	args := m.Called(ctx, now, limit)
+	return args.Get(0).([]database.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockDB) FinishWebhookDeliveryAttempt(ctx context.Context, delivery *database.WebhookDelivery) error {
+	args := m.Called(ctx, delivery)
+	return args.Error(0)
+}
+
+func (m *MockDB) ListWebhookDeliveries(ctx context.Context, subscriptionID int64, limit, offset int) ([]database.WebhookDelivery, error) {
+	args := m.Called(ctx, subscriptionID, limit, offset)
+	return args.Get(0).([]database.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockDB) GetOrders(ctx context.Context, filter database.OrderFilter) ([]database.Order, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]database.Order), args.Error(1)
+}
+
+func (m *MockDB) GetOrderByID(ctx context.Context, id int64) (*database.Order, error) {
+	args := m.Called(ctx, id)
+	order, _ := args.Get(0).(*database.Order)
+	return order, args.Error(1)
+}
+
+func (m *MockDB) CreateOrder(ctx context.Context, order *database.Order) error {
+	args := m.Called(ctx, order)
+	return args.Error(0)
+}
+
+func (m *MockDB) GetOrderStatusSummary(ctx context.Context, filter database.OrderStatusFilter) ([]database.OrderStatusSummary, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).([]database.OrderStatusSummary), args.Error(1)
 }
 
-func (m *MockDB) GetTopCustomers() ([]database.TopCustomer, error) {
-	args := m.Called()
+func (m *MockDB) GetTopCustomers(ctx context.Context, filter database.TopCustomersFilter) ([]database.TopCustomer, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).([]database.TopCustomer), args.Error(1)
 }
 
-// MockRedis is a mock implementation of Redis operations
-type MockRedis struct {
-	mock.Mock
+func (m *MockDB) GetItemStats(ctx context.Context, filter database.ItemStatsFilter) (*database.ItemStats, error) {
+	args := m.Called(ctx, filter)
+	stats, _ := args.Get(0).(*database.ItemStats)
+	return stats, args.Error(1)
 }
 
-func (m *MockRedis) Close() error {
-	args := m.Called()
+func (m *MockDB) GetCustomerDetail(ctx context.Context, customerID string, since time.Time) (*database.CustomerDetail, error) {
+	args := m.Called(ctx, customerID, since)
+	detail, _ := args.Get(0).(*database.CustomerDetail)
+	return detail, args.Error(1)
+}
+
+func (m *MockDB) RefreshOrderStats(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockDB) GetOrderStatsSummary(ctx context.Context, filter database.OrderStatusFilter) ([]database.OrderStatusSummary, time.Time, error) {
+	args := m.Called(ctx, filter)
+	summaries, _ := args.Get(0).([]database.OrderStatusSummary)
+	return summaries, args.Get(1).(time.Time), args.Error(2)
+}
+
+func (m *MockDB) GetDailyOrderStatusTotals(ctx context.Context, days int) ([]database.DailyOrderStatusTotal, error) {
+	args := m.Called(ctx, days)
+	return args.Get(0).([]database.DailyOrderStatusTotal), args.Error(1)
+}
+
+func (m *MockDB) GetCustomerTotals(ctx context.Context) ([]database.TopCustomer, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]database.TopCustomer), args.Error(1)
+}
+
+func (m *MockDB) ListOrderTenants(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockDB) CreateAPIKey(ctx context.Context, key *database.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockDB) GetAPIKeyByHash(ctx context.Context, hash string) (*database.APIKey, error) {
+	args := m.Called(ctx, hash)
+	key, _ := args.Get(0).(*database.APIKey)
+	return key, args.Error(1)
+}
+
+func (m *MockDB) ListAPIKeys(ctx context.Context, tenantID string) ([]database.APIKey, error) {
+	args := m.Called(ctx, tenantID)
+	return args.Get(0).([]database.APIKey), args.Error(1)
+}
+
+func (m *MockDB) RevokeAPIKey(ctx context.Context, id int64, tenantID string) (*database.APIKey, error) {
+	args := m.Called(ctx, id, tenantID)
+	key, _ := args.Get(0).(*database.APIKey)
+	return key, args.Error(1)
+}
+
+func (m *MockDB) CreateSyncRun(ctx context.Context, run *database.SyncRun) error {
+	args := m.Called(ctx, run)
+	return args.Error(0)
+}
+
+func (m *MockDB) FinishSyncRun(ctx context.Context, run *database.SyncRun) error {
+	args := m.Called(ctx, run)
+	return args.Error(0)
+}
+
+func (m *MockDB) ListSyncRuns(ctx context.Context, limit, offset int) ([]database.SyncRun, error) {
+	args := m.Called(ctx, limit, offset)
+	return args.Get(0).([]database.SyncRun), args.Error(1)
+}
+
+func (m *MockDB) CreateAuditEvent(ctx context.Context, event *database.AuditEvent) error {
+	args := m.Called(ctx, event)
 	return args.Error(0)
 }
 
-func (m *MockRedis) Ping(ctx interface{}) *MockResult {
+func (m *MockDB) ListAuditEvents(ctx context.Context, filter database.AuditFilter) ([]database.AuditEvent, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]database.AuditEvent), args.Error(1)
+}
+
+// MockRedis is a mock implementation of redis.CacheClient
+type MockRedis struct {
+	mock.Mock
+}
+
+var _ redis.CacheClient = (*MockRedis)(nil)
+
+func (m *MockRedis) Ping(ctx context.Context) *goredis.StatusCmd {
 	args := m.Called(ctx)
-	return args.Get(0).(*MockResult)
+	return goredis.NewStatusResult("PONG", args.Error(0))
+}
+
+func (m *MockRedis) Get(ctx context.Context, key string) *goredis.StringCmd {
+	args := m.Called(ctx, key)
+	return goredis.NewStringResult(args.String(0), args.Error(1))
+}
+
+func (m *MockRedis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *goredis.StatusCmd {
+	args := m.Called(ctx, key, value, expiration)
+	return goredis.NewStatusResult("OK", args.Error(0))
+}
+
+func (m *MockRedis) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *goredis.BoolCmd {
+	args := m.Called(ctx, key, value, expiration)
+	return goredis.NewBoolResult(args.Bool(0), args.Error(1))
+}
+
+func (m *MockRedis) Del(ctx context.Context, keys ...string) *goredis.IntCmd {
+	args := m.Called(ctx, keys)
+	return goredis.NewIntResult(int64(len(keys)), args.Error(0))
+}
+
+func (m *MockRedis) Incr(ctx context.Context, key string) *goredis.IntCmd {
+	args := m.Called(ctx, key)
+	return goredis.NewIntResult(int64(args.Int(0)), args.Error(1))
+}
+
+func (m *MockRedis) Publish(ctx context.Context, channel string, message interface{}) *goredis.IntCmd {
+	args := m.Called(ctx, channel, message)
+	return goredis.NewIntResult(1, args.Error(0))
 }
 
-func (m *MockRedis) GetJSON(ctx interface{}, key string, dest interface{}) error {
+func (m *MockRedis) GetJSON(ctx context.Context, key string, dest interface{}) error {
 	args := m.Called(ctx, key, dest)
 	return args.Error(0)
 }
 
-func (m *MockRedis) SetJSON(ctx interface{}, key string, value interface{}, ttl time.Duration) error {
+func (m *MockRedis) SetJSON(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
 	args := m.Called(ctx, key, value, ttl)
 	return args.Error(0)
 }
 
-func (m *MockRedis) InvalidatePattern(ctx interface{}, pattern string) error {
+func (m *MockRedis) MGetJSON(ctx context.Context, keys []string) ([]json.RawMessage, error) {
+	args := m.Called(ctx, keys)
+	values, _ := args.Get(0).([]json.RawMessage)
+	return values, args.Error(1)
+}
+
+func (m *MockRedis) MSetJSON(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	args := m.Called(ctx, values, ttl)
+	return args.Error(0)
+}
+
+func (m *MockRedis) InvalidatePattern(ctx context.Context, pattern string) (int64, error) {
 	args := m.Called(ctx, pattern)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRedis) AllowRate(ctx context.Context, key string, rate float64, burst int) (*redis.RateLimitResult, error) {
+	args := m.Called(ctx, key, rate, burst)
+	result, _ := args.Get(0).(*redis.RateLimitResult)
+	return result, args.Error(1)
+}
+
+func (m *MockRedis) PeekRate(ctx context.Context, key string, rate float64, burst int) (*redis.RateLimitResult, error) {
+	args := m.Called(ctx, key, rate, burst)
+	result, _ := args.Get(0).(*redis.RateLimitResult)
+	return result, args.Error(1)
+}
+
+func (m *MockRedis) AcquireLock(ctx context.Context, key string, ttl time.Duration) (*redis.Lock, error) {
+	args := m.Called(ctx, key, ttl)
+	lock, _ := args.Get(0).(*redis.Lock)
+	return lock, args.Error(1)
+}
+
+func (m *MockRedis) AcquireLockAs(ctx context.Context, key, owner string, ttl time.Duration) (*redis.Lock, error) {
+	args := m.Called(ctx, key, owner, ttl)
+	lock, _ := args.Get(0).(*redis.Lock)
+	return lock, args.Error(1)
+}
+
+func (m *MockRedis) EnqueueAt(ctx context.Context, queue, id string, value interface{}, at time.Time) error {
+	args := m.Called(ctx, queue, id, value, at)
 	return args.Error(0)
 }
 
-// MockResult is a mock Redis result
-type MockResult struct {
-	mock.Mock
+func (m *MockRedis) ClaimDue(ctx context.Context, queue string, now time.Time, limit int) ([]json.RawMessage, error) {
+	args := m.Called(ctx, queue, now, limit)
+	values, _ := args.Get(0).([]json.RawMessage)
+	return values, args.Error(1)
 }
 
-func (m *MockResult) Err() error {
-	args := m.Called()
+func (m *MockRedis) Dequeue(ctx context.Context, queue string, ids ...string) error {
+	args := m.Called(ctx, queue, ids)
+	return args.Error(0)
+}
+
+func (m *MockRedis) AppendStream(ctx context.Context, stream string, values []interface{}) error {
+	args := m.Called(ctx, stream, values)
+	return args.Error(0)
+}
+
+func (m *MockRedis) ReadStream(ctx context.Context, stream, group, consumer string, count int, block, minIdle time.Duration) ([]redis.StreamMessage, error) {
+	args := m.Called(ctx, stream, group, consumer, count, block, minIdle)
+	messages, _ := args.Get(0).([]redis.StreamMessage)
+	return messages, args.Error(1)
+}
+
+func (m *MockRedis) AckStream(ctx context.Context, stream, group string, ids ...string) error {
+	args := m.Called(ctx, stream, group, ids)
 	return args.Error(0)
 }
 
-// MockJobManager is a mock implementation of job manager
+func (m *MockRedis) StreamLen(ctx context.Context, stream string) (int64, error) {
+	args := m.Called(ctx, stream)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockJobManager is a mock implementation of JobRunner
 type MockJobManager struct {
 	mock.Mock
 }
 
-func (m *MockJobManager) SyncDataManual(ctx interface{}) error {
+var _ JobRunner = (*MockJobManager)(nil)
+
+func (m *MockJobManager) SyncDataManual(ctx context.Context) error {
 	args := m.Called(ctx)
 	return args.Error(0)
 }
 
-func setupTestRouter() (*gin.Engine, *MockDB, *MockRedis, *MockJobManager) {
-	gin.SetMode(gin.TestMode)
+func (m *MockJobManager) LastSuccessfulSync(ctx context.Context) (time.Time, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (m *MockJobManager) StartSync(ctx context.Context) (*jobs.SyncJob, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*jobs.SyncJob), args.Error(1)
+}
+
+func (m *MockJobManager) Leader(ctx context.Context) (*jobs.LeaderStatus, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*jobs.LeaderStatus), args.Error(1)
+}
+
+func (m *MockJobManager) Jobs(ctx context.Context) ([]jobs.JobInfo, error) {
+	args := m.Called(ctx)
+	list, _ := args.Get(0).([]jobs.JobInfo)
+	return list, args.Error(1)
+}
+
+func (m *MockJobManager) RunJob(name string) error {
+	return m.Called(name).Error(0)
+}
+
+func (m *MockJobManager) PauseJob(ctx context.Context, name string) error {
+	return m.Called(ctx, name).Error(0)
+}
+
+func (m *MockJobManager) ResumeJob(ctx context.Context, name string) error {
+	return m.Called(ctx, name).Error(0)
+}
+
+func (m *MockJobManager) RequeueFailedItem(ctx context.Context, id int64) (*database.FailedItem, error) {
+	args := m.Called(ctx, id)
+	item, _ := args.Get(0).(*database.FailedItem)
+	return item, args.Error(1)
+}
+
+func (m *MockJobManager) GetSyncJob(ctx context.Context, id string) (*jobs.SyncJob, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*jobs.SyncJob), args.Error(1)
+}
+
+// MockPublisher is a mock implementation of webhookPublisher
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) Publish(ctx context.Context, eventType string, data interface{}) (int, error) {
+	args := m.Called(ctx, eventType, data)
+	return args.Int(0), args.Error(1)
+}
+
+// notReadyAnalytics reports the aggregates as not reconciled, so analytics
+// endpoints fall back to the database
+type notReadyAnalytics struct{}
+
+func (notReadyAnalytics) RecordOrder(ctx context.Context, order *database.Order) error {
+	return nil
+}
+
+func (notReadyAnalytics) OrderStatusSummary(ctx context.Context, days int) ([]database.OrderStatusSummary, error) {
+	return nil, analytics.ErrNotReady
+}
+
+func (notReadyAnalytics) TopCustomers(ctx context.Context, limit int) ([]database.TopCustomer, error) {
+	return nil, analytics.ErrNotReady
+}
+
+func setupTestRouter() (*Mux, *MockDB, *MockRedis, *MockJobManager) {
+	return setupTestRouterWithConfig(&config.Config{
+		Health: config.HealthConfig{SyncStaleAfter: 2700},
+	})
+}
+
+func setupTestRouterWithConfig(cfg *config.Config) (*Mux, *MockDB, *MockRedis, *MockJobManager) {
+	h, mockDB, mockRedis, mockJobManager := setupTestHandler(cfg)
+
+	// Add routes
+	router := NewMux()
+	h.Register(router)
+
+	return router, mockDB, mockRedis, mockJobManager
+}
 
+// setupTestHandler builds a handler over mocks, for tests that need to
+// swap a dependency before registering routes
+func setupTestHandler(cfg *config.Config) (*Handler, *MockDB, *MockRedis, *MockJobManager) {
 	mockDB := &MockDB{}
 	mockRedis := &MockRedis{}
 	mockJobManager := &MockJobManager{}
 	logger := logger.New()
 
-	router := gin.New()
 	h := &Handler{
 		db:         mockDB,
 		redis:      mockRedis,
 		jobManager: mockJobManager,
+		analytics:  notReadyAnalytics{},
+		hub:        events.NewHub(logger),
 		logger:     logger,
+		reporter:   reporting.Nop{},
+		auditLog:   audit.NewRecorder(mockDB, logger),
+		cfg:        cfg,
+		deps:       health.NewTracker(cfg.Health.HistorySize, cfg.Health.DownAfter),
 	}
+	redisDown := func() bool { return h.deps.Down(health.Redis) }
+	h.cache = cache.NewGuarded(cache.NewRedis(mockRedis, logger), redisDown, logger)
+	h.versions = cache.NewVersions(mockRedis, logger).WithGuard(redisDown)
+	h.ApplyConfig(cfg)
+	h.proxyRoutes, _ = newProxyRoutes(cfg.Proxy.Routes)
 
-	// Add routes
-	router.GET("/health", h.healthCheck)
-	v1 := router.Group("/api/v1")
-	{
-		v1.POST("/sync", h.syncData)
-		v1.GET("/items", h.getItems)
-		v1.GET("/analytics/orders/status", h.getOrderStatusSummary)
-		v1.GET("/analytics/customers/top", h.getTopCustomers)
+	// Cache namespaces are at version 0 unless a test bumps them
+	mockRedis.On("Get", mock.Anything, mock.MatchedBy(func(key string) bool {
+		return strings.HasSuffix(key, ":v")
+	})).Return("", goredis.Nil).Maybe()
+	// Items loaded for a list are cached one by one too
+	mockRedis.On("MSetJSON", mock.Anything, mock.Anything, itemsCacheTTL).Return(nil).Maybe()
+	// Write operations are audited
+	mockDB.On("CreateAuditEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+	if cfg.GraphQL.Enabled {
+		h.graphqlSchema, _ = newGraphQLSchema(h)
 	}
 
-	return router, mockDB, mockRedis, mockJobManager
+	return h, mockDB, mockRedis, mockJobManager
+}
+
+func TestNewHandler_UsesSharedJobManager(t *testing.T) {
+	jobManager := &MockJobManager{}
+
+	h, err := NewHandler(&MockDB{}, nil, jobManager, nil, nil, nil, &config.Config{}, logger.New())
+	assert.NoError(t, err)
+
+	// The handler must drive the caller's manager rather than start a
+	// second scheduler of its own
+	assert.Same(t, jobManager, h.jobManager)
 }
 
-func TestHealthCheck_Success(t *testing.T) {
+func TestLiveness(t *testing.T) {
 	router, mockDB, mockRedis, _ := setupTestRouter()
 
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/healthz", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Liveness must not touch dependencies
+	mockDB.AssertNotCalled(t, "PingContext", mock.Anything)
+	mockRedis.AssertNotCalled(t, "Ping", mock.Anything)
+}
+
+func TestVersion(t *testing.T) {
+	router, _, _, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/version", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data version.Info `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, version.Get(), resp.Data)
+
+	// Probes report the build too, so a rollout can be followed from them
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/healthz", nil)
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"build":{"version":"`+version.Version+`"`)
+}
+
+func TestMetrics(t *testing.T) {
+	router, _, _, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, metrics.ContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "# TYPE db_query_duration_seconds histogram\n")
+	assert.Contains(t, w.Body.String(), "# TYPE db_query_errors_total counter\n")
+}
+
+func TestReadiness_Success(t *testing.T) {
+	router, mockDB, mockRedis, mockJobManager := setupTestRouter()
+
 	// Setup mocks
-	mockResult := &MockResult{}
-	mockResult.On("Err").Return(nil)
 	mockDB.On("PingContext", mock.Anything).Return(nil)
-	mockRedis.On("Ping", mock.Anything).Return(mockResult)
+	mockRedis.On("Ping", mock.Anything).Return(nil)
+	mockJobManager.On("LastSuccessfulSync", mock.Anything).Return(time.Now(), nil)
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/health", nil)
+	req, _ := http.NewRequest("GET", "/readyz", nil)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
@@ -149,20 +695,27 @@ func TestHealthCheck_Success(t *testing.T) {
 	var response map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Equal(t, "healthy", response["status"])
+	assert.Equal(t, "ready", response["status"])
+
+	checks := response["checks"].(map[string]interface{})
+	assert.Equal(t, "up", checks["database"].(map[string]interface{})["status"])
+	assert.Equal(t, "up", checks["redis"].(map[string]interface{})["status"])
+	assert.Equal(t, "fresh", checks["sync"].(map[string]interface{})["status"])
 
 	mockDB.AssertExpectations(t)
 	mockRedis.AssertExpectations(t)
 }
 
-func TestHealthCheck_DatabaseFailure(t *testing.T) {
-	router, mockDB, _, _ := setupTestRouter()
+func TestReadiness_DatabaseFailure(t *testing.T) {
+	router, mockDB, mockRedis, mockJobManager := setupTestRouter()
 
 	// Setup mocks - database fails
 	mockDB.On("PingContext", mock.Anything).Return(assert.AnError)
+	mockRedis.On("Ping", mock.Anything).Return(nil)
+	mockJobManager.On("LastSuccessfulSync", mock.Anything).Return(time.Time{}, nil)
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/health", nil)
+	req, _ := http.NewRequest("GET", "/readyz", nil)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
@@ -170,28 +723,179 @@ func TestHealthCheck_DatabaseFailure(t *testing.T) {
 	var response map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Equal(t, "unhealthy", response["status"])
-	assert.Equal(t, "database connection failed", response["error"])
+	assert.Equal(t, "not_ready", response["status"])
+
+	checks := response["checks"].(map[string]interface{})
+	assert.Equal(t, "down", checks["database"].(map[string]interface{})["status"])
 
 	mockDB.AssertExpectations(t)
 }
 
-func TestSyncData_Success(t *testing.T) {
-	router, _, _, mockJobManager := setupTestRouter()
-
-	// Setup mocks
-	mockJobManager.On("SyncDataManual", mock.Anything).Return(nil)
+func TestReadiness_Cached(t *testing.T) {
+	router, mockDB, mockRedis, mockJobManager := setupTestRouterWithConfig(&config.Config{
+		Health: config.HealthConfig{SyncStaleAfter: 60, CheckCacheTTL: 60, CheckTimeout: 1},
+	})
 
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("POST", "/api/v1/sync", nil)
-	router.ServeHTTP(w, req)
+	// The checks run once, and later probes reuse their results
+	mockDB.On("PingContext", mock.Anything).Return(nil).Once()
+	mockRedis.On("Ping", mock.Anything).Return(nil).Once()
+	mockJobManager.On("LastSuccessfulSync", mock.Anything).Return(time.Now(), nil).Once()
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	var checkedAt []string
+	for i, cached := range []bool{false, true} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/readyz", nil)
+		router.ServeHTTP(w, req)
 
-	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Equal(t, "sync completed successfully", response["message"])
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Status    string `json:"status"`
+			Cached    bool   `json:"cached"`
+			CheckedAt string `json:"checked_at"`
+			Checks    map[string]struct {
+				LatencyMS *float64 `json:"latency_ms"`
+			} `json:"checks"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "ready", response.Status)
+		assert.Equal(t, cached, response.Cached, "probe %d", i)
+		for _, name := range []string{"database", "redis", "sync"} {
+			assert.NotNil(t, response.Checks[name].LatencyMS, name)
+		}
+		checkedAt = append(checkedAt, response.CheckedAt)
+	}
+	assert.Equal(t, checkedAt[0], checkedAt[1])
+
+	mockDB.AssertExpectations(t)
+	mockRedis.AssertExpectations(t)
+	mockJobManager.AssertExpectations(t)
+}
+
+func TestReadiness_CheckTimeout(t *testing.T) {
+	router, mockDB, mockRedis, mockJobManager := setupTestRouterWithConfig(&config.Config{
+		Health: config.HealthConfig{SyncStaleAfter: 60, CheckTimeout: 1},
+	})
+
+	// A hung database fails its own check when its timeout expires, while
+	// the others run alongside it
+	mockDB.On("PingContext", mock.Anything).Return(context.DeadlineExceeded).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	})
+	mockRedis.On("Ping", mock.Anything).Return(nil)
+	mockJobManager.On("LastSuccessfulSync", mock.Anything).Return(time.Now(), nil)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	var response struct {
+		Checks map[string]dependencyCheck `json:"checks"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "down", response.Checks["database"].Status)
+	assert.GreaterOrEqual(t, response.Checks["database"].LatencyMS, 1000.0)
+	assert.Equal(t, "up", response.Checks["redis"].Status)
+	assert.Less(t, response.Checks["redis"].LatencyMS, 1000.0)
+}
+
+func TestReadiness_RedisDown(t *testing.T) {
+	router, mockDB, mockRedis, mockJobManager := setupTestRouterWithConfig(&config.Config{
+		Health: config.HealthConfig{SyncStaleAfter: 60, CheckTimeout: 1, HistorySize: 10, DownAfter: 2},
+	})
+
+	// Redis failing with MySQL up degrades the instance but keeps it in
+	// rotation
+	mockDB.On("PingContext", mock.Anything).Return(nil)
+	mockRedis.On("Ping", mock.Anything).Return(assert.AnError)
+	mockJobManager.On("LastSuccessfulSync", mock.Anything).Return(time.Now(), nil)
+
+	var response struct {
+		Status       string          `json:"status"`
+		Dependencies []health.Status `json:"dependencies"`
+	}
+	for _, state := range []string{health.StateUp, health.StateDown} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/readyz", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "degraded", response.Status)
+		require.Len(t, response.Dependencies, 2)
+		assert.Equal(t, health.Redis, response.Dependencies[1].Name)
+		assert.Equal(t, state, response.Dependencies[1].State, "down after two failed checks")
+	}
+	assert.Equal(t, 0.0, response.Dependencies[1].SuccessRate)
+	assert.Equal(t, 1.0, response.Dependencies[0].SuccessRate)
+}
+
+func TestReadiness_UpstreamDown(t *testing.T) {
+	h, mockDB, mockRedis, mockJobManager := setupTestHandler(&config.Config{
+		Health: config.HealthConfig{SyncStaleAfter: 60, CheckTimeout: 1},
+	})
+	router := NewMux()
+	h.Register(router)
+
+	mockDB.On("PingContext", mock.Anything).Return(nil)
+	mockRedis.On("Ping", mock.Anything).Return(nil)
+	mockJobManager.On("LastSuccessfulSync", mock.Anything).Return(time.Now(), nil)
+	h.Dependencies().Record(health.Upstream("default"), assert.AnError, time.Second)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"degraded"`)
+	assert.Contains(t, w.Body.String(), `"name":"upstream:default","state":"down"`)
+}
+
+func TestGetItems_RedisKnownDown(t *testing.T) {
+	h, mockDB, mockRedis, _ := setupTestHandler(&config.Config{
+		RateLimit: config.RateLimitConfig{Enabled: true, RequestsPerMinute: 60, Burst: 10},
+	})
+	router := NewMux()
+	h.Register(router)
+
+	// Once the health checks know Redis is down, requests go straight to
+	// MySQL instead of waiting on Redis
+	h.Dependencies().Record(health.Redis, assert.AnError, time.Second)
+	expectedItems := []database.Item{{ID: 1, ExternalID: "1", Title: "Test Item"}}
+	mockDB.On("GetItems", mock.Anything, database.ItemFilter{}).Return(expectedItems, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/items", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	mockRedis.AssertNotCalled(t, "GetJSON", mock.Anything, mock.Anything, mock.Anything)
+	mockRedis.AssertNotCalled(t, "SetJSON", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRedis.AssertNotCalled(t, "AllowRate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRedis.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	mockDB.AssertExpectations(t)
+}
+
+func TestSyncData_Success(t *testing.T) {
+	router, _, _, mockJobManager := setupTestRouter()
+
+	// Setup mocks
+	mockJobManager.On("SyncDataManual", mock.Anything).Return(nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/sync", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "sync completed successfully", response["message"])
 
 	mockJobManager.AssertExpectations(t)
 }
@@ -212,10 +916,358 @@ func TestSyncData_Failure(t *testing.T) {
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 	assert.Equal(t, "sync failed", response["error"])
+	assert.Equal(t, "INTERNAL_ERROR", response["code"])
+
+	mockJobManager.AssertExpectations(t)
+}
+
+func TestSyncData_Timeout(t *testing.T) {
+	router, _, _, mockJobManager := setupTestRouter()
+
+	// Setup mocks - the external API did not answer in time
+	mockJobManager.On("SyncDataManual", mock.Anything).Return(fmt.Errorf("failed to fetch posts: %w", context.DeadlineExceeded))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/sync", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "UPSTREAM_TIMEOUT", response["code"])
+
+	mockJobManager.AssertExpectations(t)
+}
+
+func TestSyncData_InProgress(t *testing.T) {
+	router, _, _, mockJobManager := setupTestRouter()
+
+	// Setup mocks - another instance holds the sync lock
+	mockJobManager.On("SyncDataManual", mock.Anything).Return(jobs.ErrSyncInProgress)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/sync", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	mockJobManager.AssertExpectations(t)
+}
+
+func TestSyncData_Async(t *testing.T) {
+	router, _, _, mockJobManager := setupTestRouter()
+
+	// Setup mocks
+	job := &jobs.SyncJob{ID: "abc123", Status: jobs.SyncQueued}
+	mockJobManager.On("StartSync", mock.Anything).Return(job, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/sync?async=true", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "/api/v1/sync/abc123", w.Header().Get("Location"))
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", response["job_id"])
+	assert.Equal(t, "queued", response["status"])
+
+	mockJobManager.AssertNotCalled(t, "SyncDataManual", mock.Anything)
+	mockJobManager.AssertExpectations(t)
+}
+
+func TestGetSyncJob_NotFound(t *testing.T) {
+	router, _, _, mockJobManager := setupTestRouter()
+
+	// Setup mocks
+	mockJobManager.On("GetSyncJob", mock.Anything, "missing").Return(nil, jobs.ErrSyncJobNotFound)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/sync/missing", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockJobManager.AssertExpectations(t)
+}
+
+// recordingReporter keeps what it is asked to report
+type recordingReporter struct {
+	reporting.Nop
+	errors  []error
+	panics  []interface{}
+	tags    []map[string]string
+	request *http.Request
+}
+
+func (r *recordingReporter) CaptureError(err error, req *http.Request, tags map[string]string) {
+	r.errors = append(r.errors, err)
+	r.tags = append(r.tags, tags)
+	r.request = req
+}
+
+func (r *recordingReporter) CapturePanic(recovered interface{}, req *http.Request, tags map[string]string) {
+	r.panics = append(r.panics, recovered)
+	r.tags = append(r.tags, tags)
+	r.request = req
+}
+
+func TestErrorReporting(t *testing.T) {
+	h, _, _, mockJobManager := setupTestHandler(&config.Config{})
+	reporter := &recordingReporter{}
+	h.reporter = reporter
+	router := NewMux()
+	h.Register(router)
+
+	mockJobManager.On("GetSyncJob", mock.Anything, "missing").Return(nil, jobs.ErrSyncJobNotFound)
+	mockJobManager.On("GetSyncJob", mock.Anything, "broken").Return(nil, fmt.Errorf("redis unavailable"))
+	mockJobManager.On("GetSyncJob", mock.Anything, "panics").Run(func(mock.Arguments) {
+		panic("nil map")
+	}).Return(nil, nil)
+
+	// Client errors aren't reported
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/sync/missing", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, reporter.errors)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/sync/broken", nil)
+	req.Header.Set("X-Request-ID", "req-500")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	if assert.Len(t, reporter.errors, 1) {
+		assert.Equal(t, "req-500", reporter.tags[0]["request_id"])
+		assert.Equal(t, "/api/v1/sync/broken", reporter.request.URL.Path)
+	}
+
+	// A panic becomes a 500 and is reported once, as a panic
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/sync/panics", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, []interface{}{"nil map"}, reporter.panics)
+	assert.Len(t, reporter.errors, 1)
 
 	mockJobManager.AssertExpectations(t)
 }
 
+func TestApplyConfig_RateLimit(t *testing.T) {
+	cfg := &config.Config{}
+	h, _, mockRedis, mockJobManager := setupTestHandler(cfg)
+	router := NewMux()
+	h.Register(router)
+
+	mockJobManager.On("GetSyncJob", mock.Anything, "missing").Return(nil, jobs.ErrSyncJobNotFound)
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/sync/missing", nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Disabled at startup, so Redis isn't consulted
+	assert.Equal(t, http.StatusNotFound, get().Code)
+	mockRedis.AssertNotCalled(t, "AllowRate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRedis.AssertNotCalled(t, "PeekRate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// A reload enables it for the routes already registered
+	mockRedis.On("PeekRate", mock.Anything, authFailureKeyPrefix, 2.0, 5).Return(&redis.RateLimitResult{Allowed: true, Limit: 5}, nil)
+	mockRedis.On("AllowRate", mock.Anything, mock.Anything, 2.0, 5).Return(&redis.RateLimitResult{Limit: 5, RetryAfter: time.Second}, nil)
+	h.ApplyConfig(&config.Config{RateLimit: config.RateLimitConfig{Enabled: true, RequestsPerMinute: 120, Burst: 5}})
+
+	w := get()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "5", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	mockRedis.AssertExpectations(t)
+}
+
+func TestRateLimit_FailsOpen(t *testing.T) {
+	router, _, mockRedis, mockJobManager := setupTestRouterWithConfig(&config.Config{
+		RateLimit: config.RateLimitConfig{Enabled: true, RequestsPerMinute: 60, Burst: 1},
+	})
+	mockJobManager.On("GetSyncJob", mock.Anything, "missing").Return(nil, jobs.ErrSyncJobNotFound)
+	mockRedis.On("PeekRate", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, assert.AnError)
+	mockRedis.On("AllowRate", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, assert.AnError)
+
+	// Redis errors let the request through, without rate limit headers
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/sync/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	mockRedis.AssertExpectations(t)
+}
+
+func TestRateLimit_AuthFailures(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouterWithConfig(&config.Config{
+		Auth:      config.AuthConfig{APIKeysEnabled: true, AdminToken: "admin"},
+		RateLimit: config.RateLimitConfig{Enabled: true, RequestsPerMinute: 120, Burst: 5},
+	})
+	failures := authFailureKeyPrefix + "192.0.2.1"
+	mockRedis.On("GetJSON", mock.Anything, apiKeyCachePrefix+hashAPIKey("bad"), mock.Anything).Return(goredis.Nil)
+	mockDB.On("GetAPIKeyByHash", mock.Anything, hashAPIKey("bad")).Return(nil, database.ErrNotFound)
+	mockRedis.On("PeekRate", mock.Anything, failures, 2.0, 5).Return(&redis.RateLimitResult{Allowed: true, Limit: 5}, nil).Twice()
+	mockRedis.On("AllowRate", mock.Anything, failures, 2.0, 5).Return(&redis.RateLimitResult{Limit: 5}, nil).Twice()
+
+	// Each failure is counted against the client IP, on /api/v1 and /admin
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/api/v1/items", nil),
+		httptest.NewRequest("GET", "/admin/audit", nil),
+	} {
+		if req.URL.Path == "/admin/audit" {
+			req.Header.Set("X-Admin-Token", "guess")
+		} else {
+			req.Header.Set(apiKeyHeader, "bad")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	// Once they've used up the bucket, credentials aren't checked at all
+	mockRedis.On("PeekRate", mock.Anything, failures, 2.0, 5).Return(&redis.RateLimitResult{Limit: 5, RetryAfter: 30 * time.Second}, nil).Once()
+	req := httptest.NewRequest("GET", "/api/v1/items", nil)
+	req.Header.Set(apiKeyHeader, "bad")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":"RATE_LIMITED"`)
+
+	mockDB.AssertNumberOfCalls(t, "GetAPIKeyByHash", 1)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestAccessLog(t *testing.T) {
+	h, _, _, mockJobManager := setupTestHandler(&config.Config{Health: config.HealthConfig{SyncStaleAfter: 2700}})
+	log, hook := logtest.NewNullLogger()
+	log.SetLevel(logrus.InfoLevel)
+	h.logger = &logger.Logger{Logger: log}
+	router := NewMux()
+	h.Register(router)
+
+	mockJobManager.On("GetSyncJob", mock.Anything, "missing").Return(nil, jobs.ErrSyncJobNotFound)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/sync/missing", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	req.Header.Set("User-Agent", "test-client")
+	router.ServeHTTP(w, req)
+
+	entry := hook.LastEntry()
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, logrus.WarnLevel, entry.Level)
+		assert.Equal(t, "req-123", entry.Data["request_id"])
+		assert.Equal(t, "GET", entry.Data["method"])
+		assert.Equal(t, "/api/v1/sync/missing", entry.Data["path"])
+		assert.Equal(t, http.StatusNotFound, entry.Data["status"])
+		assert.Equal(t, w.Body.Len(), entry.Data["bytes"])
+		assert.Equal(t, "test-client", entry.Data["user_agent"])
+		assert.Contains(t, entry.Data, "latency_ms")
+		assert.Contains(t, entry.Data, "client_ip")
+	}
+
+	// Probes are only logged at debug level
+	hook.Reset()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/healthz", nil)
+	router.ServeHTTP(w, req)
+	assert.Empty(t, hook.AllEntries())
+}
+
+func TestDebugLog(t *testing.T) {
+	h, _, _, _ := setupTestHandler(&config.Config{
+		Auth:     config.AuthConfig{AdminToken: "admin"},
+		DebugLog: config.DebugLogConfig{HeaderEnabled: true, MaxBodySize: 1024, RedactHeaders: []string{"X-Signature"}},
+	})
+	h.logger = logger.New()
+	h.logger.SetOutput(io.Discard)
+	hook := logtest.NewLocal(h.logger.Logger)
+	router := NewMux()
+	h.Register(router)
+
+	captured := func() *logrus.Entry {
+		for _, entry := range hook.AllEntries() {
+			if entry.Message == "Request captured" {
+				return entry
+			}
+		}
+		return nil
+	}
+
+	// An admin asks for a request to be captured
+	body := `[{"external_id":"1",`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/items/bulk?api_key=k&dry_run=1", strings.NewReader(body))
+	req.Header.Set("X-Debug-Log", "true")
+	req.Header.Set("X-Admin-Token", "admin")
+	req.Header.Set("Authorization", "Bearer abc")
+	req.Header.Set("X-Signature", "sha256=abc")
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	entry := captured()
+	require.NotNil(t, entry)
+	assert.Equal(t, http.StatusBadRequest, entry.Data["status"])
+	assert.Equal(t, "/api/v1/items/bulk", entry.Data["path"])
+	assert.Equal(t, map[string]string{"api_key": "[REDACTED]", "dry_run": "1"}, entry.Data["query"])
+	assert.Equal(t, body, entry.Data["request_body"])
+	assert.Equal(t, w.Body.String(), entry.Data["response_body"])
+	headers := entry.Data["request_headers"].(map[string]string)
+	for _, name := range []string{"Authorization", "X-Admin-Token", "X-Signature"} {
+		assert.Equal(t, "[REDACTED]", headers[name], name)
+	}
+	assert.Equal(t, "application/json", headers["Content-Type"])
+	assert.Contains(t, entry.Data["response_headers"], "Content-Type")
+
+	// Not without the admin token
+	hook.Reset()
+	req, _ = http.NewRequest("POST", "/api/v1/items/bulk", strings.NewReader(body))
+	req.Header.Set("X-Debug-Log", "true")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Nil(t, captured())
+
+	// Sampled requests have their bodies cut at the size limit
+	hook.Reset()
+	h.ApplyConfig(&config.Config{DebugLog: config.DebugLogConfig{Enabled: true, SampleRate: 1, MaxBodySize: 8}})
+	req, _ = http.NewRequest("POST", "/api/v1/items/bulk", strings.NewReader(body))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	entry = captured()
+	require.NotNil(t, entry)
+	assert.Equal(t, `[{"exter... [12 more bytes]`, entry.Data["request_body"])
+}
+
+func TestListSyncHistory(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+
+	// Setup mocks
+	runs := []database.SyncRun{
+		{ID: 2, Trigger: "scheduled", Status: "succeeded", SuccessCount: 100},
+		{ID: 1, Trigger: "manual", Status: "failed", ErrorCount: 1, Errors: []string{"failed to fetch posts"}},
+	}
+	mockDB.On("ListSyncRuns", mock.Anything, 2, 0).Return(runs, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/sync/history?limit=2", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), response["count"])
+
+	mockDB.AssertExpectations(t)
+}
+
 func TestGetItems_CacheHit(t *testing.T) {
 	router, _, mockRedis, _ := setupTestRouter()
 
@@ -223,7 +1275,7 @@ func TestGetItems_CacheHit(t *testing.T) {
 	expectedItems := []database.Item{
 		{ID: 1, ExternalID: "1", Title: "Test Item", Body: "Test Body", UserID: 1},
 	}
-	mockRedis.On("GetJSON", mock.Anything, "items:all", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+	mockRedis.On("GetJSON", mock.Anything, "items:v0:all", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
 		dest := args.Get(2).(*[]database.Item)
 		*dest = expectedItems
 	})
@@ -244,6 +1296,59 @@ func TestGetItems_CacheHit(t *testing.T) {
 	mockRedis.AssertExpectations(t)
 }
 
+func TestGetItems_NotModified(t *testing.T) {
+	router, _, mockRedis, _ := setupTestRouter()
+
+	expectedItems := []database.Item{
+		{ID: 1, ExternalID: "1", Title: "Test Item", Body: "Test Body", UserID: 1, UpdatedAt: time.Now()},
+	}
+	mockRedis.On("GetJSON", mock.Anything, "items:v0:all", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		dest := args.Get(2).(*[]database.Item)
+		*dest = expectedItems
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/items", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/items", nil)
+	req.Header.Set("If-None-Match", etag)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Empty(t, w.Body.String())
+
+	// A changed listing no longer matches
+	expectedItems[0].UpdatedAt = expectedItems[0].UpdatedAt.Add(time.Second)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/items", nil)
+	req.Header.Set("If-None-Match", etag)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	// The version 2 body has its own ETag, so the version 1 one doesn't
+	// revalidate it
+	etag = w.Header().Get("ETag")
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/items", nil)
+	req.Header.Set("If-None-Match", etag)
+	req.Header.Set("Accept-Version", "2")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), `"meta"`)
+}
+
 func TestGetItems_CacheMiss(t *testing.T) {
 	router, mockDB, mockRedis, _ := setupTestRouter()
 
@@ -251,9 +1356,9 @@ func TestGetItems_CacheMiss(t *testing.T) {
 	expectedItems := []database.Item{
 		{ID: 1, ExternalID: "1", Title: "Test Item", Body: "Test Body", UserID: 1},
 	}
-	mockRedis.On("GetJSON", mock.Anything, "items:all", mock.Anything).Return(assert.AnError)
-	mockDB.On("GetAllItems").Return(expectedItems, nil)
-	mockRedis.On("SetJSON", mock.Anything, "items:all", expectedItems, itemsCacheTTL).Return(nil)
+	mockRedis.On("GetJSON", mock.Anything, "items:v0:all", mock.Anything).Return(assert.AnError)
+	mockDB.On("GetItems", mock.Anything, database.ItemFilter{}).Return(expectedItems, nil)
+	mockRedis.On("SetJSON", mock.Anything, "items:v0:all", mock.Anything, itemsCacheTTL).Return(nil)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/api/v1/items", nil)
@@ -268,54 +1373,2880 @@ func TestGetItems_CacheMiss(t *testing.T) {
 	assert.Equal(t, false, response["cached"])
 	assert.Equal(t, float64(1), response["count"])
 
+	// The loaded items are cached for GET /api/v1/items/:id in one batch
+	mockRedis.AssertCalled(t, "MSetJSON", mock.Anything, map[string]interface{}{"items:v0:id:1": expectedItems[0]}, itemsCacheTTL)
 	mockDB.AssertExpectations(t)
 	mockRedis.AssertExpectations(t)
 }
 
-func TestGetOrderStatusSummary_Success(t *testing.T) {
-	router, mockDB, _, _ := setupTestRouter()
+func TestGetItems_IncludeDeleted(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouter()
 
-	// Setup mocks
-	expectedSummaries := []database.OrderStatusSummary{
-		{Status: "PAID", OrderCount: 10, TotalAmount: 1500.50},
-		{Status: "PENDING", OrderCount: 5, TotalAmount: 750.25},
+	deletedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	items := []database.Item{
+		{ID: 1, ExternalID: "1", Title: "Kept", UserID: 1},
+		{ID: 2, ExternalID: "2", Title: "Removed upstream", UserID: 1, DeletedAt: &deletedAt},
 	}
-	mockDB.On("GetOrderStatusSummary").Return(expectedSummaries, nil)
+	key := "items:v0:list:include_deleted=true"
+	mockRedis.On("GetJSON", mock.Anything, key, mock.Anything).Return(goredis.Nil)
+	mockDB.On("GetItems", mock.Anything, database.ItemFilter{IncludeDeleted: true}).Return(items, nil)
+	mockRedis.On("SetJSON", mock.Anything, key, mock.Anything, itemsCacheTTL).Return(nil)
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/api/v1/analytics/orders/status", nil)
+	req, _ := http.NewRequest("GET", "/api/v1/items?include_deleted=true", nil)
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
-
-	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Contains(t, response, "data")
-
+	assert.Contains(t, w.Body.String(), `"deleted_at":"2024-01-02T03:04:05Z"`)
+	// Only the live item is cached for GET /api/v1/items/:id
+	mockRedis.AssertCalled(t, "MSetJSON", mock.Anything, map[string]interface{}{"items:v0:id:1": items[0]}, itemsCacheTTL)
 	mockDB.AssertExpectations(t)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/items?include_deleted=maybe", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
-func TestGetTopCustomers_Success(t *testing.T) {
+func TestSearchItems(t *testing.T) {
 	router, mockDB, _, _ := setupTestRouter()
 
-	// Setup mocks
-	expectedCustomers := []database.TopCustomer{
-		{CustomerID: "customer-1", TotalSpend: 2500.75, OrderCount: 15},
-		{CustomerID: "customer-2", TotalSpend: 1800.25, OrderCount: 12},
+	match := database.ItemMatch{
+		Item:  database.Item{ID: 3, ExternalID: "3", Title: "Gophers <3 Go", Body: "Nothing to see"},
+		Score: 2.5,
 	}
-	mockDB.On("GetTopCustomers").Return(expectedCustomers, nil)
+	mockDB.On("SearchItems", mock.Anything, database.ItemSearch{Query: "gophers go", Limit: 10, Offset: 20}).
+		Return([]database.ItemMatch{match}, nil).Once()
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/api/v1/analytics/customers/top", nil)
+	req, _ := http.NewRequest("GET", "/api/v1/items/search?q=+gophers+go+&limit=10&offset=20", nil)
 	router.ServeHTTP(w, req)
-
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Contains(t, response, "data")
+	var response struct {
+		Data  []searchResult `json:"data"`
+		Count int            `json:"count"`
+		Query string         `json:"query"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Count)
+	assert.Equal(t, "gophers go", response.Query)
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, int64(3), response.Data[0].Item.ID)
+	assert.Equal(t, 2.5, response.Data[0].Score)
+	assert.Equal(t, searchHighlights{Title: "<mark>Gophers</mark> &lt;3 <mark>Go</mark>"}, response.Data[0].Highlights)
 
 	mockDB.AssertExpectations(t)
-}
\ No newline at end of file
+}
+
+func TestSearchItems_InvalidParams(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+
+	for _, query := range []string{"", "q=+", "q=go&limit=0", "q=go&limit=101", "q=go&offset=-1", "q=" + strings.Repeat("a", maxSearchQuery+1)} {
+		t.Run(query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v1/items/search?"+query, nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+	mockDB.AssertNotCalled(t, "SearchItems", mock.Anything, mock.Anything)
+}
+
+func TestSnippet(t *testing.T) {
+	re := termsPattern("fox dog")
+	long := strings.Repeat("lorem ipsum ", 10) + "the quick brown fox jumps " + strings.Repeat("dolor sit ", 10)
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"no match", "nothing here", ""},
+		{"short body", "a <b>fox</b>", "a &lt;b&gt;<mark>fox</mark>&lt;/b&gt;"},
+		{"long body", long, "…lorem ipsum lorem ipsum lorem ipsum lorem ipsum lorem ipsum the quick brown <mark>fox</mark> jumps dolor sit dolor sit dolor sit dolor sit dolor sit dolor sit dolor sit…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, snippet(tt.body, re))
+		})
+	}
+}
+
+func TestGetItem_NotFound(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouter()
+
+	// Setup mocks - cache miss, row doesn't exist
+	mockRedis.On("GetJSON", mock.Anything, "items:v0:id:42", mock.Anything).Return(assert.AnError)
+	mockDB.On("GetItemByID", mock.Anything, int64(42)).Return(nil, database.ErrNotFound)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/items/42", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "item not found", response["error"])
+	assert.Equal(t, "ITEM_NOT_FOUND", response["code"])
+	assert.NotEmpty(t, response["request_id"])
+
+	mockDB.AssertExpectations(t)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestGetItem_InvalidID(t *testing.T) {
+	router, _, _, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/items/abc", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestNegativeCache(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouterWithConfig(&config.Config{
+		Cache: config.CacheConfig{NegativeTTL: 30},
+	})
+
+	// A missing item is cached as null for the negative TTL
+	mockRedis.On("GetJSON", mock.Anything, "items:v0:id:42", mock.Anything).Return(goredis.Nil).Twice()
+	mockDB.On("GetItemByID", mock.Anything, int64(42)).Return(nil, database.ErrNotFound).Once()
+	mockRedis.On("SetJSON", mock.Anything, "items:v0:id:42", json.RawMessage("null"), 30*time.Second).Return(nil).Once()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/items/42", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// and served from the cache while it lasts
+	mockRedis.On("GetJSON", mock.Anything, "items:v0:id:42", mock.Anything).Return(nil).Once()
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/items/42", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"ITEM_NOT_FOUND"`)
+
+	// Empty results are cached for the negative TTL rather than the usual one
+	key := "items:v0:list:user_id=9"
+	mockRedis.On("GetJSON", mock.Anything, key, mock.Anything).Return(goredis.Nil).Twice()
+	mockDB.On("GetItems", mock.Anything, mock.Anything).Return([]database.Item{}, nil).Once()
+	mockRedis.On("SetJSON", mock.Anything, key, json.RawMessage("[]"), 30*time.Second).Return(nil).Once()
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/items?user_id=9", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockDB.AssertExpectations(t)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestGetOrderStatusSummary_Success(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+
+	// Setup mocks
+	expectedSummaries := []database.OrderStatusSummary{
+		{Status: "PAID", OrderCount: 10, TotalAmount: 1500.50},
+		{Status: "PENDING", OrderCount: 5, TotalAmount: 750.25},
+	}
+	mockDB.On("GetOrderStatusSummary", mock.Anything, mock.MatchedBy(func(f database.OrderStatusFilter) bool {
+		return f.CreatedFrom != nil && f.CreatedTo != nil && f.GroupBy == ""
+	})).Return(expectedSummaries, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/analytics/orders/status", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response, "data")
+	period := response["period"].(map[string]interface{})
+	assert.Equal(t, "30d", period["name"])
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestGetOrderStatusSummary_OrderStats(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouterWithConfig(&config.Config{
+		Jobs: config.JobsConfig{OrderStatsEnabled: true, OrderStatsMaxAge: 3600},
+	})
+
+	// Preset periods are served from order_stats while it's fresh
+	computedAt := time.Now().Add(-10 * time.Minute).UTC().Truncate(time.Second)
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	stats := []database.OrderStatusSummary{{PeriodStart: &day, Status: "PAID", OrderCount: 3, TotalAmount: 300}}
+	mockDB.On("GetOrderStatsSummary", mock.Anything, mock.MatchedBy(func(f database.OrderStatusFilter) bool {
+		return f.GroupBy == database.GroupByDay
+	})).Return(stats, computedAt, nil).Once()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/analytics/orders/status?period=90d&group_by=day", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	var response struct {
+		Data       []database.OrderStatusSummary `json:"data"`
+		ComputedAt time.Time                     `json:"computed_at"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, stats, response.Data)
+	assert.True(t, computedAt.Equal(response.ComputedAt))
+
+	// and query orders once it's stale
+	mockDB.On("GetOrderStatsSummary", mock.Anything, mock.MatchedBy(func(f database.OrderStatusFilter) bool {
+		return f.GroupBy == database.GroupByWeek
+	})).Return(stats, time.Now().Add(-2*time.Hour), nil).Once()
+	mockDB.On("GetOrderStatusSummary", mock.Anything, mock.Anything).Return([]database.OrderStatusSummary{
+		{PeriodStart: &day, Status: "PAID", OrderCount: 4, TotalAmount: 400},
+	}, nil).Once()
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/analytics/orders/status?period=90d&group_by=week", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "computed_at")
+	assert.Contains(t, w.Body.String(), `"order_count":4`)
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestGetOrderStatusSummary_CustomGrouped(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	filter := database.OrderStatusFilter{CreatedFrom: &from, CreatedTo: &to, GroupBy: database.GroupByWeek}
+	mockDB.On("GetOrderStatusSummary", mock.Anything, filter).Return([]database.OrderStatusSummary{
+		{PeriodStart: &from, Status: "PAID", OrderCount: 3, TotalAmount: 300},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/analytics/orders/status?period=custom&from=2024-01-01T00:00:00Z&to=2024-03-31T00:00:00Z&group_by=week", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data   []database.OrderStatusSummary `json:"data"`
+		Period reportPeriod                  `json:"period"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, reportPeriod{Name: "custom", From: from, To: to, GroupBy: "week"}, response.Period)
+	assert.Len(t, response.Data, 1)
+	assert.True(t, from.Equal(*response.Data[0].PeriodStart))
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestParseOrderStatusFilter(t *testing.T) {
+	now := time.Date(2024, 5, 20, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		query   string
+		from    time.Time
+		wantErr bool
+	}{
+		{query: "", from: time.Date(2024, 4, 21, 0, 0, 0, 0, time.UTC)},
+		{query: "period=7d", from: time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC)},
+		{query: "period=90d&group_by=day", from: time.Date(2024, 2, 21, 0, 0, 0, 0, time.UTC)},
+		{query: "from=2024-05-01", from: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{query: "period=1y", wantErr: true},
+		{query: "period=7d&from=2024-05-01", wantErr: true},
+		{query: "period=custom", wantErr: true},
+		{query: "period=custom&from=2024-05-10&to=2024-05-01", wantErr: true},
+		{query: "group_by=month", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			period, filter, err := parseOrderStatusFilter(query, now)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.from.Equal(period.From), "from = %s", period.From)
+			assert.True(t, now.Equal(period.To))
+			assert.Equal(t, period.From, *filter.CreatedFrom)
+		})
+	}
+
+	// Late evening in New York is already the next day in UTC
+	query, _ := url.ParseQuery("period=7d")
+	period, _, err := parseOrderStatusFilter(query, time.Date(2024, 5, 20, 21, 30, 0, 0, time.FixedZone("EST", -5*60*60)))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC), period.From)
+}
+
+func TestParseEndTimeParam(t *testing.T) {
+	// A date-only upper bound includes the whole day under <=
+	query, _ := url.ParseQuery("created_from=2024-01-31&created_to=2024-01-31")
+	filter, err := parseItemFilter(query)
+	require.NoError(t, err)
+	// UTC days, whatever the server's time zone
+	assert.True(t, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC).Equal(*filter.CreatedFrom))
+	assert.True(t, time.Date(2024, 1, 31, 23, 59, 59, 999999000, time.UTC).Equal(*filter.CreatedTo), "created_to = %s", filter.CreatedTo)
+	noon := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	assert.False(t, noon.Before(*filter.CreatedFrom))
+	assert.False(t, noon.After(*filter.CreatedTo), "created_to = %s", filter.CreatedTo)
+	assert.True(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC).After(*filter.CreatedTo))
+
+	// Timestamps are kept as given
+	end, err := parseEndTimeParam("2024-01-31T12:00:00Z")
+	require.NoError(t, err)
+	assert.True(t, time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC).Equal(end))
+
+	_, err = parseEndTimeParam("31/01/2024")
+	assert.Error(t, err)
+}
+
+func TestGetTopCustomers_Success(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+
+	// Setup mocks
+	expectedCustomers := []database.TopCustomer{
+		{CustomerID: "customer-1", TotalSpend: 2500.75, OrderCount: 15},
+		{CustomerID: "customer-2", TotalSpend: 1800.25, OrderCount: 12},
+	}
+	mockDB.On("GetTopCustomers", mock.Anything, database.TopCustomersFilter{Limit: 5}).Return(expectedCustomers, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/analytics/customers/top", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response, "data")
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestGetTopCustomers_Window(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	filter := database.TopCustomersFilter{CreatedFrom: &from, CreatedTo: &to, Limit: 10}
+	mockDB.On("GetTopCustomers", mock.Anything, filter).Return([]database.TopCustomer{}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/analytics/customers/top?limit=10&from=2024-01-01T00:00:00Z&to=2024-01-31T00:00:00Z", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(10), response["limit"])
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestGetTopCustomers_InvalidParams(t *testing.T) {
+	router, _, _, _ := setupTestRouter()
+
+	for _, query := range []string{"limit=0", "limit=101", "limit=abc", "from=yesterday", "from=2024-02-01&to=2024-01-01"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/analytics/customers/top?"+query, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestWarmCache(t *testing.T) {
+	h, mockDB, mockRedis, _ := setupTestHandler(&config.Config{
+		Cache: config.CacheConfig{OrderStatusTTL: 60, TopCustomersTTL: 60},
+	})
+	targets, err := newWarmTargets([]string{
+		"/api/v1/items?sort=title",
+		"/api/v1/analytics/orders/status?group_by=day",
+		"/api/v1/analytics/customers/top?limit=5",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	h.warmTargets = targets
+
+	itemsFilter := database.ItemFilter{SortBy: "title", SortAscending: true}
+	itemsKey := "items:v0:" + strings.TrimPrefix(itemsCacheKeyFor(itemsFilter), "items:")
+	statusKey := "analytics:v0:orders:status:group_by=day&period=30d"
+	customersKey := "analytics:v0:customers:top:limit=5"
+
+	// A failing target doesn't stop the others being warmed
+	mockRedis.On("GetJSON", mock.Anything, mock.Anything, mock.Anything).Return(goredis.Nil)
+	mockDB.On("GetItems", mock.Anything, itemsFilter).Return([]database.Item(nil), assert.AnError).Once()
+	mockDB.On("GetOrderStatusSummary", mock.Anything, mock.MatchedBy(func(f database.OrderStatusFilter) bool {
+		return f.GroupBy == database.GroupByDay
+	})).Return([]database.OrderStatusSummary{{Status: "PAID", OrderCount: 1}}, nil).Once()
+	mockDB.On("GetTopCustomers", mock.Anything, database.TopCustomersFilter{Limit: 5}).
+		Return([]database.TopCustomer{{CustomerID: "customer-1"}}, nil).Once()
+	mockRedis.On("SetJSON", mock.Anything, statusKey, mock.Anything, time.Minute).Return(nil).Once()
+	mockRedis.On("SetJSON", mock.Anything, customersKey, mock.Anything, time.Minute).Return(nil).Once()
+
+	h.WarmCache(context.Background())
+
+	mockDB.AssertExpectations(t)
+	mockRedis.AssertExpectations(t)
+	mockRedis.AssertNotCalled(t, "SetJSON", mock.Anything, itemsKey, mock.Anything, mock.Anything)
+}
+
+func TestNewWarmTargets_Invalid(t *testing.T) {
+	for _, target := range []string{
+		"/api/v1/orders",
+		"/api/v1/items?user_id=abc",
+		"/api/v1/analytics/orders/status?period=1y",
+		"/api/v1/analytics/customers/top?limit=0",
+	} {
+		_, err := newWarmTargets([]string{target})
+		assert.Error(t, err, target)
+	}
+}
+
+func TestGetTopCustomers_Cached(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouterWithConfig(&config.Config{
+		Cache: config.CacheConfig{TopCustomersTTL: 60},
+	})
+
+	expectedCustomers := []database.TopCustomer{
+		{CustomerID: "customer-1", TotalSpend: 2500.75, OrderCount: 15},
+	}
+	key := "analytics:v0:customers:top:from=2024-01-01T00%3A00%3A00Z&limit=10"
+	mockRedis.On("GetJSON", mock.Anything, key, mock.Anything).Return(goredis.Nil).Twice()
+	mockDB.On("GetTopCustomers", mock.Anything, mock.Anything).Return(expectedCustomers, nil).Once()
+	mockRedis.On("SetJSON", mock.Anything, key, mock.Anything, time.Minute).Return(nil).Once()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/analytics/customers/top?limit=10&from=2024-01-01T00:00:00Z", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+
+	// Served from the cache on the next request
+	mockRedis.On("GetJSON", mock.Anything, key, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		dest := args.Get(2).(*[]database.TopCustomer)
+		*dest = expectedCustomers
+	})
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/analytics/customers/top?limit=10&from=2024-01-01T00:00:00Z", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+
+	mockDB.AssertExpectations(t)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestGetItemStats(t *testing.T) {
+	router, mockDB, mockRedis, mockJobManager := setupTestRouterWithConfig(&config.Config{
+		Cache: config.CacheConfig{ItemStatsTTL: 60},
+	})
+
+	stats := &database.ItemStats{
+		TotalItems:   3,
+		DeletedItems: 1,
+		ByUser:       []database.UserItemCount{{UserID: 1, ItemCount: 2}, {UserID: 2, ItemCount: 1}},
+		Daily:        []database.DailyItemCount{{Day: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), ItemCount: 3}},
+	}
+	key := "items:v0:stats:days=7&users=10"
+	mockRedis.On("GetJSON", mock.Anything, key, mock.Anything).Return(goredis.Nil).Twice()
+	mockDB.On("GetItemStats", mock.Anything, database.ItemStatsFilter{Days: 7, Users: 10}).Return(stats, nil).Once()
+	mockRedis.On("SetJSON", mock.Anything, key, mock.Anything, time.Minute).Return(nil).Once()
+	mockJobManager.On("LastSuccessfulSync", mock.Anything).Return(time.Now().Add(-time.Hour), nil).Once()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/analytics/items?days=7", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	var response struct {
+		Data     database.ItemStats `json:"data"`
+		LastSync struct {
+			AgeSeconds *int64 `json:"age_seconds"`
+		} `json:"last_sync"`
+		Days int `json:"days"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, *stats, response.Data)
+	assert.Equal(t, 7, response.Days)
+	require.NotNil(t, response.LastSync.AgeSeconds)
+	assert.InDelta(t, 3600, *response.LastSync.AgeSeconds, 5)
+
+	// The counts are cached, while a sync that never ran is reported as such
+	mockRedis.On("GetJSON", mock.Anything, key, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*args.Get(2).(*database.ItemStats) = *stats
+	})
+	mockJobManager.On("LastSuccessfulSync", mock.Anything).Return(time.Time{}, nil).Once()
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/analytics/items?days=7", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Contains(t, w.Body.String(), `"last_sync":{"age_seconds":null,"succeeded_at":null}`)
+
+	mockDB.AssertExpectations(t)
+	mockJobManager.AssertExpectations(t)
+}
+
+func TestGetItemStats_InvalidParams(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+
+	for _, query := range []string{"days=0", "days=366", "days=x", "users=0", "users=101"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/analytics/items?"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	mockDB.AssertNotCalled(t, "GetItemStats", mock.Anything, mock.Anything)
+}
+
+func TestGetCustomerDetail(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouterWithConfig(&config.Config{
+		Cache: config.CacheConfig{TopCustomersTTL: 60, NegativeTTL: 30},
+	})
+
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month()-2, 1, 0, 0, 0, 0, now.Location())
+	detail := &database.CustomerDetail{
+		CustomerID:        "customer-1",
+		TotalSpend:        300,
+		OrderCount:        3,
+		AverageOrderValue: 100,
+		FirstOrderAt:      time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC),
+		LastOrderAt:       time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC),
+		ByStatus:          []database.OrderStatusSummary{{Status: "completed", OrderCount: 3, TotalAmount: 300}},
+		Monthly:           []database.MonthlySpend{{Month: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), OrderCount: 1, TotalSpend: 100}},
+	}
+	key := "analytics:v0:customers:detail:id=customer-1&since=" + since.Format("2006-01-02")
+	mockRedis.On("GetJSON", mock.Anything, key, mock.Anything).Return(goredis.Nil).Twice()
+	mockDB.On("GetCustomerDetail", mock.Anything, "customer-1", since).Return(detail, nil).Once()
+	mockRedis.On("SetJSON", mock.Anything, key, mock.Anything, time.Minute).Return(nil).Once()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/analytics/customers/customer-1?months=3", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	var response struct {
+		Data  database.CustomerDetail `json:"data"`
+		Since time.Time               `json:"since"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, *detail, response.Data)
+	assert.True(t, since.Equal(response.Since))
+
+	mockRedis.On("GetJSON", mock.Anything, key, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*args.Get(2).(*database.CustomerDetail) = *detail
+	}).Once()
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/analytics/customers/customer-1?months=3", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+
+	// A customer without orders is not found, and cached as such for the
+	// negative TTL
+	key = "analytics:v0:customers:detail:id=nobody&since=" + since.Format("2006-01-02")
+	mockRedis.On("GetJSON", mock.Anything, key, mock.Anything).Return(goredis.Nil).Twice()
+	mockDB.On("GetCustomerDetail", mock.Anything, "nobody", since).Return(nil, database.ErrNotFound).Once()
+	mockRedis.On("SetJSON", mock.Anything, key, json.RawMessage("null"), 30*time.Second).Return(nil).Once()
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/analytics/customers/nobody?months=3", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"CUSTOMER_NOT_FOUND"`)
+
+	mockDB.AssertExpectations(t)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestGetCustomerDetail_InvalidParams(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+
+	for _, path := range []string{
+		"customer-1?months=0",
+		"customer-1?months=61",
+		"customer-1?months=x",
+		strings.Repeat("c", 37),
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/analytics/customers/"+path, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+	mockDB.AssertNotCalled(t, "GetCustomerDetail", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOrderStatusCacheKeyFor(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	// Preset periods ignore their moving bounds
+	assert.Equal(t, "analytics:orders:status:period=30d",
+		orderStatusCacheKeyFor(reportPeriod{Name: "30d", From: from, To: time.Now()}))
+	assert.Equal(t, "analytics:orders:status:from=2024-01-01T00%3A00%3A00Z&group_by=day&period=custom&to=2024-01-31T00%3A00%3A00Z",
+		orderStatusCacheKeyFor(reportPeriod{Name: "custom", From: from, To: to, GroupBy: "day"}))
+
+	// So do custom periods without an end, which run up to now
+	period, _, err := newOrderStatusFilter("", &from, nil, "", time.Now())
+	require.NoError(t, err)
+	later, _, err := newOrderStatusFilter("", &from, nil, "", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, "analytics:orders:status:from=2024-01-01T00%3A00%3A00Z&period=custom&to=open", orderStatusCacheKeyFor(period))
+	assert.Equal(t, orderStatusCacheKeyFor(period), orderStatusCacheKeyFor(later))
+}
+
+func TestCreateOrder_InvalidatesAnalyticsCache(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouter()
+
+	mockDB.On("CreateOrder", mock.Anything, mock.Anything).Return(nil)
+	mockRedis.On("Incr", mock.Anything, "analytics:v").Return(1, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/orders", strings.NewReader(`{"customer_id":"customer-1","amount":10}`))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockDB.AssertExpectations(t)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	cfg := &config.Config{
+		Proxy: config.ProxyConfig{Routes: []config.ProxyRoute{
+			{Name: "users", Prefix: "/users", Targets: []string{"http://users.internal"}},
+		}},
+		Webhooks:  config.WebhookConfig{Secret: "s3cret"},
+		WebSocket: config.WebSocketConfig{Enabled: true},
+		GraphQL:   config.GraphQLConfig{Enabled: true},
+	}
+	router, _, _, _ := setupTestRouterWithConfig(cfg)
+	h := &Handler{cfg: cfg, hub: events.NewHub(logger.New())}
+	doc := h.buildOpenAPI()
+
+	for _, rt := range router.routes {
+		path := "/" + strings.Join(rt.segments, "/")
+		assert.True(t, doc.Has(rt.method, path), "%s %s is not documented", rt.method, path)
+	}
+}
+
+func TestOpenAPI_Served(t *testing.T) {
+	router, _, _, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/openapi.json", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var doc map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &doc)
+	assert.NoError(t, err)
+	assert.Equal(t, "3.0.3", doc["openapi"])
+	assert.Contains(t, doc["paths"], "/api/v1/items/{id}")
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat(`{"title":"item"},`, 100)
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}), config.CompressionConfig{Enabled: true, MinSize: 1024, Level: -1})
+
+	// Large JSON responses are gzipped for clients that accept it
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	gz, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	decoded, err := io.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+
+	// Clients that don't accept gzip get the plain body
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, body, w.Body.String())
+}
+
+func TestCompress_SmallResponse(t *testing.T) {
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"ok":true}`))
+	}), config.CompressionConfig{Enabled: true, MinSize: 1024, Level: -1})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, `{"ok":true}`, w.Body.String())
+}
+
+func TestProxy_ForwardsRequest(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/accounts/42", r.URL.Path)
+		assert.Equal(t, "expand=true", r.URL.RawQuery)
+		assert.Equal(t, "acme", r.Header.Get("X-Tenant"))
+		assert.Empty(t, r.Header.Get("Cookie"))
+		assert.Equal(t, "203.0.113.7", r.Header.Get("X-Forwarded-For"))
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "proxied")
+	}))
+	defer backend.Close()
+
+	router, _, _, _ := setupTestRouterWithConfig(&config.Config{Proxy: config.ProxyConfig{Routes: []config.ProxyRoute{
+		{Name: "api", Prefix: "/", Targets: []string{"http://127.0.0.1:1"}},
+		{Name: "accounts", Prefix: "/billing/accounts", Targets: []string{backend.URL + "/v1/accounts"}, Timeout: 5,
+			SetHeaders: map[string]string{"X-Tenant": "acme"}, RemoveHeaders: []string{"Cookie"}},
+	}}})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/proxy/billing/accounts/42?expand=true", nil)
+	req.Header.Set("Cookie", "session=secret")
+	req.RemoteAddr = "203.0.113.7:41000"
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "proxied", w.Body.String())
+}
+
+func TestProxy_StripsGatewayCredentials(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range gatewayCredentialHeaders {
+			assert.Empty(t, r.Header.Values(name), name)
+		}
+		assert.Equal(t, "Bearer upstream-token", r.Header.Get("X-Upstream-Auth"))
+	}))
+	defer backend.Close()
+
+	// Even a route that removes nothing doesn't hand the caller's
+	// credentials to its upstream
+	router, _, _, _ := setupTestRouterWithConfig(&config.Config{Proxy: config.ProxyConfig{Routes: []config.ProxyRoute{
+		{Name: "users", Prefix: "/users", Targets: []string{backend.URL}, Timeout: 5,
+			SetHeaders: map[string]string{"X-Upstream-Auth": "Bearer upstream-token"}},
+	}}})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/proxy/users/1", nil)
+	req.Header.Set(apiKeyHeader, "gw_key")
+	req.Header.Set("Authorization", "Bearer gateway-token")
+	req.Header.Set("X-Admin-Token", "admin")
+	req.Header.Set(signing.ClientHeader, "billing-worker")
+	req.Header.Set(signing.TimestampHeader, "1700000000")
+	req.Header.Set(signing.SignatureHeader, "deadbeef")
+	req.Header.Set(idempotencyKeyHeader, "key-1")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestProxy_RetriesIdempotentRequests(t *testing.T) {
+	var calls int
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer backend.Close()
+
+	router, _, _, _ := setupTestRouterWithConfig(&config.Config{Proxy: config.ProxyConfig{Routes: []config.ProxyRoute{
+		{Name: "users", Prefix: "/users", Targets: []string{backend.URL}, Timeout: 5, MaxRetries: 2},
+	}}})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/proxy/users/1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, calls)
+
+	// Requests with a body can't be replayed
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/proxy/users", strings.NewReader(`{"name":"a"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, 3, calls)
+}
+
+func TestProxy_Errors(t *testing.T) {
+	router, _, _, _ := setupTestRouterWithConfig(&config.Config{Proxy: config.ProxyConfig{Routes: []config.ProxyRoute{
+		{Name: "users", Prefix: "/users", Targets: []string{"http://127.0.0.1:1"}, Timeout: 5},
+	}}})
+
+	tests := []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{"/proxy/orders/1", http.StatusNotFound, "ROUTE_NOT_FOUND"},
+		{"/proxy/users/1", http.StatusBadGateway, "UPSTREAM_ERROR"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tt.path, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.status, w.Code, tt.path)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, tt.code, body["code"], tt.path)
+	}
+}
+
+func TestProxy_RateLimit(t *testing.T) {
+	var calls int
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer backend.Close()
+
+	router, _, _, _ := setupTestRouterWithConfig(&config.Config{Proxy: config.ProxyConfig{Routes: []config.ProxyRoute{
+		{Name: "users", Prefix: "/users", Targets: []string{backend.URL}, Timeout: 5,
+			RateLimit: config.ThrottleConfig{RequestsPerSecond: 0.1, Burst: 1}},
+	}}})
+
+	// The second request would have to queue for ten seconds, longer than
+	// the route allows, so it's refused without reaching the target
+	for _, status := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/proxy/users/1", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code)
+	}
+	assert.Equal(t, 1, calls)
+}
+
+func TestNewProxyRoutes_InvalidTarget(t *testing.T) {
+	_, err := newProxyRoutes([]config.ProxyRoute{{Name: "users", Prefix: "/users", Targets: []string{"users.internal"}}})
+	assert.Error(t, err)
+}
+
+// signedWebhook builds a webhook delivery signed with secret at ts
+func signedWebhook(secret string, ts time.Time, body string) *http.Request {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	req, _ := http.NewRequest("POST", "/api/v1/webhooks/items", strings.NewReader(body))
+	req.Header.Set(webhooks.TimestampHeader, timestamp)
+	req.Header.Set(webhooks.SignatureHeader, webhooks.Sign(secret, timestamp, []byte(body)))
+	return req
+}
+
+func TestItemsWebhook(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouterWithConfig(&config.Config{
+		Webhooks: config.WebhookConfig{Secret: "s3cret", Tolerance: 300},
+	})
+
+	body := `{"id":"evt_1","items":[{"id":7,"userId":2,"title":"Pushed","body":"Now"}]}`
+	expected := []database.Item{{ExternalID: "7", Title: "Pushed", Body: "Now", UserID: 2}}
+	stored := database.Item{ID: 11, ExternalID: "7", Title: "Pushed", Body: "Now", UserID: 2}
+	mockDB.On("GetItemsByExternalIDs", mock.Anything, []string{"7"}).Return([]database.Item{}, nil).Once()
+	mockDB.On("ApplyItemsWebhook", mock.Anything, "evt_1", expected).Return(true, nil).Once()
+	mockDB.On("GetItemsByExternalIDs", mock.Anything, []string{"7"}).Return([]database.Item{stored}, nil).Once()
+	mockRedis.On("Incr", mock.Anything, "items:v").Return(1, nil).Once()
+	mockRedis.On("Publish", mock.Anything, events.ItemsChannel, mock.MatchedBy(func(payload []byte) bool {
+		var event events.ItemEvent
+		return json.Unmarshal(payload, &event) == nil && event.Type == events.ItemCreated && event.Item.ID == 11
+	})).Return(nil).Once()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, signedWebhook("s3cret", time.Now(), body))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, false, response["duplicate"])
+	assert.Equal(t, float64(1), response["count"])
+
+	// A redelivery is acknowledged without touching the cache or
+	// publishing live updates
+	mockDB.On("GetItemsByExternalIDs", mock.Anything, []string{"7"}).Return([]database.Item{stored}, nil).Once()
+	mockDB.On("ApplyItemsWebhook", mock.Anything, "evt_1", expected).Return(false, nil).Once()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, signedWebhook("s3cret", time.Now(), body))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, true, response["duplicate"])
+
+	mockDB.AssertExpectations(t)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestItemsWebhook_Rejected(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouterWithConfig(&config.Config{
+		Webhooks: config.WebhookConfig{Secret: "s3cret", Tolerance: 300},
+	})
+	body := `{"id":"evt_1","items":[]}`
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"wrong secret", signedWebhook("other", time.Now(), body), http.StatusUnauthorized},
+		{"stale timestamp", signedWebhook("s3cret", time.Now().Add(-10*time.Minute), body), http.StatusUnauthorized},
+		{"unsigned", httptest.NewRequest("POST", "/api/v1/webhooks/items", strings.NewReader(body)), http.StatusUnauthorized},
+		{"missing id", signedWebhook("s3cret", time.Now(), `{"items":[]}`), http.StatusBadRequest},
+		{"invalid item", signedWebhook("s3cret", time.Now(), `{"id":"evt_2","items":[{"id":0}]}`), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.req)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+	mockDB.AssertNotCalled(t, "ApplyItemsWebhook", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestItemsWebhook_DisabledWithoutSecret(t *testing.T) {
+	router, _, _, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, signedWebhook("", time.Now(), `{"id":"evt_1"}`))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestItemsWebhook_PublishesEvent(t *testing.T) {
+	h, mockDB, mockRedis, _ := setupTestHandler(&config.Config{
+		Webhooks: config.WebhookConfig{Secret: "s3cret", Tolerance: 300},
+	})
+	publisher := &MockPublisher{}
+	h.webhooks = publisher
+	router := NewMux()
+	h.Register(router)
+
+	body := `{"id":"evt_1","items":[{"id":7,"userId":2,"title":"Pushed","body":"Now"}]}`
+	mockDB.On("GetItemsByExternalIDs", mock.Anything, []string{"7"}).Return([]database.Item{}, nil)
+	mockDB.On("ApplyItemsWebhook", mock.Anything, "evt_1", mock.Anything).Return(true, nil).Once()
+	mockRedis.On("Publish", mock.Anything, events.ItemsChannel, mock.Anything).Return(nil)
+	mockRedis.On("Incr", mock.Anything, "items:v").Return(1, nil).Once()
+	publisher.On("Publish", mock.Anything, webhooks.EventItemsUpdated, H{
+		"source_event_id": "evt_1",
+		"external_ids":    []string{"7"},
+		"count":           1,
+	}).Return(1, nil).Once()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, signedWebhook("s3cret", time.Now(), body))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Duplicates change nothing, so subscribers aren't notified again
+	mockDB.On("ApplyItemsWebhook", mock.Anything, "evt_1", mock.Anything).Return(false, nil).Once()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, signedWebhook("s3cret", time.Now(), body))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	publisher.AssertExpectations(t)
+}
+
+// adminRequest builds a request to an admin route
+func adminRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-Admin-Token", "admin")
+	return req
+}
+
+func TestImportItems(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouterWithConfig(&config.Config{
+		Jobs: config.JobsConfig{SyncBatchSize: 500},
+	})
+
+	body := `[
+		{"external_id":"1","title":"New","body":"b","user_id":2},
+		{"external_id":"2","title":"Changed","user_id":2},
+		{"external_id":" ","title":"No ID"},
+		{"external_id":"3","title":"Wrong type","user_id":"two"},
+		{"external_id":"1","title":"Again"}
+	]`
+	expected := []database.Item{
+		{ExternalID: "1", Title: "New", Body: "b", UserID: 2},
+		{ExternalID: "2", Title: "Changed", UserID: 2},
+	}
+	stored := database.Item{ID: 5, ExternalID: "2", Title: "Old", UserID: 2}
+	mockDB.On("GetItemsByExternalIDs", mock.Anything, []string{"1", "2"}).Return([]database.Item{stored}, nil)
+	mockDB.On("UpsertItemsBatch", mock.Anything, expected, 500).Return(nil).Once()
+	mockRedis.On("Incr", mock.Anything, "items:v").Return(1, nil).Once()
+	mockRedis.On("Publish", mock.Anything, events.ItemsChannel, mock.Anything).Return(nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/items/bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data    []bulkResult `json:"data"`
+		Created int          `json:"created"`
+		Updated int          `json:"updated"`
+		Failed  int          `json:"failed"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Created)
+	assert.Equal(t, 1, response.Updated)
+	assert.Equal(t, 3, response.Failed)
+	require.Len(t, response.Data, 5)
+	assert.Equal(t, bulkResult{Index: 0, ExternalID: "1", Status: bulkCreated}, response.Data[0])
+	assert.Equal(t, bulkResult{Index: 1, ExternalID: "2", Status: bulkUpdated}, response.Data[1])
+	assert.Equal(t, bulkFailed, response.Data[2].Status)
+	assert.Equal(t, "external_id is required", response.Data[2].Reason)
+	assert.Equal(t, bulkFailed, response.Data[3].Status)
+	assert.Contains(t, response.Data[3].Reason, "invalid item")
+	assert.Equal(t, bulkResult{Index: 4, ExternalID: "1", Status: bulkFailed, Reason: "duplicate of the item at index 0"}, response.Data[4])
+
+	mockDB.AssertCalled(t, "CreateAuditEvent", mock.Anything, mock.MatchedBy(func(event *database.AuditEvent) bool {
+		return event.Action == audit.ActionItemsImport && event.Target == "items"
+	}))
+	mockDB.AssertExpectations(t)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestImportItems_NDJSON(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouterWithConfig(&config.Config{
+		Jobs: config.JobsConfig{SyncBatchSize: 500, Outbox: true},
+	})
+
+	body := "{\"external_id\":\"1\",\"title\":\"One\"}\n\n{not json\n{\"external_id\":\"2\",\"title\":\"Two\"}"
+	mockDB.On("GetItemsByExternalIDs", mock.Anything, []string{"1", "2"}).Return([]database.Item{}, nil)
+	mockDB.On("UpsertItemsBatch", mock.Anything, mock.Anything, 500).Return(nil).Once()
+	mockRedis.On("Incr", mock.Anything, "items:v").Return(1, nil).Once()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/items/bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data    []bulkResult `json:"data"`
+		Created int          `json:"created"`
+		Failed  int          `json:"failed"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Created)
+	assert.Equal(t, 1, response.Failed)
+	// Blank lines aren't records, and a malformed line fails on its own
+	require.Len(t, response.Data, 3)
+	assert.Equal(t, bulkFailed, response.Data[1].Status)
+	assert.Equal(t, "2", response.Data[2].ExternalID)
+
+	// With the outbox, the relay publishes the items' events
+	mockRedis.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+	mockDB.AssertExpectations(t)
+}
+
+func TestImportItems_Rejected(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+	tooMany := "[" + strings.Repeat(`{},`, maxBulkItems) + "{}]"
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"not an array", `{"external_id":"1","title":"One"}`},
+		{"malformed", `[{"external_id":"1",`},
+		{"trailing data", `[] []`},
+		{"too many items", tooMany},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/v1/items/bulk", strings.NewReader(tt.body))
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+	mockDB.AssertNotCalled(t, "UpsertItemsBatch", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLogLevel(t *testing.T) {
+	h, _, _, _ := setupTestHandler(&config.Config{Auth: config.AuthConfig{AdminToken: "admin"}})
+	router := NewMux()
+	h.Register(router)
+	h.logger.SetLevelName("info")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/admin/loglevel", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"level":"info"`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("PUT", "/admin/loglevel", `{"level":"DEBUG"}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"level":"debug"`)
+	assert.Equal(t, logrus.DebugLevel, h.logger.GetLevel())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("PUT", "/admin/loglevel", `{"level":"verbose"}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, logrus.DebugLevel, h.logger.GetLevel())
+
+	// Admin routes require the admin token
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/admin/loglevel", strings.NewReader(`{"level":"error"}`)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "debug", h.logger.LevelName())
+}
+
+func TestJobsLeader(t *testing.T) {
+	router, _, _, mockJobManager := setupTestRouterWithConfig(&config.Config{Auth: config.AuthConfig{AdminToken: "admin"}})
+	mockJobManager.On("Leader", mock.Anything).Return(&jobs.LeaderStatus{Election: true, Instance: "gateway-1-ab12", Leader: "gateway-2-cd34"}, nil).Once()
+	mockJobManager.On("Leader", mock.Anything).Return(nil, fmt.Errorf("redis unavailable")).Once()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/admin/jobs/leader", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"leader":"gateway-2-cd34"`)
+	assert.Contains(t, w.Body.String(), `"is_leader":false`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/admin/jobs/leader", ""))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestJobsAdmin(t *testing.T) {
+	router, mockDB, _, mockJobManager := setupTestRouterWithConfig(&config.Config{Auth: config.AuthConfig{AdminToken: "admin"}})
+	next := time.Date(2026, 1, 1, 12, 15, 0, 0, time.UTC)
+	mockJobManager.On("Jobs", mock.Anything).Return([]jobs.JobInfo{
+		{Name: "sync", Schedule: "0 */15 * * * *", NextRun: &next, LastRun: &jobs.JobRun{Trigger: jobs.TriggerScheduled, Status: jobs.JobSucceeded}},
+		{Name: "analytics_reconcile", Schedule: "0 */10 * * * *", Paused: true},
+	}, nil)
+	mockJobManager.On("RunJob", "sync").Return(nil)
+	mockJobManager.On("PauseJob", mock.Anything, "sync").Return(nil)
+	mockJobManager.On("ResumeJob", mock.Anything, "sync").Return(nil)
+	mockJobManager.On("RunJob", "missing").Return(jobs.ErrJobNotFound)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/admin/jobs", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"next_run":"2026-01-01T12:15:00Z"`)
+	assert.Contains(t, w.Body.String(), `"last_run":{"trigger":"scheduled","status":"succeeded"`)
+	assert.Contains(t, w.Body.String(), `"name":"analytics_reconcile","schedule":"0 */10 * * * *","paused":true}`)
+
+	tests := []struct {
+		path         string
+		expectedCode int
+		action       string
+	}{
+		{"/admin/jobs/sync/run", http.StatusAccepted, audit.ActionJobRun},
+		{"/admin/jobs/sync/pause", http.StatusOK, audit.ActionJobPause},
+		{"/admin/jobs/sync/resume", http.StatusOK, audit.ActionJobResume},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest("POST", tt.path, ""))
+		assert.Equal(t, tt.expectedCode, w.Code, tt.path)
+		mockDB.AssertCalled(t, "CreateAuditEvent", mock.Anything, mock.MatchedBy(func(event *database.AuditEvent) bool {
+			return event.Action == tt.action && event.Target == "job:sync"
+		}))
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/admin/jobs/missing/run", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"JOB_NOT_FOUND"`)
+}
+
+func TestFailedItems(t *testing.T) {
+	router, mockDB, _, mockJobManager := setupTestRouterWithConfig(&config.Config{Auth: config.AuthConfig{AdminToken: "admin"}})
+	dead := database.FailedItem{ID: 7, TenantID: "default", ExternalID: "42", Attempts: 5, LastError: "deadlock found"}
+	mockDB.On("ListFailedItems", mock.Anything, 10, 0).Return([]database.FailedItem{dead}, nil)
+	mockJobManager.On("RequeueFailedItem", mock.Anything, int64(7)).Return(&dead, nil)
+	mockJobManager.On("RequeueFailedItem", mock.Anything, int64(8)).Return(nil, database.ErrNotFound)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/admin/failed-items?limit=10", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"external_id":"42"`)
+	assert.Contains(t, w.Body.String(), `"last_error":"deadlock found"`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/admin/failed-items?limit=500", ""))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/admin/failed-items/7/requeue", ""))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	mockDB.AssertCalled(t, "CreateAuditEvent", mock.Anything, mock.MatchedBy(func(event *database.AuditEvent) bool {
+		return event.Action == audit.ActionFailedItemRequeue && event.Target == "failed_item:7"
+	}))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/admin/failed-items/8/requeue", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"FAILED_ITEM_NOT_FOUND"`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/admin/failed-items/abc/requeue", ""))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDebugEndpoints(t *testing.T) {
+	router, _, _, _ := setupTestRouterWithConfig(&config.Config{Auth: config.AuthConfig{AdminToken: "admin"}})
+	runtime.GC()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/admin/debug/runtime", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data runtimeStats `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Positive(t, resp.Data.Goroutines)
+	assert.Positive(t, resp.Data.Heap.Alloc)
+	assert.NotZero(t, resp.Data.GC.NumGC)
+	assert.NotEmpty(t, resp.Data.GC.RecentPausesMS)
+	assert.NotNil(t, resp.Data.GC.LastGC)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/admin/debug/vars", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"memstats"`)
+
+	// The pprof index links to profiles relative to the mount point
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/admin/debug/pprof/", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `href='goroutine?debug=1'`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/admin/debug/pprof/goroutine?debug=1", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine profile:")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/admin/debug/pprof/nonexistent", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Like other admin routes, they require the admin token
+	for _, path := range []string{"/admin/debug/runtime", "/admin/debug/vars", "/admin/debug/pprof/heap"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+	}
+}
+
+func TestCreateWebhook(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouterWithConfig(&config.Config{
+		Auth: config.AuthConfig{AdminToken: "admin"},
+	})
+
+	mockDB.On("CreateWebhookSubscription", mock.Anything, mock.MatchedBy(func(sub *database.WebhookSubscription) bool {
+		return sub.URL == "https://example.com/hook" &&
+			assert.ObjectsAreEqual([]string{webhooks.EventItemsSynced}, sub.Events) &&
+			strings.HasPrefix(sub.Secret, "whsec_")
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*database.WebhookSubscription).ID = 5
+	}).Return(nil).Once()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/admin/webhooks", `{"url":"https://example.com/hook","events":["items.synced","items.synced"]}`))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, strings.HasPrefix(response["secret"].(string), "whsec_"))
+	data := response["data"].(map[string]interface{})
+	assert.Equal(t, float64(5), data["id"])
+	assert.NotContains(t, data, "secret")
+	mockDB.AssertExpectations(t)
+}
+
+func TestCreateWebhook_Invalid(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouterWithConfig(&config.Config{
+		Auth: config.AuthConfig{AdminToken: "admin"},
+	})
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed", `{`},
+		{"relative url", `{"url":"/hook"}`},
+		{"unsupported scheme", `{"url":"ftp://example.com/hook"}`},
+		{"unknown event", `{"url":"https://example.com/hook","events":["orders.created"]}`},
+		{"short secret", `{"url":"https://example.com/hook","secret":"short"}`},
+		{"missing url", `{"events":["items.synced"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, adminRequest("POST", "/admin/webhooks", tt.body))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+	mockDB.AssertNotCalled(t, "CreateWebhookSubscription", mock.Anything, mock.Anything)
+}
+
+func TestDisableWebhook(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouterWithConfig(&config.Config{
+		Auth: config.AuthConfig{AdminToken: "admin"},
+	})
+
+	disabledAt := time.Now()
+	mockDB.On("DisableWebhookSubscription", mock.Anything, int64(5)).Return(&database.WebhookSubscription{ID: 5, DisabledAt: &disabledAt}, nil).Once()
+	mockDB.On("DisableWebhookSubscription", mock.Anything, int64(6)).Return(nil, database.ErrNotFound).Once()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("DELETE", "/admin/webhooks/5", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("DELETE", "/admin/webhooks/6", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), string(apierror.CodeWebhookNotFound))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("DELETE", "/admin/webhooks/abc", ""))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestListWebhookDeliveries(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouterWithConfig(&config.Config{
+		Auth: config.AuthConfig{AdminToken: "admin"},
+	})
+
+	mockDB.On("ListWebhookDeliveries", mock.Anything, int64(5), 10, 20).Return([]database.WebhookDelivery{
+		{ID: 1, SubscriptionID: 5, EventType: webhooks.EventItemsSynced, Status: database.DeliveryPending, URL: "https://example.com/hook", Secret: "hidden"},
+	}, nil).Once()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/admin/webhooks/5/deliveries?limit=10&offset=20", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "hidden")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/admin/webhooks/5/deliveries?limit=1000", ""))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestCreateOrder_InvalidFields(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/orders", strings.NewReader(`{"customer_id":"  ","amount":-1,"status":"shipped"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response struct {
+		Code    apierror.Code `json:"code"`
+		Message string        `json:"message"`
+		Details struct {
+			Fields []FieldError `json:"fields"`
+		} `json:"details"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, apierror.CodeInvalidRequest, response.Code)
+	assert.Equal(t, "customer_id is required", response.Message)
+	assert.Equal(t, []FieldError{
+		{Field: "customer_id", Rule: "required", Message: "customer_id is required"},
+		{Field: "amount", Rule: "gt", Message: "amount must be greater than 0"},
+		{Field: "status", Rule: "oneof", Message: "status must be one of PENDING, PAID, CANCELLED"},
+	}, response.Details.Fields)
+
+	mockDB.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+}
+
+func TestValidateRequest(t *testing.T) {
+	req := createWebhookRequest{URL: "https://example.com/hook", Events: []string{webhooks.EventItemsSynced, "orders.created"}}
+	fields := validateRequest(&req)
+	if assert.Len(t, fields, 1) {
+		assert.Equal(t, "events[1]", fields[0].Field)
+		assert.Equal(t, "webhook_event", fields[0].Rule)
+		assert.Contains(t, fields[0].Message, `unknown event "orders.created"`)
+	}
+
+	assert.Empty(t, validateRequest(&logLevelRequest{Level: "debug"}))
+}
+
+func TestCreateOrder_Audited(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouter()
+
+	mockDB.On("CreateOrder", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*database.Order).ID = 42
+	}).Return(nil)
+	mockRedis.On("Incr", mock.Anything, "analytics:v").Return(1, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/orders", strings.NewReader(`{"customer_id":"customer-1","amount":10}`))
+	req.Header.Set(requestIDHeader, "req-1")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	mockDB.AssertCalled(t, "CreateAuditEvent", mock.Anything, mock.MatchedBy(func(event *database.AuditEvent) bool {
+		return event.Action == audit.ActionOrderCreate && event.Actor == "anonymous" && event.RequestID == "req-1" &&
+			event.Target == "order:42" && event.Changes["customer_id"] == database.Change{To: "customer-1"}
+	}))
+}
+
+func TestIdempotency(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouterWithConfig(&config.Config{
+		Idempotency: config.IdempotencyConfig{Enabled: true, TTL: 60},
+	})
+	const key = "idempotency:ip:192.0.2.1:order-1"
+	body := `{"customer_id":"customer-1","amount":10}`
+	request := func(body string) *http.Request {
+		req := httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(body))
+		req.Header.Set(idempotencyKeyHeader, "order-1")
+		return req
+	}
+
+	// The first request runs and its response is stored
+	mockRedis.On("SetNX", mock.Anything, key, mock.Anything, idempotencyLockTTL).Return(true, nil).Once()
+	mockDB.On("CreateOrder", mock.Anything, mock.Anything).Return(nil).Once()
+	mockRedis.On("Incr", mock.Anything, "analytics:v").Return(1, nil)
+	var stored idempotentResponse
+	mockRedis.On("SetJSON", mock.Anything, key, mock.Anything, time.Minute).Return(nil).Run(func(args mock.Arguments) {
+		stored = args.Get(2).(idempotentResponse)
+	}).Once()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request(body))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, http.StatusCreated, stored.Status)
+	assert.Equal(t, w.Body.String(), string(stored.Body))
+	assert.Equal(t, "application/json; charset=utf-8", stored.Header["Content-Type"])
+
+	// A retry gets the stored response without creating another order
+	mockRedis.On("SetNX", mock.Anything, key, mock.Anything, idempotencyLockTTL).Return(false, nil)
+	mockRedis.On("GetJSON", mock.Anything, key, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*args.Get(2).(*idempotentResponse) = stored
+	}).Twice()
+
+	replay := httptest.NewRecorder()
+	router.ServeHTTP(replay, request(body))
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, w.Body.String(), replay.Body.String())
+
+	// The same key with a different body is rejected
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, request(`{"customer_id":"customer-2","amount":10}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), string(apierror.CodeIdempotencyReused))
+
+	// So is a retry while the first request is still running
+	mockRedis.On("GetJSON", mock.Anything, key, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*args.Get(2).(*idempotentResponse) = idempotentResponse{Fingerprint: stored.Fingerprint}
+	}).Once()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, request(body))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), string(apierror.CodeRequestInProgress))
+
+	mockDB.AssertNumberOfCalls(t, "CreateOrder", 1)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestIdempotency_FailedRequestReleasesKey(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouterWithConfig(&config.Config{
+		Idempotency: config.IdempotencyConfig{Enabled: true, TTL: 60},
+	})
+	const key = "idempotency:ip:192.0.2.1:order-1"
+
+	mockRedis.On("SetNX", mock.Anything, key, mock.Anything, idempotencyLockTTL).Return(true, nil).Once()
+	mockDB.On("CreateOrder", mock.Anything, mock.Anything).Return(assert.AnError).Once()
+	mockRedis.On("Del", mock.Anything, []string{key}).Return(nil).Once()
+
+	req := httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(`{"customer_id":"customer-1","amount":10}`))
+	req.Header.Set(idempotencyKeyHeader, "order-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	mockRedis.AssertExpectations(t)
+	mockRedis.AssertNotCalled(t, "SetJSON", mock.Anything, key, mock.Anything, mock.Anything)
+}
+
+func TestListAuditEvents(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouterWithConfig(&config.Config{
+		Auth: config.AuthConfig{AdminToken: "admin"},
+	})
+
+	from := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	mockDB.On("ListAuditEvents", mock.Anything, database.AuditFilter{
+		Action: audit.ActionAPIKeyRevoke, Actor: "admin", From: &from, Limit: 10, Offset: 5,
+	}).Return([]database.AuditEvent{
+		{ID: 1, Action: audit.ActionAPIKeyRevoke, Actor: "admin", Target: "api_key:3"},
+	}, nil).Once()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/admin/audit?action=api_key.revoke&actor=admin&from=2026-01-02T00:00:00Z&limit=10&offset=5", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"target":"api_key:3"`)
+
+	for _, query := range []string{"limit=1000", "offset=-1", "from=yesterday"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest("GET", "/admin/audit?"+query, ""))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestLiveItems(t *testing.T) {
+	h, _, _, _ := setupTestHandler(&config.Config{
+		WebSocket: config.WebSocketConfig{Enabled: true, HeartbeatInterval: 60},
+	})
+	router := NewMux()
+	h.Register(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?user_id=2", "", server.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var control liveServerMessage
+	assert.NoError(t, websocket.JSON.Receive(ws, &control))
+	assert.Equal(t, liveSubscribed, control.Type)
+	assert.Equal(t, []int{2}, control.UserIDs)
+
+	// Only the filtered user's items are delivered
+	h.hub.Broadcast(events.ItemEvent{Type: events.ItemCreated, Item: database.Item{ID: 1, UserID: 1}})
+	h.hub.Broadcast(events.ItemEvent{Type: events.ItemUpdated, Item: database.Item{ID: 2, UserID: 2}})
+
+	var event events.ItemEvent
+	assert.NoError(t, websocket.JSON.Receive(ws, &event))
+	assert.Equal(t, events.ItemUpdated, event.Type)
+	assert.Equal(t, int64(2), event.Item.ID)
+
+	// The filter can be changed on an open connection
+	assert.NoError(t, websocket.JSON.Send(ws, liveClientMessage{Action: "subscribe", UserIDs: []int{1}}))
+	assert.NoError(t, websocket.JSON.Receive(ws, &control))
+	assert.Equal(t, liveSubscribed, control.Type)
+	assert.Equal(t, []int{1}, control.UserIDs)
+
+	h.hub.Broadcast(events.ItemEvent{Type: events.ItemCreated, Item: database.Item{ID: 3, UserID: 1}})
+	assert.NoError(t, websocket.JSON.Receive(ws, &event))
+	assert.Equal(t, int64(3), event.Item.ID)
+
+	assert.NoError(t, websocket.JSON.Send(ws, liveClientMessage{Action: "unsubscribe"}))
+	assert.NoError(t, websocket.JSON.Receive(ws, &control))
+	assert.Equal(t, liveError, control.Type)
+
+	// Closing the hub ends the connection
+	h.hub.Close()
+	assert.Error(t, websocket.JSON.Receive(ws, &event))
+}
+
+func TestLiveItems_Rejected(t *testing.T) {
+	router, _, _, _ := setupTestRouterWithConfig(&config.Config{
+		WebSocket: config.WebSocketConfig{Enabled: true},
+	})
+
+	tests := []struct {
+		name   string
+		query  string
+		header bool
+		status int
+	}{
+		{"not an upgrade", "", false, http.StatusUpgradeRequired},
+		{"invalid user_id", "?user_id=1,abc", true, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/ws"+tt.query, nil)
+			if tt.header {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestLiveItems_ConnectionLimit(t *testing.T) {
+	h, _, _, _ := setupTestHandler(&config.Config{
+		WebSocket: config.WebSocketConfig{Enabled: true},
+	})
+	h.liveConns = make(chan struct{}, 1)
+	h.liveConns <- struct{}{}
+	router := NewMux()
+	h.Register(router)
+
+	req := httptest.NewRequest("GET", "/ws", nil)
+	req.Header.Set("Upgrade", "websocket")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestLiveItems_DisabledByDefault(t *testing.T) {
+	router, _, _, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/ws", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// dialGRPC serves h's gRPC server in memory and returns a client for it
+func dialGRPC(t *testing.T, h *Handler) gatewaypb.GatewayClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := h.NewGRPCServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial gRPC server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return gatewaypb.NewGatewayClient(conn)
+}
+
+// grpcErrorReason returns the API error code carried by a gRPC error
+func grpcErrorReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func TestGRPC_ListItems(t *testing.T) {
+	h, _, mockRedis, _ := setupTestHandler(&config.Config{})
+	client := dialGRPC(t, h)
+
+	expectedItems := []database.Item{
+		{ID: 1, ExternalID: "1", Title: "Test Item", Body: "Test Body", UserID: 1},
+	}
+	mockRedis.On("GetJSON", mock.Anything, "items:v0:list:user_id=1", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		dest := args.Get(2).(*[]database.Item)
+		*dest = expectedItems
+	})
+
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-123")
+	resp, err := client.ListItems(ctx, &gatewaypb.ListItemsRequest{UserId: proto.Int32(1)}, grpc.Header(&header))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, resp.Cached)
+	if assert.Len(t, resp.Items, 1) {
+		assert.Equal(t, int64(1), resp.Items[0].Id)
+		assert.Equal(t, "Test Item", resp.Items[0].Title)
+	}
+	assert.Equal(t, []string{"req-123"}, header.Get("x-request-id"))
+
+	_, err = client.ListItems(context.Background(), &gatewaypb.ListItemsRequest{Sort: "-nope"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	mockRedis.AssertExpectations(t)
+}
+
+func TestGRPC_GetItem(t *testing.T) {
+	h, mockDB, mockRedis, _ := setupTestHandler(&config.Config{})
+	client := dialGRPC(t, h)
+
+	mockRedis.On("GetJSON", mock.Anything, "items:v0:id:42", mock.Anything).Return(assert.AnError)
+	mockDB.On("GetItemByID", mock.Anything, int64(42)).Return(nil, database.ErrNotFound)
+
+	_, err := client.GetItem(context.Background(), &gatewaypb.GetItemRequest{Id: 42})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, string(apierror.CodeItemNotFound), grpcErrorReason(err))
+
+	_, err = client.GetItem(context.Background(), &gatewaypb.GetItemRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, string(apierror.CodeInvalidParameter), grpcErrorReason(err))
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestGRPC_Analytics(t *testing.T) {
+	h, mockDB, _, _ := setupTestHandler(&config.Config{})
+	client := dialGRPC(t, h)
+
+	customers := []database.TopCustomer{{CustomerID: "customer-1", TotalSpend: 2500.75, OrderCount: 15}}
+	mockDB.On("GetTopCustomers", mock.Anything, database.TopCustomersFilter{Limit: 5}).Return(customers, nil)
+	summaries := []database.OrderStatusSummary{{Status: "PAID", OrderCount: 3, TotalAmount: 120.5}}
+	mockDB.On("GetOrderStatusSummary", mock.Anything, mock.Anything).Return(summaries, nil)
+
+	top, err := client.GetTopCustomers(context.Background(), &gatewaypb.GetTopCustomersRequest{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int32(5), top.Limit)
+		assert.Len(t, top.Customers, 1)
+		assert.Equal(t, "customer-1", top.Customers[0].CustomerId)
+	}
+
+	summary, err := client.GetOrderStatusSummary(context.Background(), &gatewaypb.GetOrderStatusSummaryRequest{Period: "7d"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "7d", summary.Period.Name)
+		assert.Len(t, summary.Summaries, 1)
+		assert.Equal(t, int32(3), summary.Summaries[0].OrderCount)
+	}
+
+	// Validation is shared with the HTTP endpoints
+	_, err = client.GetTopCustomers(context.Background(), &gatewaypb.GetTopCustomersRequest{Limit: 101})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.GetOrderStatusSummary(context.Background(), &gatewaypb.GetOrderStatusSummaryRequest{Period: "7d", From: timestamppb.Now()})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestGRPC_Sync(t *testing.T) {
+	h, _, _, mockJobManager := setupTestHandler(&config.Config{})
+	client := dialGRPC(t, h)
+
+	mockJobManager.On("StartSync", mock.Anything).Return(&jobs.SyncJob{ID: "job-1", Status: jobs.SyncQueued}, nil).Once()
+	resp, err := client.Sync(context.Background(), &gatewaypb.SyncRequest{Async: true})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "job-1", resp.JobId)
+		assert.Equal(t, jobs.SyncQueued, resp.Status)
+	}
+
+	mockJobManager.On("SyncDataManual", mock.Anything).Return(jobs.ErrSyncInProgress).Once()
+	_, err = client.Sync(context.Background(), &gatewaypb.SyncRequest{})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	assert.Equal(t, string(apierror.CodeSyncInProgress), grpcErrorReason(err))
+
+	mockJobManager.AssertExpectations(t)
+}
+
+func TestGRPC_Authenticate(t *testing.T) {
+	h, mockDB, mockRedis, _ := setupTestHandler(&config.Config{
+		Auth: config.AuthConfig{APIKeysEnabled: true},
+	})
+	client := dialGRPC(t, h)
+
+	_, err := client.GetItem(context.Background(), &gatewaypb.GetItemRequest{Id: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	mockRedis.On("GetJSON", mock.Anything, apiKeyCachePrefix+hashAPIKey("bad"), mock.Anything).Return(assert.AnError)
+	mockDB.On("GetAPIKeyByHash", mock.Anything, hashAPIKey("bad")).Return(nil, database.ErrNotFound)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "bad")
+	_, err = client.GetItem(ctx, &gatewaypb.GetItemRequest{Id: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, string(apierror.CodeUnauthorized), grpcErrorReason(err))
+}
+
+func TestGRPC_Authorize(t *testing.T) {
+	h, mockDB, mockRedis, _ := setupTestHandler(&config.Config{
+		Auth: config.AuthConfig{APIKeysEnabled: true},
+		RBAC: config.RBACConfig{Enabled: true, Roles: rbac.DefaultRoles(), DefaultRoles: []string{"reader"}},
+	})
+	client := dialGRPC(t, h)
+
+	mockRedis.On("GetJSON", mock.Anything, apiKeyCachePrefix+hashAPIKey("reader-key"), mock.Anything).Return(goredis.Nil)
+	mockDB.On("GetAPIKeyByHash", mock.Anything, hashAPIKey("reader-key")).Return(&database.APIKey{ID: 1}, nil)
+	mockRedis.On("SetJSON", mock.Anything, apiKeyCachePrefix+hashAPIKey("reader-key"), mock.Anything, mock.Anything).Return(nil)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "reader-key")
+	_, err := client.Sync(ctx, &gatewaypb.SyncRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, string(apierror.CodeForbidden), grpcErrorReason(err))
+}
+
+// graphqlPost runs a GraphQL document against router and decodes the result
+func graphqlPost(t *testing.T, router http.Handler, query string, variables map[string]interface{}) (int, graphqlResult) {
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body))))
+
+	var result graphqlResult
+	if w.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	}
+	return w.Code, result
+}
+
+// graphqlResult is a decoded GraphQL response
+type graphqlResult struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Path       []interface{}          `json:"path"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func TestGraphQL_Items(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouterWithConfig(&config.Config{
+		GraphQL: config.GraphQLConfig{Enabled: true},
+	})
+
+	expectedItems := []database.Item{
+		{ID: 1, ExternalID: "1", Title: "First", UserID: 1},
+		{ID: 2, ExternalID: "2", Title: "Second", UserID: 1},
+		{ID: 3, ExternalID: "3", Title: "Third", UserID: 1},
+	}
+	mockRedis.On("GetJSON", mock.Anything, "items:v0:list:user_id=1", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		dest := args.Get(2).(*[]database.Item)
+		*dest = expectedItems
+	})
+	mockRedis.On("GetJSON", mock.Anything, "items:v0:id:2", mock.Anything).Return(assert.AnError)
+	mockDB.On("GetItemByID", mock.Anything, int64(2)).Return(nil, database.ErrNotFound)
+
+	code, result := graphqlPost(t, router, `query($user: Int) {
+		items(filter: {userId: $user}, limit: 1, offset: 1) { totalCount cached items { id title userId } }
+		item(id: "2") { id }
+	}`, map[string]interface{}{"user": 1})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, result.Errors)
+	assert.JSONEq(t, `{"totalCount":3,"cached":true,"items":[{"id":"2","title":"Second","userId":1}]}`, string(result.Data["items"]))
+	assert.JSONEq(t, `null`, string(result.Data["item"]))
+
+	// Validation is shared with the HTTP endpoints
+	_, result = graphqlPost(t, router, `{ items(sort: "-nope") { totalCount } }`, nil)
+	if assert.Len(t, result.Errors, 1) {
+		assert.Contains(t, result.Errors[0].Message, `unsupported column "nope"`)
+		assert.Equal(t, string(apierror.CodeInvalidParameter), result.Errors[0].Extensions["code"])
+	}
+	_, result = graphqlPost(t, router, `{ items(limit: 501) { totalCount } }`, nil)
+	assert.Len(t, result.Errors, 1)
+
+	mockRedis.AssertExpectations(t)
+	mockDB.AssertExpectations(t)
+}
+
+func TestGraphQL_OrdersAndAnalytics(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouterWithConfig(&config.Config{
+		GraphQL: config.GraphQLConfig{Enabled: true},
+	})
+
+	orders := []database.Order{{ID: 7, CustomerID: "customer-1", Amount: 99.5, Status: "PAID"}}
+	mockDB.On("GetOrders", mock.Anything, database.OrderFilter{Status: "PAID", Limit: 10}).Return(orders, nil)
+	customers := []database.TopCustomer{{CustomerID: "customer-1", TotalSpend: 2500.75, OrderCount: 15}}
+	mockDB.On("GetTopCustomers", mock.Anything, database.TopCustomersFilter{Limit: 5}).Return(customers, nil)
+	summaries := []database.OrderStatusSummary{{Status: "PAID", OrderCount: 3, TotalAmount: 120.5}}
+	mockDB.On("GetOrderStatusSummary", mock.Anything, mock.Anything).Return(summaries, nil)
+
+	code, result := graphqlPost(t, router, `{
+		orders(filter: {status: "paid"}, limit: 10) { id customerId amount status }
+		topCustomers { limit customers { customerId totalSpend orderCount } }
+		orderStatusSummary(period: "7d") { period { name groupBy } summaries { status orderCount } }
+	}`, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, result.Errors)
+	assert.JSONEq(t, `[{"id":"7","customerId":"customer-1","amount":99.5,"status":"PAID"}]`, string(result.Data["orders"]))
+	assert.JSONEq(t, `{"limit":5,"customers":[{"customerId":"customer-1","totalSpend":2500.75,"orderCount":15}]}`, string(result.Data["topCustomers"]))
+	assert.JSONEq(t, `{"period":{"name":"7d","groupBy":null},"summaries":[{"status":"PAID","orderCount":3}]}`, string(result.Data["orderStatusSummary"]))
+
+	_, result = graphqlPost(t, router, `{ orders(limit: 0) { id } }`, nil)
+	assert.Len(t, result.Errors, 1)
+	_, result = graphqlPost(t, router, `{ orderStatusSummary(period: "7d", from: "2024-01-01T00:00:00Z") { cached } }`, nil)
+	if assert.Len(t, result.Errors, 1) {
+		assert.Contains(t, result.Errors[0].Message, "from and to require period=custom")
+	}
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestGraphQL_Sync(t *testing.T) {
+	router, _, _, mockJobManager := setupTestRouterWithConfig(&config.Config{
+		GraphQL: config.GraphQLConfig{Enabled: true},
+	})
+
+	mockJobManager.On("StartSync", mock.Anything).Return(&jobs.SyncJob{ID: "job-1", Status: jobs.SyncQueued}, nil).Once()
+	_, result := graphqlPost(t, router, `mutation { sync(async: true) { jobId status } }`, nil)
+	assert.Empty(t, result.Errors)
+	assert.JSONEq(t, `{"jobId":"job-1","status":"`+jobs.SyncQueued+`"}`, string(result.Data["sync"]))
+
+	mockJobManager.On("SyncDataManual", mock.Anything).Return(jobs.ErrSyncInProgress).Once()
+	_, result = graphqlPost(t, router, `mutation { sync { status } }`, nil)
+	if assert.Len(t, result.Errors, 1) {
+		assert.Equal(t, string(apierror.CodeSyncInProgress), result.Errors[0].Extensions["code"])
+		assert.Equal(t, []interface{}{"sync"}, result.Errors[0].Path)
+	}
+
+	mockJobManager.AssertExpectations(t)
+}
+
+func TestGraphQL_Rejected(t *testing.T) {
+	router, _, _, _ := setupTestRouterWithConfig(&config.Config{
+		Auth:    config.AuthConfig{APIKeysEnabled: true},
+		GraphQL: config.GraphQLConfig{Enabled: true},
+	})
+
+	// Authenticated like /api/v1
+	code, _ := graphqlPost(t, router, `{ topCustomers { limit } }`, nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	router, _, _, _ = setupTestRouterWithConfig(&config.Config{GraphQL: config.GraphQLConfig{Enabled: true, MaxDepth: 2}})
+	code, _ = graphqlPost(t, router, "", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, result := graphqlPost(t, router, `{ orderStatusSummary { period { name } } }`, nil)
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, result.Errors, 1) {
+		assert.Contains(t, result.Errors[0].Message, "exceeds max depth 2")
+	}
+
+	// Invalid documents are reported in the errors list
+	code, result = graphqlPost(t, router, `{ nope }`, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, result.Errors, 1)
+	assert.Nil(t, result.Data)
+
+	// Not mounted unless enabled
+	router, _, _, _ = setupTestRouter()
+	code, _ = graphqlPost(t, router, `{ topCustomers { limit } }`, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestGetItems_ExportCSV(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	userID := 1
+	mockDB.On("StreamItems", mock.Anything, database.ItemFilter{UserID: &userID}).Return([]database.Item{
+		{ID: 1, ExternalID: "1", Title: "Plain", Body: "line one\nline two", UserID: 1, CreatedAt: created, UpdatedAt: created},
+		{ID: 2, ExternalID: "2", Title: "=HYPERLINK(\"x\")", Body: "b", UserID: 1, CreatedAt: created, UpdatedAt: created},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/items?user_id=1&format=csv", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, csvContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="items.csv"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "id,external_id,title,body,user_id,created_at,updated_at,deleted_at\n"+
+		"1,1,Plain,\"line one\nline two\",1,2024-01-02T03:04:05Z,2024-01-02T03:04:05Z,\n"+
+		"2,2,\"'=HYPERLINK(\"\"x\"\")\",b,1,2024-01-02T03:04:05Z,2024-01-02T03:04:05Z,\n", w.Body.String())
+
+	// Exports are read from the database, not the cache
+	mockDB.AssertExpectations(t)
+}
+
+func TestGetItems_ExportXLSX(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mockDB.On("StreamItems", mock.Anything, database.ItemFilter{}).Return([]database.Item{
+		{ID: 1, ExternalID: "1", Title: "Item", Body: "Body", UserID: 7, CreatedAt: created, UpdatedAt: created},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/items", nil)
+	req.Header.Set("Accept", xlsxContentType)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, xlsxContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="items.xlsx"`, w.Header().Get("Content-Disposition"))
+
+	f, err := excelize.OpenReader(w.Body)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+	rows, err := f.GetRows("items")
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, itemColumns, rows[0])
+		assert.Equal(t, []string{"1", "1", "Item", "Body", "7"}, rows[1][:5])
+	}
+}
+
+func TestGetItems_StreamNDJSON(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	items := make([]database.Item, flushRows+1)
+	for i := range items {
+		id := int64(i + 1)
+		items[i] = database.Item{ID: id, ExternalID: strconv.FormatInt(id, 10), Title: "<Item>", Body: "Body", UserID: 7, CreatedAt: created, UpdatedAt: created}
+	}
+	mockDB.On("StreamItems", mock.Anything, database.ItemFilter{}).Return(items, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/items", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ndjsonContentType, w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+
+	// Each line is an item as the JSON listing encodes it
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if !assert.Len(t, lines, len(items)) {
+		return
+	}
+	for i, line := range lines {
+		var item database.Item
+		assert.NoError(t, json.Unmarshal([]byte(line), &item))
+		assert.Equal(t, items[i], item)
+	}
+	assert.True(t, strings.HasPrefix(lines[0], `{"id":1,"external_id":"1","title":"\u003cItem\u003e",`), lines[0])
+}
+
+func TestGetItems_ExportFailed(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+	mockDB.On("StreamItems", mock.Anything, database.ItemFilter{}).Return(nil, assert.AnError)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/items?format=csv", nil)
+	router.ServeHTTP(w, req)
+
+	// Nothing was sent yet, so the failure is a normal error response
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, string(apierror.CodeInternal), response["code"])
+}
+
+func TestAnalytics_Export(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+
+	customers := []database.TopCustomer{
+		{CustomerID: "customer-1", TotalSpend: 2500.75, OrderCount: 15},
+		{CustomerID: "customer-2", TotalSpend: 1800, OrderCount: 12},
+	}
+	mockDB.On("GetTopCustomers", mock.Anything, database.TopCustomersFilter{Limit: 2}).Return(customers, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/analytics/customers/top?limit=2&format=csv", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `attachment; filename="top-customers.csv"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	assert.Equal(t, "rank,customer_id,total_spend,order_count\n1,customer-1,2500.75,15\n2,customer-2,1800,12\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/analytics/customers/top?limit=2&format=ndjson", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ndjsonContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"rank":1,"customer_id":"customer-1","total_spend":2500.75,"order_count":15}`+"\n"+
+		`{"rank":2,"customer_id":"customer-2","total_spend":1800,"order_count":12}`+"\n", w.Body.String())
+
+	week := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockDB.On("GetOrderStatusSummary", mock.Anything, mock.Anything).Return([]database.OrderStatusSummary{
+		{PeriodStart: &week, Status: "PAID", OrderCount: 3, TotalAmount: 300.5},
+	}, nil)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/analytics/orders/status?period=7d&group_by=week", nil)
+	req.Header.Set("Accept", "text/csv, application/json;q=0.5")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `attachment; filename="order-status-7d.csv"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "period_start,status,order_count,total_amount\n2024-01-01T00:00:00Z,PAID,3,300.5\n", w.Body.String())
+
+	// An unknown format is rejected before querying
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/analytics/customers/top?format=pdf", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestMaxBodySize(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouterWithConfig(&config.Config{
+		HTTP: config.HTTPConfig{MaxBodySize: 32},
+	})
+	body := `{"customer_id":"customer-1","amount":10}`
+
+	// Declared by Content-Length, and found while reading a chunked body
+	for _, contentLength := range []int64{int64(len(body)), -1} {
+		req := httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(body))
+		req.ContentLength = contentLength
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, contentLength)
+		assert.Contains(t, w.Body.String(), `"code":"REQUEST_TOO_LARGE"`)
+	}
+	mockDB.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+}
+
+func TestTimeout(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouterWithConfig(&config.Config{
+		HTTP: config.HTTPConfig{RequestTimeout: 10},
+	})
+
+	// Routes pass the timeout on to the database
+	withDeadline := mock.MatchedBy(func(ctx context.Context) bool {
+		deadline, ok := ctx.Deadline()
+		return ok && time.Until(deadline) <= 10*time.Second
+	})
+	mockDB.On("GetOrderByID", withDeadline, int64(1)).Return(nil, context.DeadlineExceeded).Once()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/orders/1", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"UPSTREAM_TIMEOUT"`)
+	mockDB.AssertExpectations(t)
+
+	// A handler giving up without responding still gets the error body
+	h, _, _, _ := setupTestHandler(&config.Config{})
+	mux := NewMux()
+	mux.Use(h.renderErrors())
+	mux.Handle(http.MethodGet, "/slow", timeout(10*time.Millisecond), func(c Context) {
+		<-c.Request().Context().Done()
+	})
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"request timed out"`)
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		max        int
+		held       int  // requests already in flight
+		release    bool // the held requests finish while the next one queues
+		panicked   bool // an earlier request panicked while holding a slot
+		wantStatus int
+	}{
+		{name: "free slot", max: 2, held: 1, wantStatus: http.StatusNoContent},
+		{name: "queue timeout", max: 1, held: 1, wantStatus: http.StatusServiceUnavailable},
+		{name: "slot freed while queued", max: 1, held: 1, release: true, wantStatus: http.StatusNoContent},
+		{name: "unlimited", max: 0, held: 3, wantStatus: http.StatusNoContent},
+		{name: "slot freed by panic", max: 1, panicked: true, wantStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _, _, _ := setupTestHandler(&config.Config{})
+			limits := config.ConcurrencyConfig{QueueTimeout: 100, RetryAfter: 7}
+			entered, gate := make(chan struct{}), make(chan struct{})
+			mux := NewMux()
+			mux.Use(h.renderErrors(), h.recoverPanics())
+			mux.Handle(http.MethodGet, "/work", concurrencyLimit(tt.max, limits, h.logger), func(c Context) {
+				switch {
+				case c.Query("panic") != "":
+					panic("boom")
+				case c.Query("hold") != "":
+					entered <- struct{}{}
+					<-gate
+				}
+				c.Status(http.StatusNoContent)
+			})
+
+			if tt.panicked {
+				w := httptest.NewRecorder()
+				mux.ServeHTTP(w, httptest.NewRequest("GET", "/work?panic=1", nil))
+				assert.Equal(t, http.StatusInternalServerError, w.Code)
+			}
+
+			held := make(chan int, tt.held)
+			for i := 0; i < tt.held; i++ {
+				go func() {
+					w := httptest.NewRecorder()
+					mux.ServeHTTP(w, httptest.NewRequest("GET", "/work?hold=1", nil))
+					held <- w.Code
+				}()
+				<-entered
+			}
+			if tt.release {
+				time.AfterFunc(20*time.Millisecond, func() { close(gate) })
+			}
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("GET", "/work", nil))
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusServiceUnavailable {
+				assert.Equal(t, "7", w.Header().Get("Retry-After"))
+				assert.Contains(t, w.Body.String(), `"code":"SERVICE_OVERLOADED"`)
+			}
+
+			if !tt.release {
+				close(gate)
+			}
+			for i := 0; i < tt.held; i++ {
+				assert.Equal(t, http.StatusNoContent, <-held)
+			}
+		})
+	}
+}
+
+func TestIPFilter(t *testing.T) {
+	cfg := &config.Config{
+		Auth: config.AuthConfig{AdminToken: "admin"},
+		IPFilter: config.IPFilterConfig{
+			Deny:   []string{"203.0.113.9"},
+			Routes: []config.IPFilterRoute{{Prefix: "/admin", Allow: []string{"10.0.0.0/8"}}},
+		},
+	}
+	h, _, _, _ := setupTestHandler(cfg)
+	router := NewMux()
+	assert.NoError(t, router.SetTrustedProxies([]string{"10.0.0.1"}))
+	h.Register(router)
+
+	tests := []struct {
+		name         string
+		path         string
+		remoteAddr   string
+		forwardedFor string
+		expectedCode int
+	}{
+		{"unlisted route", "/healthz", "192.0.2.1:1234", "", http.StatusOK},
+		{"denied address", "/healthz", "203.0.113.9:1234", "", http.StatusForbidden},
+		{"admin from outside", "/admin/loglevel", "192.0.2.1:1234", "", http.StatusForbidden},
+		{"admin from inside", "/admin/loglevel", "10.1.2.3:1234", "", http.StatusOK},
+		{"forwarded by trusted proxy", "/admin/loglevel", "10.0.0.1:1234", "10.1.2.3", http.StatusOK},
+		{"denied behind trusted proxy", "/healthz", "10.0.0.1:1234", "203.0.113.9", http.StatusForbidden},
+		{"spoofed hop", "/admin/loglevel", "10.0.0.1:1234", "10.1.2.3, 192.0.2.1", http.StatusForbidden},
+		{"untrusted proxy", "/healthz", "192.0.2.1:1234", "203.0.113.9", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := adminRequest("GET", tt.path, "")
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode == http.StatusForbidden {
+				assert.Contains(t, w.Body.String(), `"code":"FORBIDDEN"`)
+			}
+		})
+	}
+
+	// A reload that drops the lists lets everyone back in
+	h.ApplyConfig(&config.Config{})
+	req := adminRequest("GET", "/admin/loglevel", "")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAPIKeys_Lifecycle(t *testing.T) {
+	router, mockDB, mockRedis, mockJobManager := setupTestRouterWithConfig(&config.Config{
+		Auth: config.AuthConfig{APIKeysEnabled: true, AdminToken: "admin"},
+	})
+	mockJobManager.On("GetSyncJob", mock.Anything, "missing").Return(nil, jobs.ErrSyncJobNotFound)
+
+	var stored *database.APIKey
+	mockDB.On("CreateAPIKey", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*database.APIKey)
+		stored.ID = 9
+	}).Return(nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/admin/api-keys", `{"name":"ci"}`))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Data map[string]interface{} `json:"data"`
+		Key  string                 `json:"key"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	// Only the hash is stored, and the raw key is shown just this once
+	require.NotNil(t, stored)
+	assert.True(t, strings.HasPrefix(created.Key, "gw_"))
+	assert.Equal(t, hashAPIKey(created.Key), stored.KeyHash)
+	assert.NotEqual(t, created.Key, stored.KeyHash)
+	assert.Equal(t, created.Key[:10], stored.KeyPrefix)
+	assert.NotContains(t, created.Data, "key_hash")
+	assert.NotContains(t, w.Body.String(), stored.KeyHash)
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/sync/missing", nil)
+		req.Header.Set(apiKeyHeader, created.Key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The new key authenticates, looked up by its hash
+	cacheKey := apiKeyCachePrefix + stored.KeyHash
+	mockRedis.On("GetJSON", mock.Anything, cacheKey, mock.Anything).Return(goredis.Nil)
+	mockDB.On("GetAPIKeyByHash", mock.Anything, stored.KeyHash).Return(stored, nil).Once()
+	mockRedis.On("SetJSON", mock.Anything, cacheKey, mock.Anything, mock.Anything).Return(nil).Once()
+	assert.Equal(t, http.StatusNotFound, get().Code)
+
+	// Revoking it evicts the cached lookup
+	revoked := *stored
+	now := time.Now()
+	revoked.RevokedAt = &now
+	mockDB.On("RevokeAPIKey", mock.Anything, int64(9), "").Return(&revoked, nil)
+	mockRedis.On("Del", mock.Anything, []string{cacheKey}).Return(nil).Once()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("DELETE", "/admin/api-keys/9", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// so the key stops working at once
+	mockDB.On("GetAPIKeyByHash", mock.Anything, stored.KeyHash).Return(nil, database.ErrNotFound)
+	w = get()
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"UNAUTHORIZED"`)
+
+	mockDB.AssertExpectations(t)
+	mockRedis.AssertExpectations(t)
+}
+
+func TestRBAC(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouterWithConfig(&config.Config{
+		Auth: config.AuthConfig{APIKeysEnabled: true, AdminToken: "admin"},
+		RBAC: config.RBACConfig{Enabled: true, Roles: rbac.DefaultRoles(), DefaultRoles: []string{"reader"}, JWTClaim: "roles"},
+	})
+	keys := map[string]*database.APIKey{
+		"reader-key":   {ID: 1},
+		"operator-key": {ID: 2, Roles: []string{"operator"}},
+		"admin-key":    {ID: 3, Roles: []string{"admin"}},
+	}
+	for raw, key := range keys {
+		mockRedis.On("GetJSON", mock.Anything, apiKeyCachePrefix+hashAPIKey(raw), mock.Anything).Return(goredis.Nil)
+		mockDB.On("GetAPIKeyByHash", mock.Anything, hashAPIKey(raw)).Return(key, nil)
+		mockRedis.On("SetJSON", mock.Anything, apiKeyCachePrefix+hashAPIKey(raw), mock.Anything, mock.Anything).Return(nil)
+	}
+	mockDB.On("ListAuditEvents", mock.Anything, mock.Anything).Return([]database.AuditEvent{}, nil)
+
+	tests := []struct {
+		name         string
+		method, path string
+		apiKey       string
+		adminToken   string
+		expectedCode int
+	}{
+		{"default role lacks permission", "POST", "/api/v1/sync", "reader-key", "", http.StatusForbidden},
+		{"operator lacks admin permission", "GET", "/admin/audit", "operator-key", "", http.StatusForbidden},
+		{"admin role", "GET", "/admin/audit", "admin-key", "", http.StatusOK},
+		{"admin token", "GET", "/admin/audit", "", "admin", http.StatusOK},
+		{"no credentials", "GET", "/admin/audit", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.apiKey != "" {
+				req.Header.Set(apiKeyHeader, tt.apiKey)
+			}
+			if tt.adminToken != "" {
+				req.Header.Set("X-Admin-Token", tt.adminToken)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode == http.StatusForbidden {
+				assert.Contains(t, w.Body.String(), `"code":"FORBIDDEN"`)
+			}
+		})
+	}
+
+	// Denials are audited
+	denied := map[string]interface{}{}
+	for _, call := range mockDB.Calls {
+		if call.Method != "CreateAuditEvent" {
+			continue
+		}
+		if event := call.Arguments.Get(1).(*database.AuditEvent); event.Action == audit.ActionAccessDenied {
+			denied[event.Actor+" "+event.Target] = event.Changes["permission"].To
+		}
+	}
+	assert.Equal(t, map[string]interface{}{
+		"api_key:1 POST /api/v1/sync": rbac.SyncTrigger,
+		"api_key:2 GET /admin/audit":  rbac.AdminAudit,
+	}, denied)
+
+	// Keys can only be given configured roles
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/admin/api-keys", `{"name":"ci","roles":["reader","superuser"]}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"roles[1]"`)
+}
+
+func TestSignedRequests(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouterWithConfig(&config.Config{
+		Auth: config.AuthConfig{
+			APIKeysEnabled:   true,
+			SigningEnabled:   true,
+			SigningTolerance: 300,
+			SigningClients:   []config.SigningClient{{ID: "billing-worker", Secret: "s3cret"}},
+		},
+	})
+	mockDB.On("CreateOrder", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*database.Order).ID = 42
+	}).Return(nil)
+	mockRedis.On("Incr", mock.Anything, "analytics:v").Return(1, nil)
+
+	body := `{"customer_id":"customer-1","amount":10}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	tests := []struct {
+		name         string
+		client       string
+		timestamp    string
+		signedBody   string
+		expectedCode int
+	}{
+		{"valid signature", "billing-worker", now, body, http.StatusCreated},
+		{"tampered body", "billing-worker", now, `{"customer_id":"customer-2","amount":10}`, http.StatusUnauthorized},
+		{"stale timestamp", "billing-worker", stale, body, http.StatusUnauthorized},
+		{"unknown client", "reports", now, body, http.StatusUnauthorized},
+		{"unsigned", "", now, body, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(body))
+			if tt.client != "" {
+				req.Header.Set(signing.ClientHeader, tt.client)
+				req.Header.Set(signing.TimestampHeader, tt.timestamp)
+				req.Header.Set(signing.SignatureHeader, signing.Sign("s3cret", tt.timestamp, "POST", "/api/v1/orders", []byte(tt.signedBody)))
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+		})
+	}
+
+	// The handler still reads the body, and the client is the actor
+	mockDB.AssertCalled(t, "CreateOrder", mock.Anything, mock.MatchedBy(func(order *database.Order) bool {
+		return order.CustomerID == "customer-1"
+	}))
+	mockDB.AssertCalled(t, "CreateAuditEvent", mock.Anything, mock.MatchedBy(func(event *database.AuditEvent) bool {
+		return event.Action == audit.ActionOrderCreate && event.Actor == "client:billing-worker"
+	}))
+	mockDB.AssertNumberOfCalls(t, "CreateOrder", 1)
+}
+
+func TestSignedRequests_OnlyAuth(t *testing.T) {
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			SigningEnabled:   true,
+			SigningTolerance: 300,
+			SigningClients:   []config.SigningClient{{ID: "billing-worker", Secret: "s3cret"}},
+		},
+		RBAC: config.RBACConfig{Enabled: true, Roles: rbac.DefaultRoles(), DefaultRoles: []string{"operator"}},
+	}
+	router, mockDB, _, _ := setupTestRouterWithConfig(cfg)
+
+	// With signing the only credential, an unsigned request isn't anonymous
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/orders", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "missing request signature")
+	mockDB.AssertNotCalled(t, "GetOrders", mock.Anything, mock.Anything)
+
+	// gRPC calls can't be signed, so they're refused
+	h, _, _, _ := setupTestHandler(cfg)
+	_, err := dialGRPC(t, h).GetItem(context.Background(), &gatewaypb.GetItemRequest{Id: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	keyFile := filepath.Join(t.TempDir(), "jwt.pem")
+	require.NoError(t, os.WriteFile(keyFile, publicPEM, 0o600))
+
+	newRouter := func(auth config.AuthConfig) http.Handler {
+		auth.JWTEnabled, auth.JWTIssuer, auth.JWTAudience = true, "auth.example.com", "gateway"
+		h, mockDB, mockRedis, _ := setupTestHandler(&config.Config{Auth: auth})
+		jwtAuth, err := newJWTAuthenticator(auth)
+		require.NoError(t, err)
+		h.jwtAuth = jwtAuth
+		router := NewMux()
+		h.Register(router)
+		mockDB.On("CreateOrder", mock.Anything, mock.Anything).Return(nil)
+		mockRedis.On("Incr", mock.Anything, "analytics:v").Return(1, nil)
+		return router
+	}
+	hs256 := newRouter(config.AuthConfig{JWTAlgorithm: "HS256", JWTSecret: "s3cret"})
+	rs256 := newRouter(config.AuthConfig{JWTAlgorithm: "RS256", JWTPublicKeyFile: keyFile})
+
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{"iss": "auth.example.com", "aud": "gateway", "sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+		for name, value := range overrides {
+			if value == nil {
+				delete(c, name)
+			} else {
+				c[name] = value
+			}
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+		signed, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+	hsToken := func(overrides jwt.MapClaims) string {
+		return "Bearer " + sign(jwt.SigningMethodHS256, []byte("s3cret"), claims(overrides))
+	}
+
+	const invalidToken = `Bearer error="invalid_token"`
+	tests := []struct {
+		name          string
+		router        http.Handler
+		authorization string
+		expectedCode  int
+		challenge     string // expected WWW-Authenticate
+	}{
+		{"valid HS256 token", hs256, hsToken(nil), http.StatusCreated, ""},
+		{"valid RS256 token", rs256, "Bearer " + sign(jwt.SigningMethodRS256, key, claims(nil)), http.StatusCreated, ""},
+		{"expired", hs256, hsToken(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), http.StatusUnauthorized, invalidToken},
+		{"missing exp", hs256, hsToken(jwt.MapClaims{"exp": nil}), http.StatusUnauthorized, invalidToken},
+		{"other issuer", hs256, hsToken(jwt.MapClaims{"iss": "evil.example.com"}), http.StatusUnauthorized, invalidToken},
+		{"other audience", hs256, hsToken(jwt.MapClaims{"aud": "billing"}), http.StatusUnauthorized, invalidToken},
+		{"wrong secret", hs256, "Bearer " + sign(jwt.SigningMethodHS256, []byte("guess"), claims(nil)), http.StatusUnauthorized, invalidToken},
+		// An HS256 token keyed with the RSA public key must not pass as RS256
+		{"HS256 against RS256", rs256, "Bearer " + sign(jwt.SigningMethodHS256, publicPEM, claims(nil)), http.StatusUnauthorized, invalidToken},
+		{"alg none", hs256, "Bearer " + sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claims(nil)), http.StatusUnauthorized, invalidToken},
+		{"malformed token", hs256, "Bearer not.a.jwt", http.StatusUnauthorized, invalidToken},
+		{"missing header", hs256, "", http.StatusUnauthorized, "Bearer"},
+		{"empty bearer", hs256, "Bearer ", http.StatusUnauthorized, "Bearer"},
+		{"other scheme", hs256, "Basic YWxpY2U6czNjcmV0", http.StatusUnauthorized, "Bearer"},
+		{"token without scheme", hs256, strings.TrimPrefix(hsToken(nil), "Bearer "), http.StatusUnauthorized, "Bearer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(`{"customer_id":"customer-1","amount":10}`))
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			tt.router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+			assert.Equal(t, tt.challenge, w.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestOIDC(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var idp *httptest.Server
+	idp = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(H{"issuer": idp.URL, "jwks_uri": idp.URL + "/certs"})
+		case "/certs":
+			json.NewEncoder(w).Encode(H{"keys": []H{{
+				"kty": "RSA", "kid": "key-1", "use": "sig",
+				"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer idp.Close()
+
+	cfg := &config.Config{Auth: config.AuthConfig{
+		APIKeysEnabled: true, OIDCEnabled: true, OIDCIssuer: idp.URL, OIDCAudience: "gateway", OIDCJWKSCacheTTL: 3600,
+	}}
+	h, mockDB, mockRedis, _ := setupTestHandler(cfg)
+	h.jwtAuth = newOIDCAuthenticator(cfg.Auth)
+	router := NewMux()
+	h.Register(router)
+	mockDB.On("CreateOrder", mock.Anything, mock.Anything).Return(nil)
+	mockRedis.On("Incr", mock.Anything, "analytics:v").Return(1, nil)
+
+	token := func(kid, aud string) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss": idp.URL, "aud": aud, "sub": "alice", "exp": time.Now().Add(time.Hour).Unix(),
+		})
+		tok.Header["kid"] = kid
+		signed, err := tok.SignedString(key)
+		assert.NoError(t, err)
+		return signed
+	}
+	tests := []struct {
+		name         string
+		token        string
+		expectedCode int
+	}{
+		{"provider token", token("key-1", "gateway"), http.StatusCreated},
+		{"other audience", token("key-1", "billing"), http.StatusUnauthorized},
+		{"unknown key", token("key-2", "gateway"), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(`{"customer_id":"customer-1","amount":10}`))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+		})
+	}
+
+	mockDB.AssertCalled(t, "CreateAuditEvent", mock.Anything, mock.MatchedBy(func(event *database.AuditEvent) bool {
+		return event.Action == audit.ActionOrderCreate && event.Actor == "user:alice"
+	}))
+
+	// API keys are still accepted alongside provider tokens
+	mockRedis.On("GetJSON", mock.Anything, apiKeyCachePrefix+hashAPIKey("gw_key"), mock.Anything).Return(goredis.Nil)
+	mockDB.On("GetAPIKeyByHash", mock.Anything, hashAPIKey("gw_key")).Return(&database.APIKey{ID: 7}, nil)
+	mockRedis.On("SetJSON", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	req := httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(`{"customer_id":"customer-1","amount":10}`))
+	req.Header.Set(apiKeyHeader, "gw_key")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestTenancy(t *testing.T) {
+	cfg := &config.Config{
+		Auth:    config.AuthConfig{APIKeysEnabled: true, JWTEnabled: true, JWTAlgorithm: "HS256", JWTSecret: "s3cret", AdminToken: "admin"},
+		Tenancy: config.TenancyConfig{Enabled: true, JWTClaim: "tenant_id"},
+	}
+	h, mockDB, mockRedis, _ := setupTestHandler(cfg)
+	jwtAuth, err := newJWTAuthenticator(cfg.Auth)
+	if !assert.NoError(t, err) {
+		return
+	}
+	h.jwtAuth = jwtAuth
+	router := NewMux()
+	h.Register(router)
+
+	var tenants []string
+	mockDB.On("CreateOrder", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		tenants = append(tenants, tenant.From(args.Get(0).(context.Context)))
+	}).Return(nil)
+	mockRedis.On("Incr", mock.Anything, mock.Anything).Return(1, nil)
+	mockRedis.On("GetJSON", mock.Anything, apiKeyCachePrefix+hashAPIKey("acme_key"), mock.Anything).Return(goredis.Nil)
+	mockDB.On("GetAPIKeyByHash", mock.Anything, hashAPIKey("acme_key")).Return(&database.APIKey{ID: 7, TenantID: "acme"}, nil)
+	mockRedis.On("SetJSON", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	token := func(claims jwt.MapClaims) string {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("s3cret"))
+		assert.NoError(t, err)
+		return signed
+	}
+	tests := []struct {
+		name         string
+		header, auth string
+		expectedCode int
+	}{
+		{"API key tenant", apiKeyHeader, "acme_key", http.StatusCreated},
+		{"token tenant", "Authorization", "Bearer " + token(jwt.MapClaims{"sub": "alice", "tenant_id": "globex"}), http.StatusCreated},
+		{"token without tenant", "Authorization", "Bearer " + token(jwt.MapClaims{"sub": "bob"}), http.StatusForbidden},
+		{"token with invalid tenant", "Authorization", "Bearer " + token(jwt.MapClaims{"sub": "eve", "tenant_id": "../acme"}), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(`{"customer_id":"customer-1","amount":10}`))
+			req.Header.Set(tt.header, tt.auth)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+		})
+	}
+
+	// Orders are written within the caller's tenant and nobody else's
+	assert.Equal(t, []string{"acme", "globex"}, tenants)
+
+	// Keys are issued for a tenant, the default one unless named
+	mockDB.On("CreateAPIKey", mock.Anything, mock.Anything).Return(nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/admin/api-keys", `{"name":"ci","tenant_id":"Acme Corp"}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"tenant_id"`)
+	for body, expected := range map[string]string{`{"name":"ci"}`: tenant.Default, `{"name":"ci","tenant_id":"acme"}`: "acme"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest("POST", "/admin/api-keys", body))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"tenant_id":"`+expected+`"`)
+	}
+}
+
+func TestAdminRoutes_TenantScoped(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouterWithConfig(&config.Config{
+		Auth:    config.AuthConfig{APIKeysEnabled: true, AdminToken: "admin"},
+		RBAC:    config.RBACConfig{Enabled: true, Roles: rbac.DefaultRoles()},
+		Tenancy: config.TenancyConfig{Enabled: true},
+	})
+	mockRedis.On("GetJSON", mock.Anything, apiKeyCachePrefix+hashAPIKey("acme-admin"), mock.Anything).Return(goredis.Nil)
+	mockDB.On("GetAPIKeyByHash", mock.Anything, hashAPIKey("acme-admin")).Return(&database.APIKey{ID: 1, Roles: []string{"admin"}, TenantID: "acme"}, nil)
+	mockRedis.On("SetJSON", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("CreateAPIKey", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("ListAPIKeys", mock.Anything, "acme").Return([]database.APIKey{}, nil)
+	mockDB.On("ListAPIKeys", mock.Anything, "").Return([]database.APIKey{}, nil)
+	mockDB.On("RevokeAPIKey", mock.Anything, int64(5), "acme").Return(nil, database.ErrNotFound)
+	mockDB.On("ListWebhookSubscriptions", mock.Anything).Return([]database.WebhookSubscription{}, nil)
+
+	tests := []struct {
+		name         string
+		method, path string
+		body         string
+		adminToken   bool
+		expectedCode int
+	}{
+		{"key for own tenant", "POST", "/admin/api-keys", `{"name":"ci"}`, false, http.StatusCreated},
+		{"key for another tenant", "POST", "/admin/api-keys", `{"name":"ci","tenant_id":"globex"}`, false, http.StatusForbidden},
+		{"own tenant's keys", "GET", "/admin/api-keys", "", false, http.StatusOK},
+		{"another tenant's key", "DELETE", "/admin/api-keys/5", "", false, http.StatusNotFound},
+		{"webhooks", "GET", "/admin/webhooks", "", false, http.StatusForbidden},
+		{"every tenant's keys with the admin token", "GET", "/admin/api-keys", "", true, http.StatusOK},
+		{"webhooks with the admin token", "GET", "/admin/webhooks", "", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := adminRequest(tt.method, tt.path, tt.body)
+			if !tt.adminToken {
+				req.Header.Del("X-Admin-Token")
+				req.Header.Set(apiKeyHeader, "acme-admin")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+		})
+	}
+
+	// A key issued by a tenant's admin belongs to that tenant
+	mockDB.AssertCalled(t, "CreateAPIKey", mock.Anything, mock.MatchedBy(func(key *database.APIKey) bool {
+		return key.Name == "ci" && key.TenantID == "acme"
+	}))
+	mockDB.AssertNumberOfCalls(t, "CreateAPIKey", 1)
+	mockDB.AssertCalled(t, "ListAPIKeys", mock.Anything, "acme")
+	mockDB.AssertCalled(t, "ListAPIKeys", mock.Anything, "")
+}
+
+func TestClaimRoles(t *testing.T) {
+	assert.Equal(t, []string{"reader", "operator"}, claimRoles(jwt.MapClaims{"roles": []interface{}{"reader", "operator"}}, "roles"))
+	assert.Equal(t, []string{"reader", "operator"}, claimRoles(jwt.MapClaims{"scope": "reader operator"}, "scope"))
+	assert.Empty(t, claimRoles(jwt.MapClaims{}, "roles"))
+}
+
+func TestResponseEnvelope(t *testing.T) {
+	router, mockDB, _, _ := setupTestRouter()
+
+	runs := []database.SyncRun{{ID: 2, Trigger: "scheduled", Status: "succeeded"}}
+	mockDB.On("ListSyncRuns", mock.Anything, 1, 3).Return(runs, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/sync/history?limit=1&offset=3", nil)
+	req.Header.Set("Accept-Version", "2")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Version")
+
+	var response struct {
+		Data []database.SyncRun `json:"data"`
+		Meta map[string]interface{}
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, runs[0].ID, response.Data[0].ID)
+	assert.Equal(t, map[string]interface{}{"count": 1.0, "limit": 1.0, "offset": 3.0}, response.Meta["pagination"])
+	assert.Equal(t, w.Header().Get("X-Request-ID"), response.Meta["request_id"])
+	assert.NotEmpty(t, response.Meta["timestamp"])
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestResponseEnvelope_MessageOnly(t *testing.T) {
+	router, _, _, mockJobManager := setupTestRouterWithConfig(&config.Config{Auth: config.AuthConfig{AdminToken: "admin"}})
+	mockJobManager.On("ResumeJob", mock.Anything, "sync").Return(nil)
+
+	for version, want := range map[string]string{"1": `"message":"job resumed"`, "2": `"meta":{"message":"job resumed"`} {
+		w := httptest.NewRecorder()
+		req := adminRequest("POST", "/admin/jobs/sync/resume", "")
+		req.Header.Set("Accept-Version", version)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), want)
+		assert.NotContains(t, w.Body.String(), `"data"`)
+	}
+}
+
+func TestResponseEnvelope_Error(t *testing.T) {
+	router, mockDB, mockRedis, _ := setupTestRouter()
+
+	mockRedis.On("GetJSON", mock.Anything, "items:v0:id:42", mock.Anything).Return(assert.AnError)
+	mockDB.On("GetItemByID", mock.Anything, int64(42)).Return(nil, database.ErrNotFound)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/items/42", nil)
+	req.Header.Set("Accept-Version", "2")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var response map[string]map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotContains(t, response, "data")
+	assert.Equal(t, "ITEM_NOT_FOUND", response["error"]["code"])
+	assert.Equal(t, "item not found", response["error"]["message"])
+	assert.Equal(t, "no item with id 42", response["error"]["cause"])
+	assert.NotEmpty(t, response["meta"]["request_id"])
+}
+
+func TestResponseEnvelope_UnsupportedVersion(t *testing.T) {
+	router, _, _, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/version", nil)
+	req.Header.Set("Accept-Version", "3")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "unsupported API version", response["error"])
+	assert.Equal(t, "INVALID_REQUEST", response["code"])
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/response"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchQuery     = 200
+
+	// snippetRadius is roughly how many bytes of body a snippet shows on
+	// each side of the first match
+	snippetRadius = 80
+)
+
+// Highlighted matches are wrapped in these tags
+const (
+	highlightOpen  = "<mark>"
+	highlightClose = "</mark>"
+)
+
+// searchResult is an item found by GET /api/v1/items/search
+type searchResult struct {
+	Item       database.Item    `json:"item"`
+	Score      float64          `json:"score"`
+	Highlights searchHighlights `json:"highlights"`
+}
+
+// searchHighlights are the matched fields of a search result, HTML-escaped
+// with each match of a search term wrapped in <mark>. Body is a snippet
+// around its first match, empty if only the title matched.
+type searchHighlights struct {
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+}
+
+// searchItems handles GET /api/v1/items/search?q=, full-text search over
+// item titles and bodies, most relevant first
+func (h *Handler) searchItems(c Context) {
+	search, err := parseItemSearch(c)
+	if err != nil {
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", err))
+		return
+	}
+
+	matches, err := h.db.SearchItems(c.Request().Context(), search)
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to search items")
+		abortWithError(c, apierror.Internal("failed to search items", err))
+		return
+	}
+
+	re := termsPattern(search.Query)
+	results := make([]searchResult, len(matches))
+	for i, m := range matches {
+		results[i] = searchResult{
+			Item:  m.Item,
+			Score: m.Score,
+			Highlights: searchHighlights{
+				Title: highlight(m.Item.Title, re),
+				Body:  snippet(m.Item.Body, re),
+			},
+		}
+	}
+
+	respond(c, http.StatusOK, reply{Data: results, Meta: response.Meta{
+		Pagination: page(len(results), search.Limit, search.Offset),
+		Filters:    H{"query": search.Query},
+	}})
+}
+
+// parseItemSearch reads the search query, limit and offset
+func parseItemSearch(c Context) (database.ItemSearch, error) {
+	search := database.ItemSearch{Query: strings.TrimSpace(c.Query("q")), Limit: defaultSearchLimit}
+	if search.Query == "" {
+		return database.ItemSearch{}, errors.New("q is required")
+	}
+	if utf8.RuneCountInString(search.Query) > maxSearchQuery {
+		return database.ItemSearch{}, fmt.Errorf("q must be at most %d characters", maxSearchQuery)
+	}
+
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxSearchLimit {
+			return database.ItemSearch{}, fmt.Errorf("limit must be between 1 and %d", maxSearchLimit)
+		}
+		search.Limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return database.ItemSearch{}, errors.New("offset must be a non-negative integer")
+		}
+		search.Offset = n
+	}
+	return search, nil
+}
+
+// termsPattern returns a case-insensitive pattern matching any word of q,
+// longest first so the longest of overlapping terms is highlighted
+func termsPattern(q string) *regexp.Regexp {
+	terms := strings.Fields(q)
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	for i, term := range terms {
+		terms[i] = regexp.QuoteMeta(term)
+	}
+	return regexp.MustCompile("(?i)" + strings.Join(terms, "|"))
+}
+
+// highlight HTML-escapes s and wraps each match of re in <mark>
+func highlight(s string, re *regexp.Regexp) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(s, -1) {
+		b.WriteString(html.EscapeString(s[last:loc[0]]))
+		b.WriteString(highlightOpen + html.EscapeString(s[loc[0]:loc[1]]) + highlightClose)
+		last = loc[1]
+	}
+	b.WriteString(html.EscapeString(s[last:]))
+	return b.String()
+}
+
+// snippet returns the highlighted part of body around the first match of
+// re, cut at spaces and marked with ellipses where body goes on, or ""
+// if nothing in body matches
+func snippet(body string, re *regexp.Regexp) string {
+	loc := re.FindStringIndex(body)
+	if loc == nil {
+		return ""
+	}
+
+	start := max(loc[0]-snippetRadius, 0)
+	for start > 0 && !utf8.RuneStart(body[start]) {
+		start++
+	}
+	if i := strings.IndexByte(body[start:loc[0]], ' '); start > 0 && i >= 0 {
+		start += i + 1
+	}
+	end := min(loc[1]+snippetRadius, len(body))
+	for end < len(body) && !utf8.RuneStart(body[end]) {
+		end++
+	}
+	if i := strings.LastIndexByte(body[loc[1]:end], ' '); end < len(body) && i >= 0 {
+		end = loc[1] + i
+	}
+
+	s := highlight(body[start:end], re)
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(body) {
+		s += "…"
+	}
+	return s
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"api-gateway-backend/internal/analytics"
+	"api-gateway-backend/internal/cache"
+	"api-gateway-backend/internal/database"
+)
+
+// The queries below are shared by the HTTP handlers and the gRPC server, so
+// both transports serve the same caches and aggregates. Each reports
+// whether its result came from a cache.
+
+// listItems returns the items matching filter, cached per filter. An empty
+// result is only cached for the negative TTL. Items loaded from the
+// database are also cached one by one for findItem.
+func (h *Handler) listItems(ctx context.Context, filter database.ItemFilter) ([]database.Item, bool, error) {
+	// The list and its items are cached at the same version, so a bump
+	// during the load can't leave them cached under the new one
+	versioned := h.versions.At(ctx, itemsCacheNamespace)
+
+	// On a miss only one request per key queries the database
+	var items []database.Item
+	cached, err := h.cache.GetOrLoad(ctx, versioned(itemsCacheKeyFor(filter)), itemsCacheTTL, &items, func(ctx context.Context) (interface{}, error) {
+		items, err := h.db.GetItems(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 {
+			return h.negative(items, itemsCacheTTL), nil
+		}
+		h.cacheItems(ctx, versioned, items)
+		return items, nil
+	})
+	return items, cached, err
+}
+
+// cacheItems caches each of items at its findItem key in one round trip,
+// so a list load or cache warm also warms the items in it. Deleted items
+// are skipped, as findItem doesn't serve them. Failures are only logged,
+// since the list is still served.
+func (h *Handler) cacheItems(ctx context.Context, versioned func(key string) string, items []database.Item) {
+	values := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		if item.DeletedAt == nil {
+			values[versioned(itemCacheKeyFor(item.ID))] = item
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+	if err := h.cache.SetMany(ctx, values, itemsCacheTTL); err != nil {
+		h.logger.FromContext(ctx).WithError(err).WithField("items", len(items)).Warn("Failed to cache items")
+	}
+}
+
+// findItem returns the item with id, cached per item. It returns
+// database.ErrNotFound if there is none; that is cached too, as null, for
+// the negative TTL.
+func (h *Handler) findItem(ctx context.Context, id int64) (database.Item, bool, error) {
+	var item database.Item
+	cached, err := h.cache.GetOrLoad(ctx, h.versions.Key(ctx, itemCacheKeyFor(id)), itemsCacheTTL, &item, func(ctx context.Context) (interface{}, error) {
+		item, err := h.db.GetItemByID(ctx, id)
+		if errors.Is(err, database.ErrNotFound) {
+			return h.negative(nil, itemsCacheTTL), nil
+		}
+		return item, err
+	})
+	if err == nil && item.ID == 0 {
+		err = database.ErrNotFound
+	}
+	return item, cached, err
+}
+
+// negative caches value, a missing or empty result, for the negative TTL,
+// or for ttl if that is shorter, so lookups of missing data don't all
+// reach the database while it stays missing
+func (h *Handler) negative(value interface{}, ttl time.Duration) cache.Expiring {
+	if negative := h.live.Load().negativeTTL; negative < ttl {
+		ttl = negative
+	}
+	return cache.Expiring{Value: value, TTL: ttl}
+}
+
+// orderStatusSummary returns order counts and totals by status. Ungrouped
+// preset periods within the aggregate window are served from Redis; other
+// preset periods, or those if the aggregates aren't ready, are served from
+// order_stats while the order stats job keeps it fresh, reporting when it
+// was computed. Custom ranges and anything else query the database, with
+// results cached briefly per parameter set.
+func (h *Handler) orderStatusSummary(ctx context.Context, period reportPeriod, filter database.OrderStatusFilter) ([]database.OrderStatusSummary, *time.Time, bool, error) {
+	days := presetPeriods[period.Name]
+	if days > 0 && days <= analytics.StatusWindowDays && filter.GroupBy == "" {
+		summaries, err := h.analytics.OrderStatusSummary(ctx, days)
+		if err == nil {
+			return summaries, nil, true, nil
+		}
+		if !errors.Is(err, analytics.ErrNotReady) {
+			h.logger.FromContext(ctx).WithError(err).Warn("Failed to read order status aggregates")
+		}
+	}
+
+	if days > 0 && h.cfg.Jobs.OrderStatsEnabled {
+		summaries, computedAt, err := h.db.GetOrderStatsSummary(ctx, filter)
+		maxAge := time.Duration(h.cfg.Jobs.OrderStatsMaxAge) * time.Second
+		switch {
+		case err == nil && time.Since(computedAt) <= maxAge:
+			return summaries, &computedAt, true, nil
+		case err == nil:
+			h.logger.FromContext(ctx).WithField("computed_at", computedAt).Debug("Order stats stale, querying orders")
+		case !errors.Is(err, database.ErrNotFound):
+			h.logger.FromContext(ctx).WithError(err).Warn("Failed to read order stats")
+		}
+	}
+
+	var summaries []database.OrderStatusSummary
+	ttl := h.live.Load().orderStatusTTL
+	cached, err := h.loadAnalytics(ctx, orderStatusCacheKeyFor(period), ttl, &summaries, func(ctx context.Context) (interface{}, error) {
+		summaries, err := h.db.GetOrderStatusSummary(ctx, filter)
+		if err == nil && len(summaries) == 0 {
+			return h.negative(summaries, ttl), nil
+		}
+		return summaries, err
+	})
+	return summaries, nil, cached, err
+}
+
+// topCustomers ranks customers by spend. All-time rankings are served from
+// the Redis aggregates, falling back to the database; a from/to window
+// always queries the database. Database results are cached briefly per
+// parameter set.
+func (h *Handler) topCustomers(ctx context.Context, filter database.TopCustomersFilter) ([]database.TopCustomer, bool, error) {
+	if filter.CreatedFrom == nil && filter.CreatedTo == nil {
+		customers, err := h.analytics.TopCustomers(ctx, filter.Limit)
+		if err == nil {
+			return customers, true, nil
+		}
+		if !errors.Is(err, analytics.ErrNotReady) {
+			h.logger.FromContext(ctx).WithError(err).Warn("Failed to read customer aggregates")
+		}
+	}
+
+	var customers []database.TopCustomer
+	ttl := h.live.Load().topCustomersTTL
+	cached, err := h.loadAnalytics(ctx, topCustomersCacheKeyFor(filter), ttl, &customers, func(ctx context.Context) (interface{}, error) {
+		customers, err := h.db.GetTopCustomers(ctx, filter)
+		if err == nil && len(customers) == 0 {
+			return h.negative(customers, ttl), nil
+		}
+		return customers, err
+	})
+	return customers, cached, err
+}
+
+// customerDetail summarizes a customer's orders, always from the database
+// since the aggregates only rank customers. Results are cached briefly per
+// customer and window, like top customers, including a customer without
+// orders for the negative TTL; either way database.ErrNotFound is returned
+// for one.
+func (h *Handler) customerDetail(ctx context.Context, customerID string, since time.Time) (*database.CustomerDetail, bool, error) {
+	var detail database.CustomerDetail
+	ttl := h.live.Load().topCustomersTTL
+	cached, err := h.loadAnalytics(ctx, customerDetailCacheKeyFor(customerID, since), ttl, &detail, func(ctx context.Context) (interface{}, error) {
+		detail, err := h.db.GetCustomerDetail(ctx, customerID, since)
+		if errors.Is(err, database.ErrNotFound) {
+			return h.negative(nil, ttl), nil
+		}
+		return detail, err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if detail.CustomerID == "" {
+		return nil, cached, database.ErrNotFound
+	}
+	return &detail, cached, nil
+}
+
+// itemStats counts items in total, per user and per day. Unlike the order
+// analytics there are no aggregates to serve them from, so results are
+// cached briefly per parameter set, in the items namespace so a sync that
+// changes items retires them.
+func (h *Handler) itemStats(ctx context.Context, filter database.ItemStatsFilter) (*database.ItemStats, bool, error) {
+	var stats database.ItemStats
+	cached, err := h.loadAnalytics(ctx, itemStatsCacheKeyFor(filter), h.live.Load().itemStatsTTL, &stats, func(ctx context.Context) (interface{}, error) {
+		return h.db.GetItemStats(ctx, filter)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &stats, cached, nil
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/audit"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/response"
+	"api-gateway-backend/internal/webhooks"
+)
+
+const (
+	defaultWebhookDeliveriesLimit = 20
+	maxWebhookDeliveriesLimit     = 100
+)
+
+// webhookPublisher queues events for webhook subscribers
+type webhookPublisher interface {
+	Publish(ctx context.Context, eventType string, data interface{}) (int, error)
+}
+
+// createWebhookRequest is the body of POST /admin/webhooks
+type createWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,http_url,max=2048"`
+	Events []string `json:"events" validate:"dive,webhook_event"`
+	Secret string   `json:"secret" validate:"omitempty,min=16,max=255"`
+}
+
+// createdWebhook is a new subscription with the secret its deliveries are
+// signed with, which is only ever shown in the response that creates it
+type createdWebhook struct {
+	*database.WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+// normalize trims the URL
+func (r *createWebhookRequest) normalize() {
+	r.URL = strings.TrimSpace(r.URL)
+}
+
+// toSubscription converts a validated request to a subscription, dropping
+// repeated events
+func (r *createWebhookRequest) toSubscription() *database.WebhookSubscription {
+	var events []string
+	seen := make(map[string]bool)
+	for _, e := range r.Events {
+		if !seen[e] {
+			seen[e] = true
+			events = append(events, e)
+		}
+	}
+	return &database.WebhookSubscription{URL: r.URL, Events: events, Secret: r.Secret}
+}
+
+// isWebhookEventType reports whether e is an event subscribers can receive
+func isWebhookEventType(e string) bool {
+	for _, t := range webhooks.EventTypes {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// createWebhook handles POST /admin/webhooks. Without a secret in the
+// request one is generated; either way it is only returned in this
+// response.
+func (h *Handler) createWebhook(c Context) {
+	var req createWebhookRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	sub := req.toSubscription()
+	if sub.Secret == "" {
+		var err error
+		if sub.Secret, err = generateWebhookSecret(); err != nil {
+			h.log(c).WithError(err).Error("Failed to generate webhook secret")
+			abortWithError(c, apierror.Internal("failed to create webhook", err))
+			return
+		}
+	}
+
+	if err := h.db.CreateWebhookSubscription(c.Request().Context(), sub); err != nil {
+		h.log(c).WithError(err).Error("Failed to store webhook subscription")
+		abortWithError(c, apierror.Internal("failed to create webhook", err))
+		return
+	}
+
+	h.log(c).WithFields(map[string]interface{}{
+		"id":  sub.ID,
+		"url": sub.URL,
+	}).Info("Webhook subscription created")
+	h.audit(c, audit.ActionWebhookCreate, "webhook:"+strconv.FormatInt(sub.ID, 10), nil, sub)
+	respond(c, http.StatusCreated, reply{
+		Data: createdWebhook{WebhookSubscription: sub, Secret: sub.Secret},
+		V1:   H{"data": sub, "secret": sub.Secret},
+	})
+}
+
+// listWebhooks handles GET /admin/webhooks
+func (h *Handler) listWebhooks(c Context) {
+	subs, err := h.db.ListWebhookSubscriptions(c.Request().Context())
+	if err != nil {
+		h.log(c).WithError(err).Error("Failed to list webhook subscriptions")
+		abortWithError(c, apierror.Internal("failed to retrieve webhooks", err))
+		return
+	}
+
+	respond(c, http.StatusOK, reply{Data: subs, Meta: response.Meta{Pagination: &response.Pagination{Count: len(subs)}}})
+}
+
+// disableWebhook handles DELETE /admin/webhooks/:id. The subscription and
+// its delivery log are kept; pending deliveries are cancelled.
+func (h *Handler) disableWebhook(c Context) {
+	ctx := c.Request().Context()
+
+	id, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	sub, err := h.db.DisableWebhookSubscription(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		abortWithError(c, apierror.NotFound(apierror.CodeWebhookNotFound, "webhook not found", fmt.Errorf("no active webhook with id %d", id)))
+		return
+	}
+	if err != nil {
+		h.log(c).WithError(err).WithField("id", id).Error("Failed to disable webhook subscription")
+		abortWithError(c, apierror.Internal("failed to disable webhook", err))
+		return
+	}
+
+	h.log(c).WithField("id", id).Info("Webhook subscription disabled")
+	h.audit(c, audit.ActionWebhookDisable, "webhook:"+strconv.FormatInt(id, 10), H{"disabled_at": nil}, H{"disabled_at": sub.DisabledAt})
+	respond(c, http.StatusOK, reply{Data: sub})
+}
+
+// listWebhookDeliveries handles GET /admin/webhooks/:id/deliveries
+func (h *Handler) listWebhookDeliveries(c Context) {
+	ctx := c.Request().Context()
+
+	id, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	limit, offset := defaultWebhookDeliveriesLimit, 0
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxWebhookDeliveriesLimit {
+			abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", fmt.Errorf("limit must be between 1 and %d", maxWebhookDeliveriesLimit)))
+			return
+		}
+		limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid query parameter", errors.New("offset must be a non-negative integer")))
+			return
+		}
+		offset = n
+	}
+
+	deliveries, err := h.db.ListWebhookDeliveries(ctx, id, limit, offset)
+	if err != nil {
+		h.log(c).WithError(err).WithField("id", id).Error("Failed to list webhook deliveries")
+		abortWithError(c, apierror.Internal("failed to retrieve webhook deliveries", err))
+		return
+	}
+
+	respond(c, http.StatusOK, reply{Data: deliveries, Meta: response.Meta{Pagination: page(len(deliveries), limit, offset)}})
+}
+
+// webhookIDParam parses the :id parameter, aborting with 400 if it isn't a
+// positive integer
+func webhookIDParam(c Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidParameter, "invalid webhook id", errors.New("id must be a positive integer")))
+		return 0, false
+	}
+	return id, true
+}
+
+// publishWebhook queues an event for webhook subscribers. Notifications
+// are best effort: failures are logged and never fail the request.
+func (h *Handler) publishWebhook(c Context, eventType string, data interface{}) {
+	if h.webhooks == nil {
+		return
+	}
+
+	log := h.log(c).WithField("event_type", eventType)
+	queued, err := h.webhooks.Publish(c.Request().Context(), eventType, data)
+	if err != nil {
+		log.WithError(err).Warn("Failed to queue webhook deliveries")
+		return
+	}
+	if queued > 0 {
+		log.WithField("deliveries", queued).Debug("Queued webhook deliveries")
+	}
+}
+
+// generateWebhookSecret returns a new random signing secret
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+
+	"api-gateway-backend/internal/tenant"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tenantKey is the context key holding the tenant the request acts for
+const tenantKey = "tenant"
+
+// enterTenant makes the request act for the tenant its credentials belong
+// to, by attaching it to the request's context.Context: the database, cache
+// and idempotency layers scope everything they read and write by it. With
+// tenancy disabled every request acts for the default tenant. It reports
+// whether the request may proceed, having aborted it otherwise.
+func (h *Handler) enterTenant(c Context) bool {
+	if !h.cfg.Tenancy.Enabled {
+		return true
+	}
+
+	id, err := h.tenantOf(c)
+	if err != nil {
+		abortWithError(c, errForbidden.Wrap(err))
+		return false
+	}
+
+	c.Set(tenantKey, id)
+	c.SetRequest(c.Request().WithContext(tenant.With(c.Request().Context(), id)))
+	return true
+}
+
+// tenantOf returns the tenant of the request's API key, signing client or
+// JWT, the last named by the tenancy.jwt_claim claim. Keys and clients
+// without one, and unauthenticated requests, act for the default tenant; a
+// token without a valid claim is an error, as it can't be told apart from
+// one meant for another tenant.
+func (h *Handler) tenantOf(c Context) (string, error) {
+	if key, ok := ClientAPIKey(c); ok {
+		return orDefaultTenant(key.TenantID), nil
+	}
+	if client, ok := SigningClient(c); ok {
+		return orDefaultTenant(client.Tenant), nil
+	}
+	if claims, ok := Claims(c); ok {
+		return h.claimTenant(claims)
+	}
+	return tenant.Default, nil
+}
+
+// claimTenant returns the tenant named by the tenancy.jwt_claim claim
+func (h *Handler) claimTenant(claims jwt.MapClaims) (string, error) {
+	claim := h.cfg.Tenancy.JWTClaim
+	id, _ := claims[claim].(string)
+	if !tenant.Valid(id) {
+		return "", fmt.Errorf("token has no valid %s claim", claim)
+	}
+	return id, nil
+}
+
+// orDefaultTenant returns id, or the default tenant if it's empty
+func orDefaultTenant(id string) string {
+	if id == "" {
+		return tenant.Default
+	}
+	return id
+}
+
+// Tenant returns the tenant the request acts for, if tenancy is enabled
+func Tenant(c Context) (string, bool) {
+	value, ok := c.Get(tenantKey)
+	if !ok {
+		return "", false
+	}
+	id, ok := value.(string)
+	return id, ok
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// warmTarget is an API request whose result is loaded into the cache
+// ahead of the first client asking for it
+type warmTarget struct {
+	target string
+	warm   func(ctx context.Context, h *Handler) error
+}
+
+// newWarmTargets parses the configured warm targets, each a GET path with
+// an optional query string, as a client would request it. The query is
+// validated the same way the endpoint validates it.
+func newWarmTargets(targets []string) ([]warmTarget, error) {
+	built := make([]warmTarget, 0, len(targets))
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, fmt.Errorf("warm target %q: %w", target, err)
+		}
+		query := u.Query()
+
+		var warm func(ctx context.Context, h *Handler) error
+		switch u.Path {
+		case "/api/v1/items":
+			filter, err := parseItemFilter(query)
+			if err != nil {
+				return nil, fmt.Errorf("warm target %q: %w", target, err)
+			}
+			warm = func(ctx context.Context, h *Handler) error {
+				_, _, err := h.listItems(ctx, filter)
+				return err
+			}
+		case "/api/v1/analytics/orders/status":
+			if _, _, err := parseOrderStatusFilter(query, time.Now()); err != nil {
+				return nil, fmt.Errorf("warm target %q: %w", target, err)
+			}
+			// Preset periods end now, so the filter is built per run
+			warm = func(ctx context.Context, h *Handler) error {
+				period, filter, err := parseOrderStatusFilter(query, time.Now())
+				if err != nil {
+					return err
+				}
+				_, _, _, err = h.orderStatusSummary(ctx, period, filter)
+				return err
+			}
+		case "/api/v1/analytics/customers/top":
+			filter, err := parseTopCustomersFilter(query)
+			if err != nil {
+				return nil, fmt.Errorf("warm target %q: %w", target, err)
+			}
+			warm = func(ctx context.Context, h *Handler) error {
+				_, _, err := h.topCustomers(ctx, filter)
+				return err
+			}
+		default:
+			return nil, fmt.Errorf("warm target %q: only /api/v1/items, /api/v1/analytics/orders/status and /api/v1/analytics/customers/top can be warmed", target)
+		}
+
+		built = append(built, warmTarget{target: target, warm: warm})
+	}
+	return built, nil
+}
+
+// WarmCache loads the configured warm targets into the cache, so the first
+// requests after startup or a sync don't pay for the cache miss. Results
+// already cached are left as they are. Failures are logged and the
+// remaining targets still warmed.
+func (h *Handler) WarmCache(ctx context.Context) {
+	if len(h.warmTargets) == 0 {
+		return
+	}
+	log := h.logger.FromContext(ctx)
+	start := time.Now()
+
+	warmed := 0
+	for _, t := range h.warmTargets {
+		if err := t.warm(ctx, h); err != nil {
+			log.WithError(err).WithField("target", t.target).Warn("Failed to warm cache")
+			continue
+		}
+		warmed++
+	}
+
+	log.WithFields(map[string]interface{}{
+		"warmed":   warmed,
+		"targets":  len(h.warmTargets),
+		"duration": time.Since(start),
+	}).Info("Cache warmed")
+}
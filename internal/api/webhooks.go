@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway-backend/internal/apierror"
+	"api-gateway-backend/internal/audit"
+	"api-gateway-backend/internal/client"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/events"
+	"api-gateway-backend/internal/webhooks"
+)
+
+const (
+	maxWebhookBodySize = 1 << 20
+	maxWebhookItems    = 1000
+)
+
+// itemsWebhookPayload is the body of an items webhook delivery. Items use
+// the upstream's post representation.
+type itemsWebhookPayload struct {
+	ID    string                `json:"id"`
+	Items []client.PostResponse `json:"items"`
+}
+
+// itemsWebhook handles POST /api/v1/webhooks/items. The upstream signs
+// each delivery instead of authenticating with an API key; pushed items
+// are upserted right away and the event ID makes redeliveries no-ops.
+func (h *Handler) itemsWebhook(c Context) {
+	ctx := c.Request().Context()
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxWebhookBodySize+1))
+	if err != nil {
+		abortWithError(c, bodyReadError(err))
+		return
+	}
+	if len(body) > maxWebhookBodySize {
+		abortWithError(c, bodyTooLarge(maxWebhookBodySize))
+		return
+	}
+
+	tolerance := time.Duration(h.cfg.Webhooks.Tolerance) * time.Second
+	if err := webhooks.Verify(h.cfg.Webhooks.Secret, c.GetHeader(webhooks.TimestampHeader), c.GetHeader(webhooks.SignatureHeader), body, tolerance, time.Now()); err != nil {
+		h.log(c).WithError(err).Warn("Rejected webhook delivery")
+		abortWithError(c, errUnauthorized.Wrap(err))
+		return
+	}
+	c.Set(actorKey, "webhook")
+
+	var payload itemsWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidRequest, "invalid request body", err))
+		return
+	}
+	items, err := payload.toItems()
+	if err != nil {
+		abortWithError(c, apierror.BadRequest(apierror.CodeInvalidRequest, "invalid webhook payload", err))
+		return
+	}
+
+	before, err := events.Snapshot(ctx, h.db, items)
+	if err != nil {
+		h.log(c).WithError(err).Warn("Failed to snapshot items, live updates skipped for this webhook")
+	}
+
+	applied, err := h.db.ApplyItemsWebhook(ctx, payload.ID, items)
+	if err != nil {
+		h.log(c).WithError(err).WithField("event_id", payload.ID).Error("Failed to apply webhook")
+		abortWithError(c, apierror.Internal("failed to apply webhook", err))
+		return
+	}
+
+	log := h.log(c).WithField("event_id", payload.ID).WithField("count", len(items))
+	if applied {
+		h.invalidateItems(c)
+		h.publishWebhook(c, webhooks.EventItemsUpdated, itemsUpdatedEvent(payload.ID, items))
+		// With the outbox, the relay job publishes the items' events
+		if before != nil && !h.cfg.Jobs.Outbox {
+			h.publishItemEvents(c, before, items)
+		}
+		from, to := itemContents(before, items)
+		h.audit(c, audit.ActionItemsWebhook, "webhook_event:"+payload.ID, from, to)
+		log.Info("Applied items webhook")
+	} else {
+		log.Info("Skipped duplicate items webhook")
+	}
+
+	respond(c, http.StatusOK, reply{
+		Data: H{"event_id": payload.ID, "duplicate": !applied, "count": len(items)},
+		Flat: true,
+	})
+}
+
+// itemContents returns the content of the written items and of their stored
+// versions in before, keyed by external ID, for the audit log. Items
+// missing from before are new.
+func itemContents(before map[string]database.Item, written []database.Item) (H, H) {
+	content := func(item database.Item) H {
+		return H{"title": item.Title, "body": item.Body, "user_id": item.UserID, "deleted": item.DeletedAt != nil}
+	}
+	from, to := H{}, H{}
+	for _, item := range written {
+		if stored, ok := before[item.ExternalID]; ok {
+			from[item.ExternalID] = content(stored)
+		}
+		to[item.ExternalID] = content(item)
+	}
+	return from, to
+}
+
+// toItems validates the payload and converts it to items
+func (p itemsWebhookPayload) toItems() ([]database.Item, error) {
+	if strings.TrimSpace(p.ID) == "" {
+		return nil, errors.New("id is required")
+	}
+	if len(p.ID) > 255 {
+		return nil, errors.New("id must be at most 255 characters")
+	}
+	if len(p.Items) > maxWebhookItems {
+		return nil, fmt.Errorf("at most %d items per delivery", maxWebhookItems)
+	}
+
+	items := make([]database.Item, len(p.Items))
+	for i, post := range p.Items {
+		if post.ID <= 0 {
+			return nil, fmt.Errorf("items[%d]: id must be positive", i)
+		}
+		items[i] = database.Item{
+			ExternalID: strconv.Itoa(post.ID),
+			Title:      post.Title,
+			Body:       post.Body,
+			UserID:     post.UserID,
+		}
+	}
+	return items, nil
+}
+
+// itemsUpdatedEvent is the data of the event published to subscribers
+// after an upstream webhook changed items
+func itemsUpdatedEvent(sourceEventID string, items []database.Item) H {
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ExternalID
+	}
+	return H{
+		"source_event_id": sourceEventID,
+		"external_ids":    ids,
+		"count":           len(items),
+	}
+}
+
+// invalidateItems retires cached items and listings after items change
+func (h *Handler) invalidateItems(c Context) {
+	version, err := h.versions.Bump(c.Request().Context(), itemsCacheNamespace)
+	if err != nil {
+		h.log(c).WithError(err).Warn("Failed to invalidate items cache")
+		return
+	}
+	h.log(c).WithField("version", version).Debug("Invalidated items cache")
+}
@@ -0,0 +1,137 @@
+// Package apierror defines the typed errors returned by the API and the
+// machine-readable codes clients can branch on.
+package apierror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Code is a stable, machine-readable error identifier
+type Code string
+
+// Error codes returned in the "code" field of error responses
+const (
+	CodeInvalidRequest     Code = "INVALID_REQUEST"
+	CodeInvalidParameter   Code = "INVALID_PARAMETER"
+	CodeUnauthorized       Code = "UNAUTHORIZED"
+	CodeForbidden          Code = "FORBIDDEN"
+	CodeRouteNotFound      Code = "ROUTE_NOT_FOUND"
+	CodeItemNotFound       Code = "ITEM_NOT_FOUND"
+	CodeOrderNotFound      Code = "ORDER_NOT_FOUND"
+	CodeCustomerNotFound   Code = "CUSTOMER_NOT_FOUND"
+	CodeAPIKeyNotFound     Code = "API_KEY_NOT_FOUND"
+	CodeSyncJobNotFound    Code = "SYNC_JOB_NOT_FOUND"
+	CodeJobNotFound        Code = "JOB_NOT_FOUND"
+	CodeFailedItemNotFound Code = "FAILED_ITEM_NOT_FOUND"
+	CodeWebhookNotFound    Code = "WEBHOOK_NOT_FOUND"
+	CodeSyncInProgress     Code = "SYNC_IN_PROGRESS"
+	CodeRequestInProgress  Code = "REQUEST_IN_PROGRESS"
+	CodeIdempotencyReused  Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeRequestTooLarge    Code = "REQUEST_TOO_LARGE"
+	CodeRateLimited        Code = "RATE_LIMITED"
+	CodeServiceOverloaded  Code = "SERVICE_OVERLOADED"
+	CodeUpstreamTimeout    Code = "UPSTREAM_TIMEOUT"
+	CodeUpstreamError      Code = "UPSTREAM_ERROR"
+	CodeInternal           Code = "INTERNAL_ERROR"
+)
+
+// Codes lists every error code, e.g. for API documentation
+func Codes() []Code {
+	return []Code{
+		CodeInvalidRequest, CodeInvalidParameter, CodeUnauthorized, CodeForbidden,
+		CodeRouteNotFound, CodeItemNotFound, CodeOrderNotFound, CodeCustomerNotFound, CodeAPIKeyNotFound,
+		CodeSyncJobNotFound, CodeJobNotFound, CodeFailedItemNotFound, CodeWebhookNotFound, CodeSyncInProgress, CodeRequestInProgress,
+		CodeIdempotencyReused, CodeRequestTooLarge, CodeRateLimited, CodeServiceOverloaded,
+		CodeUpstreamTimeout, CodeUpstreamError, CodeInternal,
+	}
+}
+
+// Error is an API error with the HTTP status and code it is rendered with.
+// Message is the short summary clients already read from the "error"
+// field; the underlying cause, if any, is reported as "message".
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Details interface{}
+	Err     error
+}
+
+// New creates an error with no underlying cause
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap returns a copy of e caused by err
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+// WithDetails returns a copy of e carrying structured details
+func (e *Error) WithDetails(details interface{}) *Error {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// Body returns the JSON response body for e
+func (e *Error) Body() map[string]interface{} {
+	body := map[string]interface{}{
+		"error": e.Message,
+		"code":  e.Code,
+	}
+	if e.Err != nil {
+		body["message"] = e.Err.Error()
+	}
+	if e.Details != nil {
+		body["details"] = e.Details
+	}
+	return body
+}
+
+// BadRequest reports an invalid request parameter or body
+func BadRequest(code Code, message string, err error) *Error {
+	return New(http.StatusBadRequest, code, message).Wrap(err)
+}
+
+// NotFound reports a missing resource
+func NotFound(code Code, message string, err error) *Error {
+	return New(http.StatusNotFound, code, message).Wrap(err)
+}
+
+// Internal reports a failure while serving the request. Timeouts and
+// cancellations are reported as UPSTREAM_TIMEOUT with 504, since they mean
+// a dependency did not answer in time.
+func Internal(message string, err error) *Error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return New(http.StatusGatewayTimeout, CodeUpstreamTimeout, message).Wrap(err)
+	}
+	return New(http.StatusInternalServerError, CodeInternal, message).Wrap(err)
+}
+
+// From returns err as an *Error, treating untyped errors as internal
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal("internal error", err)
+}
@@ -0,0 +1,40 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInternal_MapsTimeouts(t *testing.T) {
+	err := Internal("sync failed", fmt.Errorf("fetch: %w", context.DeadlineExceeded))
+	assert.Equal(t, http.StatusGatewayTimeout, err.Status)
+	assert.Equal(t, CodeUpstreamTimeout, err.Code)
+
+	err = Internal("sync failed", errors.New("boom"))
+	assert.Equal(t, http.StatusInternalServerError, err.Status)
+	assert.Equal(t, CodeInternal, err.Code)
+}
+
+func TestFrom(t *testing.T) {
+	notFound := NotFound(CodeItemNotFound, "item not found", errors.New("no item with id 1"))
+	assert.Same(t, notFound, From(fmt.Errorf("lookup: %w", notFound)))
+
+	err := From(errors.New("boom"))
+	assert.Equal(t, CodeInternal, err.Code)
+}
+
+func TestBody(t *testing.T) {
+	err := BadRequest(CodeInvalidParameter, "invalid query parameter", errors.New("limit must be positive")).
+		WithDetails(map[string]string{"param": "limit"})
+
+	body := err.Body()
+	assert.Equal(t, "invalid query parameter", body["error"])
+	assert.Equal(t, CodeInvalidParameter, body["code"])
+	assert.Equal(t, "limit must be positive", body["message"])
+	assert.Equal(t, map[string]string{"param": "limit"}, body["details"])
+}
@@ -0,0 +1,187 @@
+// Package audit records mutating actions, who made them and the fields
+// they changed, in the audit_events table served at GET /admin/audit.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/logger"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Audited actions
+const (
+	ActionSync              = "sync.trigger"
+	ActionOrderCreate       = "order.create"
+	ActionItemsWebhook      = "items.webhook"
+	ActionItemsImport       = "items.import"
+	ActionAPIKeyCreate      = "api_key.create"
+	ActionAPIKeyRevoke      = "api_key.revoke"
+	ActionWebhookCreate     = "webhook.create"
+	ActionWebhookDisable    = "webhook.disable"
+	ActionLogLevelSet       = "log_level.set"
+	ActionConfigReload      = "config.reload"
+	ActionJobRun            = "job.run"
+	ActionJobPause          = "job.pause"
+	ActionJobResume         = "job.resume"
+	ActionFailedItemRequeue = "failed_item.requeue"
+	ActionAccessDenied      = "access.denied"
+)
+
+// Redacted replaces the values of sensitive fields in recorded changes
+const Redacted = "[REDACTED]"
+
+// sensitive lists substrings of field names whose values are never
+// recorded, only that they changed
+var sensitive = []string{"password", "secret", "token", "hash"}
+
+// Store is where audit events are kept
+type Store interface {
+	CreateAuditEvent(ctx context.Context, event *database.AuditEvent) error
+}
+
+// Recorder writes audit events to a Store
+type Recorder struct {
+	store  Store
+	logger *logger.Logger
+}
+
+// NewRecorder creates a recorder writing to store
+func NewRecorder(store Store, log *logger.Logger) *Recorder {
+	return &Recorder{store: store, logger: log}
+}
+
+// Record stores event. A failure is logged rather than returned, so the
+// audit log being unavailable never fails the action it records.
+func (r *Recorder) Record(ctx context.Context, event *database.AuditEvent) {
+	if err := r.store.CreateAuditEvent(ctx, event); err != nil {
+		r.logger.FromContext(ctx).WithError(err).WithFields(map[string]interface{}{
+			"action": event.Action,
+			"actor":  event.Actor,
+			"target": event.Target,
+		}).Warn("Failed to record audit event")
+	}
+}
+
+// ConfigChanges returns a subscriber that records each config reload that
+// changed a setting, starting from current, which was loaded from path
+func (r *Recorder) ConfigChanges(path string, current *config.Config) config.Subscriber {
+	prev := current
+	return config.SubscriberFunc(func(next *config.Config) {
+		changes := DiffConfig(prev, next)
+		prev = next
+		if len(changes) == 0 {
+			return
+		}
+		r.Record(context.Background(), &database.AuditEvent{
+			Action:  ActionConfigReload,
+			Actor:   "config",
+			Target:  path,
+			Changes: changes,
+		})
+	})
+}
+
+// Diff returns the fields that differ between before and after, either of
+// which may be nil for a create or delete. Values are compared in their
+// JSON form, nested objects by dotted path, e.g. rate_limit.burst.
+func Diff(before, after interface{}) database.Changes {
+	return diff(jsonFields(before), jsonFields(after))
+}
+
+// DiffConfig returns the settings that differ between prev and next, named
+// as in the config file
+func DiffConfig(prev, next *config.Config) database.Changes {
+	return diff(yamlFields(prev), yamlFields(next))
+}
+
+// diff compares two flattened field maps
+func diff(before, after map[string]interface{}) database.Changes {
+	changes := database.Changes{}
+	for name, from := range before {
+		if to, ok := after[name]; !ok || !reflect.DeepEqual(from, to) {
+			changes[name] = change(name, from, to)
+		}
+	}
+	for name, to := range after {
+		if _, ok := before[name]; !ok {
+			changes[name] = change(name, nil, to)
+		}
+	}
+	return changes
+}
+
+// change records a field going from one value to another, redacting both
+// if the field is sensitive
+func change(name string, from, to interface{}) database.Change {
+	if isSensitive(name) {
+		if from != nil {
+			from = Redacted
+		}
+		if to != nil {
+			to = Redacted
+		}
+	}
+	return database.Change{From: from, To: to}
+}
+
+// isSensitive reports whether name's last segment names a credential
+func isSensitive(name string) bool {
+	field := strings.ToLower(name[strings.LastIndex(name, ".")+1:])
+	for _, s := range sensitive {
+		if strings.Contains(field, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonFields flattens v's JSON object form, nil if v is nil or isn't an
+// object
+func jsonFields(v interface{}) map[string]interface{} {
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil() {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	return flatten("", m, map[string]interface{}{})
+}
+
+// yamlFields flattens v's YAML mapping form, so fields carry the names
+// used in the config file
+func yamlFields(v interface{}) map[string]interface{} {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]interface{}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	return flatten("", m, map[string]interface{}{})
+}
+
+// flatten adds m's fields to out under prefix, descending into nested
+// objects; lists are kept whole
+func flatten(prefix string, m map[string]interface{}, out map[string]interface{}) map[string]interface{} {
+	for name, value := range m {
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flatten(prefix+name+".", nested, out)
+			continue
+		}
+		out[prefix+name] = value
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/logger"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore struct {
+	events []database.AuditEvent
+	err    error
+}
+
+func (s *memoryStore) CreateAuditEvent(ctx context.Context, event *database.AuditEvent) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, *event)
+	return nil
+}
+
+func TestDiff(t *testing.T) {
+	type limits struct {
+		Requests int `json:"requests"`
+	}
+	type settings struct {
+		Name   string `json:"name"`
+		Secret string `json:"secret"`
+		Limits limits `json:"limits"`
+		Tags   []string
+	}
+
+	before := settings{Name: "a", Secret: "old", Limits: limits{Requests: 10}, Tags: []string{"x"}}
+	after := settings{Name: "a", Secret: "new", Limits: limits{Requests: 20}, Tags: []string{"x"}}
+	assert.Equal(t, database.Changes{
+		"secret":          {From: Redacted, To: Redacted},
+		"limits.requests": {From: float64(10), To: float64(20)},
+	}, Diff(before, after))
+
+	assert.Equal(t, database.Changes{
+		"name":            {To: "a"},
+		"secret":          {To: Redacted},
+		"limits.requests": {To: float64(10)},
+		"Tags":            {To: []interface{}{"x"}},
+	}, Diff(nil, &before), "a create lists every field")
+	assert.Empty(t, Diff(before, before))
+}
+
+func TestRecorder_ConfigChanges(t *testing.T) {
+	store := &memoryStore{}
+	log, hook := logtest.NewNullLogger()
+	r := NewRecorder(store, &logger.Logger{Logger: log})
+
+	prev := &config.Config{LogLevel: "info", Auth: config.AuthConfig{AdminToken: "one"}}
+	sub := r.ConfigChanges("config.yaml", prev)
+
+	sub.ApplyConfig(&config.Config{LogLevel: "info", Auth: config.AuthConfig{AdminToken: "one"}})
+	assert.Empty(t, store.events, "a reload without changes isn't recorded")
+
+	sub.ApplyConfig(&config.Config{LogLevel: "debug", Auth: config.AuthConfig{AdminToken: "two"}})
+	require.Len(t, store.events, 1)
+	event := store.events[0]
+	assert.Equal(t, ActionConfigReload, event.Action)
+	assert.Equal(t, "config.yaml", event.Target)
+	assert.Equal(t, database.Change{From: "info", To: "debug"}, event.Changes["log_level"])
+	assert.Equal(t, database.Change{From: Redacted, To: Redacted}, event.Changes["auth.admin_token"])
+
+	store.err = errors.New("database down")
+	sub.ApplyConfig(&config.Config{LogLevel: "warn"})
+	assert.Len(t, store.events, 1)
+	require.NotNil(t, hook.LastEntry())
+	assert.Equal(t, "Failed to record audit event", hook.LastEntry().Message)
+}
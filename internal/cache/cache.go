@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"time"
+
+	"api-gateway-backend/internal/logger"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrMiss is returned by Get when a key is not cached
+var ErrMiss = errors.New("cache miss")
+
+// loadTimeout bounds a shared load, which outlives any single caller
+const loadTimeout = 30 * time.Second
+
+// Expiring is a load result cached for TTL rather than the ttl GetOrLoad
+// was called with, e.g. an empty result cached briefly. A TTL of zero or
+// less returns the value without caching it.
+type Expiring struct {
+	Value interface{}
+	TTL   time.Duration
+}
+
+// Jitter returns ttl randomly lengthened or shortened by up to fraction of
+// it, so keys written together don't all expire at once
+func Jitter(ttl time.Duration, fraction float64) time.Duration {
+	spread := int64(float64(ttl) * fraction)
+	if spread <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int63n(2*spread+1)-spread)
+}
+
+// Cache stores JSON-encodable values by key
+type Cache interface {
+	// Get decodes the value cached at key into dest, or returns ErrMiss
+	Get(ctx context.Context, key string, dest interface{}) error
+	// Set caches value at key for ttl
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	// SetMany caches each value in values at its key for ttl, in a
+	// single round trip where the backend allows
+	SetMany(ctx context.Context, values map[string]interface{}, ttl time.Duration) error
+	// Delete removes keys from the cache
+	Delete(ctx context.Context, keys ...string) error
+	// GetOrLoad decodes the value cached at key into dest. On a miss, load
+	// is called once for all concurrent callers of the same key and its
+	// result is cached for ttl. load receives a context detached from the
+	// caller's cancellation. load may return an Expiring to choose the
+	// TTL per result. It reports whether the value was cached.
+	GetOrLoad(ctx context.Context, key string, ttl time.Duration, dest interface{}, load func(ctx context.Context) (interface{}, error)) (bool, error)
+}
+
+// getOrLoad implements GetOrLoad on top of c's Get and Set. Loads are
+// deduplicated per key through group, so when a hot key expires only one
+// goroutine per process regenerates it while the others wait.
+func getOrLoad(ctx context.Context, c Cache, group *singleflight.Group, log *logger.Logger,
+	key string, ttl time.Duration, dest interface{}, load func(ctx context.Context) (interface{}, error)) (bool, error) {
+	if err := c.Get(ctx, key, dest); err == nil {
+		return true, nil
+	}
+
+	data, err, _ := group.Do(key, func() (interface{}, error) {
+		// The load is shared, so it must not fail because the first
+		// caller's request was cancelled
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+
+		// A previous flight may have filled the key since our read
+		var cached json.RawMessage
+		if err := c.Get(ctx, key, &cached); err == nil {
+			return []byte(cached), nil
+		}
+
+		value, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ttl := ttl
+		if e, ok := value.(Expiring); ok {
+			value, ttl = e.Value, e.TTL
+		}
+
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if ttl <= 0 {
+			return data, nil
+		}
+		if err := c.Set(ctx, key, json.RawMessage(data), ttl); err != nil && !errors.Is(err, ErrUnavailable) {
+			log.FromContext(ctx).WithError(err).WithField("key", key).Warn("Failed to cache value")
+		}
+		return data, nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return false, json.Unmarshal(data.([]byte), dest)
+}
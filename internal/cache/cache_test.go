@@ -0,0 +1,312 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/tenant"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingCache is a Cache whose backend is unavailable
+type failingCache struct{}
+
+var errUnavailable = errors.New("connection refused")
+
+func (failingCache) Get(ctx context.Context, key string, dest interface{}) error {
+	return errUnavailable
+}
+
+func (failingCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return errUnavailable
+}
+
+func (failingCache) SetMany(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	return errUnavailable
+}
+
+func (failingCache) Delete(ctx context.Context, keys ...string) error {
+	return errUnavailable
+}
+
+func (failingCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, dest interface{}, load func(ctx context.Context) (interface{}, error)) (bool, error) {
+	return false, errUnavailable
+}
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2, logger.New())
+
+	require.NoError(t, c.Set(ctx, "a", 1, 0))
+	require.NoError(t, c.Set(ctx, "b", 2, 0))
+
+	// Touch "a" so "b" becomes the eviction candidate
+	var v int
+	require.NoError(t, c.Get(ctx, "a", &v))
+	require.NoError(t, c.Set(ctx, "c", 3, 0))
+
+	assert.ErrorIs(t, c.Get(ctx, "b", &v), ErrMiss)
+	assert.NoError(t, c.Get(ctx, "a", &v))
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestLRU_Expiry(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(10, logger.New())
+
+	require.NoError(t, c.Set(ctx, "k", "v", time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+
+	var v string
+	assert.ErrorIs(t, c.Get(ctx, "k", &v), ErrMiss)
+}
+
+func TestGetOrLoad_DeduplicatesConcurrentLoads(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(10, logger.New())
+
+	var loads int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return []string{"x", "y"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var got []string
+			_, err := c.GetOrLoad(ctx, "items:all", time.Minute, &got, load)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"x", "y"}, got)
+		}()
+	}
+
+	// Give every caller time to join the in-flight load
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	var got []string
+	cached, err := c.GetOrLoad(ctx, "items:all", time.Minute, &got, load)
+	assert.NoError(t, err)
+	assert.True(t, cached)
+}
+
+func TestFallback_ServesLocalCopyWhenPrimaryFails(t *testing.T) {
+	ctx := context.Background()
+	local := NewLRU(10, logger.New())
+	c := NewFallback(failingCache{}, local, logger.New())
+
+	loads := 0
+	load := func(ctx context.Context) (interface{}, error) {
+		loads++
+		return map[string]int{"count": 3}, nil
+	}
+
+	var got map[string]int
+	cached, err := c.GetOrLoad(ctx, "items:all", time.Minute, &got, load)
+	require.NoError(t, err)
+	assert.False(t, cached)
+
+	cached, err = c.GetOrLoad(ctx, "items:all", time.Minute, &got, load)
+	require.NoError(t, err)
+	assert.True(t, cached)
+	assert.Equal(t, 3, got["count"])
+	assert.Equal(t, 1, loads)
+}
+
+func TestFallback_SetManyCachesLocallyWhenPrimaryFails(t *testing.T) {
+	ctx := context.Background()
+	local := NewLRU(10, logger.New())
+	c := NewFallback(failingCache{}, local, logger.New())
+
+	require.NoError(t, c.SetMany(ctx, map[string]interface{}{"items:id:1": 1, "items:id:2": 2}, time.Minute))
+	assert.Equal(t, 2, local.Len())
+
+	var got int
+	require.NoError(t, c.Get(ctx, "items:id:2", &got))
+	assert.Equal(t, 2, got)
+}
+
+func TestGuarded_SkipsBackendWhileDown(t *testing.T) {
+	ctx := context.Background()
+	backend := NewLRU(10, logger.New())
+	var down atomic.Bool
+	c := NewGuarded(backend, down.Load, logger.New())
+
+	loads := 0
+	load := func(ctx context.Context) (interface{}, error) {
+		loads++
+		return loads, nil
+	}
+
+	var got int
+	_, err := c.GetOrLoad(ctx, "k", time.Minute, &got, load)
+	require.NoError(t, err)
+
+	// While down every call loads, and nothing reaches the backend
+	down.Store(true)
+	for i := 0; i < 2; i++ {
+		cached, err := c.GetOrLoad(ctx, "k", time.Minute, &got, load)
+		require.NoError(t, err)
+		assert.False(t, cached)
+	}
+	assert.Equal(t, 3, got)
+	assert.ErrorIs(t, c.Get(ctx, "k", &got), ErrUnavailable)
+	assert.ErrorIs(t, c.Set(ctx, "other", 1, time.Minute), ErrUnavailable)
+	assert.Equal(t, 1, backend.Len())
+
+	// Behind a fallback, the local copy is served instead
+	local := NewLRU(10, logger.New())
+	f := NewFallback(c, local, logger.New())
+	require.NoError(t, f.Set(ctx, "k", 7, time.Minute))
+	require.NoError(t, f.Get(ctx, "k", &got))
+	assert.Equal(t, 7, got)
+
+	down.Store(false)
+	require.NoError(t, c.Get(ctx, "k", &got))
+	assert.Equal(t, 1, got)
+}
+
+func TestGetOrLoad_PropagatesLoadError(t *testing.T) {
+	c := NewLRU(10, logger.New())
+
+	var got []string
+	_, err := c.GetOrLoad(context.Background(), "k", time.Minute, &got, func(ctx context.Context) (interface{}, error) {
+		return nil, errUnavailable
+	})
+	assert.ErrorIs(t, err, errUnavailable)
+}
+
+func TestGetOrLoad_Expiring(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(10, logger.New())
+
+	loads := 0
+	load := func(ttl time.Duration) func(ctx context.Context) (interface{}, error) {
+		return func(ctx context.Context) (interface{}, error) {
+			loads++
+			return Expiring{Value: []string{}, TTL: ttl}, nil
+		}
+	}
+
+	// The result's TTL overrides the call's
+	var got []string
+	_, err := c.GetOrLoad(ctx, "empty", time.Hour, &got, load(20*time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, got)
+	cached, err := c.GetOrLoad(ctx, "empty", time.Hour, &got, load(20*time.Millisecond))
+	require.NoError(t, err)
+	assert.True(t, cached)
+
+	time.Sleep(30 * time.Millisecond)
+	cached, err = c.GetOrLoad(ctx, "empty", time.Hour, &got, load(20*time.Millisecond))
+	require.NoError(t, err)
+	assert.False(t, cached)
+	assert.Equal(t, 2, loads)
+
+	// A zero TTL isn't cached at all
+	for i := 0; i < 2; i++ {
+		cached, err = c.GetOrLoad(ctx, "uncached", time.Hour, &got, load(0))
+		require.NoError(t, err)
+		assert.False(t, cached)
+	}
+	assert.Equal(t, 4, loads)
+}
+
+func TestJitter(t *testing.T) {
+	ttl := 100 * time.Second
+	assert.Equal(t, ttl, Jitter(ttl, 0))
+
+	spread := make(map[time.Duration]bool)
+	for i := 0; i < 200; i++ {
+		jittered := Jitter(ttl, 0.1)
+		assert.GreaterOrEqual(t, jittered, 90*time.Second)
+		assert.LessOrEqual(t, jittered, 110*time.Second)
+		spread[jittered] = true
+	}
+	assert.Greater(t, len(spread), 1, "TTLs should differ")
+}
+
+// memoryVersions is a versionStore in memory that can be made to fail
+type memoryVersions struct {
+	counters map[string]int64
+	down     bool
+	reads    int
+}
+
+func (m *memoryVersions) Get(ctx context.Context, key string) *goredis.StringCmd {
+	m.reads++
+	if m.down {
+		return goredis.NewStringResult("", errUnavailable)
+	}
+	n, ok := m.counters[key]
+	if !ok {
+		return goredis.NewStringResult("", goredis.Nil)
+	}
+	return goredis.NewStringResult(strconv.FormatInt(n, 10), nil)
+}
+
+func (m *memoryVersions) Incr(ctx context.Context, key string) *goredis.IntCmd {
+	if m.down {
+		return goredis.NewIntResult(0, errUnavailable)
+	}
+	m.counters[key]++
+	return goredis.NewIntResult(m.counters[key], nil)
+}
+
+func TestVersions(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryVersions{counters: make(map[string]int64)}
+	v := NewVersions(store, logger.New())
+
+	assert.Equal(t, "items:v0:all", v.Key(ctx, "items:all"))
+	assert.Equal(t, "items:v0:list:user_id=1", v.Key(ctx, "items:list:user_id=1"))
+
+	// Bumping a namespace moves only its keys
+	version, err := v.Bump(ctx, "items")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), version)
+	assert.Equal(t, "items:v1:all", v.Key(ctx, "items:all"))
+	assert.Equal(t, "analytics:v0:customers:top:limit=10", v.Key(ctx, "analytics:customers:top:limit=10"))
+	assert.Equal(t, map[string]int64{"items:v": 1}, store.counters)
+
+	versioned := v.At(ctx, "items")
+	assert.Equal(t, []string{"items:v1:id:1", "items:v1:id:2"}, []string{versioned("items:id:1"), versioned("items:id:2")})
+
+	// Each tenant has its own keys and versions
+	acme := tenant.With(ctx, "acme")
+	assert.Equal(t, "acme/items:v0:all", v.Key(acme, "items:all"))
+	_, err = v.Bump(acme, "items")
+	require.NoError(t, err)
+	assert.Equal(t, "acme/items:v1:id:1", v.At(acme, "items")("items:id:1"))
+	assert.Equal(t, map[string]int64{"items:v": 1, "acme/items:v": 1}, store.counters)
+
+	// While Redis is down the last version read is used
+	store.down = true
+	assert.Equal(t, "items:v1:all", v.Key(ctx, "items:all"))
+	_, err = v.Bump(ctx, "items")
+	assert.ErrorIs(t, err, errUnavailable)
+
+	// and with a guard Redis isn't asked while it's known to be down
+	reads := store.reads
+	v.WithGuard(func() bool { return store.down })
+	assert.Equal(t, "items:v1:all", v.Key(ctx, "items:all"))
+	assert.Equal(t, reads, store.reads)
+}
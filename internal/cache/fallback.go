@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"api-gateway-backend/internal/logger"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Fallback is a Cache that uses a shared primary (Redis) and keeps a copy
+// of every value in a local secondary (LRU). The secondary is only read
+// when the primary fails, so while Redis is down handlers keep serving
+// recently cached, possibly stale, data instead of falling through to the
+// database on every request.
+type Fallback struct {
+	primary   Cache
+	secondary Cache
+	logger    *logger.Logger
+	group     singleflight.Group
+}
+
+// NewFallback creates a cache that falls back to secondary when primary
+// is unavailable
+func NewFallback(primary, secondary Cache, log *logger.Logger) *Fallback {
+	return &Fallback{primary: primary, secondary: secondary, logger: log}
+}
+
+// Get reads from the primary, or from the secondary if the primary errors
+func (f *Fallback) Get(ctx context.Context, key string, dest interface{}) error {
+	err := f.primary.Get(ctx, key, dest)
+	if err == nil || errors.Is(err, ErrMiss) {
+		return err
+	}
+
+	if !errors.Is(err, ErrUnavailable) {
+		f.logger.FromContext(ctx).WithError(err).WithField("key", key).Warn("Cache unavailable, using local fallback")
+	}
+	return f.secondary.Get(ctx, key, dest)
+}
+
+// Set writes to both caches. A primary failure is logged rather than
+// returned, since the value is still cached locally; a primary known to be
+// down is skipped quietly.
+func (f *Fallback) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := f.secondary.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	if err := f.primary.Set(ctx, key, value, ttl); err != nil && !errors.Is(err, ErrUnavailable) {
+		f.logger.FromContext(ctx).WithError(err).WithField("key", key).Warn("Failed to write to cache, cached locally only")
+	}
+	return nil
+}
+
+// SetMany writes values to both caches, logging a primary failure as Set
+// does
+func (f *Fallback) SetMany(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	if err := f.secondary.SetMany(ctx, values, ttl); err != nil {
+		return err
+	}
+	if err := f.primary.SetMany(ctx, values, ttl); err != nil && !errors.Is(err, ErrUnavailable) {
+		f.logger.FromContext(ctx).WithError(err).WithField("keys", len(values)).Warn("Failed to write to cache, cached locally only")
+	}
+	return nil
+}
+
+// Delete removes keys from both caches
+func (f *Fallback) Delete(ctx context.Context, keys ...string) error {
+	if err := f.secondary.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	return f.primary.Delete(ctx, keys...)
+}
+
+// GetOrLoad reads key through the fallback chain, regenerating it with
+// load on a miss
+func (f *Fallback) GetOrLoad(ctx context.Context, key string, ttl time.Duration, dest interface{}, load func(ctx context.Context) (interface{}, error)) (bool, error) {
+	return getOrLoad(ctx, f, &f.group, f.logger, key, ttl, dest, load)
+}
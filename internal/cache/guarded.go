@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"api-gateway-backend/internal/logger"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrUnavailable is returned by a Guarded cache while its backend is known
+// to be down
+var ErrUnavailable = errors.New("cache backend known to be down")
+
+// Guarded is a Cache that doesn't call its backend while down reports it
+// unavailable, so requests fail fast rather than each waiting for the
+// backend to time out. Reads and writes return ErrUnavailable meanwhile,
+// and GetOrLoad loads without caching.
+type Guarded struct {
+	cache  Cache
+	down   func() bool
+	logger *logger.Logger
+	group  singleflight.Group
+}
+
+// NewGuarded creates a cache that skips c while down returns true
+func NewGuarded(c Cache, down func() bool, log *logger.Logger) *Guarded {
+	return &Guarded{cache: c, down: down, logger: log}
+}
+
+// Get reads from the backend unless it's down
+func (g *Guarded) Get(ctx context.Context, key string, dest interface{}) error {
+	if g.down() {
+		return ErrUnavailable
+	}
+	return g.cache.Get(ctx, key, dest)
+}
+
+// Set writes to the backend unless it's down
+func (g *Guarded) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if g.down() {
+		return ErrUnavailable
+	}
+	return g.cache.Set(ctx, key, value, ttl)
+}
+
+// SetMany writes to the backend unless it's down
+func (g *Guarded) SetMany(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	if g.down() {
+		return ErrUnavailable
+	}
+	return g.cache.SetMany(ctx, values, ttl)
+}
+
+// Delete removes keys from the backend unless it's down
+func (g *Guarded) Delete(ctx context.Context, keys ...string) error {
+	if g.down() {
+		return ErrUnavailable
+	}
+	return g.cache.Delete(ctx, keys...)
+}
+
+// GetOrLoad reads key through the guard, so while the backend is down
+// every call loads, still once for all concurrent callers of a key
+func (g *Guarded) GetOrLoad(ctx context.Context, key string, ttl time.Duration, dest interface{}, load func(ctx context.Context) (interface{}, error)) (bool, error) {
+	return getOrLoad(ctx, g, &g.group, g.logger, key, ttl, dest, load)
+}
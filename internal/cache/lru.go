@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"api-gateway-backend/internal/logger"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// LRU is an in-process Cache that holds at most a fixed number of entries,
+// evicting the least recently used one when full. Values are stored as
+// JSON so callers never share mutable state.
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // front is most recently used
+	logger   *logger.Logger
+	group    singleflight.Group
+}
+
+// lruEntry is a cached value and its expiry
+type lruEntry struct {
+	key       string
+	data      []byte
+	expiresAt time.Time // zero means no expiry
+}
+
+// NewLRU creates an in-process cache holding up to capacity entries
+func NewLRU(capacity int, log *logger.Logger) *LRU {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRU{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		logger:   log,
+	}
+}
+
+// Get decodes the value cached at key into dest
+func (l *LRU) Get(ctx context.Context, key string, dest interface{}) error {
+	l.mu.Lock()
+	elem, ok := l.entries[key]
+	if !ok {
+		l.mu.Unlock()
+		return ErrMiss
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		l.removeElement(elem)
+		l.mu.Unlock()
+		return ErrMiss
+	}
+	l.order.MoveToFront(elem)
+	data := entry.data
+	l.mu.Unlock()
+
+	return json.Unmarshal(data, dest)
+}
+
+// Set caches value at key for ttl, evicting the least recently used entry
+// if the cache is full
+func (l *LRU) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.entries[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.data, entry.expiresAt = data, expiresAt
+		l.order.MoveToFront(elem)
+		return nil
+	}
+
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, data: data, expiresAt: expiresAt})
+	for l.order.Len() > l.capacity {
+		l.removeElement(l.order.Back())
+	}
+	return nil
+}
+
+// SetMany caches each value in values at its key for ttl
+func (l *LRU) SetMany(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	for key, value := range values {
+		if err := l.Set(ctx, key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes keys from the cache
+func (l *LRU) Delete(ctx context.Context, keys ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := l.entries[key]; ok {
+			l.removeElement(elem)
+		}
+	}
+	return nil
+}
+
+// GetOrLoad reads key from memory, regenerating it with load on a miss
+func (l *LRU) GetOrLoad(ctx context.Context, key string, ttl time.Duration, dest interface{}, load func(ctx context.Context) (interface{}, error)) (bool, error) {
+	return getOrLoad(ctx, l, &l.group, l.logger, key, ttl, dest, load)
+}
+
+// Len returns the number of cached entries, including expired ones not
+// yet evicted
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// removeElement drops an entry; the caller must hold mu
+func (l *LRU) removeElement(elem *list.Element) {
+	l.order.Remove(elem)
+	delete(l.entries, elem.Value.(*lruEntry).key)
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/redis"
+
+	goredis "github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// Redis is a Cache shared by all instances through Redis
+type Redis struct {
+	client redis.CacheClient
+	logger *logger.Logger
+	group  singleflight.Group
+	jitter float64 // share of each TTL to randomize, see Jitter
+}
+
+// NewRedis creates a Redis-backed cache
+func NewRedis(rdb redis.CacheClient, log *logger.Logger) *Redis {
+	return &Redis{client: rdb, logger: log}
+}
+
+// WithJitter randomizes the TTL of every key set by up to fraction of it,
+// so keys cached together don't expire, and get reloaded, together. Only
+// use it for values that may safely outlive their TTL.
+func (r *Redis) WithJitter(fraction float64) *Redis {
+	r.jitter = fraction
+	return r
+}
+
+// Get decodes the value cached at key into dest
+func (r *Redis) Get(ctx context.Context, key string, dest interface{}) error {
+	err := r.client.GetJSON(ctx, key, dest)
+	if errors.Is(err, goredis.Nil) {
+		return ErrMiss
+	}
+	return err
+}
+
+// Set caches value at key for ttl, jittered if configured
+func (r *Redis) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return r.client.SetJSON(ctx, key, value, Jitter(ttl, r.jitter))
+}
+
+// SetMany caches values in one pipeline. The batch shares one jittered
+// TTL, so it expires together but apart from other batches.
+func (r *Redis) SetMany(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	return r.client.MSetJSON(ctx, values, Jitter(ttl, r.jitter))
+}
+
+// Delete removes keys from Redis
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// GetOrLoad reads key from Redis, regenerating it with load on a miss
+func (r *Redis) GetOrLoad(ctx context.Context, key string, ttl time.Duration, dest interface{}, load func(ctx context.Context) (interface{}, error)) (bool, error) {
+	return getOrLoad(ctx, r, &r.group, r.logger, key, ttl, dest, load)
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/tenant"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// versionStore is the part of the Redis client Versions uses
+type versionStore interface {
+	Get(ctx context.Context, key string) *goredis.StringCmd
+	Incr(ctx context.Context, key string) *goredis.IntCmd
+}
+
+// Versions invalidates cache namespaces by version rather than by deleting
+// keys. Each namespace, the part of a key before its first colon, has a
+// counter in Redis at <namespace>:v, kept per tenant. Key puts the current
+// version into a key, so Bump retires every key in the namespace with a
+// single INCR and the old keys expire by their TTL.
+type Versions struct {
+	client versionStore
+	logger *logger.Logger
+	down   func() bool // nil if Redis is always tried
+
+	mu   sync.Mutex
+	last map[string]int64 // last version read per namespace
+}
+
+// NewVersions creates namespace versions kept in rdb
+func NewVersions(rdb versionStore, log *logger.Logger) *Versions {
+	return &Versions{client: rdb, logger: log, last: make(map[string]int64)}
+}
+
+// WithGuard makes v use the last versions this process read, without
+// asking Redis, while down reports Redis known to be down
+func (v *Versions) WithGuard(down func() bool) *Versions {
+	v.down = down
+	return v
+}
+
+// Key returns key at its namespace's current version, e.g. items:all at
+// version 3 is items:v3:all. If the version can't be read, the last one
+// this process read is used, so the local fallback cache keeps serving
+// while Redis is down.
+func (v *Versions) Key(ctx context.Context, key string) string {
+	namespace, _, _ := strings.Cut(key, ":")
+	return v.At(ctx, namespace)(key)
+}
+
+// At reads namespace's version once and returns a function that puts keys
+// in namespace at it, for building many keys at the same version
+func (v *Versions) At(ctx context.Context, namespace string) func(key string) string {
+	scoped := scope(ctx, namespace)
+	prefix := scoped + ":v" + strconv.FormatInt(v.current(ctx, scoped), 10) + ":"
+	return func(key string) string {
+		return prefix + strings.TrimPrefix(key, namespace+":")
+	}
+}
+
+// Bump moves namespace to a new version, invalidating its keys
+func (v *Versions) Bump(ctx context.Context, namespace string) (int64, error) {
+	scoped := scope(ctx, namespace)
+	version, err := v.client.Incr(ctx, versionKey(scoped)).Result()
+	if err != nil {
+		return 0, err
+	}
+	v.remember(scoped, version)
+	return version, nil
+}
+
+// scope returns namespace within the tenant of ctx, e.g. acme/items, so
+// every key built by Key and At, and every Bump, only touches that
+// tenant's entries. The default tenant's namespaces keep their plain
+// names.
+func scope(ctx context.Context, namespace string) string {
+	if id := tenant.From(ctx); id != tenant.Default {
+		return id + "/" + namespace
+	}
+	return namespace
+}
+
+// current reads namespace's version; a namespace never bumped is at 0
+func (v *Versions) current(ctx context.Context, namespace string) int64 {
+	if v.down != nil && v.down() {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		return v.last[namespace]
+	}
+
+	version, err := v.client.Get(ctx, versionKey(namespace)).Int64()
+	switch {
+	case errors.Is(err, goredis.Nil):
+		version = 0
+	case err != nil:
+		v.logger.FromContext(ctx).WithError(err).WithField("namespace", namespace).Warn("Failed to read cache version, using the last known one")
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		return v.last[namespace]
+	}
+	v.remember(namespace, version)
+	return version
+}
+
+// remember records version as the last one seen for namespace
+func (v *Versions) remember(namespace string, version int64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.last[namespace] = version
+}
+
+// versionKey is the Redis key of namespace's version counter. It has no
+// TTL, so the version never goes back to a number whose keys may still be
+// cached.
+func versionKey(namespace string) string {
+	return namespace + ":v"
+}
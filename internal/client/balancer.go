@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-gateway-backend/internal/config"
+)
+
+// Balancer spreads requests across the base URLs of one logical upstream.
+// Backends are health checked passively: one that fails EjectAfter times
+// in a row is taken out of rotation for EjectDuration. If every backend is
+// ejected, all of them are tried again rather than failing outright.
+type Balancer struct {
+	name       string // of the upstream or proxy route, for metrics
+	backends   []*Backend
+	policy     string
+	ejectAfter int
+	ejectFor   time.Duration
+	next       uint64
+	now        func() time.Time
+}
+
+// Backend is one base URL behind a Balancer
+type Backend struct {
+	url      *url.URL
+	label    string // the base URL without credentials or query, for metrics
+	inFlight int64
+
+	mu           sync.Mutex
+	failures     int
+	ejectedUntil time.Time
+}
+
+// NewBalancer creates a balancer over urls for the upstream or proxy route
+// called name
+func NewBalancer(name string, urls []string, cfg config.BalancerConfig) (*Balancer, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no base URLs")
+	}
+	switch cfg.Policy {
+	case "", config.BalanceRoundRobin, config.BalanceLeastConnections:
+	default:
+		return nil, fmt.Errorf("unsupported balance policy %q", cfg.Policy)
+	}
+
+	b := &Balancer{
+		name:       name,
+		policy:     cfg.Policy,
+		ejectAfter: cfg.EjectAfter,
+		ejectFor:   time.Duration(cfg.EjectDuration) * time.Second,
+		now:        time.Now,
+	}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid base URL %q", raw)
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		label := u.Scheme + "://" + u.Host + u.Path
+		b.backends = append(b.backends, &Backend{url: u, label: label})
+		backendEjected.Set(0, name, label)
+	}
+	return b, nil
+}
+
+// Pick chooses the backend for the next request. The caller must report
+// the outcome with Done.
+func (b *Balancer) Pick() *Backend {
+	now := b.now()
+	candidates := make([]*Backend, 0, len(b.backends))
+	for _, be := range b.backends {
+		if be.available(now) {
+			candidates = append(candidates, be)
+			backendEjected.Set(0, b.name, be.label)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = b.backends
+	}
+
+	start := int(atomic.AddUint64(&b.next, 1) - 1)
+	picked := candidates[start%len(candidates)]
+	if b.policy == config.BalanceLeastConnections {
+		// Scan from the round-robin position so ties are spread evenly
+		for i := 1; i < len(candidates); i++ {
+			be := candidates[(start+i)%len(candidates)]
+			if atomic.LoadInt64(&be.inFlight) < atomic.LoadInt64(&picked.inFlight) {
+				picked = be
+			}
+		}
+	}
+
+	atomic.AddInt64(&picked.inFlight, 1)
+	return picked
+}
+
+// Done records the outcome of a request picked for be, ejecting it once
+// it has failed too many times in a row
+func (b *Balancer) Done(be *Backend, failed bool) {
+	atomic.AddInt64(&be.inFlight, -1)
+
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	if !failed {
+		be.failures = 0
+		return
+	}
+	be.failures++
+	if b.ejectAfter > 0 && be.failures >= b.ejectAfter {
+		be.ejectedUntil = b.now().Add(b.ejectFor)
+		be.failures = 0
+		backendEjected.Set(1, b.name, be.label)
+	}
+}
+
+// Resolve returns u's path and query relative to the backend's base URL
+func (be *Backend) Resolve(u *url.URL) *url.URL {
+	resolved := *be.url
+	resolved.Path = be.url.Path + "/" + strings.TrimPrefix(u.Path, "/")
+	resolved.RawPath = ""
+	switch {
+	case be.url.RawQuery == "":
+		resolved.RawQuery = u.RawQuery
+	case u.RawQuery != "":
+		resolved.RawQuery = be.url.RawQuery + "&" + u.RawQuery
+	}
+	return &resolved
+}
+
+func (be *Backend) available(now time.Time) bool {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	return !now.Before(be.ejectedUntil)
+}
+
+// Failed reports whether a round trip counts against the backend's health:
+// it could not be completed or the backend answered with a server error.
+// Requests the caller gave up on say nothing about the backend.
+func Failed(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// BalancedTransport sends each request to a backend picked by Balancer,
+// resolving the request's path and query against the backend's base URL.
+// The backend is released once the response body is closed, so
+// least-connections counts streamed responses correctly.
+type BalancedTransport struct {
+	Balancer *Balancer
+	Next     http.RoundTripper
+}
+
+func (t *BalancedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	be := t.Balancer.Pick()
+
+	out := new(http.Request)
+	*out = *req
+	out.URL = be.Resolve(req.URL)
+	out.Host = ""
+
+	resp, err := t.Next.RoundTrip(out)
+	if err != nil {
+		t.Balancer.Done(be, Failed(nil, err))
+		return nil, err
+	}
+
+	failed := Failed(resp, nil)
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: func() { t.Balancer.Done(be, failed) }}
+	return resp, nil
+}
+
+// releaseBody calls release once when the body is closed
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseBody) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
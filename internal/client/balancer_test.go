@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBalancer(t *testing.T, policy string, urls ...string) *Balancer {
+	b, err := NewBalancer("test", urls, config.BalancerConfig{Policy: policy, EjectAfter: 2, EjectDuration: 30})
+	assert.NoError(t, err)
+	return b
+}
+
+func TestBalancer_RoundRobin(t *testing.T) {
+	b := newTestBalancer(t, config.BalanceRoundRobin, "http://a", "http://b", "http://c")
+
+	var hosts []string
+	for i := 0; i < 6; i++ {
+		be := b.Pick()
+		hosts = append(hosts, be.url.Host)
+		b.Done(be, false)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "a", "b", "c"}, hosts)
+}
+
+func TestBalancer_LeastConnections(t *testing.T) {
+	b := newTestBalancer(t, config.BalanceLeastConnections, "http://a", "http://b")
+
+	busy := b.Pick()
+	assert.Equal(t, "a", busy.url.Host)
+
+	// a is still in flight, so b is picked regardless of rotation
+	for i := 0; i < 3; i++ {
+		be := b.Pick()
+		assert.Equal(t, "b", be.url.Host)
+		b.Done(be, false)
+	}
+}
+
+func TestBalancer_EjectsFailingBackend(t *testing.T) {
+	b := newTestBalancer(t, config.BalanceRoundRobin, "http://a", "http://b")
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	a := b.backends[0]
+	b.Done(b.Pick(), true) // a
+	b.Done(b.Pick(), false)
+	b.Done(b.Pick(), true) // a again, ejected
+
+	for i := 0; i < 4; i++ {
+		be := b.Pick()
+		assert.Equal(t, "b", be.url.Host)
+		b.Done(be, false)
+	}
+
+	assert.Equal(t, float64(1), backendEjected.Value("test", "http://a"))
+
+	// Back in rotation once the ejection lapses
+	now = now.Add(31 * time.Second)
+	assert.True(t, a.available(now))
+
+	// With every backend ejected, all are tried rather than none
+	b.ejectAfter = 1
+	b.Done(b.Pick(), true)
+	b.Done(b.Pick(), true)
+	assert.NotNil(t, b.Pick())
+}
+
+func TestBalancer_Invalid(t *testing.T) {
+	_, err := NewBalancer("test", nil, config.BalancerConfig{})
+	assert.Error(t, err)
+
+	_, err = NewBalancer("test", []string{"example.com"}, config.BalancerConfig{})
+	assert.Error(t, err)
+
+	_, err = NewBalancer("test", []string{"http://a"}, config.BalancerConfig{Policy: "random"})
+	assert.Error(t, err)
+}
+
+func TestBackend_Resolve(t *testing.T) {
+	b := newTestBalancer(t, "", "https://api.example.com/v2/?key=k")
+	u := b.backends[0].Resolve(&url.URL{Path: "/users", RawQuery: "limit=10"})
+	assert.Equal(t, "https://api.example.com/v2/users?key=k&limit=10", u.String())
+}
+
+func TestFetch_FailsOverToHealthyBackend(t *testing.T) {
+	var downCalls int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downCalls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+
+	u, err := New("crm", config.UpstreamConfig{
+		BaseURLs:   []string{down.URL, up.URL},
+		Balancer:   config.BalancerConfig{EjectAfter: 1, EjectDuration: 60},
+		MaxRetries: 1,
+	}, nil)
+	assert.NoError(t, err)
+	u.retryBase = time.Millisecond
+
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, u.Do(context.Background(), Endpoint{Path: "/"}, nil))
+	}
+	// The failing backend was ejected after its first error
+	assert.Equal(t, int32(1), atomic.LoadInt32(&downCalls))
+}
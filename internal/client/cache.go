@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway-backend/internal/cache"
+)
+
+// responseKeyPrefix namespaces upstream responses shared through the cache
+const responseKeyPrefix = "upstream:response:"
+
+// responseCache keeps an upstream's GET responses, fresh for their
+// Cache-Control max-age or ttl without one. Once stale, a response with an
+// ETag or Last-Modified is kept for staleTTL more to revalidate it with a
+// conditional request.
+type responseCache struct {
+	cache    cache.Cache
+	ttl      time.Duration
+	staleTTL time.Duration
+}
+
+// cachedResponse is a response body with what's needed to revalidate it
+type cachedResponse struct {
+	Body         []byte    `json:"body"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Link         string    `json:"link,omitempty"` // naming the next page of a collection
+	FreshUntil   time.Time `json:"fresh_until"`
+}
+
+// fresh reports whether the response may be used without asking the
+// upstream
+func (r *cachedResponse) fresh(now time.Time) bool {
+	return now.Before(r.FreshUntil)
+}
+
+// revalidatable reports whether the upstream can be asked if the response
+// is still current
+func (r *cachedResponse) revalidatable() bool {
+	return r.ETag != "" || r.LastModified != ""
+}
+
+// responseKey returns the cache key of ep's response, keyed by its path
+// and query, and whether ep's response is cached at all: only GETs without
+// a body are, on upstreams with caching enabled
+func (u *Upstream) responseKey(ep Endpoint) (string, bool) {
+	if u.responses == nil || (ep.Method != "" && ep.Method != http.MethodGet) || ep.Body != nil {
+		return "", false
+	}
+	return responseKeyPrefix + u.name + ":" + (&url.URL{Path: ep.Path, RawQuery: ep.Query.Encode()}).String(), true
+}
+
+// lookup returns the cached response at key, or nil if there is none or
+// the cache can't be read
+func (c *responseCache) lookup(ctx context.Context, key string) *cachedResponse {
+	var cached cachedResponse
+	if err := c.cache.Get(ctx, key, &cached); err != nil {
+		return nil
+	}
+	return &cached
+}
+
+// store caches body as the response at key, as header allows. Failures
+// only mean the next request goes to the upstream.
+func (c *responseCache) store(ctx context.Context, key string, body []byte, header http.Header, now time.Time) {
+	freshFor, ok := freshness(header.Get("Cache-Control"), c.ttl)
+	if !ok {
+		return
+	}
+	cached := cachedResponse{
+		Body:         body,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		Link:         header.Get("Link"),
+		FreshUntil:   now.Add(freshFor),
+	}
+	keep := freshFor
+	if cached.revalidatable() {
+		keep += c.staleTTL
+	}
+	if keep <= 0 {
+		return
+	}
+	_ = c.cache.Set(ctx, key, cached, keep)
+}
+
+// freshness returns how long a response with the Cache-Control header
+// cacheControl stays fresh, fallback if it doesn't say, and whether it may
+// be stored at all. Every directive is read first, as no-store and private
+// forbid storing in this shared cache whatever else the header says.
+func freshness(cacheControl string, fallback time.Duration) (time.Duration, bool) {
+	fresh := fallback
+	var noCache bool
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "private":
+			return 0, false
+		case "no-cache":
+			// Stored, but revalidated before every use
+			noCache = true
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && seconds >= 0 {
+				fresh = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if noCache {
+		return 0, true
+	}
+	return fresh, true
+}
+
+// decode unmarshals a JSON response body into dest, if both are set
+func decode(body []byte, dest interface{}) error {
+	if dest == nil || len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, dest)
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/cache"
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCachingClient(t *testing.T, handler http.HandlerFunc) *Upstream {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	u, err := New("test", config.UpstreamConfig{
+		BaseURLs: []string{server.URL}, Timeout: 5,
+		CacheEnabled: true, CacheTTL: 60, CacheStaleTTL: 60,
+	}, cache.NewLRU(10, logger.New()))
+	require.NoError(t, err)
+	return u
+}
+
+func TestResponseCache_Fresh(t *testing.T) {
+	var calls int32
+	u := newCachingClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(`[{"userId":1,"id":1,"title":"t","body":"b"}]`))
+	})
+
+	// A fresh response is used without asking the upstream again
+	for i := 0; i < 2; i++ {
+		posts, err := FetchPosts(context.Background(), u)
+		require.NoError(t, err)
+		assert.Len(t, posts, 1)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// Other queries and methods are fetched separately or not cached
+	_, err := Fetch[[]PostResponse](context.Background(), u, Endpoint{Path: "/posts", Query: map[string][]string{"userId": {"1"}}})
+	require.NoError(t, err)
+	require.NoError(t, u.Do(context.Background(), Endpoint{Method: http.MethodPost, Path: "/posts", Body: PostResponse{}}, nil))
+	require.NoError(t, u.Do(context.Background(), Endpoint{Method: http.MethodPost, Path: "/posts", Body: PostResponse{}}, nil))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestResponseCache_Revalidate(t *testing.T) {
+	var calls, notModified int32
+	u := newCachingClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`[{"userId":1,"id":1,"title":"t","body":"b"}]`))
+	})
+
+	// A response to revalidate before every use is kept, and reused when
+	// the upstream reports it unchanged
+	for i := 0; i < 3; i++ {
+		posts, err := FetchPosts(context.Background(), u)
+		require.NoError(t, err)
+		require.Len(t, posts, 1)
+		assert.Equal(t, "t", posts[0].Title)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&notModified))
+}
+
+func TestResponseCache_NoStore(t *testing.T) {
+	var calls int32
+	u := newCachingClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`[]`))
+	})
+
+	for i := 0; i < 2; i++ {
+		_, err := FetchPosts(context.Background(), u)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestFreshness(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		want         time.Duration
+		store        bool
+	}{
+		{"", time.Minute, true},
+		{"public, max-age=300", 5 * time.Minute, true},
+		{"max-age=abc", time.Minute, true},
+		{"no-cache", 0, true},
+		{"No-Store", 0, false},
+		{"no-cache, no-store", 0, false},
+		{"no-cache, max-age=300", 0, true},
+		{"private, max-age=300", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cacheControl, func(t *testing.T) {
+			got, store := freshness(tt.cacheControl, time.Minute)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.store, store)
+		})
+	}
+}
@@ -1,121 +1,464 @@
+// Package client calls the external APIs data is synced from. Each named
+// upstream shares one connection pool, authentication and retry policy, so
+// adding a data source only means describing its endpoints.
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"api-gateway-backend/internal/cache"
 	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/logger"
 )
 
-// ExternalAPIClient handles external API requests
-type ExternalAPIClient struct {
-	client  *http.Client
-	baseURL string
+// retryBaseDelay is the backoff before the first retry, doubled per
+// attempt, for upstreams that don't set their own
+const retryBaseDelay = time.Second
+
+// Upstream is a client for one external API
+type Upstream struct {
+	name             string
+	client           *http.Client
+	balancer         *Balancer
+	timeout          time.Duration // per attempt
+	authMu           sync.RWMutex  // guards auth, whose secrets can be rotated
+	auth             config.UpstreamConfig
+	maxRetries       int
+	retryBase        time.Duration
+	retryMax         time.Duration  // 0 means no cap on a single backoff
+	retryStatus      map[int]bool   // nil retries 429 and every 5xx
+	maxRetryDuration time.Duration  // 0 means no cap
+	limiter          *Limiter       // nil without a client-side rate limit
+	tokens           *tokenSource   // set for OAuth2 upstreams
+	responses        *responseCache // set if GET responses are cached
+	pagination       config.PaginationConfig
+	observe          Observer       // nil if calls aren't observed
+	logger           *logger.Logger // nil if slow calls aren't logged
+	slowCall         time.Duration  // 0 disables slow call logs
 }
 
-// PostResponse represents a post from JSONPlaceholder API
-type PostResponse struct {
-	UserID int    `json:"userId"`
-	ID     int    `json:"id"`
-	Title  string `json:"title"`
-	Body   string `json:"body"`
+// Observer is told the outcome of each call to an upstream, after any
+// retries, e.g. to track the upstream's health. err is nil for a success.
+type Observer func(name string, err error, latency time.Duration)
+
+// Endpoint describes one request to an upstream. Zero values fall back to
+// a GET with the upstream's timeout.
+type Endpoint struct {
+	Method  string
+	Path    string // appended to the upstream base URL
+	Query   url.Values
+	Header  http.Header
+	Body    interface{} // JSON-encoded when set
+	Timeout time.Duration
 }
 
-// New creates a new external API client
-func New(cfg config.ExternalAPIConfig) *ExternalAPIClient {
-	return &ExternalAPIClient{
-		client: &http.Client{
-			Timeout: time.Duration(cfg.Timeout) * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:        10,
-				IdleConnTimeout:     30 * time.Second,
-				DisableCompression:  true,
-				MaxIdleConnsPerHost: 10,
-			},
-		},
-		baseURL: cfg.BaseURL,
+// New creates a client for the upstream described by cfg. OAuth2 access
+// tokens and, with cfg.CacheEnabled, GET responses are shared through
+// shared, which may be nil to keep tokens per instance and not cache
+// responses.
+func New(name string, cfg config.UpstreamConfig, shared cache.Cache) (*Upstream, error) {
+	balancer, err := NewBalancer(name, cfg.BaseURLs, cfg.Balancer)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{Transport: transport}
+
+	u := &Upstream{
+		name:             name,
+		client:           httpClient,
+		balancer:         balancer,
+		limiter:          NewLimiter(name, cfg.RateLimit),
+		timeout:          time.Duration(cfg.Timeout) * time.Second,
+		auth:             cfg,
+		maxRetries:       cfg.MaxRetries,
+		retryBase:        retryBaseDelay,
+		retryMax:         time.Duration(cfg.RetryMaxDelay) * time.Millisecond,
+		maxRetryDuration: time.Duration(cfg.MaxRetryDuration) * time.Second,
+		pagination:       cfg.Pagination,
+		slowCall:         time.Duration(cfg.SlowCallThreshold) * time.Millisecond,
+	}
+	if cfg.RetryBaseDelay > 0 {
+		u.retryBase = time.Duration(cfg.RetryBaseDelay) * time.Millisecond
+	}
+	if len(cfg.RetryStatusCodes) > 0 {
+		u.retryStatus = make(map[int]bool, len(cfg.RetryStatusCodes))
+		for _, code := range cfg.RetryStatusCodes {
+			u.retryStatus[code] = true
+		}
+	}
+	if cfg.AuthType == config.AuthOAuth2 {
+		u.tokens = newTokenSource(name, httpClient, cfg, shared)
+	}
+	if cfg.CacheEnabled && shared != nil {
+		u.responses = &responseCache{
+			cache:    shared,
+			ttl:      time.Duration(cfg.CacheTTL) * time.Second,
+			staleTTL: time.Duration(cfg.CacheStaleTTL) * time.Second,
+		}
 	}
+	return u, nil
 }
 
-// FetchPosts fetches posts from external API with retry logic
-func (c *ExternalAPIClient) FetchPosts(ctx context.Context) ([]PostResponse, error) {
-	url := fmt.Sprintf("%s/posts", c.baseURL)
+// Name returns the upstream's configured name
+func (u *Upstream) Name() string { return u.name }
 
-	var posts []PostResponse
-	err := c.retryRequest(ctx, url, &posts, 3)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch posts: %w", err)
+// SetAuthToken switches to a rotated bearer or header auth token
+func (u *Upstream) SetAuthToken(token string) {
+	u.authMu.Lock()
+	defer u.authMu.Unlock()
+	u.auth.AuthToken = token
+}
+
+// SetAuthPassword switches to a rotated basic auth password
+func (u *Upstream) SetAuthPassword(password string) {
+	u.authMu.Lock()
+	defer u.authMu.Unlock()
+	u.auth.AuthPassword = password
+}
+
+// SetOAuthClientSecret switches to a rotated OAuth2 client secret, used
+// from the next access token request on
+func (u *Upstream) SetOAuthClientSecret(secret string) {
+	if u.tokens != nil {
+		u.tokens.setClientSecret(secret)
 	}
+}
 
-	return posts, nil
+// Fetch performs ep against u and decodes the JSON response into a T
+func Fetch[T any](ctx context.Context, u *Upstream, ep Endpoint) (T, error) {
+	var dest T
+	err := u.Do(ctx, ep, &dest)
+	return dest, err
 }
 
-// retryRequest performs HTTP request with exponential backoff retry
-func (c *ExternalAPIClient) retryRequest(ctx context.Context, url string, dest interface{}, maxRetries int) error {
+// Do performs ep with exponential backoff retry and decodes the JSON
+// response into dest. Waits are jittered so clients failing together don't
+// retry together, a Retry-After from the upstream is honored, and no retry
+// is attempted that would run past the upstream's max retry duration. Each
+// attempt first queues for the upstream's rate limit, if it has one. With
+// response caching, a fresh cached GET response is used without calling
+// the upstream, and a stale one is revalidated with a conditional request.
+// Calls slower than the upstream's slow call threshold are logged.
+func (u *Upstream) Do(ctx context.Context, ep Endpoint, dest interface{}) (err error) {
+	key, cacheable := u.responseKey(ep)
+	var cached *cachedResponse
+	if cacheable {
+		if cached = u.responses.lookup(ctx, key); cached != nil && cached.fresh(time.Now()) {
+			if err := decode(cached.Body, dest); err == nil {
+				setLink(dest, cached.Link)
+				return nil
+			}
+			cached = nil
+		}
+	}
+
+	start := time.Now()
+	// A call the caller gave up on, or that never left because of the rate
+	// limit, says nothing about the upstream
+	if u.observe != nil {
+		defer func() {
+			if !errors.Is(err, context.Canceled) && !errors.Is(err, ErrThrottled) {
+				u.observe(u.name, err, time.Since(start))
+			}
+		}()
+	}
+	attempts := 0
+	defer func() { u.logSlowCall(ctx, ep, attempts, time.Since(start), err) }()
 	var lastErr error
+	var wait time.Duration
 
-	for attempt := 0; attempt <= maxRetries; attempt++ {
+	for attempt := 0; attempt <= u.maxRetries; attempt++ {
 		if attempt > 0 {
-			// Exponential backoff: 1s, 2s, 4s, 8s...
-			backoff := time.Duration(1<<uint(attempt-1)) * time.Second
+			upstreamRetries.Inc(u.name)
+			if u.maxRetryDuration > 0 && time.Since(start)+wait > u.maxRetryDuration {
+				return fmt.Errorf("%s: retry budget of %s exhausted, last error: %w", u.name, u.maxRetryDuration, lastErr)
+			}
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
-			case <-time.After(backoff):
+			case <-time.After(wait):
 			}
 		}
 
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		// Every attempt counts toward the upstream's quota
+		if err := u.limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("%s: %w", u.name, err)
+		}
+
+		attempts++
+		retry, retryAfter, err := u.do(ctx, ep, dest, key, cached)
+		if err == nil {
+			return nil
+		}
+		if !retry {
+			return fmt.Errorf("%s: %w", u.name, err)
+		}
+		lastErr = err
+
+		// Exponential backoff: 1s, 2s, 4s, 8s... by default, up to the max
+		// delay, less up to half as jitter
+		backoff := u.retryBase << uint(attempt)
+		if u.retryMax > 0 && (backoff > u.retryMax || backoff <= 0) {
+			backoff = u.retryMax
+		}
+		wait = backoff - jitter(backoff/2)
+		if retryAfter > 0 {
+			wait = retryAfter + jitter(u.retryBase)
+		}
+	}
+
+	return fmt.Errorf("%s: max retries exceeded, last error: %w", u.name, lastErr)
+}
+
+// do performs a single attempt and decodes a successful response into
+// dest, caching it at key if set. A stale cached response is revalidated
+// and used if the upstream reports it unchanged. On failure it reports
+// whether the request may be retried and any delay the upstream asked for.
+func (u *Upstream) do(ctx context.Context, ep Endpoint, dest interface{}, key string, stale *cachedResponse) (bool, time.Duration, error) {
+	timeout := ep.Timeout
+	if timeout <= 0 {
+		timeout = u.timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var token string
+	if u.tokens != nil {
+		var err error
+		if token, err = u.tokens.Token(ctx); err != nil {
+			return true, 0, fmt.Errorf("failed to get access token: %w", err)
+		}
+	}
+
+	// Each attempt is balanced separately, so retries can land elsewhere
+	backend := u.balancer.Pick()
+	req, err := u.newRequest(ctx, backend, ep, token)
+	if err != nil {
+		u.balancer.Done(backend, false)
+		return false, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if stale != nil && stale.ETag != "" {
+		req.Header.Set("If-None-Match", stale.ETag)
+	}
+	if stale != nil && stale.LastModified != "" {
+		req.Header.Set("If-Modified-Since", stale.LastModified)
+	}
+
+	sent := time.Now()
+	resp, err := u.client.Do(req)
+	u.balancer.Done(backend, Failed(resp, err))
+	upstreamDuration.Observe(time.Since(sent).Seconds(), u.name)
+	if err != nil {
+		upstreamRequests.Inc(u.name, statusLabel(0))
+		return true, 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	upstreamRequests.Inc(u.name, statusLabel(resp.StatusCode))
+
+	// The cached response is still current, and fresh again
+	if resp.StatusCode == http.StatusNotModified && stale != nil {
+		header := resp.Header.Clone()
+		if header.Get("ETag") == "" && stale.ETag != "" {
+			header.Set("ETag", stale.ETag)
+		}
+		if header.Get("Last-Modified") == "" && stale.LastModified != "" {
+			header.Set("Last-Modified", stale.LastModified)
+		}
+		if header.Get("Link") == "" && stale.Link != "" {
+			header.Set("Link", stale.Link)
+		}
+		u.responses.store(ctx, key, stale.Body, header, time.Now())
+		if err := decode(stale.Body, dest); err != nil {
+			return false, 0, fmt.Errorf("failed to unmarshal cached response: %w", err)
+		}
+		setLink(dest, header.Get("Link"))
+		return false, 0, nil
+	}
+
+	// Check for successful status codes
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return fmt.Errorf("failed to create request: %w", err)
+			return true, 0, fmt.Errorf("failed to read response body: %w", err)
+		}
+
+		if err := decode(body, dest); err != nil {
+			return true, 0, fmt.Errorf("failed to unmarshal response: %w", err)
 		}
+		setLink(dest, resp.Header.Get("Link"))
+		if key != "" {
+			u.responses.store(ctx, key, body, resp.Header, time.Now())
+		}
+
+		return false, 0, nil
+	}
 
-		req.Header.Set("Accept", "application/json")
-		req.Header.Set("User-Agent", "API-Gateway-Backend/1.0")
+	// Drain the error body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
 
-		resp, err := c.client.Do(req)
+	var retryAfter time.Duration
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		retryAfter, _ = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+
+	// Handle different HTTP error codes
+	switch {
+	case resp.StatusCode >= 500:
+		// Server errors - retry unless the upstream's policy says otherwise
+		return u.retryable(resp.StatusCode), retryAfter, fmt.Errorf("server error: %d", resp.StatusCode)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		// Rate limited - retry once the upstream allows it
+		return u.retryable(resp.StatusCode), retryAfter, fmt.Errorf("rate limited: %d", resp.StatusCode)
+	case resp.StatusCode == http.StatusUnauthorized && u.tokens != nil:
+		// Token revoked or expired early - retry with a fresh one
+		u.tokens.Invalidate(ctx)
+		return true, 0, fmt.Errorf("unauthorized: %d", resp.StatusCode)
+	case resp.StatusCode >= 400:
+		// Client errors - don't retry unless the upstream's policy says so
+		return u.retryable(resp.StatusCode), 0, fmt.Errorf("client error: %d", resp.StatusCode)
+	default:
+		return true, 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+}
+
+// retryable reports whether a response with the error status code may be
+// retried: by default 429 and every 5xx, or the upstream's own list
+func (u *Upstream) retryable(code int) bool {
+	if u.retryStatus != nil {
+		return u.retryStatus[code]
+	}
+	return code >= 500 || code == http.StatusTooManyRequests
+}
+
+// logSlowCall logs a call to ep that took longer than the slow call
+// threshold over its attempts, failing with err if set
+func (u *Upstream) logSlowCall(ctx context.Context, ep Endpoint, attempts int, elapsed time.Duration, err error) {
+	if u.logger == nil || u.slowCall <= 0 || elapsed < u.slowCall {
+		return
+	}
+	method := ep.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	entry := u.logger.FromContext(ctx).WithFields(map[string]interface{}{
+		"upstream":    u.name,
+		"method":      method,
+		"path":        ep.Path,
+		"attempts":    attempts,
+		"duration_ms": elapsed.Milliseconds(),
+	})
+	if err != nil {
+		entry = entry.WithError(err)
+	}
+	entry.Warn("Slow upstream call")
+}
+
+// newRequest builds the HTTP request for one attempt at ep against
+// backend. token is the OAuth2 access token for upstreams that use one.
+func (u *Upstream) newRequest(ctx context.Context, backend *Backend, ep Endpoint, token string) (*http.Request, error) {
+	method := ep.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	target := backend.Resolve(&url.URL{Path: ep.Path, RawQuery: ep.Query.Encode()}).String()
+
+	var body io.Reader
+	if ep.Body != nil {
+		data, err := json.Marshal(ep.Body)
 		if err != nil {
-			lastErr = fmt.Errorf("request failed: %w", err)
-			continue
+			return nil, err
 		}
+		body = bytes.NewReader(data)
+	}
 
-		defer resp.Body.Close()
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
 
-		// Check for successful status codes
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				lastErr = fmt.Errorf("failed to read response body: %w", err)
-				continue
-			}
+	u.authMu.RLock()
+	auth := u.auth
+	u.authMu.RUnlock()
 
-			if err := json.Unmarshal(body, dest); err != nil {
-				lastErr = fmt.Errorf("failed to unmarshal response: %w", err)
-				continue
-			}
+	for key, value := range auth.Headers {
+		req.Header.Set(key, value)
+	}
+	for key, values := range ep.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "API-Gateway-Backend/1.0")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if id := logger.RequestIDFromContext(ctx); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
 
-			return nil
+	switch auth.AuthType {
+	case config.AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+auth.AuthToken)
+	case config.AuthHeader:
+		req.Header.Set(auth.AuthHeader, auth.AuthToken)
+	case config.AuthBasic:
+		req.SetBasicAuth(auth.AuthUsername, auth.AuthPassword)
+	case config.AuthOAuth2:
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return req, nil
+}
+
+// parseRetryAfter reads a Retry-After header in either its delay-seconds or
+// HTTP-date form. A date in the past means retry immediately.
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
 		}
+		return time.Duration(seconds) * time.Second, true
+	}
 
-		// Handle different HTTP error codes
-		switch {
-		case resp.StatusCode >= 500:
-			// Server errors - retry
-			lastErr = fmt.Errorf("server error: %d", resp.StatusCode)
-		case resp.StatusCode == 429:
-			// Rate limited - retry with longer backoff
-			lastErr = fmt.Errorf("rate limited: %d", resp.StatusCode)
-		case resp.StatusCode >= 400:
-			// Client errors - don't retry
-			return fmt.Errorf("client error: %d", resp.StatusCode)
-		default:
-			lastErr = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if t, err := http.ParseTime(header); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
 		}
+		return 0, true
 	}
 
-	return fmt.Errorf("max retries exceeded, last error: %w", lastErr)
-}
\ No newline at end of file
+	return 0, false
+}
+
+// jitter returns a random duration in [0, max)
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
@@ -0,0 +1,361 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/logger"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(baseURL string) *Upstream {
+	u, _ := New("test", config.UpstreamConfig{BaseURLs: []string{baseURL}, Timeout: 5, MaxRetries: 3}, nil)
+	u.retryBase = time.Millisecond
+	return u
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{header: "", ok: false},
+		{header: "3", want: 3 * time.Second, ok: true},
+		{header: " 0 ", want: 0, ok: true},
+		{header: "-1", ok: false},
+		{header: "Mon, 15 Jan 2024 10:30:20 GMT", want: 20 * time.Second, ok: true},
+		{header: "Mon, 15 Jan 2024 10:29:00 GMT", want: 0, ok: true},
+		{header: "soon", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.header, now)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRetryRequest_HonorsRetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[{"userId":1,"id":1,"title":"t","body":"b"}]`))
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	posts, err := FetchPosts(context.Background(), newTestClient(server.URL))
+	assert.NoError(t, err)
+	assert.Len(t, posts, 1)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestUpstream_Instrumentation(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	u, err := New("instrumented", config.UpstreamConfig{BaseURLs: []string{server.URL}, Timeout: 5, MaxRetries: 1}, nil)
+	require.NoError(t, err)
+	u.retryBase = time.Millisecond
+	u.logger = logger.New()
+	u.logger.SetOutput(io.Discard)
+	hook := logtest.NewLocal(u.logger.Logger)
+
+	// Every attempt is counted by status, and the call logged as slow
+	// against a tiny threshold
+	u.slowCall = time.Nanosecond
+	_, err = FetchPosts(context.Background(), u)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), upstreamRequests.Value("instrumented", "503"))
+	assert.Equal(t, float64(1), upstreamRequests.Value("instrumented", "200"))
+	assert.Equal(t, float64(1), upstreamRetries.Value("instrumented"))
+	assert.Equal(t, uint64(2), upstreamDuration.Count("instrumented"))
+	if entry := hook.LastEntry(); assert.NotNil(t, entry) {
+		assert.Equal(t, "Slow upstream call", entry.Message)
+		assert.Equal(t, 2, entry.Data["attempts"])
+		assert.Equal(t, "/posts", entry.Data["path"])
+	}
+
+	hook.Reset()
+	u.slowCall = time.Minute
+	_, err = FetchPosts(context.Background(), u)
+	require.NoError(t, err)
+	assert.Empty(t, hook.AllEntries())
+}
+
+func TestRetryRequest_StatusCodes(t *testing.T) {
+	var calls, status int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	u, err := New("test", config.UpstreamConfig{
+		BaseURLs: []string{server.URL}, Timeout: 5, MaxRetries: 2,
+		RetryBaseDelay: 1, RetryMaxDelay: 2, RetryStatusCodes: []int{502, 409},
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Millisecond, u.retryMax)
+
+	// Listed codes are retried, even client errors, and others aren't
+	for _, tt := range []struct {
+		status int32
+		calls  int32
+	}{{502, 3}, {409, 3}, {503, 1}, {429, 1}} {
+		atomic.StoreInt32(&calls, 0)
+		atomic.StoreInt32(&status, tt.status)
+		_, err := FetchPosts(context.Background(), u)
+		assert.Error(t, err)
+		assert.Equal(t, tt.calls, atomic.LoadInt32(&calls), "status %d", tt.status)
+	}
+}
+
+func TestRetryRequest_RetryBudget(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	c := newTestClient(server.URL)
+	c.maxRetryDuration = time.Second
+
+	start := time.Now()
+	_, err := FetchPosts(context.Background(), c)
+	assert.ErrorContains(t, err, "retry budget")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestRetryRequest_ClientErrorNotRetried(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := FetchPosts(context.Background(), newTestClient(server.URL))
+	assert.ErrorContains(t, err, "client error: 404")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestJitter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), jitter(0))
+	for i := 0; i < 100; i++ {
+		d := jitter(time.Second)
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.Less(t, d, time.Second)
+	}
+}
+
+func TestFetch_Endpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v2/users", r.URL.Path)
+		assert.Equal(t, "10", r.URL.Query().Get("limit"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.Equal(t, "yes", r.Header.Get("X-Custom"))
+
+		var body map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "active", body["status"])
+
+		w.Write([]byte(`{"users":["a","b"]}`))
+	}))
+	defer server.Close()
+
+	u, err := New("crm", config.UpstreamConfig{
+		BaseURLs:  []string{server.URL + "/v2/"},
+		AuthType:  config.AuthBearer,
+		AuthToken: "secret",
+	}, nil)
+	assert.NoError(t, err)
+
+	type users struct {
+		Users []string `json:"users"`
+	}
+	got, err := Fetch[users](context.Background(), u, Endpoint{
+		Method: http.MethodPost,
+		Path:   "/users",
+		Query:  url.Values{"limit": {"10"}},
+		Header: http.Header{"X-Custom": {"yes"}},
+		Body:   map[string]string{"status": "active"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, got.Users)
+}
+
+func TestFetch_Auth(t *testing.T) {
+	tests := []struct {
+		cfg   config.UpstreamConfig
+		check func(t *testing.T, r *http.Request)
+	}{
+		{
+			cfg: config.UpstreamConfig{AuthType: config.AuthHeader, AuthHeader: "X-API-Key", AuthToken: "k"},
+			check: func(t *testing.T, r *http.Request) {
+				assert.Equal(t, "k", r.Header.Get("X-API-Key"))
+			},
+		},
+		{
+			cfg: config.UpstreamConfig{Headers: map[string]string{"X-Tenant": "acme"}},
+			check: func(t *testing.T, r *http.Request) {
+				assert.Equal(t, "acme", r.Header.Get("X-Tenant"))
+			},
+		},
+		{
+			cfg: config.UpstreamConfig{AuthType: config.AuthBasic, AuthUsername: "user", AuthPassword: "pass"},
+			check: func(t *testing.T, r *http.Request) {
+				user, pass, ok := r.BasicAuth()
+				assert.True(t, ok)
+				assert.Equal(t, "user", user)
+				assert.Equal(t, "pass", pass)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cfg.AuthType, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.check(t, r)
+			}))
+			defer server.Close()
+
+			tt.cfg.BaseURLs = []string{server.URL}
+			u, err := New("test", tt.cfg, nil)
+			assert.NoError(t, err)
+			assert.NoError(t, u.Do(context.Background(), Endpoint{Path: "/"}, nil))
+		})
+	}
+}
+
+func TestFetch_EndpointTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	u := newTestClient(server.URL)
+	u.maxRetries = 0
+
+	err := u.Do(context.Background(), Endpoint{Path: "/slow", Timeout: 20 * time.Millisecond}, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestNewRegistry(t *testing.T) {
+	r, err := NewRegistry(config.ExternalAPIConfig{Upstreams: map[string]config.UpstreamConfig{
+		config.DefaultUpstream: {BaseURLs: []string{"https://example.com"}},
+		"crm":                  {BaseURLs: []string{"https://crm.example.com"}, AuthType: config.AuthBearer},
+	}}, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"crm", "default"}, r.Names())
+
+	u, err := r.Get("crm")
+	assert.NoError(t, err)
+	assert.Equal(t, "crm", u.Name())
+
+	_, err = r.Get("billing")
+	assert.Error(t, err)
+
+	_, err = NewRegistry(config.ExternalAPIConfig{Upstreams: map[string]config.UpstreamConfig{
+		"crm": {BaseURLs: []string{"https://crm.example.com"}, AuthType: "oauth"},
+	}}, nil, nil)
+	assert.Error(t, err)
+
+	_, err = NewRegistry(config.ExternalAPIConfig{Upstreams: map[string]config.UpstreamConfig{
+		"crm": {BaseURLs: []string{"https://crm.example.com"}, AuthType: config.AuthOAuth2},
+	}}, nil, nil)
+	assert.Error(t, err)
+
+	_, err = NewRegistry(config.ExternalAPIConfig{Upstreams: map[string]config.UpstreamConfig{
+		"crm": {},
+	}}, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestRegistry_Observe(t *testing.T) {
+	var fail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	r, err := NewRegistry(config.ExternalAPIConfig{Upstreams: map[string]config.UpstreamConfig{
+		"crm": {BaseURLs: []string{server.URL}, Timeout: 5},
+	}}, nil, nil)
+	assert.NoError(t, err)
+	var outcomes []error
+	r.Observe(func(name string, err error, latency time.Duration) {
+		assert.Equal(t, "crm", name)
+		outcomes = append(outcomes, err)
+	})
+	u, _ := r.Get("crm")
+
+	_, err = FetchPosts(context.Background(), u)
+	assert.NoError(t, err)
+	fail.Store(true)
+	_, err = FetchPosts(context.Background(), u)
+	assert.Error(t, err)
+
+	// Calls the caller cancelled aren't counted
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = FetchPosts(ctx, u)
+	assert.Error(t, err)
+
+	if assert.Len(t, outcomes, 2) {
+		assert.NoError(t, outcomes[0])
+		assert.Error(t, outcomes[1])
+	}
+}
+
+func TestFetchUsers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/users", r.URL.Path)
+		w.Write([]byte(`[{"id":1,"name":"Leanne Graham","username":"Bret","company":{"name":"Romaguera-Crona"}}]`))
+	}))
+	defer server.Close()
+
+	users, err := FetchUsers(context.Background(), newTestClient(server.URL))
+	assert.NoError(t, err)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "Bret", users[0].Username)
+		assert.Equal(t, "Romaguera-Crona", users[0].Company.Name)
+	}
+}
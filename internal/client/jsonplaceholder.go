@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+// JSONPlaceholder-compatible resource endpoints
+var (
+	postsEndpoint    = Endpoint{Path: "/posts"}
+	usersEndpoint    = Endpoint{Path: "/users"}
+	commentsEndpoint = Endpoint{Path: "/comments"}
+	todosEndpoint    = Endpoint{Path: "/todos"}
+)
+
+// PostResponse represents a post from JSONPlaceholder API
+type PostResponse struct {
+	UserID int    `json:"userId"`
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+// UserResponse represents a user from JSONPlaceholder API
+type UserResponse struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Phone    string `json:"phone"`
+	Website  string `json:"website"`
+	Company  struct {
+		Name string `json:"name"`
+	} `json:"company"`
+}
+
+// CommentResponse represents a comment from JSONPlaceholder API
+type CommentResponse struct {
+	PostID int    `json:"postId"`
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Body   string `json:"body"`
+}
+
+// TodoResponse represents a todo from JSONPlaceholder API
+type TodoResponse struct {
+	UserID    int    `json:"userId"`
+	ID        int    `json:"id"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+}
+
+// FetchPosts fetches posts from u with retry logic, following pagination
+func FetchPosts(ctx context.Context, u *Upstream) ([]PostResponse, error) {
+	posts, err := fetchAll[PostResponse](ctx, u, postsEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch posts: %w", err)
+	}
+
+	return posts, nil
+}
+
+// FetchPostPages fetches posts from u page by page, handing each page to
+// fn as it arrives
+func FetchPostPages(ctx context.Context, u *Upstream, fn func(posts []PostResponse) error) error {
+	if err := FetchPages(ctx, u, postsEndpoint, fn); err != nil {
+		return fmt.Errorf("failed to fetch posts: %w", err)
+	}
+
+	return nil
+}
+
+// FetchUsers fetches users from u with retry logic, following pagination
+func FetchUsers(ctx context.Context, u *Upstream) ([]UserResponse, error) {
+	users, err := fetchAll[UserResponse](ctx, u, usersEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %w", err)
+	}
+
+	return users, nil
+}
+
+// FetchComments fetches comments from u with retry logic, following pagination
+func FetchComments(ctx context.Context, u *Upstream) ([]CommentResponse, error) {
+	comments, err := fetchAll[CommentResponse](ctx, u, commentsEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch comments: %w", err)
+	}
+
+	return comments, nil
+}
+
+// FetchTodos fetches todos from u with retry logic, following pagination
+func FetchTodos(ctx context.Context, u *Upstream) ([]TodoResponse, error) {
+	todos, err := fetchAll[TodoResponse](ctx, u, todosEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch todos: %w", err)
+	}
+
+	return todos, nil
+}
+
+// fetchAll fetches every page of the collection at ep
+func fetchAll[T any](ctx context.Context, u *Upstream, ep Endpoint) ([]T, error) {
+	var all []T
+	err := FetchPages(ctx, u, ep, func(items []T) error {
+		all = append(all, items...)
+		return nil
+	})
+	return all, err
+}
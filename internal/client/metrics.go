@@ -0,0 +1,33 @@
+package client
+
+import (
+	"strconv"
+
+	"api-gateway-backend/internal/metrics"
+)
+
+// Upstream call metrics, labelled by upstream name. Backend ejection is
+// also tracked for proxy routes, labelled by route name.
+var (
+	upstreamRequests = metrics.NewCounterVec("upstream_requests_total",
+		"Requests sent to upstreams, retries included, by response status code, or error when none came back.", "upstream", "status")
+	upstreamDuration = metrics.NewHistogramVec("upstream_request_duration_seconds",
+		"Time taken by each request to an upstream until its response headers.", metrics.DefaultBuckets, "upstream")
+	upstreamRetries = metrics.NewCounterVec("upstream_retries_total",
+		"Requests to upstreams retried after a failed attempt.", "upstream")
+	backendEjected = metrics.NewGaugeVec("upstream_backend_ejected",
+		"Whether a backend is out of rotation after failing repeatedly (1) or in rotation (0).", "upstream", "backend")
+)
+
+func init() {
+	metrics.Default.Register(upstreamRequests, upstreamDuration, upstreamRetries, backendEjected)
+}
+
+// statusLabel returns the status label of a request that got code back,
+// or failed without a response if code is 0
+func statusLabel(code int) string {
+	if code == 0 {
+		return "error"
+	}
+	return strconv.Itoa(code)
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fixtures holds the resources MockTransport serves, one JSON array per
+// resource named after it
+//
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// MockTransport answers upstream requests in process with the embedded
+// fixture data, the way JSONPlaceholder would, so the gateway runs without
+// network access. GET /<resource> lists a resource, filtered by any query
+// parameters naming a field and paged by _page and _limit with a Link
+// header, and GET /<resource>/<id> returns one entry; any base URL path is
+// ignored. OAuth2 token requests are granted, and anything else is 404.
+type MockTransport struct {
+	once      sync.Once
+	resources map[string][]map[string]interface{}
+	err       error
+}
+
+func (t *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+
+	if req.Method == http.MethodPost && req.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		return mockResponse(req, http.StatusOK, nil, map[string]interface{}{
+			"access_token": "mock-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return mockResponse(req, http.StatusMethodNotAllowed, nil, map[string]string{"error": "fixtures are read-only"})
+	}
+
+	// The resource is the last path segment, or the one before an ID
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	name, id := segments[len(segments)-1], ""
+	if _, err := strconv.Atoi(name); err == nil && len(segments) > 1 {
+		name, id = segments[len(segments)-2], name
+	}
+	entries, ok := t.resources[name]
+	if !ok {
+		return mockResponse(req, http.StatusNotFound, nil, map[string]string{})
+	}
+	if id != "" {
+		for _, entry := range entries {
+			if fmt.Sprint(entry["id"]) == id {
+				return mockResponse(req, http.StatusOK, nil, entry)
+			}
+		}
+		return mockResponse(req, http.StatusNotFound, nil, map[string]string{})
+	}
+
+	query := req.URL.Query()
+	matched := make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		if mockMatches(entry, query) {
+			matched = append(matched, entry)
+		}
+	}
+
+	header := http.Header{"X-Total-Count": {strconv.Itoa(len(matched))}}
+	limit, _ := strconv.Atoi(query.Get("_limit"))
+	if limit <= 0 {
+		return mockResponse(req, http.StatusOK, header, matched)
+	}
+	page, _ := strconv.Atoi(query.Get("_page"))
+	page = max(page, 1)
+	start := min((page-1)*limit, len(matched))
+	end := min(start+limit, len(matched))
+	if end < len(matched) {
+		next := *req.URL
+		q := next.Query()
+		q.Set("_page", strconv.Itoa(page+1))
+		next.RawQuery = q.Encode()
+		header.Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	}
+	return mockResponse(req, http.StatusOK, header, matched[start:end])
+}
+
+// load parses the fixtures once
+func (t *MockTransport) load() error {
+	t.once.Do(func() {
+		files, err := fixtures.ReadDir("fixtures")
+		if err != nil {
+			t.err = err
+			return
+		}
+		t.resources = make(map[string][]map[string]interface{}, len(files))
+		for _, file := range files {
+			data, err := fixtures.ReadFile("fixtures/" + file.Name())
+			if err != nil {
+				t.err = err
+				return
+			}
+			var entries []map[string]interface{}
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.UseNumber()
+			if err := decoder.Decode(&entries); err != nil {
+				t.err = fmt.Errorf("invalid fixture %s: %w", file.Name(), err)
+				return
+			}
+			t.resources[strings.TrimSuffix(file.Name(), ".json")] = entries
+		}
+	})
+	return t.err
+}
+
+// mockMatches reports whether entry has every field query filters on.
+// Parameters starting with _ control paging rather than filter.
+func mockMatches(entry map[string]interface{}, query map[string][]string) bool {
+	for name, values := range query {
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+		value, ok := entry[name]
+		if !ok || fmt.Sprint(value) != values[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// mockResponse builds a JSON response to req
+func mockResponse(req *http.Request, status int, header http.Header, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	if req.Method == http.MethodHead {
+		data = nil
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"api-gateway-backend/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockRegistry(t *testing.T, upstream config.UpstreamConfig) *Upstream {
+	// Nothing listens here; every call must be answered in process
+	upstream.BaseURLs = []string{"http://127.0.0.1:1/api"}
+	upstream.Timeout = 5
+	r, err := NewRegistry(config.ExternalAPIConfig{
+		Mode:      config.ExternalAPIMock,
+		Upstreams: map[string]config.UpstreamConfig{"default": upstream},
+	}, nil, nil)
+	require.NoError(t, err)
+	u, err := r.Get("default")
+	require.NoError(t, err)
+	return u
+}
+
+func TestMockTransport(t *testing.T) {
+	u := newMockRegistry(t, config.UpstreamConfig{})
+	ctx := context.Background()
+
+	posts, err := FetchPosts(ctx, u)
+	require.NoError(t, err)
+	assert.Len(t, posts, 20)
+	users, err := FetchUsers(ctx, u)
+	require.NoError(t, err)
+	assert.Len(t, users, 5)
+
+	// Single entries and filters
+	post, err := Fetch[PostResponse](ctx, u, Endpoint{Path: "/posts/3"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, post.ID)
+	comments, err := Fetch[[]CommentResponse](ctx, u, Endpoint{Path: "/comments", Query: map[string][]string{"postId": {"3"}}})
+	require.NoError(t, err)
+	assert.Len(t, comments, 2)
+	for _, c := range comments {
+		assert.Equal(t, 3, c.PostID)
+	}
+
+	// Unknown resources and entries, and writes, are refused
+	_, err = Fetch[PostResponse](ctx, u, Endpoint{Path: "/posts/999"})
+	assert.ErrorContains(t, err, "404")
+	_, err = Fetch[[]PostResponse](ctx, u, Endpoint{Path: "/albums"})
+	assert.ErrorContains(t, err, "404")
+	err = u.Do(ctx, Endpoint{Method: http.MethodPost, Path: "/posts", Body: PostResponse{}}, nil)
+	assert.ErrorContains(t, err, "405")
+}
+
+func TestMockTransport_Paginated(t *testing.T) {
+	u := newMockRegistry(t, config.UpstreamConfig{Pagination: config.PaginationConfig{
+		Strategy: config.PaginatePage, PageParam: "_page", LimitParam: "_limit", PageSize: 7, MaxPages: 10,
+	}})
+
+	var sizes, ids []int
+	require.NoError(t, FetchPostPages(context.Background(), u, func(posts []PostResponse) error {
+		sizes = append(sizes, len(posts))
+		for _, p := range posts {
+			ids = append(ids, p.ID)
+		}
+		return nil
+	}))
+	assert.Equal(t, []int{7, 7, 6}, sizes)
+	require.Len(t, ids, 20)
+	assert.Equal(t, 20, ids[19])
+}
+
+func TestMockTransport_OAuth2(t *testing.T) {
+	u := newMockRegistry(t, config.UpstreamConfig{
+		AuthType:          config.AuthOAuth2,
+		OAuthTokenURL:     "http://127.0.0.1:1/oauth/token",
+		OAuthClientID:     "gateway",
+		OAuthClientSecret: "s3cret",
+	})
+
+	posts, err := FetchPosts(context.Background(), u)
+	require.NoError(t, err)
+	assert.Len(t, posts, 20)
+}
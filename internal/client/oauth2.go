@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"api-gateway-backend/internal/cache"
+	"api-gateway-backend/internal/config"
+)
+
+const (
+	// tokenKeyPrefix namespaces access tokens shared through the cache
+	tokenKeyPrefix = "upstream:token:"
+
+	// tokenExpiryMargin refreshes tokens this long before they expire, so
+	// a token never lapses between being read and the request landing
+	tokenExpiryMargin = 30 * time.Second
+
+	// defaultTokenLifetime applies when the token response omits expires_in
+	defaultTokenLifetime = 5 * time.Minute
+)
+
+// accessToken is an OAuth2 access token and when it stops being usable
+type accessToken struct {
+	Value     string    `json:"value"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// valid reports whether the token can still be sent at now
+func (t accessToken) valid(now time.Time) bool {
+	return t.Value != "" && now.Add(tokenExpiryMargin).Before(t.ExpiresAt)
+}
+
+// tokenSource fetches OAuth2 client-credentials tokens for an upstream.
+// Tokens are kept in memory and, when a cache is set, shared through it so
+// every instance doesn't request its own.
+type tokenSource struct {
+	upstream string
+	client   *http.Client
+	cfg      config.UpstreamConfig
+	cache    cache.Cache // may be nil
+
+	mu    sync.Mutex
+	token accessToken
+}
+
+// newTokenSource creates a token source for the upstream's OAuth2 settings
+func newTokenSource(upstream string, httpClient *http.Client, cfg config.UpstreamConfig, tokens cache.Cache) *tokenSource {
+	return &tokenSource{
+		upstream: upstream,
+		client:   httpClient,
+		cfg:      cfg,
+		cache:    tokens,
+	}
+}
+
+// Token returns a valid access token, fetching a new one when the current
+// token is missing or about to expire
+func (s *tokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.token.valid(now) {
+		return s.token.Value, nil
+	}
+
+	if s.cache != nil {
+		var cached accessToken
+		if err := s.cache.Get(ctx, s.cacheKey(), &cached); err == nil && cached.valid(now) {
+			s.token = cached
+			return cached.Value, nil
+		}
+	}
+
+	token, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+
+	if ttl := time.Until(token.ExpiresAt) - tokenExpiryMargin; s.cache != nil && ttl > 0 {
+		// Failing to share the token only costs other instances a fetch
+		_ = s.cache.Set(ctx, s.cacheKey(), token, ttl)
+	}
+
+	return token.Value, nil
+}
+
+// Invalidate drops the current token after the upstream rejected it, so
+// the next request fetches a fresh one
+func (s *tokenSource) Invalidate(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = accessToken{}
+	if s.cache != nil {
+		_ = s.cache.Delete(ctx, s.cacheKey())
+	}
+}
+
+// setClientSecret switches to a rotated client secret. The current token
+// stays in use until it expires or is rejected.
+func (s *tokenSource) setClientSecret(secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg.OAuthClientSecret = secret
+}
+
+func (s *tokenSource) cacheKey() string {
+	return tokenKeyPrefix + s.upstream
+}
+
+// fetch requests a new token with the client credentials grant
+func (s *tokenSource) fetch(ctx context.Context) (accessToken, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.cfg.OAuthScopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.OAuthScopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.OAuthTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return accessToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.cfg.OAuthClientID), url.QueryEscape(s.cfg.OAuthClientSecret))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return accessToken{}, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return accessToken{}, fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return accessToken{}, fmt.Errorf("token endpoint returned %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return accessToken{}, fmt.Errorf("failed to unmarshal token response: %w", err)
+	}
+	if payload.AccessToken == "" {
+		return accessToken{}, errors.New("token response has no access_token")
+	}
+	if payload.TokenType != "" && !strings.EqualFold(payload.TokenType, "bearer") {
+		return accessToken{}, fmt.Errorf("unsupported token type %q", payload.TokenType)
+	}
+
+	lifetime := defaultTokenLifetime
+	if payload.ExpiresIn > 0 {
+		lifetime = time.Duration(payload.ExpiresIn) * time.Second
+	}
+
+	return accessToken{Value: payload.AccessToken, ExpiresAt: time.Now().Add(lifetime)}, nil
+}
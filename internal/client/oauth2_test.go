@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/cache"
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/logger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTokenServer issues numbered tokens valid for expiresIn seconds
+func newTokenServer(t *testing.T, expiresIn int, issued *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "read write", r.PostForm.Get("scope"))
+		id, secret, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "gateway", id)
+		assert.Equal(t, "s3cret", secret)
+
+		n := atomic.AddInt32(issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":%d}`, n, expiresIn)
+	}))
+}
+
+func oauthConfig(baseURL, tokenURL string) config.UpstreamConfig {
+	return config.UpstreamConfig{
+		BaseURLs:          []string{baseURL},
+		MaxRetries:        1,
+		AuthType:          config.AuthOAuth2,
+		OAuthTokenURL:     tokenURL,
+		OAuthClientID:     "gateway",
+		OAuthClientSecret: "s3cret",
+		OAuthScopes:       []string{"read", "write"},
+	}
+}
+
+func TestFetch_OAuth2(t *testing.T) {
+	var issued int32
+	tokens := newTokenServer(t, 3600, &issued)
+	defer tokens.Close()
+
+	var seen []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+	}))
+	defer api.Close()
+
+	shared := cache.NewLRU(10, logger.New())
+	cfg := oauthConfig(api.URL, tokens.URL)
+
+	u, err := New("crm", cfg, shared)
+	assert.NoError(t, err)
+	assert.NoError(t, u.Do(context.Background(), Endpoint{Path: "/"}, nil))
+	assert.NoError(t, u.Do(context.Background(), Endpoint{Path: "/"}, nil))
+
+	// A second instance picks the token up from the shared cache
+	other, err := New("crm", cfg, shared)
+	assert.NoError(t, err)
+	assert.NoError(t, other.Do(context.Background(), Endpoint{Path: "/"}, nil))
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&issued))
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-1", "Bearer token-1"}, seen)
+}
+
+func TestFetch_OAuth2RefreshesExpiringToken(t *testing.T) {
+	var issued int32
+	// Tokens inside the expiry margin are refreshed before every request
+	tokens := newTokenServer(t, int(tokenExpiryMargin/time.Second)-1, &issued)
+	defer tokens.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer api.Close()
+
+	u, err := New("crm", oauthConfig(api.URL, tokens.URL), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, u.Do(context.Background(), Endpoint{Path: "/"}, nil))
+	assert.NoError(t, u.Do(context.Background(), Endpoint{Path: "/"}, nil))
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&issued))
+}
+
+func TestFetch_OAuth2RetriesUnauthorized(t *testing.T) {
+	var issued int32
+	tokens := newTokenServer(t, 3600, &issued)
+	defer tokens.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+
+	shared := cache.NewLRU(10, logger.New())
+	u, err := New("crm", oauthConfig(api.URL, tokens.URL), shared)
+	assert.NoError(t, err)
+	u.retryBase = time.Millisecond
+
+	assert.NoError(t, u.Do(context.Background(), Endpoint{Path: "/"}, nil))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&issued))
+
+	var cached accessToken
+	assert.NoError(t, shared.Get(context.Background(), tokenKeyPrefix+"crm", &cached))
+	assert.Equal(t, "token-2", cached.Value)
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"api-gateway-backend/internal/config"
+)
+
+// ErrTooManyPages is returned for a collection with more pages than its
+// upstream's max pages, rather than handing on a truncated collection
+var ErrTooManyPages = errors.New("too many pages")
+
+// page is one raw page of a collection, with the Link header it came with
+type page struct {
+	body json.RawMessage
+	link string
+}
+
+func (p *page) UnmarshalJSON(data []byte) error {
+	p.body = append(p.body[:0], data...)
+	return nil
+}
+
+// setLink hands the Link header of a response to dest if it is a page
+func setLink(dest interface{}, link string) {
+	if p, ok := dest.(*page); ok {
+		p.link = link
+	}
+}
+
+// FetchPages fetches the collection at ep page by page, as u's pagination
+// config describes, handing the items of each page to fn as it arrives. It
+// stops at the first error, including one from fn. Pages named by a Link
+// header are fetched from ep's path with the link's query, so they go
+// through the upstream's balancing and auth like the first.
+func FetchPages[T any](ctx context.Context, u *Upstream, ep Endpoint, fn func(items []T) error) error {
+	p := u.pagination
+	query := url.Values{}
+	for name, values := range ep.Query {
+		query[name] = append([]string(nil), values...)
+	}
+
+	for n := 1; ; n++ {
+		if p.Strategy != config.PaginateNone && n > p.MaxPages {
+			return fmt.Errorf("%s: %w: more than %d", u.name, ErrTooManyPages, p.MaxPages)
+		}
+		if p.Strategy == config.PaginatePage {
+			query.Set(p.PageParam, strconv.Itoa(n))
+			query.Set(p.LimitParam, strconv.Itoa(p.PageSize))
+		}
+
+		pageEp := ep
+		pageEp.Query = query
+		var raw page
+		if err := u.Do(ctx, pageEp, &raw); err != nil {
+			return err
+		}
+		items, cursor, err := parsePage[T](raw.body, p)
+		if err != nil {
+			return fmt.Errorf("%s: failed to unmarshal page %d: %w", u.name, n, err)
+		}
+		if len(items) > 0 {
+			if err := fn(items); err != nil {
+				return err
+			}
+		}
+
+		switch p.Strategy {
+		case config.PaginatePage:
+			if len(items) < p.PageSize {
+				return nil
+			}
+		case config.PaginateLink:
+			next := nextLink(raw.link)
+			if next == nil {
+				return nil
+			}
+			query = next.Query()
+		case config.PaginateCursor:
+			if cursor == "" {
+				return nil
+			}
+			query.Set(p.CursorParam, cursor)
+		default:
+			return nil
+		}
+	}
+}
+
+// parsePage returns the items of a page and the cursor of the next one
+func parsePage[T any](body []byte, p config.PaginationConfig) ([]T, string, error) {
+	var items []T
+	if len(body) == 0 {
+		return nil, "", nil
+	}
+	if p.ItemsField == "" {
+		err := json.Unmarshal(body, &items)
+		return items, "", err
+	}
+
+	if raw := field(body, p.ItemsField); raw != nil {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, "", err
+		}
+	}
+	var cursor string
+	if p.Strategy == config.PaginateCursor {
+		if raw := field(body, p.CursorField); raw != nil {
+			// Cursors are strings or numbers, and null on the last page
+			if err := json.Unmarshal(raw, &cursor); err != nil {
+				cursor = strings.TrimSpace(string(raw))
+			}
+		}
+	}
+	return items, cursor, nil
+}
+
+// field returns the raw value at the dotted path in a JSON object, e.g.
+// meta.next_cursor, or nil if there is none
+func field(body []byte, path string) json.RawMessage {
+	raw := json.RawMessage(body)
+	for _, name := range strings.Split(path, ".") {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil
+		}
+		if raw = fields[name]; raw == nil {
+			return nil
+		}
+	}
+	return raw
+}
+
+// nextLink returns the URL of the rel="next" link in a Link header, or nil
+// if there is none
+func nextLink(header string) *url.URL {
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+		if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(name, "rel") {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+				if strings.EqualFold(rel, "next") {
+					next, err := url.Parse(target[1 : len(target)-1])
+					if err != nil {
+						return nil
+					}
+					return next
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"api-gateway-backend/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postsServer answers each request with the body and any Link header page
+// returns for it
+func postsServer(t *testing.T, page func(r *http.Request) (body, link string)) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, link := page(r)
+		if link != "" {
+			w.Header().Set("Link", link)
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// postsJSON returns a JSON array of the posts with IDs from first to last
+func postsJSON(first, last int) string {
+	body := "["
+	for id := first; id <= last; id++ {
+		if id > first {
+			body += ","
+		}
+		body += fmt.Sprintf(`{"userId":1,"id":%d,"title":"t","body":"b"}`, id)
+	}
+	return body + "]"
+}
+
+func newPagingClient(t *testing.T, baseURL string, p config.PaginationConfig) *Upstream {
+	u, err := New("test", config.UpstreamConfig{BaseURLs: []string{baseURL}, Timeout: 5, Pagination: p}, nil)
+	require.NoError(t, err)
+	return u
+}
+
+// collectPages fetches posts page by page, returning the IDs of each page
+func collectPages(t *testing.T, u *Upstream) ([][]int, error) {
+	var pages [][]int
+	err := FetchPostPages(context.Background(), u, func(posts []PostResponse) error {
+		var ids []int
+		for _, post := range posts {
+			ids = append(ids, post.ID)
+		}
+		pages = append(pages, ids)
+		return nil
+	})
+	return pages, err
+}
+
+func TestFetchPages_Page(t *testing.T) {
+	baseURL := postsServer(t, func(r *http.Request) (string, string) {
+		assert.Equal(t, "2", r.URL.Query().Get("_limit"))
+		n, _ := strconv.Atoi(r.URL.Query().Get("_page"))
+		return postsJSON(2*n-1, min(2*n, 5)), ""
+	})
+	u := newPagingClient(t, baseURL, config.PaginationConfig{
+		Strategy: config.PaginatePage, PageParam: "_page", LimitParam: "_limit", PageSize: 2, MaxPages: 10,
+	})
+
+	pages, err := collectPages(t, u)
+	require.NoError(t, err)
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, pages)
+
+	// A collection longer than the guard fails rather than come back cut
+	u.pagination.MaxPages = 2
+	_, err = collectPages(t, u)
+	assert.ErrorIs(t, err, ErrTooManyPages)
+}
+
+func TestFetchPages_Link(t *testing.T) {
+	var baseURL string
+	baseURL = postsServer(t, func(r *http.Request) (string, string) {
+		switch r.URL.Query().Get("page") {
+		case "":
+			return postsJSON(1, 2), fmt.Sprintf(`<%s/posts?page=2>; rel="next", <%s/posts?page=2>; rel="last"`, baseURL, baseURL)
+		default:
+			return postsJSON(3, 3), fmt.Sprintf(`<%s/posts>; rel="first"`, baseURL)
+		}
+	})
+	u := newPagingClient(t, baseURL, config.PaginationConfig{Strategy: config.PaginateLink, MaxPages: 10})
+
+	pages, err := collectPages(t, u)
+	require.NoError(t, err)
+	assert.Equal(t, [][]int{{1, 2}, {3}}, pages)
+}
+
+func TestFetchPages_Cursor(t *testing.T) {
+	baseURL := postsServer(t, func(r *http.Request) (string, string) {
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			return fmt.Sprintf(`{"data":%s,"meta":{"next":"abc"}}`, postsJSON(1, 2)), ""
+		case "abc":
+			return fmt.Sprintf(`{"data":%s,"meta":{"next":42}}`, postsJSON(3, 4)), ""
+		default:
+			assert.Equal(t, "42", r.URL.Query().Get("cursor"))
+			return fmt.Sprintf(`{"data":%s,"meta":{"next":null}}`, postsJSON(5, 5)), ""
+		}
+	})
+	u := newPagingClient(t, baseURL, config.PaginationConfig{
+		Strategy: config.PaginateCursor, CursorParam: "cursor", CursorField: "meta.next", ItemsField: "data", MaxPages: 10,
+	})
+
+	pages, err := collectPages(t, u)
+	require.NoError(t, err)
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, pages)
+}
+
+func TestFetchPages_StopsOnHandlerError(t *testing.T) {
+	baseURL := postsServer(t, func(r *http.Request) (string, string) {
+		return postsJSON(1, 2), ""
+	})
+	u := newPagingClient(t, baseURL, config.PaginationConfig{
+		Strategy: config.PaginatePage, PageParam: "_page", LimitParam: "_limit", PageSize: 2, MaxPages: 10,
+	})
+
+	calls := 0
+	stop := errors.New("database down")
+	err := FetchPostPages(context.Background(), u, func(posts []PostResponse) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{`<https://api.example.com/posts?page=3>; rel="next"`, "https://api.example.com/posts?page=3"},
+		{`<https://api.example.com/posts?page=1>; rel="prev", <https://api.example.com/posts?page=3>; rel="next last"`, "https://api.example.com/posts?page=3"},
+		{`<https://api.example.com/posts?page=1>; rel="first"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			next := nextLink(tt.header)
+			if tt.want == "" {
+				assert.Nil(t, next)
+				return
+			}
+			assert.Equal(t, tt.want, next.String())
+		})
+	}
+}
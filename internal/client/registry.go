@@ -0,0 +1,76 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+
+	"api-gateway-backend/internal/cache"
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/logger"
+)
+
+// Registry holds a client for each configured upstream
+type Registry struct {
+	upstreams map[string]*Upstream
+}
+
+// NewRegistry creates clients for every upstream in cfg, sharing OAuth2
+// access tokens and cached GET responses through shared. Slow calls are
+// logged to log. In mock mode every upstream is answered by a
+// MockTransport instead of over the network.
+func NewRegistry(cfg config.ExternalAPIConfig, shared cache.Cache, log *logger.Logger) (*Registry, error) {
+	r := &Registry{upstreams: make(map[string]*Upstream, len(cfg.Upstreams))}
+	var mock *MockTransport
+	if cfg.Mode == config.ExternalAPIMock {
+		mock = &MockTransport{}
+	}
+	for name, upstream := range cfg.Upstreams {
+		switch upstream.AuthType {
+		case config.AuthNone, config.AuthBearer, config.AuthHeader, config.AuthBasic:
+		case config.AuthOAuth2:
+			if upstream.OAuthTokenURL == "" || upstream.OAuthClientID == "" {
+				return nil, fmt.Errorf("upstream %q needs a token URL and client ID for oauth2", name)
+			}
+		default:
+			return nil, fmt.Errorf("upstream %q has unsupported auth type %q", name, upstream.AuthType)
+		}
+		u, err := New(name, upstream, shared)
+		if err != nil {
+			return nil, fmt.Errorf("upstream %q: %w", name, err)
+		}
+		u.logger = log
+		if mock != nil {
+			// Token requests share the client, so they're answered too
+			u.client.Transport = mock
+		}
+		r.upstreams[name] = u
+	}
+	return r, nil
+}
+
+// Get returns the named upstream
+func (r *Registry) Get(name string) (*Upstream, error) {
+	u, ok := r.upstreams[name]
+	if !ok {
+		return nil, fmt.Errorf("upstream %q is not configured", name)
+	}
+	return u, nil
+}
+
+// Observe has every upstream report the outcome of its calls to observe.
+// It must be called before the upstreams are used.
+func (r *Registry) Observe(observe Observer) {
+	for _, u := range r.upstreams {
+		u.observe = observe
+	}
+}
+
+// Names returns the configured upstream names in order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.upstreams))
+	for name := range r.upstreams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/metrics"
+)
+
+// ErrThrottled is returned for a call that would have to queue longer than
+// its rate limit allows, or past its context's deadline
+var ErrThrottled = errors.New("client-side rate limit reached")
+
+// Throttling metrics, labelled by upstream or proxy route name
+var (
+	throttledCalls = metrics.NewCounterVec("upstream_throttled_calls_total",
+		"Calls held back by a client-side rate limit, by whether they were delayed or rejected.", "upstream", "outcome")
+	throttleWait = metrics.NewHistogramVec("upstream_throttle_wait_seconds",
+		"Time delayed calls queued for a client-side rate limit.", metrics.DefaultBuckets, "upstream")
+)
+
+func init() {
+	metrics.Default.Register(throttledCalls, throttleWait)
+}
+
+// Limiter is a token bucket limiting the calls this instance makes to one
+// upstream. Calls queue for tokens in the order they arrive. A nil Limiter
+// doesn't limit.
+type Limiter struct {
+	name    string
+	rate    float64 // tokens per second
+	burst   float64
+	maxWait time.Duration
+	now     func() time.Time
+
+	mu     sync.Mutex
+	tokens float64 // below 0 while queued calls hold tokens not yet refilled
+	last   time.Time
+}
+
+// NewLimiter creates a limiter for the upstream or route called name, or
+// returns nil if cfg sets no rate
+func NewLimiter(name string, cfg config.ThrottleConfig) *Limiter {
+	if cfg.RequestsPerSecond <= 0 {
+		return nil
+	}
+	burst := float64(max(cfg.Burst, 1))
+	return &Limiter{
+		name:    name,
+		rate:    cfg.RequestsPerSecond,
+		burst:   burst,
+		maxWait: time.Duration(cfg.MaxWait) * time.Second,
+		now:     time.Now,
+		tokens:  burst,
+		last:    time.Now(),
+	}
+}
+
+// Wait blocks until a call may go ahead. It returns ErrThrottled straight
+// away if the call would have to queue longer than the max wait or past
+// ctx's deadline, and ctx's error if ctx ends while it queues.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	delay, ok := l.reserve(ctx)
+	if !ok {
+		throttledCalls.Inc(l.name, "rejected")
+		return ErrThrottled
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	throttledCalls.Inc(l.name, "delayed")
+	throttleWait.Observe(delay.Seconds(), l.name)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		l.release()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve takes a token, possibly one not yet refilled, and returns how
+// long until it is; it takes none if that's too long to wait
+func (l *Limiter) reserve(ctx context.Context) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	var delay time.Duration
+	if l.tokens < 1 {
+		delay = time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	}
+	if delay > l.maxWait {
+		return delay, false
+	}
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		return delay, false
+	}
+	l.tokens--
+	return delay, true
+}
+
+// release returns the token of a call that gave up queueing
+func (l *Limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.burst, l.tokens+1)
+}
+
+// ThrottledTransport waits for Limiter before each round trip through
+// Next, so retries are limited too
+type ThrottledTransport struct {
+	Limiter *Limiter
+	Next    http.RoundTripper
+}
+
+func (t *ThrottledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.Limiter.Wait(req.Context()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.Next.RoundTrip(req)
+}
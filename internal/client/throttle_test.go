@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_QueuesUpToMaxWait(t *testing.T) {
+	l := NewLimiter("test", config.ThrottleConfig{RequestsPerSecond: 20, Burst: 2, MaxWait: 1})
+	delayed := throttledCalls.Value("test", "delayed")
+
+	// The burst goes straight through, then calls queue a token apart
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, l.Wait(context.Background()))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	assert.Equal(t, delayed+2, throttledCalls.Value("test", "delayed"))
+
+	// A call that would outlive its deadline is refused at once
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.Wait(ctx), ErrThrottled)
+	assert.Equal(t, 1.0, throttledCalls.Value("test", "rejected"))
+
+	var unlimited *Limiter
+	assert.Nil(t, NewLimiter("test", config.ThrottleConfig{}))
+	assert.NoError(t, unlimited.Wait(context.Background()))
+}
+
+func TestUpstream_RateLimit(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	u, err := New("limited", config.UpstreamConfig{
+		BaseURLs: []string{server.URL}, Timeout: 5,
+		RateLimit: config.ThrottleConfig{RequestsPerSecond: 0.1, Burst: 1},
+	}, nil)
+	require.NoError(t, err)
+	var observed int32
+	u.observe = func(string, error, time.Duration) { atomic.AddInt32(&observed, 1) }
+
+	// Over the quota, a call fails without reaching the upstream or
+	// counting against its health
+	_, err = FetchPosts(context.Background(), u)
+	require.NoError(t, err)
+	_, err = FetchPosts(context.Background(), u)
+	assert.ErrorIs(t, err, ErrThrottled)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&observed))
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"api-gateway-backend/internal/config"
+)
+
+// newTransport returns the connection settings for calling the upstream:
+// its proxy, host overrides and TLS
+func newTransport(cfg config.UpstreamConfig) (*http.Transport, error) {
+	proxy, err := newProxyFunc(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Transport{
+		Proxy:               proxy,
+		DialContext:         newDialer(cfg.HostOverrides),
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     30 * time.Second,
+		DisableCompression:  true,
+		MaxIdleConnsPerHost: 10,
+	}, nil
+}
+
+// newProxyFunc returns how the upstream is reached: through its own proxy,
+// directly, or by default through HTTP_PROXY or HTTPS_PROXY unless NO_PROXY
+// matches it
+func newProxyFunc(cfg config.UpstreamConfig) (func(*http.Request) (*url.URL, error), error) {
+	switch cfg.Proxy {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case config.ProxyDirect:
+		return nil, nil
+	}
+	proxyURL, err := url.Parse(cfg.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+// newDialer returns a dial function connecting to the IP address in
+// overrides for a host instead of resolving it. Requests keep the host
+// name for their Host header and TLS server name. When a proxy is used,
+// the override applies to the proxy's host.
+func newDialer(overrides map[string]string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if len(overrides) == 0 {
+		return dialer.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if ip, ok := overrides[host]; ok {
+				addr = net.JoinHostPort(ip, port)
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
+
+// newTLSConfig returns the TLS settings for calling the upstream: its
+// client certificate, CAs trusted on top of the system ones and whether
+// certificates are verified at all. It returns nil for the defaults.
+func newTLSConfig(cfg config.UpstreamConfig) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSCAFile == "" && !cfg.TLSInsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+	}
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+	return tlsConfig, nil
+}
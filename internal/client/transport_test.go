@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeClientCert writes a self-signed client certificate and its key to a
+// temporary directory, returning the certificate and their paths
+func writeClientCert(t *testing.T) (cert *x509.Certificate, certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gateway"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return cert, certFile, keyFile
+}
+
+func TestNew_MutualTLS(t *testing.T) {
+	clientCert, certFile, keyFile := writeClientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+
+	call := func(cfg config.UpstreamConfig) error {
+		cfg.BaseURLs, cfg.Timeout = []string{server.URL}, 5
+		u, err := New("internal", cfg, nil)
+		require.NoError(t, err)
+		_, err = FetchPosts(context.Background(), u)
+		return err
+	}
+
+	// Trusting the server's CA and presenting a certificate gets through
+	assert.NoError(t, call(config.UpstreamConfig{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSCAFile: caFile}))
+	// Without the CA the server isn't trusted, and without a certificate
+	// the server turns the gateway away
+	assert.Error(t, call(config.UpstreamConfig{TLSCertFile: certFile, TLSKeyFile: keyFile}))
+	assert.Error(t, call(config.UpstreamConfig{TLSCAFile: caFile}))
+	// Skipping verification still needs the certificate
+	assert.NoError(t, call(config.UpstreamConfig{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSInsecureSkipVerify: true}))
+}
+
+func TestNew_InvalidTLSFiles(t *testing.T) {
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+	_, err := New("internal", config.UpstreamConfig{BaseURLs: []string{"https://localhost"}, TLSCAFile: notPEM}, nil)
+	assert.ErrorContains(t, err, "no certificates found")
+	_, err = New("internal", config.UpstreamConfig{BaseURLs: []string{"https://localhost"}, TLSCertFile: notPEM, TLSKeyFile: notPEM}, nil)
+	assert.ErrorContains(t, err, "failed to load client certificate")
+}
+
+func TestNew_HostOverrides(t *testing.T) {
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// The name doesn't resolve, but the override connects to the server
+	u, err := New("internal", config.UpstreamConfig{
+		BaseURLs: []string{"http://api.gateway.invalid:" + port}, Timeout: 5,
+		Proxy: config.ProxyDirect, HostOverrides: map[string]string{"api.gateway.invalid": "127.0.0.1"},
+	}, nil)
+	require.NoError(t, err)
+	_, err = FetchPosts(context.Background(), u)
+	require.NoError(t, err)
+	assert.Equal(t, "api.gateway.invalid:"+port, host)
+}
+
+func TestNew_Proxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte(`[]`))
+	}))
+	defer proxy.Close()
+
+	u, err := New("internal", config.UpstreamConfig{
+		BaseURLs: []string{"http://api.gateway.invalid"}, Timeout: 5, Proxy: proxy.URL,
+	}, nil)
+	require.NoError(t, err)
+	_, err = FetchPosts(context.Background(), u)
+	require.NoError(t, err)
+	assert.Equal(t, "http://api.gateway.invalid/posts", proxied)
+}
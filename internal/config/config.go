@@ -1,67 +1,1128 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
+
+	"api-gateway-backend/internal/rbac"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Environment string
-	Port        string
-	Database    DatabaseConfig
-	Redis       RedisConfig
-	ExternalAPI ExternalAPIConfig
+	Environment    string               `yaml:"environment"`
+	Port           string               `yaml:"port"`
+	LogLevel       string               `yaml:"log_level"`       // debug, info, warn or error
+	LogRedact      []string             `yaml:"log_redact"`      // field names to redact in logs, on top of the built-in ones
+	ReloadInterval int                  `yaml:"reload_interval"` // seconds between config file checks, 0 to only reload on SIGHUP
+	Database       DatabaseConfig       `yaml:"database"`
+	Redis          RedisConfig          `yaml:"redis"`
+	ExternalAPI    ExternalAPIConfig    `yaml:"external_api"`
+	HTTP           HTTPConfig           `yaml:"http"`
+	IPFilter       IPFilterConfig       `yaml:"ip_filter"`
+	Concurrency    ConcurrencyConfig    `yaml:"concurrency"`
+	Auth           AuthConfig           `yaml:"auth"`
+	RBAC           RBACConfig           `yaml:"rbac"`
+	Tenancy        TenancyConfig        `yaml:"tenancy"`
+	RateLimit      RateLimitConfig      `yaml:"rate_limit"`
+	Health         HealthConfig         `yaml:"health"`
+	Jobs           JobsConfig           `yaml:"jobs"`
+	Cache          CacheConfig          `yaml:"cache"`
+	Compression    CompressionConfig    `yaml:"compression"`
+	Proxy          ProxyConfig          `yaml:"proxy"`
+	Webhooks       WebhookConfig        `yaml:"webhooks"`
+	Kafka          KafkaConfig          `yaml:"kafka"`
+	WebSocket      WebSocketConfig      `yaml:"websocket"`
+	Idempotency    IdempotencyConfig    `yaml:"idempotency"`
+	TLS            TLSConfig            `yaml:"tls"`
+	GRPC           GRPCConfig           `yaml:"grpc"`
+	GraphQL        GraphQLConfig        `yaml:"graphql"`
+	Secrets        SecretsConfig        `yaml:"secrets"`
+	ErrorReporting ErrorReportingConfig `yaml:"error_reporting"`
+	DebugLog       DebugLogConfig       `yaml:"debug_log"`
 }
 
 // DatabaseConfig holds database configuration
 type DatabaseConfig struct {
-	Host     string
-	Port     int
-	User     string
-	Password string
-	Name     string
+	Driver      string `yaml:"driver"` // mysql or postgres
+	Host        string `yaml:"host"`
+	Port        int    `yaml:"port"`
+	User        string `yaml:"user"`
+	Password    string `yaml:"password"`
+	PasswordRef string `yaml:"password_ref"` // secret holding Password, fetched from Secrets
+	Name        string `yaml:"name"`
+	SSLMode     string `yaml:"ssl_mode"` // postgres only
+
+	SlowQueryThreshold int `yaml:"slow_query_threshold"` // in milliseconds, slower queries are logged; 0 disables
 }
 
 // RedisConfig holds Redis configuration
 type RedisConfig struct {
-	Host     string
-	Port     int
-	Password string
-	DB       int
+	Mode             string   `yaml:"mode"` // standalone, sentinel or cluster
+	Host             string   `yaml:"host"`
+	Port             int      `yaml:"port"`
+	Addrs            []string `yaml:"addrs"`       // sentinel or cluster node addresses
+	MasterName       string   `yaml:"master_name"` // sentinel master name
+	Password         string   `yaml:"password"`
+	PasswordRef      string   `yaml:"password_ref"` // secret holding Password, fetched from Secrets
+	SentinelPassword string   `yaml:"sentinel_password"`
+	DB               int      `yaml:"db"`              // ignored in cluster mode
+	ScanBatchSize    int      `yaml:"scan_batch_size"` // keys per SCAN page when invalidating by pattern
+}
+
+// DefaultUpstream names the upstream configured by the EXTERNAL_API_*
+// variables, which items are synced from
+const DefaultUpstream = "default"
+
+// Upstream authentication types
+const (
+	AuthNone   = ""
+	AuthBearer = "bearer" // Authorization: Bearer <AuthToken>
+	AuthHeader = "header" // <AuthHeader>: <AuthToken>
+	AuthBasic  = "basic"  // HTTP basic auth with AuthUsername and AuthPassword
+	AuthOAuth2 = "oauth2" // OAuth2 client credentials, Authorization: Bearer <token>
+)
+
+// Pagination strategies for fetching an upstream's collections
+const (
+	PaginateNone   = ""       // the whole collection comes in one response
+	PaginatePage   = "page"   // numbered pages of PageSize items
+	PaginateLink   = "link"   // the next page named by a Link header
+	PaginateCursor = "cursor" // an opaque cursor returned with each page
+)
+
+// PaginationConfig holds how an upstream splits collections into pages.
+// Pages are either a JSON array of items or an object holding them under
+// ItemsField.
+type PaginationConfig struct {
+	Strategy    string `yaml:"strategy"`
+	PageParam   string `yaml:"page_param"`   // query parameter numbering pages from 1, for PaginatePage
+	LimitParam  string `yaml:"limit_param"`  // query parameter asking for PageSize items, for PaginatePage
+	PageSize    int    `yaml:"page_size"`    // a shorter page is the last one
+	CursorParam string `yaml:"cursor_param"` // query parameter passing the cursor back, for PaginateCursor
+	CursorField string `yaml:"cursor_field"` // response field holding the next page's cursor, empty on the last page
+	ItemsField  string `yaml:"items_field"`  // response field holding the items, empty if pages are arrays
+	MaxPages    int    `yaml:"max_pages"`    // fetching fails rather than go past this many pages
+}
+
+// ProxyDirect as an upstream's proxy connects to it directly, even if
+// HTTP_PROXY or HTTPS_PROXY is set
+const ProxyDirect = "direct"
+
+// Load balancing policies across an upstream's base URLs
+const (
+	BalanceRoundRobin       = "round_robin"
+	BalanceLeastConnections = "least_connections"
+)
+
+// BalancerConfig holds how requests are spread across an upstream's base
+// URLs and when a failing one is taken out of rotation
+type BalancerConfig struct {
+	Policy        string `yaml:"policy"`
+	EjectAfter    int    `yaml:"eject_after"`    // consecutive failures before a backend is ejected, 0 disables ejection
+	EjectDuration int    `yaml:"eject_duration"` // in seconds
+}
+
+// ThrottleConfig limits the rate of calls to an upstream or proxy route
+// from each instance, as a token bucket. Calls over the limit queue for a
+// token for up to MaxWait and fail if none frees up in time.
+type ThrottleConfig struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"` // 0 disables the limit
+	Burst             int     `yaml:"burst"`               // calls allowed at once above the rate
+	MaxWait           int     `yaml:"max_wait"`            // in seconds a call may queue for a token
 }
 
-// ExternalAPIConfig holds external API configuration
+// External API modes
+const (
+	ExternalAPILive = "live" // call the configured upstreams
+	ExternalAPIMock = "mock" // answer in process with fixture data, for local development
+)
+
+// ExternalAPIConfig holds the external APIs data is synced from
 type ExternalAPIConfig struct {
-	BaseURL string
-	Timeout int // in seconds
+	Mode      string                    `yaml:"mode"`
+	Upstreams map[string]UpstreamConfig `yaml:"upstreams"` // by name, including DefaultUpstream
+}
+
+// UpstreamConfig holds one external API's connection, auth and retry
+// settings
+type UpstreamConfig struct {
+	BaseURLs          []string          `yaml:"base_urls"` // balanced according to Balancer
+	Balancer          BalancerConfig    `yaml:"balancer"`
+	RateLimit         ThrottleConfig    `yaml:"rate_limit"`
+	Timeout           int               `yaml:"timeout"` // in seconds, per attempt
+	MaxRetries        int               `yaml:"max_retries"`
+	MaxRetryDuration  int               `yaml:"max_retry_duration"`  // in seconds, total time a request may spend retrying, 0 disables the cap
+	RetryBaseDelay    int               `yaml:"retry_base_delay"`    // in milliseconds, backoff before the first retry, doubled per attempt
+	RetryMaxDelay     int               `yaml:"retry_max_delay"`     // in milliseconds, longest backoff between attempts, 0 for no limit
+	RetryStatusCodes  []int             `yaml:"retry_status_codes"`  // response codes retried, empty for 429 and every 5xx
+	SlowCallThreshold int               `yaml:"slow_call_threshold"` // in milliseconds, slower calls are logged; 0 disables
+	AuthType          string            `yaml:"auth_type"`
+	AuthHeader        string            `yaml:"auth_header"`
+	AuthToken         string            `yaml:"auth_token"`
+	AuthTokenRef      string            `yaml:"auth_token_ref"` // secret holding AuthToken, fetched from Secrets
+	AuthUsername      string            `yaml:"auth_username"`
+	AuthPassword      string            `yaml:"auth_password"`
+	AuthPasswordRef   string            `yaml:"auth_password_ref"` // secret holding AuthPassword, fetched from Secrets
+	Headers           map[string]string `yaml:"headers"`           // sent with every request
+	Pagination        PaginationConfig  `yaml:"pagination"`
+
+	// TLS toward the upstream, e.g. for internal services requiring mutual TLS
+	TLSCertFile           string `yaml:"tls_cert_file"`            // PEM client certificate chain, presented with TLSKeyFile
+	TLSKeyFile            string `yaml:"tls_key_file"`             // PEM private key of the client certificate
+	TLSCAFile             string `yaml:"tls_ca_file"`              // PEM bundle of CAs trusted on top of the system ones
+	TLSInsecureSkipVerify bool   `yaml:"tls_insecure_skip_verify"` // development only, refused in production
+
+	// Egress toward the upstream, e.g. through a corporate proxy
+	Proxy         string            `yaml:"proxy"`          // proxy URL overriding HTTP_PROXY and HTTPS_PROXY, or ProxyDirect to bypass them
+	HostOverrides map[string]string `yaml:"host_overrides"` // IP addresses to connect to instead of resolving the hosts
+
+	// Caching of GET responses in Redis, shared by every instance
+	CacheEnabled  bool `yaml:"cache_enabled"`
+	CacheTTL      int  `yaml:"cache_ttl"`       // in seconds, how long a response without a Cache-Control max-age is fresh
+	CacheStaleTTL int  `yaml:"cache_stale_ttl"` // in seconds, how long a stale response with an ETag or Last-Modified is kept to revalidate
+
+	// OAuth2 client credentials, used when AuthType is AuthOAuth2
+	OAuthTokenURL        string   `yaml:"oauth_token_url"`
+	OAuthClientID        string   `yaml:"oauth_client_id"`
+	OAuthClientSecret    string   `yaml:"oauth_client_secret"`
+	OAuthClientSecretRef string   `yaml:"oauth_client_secret_ref"` // secret holding OAuthClientSecret, fetched from Secrets
+	OAuthScopes          []string `yaml:"oauth_scopes"`
+}
+
+// HTTPConfig holds limits on incoming requests
+type HTTPConfig struct {
+	MaxBodySize    int `yaml:"max_body_size"`    // in bytes, 0 for no limit
+	MaxHeaderBytes int `yaml:"max_header_bytes"` // in bytes, of the request line and headers
+	RequestTimeout int `yaml:"request_timeout"`  // in seconds, for routes without their own timeout, 0 for no limit
+}
+
+// IPFilterConfig holds the client addresses allowed or denied access, as
+// IP addresses or CIDR ranges. A request is refused if its address is
+// denied, or if an allow list applies and doesn't include it.
+type IPFilterConfig struct {
+	TrustedProxies []string        `yaml:"trusted_proxies"` // whose X-Forwarded-For and X-Real-IP headers name the client
+	Allow          []string        `yaml:"allow"`           // empty allows every address that isn't denied
+	Deny           []string        `yaml:"deny"`
+	Routes         []IPFilterRoute `yaml:"routes"`
+}
+
+// IPFilterRoute adds allow and deny lists for requests under a path
+// prefix, e.g. to keep /admin to internal ranges
+type IPFilterRoute struct {
+	Prefix string   `yaml:"prefix"`
+	Allow  []string `yaml:"allow"`
+	Deny   []string `yaml:"deny"`
+}
+
+// ConcurrencyConfig holds in-flight request limits (0 disables a limit)
+type ConcurrencyConfig struct {
+	MaxInFlight          int `yaml:"max_in_flight"`           // across all routes
+	MaxInFlightAPI       int `yaml:"max_in_flight_api"`       // across /api/v1
+	MaxInFlightAnalytics int `yaml:"max_in_flight_analytics"` // across /api/v1/analytics
+	QueueTimeout         int `yaml:"queue_timeout_ms"`        // in milliseconds
+	RetryAfter           int `yaml:"retry_after"`             // in seconds
+}
+
+// AuthConfig holds API authentication configuration
+type AuthConfig struct {
+	JWTEnabled       bool   `yaml:"jwt_enabled"`
+	JWTAlgorithm     string `yaml:"jwt_algorithm"`       // HS256 or RS256
+	JWTSecret        string `yaml:"jwt_secret"`          // shared secret for HS256
+	JWTPublicKeyFile string `yaml:"jwt_public_key_file"` // PEM public key path for RS256
+	JWTIssuer        string `yaml:"jwt_issuer"`          // required iss claim, empty to skip
+	JWTAudience      string `yaml:"jwt_audience"`        // required aud claim, empty to skip
+	OIDCEnabled      bool   `yaml:"oidc_enabled"`
+	OIDCIssuer       string `yaml:"oidc_issuer"`         // discovery document is at <issuer>/.well-known/openid-configuration
+	OIDCAudience     string `yaml:"oidc_audience"`       // required aud claim, usually the client ID
+	OIDCJWKSCacheTTL int    `yaml:"oidc_jwks_cache_ttl"` // in seconds, before the provider's signing keys are refetched
+	APIKeysEnabled   bool   `yaml:"api_keys_enabled"`
+	APIKeyCacheTTL   int    `yaml:"api_key_cache_ttl"` // in seconds
+	AdminToken       string `yaml:"admin_token"`       // X-Admin-Token for /admin routes, empty disables them
+
+	SigningEnabled   bool            `yaml:"signing_enabled"`
+	SigningTolerance int             `yaml:"signing_tolerance"` // in seconds, max age of a signed request's timestamp
+	SigningClients   []SigningClient `yaml:"signing_clients"`
+}
+
+// SigningClient is a machine client that authenticates by signing each
+// request with a secret shared with the gateway
+type SigningClient struct {
+	ID     string   `yaml:"id"`     // sent in X-Signature-Client
+	Secret string   `yaml:"secret"` // HMAC-SHA256 signing secret
+	Roles  []string `yaml:"roles"`  // empty for the default roles
+	Tenant string   `yaml:"tenant"` // tenant the client acts for, empty for the default tenant
+}
+
+// RBACConfig holds role-based access control. API keys and JWTs carry
+// role names; each route requires a permission one of them must grant.
+type RBACConfig struct {
+	Enabled      bool       `yaml:"enabled"`
+	Roles        rbac.Roles `yaml:"roles"`         // role name to the permissions it grants, e.g. sync:trigger or admin:*; merged with the built-in roles
+	DefaultRoles []string   `yaml:"default_roles"` // held by API keys and tokens without roles, and by unauthenticated clients
+	JWTClaim     string     `yaml:"jwt_claim"`     // JWT claim listing the token's roles
+}
+
+// TenancyConfig holds multi-tenancy. Each API key, signing client and JWT
+// then acts for one tenant, and only sees that tenant's items and orders.
+type TenancyConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	JWTClaim string `yaml:"jwt_claim"` // JWT claim naming the token's tenant
+}
+
+// RateLimitConfig holds per-client rate limiting configuration
+type RateLimitConfig struct {
+	Enabled           bool `yaml:"enabled"`
+	RequestsPerMinute int  `yaml:"requests_per_minute"` // sustained rate per client
+	Burst             int  `yaml:"burst"`               // bucket capacity per client
+}
+
+// HealthConfig holds health and readiness check configuration
+type HealthConfig struct {
+	SyncStaleAfter int `yaml:"sync_stale_after"` // in seconds, age after which synced data is reported stale
+	CheckCacheTTL  int `yaml:"check_cache_ttl"`  // in seconds, how long readiness check results are reused
+	CheckTimeout   int `yaml:"check_timeout"`    // in seconds, per readiness check
+	CheckInterval  int `yaml:"check_interval"`   // in seconds, between background dependency checks, 0 disables them
+	HistorySize    int `yaml:"history_size"`     // checks of each dependency kept for its success rate
+	DownAfter      int `yaml:"down_after"`       // failed checks in a row before a dependency is known down
+}
+
+// JobsConfig holds background job configuration
+type JobsConfig struct {
+	SyncLockTTL       int    `yaml:"sync_lock_ttl"`   // in seconds, renewed while a sync runs
+	SyncBatchSize     int    `yaml:"sync_batch_size"` // rows per multi-row upsert statement
+	SyncWorkers       int    `yaml:"sync_workers"`    // batches upserted in parallel
+	SyncAtomic        bool   `yaml:"sync_atomic"`     // write each items sync in one transaction
+	SyncMaxErrors     int    `yaml:"sync_max_errors"` // failed items an atomic sync tolerates before it is rolled back
+	SyncEnabled       bool   `yaml:"sync_enabled"`
+	SyncSchedule      string `yaml:"sync_schedule"` // cron spec with seconds
+	AnalyticsEnabled  bool   `yaml:"analytics_enabled"`
+	AnalyticsSchedule string `yaml:"analytics_schedule"` // cron spec with seconds
+
+	// The order stats job rebuilds order_stats, which order status
+	// summaries over preset periods are read from while it's fresh
+	OrderStatsEnabled  bool   `yaml:"order_stats_enabled"`
+	OrderStatsSchedule string `yaml:"order_stats_schedule"` // cron spec with seconds
+	OrderStatsMaxAge   int    `yaml:"order_stats_max_age"`  // in seconds, after which summaries query orders instead
+
+	// With stream ingestion a sync publishes the fetched items to a Redis
+	// Stream, and a consumer group on every instance persists them
+	IngestStream     bool `yaml:"ingest_stream"`
+	IngestWorkers    int  `yaml:"ingest_workers"`     // consumers per instance
+	IngestBatchSize  int  `yaml:"ingest_batch_size"`  // entries a consumer reads and upserts at once
+	IngestMaxBacklog int  `yaml:"ingest_max_backlog"` // entries not yet persisted before a sync waits to publish more
+
+	// Users, comments and todos are synced by separate jobs, or with a
+	// ResourceSyncSchedule by one job fetching them concurrently
+	ResourceSyncEnabled     bool   `yaml:"resource_sync_enabled"`
+	UsersSyncSchedule       string `yaml:"users_sync_schedule"`       // cron spec with seconds
+	CommentsSyncSchedule    string `yaml:"comments_sync_schedule"`    // cron spec with seconds
+	TodosSyncSchedule       string `yaml:"todos_sync_schedule"`       // cron spec with seconds
+	ResourceSyncSchedule    string `yaml:"resource_sync_schedule"`    // cron spec with seconds, replacing the separate schedules when set
+	ResourceSyncConcurrency int    `yaml:"resource_sync_concurrency"` // resources synced at once by the combined job
+
+	WebhookDeliveryEnabled  bool   `yaml:"webhook_delivery_enabled"`
+	WebhookDeliverySchedule string `yaml:"webhook_delivery_schedule"` // cron spec with seconds
+
+	// Items whose upsert fails during a sync are retried from a Redis
+	// queue with exponential backoff, then dead-lettered into failed_items
+	ItemRetryEnabled     bool   `yaml:"item_retry_enabled"`
+	ItemRetrySchedule    string `yaml:"item_retry_schedule"`     // cron spec with seconds
+	ItemRetryMaxAttempts int    `yaml:"item_retry_max_attempts"` // attempts, the sync's included, before an item is dead-lettered
+	ItemRetryDelay       int    `yaml:"item_retry_delay"`        // in seconds, before the first retry, doubled per attempt
+
+	// With the outbox, item and order changes are recorded in outbox_events
+	// in the same transaction as the change, and a relay job publishes them
+	// to Redis pub/sub or, with OutboxKafkaURL set, to Kafka
+	Outbox              bool   `yaml:"outbox"`
+	OutboxRelaySchedule string `yaml:"outbox_relay_schedule"` // cron spec with seconds
+	OutboxBatchSize     int    `yaml:"outbox_batch_size"`     // events read and published at once
+	OutboxKafkaURL      string `yaml:"outbox_kafka_url"`      // Kafka REST Proxy base URL, empty to publish to Redis
+	OutboxKafkaTimeout  int    `yaml:"outbox_kafka_timeout"`  // in seconds, per request to the REST Proxy
+
+	// With leader election, only the instance holding the leader lease in
+	// Redis runs the scheduler
+	LeaderElection bool `yaml:"leader_election"`
+	LeaderTTL      int  `yaml:"leader_ttl"` // in seconds, before a dead leader's lease expires and another instance takes over
+}
+
+// ProxyConfig holds the routes passed through to other services under /proxy
+type ProxyConfig struct {
+	Routes []ProxyRoute `yaml:"routes"`
+}
+
+// ProxyRoute forwards requests under /proxy/<Prefix> to Target
+type ProxyRoute struct {
+	Name          string            `yaml:"name"`
+	Prefix        string            `yaml:"prefix"`  // path prefix below /proxy, stripped before forwarding
+	Targets       []string          `yaml:"targets"` // base URLs requests are forwarded to
+	Balancer      BalancerConfig    `yaml:"balancer"`
+	RateLimit     ThrottleConfig    `yaml:"rate_limit"`
+	Timeout       int               `yaml:"timeout"`     // in seconds, for the whole proxied request
+	MaxRetries    int               `yaml:"max_retries"` // retries of idempotent requests without a body
+	SetHeaders    map[string]string `yaml:"set_headers"`
+	RemoveHeaders []string          `yaml:"remove_headers"`
+}
+
+// WebhookConfig holds settings for webhooks pushed by the upstream and
+// for deliveries to webhook subscribers
+type WebhookConfig struct {
+	Secret    string `yaml:"secret"`    // HMAC-SHA256 signing secret, empty disables webhooks
+	Tolerance int    `yaml:"tolerance"` // in seconds, max age of a delivery's timestamp
+
+	DeliveryTimeout     int `yaml:"delivery_timeout"`      // in seconds, per outbound delivery attempt
+	DeliveryMaxAttempts int `yaml:"delivery_max_attempts"` // attempts before an outbound delivery is given up
+	DeliveryRetryDelay  int `yaml:"delivery_retry_delay"`  // in seconds, before the first retry, doubled per attempt
+	DeliveryBatchSize   int `yaml:"delivery_batch_size"`   // outbound deliveries sent per job run
+}
+
+// KafkaConfig holds the Kafka producer publishing item changes and sync
+// completions for data platforms. No brokers disables it.
+type KafkaConfig struct {
+	Brokers      []string `yaml:"brokers"` // host:port of the bootstrap brokers
+	Topic        string   `yaml:"topic"`
+	WriteTimeout int      `yaml:"write_timeout"` // in seconds, per produce request
+}
+
+// TLSConfig holds HTTPS serving configuration. Certificates come from
+// CertFile and KeyFile, or from Let's Encrypt for AutocertDomains.
+type TLSConfig struct {
+	Enabled          bool     `yaml:"enabled"`
+	CertFile         string   `yaml:"cert_file"` // PEM certificate chain
+	KeyFile          string   `yaml:"key_file"`  // PEM private key
+	AutocertDomains  []string `yaml:"autocert_domains"`
+	AutocertEmail    string   `yaml:"autocert_email"`     // contact for the Let's Encrypt account, optional
+	AutocertCacheDir string   `yaml:"autocert_cache_dir"` // where obtained certificates are kept across restarts
+	RedirectPort     string   `yaml:"redirect_port"`      // plain HTTP port redirecting to HTTPS, empty disables
+}
+
+// GRPCConfig holds the gRPC server configuration
+type GRPCConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Port    string `yaml:"port"`
+}
+
+// GraphQLConfig holds the /graphql endpoint configuration
+type GraphQLConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	MaxDepth int  `yaml:"max_depth"` // deepest field nesting a query may use, including introspection, 0 for no limit
+}
+
+// WebSocketConfig holds the /ws live updates endpoint configuration
+type WebSocketConfig struct {
+	Enabled           bool `yaml:"enabled"`
+	MaxConnections    int  `yaml:"max_connections"`    // per instance, 0 for no limit
+	HeartbeatInterval int  `yaml:"heartbeat_interval"` // in seconds, between heartbeats that keep idle connections open
+}
+
+// IdempotencyConfig holds Idempotency-Key handling for POST endpoints
+type IdempotencyConfig struct {
+	Enabled bool `yaml:"enabled"`
+	TTL     int  `yaml:"ttl"` // in seconds, how long a response is replayed for its key
+}
+
+// Secrets providers
+const (
+	SecretsNone  = ""
+	SecretsVault = "vault" // HashiCorp Vault KV version 2
+	SecretsAWS   = "aws"   // AWS Secrets Manager
+)
+
+// SecretsConfig holds where the secrets named by *Ref settings are fetched
+// from. A reference is <path>#<key>: the secret's path in Vault or name in
+// AWS, and the key of the value within it, which may be left out for
+// secrets holding a single value.
+type SecretsConfig struct {
+	Provider        string `yaml:"provider"`
+	RefreshInterval int    `yaml:"refresh_interval"` // in seconds, between checks for rotated secrets, 0 disables refreshing
+
+	VaultAddr      string `yaml:"vault_addr"`
+	VaultToken     string `yaml:"vault_token"`
+	VaultMount     string `yaml:"vault_mount"`     // KV version 2 secrets engine mount
+	VaultNamespace string `yaml:"vault_namespace"` // Vault Enterprise namespace, empty for none
+
+	AWSRegion          string `yaml:"aws_region"`
+	AWSEndpoint        string `yaml:"aws_endpoint"` // overrides the regional Secrets Manager endpoint
+	AWSAccessKeyID     string `yaml:"aws_access_key_id"`
+	AWSSecretAccessKey string `yaml:"aws_secret_access_key"`
+	AWSSessionToken    string `yaml:"aws_session_token"` // for temporary credentials
+}
+
+// ErrorReportingConfig holds the Sentry error reporting configuration.
+// Reporting is disabled without a DSN.
+type ErrorReportingConfig struct {
+	DSN         string  `yaml:"dsn"`
+	Environment string  `yaml:"environment"` // defaults to Environment
+	Release     string  `yaml:"release"`
+	SampleRate  float64 `yaml:"sample_rate"` // share of errors reported, 0-1
+}
+
+// DebugLogConfig holds the capture of request and response headers and
+// bodies in the log, for diagnosing integrations
+type DebugLogConfig struct {
+	Enabled       bool     `yaml:"enabled"`        // capture a sample of requests
+	SampleRate    float64  `yaml:"sample_rate"`    // share of requests captured while enabled, 0-1
+	HeaderEnabled bool     `yaml:"header_enabled"` // let admins capture a request with the X-Debug-Log header
+	MaxBodySize   int      `yaml:"max_body_size"`  // in bytes, captured of each body
+	RedactHeaders []string `yaml:"redact_headers"` // on top of those named like redacted log fields
+}
+
+// CacheConfig holds response cache configuration
+type CacheConfig struct {
+	LocalSize       int `yaml:"local_size"`        // in-process fallback entries used while Redis is down, 0 disables
+	OrderStatusTTL  int `yaml:"order_status_ttl"`  // in seconds, 0 disables caching order status summaries
+	TopCustomersTTL int `yaml:"top_customers_ttl"` // in seconds, 0 disables caching top customers
+	ItemStatsTTL    int `yaml:"item_stats_ttl"`    // in seconds, 0 disables caching item statistics
+	NegativeTTL     int `yaml:"negative_ttl"`      // in seconds, for missing items and empty results; 0 disables caching them
+
+	// TTLJitter spreads the expiry of keys written together: each TTL
+	// is randomly lengthened or shortened by up to this share of it
+	TTLJitter float64 `yaml:"ttl_jitter"`
+
+	// WarmTargets are API requests, path and query, whose results are
+	// cached at startup and after every sync that changes items
+	WarmEnabled bool     `yaml:"warm_enabled"`
+	WarmTargets []string `yaml:"warm_targets"`
+}
+
+// CompressionConfig holds response compression configuration
+type CompressionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	MinSize int  `yaml:"min_size"` // in bytes, smaller responses are sent uncompressed
+	Level   int  `yaml:"level"`    // gzip level 1-9, -1 for the default
+}
+
+// Load loads configuration from defaults, overlaid by the YAML or JSON file
+// at path if it isn't empty, overlaid in turn by environment variables. The
+// result is validated before it is returned.
+func Load(path string) (*Config, error) {
+	cfg := defaults()
+	if path != "" {
+		if err := loadFile(cfg, path); err != nil {
+			return nil, err
+		}
+	}
+	applyEnv(cfg)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
 }
 
-// Load loads configuration from environment variables
-func Load() *Config {
+// defaults returns the configuration used when neither the file nor the
+// environment sets a value
+func defaults() *Config {
+	upstream := defaultUpstream()
+	upstream.BaseURLs = []string{"https://jsonplaceholder.typicode.com"}
+
 	return &Config{
-		Environment: getEnv("ENVIRONMENT", "development"),
-		Port:        getEnv("PORT", "8080"),
+		Environment:    "development",
+		Port:           "8080",
+		LogLevel:       "info",
+		ReloadInterval: 10,
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnvAsInt("DB_PORT", 3306),
-			User:     getEnv("DB_USER", "apiuser"),
-			Password: getEnv("DB_PASSWORD", "apipassword"),
-			Name:     getEnv("DB_NAME", "api_gateway"),
+			Driver:   "mysql",
+			Host:     "localhost",
+			Port:     3306,
+			User:     "apiuser",
+			Password: "apipassword",
+			Name:     "api_gateway",
+			SSLMode:  "disable",
+
+			SlowQueryThreshold: 200,
 		},
 		Redis: RedisConfig{
-			Host:     getEnv("REDIS_HOST", "localhost"),
-			Port:     getEnvAsInt("REDIS_PORT", 6379),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       getEnvAsInt("REDIS_DB", 0),
+			Mode:          "standalone",
+			Host:          "localhost",
+			Port:          6379,
+			ScanBatchSize: 500,
 		},
 		ExternalAPI: ExternalAPIConfig{
-			BaseURL: getEnv("EXTERNAL_API_URL", "https://jsonplaceholder.typicode.com"),
-			Timeout: getEnvAsInt("EXTERNAL_API_TIMEOUT", 30),
+			Mode:      ExternalAPILive,
+			Upstreams: map[string]UpstreamConfig{DefaultUpstream: upstream},
+		},
+		HTTP: HTTPConfig{
+			MaxBodySize:    1 << 20,
+			MaxHeaderBytes: 1 << 20,
+			RequestTimeout: 10,
+		},
+		IPFilter: IPFilterConfig{
+			// Loopback only, for a proxy on the same host. Any host on a
+			// private network could otherwise spoof its client address, so
+			// load balancers there have to be listed.
+			TrustedProxies: []string{"127.0.0.0/8", "::1/128"},
+		},
+		Concurrency: ConcurrencyConfig{
+			MaxInFlight:          1000,
+			MaxInFlightAPI:       200,
+			MaxInFlightAnalytics: 20,
+			QueueTimeout:         100,
+			RetryAfter:           1,
+		},
+		Auth: AuthConfig{
+			JWTAlgorithm:     "HS256",
+			APIKeyCacheTTL:   300,
+			OIDCJWKSCacheTTL: 3600,
+			SigningTolerance: 300,
+		},
+		RBAC: RBACConfig{
+			Roles:        rbac.DefaultRoles(),
+			DefaultRoles: []string{"operator"},
+			JWTClaim:     "roles",
+		},
+		Tenancy: TenancyConfig{
+			JWTClaim: "tenant_id",
+		},
+		RateLimit: RateLimitConfig{
+			RequestsPerMinute: 600,
+			Burst:             100,
+		},
+		Health: HealthConfig{
+			SyncStaleAfter: 2700,
+			CheckCacheTTL:  5,
+			CheckTimeout:   2,
+			CheckInterval:  10,
+			HistorySize:    20,
+			DownAfter:      2,
+		},
+		Jobs: JobsConfig{
+			SyncLockTTL:       30,
+			SyncBatchSize:     500,
+			SyncWorkers:       4,
+			SyncEnabled:       true,
+			SyncSchedule:      "0 */15 * * * *",
+			AnalyticsEnabled:  true,
+			AnalyticsSchedule: "0 */10 * * * *",
+
+			OrderStatsSchedule: "0 0 2 * * *",
+			OrderStatsMaxAge:   93600,
+
+			IngestWorkers:    4,
+			IngestBatchSize:  100,
+			IngestMaxBacklog: 10000,
+
+			ResourceSyncEnabled:     true,
+			UsersSyncSchedule:       "0 0 * * * *",
+			CommentsSyncSchedule:    "0 20 * * * *",
+			TodosSyncSchedule:       "0 40 * * * *",
+			ResourceSyncConcurrency: 3,
+
+			WebhookDeliveryEnabled:  true,
+			WebhookDeliverySchedule: "*/15 * * * * *",
+
+			ItemRetryEnabled:     true,
+			ItemRetrySchedule:    "*/30 * * * * *",
+			ItemRetryMaxAttempts: 5,
+			ItemRetryDelay:       30,
+
+			OutboxRelaySchedule: "*/5 * * * * *",
+			OutboxBatchSize:     500,
+			OutboxKafkaTimeout:  10,
+
+			LeaderElection: true,
+			LeaderTTL:      15,
+		},
+		TLS: TLSConfig{
+			AutocertCacheDir: "autocert",
+		},
+		GRPC: GRPCConfig{
+			Port: "9090",
+		},
+		GraphQL: GraphQLConfig{
+			Enabled:  true,
+			MaxDepth: 15,
+		},
+		WebSocket: WebSocketConfig{
+			Enabled:           true,
+			MaxConnections:    1000,
+			HeartbeatInterval: 30,
+		},
+		Idempotency: IdempotencyConfig{
+			Enabled: true,
+			TTL:     86400,
+		},
+		Cache: CacheConfig{
+			LocalSize:       1000,
+			OrderStatusTTL:  60,
+			TopCustomersTTL: 60,
+			ItemStatsTTL:    60,
+			NegativeTTL:     30,
+			TTLJitter:       0.1,
+			WarmEnabled:     true,
+			WarmTargets: []string{
+				"/api/v1/items",
+				"/api/v1/analytics/orders/status",
+				"/api/v1/analytics/customers/top",
+			},
+		},
+		Compression: CompressionConfig{
+			Enabled: true,
+			MinSize: 1024,
+			Level:   -1,
 		},
+		Secrets: SecretsConfig{
+			RefreshInterval: 300,
+			VaultMount:      "secret",
+		},
+		ErrorReporting: ErrorReportingConfig{
+			SampleRate: 1,
+		},
+		DebugLog: DebugLogConfig{
+			SampleRate:    0.01,
+			HeaderEnabled: true,
+			MaxBodySize:   4096,
+		},
+		Webhooks: WebhookConfig{
+			Tolerance:           300,
+			DeliveryTimeout:     10,
+			DeliveryMaxAttempts: 8,
+			DeliveryRetryDelay:  30,
+			DeliveryBatchSize:   100,
+		},
+		Kafka: KafkaConfig{
+			Topic:        "gateway.events",
+			WriteTimeout: 10,
+		},
+	}
+}
+
+// defaultUpstream returns the settings of an upstream that only sets its
+// base URLs
+func defaultUpstream() UpstreamConfig {
+	return UpstreamConfig{
+		Balancer:          defaultBalancer(),
+		RateLimit:         defaultThrottle(),
+		Timeout:           30,
+		MaxRetries:        3,
+		MaxRetryDuration:  60,
+		RetryBaseDelay:    1000,
+		RetryMaxDelay:     30000,
+		SlowCallThreshold: 2000,
+		AuthHeader:        "X-API-Key",
+		CacheTTL:          60,
+		CacheStaleTTL:     3600,
+		Pagination: PaginationConfig{
+			PageParam:   "_page",
+			LimitParam:  "_limit",
+			PageSize:    100,
+			CursorParam: "cursor",
+			CursorField: "next_cursor",
+			MaxPages:    100,
+		},
+	}
+}
+
+// defaultProxyRoute returns the settings of a proxy route that only sets
+// its targets
+func defaultProxyRoute(name string) ProxyRoute {
+	return ProxyRoute{
+		Name:      name,
+		Balancer:  defaultBalancer(),
+		RateLimit: defaultThrottle(),
+		Timeout:   30,
+	}
+}
+
+// defaultBalancer returns the default load balancing settings
+func defaultBalancer() BalancerConfig {
+	return BalancerConfig{
+		Policy:        BalanceRoundRobin,
+		EjectAfter:    3,
+		EjectDuration: 30,
+	}
+}
+
+// defaultThrottle returns the client-side rate limit settings, disabled
+// until a rate is set
+func defaultThrottle() ThrottleConfig {
+	return ThrottleConfig{
+		Burst:   1,
+		MaxWait: 30,
+	}
+}
+
+// applyEnv overrides cfg with any environment variables that are set
+func applyEnv(cfg *Config) {
+	cfg.Environment = getEnv("ENVIRONMENT", cfg.Environment)
+	cfg.Port = getEnv("PORT", cfg.Port)
+	cfg.LogLevel = getEnv("LOG_LEVEL", cfg.LogLevel)
+	cfg.LogRedact = getEnvAsSlice("LOG_REDACT_FIELDS", cfg.LogRedact)
+	cfg.ReloadInterval = getEnvAsInt("CONFIG_RELOAD_INTERVAL", cfg.ReloadInterval)
+
+	db := &cfg.Database
+	db.Driver = getEnv("DB_DRIVER", db.Driver)
+	db.Host = getEnv("DB_HOST", db.Host)
+	db.Port = getEnvAsInt("DB_PORT", db.Port)
+	db.User = getEnv("DB_USER", db.User)
+	db.Password = getEnv("DB_PASSWORD", db.Password)
+	db.PasswordRef = getEnv("DB_PASSWORD_REF", db.PasswordRef)
+	db.Name = getEnv("DB_NAME", db.Name)
+	db.SSLMode = getEnv("DB_SSL_MODE", db.SSLMode)
+	db.SlowQueryThreshold = getEnvAsInt("DB_SLOW_QUERY_THRESHOLD", db.SlowQueryThreshold)
+
+	r := &cfg.Redis
+	r.Mode = getEnv("REDIS_MODE", r.Mode)
+	r.Host = getEnv("REDIS_HOST", r.Host)
+	r.Port = getEnvAsInt("REDIS_PORT", r.Port)
+	r.Addrs = getEnvAsSlice("REDIS_ADDRS", r.Addrs)
+	r.MasterName = getEnv("REDIS_MASTER_NAME", r.MasterName)
+	r.Password = getEnv("REDIS_PASSWORD", r.Password)
+	r.PasswordRef = getEnv("REDIS_PASSWORD_REF", r.PasswordRef)
+	r.SentinelPassword = getEnv("REDIS_SENTINEL_PASSWORD", r.SentinelPassword)
+	r.DB = getEnvAsInt("REDIS_DB", r.DB)
+	r.ScanBatchSize = getEnvAsInt("REDIS_SCAN_BATCH_SIZE", r.ScanBatchSize)
+
+	applyUpstreamsEnv(&cfg.ExternalAPI)
+
+	cfg.HTTP.MaxBodySize = getEnvAsInt("HTTP_MAX_BODY_SIZE", cfg.HTTP.MaxBodySize)
+	cfg.HTTP.MaxHeaderBytes = getEnvAsInt("HTTP_MAX_HEADER_BYTES", cfg.HTTP.MaxHeaderBytes)
+	cfg.HTTP.RequestTimeout = getEnvAsInt("HTTP_REQUEST_TIMEOUT", cfg.HTTP.RequestTimeout)
+
+	applyIPFilterEnv(&cfg.IPFilter)
+
+	cc := &cfg.Concurrency
+	cc.MaxInFlight = getEnvAsInt("MAX_IN_FLIGHT", cc.MaxInFlight)
+	cc.MaxInFlightAPI = getEnvAsInt("MAX_IN_FLIGHT_API", cc.MaxInFlightAPI)
+	cc.MaxInFlightAnalytics = getEnvAsInt("MAX_IN_FLIGHT_ANALYTICS", cc.MaxInFlightAnalytics)
+	cc.QueueTimeout = getEnvAsInt("LOAD_SHED_QUEUE_TIMEOUT_MS", cc.QueueTimeout)
+	cc.RetryAfter = getEnvAsInt("LOAD_SHED_RETRY_AFTER", cc.RetryAfter)
+
+	a := &cfg.Auth
+	a.JWTEnabled = getEnvAsBool("JWT_ENABLED", a.JWTEnabled)
+	a.JWTAlgorithm = getEnv("JWT_ALGORITHM", a.JWTAlgorithm)
+	a.JWTSecret = getEnv("JWT_SECRET", a.JWTSecret)
+	a.JWTPublicKeyFile = getEnv("JWT_PUBLIC_KEY_FILE", a.JWTPublicKeyFile)
+	a.JWTIssuer = getEnv("JWT_ISSUER", a.JWTIssuer)
+	a.JWTAudience = getEnv("JWT_AUDIENCE", a.JWTAudience)
+	a.OIDCEnabled = getEnvAsBool("OIDC_ENABLED", a.OIDCEnabled)
+	a.OIDCIssuer = getEnv("OIDC_ISSUER", a.OIDCIssuer)
+	a.OIDCAudience = getEnv("OIDC_AUDIENCE", a.OIDCAudience)
+	a.OIDCJWKSCacheTTL = getEnvAsInt("OIDC_JWKS_CACHE_TTL", a.OIDCJWKSCacheTTL)
+	a.APIKeysEnabled = getEnvAsBool("API_KEYS_ENABLED", a.APIKeysEnabled)
+	a.APIKeyCacheTTL = getEnvAsInt("API_KEY_CACHE_TTL", a.APIKeyCacheTTL)
+	a.AdminToken = getEnv("ADMIN_TOKEN", a.AdminToken)
+	a.SigningEnabled = getEnvAsBool("SIGNING_ENABLED", a.SigningEnabled)
+	a.SigningTolerance = getEnvAsInt("SIGNING_TOLERANCE", a.SigningTolerance)
+	applySigningClientsEnv(a)
+
+	cfg.RBAC.Enabled = getEnvAsBool("RBAC_ENABLED", cfg.RBAC.Enabled)
+	cfg.RBAC.DefaultRoles = getEnvAsSlice("RBAC_DEFAULT_ROLES", cfg.RBAC.DefaultRoles)
+	cfg.RBAC.JWTClaim = getEnv("RBAC_JWT_CLAIM", cfg.RBAC.JWTClaim)
+
+	cfg.Tenancy.Enabled = getEnvAsBool("TENANCY_ENABLED", cfg.Tenancy.Enabled)
+	cfg.Tenancy.JWTClaim = getEnv("TENANCY_JWT_CLAIM", cfg.Tenancy.JWTClaim)
+
+	rl := &cfg.RateLimit
+	rl.Enabled = getEnvAsBool("RATE_LIMIT_ENABLED", rl.Enabled)
+	rl.RequestsPerMinute = getEnvAsInt("RATE_LIMIT_REQUESTS_PER_MINUTE", rl.RequestsPerMinute)
+	rl.Burst = getEnvAsInt("RATE_LIMIT_BURST", rl.Burst)
+
+	cfg.Health.SyncStaleAfter = getEnvAsInt("SYNC_STALE_AFTER", cfg.Health.SyncStaleAfter)
+	cfg.Health.CheckCacheTTL = getEnvAsInt("HEALTH_CHECK_CACHE_TTL", cfg.Health.CheckCacheTTL)
+	cfg.Health.CheckTimeout = getEnvAsInt("HEALTH_CHECK_TIMEOUT", cfg.Health.CheckTimeout)
+	cfg.Health.CheckInterval = getEnvAsInt("HEALTH_CHECK_INTERVAL", cfg.Health.CheckInterval)
+	cfg.Health.HistorySize = getEnvAsInt("HEALTH_HISTORY_SIZE", cfg.Health.HistorySize)
+	cfg.Health.DownAfter = getEnvAsInt("HEALTH_DOWN_AFTER", cfg.Health.DownAfter)
+
+	j := &cfg.Jobs
+	j.SyncLockTTL = getEnvAsInt("SYNC_LOCK_TTL", j.SyncLockTTL)
+	j.SyncBatchSize = getEnvAsInt("SYNC_BATCH_SIZE", j.SyncBatchSize)
+	j.SyncWorkers = getEnvAsInt("SYNC_WORKERS", j.SyncWorkers)
+	j.SyncAtomic = getEnvAsBool("SYNC_ATOMIC", j.SyncAtomic)
+	j.SyncMaxErrors = getEnvAsInt("SYNC_MAX_ERRORS", j.SyncMaxErrors)
+	j.SyncEnabled = getEnvAsBool("JOB_SYNC_ENABLED", j.SyncEnabled)
+	j.SyncSchedule = getEnv("CRON_SYNC_SCHEDULE", j.SyncSchedule)
+	j.AnalyticsEnabled = getEnvAsBool("JOB_ANALYTICS_ENABLED", j.AnalyticsEnabled)
+	j.AnalyticsSchedule = getEnv("CRON_ANALYTICS_SCHEDULE", j.AnalyticsSchedule)
+	j.OrderStatsEnabled = getEnvAsBool("JOB_ORDER_STATS_ENABLED", j.OrderStatsEnabled)
+	j.OrderStatsSchedule = getEnv("CRON_ORDER_STATS_SCHEDULE", j.OrderStatsSchedule)
+	j.OrderStatsMaxAge = getEnvAsInt("ORDER_STATS_MAX_AGE", j.OrderStatsMaxAge)
+	j.IngestStream = getEnvAsBool("SYNC_INGEST_STREAM", j.IngestStream)
+	j.IngestWorkers = getEnvAsInt("SYNC_INGEST_WORKERS", j.IngestWorkers)
+	j.IngestBatchSize = getEnvAsInt("SYNC_INGEST_BATCH_SIZE", j.IngestBatchSize)
+	j.IngestMaxBacklog = getEnvAsInt("SYNC_INGEST_MAX_BACKLOG", j.IngestMaxBacklog)
+	j.ResourceSyncEnabled = getEnvAsBool("JOB_RESOURCE_SYNC_ENABLED", j.ResourceSyncEnabled)
+	j.UsersSyncSchedule = getEnv("CRON_SYNC_USERS_SCHEDULE", j.UsersSyncSchedule)
+	j.CommentsSyncSchedule = getEnv("CRON_SYNC_COMMENTS_SCHEDULE", j.CommentsSyncSchedule)
+	j.TodosSyncSchedule = getEnv("CRON_SYNC_TODOS_SCHEDULE", j.TodosSyncSchedule)
+	j.ResourceSyncSchedule = getEnv("CRON_SYNC_RESOURCES_SCHEDULE", j.ResourceSyncSchedule)
+	j.ResourceSyncConcurrency = getEnvAsInt("JOB_RESOURCE_SYNC_CONCURRENCY", j.ResourceSyncConcurrency)
+	j.WebhookDeliveryEnabled = getEnvAsBool("JOB_WEBHOOK_DELIVERY_ENABLED", j.WebhookDeliveryEnabled)
+	j.WebhookDeliverySchedule = getEnv("CRON_WEBHOOK_DELIVERY_SCHEDULE", j.WebhookDeliverySchedule)
+	j.ItemRetryEnabled = getEnvAsBool("JOB_ITEM_RETRY_ENABLED", j.ItemRetryEnabled)
+	j.ItemRetrySchedule = getEnv("CRON_ITEM_RETRY_SCHEDULE", j.ItemRetrySchedule)
+	j.ItemRetryMaxAttempts = getEnvAsInt("ITEM_RETRY_MAX_ATTEMPTS", j.ItemRetryMaxAttempts)
+	j.ItemRetryDelay = getEnvAsInt("ITEM_RETRY_DELAY", j.ItemRetryDelay)
+	j.Outbox = getEnvAsBool("OUTBOX_ENABLED", j.Outbox)
+	j.OutboxRelaySchedule = getEnv("CRON_OUTBOX_RELAY_SCHEDULE", j.OutboxRelaySchedule)
+	j.OutboxBatchSize = getEnvAsInt("OUTBOX_BATCH_SIZE", j.OutboxBatchSize)
+	j.OutboxKafkaURL = getEnv("OUTBOX_KAFKA_URL", j.OutboxKafkaURL)
+	j.OutboxKafkaTimeout = getEnvAsInt("OUTBOX_KAFKA_TIMEOUT", j.OutboxKafkaTimeout)
+	j.LeaderElection = getEnvAsBool("JOB_LEADER_ELECTION", j.LeaderElection)
+	j.LeaderTTL = getEnvAsInt("JOB_LEADER_TTL", j.LeaderTTL)
+
+	t := &cfg.TLS
+	t.Enabled = getEnvAsBool("TLS_ENABLED", t.Enabled)
+	t.CertFile = getEnv("TLS_CERT_FILE", t.CertFile)
+	t.KeyFile = getEnv("TLS_KEY_FILE", t.KeyFile)
+	t.AutocertDomains = getEnvAsSlice("TLS_AUTOCERT_DOMAINS", t.AutocertDomains)
+	t.AutocertEmail = getEnv("TLS_AUTOCERT_EMAIL", t.AutocertEmail)
+	t.AutocertCacheDir = getEnv("TLS_AUTOCERT_CACHE_DIR", t.AutocertCacheDir)
+	t.RedirectPort = getEnv("TLS_REDIRECT_PORT", t.RedirectPort)
+
+	cfg.GRPC.Enabled = getEnvAsBool("GRPC_ENABLED", cfg.GRPC.Enabled)
+	cfg.GRPC.Port = getEnv("GRPC_PORT", cfg.GRPC.Port)
+
+	cfg.GraphQL.Enabled = getEnvAsBool("GRAPHQL_ENABLED", cfg.GraphQL.Enabled)
+	cfg.GraphQL.MaxDepth = getEnvAsInt("GRAPHQL_MAX_DEPTH", cfg.GraphQL.MaxDepth)
+
+	ws := &cfg.WebSocket
+	ws.Enabled = getEnvAsBool("WS_ENABLED", ws.Enabled)
+	ws.MaxConnections = getEnvAsInt("WS_MAX_CONNECTIONS", ws.MaxConnections)
+	ws.HeartbeatInterval = getEnvAsInt("WS_HEARTBEAT_INTERVAL", ws.HeartbeatInterval)
+
+	cfg.Idempotency.Enabled = getEnvAsBool("IDEMPOTENCY_ENABLED", cfg.Idempotency.Enabled)
+	cfg.Idempotency.TTL = getEnvAsInt("IDEMPOTENCY_TTL", cfg.Idempotency.TTL)
+
+	c := &cfg.Cache
+	c.LocalSize = getEnvAsInt("CACHE_LOCAL_SIZE", c.LocalSize)
+	c.OrderStatusTTL = getEnvAsInt("CACHE_ORDER_STATUS_TTL", c.OrderStatusTTL)
+	c.TopCustomersTTL = getEnvAsInt("CACHE_TOP_CUSTOMERS_TTL", c.TopCustomersTTL)
+	c.ItemStatsTTL = getEnvAsInt("CACHE_ITEM_STATS_TTL", c.ItemStatsTTL)
+	c.NegativeTTL = getEnvAsInt("CACHE_NEGATIVE_TTL", c.NegativeTTL)
+	c.TTLJitter = getEnvAsFloat("CACHE_TTL_JITTER", c.TTLJitter)
+	c.WarmEnabled = getEnvAsBool("CACHE_WARM_ENABLED", c.WarmEnabled)
+	c.WarmTargets = getEnvAsSlice("CACHE_WARM_TARGETS", c.WarmTargets)
+
+	comp := &cfg.Compression
+	comp.Enabled = getEnvAsBool("COMPRESSION_ENABLED", comp.Enabled)
+	comp.MinSize = getEnvAsInt("COMPRESSION_MIN_SIZE", comp.MinSize)
+	comp.Level = getEnvAsInt("COMPRESSION_LEVEL", comp.Level)
+
+	applyProxyRoutesEnv(&cfg.Proxy)
+
+	sec := &cfg.Secrets
+	sec.Provider = strings.ToLower(getEnv("SECRETS_PROVIDER", sec.Provider))
+	sec.RefreshInterval = getEnvAsInt("SECRETS_REFRESH_INTERVAL", sec.RefreshInterval)
+	sec.VaultAddr = getEnv("VAULT_ADDR", sec.VaultAddr)
+	sec.VaultToken = getEnv("VAULT_TOKEN", sec.VaultToken)
+	sec.VaultMount = getEnv("VAULT_MOUNT", sec.VaultMount)
+	sec.VaultNamespace = getEnv("VAULT_NAMESPACE", sec.VaultNamespace)
+	sec.AWSRegion = getEnv("AWS_REGION", sec.AWSRegion)
+	sec.AWSEndpoint = getEnv("AWS_SECRETS_MANAGER_ENDPOINT", sec.AWSEndpoint)
+	sec.AWSAccessKeyID = getEnv("AWS_ACCESS_KEY_ID", sec.AWSAccessKeyID)
+	sec.AWSSecretAccessKey = getEnv("AWS_SECRET_ACCESS_KEY", sec.AWSSecretAccessKey)
+	sec.AWSSessionToken = getEnv("AWS_SESSION_TOKEN", sec.AWSSessionToken)
+
+	er := &cfg.ErrorReporting
+	er.DSN = getEnv("SENTRY_DSN", er.DSN)
+	er.Environment = getEnv("SENTRY_ENVIRONMENT", er.Environment)
+	er.Release = getEnv("SENTRY_RELEASE", er.Release)
+	er.SampleRate = getEnvAsFloat("SENTRY_SAMPLE_RATE", er.SampleRate)
+
+	dl := &cfg.DebugLog
+	dl.Enabled = getEnvAsBool("DEBUG_LOG_ENABLED", dl.Enabled)
+	dl.SampleRate = getEnvAsFloat("DEBUG_LOG_SAMPLE_RATE", dl.SampleRate)
+	dl.HeaderEnabled = getEnvAsBool("DEBUG_LOG_HEADER_ENABLED", dl.HeaderEnabled)
+	dl.MaxBodySize = getEnvAsInt("DEBUG_LOG_MAX_BODY_SIZE", dl.MaxBodySize)
+	dl.RedactHeaders = getEnvAsSlice("DEBUG_LOG_REDACT_HEADERS", dl.RedactHeaders)
+
+	w := &cfg.Webhooks
+	w.Secret = getEnv("WEBHOOK_SECRET", w.Secret)
+	w.Tolerance = getEnvAsInt("WEBHOOK_TOLERANCE", w.Tolerance)
+	w.DeliveryTimeout = getEnvAsInt("WEBHOOK_DELIVERY_TIMEOUT", w.DeliveryTimeout)
+	w.DeliveryMaxAttempts = getEnvAsInt("WEBHOOK_DELIVERY_MAX_ATTEMPTS", w.DeliveryMaxAttempts)
+	w.DeliveryRetryDelay = getEnvAsInt("WEBHOOK_DELIVERY_RETRY_DELAY", w.DeliveryRetryDelay)
+	w.DeliveryBatchSize = getEnvAsInt("WEBHOOK_DELIVERY_BATCH_SIZE", w.DeliveryBatchSize)
+
+	k := &cfg.Kafka
+	k.Brokers = getEnvAsSlice("KAFKA_BROKERS", k.Brokers)
+	k.Topic = getEnv("KAFKA_TOPIC", k.Topic)
+	k.WriteTimeout = getEnvAsInt("KAFKA_WRITE_TIMEOUT", k.WriteTimeout)
+}
+
+// applyUpstreamsEnv overrides the default upstream from EXTERNAL_API_* and
+// every other upstream, including those named in EXTERNAL_API_UPSTREAMS,
+// from UPSTREAM_<NAME>_*
+func applyUpstreamsEnv(cfg *ExternalAPIConfig) {
+	cfg.Mode = strings.ToLower(getEnv("EXTERNAL_API_MODE", cfg.Mode))
+	for _, name := range getEnvAsSlice("EXTERNAL_API_UPSTREAMS", nil) {
+		if _, ok := cfg.Upstreams[name]; !ok {
+			cfg.Upstreams[name] = defaultUpstream()
+		}
+	}
+	for name, upstream := range cfg.Upstreams {
+		prefix := "EXTERNAL_API_"
+		if name != DefaultUpstream {
+			prefix = envPrefix("UPSTREAM_", name)
+		}
+		applyUpstreamEnv(prefix, &upstream)
+		cfg.Upstreams[name] = upstream
 	}
 }
 
+// applyUpstreamEnv overrides one upstream from variables sharing prefix
+func applyUpstreamEnv(prefix string, u *UpstreamConfig) {
+	u.BaseURLs = getEnvAsSlice(prefix+"URL", u.BaseURLs)
+	applyBalancerEnv(prefix, &u.Balancer)
+	applyThrottleEnv(prefix, &u.RateLimit)
+	u.Timeout = getEnvAsInt(prefix+"TIMEOUT", u.Timeout)
+	u.MaxRetries = getEnvAsInt(prefix+"MAX_RETRIES", u.MaxRetries)
+	u.MaxRetryDuration = getEnvAsInt(prefix+"MAX_RETRY_DURATION", u.MaxRetryDuration)
+	u.RetryBaseDelay = getEnvAsInt(prefix+"RETRY_BASE_DELAY", u.RetryBaseDelay)
+	u.RetryMaxDelay = getEnvAsInt(prefix+"RETRY_MAX_DELAY", u.RetryMaxDelay)
+	u.RetryStatusCodes = getEnvAsIntSlice(prefix+"RETRY_STATUS_CODES", u.RetryStatusCodes)
+	u.SlowCallThreshold = getEnvAsInt(prefix+"SLOW_CALL_THRESHOLD", u.SlowCallThreshold)
+	u.AuthType = strings.ToLower(getEnv(prefix+"AUTH_TYPE", u.AuthType))
+	u.AuthHeader = getEnv(prefix+"AUTH_HEADER", u.AuthHeader)
+	u.AuthToken = getEnv(prefix+"AUTH_TOKEN", u.AuthToken)
+	u.AuthTokenRef = getEnv(prefix+"AUTH_TOKEN_REF", u.AuthTokenRef)
+	u.AuthUsername = getEnv(prefix+"AUTH_USERNAME", u.AuthUsername)
+	u.AuthPassword = getEnv(prefix+"AUTH_PASSWORD", u.AuthPassword)
+	u.AuthPasswordRef = getEnv(prefix+"AUTH_PASSWORD_REF", u.AuthPasswordRef)
+	u.Headers = getEnvAsMap(prefix+"HEADERS", u.Headers)
+	applyPaginationEnv(prefix, &u.Pagination)
+	u.TLSCertFile = getEnv(prefix+"TLS_CERT_FILE", u.TLSCertFile)
+	u.TLSKeyFile = getEnv(prefix+"TLS_KEY_FILE", u.TLSKeyFile)
+	u.TLSCAFile = getEnv(prefix+"TLS_CA_FILE", u.TLSCAFile)
+	u.TLSInsecureSkipVerify = getEnvAsBool(prefix+"TLS_INSECURE_SKIP_VERIFY", u.TLSInsecureSkipVerify)
+	u.Proxy = getEnv(prefix+"PROXY", u.Proxy)
+	u.HostOverrides = getEnvAsMap(prefix+"HOST_OVERRIDES", u.HostOverrides)
+	u.CacheEnabled = getEnvAsBool(prefix+"CACHE_ENABLED", u.CacheEnabled)
+	u.CacheTTL = getEnvAsInt(prefix+"CACHE_TTL", u.CacheTTL)
+	u.CacheStaleTTL = getEnvAsInt(prefix+"CACHE_STALE_TTL", u.CacheStaleTTL)
+
+	u.OAuthTokenURL = getEnv(prefix+"OAUTH_TOKEN_URL", u.OAuthTokenURL)
+	u.OAuthClientID = getEnv(prefix+"OAUTH_CLIENT_ID", u.OAuthClientID)
+	u.OAuthClientSecret = getEnv(prefix+"OAUTH_CLIENT_SECRET", u.OAuthClientSecret)
+	u.OAuthClientSecretRef = getEnv(prefix+"OAUTH_CLIENT_SECRET_REF", u.OAuthClientSecretRef)
+	u.OAuthScopes = getEnvAsSlice(prefix+"OAUTH_SCOPES", u.OAuthScopes)
+}
+
+// adminPrefix is the path prefix ADMIN_IP_ALLOWLIST applies to
+const adminPrefix = "/admin"
+
+// applyIPFilterEnv overrides the global lists and, from ADMIN_IP_ALLOWLIST,
+// the allow list of the /admin route, adding the route if the file didn't
+func applyIPFilterEnv(cfg *IPFilterConfig) {
+	cfg.TrustedProxies = getEnvAsSlice("TRUSTED_PROXIES", cfg.TrustedProxies)
+	cfg.Allow = getEnvAsSlice("IP_ALLOWLIST", cfg.Allow)
+	cfg.Deny = getEnvAsSlice("IP_DENYLIST", cfg.Deny)
+
+	allow := getEnvAsSlice("ADMIN_IP_ALLOWLIST", nil)
+	if allow == nil {
+		return
+	}
+	for i := range cfg.Routes {
+		if cfg.Routes[i].Prefix == adminPrefix {
+			cfg.Routes[i].Allow = allow
+			return
+		}
+	}
+	cfg.Routes = append(cfg.Routes, IPFilterRoute{Prefix: adminPrefix, Allow: allow})
+}
+
+// applySigningClientsEnv adds each client named in SIGNING_CLIENTS that
+// the file didn't define, then overrides every client from
+// SIGNING_<ID>_SECRET and SIGNING_<ID>_ROLES
+func applySigningClientsEnv(cfg *AuthConfig) {
+	for _, id := range getEnvAsSlice("SIGNING_CLIENTS", nil) {
+		if cfg.signingClient(id) == nil {
+			cfg.SigningClients = append(cfg.SigningClients, SigningClient{ID: id})
+		}
+	}
+	for i := range cfg.SigningClients {
+		client := &cfg.SigningClients[i]
+		prefix := envPrefix("SIGNING_", client.ID)
+		client.Secret = getEnv(prefix+"SECRET", client.Secret)
+		client.Roles = getEnvAsSlice(prefix+"ROLES", client.Roles)
+		client.Tenant = getEnv(prefix+"TENANT", client.Tenant)
+	}
+}
+
+// signingClient returns the signing client called id, or nil if there is
+// none
+func (c *AuthConfig) signingClient(id string) *SigningClient {
+	for i := range c.SigningClients {
+		if c.SigningClients[i].ID == id {
+			return &c.SigningClients[i]
+		}
+	}
+	return nil
+}
+
+// applyProxyRoutesEnv adds each route named in PROXY_ROUTES that the file
+// didn't define, then overrides every route from PROXY_<NAME>_*
+func applyProxyRoutesEnv(cfg *ProxyConfig) {
+	for _, name := range getEnvAsSlice("PROXY_ROUTES", nil) {
+		if cfg.route(name) == nil {
+			cfg.Routes = append(cfg.Routes, defaultProxyRoute(name))
+		}
+	}
+	for i := range cfg.Routes {
+		route := &cfg.Routes[i]
+		prefix := envPrefix("PROXY_", route.Name)
+		route.Prefix = getEnv(prefix+"PREFIX", route.Prefix)
+		if route.Prefix == "" {
+			route.Prefix = "/" + route.Name
+		}
+		route.Targets = getEnvAsSlice(prefix+"TARGET", route.Targets)
+		applyBalancerEnv(prefix, &route.Balancer)
+		applyThrottleEnv(prefix, &route.RateLimit)
+		route.Timeout = getEnvAsInt(prefix+"TIMEOUT", route.Timeout)
+		route.MaxRetries = getEnvAsInt(prefix+"MAX_RETRIES", route.MaxRetries)
+		route.SetHeaders = getEnvAsMap(prefix+"SET_HEADERS", route.SetHeaders)
+		route.RemoveHeaders = getEnvAsSlice(prefix+"REMOVE_HEADERS", route.RemoveHeaders)
+	}
+}
+
+// route returns the route called name, or nil if there is none
+func (c *ProxyConfig) route(name string) *ProxyRoute {
+	for i := range c.Routes {
+		if c.Routes[i].Name == name {
+			return &c.Routes[i]
+		}
+	}
+	return nil
+}
+
+// applyBalancerEnv overrides load balancing settings from variables
+// sharing prefix
+func applyBalancerEnv(prefix string, b *BalancerConfig) {
+	b.Policy = strings.ToLower(getEnv(prefix+"BALANCE", b.Policy))
+	b.EjectAfter = getEnvAsInt(prefix+"EJECT_AFTER", b.EjectAfter)
+	b.EjectDuration = getEnvAsInt(prefix+"EJECT_DURATION", b.EjectDuration)
+}
+
+// applyThrottleEnv overrides client-side rate limit settings from
+// variables sharing prefix
+func applyThrottleEnv(prefix string, t *ThrottleConfig) {
+	t.RequestsPerSecond = getEnvAsFloat(prefix+"RATE_LIMIT", t.RequestsPerSecond)
+	t.Burst = getEnvAsInt(prefix+"RATE_LIMIT_BURST", t.Burst)
+	t.MaxWait = getEnvAsInt(prefix+"RATE_LIMIT_MAX_WAIT", t.MaxWait)
+}
+
+// applyPaginationEnv overrides pagination settings from variables sharing
+// prefix
+func applyPaginationEnv(prefix string, p *PaginationConfig) {
+	p.Strategy = strings.ToLower(getEnv(prefix+"PAGINATION", p.Strategy))
+	p.PageParam = getEnv(prefix+"PAGINATION_PAGE_PARAM", p.PageParam)
+	p.LimitParam = getEnv(prefix+"PAGINATION_LIMIT_PARAM", p.LimitParam)
+	p.PageSize = getEnvAsInt(prefix+"PAGINATION_PAGE_SIZE", p.PageSize)
+	p.CursorParam = getEnv(prefix+"PAGINATION_CURSOR_PARAM", p.CursorParam)
+	p.CursorField = getEnv(prefix+"PAGINATION_CURSOR_FIELD", p.CursorField)
+	p.ItemsField = getEnv(prefix+"PAGINATION_ITEMS_FIELD", p.ItemsField)
+	p.MaxPages = getEnvAsInt(prefix+"PAGINATION_MAX_PAGES", p.MaxPages)
+}
+
+// envPrefix returns the variable prefix for the upstream or route called
+// name, e.g. UPSTREAM_BILLING_API_ for billing-api
+func envPrefix(kind, name string) string {
+	return kind + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
 // getEnv gets an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -78,4 +1139,75 @@ func getEnvAsInt(key string, defaultValue int) int {
 		}
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+// getEnvAsFloat gets an environment variable as a float or returns a default value
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsSlice gets a comma-separated environment variable as a slice or
+// returns a default value
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// getEnvAsIntSlice gets a comma-separated list of integers as a slice,
+// skipping entries that aren't integers, or returns a default value
+func getEnvAsIntSlice(key string, defaultValue []int) []int {
+	if os.Getenv(key) == "" {
+		return defaultValue
+	}
+
+	var values []int
+	for _, v := range getEnvAsSlice(key, nil) {
+		if intValue, err := strconv.Atoi(v); err == nil {
+			values = append(values, intValue)
+		}
+	}
+	return values
+}
+
+// getEnvAsMap gets a comma-separated list of name:value pairs as a map,
+// skipping entries without a colon, or returns a default value
+func getEnvAsMap(key string, defaultValue map[string]string) map[string]string {
+	if os.Getenv(key) == "" {
+		return defaultValue
+	}
+
+	values := make(map[string]string)
+	for _, pair := range getEnvAsSlice(key, nil) {
+		name, value, ok := strings.Cut(pair, ":")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return values
+}
+
+// getEnvAsBool gets an environment variable as boolean or returns a default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
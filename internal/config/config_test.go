@@ -0,0 +1,414 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/logger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfigFile writes content to a file called name in a temporary
+// directory and returns its path
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load("")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "8080", cfg.Port)
+	assert.Equal(t, "mysql", cfg.Database.Driver)
+	assert.Equal(t, []string{"https://jsonplaceholder.typicode.com"}, cfg.ExternalAPI.Upstreams[DefaultUpstream].BaseURLs)
+	assert.Equal(t, 8, cfg.Webhooks.DeliveryMaxAttempts)
+	assert.Empty(t, cfg.Proxy.Routes)
+	assert.Equal(t, []string{"127.0.0.0/8", "::1/128"}, cfg.IPFilter.TrustedProxies)
+}
+
+func TestLoad_YAMLWithEnvOverride(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+port: "9000"
+database:
+  driver: postgres
+  port: 5432
+external_api:
+  upstreams:
+    default:
+      timeout: 10
+    billing-api:
+      base_urls: [https://billing-1.internal, https://billing-2.internal]
+      balancer:
+        policy: least_connections
+      headers:
+        X-Tenant: acme
+proxy:
+  routes:
+    - name: users
+      targets: [http://users.internal]
+      set_headers:
+        X-Gateway: api
+ip_filter:
+  routes:
+    - prefix: /admin
+      deny: [10.0.0.13]
+auth:
+  signing_clients:
+    - id: billing-worker
+      secret: from-file
+      roles: [reader]
+`)
+	t.Setenv("PORT", "9100")
+	t.Setenv("UPSTREAM_BILLING_API_MAX_RETRIES", "5")
+	t.Setenv("UPSTREAM_BILLING_API_HOST_OVERRIDES", "billing-1.internal:10.0.0.21")
+	t.Setenv("UPSTREAM_BILLING_API_RETRY_STATUS_CODES", "502, 503,soon")
+	t.Setenv("PROXY_ROUTES", "search")
+	t.Setenv("PROXY_SEARCH_TARGET", "http://search.internal")
+	t.Setenv("ADMIN_IP_ALLOWLIST", "10.0.0.0/8, 192.168.1.7")
+	t.Setenv("SIGNING_CLIENTS", "reports")
+	t.Setenv("SIGNING_BILLING_WORKER_SECRET", "from-env")
+	t.Setenv("SIGNING_REPORTS_SECRET", "reports-secret")
+	t.Setenv("SIGNING_REPORTS_TENANT", "acme")
+
+	cfg, err := Load(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Environment variables win over the file, which wins over defaults
+	assert.Equal(t, "9100", cfg.Port)
+	assert.Equal(t, "postgres", cfg.Database.Driver)
+	assert.Equal(t, 5432, cfg.Database.Port)
+	assert.Equal(t, "localhost", cfg.Database.Host)
+
+	// Upstreams in the file start from the defaults
+	def := cfg.ExternalAPI.Upstreams[DefaultUpstream]
+	assert.Equal(t, []string{"https://jsonplaceholder.typicode.com"}, def.BaseURLs)
+	assert.Equal(t, 10, def.Timeout)
+	billing := cfg.ExternalAPI.Upstreams["billing-api"]
+	assert.Equal(t, []string{"https://billing-1.internal", "https://billing-2.internal"}, billing.BaseURLs)
+	assert.Equal(t, BalancerConfig{Policy: BalanceLeastConnections, EjectAfter: 3, EjectDuration: 30}, billing.Balancer)
+	assert.Equal(t, 5, billing.MaxRetries)
+	assert.Equal(t, 30, billing.Timeout)
+	assert.Equal(t, map[string]string{"X-Tenant": "acme"}, billing.Headers)
+	assert.Equal(t, map[string]string{"billing-1.internal": "10.0.0.21"}, billing.HostOverrides)
+	assert.Equal(t, []int{502, 503}, billing.RetryStatusCodes)
+
+	// Routes from the file and PROXY_ROUTES are merged
+	if assert.Len(t, cfg.Proxy.Routes, 2) {
+		assert.Equal(t, "/users", cfg.Proxy.Routes[0].Prefix)
+		assert.Equal(t, 30, cfg.Proxy.Routes[0].Timeout)
+		assert.Equal(t, map[string]string{"X-Gateway": "api"}, cfg.Proxy.Routes[0].SetHeaders)
+		assert.Equal(t, "search", cfg.Proxy.Routes[1].Name)
+		assert.Equal(t, []string{"http://search.internal"}, cfg.Proxy.Routes[1].Targets)
+	}
+
+	// ADMIN_IP_ALLOWLIST sets the allow list of the file's /admin route
+	assert.Equal(t, []IPFilterRoute{
+		{Prefix: "/admin", Allow: []string{"10.0.0.0/8", "192.168.1.7"}, Deny: []string{"10.0.0.13"}},
+	}, cfg.IPFilter.Routes)
+
+	// Signing clients from the file and SIGNING_CLIENTS are merged
+	assert.Equal(t, []SigningClient{
+		{ID: "billing-worker", Secret: "from-env", Roles: []string{"reader"}},
+		{ID: "reports", Secret: "reports-secret", Tenant: "acme"},
+	}, cfg.Auth.SigningClients)
+}
+
+func TestLoad_JSON(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{
+	"graphql": {"enabled": false},
+	"webhooks": {"secret": "s3cret", "delivery_max_attempts": 3}
+}`)
+
+	cfg, err := Load(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, cfg.GraphQL.Enabled)
+	assert.Equal(t, 15, cfg.GraphQL.MaxDepth)
+	assert.Equal(t, "s3cret", cfg.Webhooks.Secret)
+	assert.Equal(t, 3, cfg.Webhooks.DeliveryMaxAttempts)
+}
+
+func TestLoad_Rejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{"unknown key", "config.yaml", "databse:\n  host: db\n", "field databse not found"},
+		{"unknown upstream key", "config.yaml", "external_api:\n  upstreams:\n    default:\n      base_url: http://x\n", `upstream "default"`},
+		{"bad JSON", "config.json", `{"port": }`, "failed to parse config file"},
+		{"extension", "config.toml", `port = "80"`, "unsupported config file extension"},
+		{"invalid value", "config.yaml", "redis:\n  mode: ring\n", `unsupported mode "ring"`},
+		{"route without targets", "config.yaml", "proxy:\n  routes:\n    - name: users\n", `proxy route "users": at least one target is required`},
+		{"upstream without URLs", "config.yaml", "external_api:\n  upstreams:\n    billing: {}\n", `upstream "billing": at least one base URL is required`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfigFile(t, tt.file, tt.content))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("COMPRESSION_LEVEL", "12")
+		t.Setenv("EXTERNAL_API_AUTH_TYPE", "digest")
+		_, err := Load("")
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "compression.level: 12")
+			assert.Contains(t, err.Error(), `unsupported auth type "digest"`)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		problem string
+	}{
+		{"production password", func(c *Config) { c.Environment = "production" }, "database.password: must be set to a non-default password in production"},
+		{"cron schedule", func(c *Config) { c.Jobs.SyncSchedule = "*/15 * * * *" }, `jobs.sync_schedule: invalid cron schedule "*/15 * * * *"`},
+		{"disabled job schedule", func(c *Config) { c.Jobs.AnalyticsEnabled, c.Jobs.AnalyticsSchedule = false, "never" }, ""},
+		{"order stats max age", func(c *Config) { c.Jobs.OrderStatsEnabled, c.Jobs.OrderStatsMaxAge = true, 0 }, "jobs.order_stats_max_age: must be positive, got 0"},
+		{"combined resource sync", func(c *Config) {
+			c.Jobs.ResourceSyncSchedule, c.Jobs.ResourceSyncConcurrency = "0 30 * * * *", 0
+		}, "jobs.resource_sync_concurrency: must be positive, got 0"},
+		{"base URL", func(c *Config) {
+			u := c.ExternalAPI.Upstreams[DefaultUpstream]
+			u.BaseURLs = []string{"jsonplaceholder.typicode.com"}
+			c.ExternalAPI.Upstreams[DefaultUpstream] = u
+		}, `upstream "default" base URL: "jsonplaceholder.typicode.com" is not an http or https URL`},
+		{"upstream retry delays", func(c *Config) {
+			u := c.ExternalAPI.Upstreams[DefaultUpstream]
+			u.RetryBaseDelay, u.RetryMaxDelay = 5000, 1000
+			c.ExternalAPI.Upstreams[DefaultUpstream] = u
+		}, `upstream "default": retry_max_delay must not be less than retry_base_delay, got 1000 and 5000`},
+		{"upstream retry status codes", func(c *Config) {
+			u := c.ExternalAPI.Upstreams[DefaultUpstream]
+			u.RetryStatusCodes = []int{503, 200}
+			c.ExternalAPI.Upstreams[DefaultUpstream] = u
+		}, `upstream "default": retry_status_codes: 200 is not an error status code`},
+		{"upstream client certificate", func(c *Config) {
+			u := c.ExternalAPI.Upstreams[DefaultUpstream]
+			u.TLSCertFile = "client.pem"
+			c.ExternalAPI.Upstreams[DefaultUpstream] = u
+		}, `upstream "default": tls_cert_file and tls_key_file must be set together`},
+		{"upstream proxy", func(c *Config) {
+			u := c.ExternalAPI.Upstreams[DefaultUpstream]
+			u.Proxy = "proxy.corp:3128"
+			c.ExternalAPI.Upstreams[DefaultUpstream] = u
+		}, `upstream "default" proxy: "proxy.corp:3128" is not an http or https URL`},
+		{"upstream host override", func(c *Config) {
+			u := c.ExternalAPI.Upstreams[DefaultUpstream]
+			u.Proxy, u.HostOverrides = ProxyDirect, map[string]string{"api.internal": "api-1"}
+			c.ExternalAPI.Upstreams[DefaultUpstream] = u
+		}, `upstream "default": host override for "api.internal": "api-1" is not an IP address`},
+		{"upstream pagination", func(c *Config) {
+			u := c.ExternalAPI.Upstreams[DefaultUpstream]
+			u.Pagination.Strategy = "offset"
+			c.ExternalAPI.Upstreams[DefaultUpstream] = u
+		}, `upstream "default": unsupported pagination strategy "offset"`},
+		{"upstream cursor pagination", func(c *Config) {
+			u := c.ExternalAPI.Upstreams[DefaultUpstream]
+			u.Pagination.Strategy = PaginateCursor
+			c.ExternalAPI.Upstreams[DefaultUpstream] = u
+		}, `upstream "default": cursor pagination requires pagination.items_field`},
+		{"mock external API", func(c *Config) { c.ExternalAPI.Mode = ExternalAPIMock }, ""},
+		{"mock external API in production", func(c *Config) {
+			c.Environment, c.Database.Password = "production", "s3cret"
+			c.ExternalAPI.Mode = ExternalAPIMock
+		}, "external_api.mode: mock must not be used in production"},
+		{"upstream insecure TLS in production", func(c *Config) {
+			c.Environment, c.Database.Password = "production", "s3cret"
+			u := c.ExternalAPI.Upstreams[DefaultUpstream]
+			u.TLSInsecureSkipVerify = true
+			c.ExternalAPI.Upstreams[DefaultUpstream] = u
+		}, `upstream "default": tls_insecure_skip_verify must not be set in production`},
+		{"proxy target", func(c *Config) {
+			c.Proxy.Routes = []ProxyRoute{{Name: "users", Prefix: "/users", Targets: []string{"ftp://users"}, Timeout: 30, Balancer: defaultBalancer()}}
+		}, `proxy route "users" target: "ftp://users" is not an http or https URL`},
+		{"cache TTL jitter", func(c *Config) { c.Cache.TTLJitter = 1 }, "cache.ttl_jitter: must be in [0, 1), got 1"},
+		{"debug log sample rate", func(c *Config) { c.DebugLog.SampleRate = 5 }, "debug_log.sample_rate: must be in [0, 1], got 5"},
+		{"max header bytes", func(c *Config) { c.HTTP.MaxHeaderBytes = 0 }, "http.max_header_bytes: must be positive, got 0"},
+		{"ip filter", func(c *Config) { c.IPFilter.Deny = []string{"10.0.0.0/33"} }, `ip_filter.deny: "10.0.0.0/33" is not an IP address or CIDR range`},
+		{"ip filter route", func(c *Config) {
+			c.IPFilter.Routes = []IPFilterRoute{{Prefix: "admin", Allow: []string{"10.0.0.1"}}}
+		}, `ip_filter route "admin": prefix must start with /`},
+		{"rbac permission", func(c *Config) {
+			c.RBAC.Enabled = true
+			c.RBAC.Roles["billing"] = []string{"billing:*"}
+		}, `rbac.roles.billing: unknown permission "billing:*"`},
+		{"rbac default role", func(c *Config) { c.RBAC.Enabled, c.RBAC.DefaultRoles = true, []string{"guest"} }, `rbac.default_roles: role "guest" is not defined`},
+		{"oidc audience", func(c *Config) {
+			c.Auth.OIDCEnabled, c.Auth.OIDCIssuer = true, "https://auth.example.com/realms/api"
+		}, "auth.oidc_audience: required for OIDC"},
+		{"signing client secret", func(c *Config) {
+			c.Auth.SigningEnabled = true
+			c.Auth.SigningClients = []SigningClient{{ID: "billing-worker"}}
+		}, `signing client "billing-worker": secret is required`},
+		{"signing client role", func(c *Config) {
+			c.RBAC.Enabled, c.Auth.SigningEnabled = true, true
+			c.Auth.SigningClients = []SigningClient{{ID: "billing-worker", Secret: "s", Roles: []string{"guest"}}}
+		}, `signing client "billing-worker": role "guest" is not defined`},
+		{"signing client tenant", func(c *Config) {
+			c.Auth.SigningEnabled = true
+			c.Auth.SigningClients = []SigningClient{{ID: "billing-worker", Secret: "s", Tenant: "Acme Corp"}}
+		}, `signing client "billing-worker": invalid tenant "Acme Corp"`},
+		{"tenancy jwt claim", func(c *Config) { c.Tenancy.Enabled, c.Tenancy.JWTClaim = true, "" }, "tenancy.jwt_claim: must be set"},
+		{"leader ttl", func(c *Config) { c.Jobs.LeaderTTL = 0 }, "jobs.leader_ttl: must be positive, got 0"},
+		{"ingest with atomic sync", func(c *Config) { c.Jobs.IngestStream, c.Jobs.SyncAtomic = true, true }, "jobs.ingest_stream: can't be combined with sync_atomic"},
+		{"item retry attempts", func(c *Config) { c.Jobs.ItemRetryMaxAttempts = 0 }, "jobs.item_retry_max_attempts: must be positive, got 0"},
+		{"outbox kafka url", func(c *Config) { c.Jobs.Outbox, c.Jobs.OutboxKafkaURL = true, "kafka:8082" }, `jobs.outbox_kafka_url: "kafka:8082" is not an http or https URL`},
+		{"kafka broker", func(c *Config) { c.Kafka.Brokers = []string{"kafka"} }, `kafka.brokers: "kafka" is not a host:port address`},
+		{"kafka topic", func(c *Config) { c.Kafka.Brokers, c.Kafka.Topic = []string{"kafka:9092"}, "" }, "kafka.topic: required with brokers"},
+		{"idempotency ttl", func(c *Config) { c.Idempotency.TTL = 0 }, "idempotency.ttl: must be positive, got 0"},
+		{"timeout", func(c *Config) { c.Webhooks.DeliveryTimeout = 0 }, "webhooks.delivery_timeout: must be positive, got 0"},
+		{"grpc port", func(c *Config) { c.GRPC.Enabled, c.GRPC.Port = true, c.Port }, `grpc.port: must differ from port "8080"`},
+		{"secret without provider", func(c *Config) { c.Database.PasswordRef = "db#password" }, "database.password_ref: requires secrets.provider"},
+		{"TLS without certificates", func(c *Config) { c.TLS.Enabled = true }, "tls: cert_file and key_file or autocert_domains are required"},
+		{"TLS redirect port", func(c *Config) {
+			c.TLS = TLSConfig{Enabled: true, AutocertDomains: []string{"api.example.com"}, AutocertCacheDir: "autocert", RedirectPort: c.Port}
+		}, "tls.redirect_port: must differ from the HTTPS and gRPC ports"},
+		{"vault without token", func(c *Config) { c.Secrets.Provider, c.Secrets.VaultAddr = SecretsVault, "http://vault:8200" }, "secrets.vault_token: required for vault"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaults()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.problem == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var verr *ValidationError
+			if assert.ErrorAs(t, err, &verr) && assert.Len(t, verr.Problems, 1) {
+				assert.Contains(t, verr.Problems[0], tt.problem)
+			}
+		})
+	}
+
+	// Every problem is reported at once
+	cfg := defaults()
+	cfg.Environment = "production"
+	cfg.Port = "http"
+	cfg.Jobs.SyncSchedule = "daily"
+	err := cfg.Validate()
+	var verr *ValidationError
+	if assert.ErrorAs(t, err, &verr) {
+		assert.Len(t, verr.Problems, 3)
+		assert.True(t, strings.HasPrefix(err.Error(), "3 problem(s): port: "), err.Error())
+	}
+}
+
+// recorder is a Subscriber remembering each configuration it was given
+type recorder struct {
+	mu      sync.Mutex
+	applied []*Config
+}
+
+func (r *recorder) ApplyConfig(cfg *Config) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.applied = append(r.applied, cfg)
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.applied)
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "log_level: info\ncache:\n  order_status_ttl: 60\n")
+	cfg, err := Load(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	w := NewWatcher(path, cfg, 0, logger.New())
+	var r recorder
+	w.Subscribe(&r)
+
+	assert.NoError(t, os.WriteFile(path, []byte("log_level: debug\ncache:\n  order_status_ttl: 5\n"), 0o600))
+	assert.NoError(t, w.Reload())
+	if assert.Equal(t, 1, r.count()) {
+		assert.Same(t, w.Current(), r.applied[0])
+		assert.Equal(t, "debug", r.applied[0].LogLevel)
+		assert.Equal(t, 5, r.applied[0].Cache.OrderStatusTTL)
+	}
+
+	// An invalid file keeps the current configuration
+	assert.NoError(t, os.WriteFile(path, []byte("log_level: verbose\n"), 0o600))
+	assert.Error(t, w.Reload())
+	assert.Equal(t, 1, r.count())
+	assert.Equal(t, "debug", w.Current().LogLevel)
+}
+
+func TestWatcher_Run(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "rate_limit:\n  burst: 10\n")
+	cfg, err := Load(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	w := NewWatcher(path, cfg, 10*time.Millisecond, logger.New())
+	var r recorder
+	w.Subscribe(&r)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, r.count(), "an unchanged file isn't reloaded")
+
+	assert.NoError(t, os.WriteFile(path, []byte("rate_limit:\n  burst: 250\n"), 0o600))
+	assert.Eventually(t, func() bool { return r.count() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 250, w.Current().RateLimit.Burst)
+}
+
+func TestRestartRequired(t *testing.T) {
+	prev := defaults()
+	prev.Database.PasswordRef = "db#password"
+	prev.Database.Password = "resolved"
+
+	next := defaults()
+	next.Database.PasswordRef = "db#password"
+	next.LogLevel = "debug"
+	next.RateLimit.Enabled = true
+	next.DebugLog.Enabled = true
+	next.Cache.ItemStatsTTL = 120
+	next.Jobs.SyncSchedule = "0 */5 * * * *"
+	next.Jobs.ItemRetrySchedule = "*/10 * * * * *"
+	next.Jobs.OutboxRelaySchedule = "*/2 * * * * *"
+	keepSecrets(next, prev)
+	assert.Equal(t, "resolved", next.Database.Password)
+	assert.Empty(t, restartRequired(prev, next))
+
+	next.Port = "9000"
+	next.Jobs.SyncEnabled = false
+	next.Cache.LocalSize = 10
+	assert.Equal(t, []string{"port", "jobs", "cache"}, restartRequired(prev, next))
+}
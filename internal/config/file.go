@@ -0,0 +1,115 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile overlays cfg with the YAML or JSON file at path, chosen by its
+// extension. Keys use the snake_case names in the yaml tags; settings the
+// file leaves out keep their current value, and unknown keys are an error
+// so typos don't go unnoticed.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+	case ".json":
+		// JSON is decoded through YAML, so both formats share one set of
+		// tags and the same unknown key checks
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		if data, err = yaml.Marshal(v); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q, expected .yaml, .yml or .json", ext)
+	}
+
+	if err := decodeStrict(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// decodeStrict decodes YAML into out, rejecting keys out doesn't have. An
+// empty document leaves out unchanged.
+func decodeStrict(data []byte, out interface{}) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// decodeNodeStrict decodes node into out like decodeStrict. Node.Decode
+// doesn't check for unknown keys, so the node is decoded from its YAML.
+func decodeNodeStrict(node *yaml.Node, out interface{}) error {
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	return decodeStrict(data, out)
+}
+
+// UnmarshalYAML decodes upstreams by name, starting each from the upstream
+// already configured under that name or else from the defaults, so a file
+// only needs to set what differs
+func (c *ExternalAPIConfig) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Upstreams map[string]yaml.Node `yaml:"upstreams"`
+	}
+	if err := decodeNodeStrict(value, &raw); err != nil {
+		return err
+	}
+
+	if c.Upstreams == nil {
+		c.Upstreams = make(map[string]UpstreamConfig)
+	}
+	for name, node := range raw.Upstreams {
+		upstream, ok := c.Upstreams[name]
+		if !ok {
+			upstream = defaultUpstream()
+		}
+		if err := decodeNodeStrict(&node, &upstream); err != nil {
+			return fmt.Errorf("upstream %q: %w", name, err)
+		}
+		c.Upstreams[name] = upstream
+	}
+	return nil
+}
+
+// UnmarshalYAML decodes proxy routes, starting each from the defaults so a
+// file only needs to set what differs
+func (c *ProxyConfig) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Routes []yaml.Node `yaml:"routes"`
+	}
+	if err := decodeNodeStrict(value, &raw); err != nil {
+		return err
+	}
+
+	c.Routes = nil
+	for i := range raw.Routes {
+		route := defaultProxyRoute("")
+		if err := decodeNodeStrict(&raw.Routes[i], &route); err != nil {
+			return fmt.Errorf("proxy route %d: %w", i+1, err)
+		}
+		c.Routes = append(c.Routes, route)
+	}
+	return nil
+}
@@ -0,0 +1,520 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"api-gateway-backend/internal/rbac"
+	"api-gateway-backend/internal/tenant"
+
+	"github.com/robfig/cron/v3"
+)
+
+// cronParser parses job schedules the way the job manager does, as cron
+// specs with a leading seconds field
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// defaultDBPassword is the development database password, which must be
+// replaced in production
+const defaultDBPassword = "apipassword"
+
+// ValidationError lists every problem Validate found, so they can all be
+// fixed at once
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d problem(s): %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// validator collects the problems found while validating
+type validator struct {
+	problems []string
+}
+
+// check records a problem unless ok
+func (v *validator) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.problems = append(v.problems, fmt.Sprintf(format, args...))
+	}
+}
+
+// positive records a problem unless value, in the setting called name, is
+// greater than zero
+func (v *validator) positive(name string, value int) {
+	v.check(value > 0, "%s: must be positive, got %d", name, value)
+}
+
+// nonNegative records a problem if value, in the setting called name, is
+// below zero
+func (v *validator) nonNegative(name string, value int) {
+	v.check(value >= 0, "%s: must not be negative, got %d", name, value)
+}
+
+// url records a problem unless raw, in the setting called name, is an
+// absolute http or https URL
+func (v *validator) url(name, raw string) {
+	u, err := url.Parse(raw)
+	v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+		"%s: %q is not an http or https URL", name, raw)
+}
+
+// addresses records a problem for each entry of the list called name that
+// is neither an IP address nor a CIDR range
+func (v *validator) addresses(name string, entries []string) {
+	for _, entry := range entries {
+		_, prefixErr := netip.ParsePrefix(entry)
+		_, addrErr := netip.ParseAddr(entry)
+		v.check(prefixErr == nil || addrErr == nil, "%s: %q is not an IP address or CIDR range", name, entry)
+	}
+}
+
+// rbac records a problem for each permission that isn't one a route
+// requires, and for default roles that aren't defined
+func (v *validator) rbac(c RBACConfig) {
+	for _, role := range sortedKeys(c.Roles) {
+		for _, perm := range c.Roles[role] {
+			v.check(rbac.ValidPattern(perm), "rbac.roles.%s: unknown permission %q", role, perm)
+		}
+	}
+	for _, role := range c.DefaultRoles {
+		_, ok := c.Roles[role]
+		v.check(ok, "rbac.default_roles: role %q is not defined", role)
+	}
+	v.check(c.JWTClaim != "", "rbac.jwt_claim: must be set")
+}
+
+// signing records the problems with the signing clients of a, whose roles
+// must be defined in r when RBAC is enabled
+func (v *validator) signing(a AuthConfig, r RBACConfig) {
+	v.positive("auth.signing_tolerance", a.SigningTolerance)
+	v.check(len(a.SigningClients) > 0, "auth.signing_clients: at least one client is required")
+	seen := make(map[string]bool, len(a.SigningClients))
+	for _, client := range a.SigningClients {
+		what := fmt.Sprintf("signing client %q", client.ID)
+		v.check(client.ID != "", "auth.signing_clients: every client needs an id")
+		v.check(!seen[client.ID], "%s: defined more than once", what)
+		seen[client.ID] = true
+		v.check(client.Secret != "", "%s: secret is required", what)
+		v.check(client.Tenant == "" || tenant.Valid(client.Tenant), "%s: invalid tenant %q", what, client.Tenant)
+		if r.Enabled {
+			for _, role := range client.Roles {
+				_, ok := r.Roles[role]
+				v.check(ok, "%s: role %q is not defined", what, role)
+			}
+		}
+	}
+}
+
+// schedule records a problem unless spec, in the setting called name, is a
+// cron spec the job manager accepts
+func (v *validator) schedule(name, spec string) {
+	_, err := cronParser.Parse(spec)
+	v.check(err == nil, "%s: invalid cron schedule %q: %v", name, spec, err)
+}
+
+// Validate reports every setting that is missing, out of range or not one
+// of its supported values, so a bad file or variable fails at startup
+// rather than when the setting is first used. The error is a
+// *ValidationError.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.check(validPort(c.Port), "port: %q is not a valid port", c.Port)
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		v.check(false, "log_level: unsupported level %q, expected debug, info, warn or error", c.LogLevel)
+	}
+	v.nonNegative("reload_interval", c.ReloadInterval)
+
+	v.check(c.Database.Driver == "mysql" || c.Database.Driver == "postgres",
+		"database.driver: unsupported driver %q, expected mysql or postgres", c.Database.Driver)
+	if c.Environment == "production" {
+		v.check(c.Database.Password != "" && c.Database.Password != defaultDBPassword,
+			"database.password: must be set to a non-default password in production")
+	}
+	v.nonNegative("database.slow_query_threshold", c.Database.SlowQueryThreshold)
+
+	switch c.Redis.Mode {
+	case "standalone":
+	case "sentinel":
+		v.check(c.Redis.MasterName != "" && len(c.Redis.Addrs) > 0, "redis: sentinel mode requires master_name and addrs")
+	case "cluster":
+		v.check(len(c.Redis.Addrs) > 0, "redis: cluster mode requires addrs")
+	default:
+		v.check(false, "redis.mode: unsupported mode %q, expected standalone, sentinel or cluster", c.Redis.Mode)
+	}
+	v.nonNegative("redis.scan_batch_size", c.Redis.ScanBatchSize)
+
+	v.check(c.ExternalAPI.Mode == ExternalAPILive || c.ExternalAPI.Mode == ExternalAPIMock,
+		"external_api.mode: unsupported mode %q", c.ExternalAPI.Mode)
+	v.check(c.ExternalAPI.Mode != ExternalAPIMock || c.Environment != "production",
+		"external_api.mode: mock must not be used in production")
+	_, ok := c.ExternalAPI.Upstreams[DefaultUpstream]
+	v.check(ok, "external_api.upstreams: the %q upstream is required", DefaultUpstream)
+	for _, name := range sortedKeys(c.ExternalAPI.Upstreams) {
+		u := c.ExternalAPI.Upstreams[name]
+		v.upstream(name, u)
+		if c.Environment == "production" {
+			v.check(!u.TLSInsecureSkipVerify, "upstream %q: tls_insecure_skip_verify must not be set in production", name)
+		}
+	}
+
+	v.nonNegative("http.max_body_size", c.HTTP.MaxBodySize)
+	v.positive("http.max_header_bytes", c.HTTP.MaxHeaderBytes)
+	v.nonNegative("http.request_timeout", c.HTTP.RequestTimeout)
+	f := c.IPFilter
+	v.addresses("ip_filter.trusted_proxies", f.TrustedProxies)
+	v.addresses("ip_filter.allow", f.Allow)
+	v.addresses("ip_filter.deny", f.Deny)
+	for _, route := range f.Routes {
+		what := fmt.Sprintf("ip_filter route %q", route.Prefix)
+		v.check(strings.HasPrefix(route.Prefix, "/"), "%s: prefix must start with /", what)
+		v.addresses(what+" allow", route.Allow)
+		v.addresses(what+" deny", route.Deny)
+	}
+
+	v.nonNegative("concurrency.queue_timeout_ms", c.Concurrency.QueueTimeout)
+	v.nonNegative("concurrency.retry_after", c.Concurrency.RetryAfter)
+
+	if c.Auth.JWTEnabled {
+		switch c.Auth.JWTAlgorithm {
+		case "HS256":
+			v.check(c.Auth.JWTSecret != "", "auth.jwt_secret: required for HS256")
+		case "RS256":
+			v.check(c.Auth.JWTPublicKeyFile != "", "auth.jwt_public_key_file: required for RS256")
+		default:
+			v.check(false, "auth.jwt_algorithm: unsupported algorithm %q, expected HS256 or RS256", c.Auth.JWTAlgorithm)
+		}
+	}
+	if c.Auth.OIDCEnabled {
+		v.check(!c.Auth.JWTEnabled, "auth.oidc_enabled: can't be combined with jwt_enabled, tokens are verified one way")
+		v.url("auth.oidc_issuer", c.Auth.OIDCIssuer)
+		v.check(c.Auth.OIDCAudience != "", "auth.oidc_audience: required for OIDC")
+		v.positive("auth.oidc_jwks_cache_ttl", c.Auth.OIDCJWKSCacheTTL)
+	}
+	v.nonNegative("auth.api_key_cache_ttl", c.Auth.APIKeyCacheTTL)
+	if c.Auth.SigningEnabled {
+		v.signing(c.Auth, c.RBAC)
+	}
+
+	if c.RBAC.Enabled {
+		v.rbac(c.RBAC)
+	}
+	if c.Tenancy.Enabled {
+		v.check(c.Tenancy.JWTClaim != "", "tenancy.jwt_claim: must be set")
+	}
+
+	if c.RateLimit.Enabled {
+		v.positive("rate_limit.requests_per_minute", c.RateLimit.RequestsPerMinute)
+		v.positive("rate_limit.burst", c.RateLimit.Burst)
+	}
+
+	v.positive("health.sync_stale_after", c.Health.SyncStaleAfter)
+	v.nonNegative("health.check_cache_ttl", c.Health.CheckCacheTTL)
+	v.positive("health.check_timeout", c.Health.CheckTimeout)
+	v.nonNegative("health.check_interval", c.Health.CheckInterval)
+	v.positive("health.history_size", c.Health.HistorySize)
+	v.positive("health.down_after", c.Health.DownAfter)
+
+	j := c.Jobs
+	v.nonNegative("jobs.sync_lock_ttl", j.SyncLockTTL)
+	v.nonNegative("jobs.sync_batch_size", j.SyncBatchSize)
+	v.nonNegative("jobs.sync_workers", j.SyncWorkers)
+	v.nonNegative("jobs.sync_max_errors", j.SyncMaxErrors)
+	if j.IngestStream {
+		v.check(!j.SyncAtomic, "jobs.ingest_stream: can't be combined with sync_atomic")
+		v.positive("jobs.ingest_workers", j.IngestWorkers)
+		v.positive("jobs.ingest_batch_size", j.IngestBatchSize)
+		v.positive("jobs.ingest_max_backlog", j.IngestMaxBacklog)
+	}
+	if j.SyncEnabled {
+		v.schedule("jobs.sync_schedule", j.SyncSchedule)
+	}
+	if j.AnalyticsEnabled {
+		v.schedule("jobs.analytics_schedule", j.AnalyticsSchedule)
+	}
+	if j.OrderStatsEnabled {
+		v.schedule("jobs.order_stats_schedule", j.OrderStatsSchedule)
+		v.positive("jobs.order_stats_max_age", j.OrderStatsMaxAge)
+	}
+	if j.ResourceSyncEnabled && j.ResourceSyncSchedule != "" {
+		v.schedule("jobs.resource_sync_schedule", j.ResourceSyncSchedule)
+		v.positive("jobs.resource_sync_concurrency", j.ResourceSyncConcurrency)
+	} else if j.ResourceSyncEnabled {
+		v.schedule("jobs.users_sync_schedule", j.UsersSyncSchedule)
+		v.schedule("jobs.comments_sync_schedule", j.CommentsSyncSchedule)
+		v.schedule("jobs.todos_sync_schedule", j.TodosSyncSchedule)
+	}
+	if j.WebhookDeliveryEnabled {
+		v.schedule("jobs.webhook_delivery_schedule", j.WebhookDeliverySchedule)
+	}
+	if j.ItemRetryEnabled {
+		v.schedule("jobs.item_retry_schedule", j.ItemRetrySchedule)
+		v.positive("jobs.item_retry_max_attempts", j.ItemRetryMaxAttempts)
+		v.positive("jobs.item_retry_delay", j.ItemRetryDelay)
+	}
+	if j.Outbox {
+		v.schedule("jobs.outbox_relay_schedule", j.OutboxRelaySchedule)
+		v.positive("jobs.outbox_batch_size", j.OutboxBatchSize)
+		if j.OutboxKafkaURL != "" {
+			v.url("jobs.outbox_kafka_url", j.OutboxKafkaURL)
+			v.positive("jobs.outbox_kafka_timeout", j.OutboxKafkaTimeout)
+		}
+	}
+	if j.LeaderElection {
+		v.positive("jobs.leader_ttl", j.LeaderTTL)
+	}
+
+	if c.TLS.Enabled {
+		v.tls(c)
+	}
+
+	if c.GRPC.Enabled {
+		v.check(validPort(c.GRPC.Port), "grpc.port: %q is not a valid port", c.GRPC.Port)
+		v.check(c.GRPC.Port != c.Port, "grpc.port: must differ from port %q", c.Port)
+	}
+	v.nonNegative("graphql.max_depth", c.GraphQL.MaxDepth)
+	if c.WebSocket.Enabled {
+		v.nonNegative("websocket.max_connections", c.WebSocket.MaxConnections)
+		v.nonNegative("websocket.heartbeat_interval", c.WebSocket.HeartbeatInterval)
+	}
+	if c.Idempotency.Enabled {
+		v.positive("idempotency.ttl", c.Idempotency.TTL)
+	}
+
+	v.nonNegative("cache.local_size", c.Cache.LocalSize)
+	v.nonNegative("cache.order_status_ttl", c.Cache.OrderStatusTTL)
+	v.nonNegative("cache.top_customers_ttl", c.Cache.TopCustomersTTL)
+	v.nonNegative("cache.item_stats_ttl", c.Cache.ItemStatsTTL)
+	v.nonNegative("cache.negative_ttl", c.Cache.NegativeTTL)
+	v.check(c.Cache.TTLJitter >= 0 && c.Cache.TTLJitter < 1, "cache.ttl_jitter: must be in [0, 1), got %v", c.Cache.TTLJitter)
+
+	v.nonNegative("compression.min_size", c.Compression.MinSize)
+	v.check(c.Compression.Level == -1 || (c.Compression.Level >= 1 && c.Compression.Level <= 9),
+		"compression.level: %d is not -1 or 1-9", c.Compression.Level)
+
+	names := make(map[string]bool)
+	for i, route := range c.Proxy.Routes {
+		v.check(route.Name != "", "proxy.routes[%d]: name is required", i)
+		v.check(!names[route.Name], "proxy route %q: defined more than once", route.Name)
+		names[route.Name] = true
+
+		what := fmt.Sprintf("proxy route %q", route.Name)
+		v.check(strings.HasPrefix(route.Prefix, "/"), "%s: prefix %q must start with /", what, route.Prefix)
+		v.check(len(route.Targets) > 0, "%s: at least one target is required", what)
+		for _, target := range route.Targets {
+			v.url(what+" target", target)
+		}
+		v.positive(what+" timeout", route.Timeout)
+		v.nonNegative(what+" max_retries", route.MaxRetries)
+		v.balancer(what, route.Balancer)
+		v.throttle(what, route.RateLimit)
+	}
+
+	v.secrets(c)
+
+	if er := c.ErrorReporting; er.DSN != "" {
+		v.url("error_reporting.dsn", er.DSN)
+		v.check(er.SampleRate > 0 && er.SampleRate <= 1, "error_reporting.sample_rate: must be in (0, 1], got %v", er.SampleRate)
+	}
+
+	if dl := c.DebugLog; dl.Enabled || dl.HeaderEnabled {
+		v.check(dl.SampleRate >= 0 && dl.SampleRate <= 1, "debug_log.sample_rate: must be in [0, 1], got %v", dl.SampleRate)
+		v.positive("debug_log.max_body_size", dl.MaxBodySize)
+	}
+
+	w := c.Webhooks
+	v.positive("webhooks.tolerance", w.Tolerance)
+	v.positive("webhooks.delivery_timeout", w.DeliveryTimeout)
+	v.positive("webhooks.delivery_max_attempts", w.DeliveryMaxAttempts)
+	v.nonNegative("webhooks.delivery_retry_delay", w.DeliveryRetryDelay)
+	v.nonNegative("webhooks.delivery_batch_size", w.DeliveryBatchSize)
+
+	if k := c.Kafka; len(k.Brokers) > 0 {
+		for _, broker := range k.Brokers {
+			_, port, err := net.SplitHostPort(broker)
+			v.check(err == nil && validPort(port), "kafka.brokers: %q is not a host:port address", broker)
+		}
+		v.check(k.Topic != "", "kafka.topic: required with brokers")
+		v.positive("kafka.write_timeout", k.WriteTimeout)
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// secrets records the problems with the secrets provider and the secret
+// references in c
+func (v *validator) secrets(c *Config) {
+	s := c.Secrets
+	switch s.Provider {
+	case SecretsNone:
+	case SecretsVault:
+		v.url("secrets.vault_addr", s.VaultAddr)
+		v.check(s.VaultToken != "", "secrets.vault_token: required for vault")
+		v.check(s.VaultMount != "", "secrets.vault_mount: required for vault")
+	case SecretsAWS:
+		v.check(s.AWSRegion != "", "secrets.aws_region: required for aws")
+		v.check(s.AWSAccessKeyID != "" && s.AWSSecretAccessKey != "", "secrets: aws requires aws_access_key_id and aws_secret_access_key")
+		if s.AWSEndpoint != "" {
+			v.url("secrets.aws_endpoint", s.AWSEndpoint)
+		}
+	default:
+		v.check(false, "secrets.provider: unsupported provider %q, expected vault or aws", s.Provider)
+	}
+	v.nonNegative("secrets.refresh_interval", s.RefreshInterval)
+
+	refs := map[string]string{
+		"database.password_ref": c.Database.PasswordRef,
+		"redis.password_ref":    c.Redis.PasswordRef,
+	}
+	for name, u := range c.ExternalAPI.Upstreams {
+		what := fmt.Sprintf("upstream %q ", name)
+		refs[what+"auth_token_ref"] = u.AuthTokenRef
+		refs[what+"auth_password_ref"] = u.AuthPasswordRef
+		refs[what+"oauth_client_secret_ref"] = u.OAuthClientSecretRef
+	}
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ref := refs[name]
+		if ref == "" {
+			continue
+		}
+		v.check(s.Provider != SecretsNone, "%s: requires secrets.provider", name)
+		v.check(!strings.HasPrefix(ref, "#"), "%s: %q has no secret path before #", name, ref)
+	}
+}
+
+// upstream records the problems with the upstream called name
+func (v *validator) upstream(name string, u UpstreamConfig) {
+	what := fmt.Sprintf("upstream %q", name)
+	v.check(len(u.BaseURLs) > 0, "%s: at least one base URL is required", what)
+	for _, baseURL := range u.BaseURLs {
+		v.url(what+" base URL", baseURL)
+	}
+	v.positive(what+" timeout", u.Timeout)
+	v.nonNegative(what+" max_retries", u.MaxRetries)
+	v.nonNegative(what+" max_retry_duration", u.MaxRetryDuration)
+	if u.MaxRetries > 0 {
+		v.positive(what+" retry_base_delay", u.RetryBaseDelay)
+		v.nonNegative(what+" retry_max_delay", u.RetryMaxDelay)
+		v.check(u.RetryMaxDelay == 0 || u.RetryMaxDelay >= u.RetryBaseDelay,
+			"%s: retry_max_delay must not be less than retry_base_delay, got %d and %d", what, u.RetryMaxDelay, u.RetryBaseDelay)
+		for _, code := range u.RetryStatusCodes {
+			v.check(code >= 400 && code <= 599, "%s: retry_status_codes: %d is not an error status code", what, code)
+		}
+	}
+	v.nonNegative(what+" slow_call_threshold", u.SlowCallThreshold)
+	v.nonNegative(what+" cache_ttl", u.CacheTTL)
+	v.nonNegative(what+" cache_stale_ttl", u.CacheStaleTTL)
+
+	switch u.AuthType {
+	case AuthNone, AuthBearer, AuthHeader, AuthBasic:
+	case AuthOAuth2:
+		v.check(u.OAuthTokenURL != "" && u.OAuthClientID != "", "%s: oauth2 requires a token URL and client ID", what)
+		if u.OAuthTokenURL != "" {
+			v.url(what+" token URL", u.OAuthTokenURL)
+		}
+	default:
+		v.check(false, "%s: unsupported auth type %q", what, u.AuthType)
+	}
+	v.check((u.TLSCertFile == "") == (u.TLSKeyFile == ""), "%s: tls_cert_file and tls_key_file must be set together", what)
+	if u.Proxy != "" && u.Proxy != ProxyDirect {
+		v.url(what+" proxy", u.Proxy)
+	}
+	for host, ip := range u.HostOverrides {
+		_, err := netip.ParseAddr(ip)
+		v.check(err == nil, "%s: host override for %q: %q is not an IP address", what, host, ip)
+	}
+	v.balancer(what, u.Balancer)
+	v.throttle(what, u.RateLimit)
+	v.pagination(what, u.Pagination)
+}
+
+// pagination records problems with how an upstream's pages are fetched
+func (v *validator) pagination(what string, p PaginationConfig) {
+	switch p.Strategy {
+	case PaginateNone:
+		return
+	case PaginatePage:
+		v.check(p.PageParam != "" && p.LimitParam != "", "%s: page pagination requires pagination.page_param and pagination.limit_param", what)
+		v.check(p.PageSize > 0, "%s: pagination.page_size must be positive, got %d", what, p.PageSize)
+	case PaginateLink:
+	case PaginateCursor:
+		v.check(p.CursorParam != "" && p.CursorField != "", "%s: cursor pagination requires pagination.cursor_param and pagination.cursor_field", what)
+		v.check(p.ItemsField != "", "%s: cursor pagination requires pagination.items_field", what)
+	default:
+		v.check(false, "%s: unsupported pagination strategy %q", what, p.Strategy)
+		return
+	}
+	v.check(p.MaxPages > 0, "%s: pagination.max_pages must be positive, got %d", what, p.MaxPages)
+}
+
+// balancer records the problems with the balancer of what
+func (v *validator) balancer(what string, b BalancerConfig) {
+	v.check(b.Policy == BalanceRoundRobin || b.Policy == BalanceLeastConnections,
+		"%s: unsupported balance policy %q", what, b.Policy)
+	v.check(b.EjectAfter >= 0 && b.EjectDuration >= 0, "%s: eject settings must not be negative", what)
+}
+
+// throttle records problems with a client-side rate limit
+func (v *validator) throttle(what string, t ThrottleConfig) {
+	v.check(t.RequestsPerSecond >= 0, "%s: rate_limit.requests_per_second must not be negative, got %v", what, t.RequestsPerSecond)
+	if t.RequestsPerSecond > 0 {
+		v.check(t.Burst > 0, "%s: rate_limit.burst must be positive, got %d", what, t.Burst)
+		v.check(t.MaxWait >= 0, "%s: rate_limit.max_wait must not be negative, got %d", what, t.MaxWait)
+	}
+}
+
+// tls records problems with the certificate source and redirect listener
+// of an enabled TLS config
+func (v *validator) tls(c *Config) {
+	t := c.TLS
+	files := t.CertFile != "" || t.KeyFile != ""
+	switch {
+	case files && len(t.AutocertDomains) > 0:
+		v.check(false, "tls: set either cert_file and key_file or autocert_domains, not both")
+	case files:
+		v.check(t.CertFile != "" && t.KeyFile != "", "tls: cert_file and key_file must be set together")
+	case len(t.AutocertDomains) > 0:
+		v.check(t.AutocertCacheDir != "", "tls.autocert_cache_dir: required for autocert")
+	default:
+		v.check(false, "tls: cert_file and key_file or autocert_domains are required")
+	}
+
+	if t.RedirectPort != "" {
+		v.check(validPort(t.RedirectPort), "tls.redirect_port: %q is not a valid port", t.RedirectPort)
+		v.check(t.RedirectPort != c.Port && !(c.GRPC.Enabled && t.RedirectPort == c.GRPC.Port),
+			"tls.redirect_port: must differ from the HTTPS and gRPC ports")
+	}
+}
+
+// validPort reports whether port is a TCP port number
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+// sortedKeys returns the keys of m in order, for stable error messages
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"api-gateway-backend/internal/logger"
+)
+
+// Subscriber is told about each configuration a reload produces. Only the
+// settings listed in Reloadable take effect at runtime; a subscriber reads
+// those it uses and ignores the rest. cfg must not be modified.
+type Subscriber interface {
+	ApplyConfig(cfg *Config)
+}
+
+// SubscriberFunc adapts a function to a Subscriber
+type SubscriberFunc func(cfg *Config)
+
+// ApplyConfig calls f(cfg)
+func (f SubscriberFunc) ApplyConfig(cfg *Config) {
+	f(cfg)
+}
+
+// Reloadable lists the settings applied without a restart. Changes to any
+// other setting are logged and wait for the next restart.
+var Reloadable = []string{
+	"log_level",
+	"cache.order_status_ttl",
+	"cache.top_customers_ttl",
+	"cache.item_stats_ttl",
+	"cache.negative_ttl",
+	"rate_limit",
+	"debug_log",
+	"ip_filter.allow",
+	"ip_filter.deny",
+	"ip_filter.routes",
+	"jobs.*_schedule",
+}
+
+// Watcher reloads the config file when it changes and passes the new
+// configuration to its subscribers. A file that fails to load or validate
+// is logged and the current configuration kept.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	log      *logger.Logger
+
+	mu          sync.Mutex
+	current     *Config
+	modTime     time.Time
+	size        int64
+	subscribers []Subscriber
+}
+
+// NewWatcher creates a watcher for the file at path, checking it every
+// interval, which current was loaded from
+func NewWatcher(path string, current *Config, interval time.Duration, log *logger.Logger) *Watcher {
+	w := &Watcher{
+		path:     path,
+		interval: interval,
+		log:      log,
+		current:  current,
+	}
+	w.modTime, w.size = w.stat()
+	return w
+}
+
+// Subscribe adds s to the subscribers notified after each reload
+func (w *Watcher) Subscribe(s Subscriber) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, s)
+}
+
+// Current returns the configuration last loaded
+func (w *Watcher) Current() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Run checks the file every interval until ctx is done, reloading it when
+// its modification time or size changes. It returns straight away if
+// there is no file or polling is disabled.
+func (w *Watcher) Run(ctx context.Context) {
+	if w.path == "" || w.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			modTime, size := w.stat()
+			w.mu.Lock()
+			changed := !modTime.Equal(w.modTime) || size != w.size
+			w.modTime, w.size = modTime, size
+			w.mu.Unlock()
+
+			if changed {
+				w.Reload()
+			}
+		}
+	}
+}
+
+// Reload loads the configuration again and, if it is valid, makes it
+// current and notifies the subscribers. Environment variables still win
+// over the file, as at startup.
+func (w *Watcher) Reload() error {
+	if w.path == "" {
+		return nil
+	}
+
+	next, err := Load(w.path)
+	if err != nil {
+		w.log.WithError(err).WithField("file", w.path).Error("Failed to reload configuration, keeping the current one")
+		return err
+	}
+
+	w.mu.Lock()
+	prev := w.current
+	keepSecrets(next, prev)
+	w.current = next
+	subscribers := append([]Subscriber(nil), w.subscribers...)
+	w.mu.Unlock()
+
+	if sections := restartRequired(prev, next); len(sections) > 0 {
+		w.log.WithField("sections", strings.Join(sections, ", ")).Warn("Changed settings take effect after a restart")
+	}
+	w.log.WithField("file", w.path).Info("Configuration reloaded")
+
+	for _, s := range subscribers {
+		s.ApplyConfig(next)
+	}
+	return nil
+}
+
+// stat returns the file's modification time and size, zero if it can't be
+// read
+func (w *Watcher) stat() (time.Time, int64) {
+	if w.path == "" {
+		return time.Time{}, 0
+	}
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
+
+// keepSecrets copies the credentials resolved from the secrets manager
+// into next wherever it references the same secret as prev, since Load
+// leaves them unresolved
+func keepSecrets(next, prev *Config) {
+	keep := func(ref, prevRef string, dest *string, value string) {
+		if ref != "" && ref == prevRef {
+			*dest = value
+		}
+	}
+
+	keep(next.Database.PasswordRef, prev.Database.PasswordRef, &next.Database.Password, prev.Database.Password)
+	keep(next.Redis.PasswordRef, prev.Redis.PasswordRef, &next.Redis.Password, prev.Redis.Password)
+	for name, u := range next.ExternalAPI.Upstreams {
+		p, ok := prev.ExternalAPI.Upstreams[name]
+		if !ok {
+			continue
+		}
+		keep(u.AuthTokenRef, p.AuthTokenRef, &u.AuthToken, p.AuthToken)
+		keep(u.AuthPasswordRef, p.AuthPasswordRef, &u.AuthPassword, p.AuthPassword)
+		keep(u.OAuthClientSecretRef, p.OAuthClientSecretRef, &u.OAuthClientSecret, p.OAuthClientSecret)
+		next.ExternalAPI.Upstreams[name] = u
+	}
+}
+
+// restartRequired returns the top-level sections of next that differ from
+// prev in a setting that isn't Reloadable
+func restartRequired(prev, next *Config) []string {
+	// Compare next with its reloadable settings taken from prev
+	fixed := *next
+	fixed.LogLevel = prev.LogLevel
+	fixed.Cache.OrderStatusTTL = prev.Cache.OrderStatusTTL
+	fixed.Cache.TopCustomersTTL = prev.Cache.TopCustomersTTL
+	fixed.Cache.ItemStatsTTL = prev.Cache.ItemStatsTTL
+	fixed.Cache.NegativeTTL = prev.Cache.NegativeTTL
+	fixed.RateLimit = prev.RateLimit
+	fixed.DebugLog = prev.DebugLog
+	fixed.IPFilter.Allow = prev.IPFilter.Allow
+	fixed.IPFilter.Deny = prev.IPFilter.Deny
+	fixed.IPFilter.Routes = prev.IPFilter.Routes
+	fixed.Jobs.SyncSchedule = prev.Jobs.SyncSchedule
+	fixed.Jobs.AnalyticsSchedule = prev.Jobs.AnalyticsSchedule
+	fixed.Jobs.OrderStatsSchedule = prev.Jobs.OrderStatsSchedule
+	fixed.Jobs.UsersSyncSchedule = prev.Jobs.UsersSyncSchedule
+	fixed.Jobs.CommentsSyncSchedule = prev.Jobs.CommentsSyncSchedule
+	fixed.Jobs.TodosSyncSchedule = prev.Jobs.TodosSyncSchedule
+	fixed.Jobs.WebhookDeliverySchedule = prev.Jobs.WebhookDeliverySchedule
+	fixed.Jobs.ItemRetrySchedule = prev.Jobs.ItemRetrySchedule
+	fixed.Jobs.OutboxRelaySchedule = prev.Jobs.OutboxRelaySchedule
+	// Switching between separate and combined resource syncs registers
+	// different jobs, so only a combined schedule can be changed in place
+	if prev.Jobs.ResourceSyncSchedule != "" && next.Jobs.ResourceSyncSchedule != "" {
+		fixed.Jobs.ResourceSyncSchedule = prev.Jobs.ResourceSyncSchedule
+	}
+
+	var sections []string
+	a, b := reflect.ValueOf(*prev), reflect.ValueOf(fixed)
+	for i := 0; i < a.NumField(); i++ {
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			name, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("yaml"), ",")
+			sections = append(sections, name)
+		}
+	}
+	return sections
+}
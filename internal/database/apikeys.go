@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// APIKey represents an issued API key. Only the SHA-256 hash of the key is
+// stored; the plaintext is returned once at creation.
+type APIKey struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	KeyPrefix string     `json:"key_prefix"`
+	KeyHash   string     `json:"-"`
+	Roles     []string   `json:"roles,omitempty"` // empty means the default roles
+	TenantID  string     `json:"tenant_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKey inserts a new API key and sets its ID and CreatedAt
+func (db *DB) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	key.CreatedAt = time.Now()
+
+	query := `INSERT INTO api_keys (name, key_prefix, key_hash, roles, tenant_id, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+	id, err := db.insert(ctx, query, key.Name, key.KeyPrefix, key.KeyHash, strings.Join(key.Roles, ","), key.TenantID, key.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	key.ID = id
+	return nil
+}
+
+// GetAPIKeyByHash retrieves an active (non-revoked) key by its hash,
+// returning ErrNotFound if no such key exists
+func (db *DB) GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error) {
+	query := `SELECT id, name, key_prefix, key_hash, roles, tenant_id, created_at, revoked_at FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL`
+
+	key, err := scanAPIKey(db.QueryRowContext(ctx, query, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return key, err
+}
+
+// ListAPIKeys retrieves the API keys of tenantID, or of every tenant if it
+// is empty, including revoked ones
+func (db *DB) ListAPIKeys(ctx context.Context, tenantID string) ([]APIKey, error) {
+	query := `SELECT id, name, key_prefix, key_hash, roles, tenant_id, created_at, revoked_at FROM api_keys`
+	var args []interface{}
+	if tenantID != "" {
+		query += ` WHERE tenant_id = ?`
+		args = append(args, tenantID)
+	}
+	query += ` ORDER BY created_at DESC`
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey marks a key of tenantID, or of any tenant if it is empty, as
+// revoked and returns it, or ErrNotFound if no such active key has that ID
+func (db *DB) RevokeAPIKey(ctx context.Context, id int64, tenantID string) (*APIKey, error) {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = ? AND revoked_at IS NULL`
+	args := []interface{}{id}
+	if tenantID != "" {
+		query += ` AND tenant_id = ?`
+		args = append(args, tenantID)
+	}
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrNotFound
+	}
+
+	query = `SELECT id, name, key_prefix, key_hash, roles, tenant_id, created_at, revoked_at FROM api_keys WHERE id = ?`
+	return scanAPIKey(db.QueryRowContext(ctx, query, id))
+}
+
+// scanAPIKey scans a single api_keys row
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*APIKey, error) {
+	var key APIKey
+	var roles string
+	var revokedAt sql.NullTime
+	if err := row.Scan(&key.ID, &key.Name, &key.KeyPrefix, &key.KeyHash, &roles, &key.TenantID, &key.CreatedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	if roles != "" {
+		key.Roles = strings.Split(roles, ",")
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
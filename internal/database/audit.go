@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// AuditEvent records one mutating action: who did what to which target,
+// and which fields it changed
+type AuditEvent struct {
+	ID        int64     `json:"id"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	RequestID string    `json:"request_id"`
+	Target    string    `json:"target,omitempty"`
+	Changes   Changes   `json:"changes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Change is the old and new value of one field
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Changes maps a field name to how it changed
+type Changes map[string]Change
+
+// AuditFilter narrows an audit log query. Zero values mean no filter.
+type AuditFilter struct {
+	Action string
+	Actor  string
+	Target string
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Offset int
+}
+
+// CreateAuditEvent inserts an audit event and sets its ID, and CreatedAt
+// if it's zero
+func (db *DB) CreateAuditEvent(ctx context.Context, event *AuditEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	changes, err := json.Marshal(event.Changes)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO audit_events (action, actor, request_id, target, changes, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+	id, err := db.insert(ctx, query, event.Action, event.Actor, event.RequestID, event.Target, string(changes), event.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	event.ID = id
+	return nil
+}
+
+// ListAuditEvents retrieves audit events matching the filter, newest first
+func (db *DB) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	query := `SELECT id, action, actor, request_id, target, changes, created_at FROM audit_events`
+
+	var conditions []string
+	var args []interface{}
+	if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	if filter.Target != "" {
+		conditions = append(conditions, "target = ?")
+		args = append(args, filter.Target)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, *filter.To)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []AuditEvent
+	for rows.Next() {
+		var event AuditEvent
+		var changes sql.NullString
+		if err := rows.Scan(&event.ID, &event.Action, &event.Actor, &event.RequestID, &event.Target,
+			&changes, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		if changes.Valid && changes.String != "" && changes.String != "null" {
+			if err := json.Unmarshal([]byte(changes.String), &event.Changes); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
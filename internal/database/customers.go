@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"api-gateway-backend/internal/tenant"
+)
+
+// groupByMonth groups a customer's spend by calendar month
+const groupByMonth = "month"
+
+// CustomerDetail summarizes the orders of one customer
+type CustomerDetail struct {
+	CustomerID        string               `json:"customer_id"`
+	TotalSpend        float64              `json:"total_spend"`
+	OrderCount        int                  `json:"order_count"`
+	AverageOrderValue float64              `json:"average_order_value"`
+	FirstOrderAt      time.Time            `json:"first_order_at"`
+	LastOrderAt       time.Time            `json:"last_order_at"`
+	ByStatus          []OrderStatusSummary `json:"by_status"`
+	Monthly           []MonthlySpend       `json:"monthly"`
+}
+
+// MonthlySpend is a customer's spend in one calendar month
+type MonthlySpend struct {
+	Month      time.Time `json:"month"`
+	OrderCount int       `json:"order_count"`
+	TotalSpend float64   `json:"total_spend"`
+}
+
+// GetCustomerDetail returns the lifetime totals of a customer's orders, by
+// status, and their spend per calendar month since since. Months without
+// orders are left out. ErrNotFound is returned if the customer has no
+// orders.
+func (db *DB) GetCustomerDetail(ctx context.Context, customerID string, since time.Time) (*CustomerDetail, error) {
+	id := tenant.From(ctx)
+	detail := &CustomerDetail{CustomerID: customerID, ByStatus: []OrderStatusSummary{}, Monthly: []MonthlySpend{}}
+
+	query := `
+		SELECT COUNT(*) as order_count, COALESCE(SUM(amount), 0) as total_spend, MIN(created_at), MAX(created_at)
+		FROM orders
+		WHERE tenant_id = ? AND customer_id = ?
+	`
+	var first, last sql.NullTime
+	err := db.QueryRowContext(ctx, query, id, customerID).Scan(&detail.OrderCount, &detail.TotalSpend, &first, &last)
+	if err != nil {
+		return nil, err
+	}
+	if detail.OrderCount == 0 {
+		return nil, ErrNotFound
+	}
+	detail.FirstOrderAt, detail.LastOrderAt = first.Time, last.Time
+	detail.AverageOrderValue = detail.TotalSpend / float64(detail.OrderCount)
+
+	query = `
+		SELECT status, COUNT(*) as order_count, SUM(amount) as total_amount
+		FROM orders
+		WHERE tenant_id = ? AND customer_id = ?
+		GROUP BY status
+		ORDER BY total_amount DESC
+	`
+	rows, err := db.QueryContext(ctx, query, id, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var summary OrderStatusSummary
+		if err := rows.Scan(&summary.Status, &summary.OrderCount, &summary.TotalAmount); err != nil {
+			return nil, err
+		}
+		detail.ByStatus = append(detail.ByStatus, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	month := db.dialect.truncateDate("created_at", groupByMonth)
+	query = `
+		SELECT ` + month + ` as month, COUNT(*) as order_count, SUM(amount) as total_spend
+		FROM orders
+		WHERE tenant_id = ? AND customer_id = ? AND created_at >= ?
+		GROUP BY ` + month + `
+		ORDER BY month
+	`
+	rows, err = db.QueryContext(ctx, query, id, customerID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var spend MonthlySpend
+		if err := rows.Scan(&spend.Month, &spend.OrderCount, &spend.TotalSpend); err != nil {
+			return nil, err
+		}
+		detail.Monthly = append(detail.Monthly, spend)
+	}
+	return detail, rows.Err()
+}
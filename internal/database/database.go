@@ -1,33 +1,55 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"api-gateway-backend/internal/config"
-
-	_ "github.com/go-sql-driver/mysql"
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/tenant"
 )
 
-// DB wraps sql.DB
+// ErrNotFound is returned when a requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+// maxIdleConns is how many idle connections the pool keeps
+const maxIdleConns = 25
+
+// DB wraps sql.DB, rebinding placeholders for the dialect and recording
+// query metrics
 type DB struct {
 	*sql.DB
+	dialect   dialect
+	connector *rotatingConnector
+	logger    *logger.Logger
+	slowQuery time.Duration // queries taking longer are logged, 0 disables
+	outbox    bool          // record item and order changes in outbox_events
 }
 
-// New creates a new database connection
-func New(cfg config.DatabaseConfig) (*DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
+// New creates a new database connection for the configured driver. Slow
+// queries are logged to log.
+func New(cfg config.DatabaseConfig, log *logger.Logger) (*DB, error) {
+	d, err := dialectFor(cfg.Driver)
+	if err != nil {
+		return nil, err
+	}
 
-	db, err := sql.Open("mysql", dsn)
+	connector, err := newRotatingConnector(d, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	db := sql.OpenDB(connector)
 
 	// Configure connection pool
 	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
+	db.SetMaxIdleConns(maxIdleConns)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
 	// Test connection
@@ -35,124 +57,829 @@ func New(cfg config.DatabaseConfig) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{DB: db}, nil
+	return &DB{
+		DB:        db,
+		dialect:   d,
+		connector: connector,
+		logger:    log,
+		slowQuery: time.Duration(cfg.SlowQueryThreshold) * time.Millisecond,
+	}, nil
+}
+
+// SetPassword switches to a rotated password. Idle connections are closed
+// so the pool reconnects with it; connections in use are replaced when
+// they reach their max lifetime.
+func (db *DB) SetPassword(password string) {
+	db.connector.setPassword(password)
+	db.DB.SetMaxIdleConns(0)
+	db.DB.SetMaxIdleConns(maxIdleConns)
+}
+
+// rotatingConnector opens each connection with the current settings, so a
+// rotated password applies to every new connection
+type rotatingConnector struct {
+	dialect dialect
+	driver  driver.Driver
+
+	mu  sync.RWMutex
+	cfg config.DatabaseConfig
+}
+
+// newRotatingConnector creates a connector for cfg, checking its DSN parses
+func newRotatingConnector(d dialect, cfg config.DatabaseConfig) (*rotatingConnector, error) {
+	base, err := d.connector(d.dsn(cfg))
+	if err != nil {
+		return nil, err
+	}
+	return &rotatingConnector{dialect: d, driver: base.Driver(), cfg: cfg}, nil
+}
+
+func (c *rotatingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.RLock()
+	dsn := c.dialect.dsn(c.cfg)
+	c.mu.RUnlock()
+
+	connector, err := c.dialect.connector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *rotatingConnector) Driver() driver.Driver { return c.driver }
+
+func (c *rotatingConnector) setPassword(password string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg.Password = password
+}
+
+// ExecContext executes a statement written with "?" placeholders
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := db.DB.ExecContext(ctx, db.dialect.rebind(query), args...)
+	db.observe(ctx, query, start, err)
+	return result, err
+}
+
+// QueryContext runs a query written with "?" placeholders. Its duration is
+// recorded up to the first row being ready, not while the rows are read.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := db.DB.QueryContext(ctx, db.dialect.rebind(query), args...)
+	db.observe(ctx, query, start, err)
+	return rows, err
+}
+
+// QueryRowContext runs a single-row query written with "?" placeholders
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := db.DB.QueryRowContext(ctx, db.dialect.rebind(query), args...)
+	db.observe(ctx, query, start, row.Err())
+	return row
+}
+
+// txExecContext executes a statement written with "?" placeholders in tx
+func (db *DB) txExecContext(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := tx.ExecContext(ctx, db.dialect.rebind(query), args...)
+	db.observe(ctx, query, start, err)
+	return result, err
+}
+
+// txQueryContext runs a query written with "?" placeholders in tx
+func (db *DB) txQueryContext(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := tx.QueryContext(ctx, db.dialect.rebind(query), args...)
+	db.observe(ctx, query, start, err)
+	return rows, err
+}
+
+// txQueryRowContext runs a single-row query written with "?" placeholders
+// in tx
+func (db *DB) txQueryRowContext(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := tx.QueryRowContext(ctx, db.dialect.rebind(query), args...)
+	db.observe(ctx, query, start, row.Err())
+	return row
+}
+
+// Exec is ExecContext without a context, rebinding placeholders as well
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// Query is QueryContext without a context, rebinding placeholders as well
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRow is QueryRowContext without a context, rebinding placeholders as well
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.QueryRowContext(context.Background(), query, args...)
+}
+
+// insert executes an INSERT and returns the new row's ID
+func (db *DB) insert(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	if returning := db.dialect.returningID(); returning != "" {
+		var id int64
+		err := db.QueryRowContext(ctx, query+returning, args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
 }
 
-// Item represents an item from external API
+// Item represents an item from external API. DeletedAt is set once the
+// item is no longer returned by the upstream.
 type Item struct {
-	ID         int64     `json:"id"`
-	ExternalID string    `json:"external_id"`
-	Title      string    `json:"title"`
-	Body       string    `json:"body"`
-	UserID     int       `json:"user_id"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID         int64      `json:"id"`
+	TenantID   string     `json:"tenant_id"`
+	ExternalID string     `json:"external_id"`
+	Title      string     `json:"title"`
+	Body       string     `json:"body"`
+	UserID     int        `json:"user_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+}
+
+// itemColumns are the items columns scanItem reads, in order
+const itemColumns = "id, tenant_id, external_id, title, body, user_id, created_at, updated_at, deleted_at"
+
+// ChangedBy reports whether upserting item over i, its stored row, changes
+// the row, including restoring it if it was deleted
+func (i Item) ChangedBy(item Item) bool {
+	return i.DeletedAt != nil || i.Title != item.Title || i.Body != item.Body || i.UserID != item.UserID
+}
+
+// itemExternalIDs returns the external IDs of items
+func itemExternalIDs(items []Item) []string {
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ExternalID
+	}
+	return ids
+}
+
+// scanItem scans a row of itemColumns
+func scanItem(row interface{ Scan(...interface{}) error }) (Item, error) {
+	var item Item
+	var deletedAt sql.NullTime
+	err := row.Scan(&item.ID, &item.TenantID, &item.ExternalID, &item.Title, &item.Body, &item.UserID, &item.CreatedAt, &item.UpdatedAt, &deletedAt)
+	if deletedAt.Valid {
+		item.DeletedAt = &deletedAt.Time
+	}
+	return item, err
 }
 
 // Order represents an order for analytics queries
 type Order struct {
 	ID         int64     `json:"id"`
+	TenantID   string    `json:"tenant_id"`
 	CustomerID string    `json:"customer_id"`
 	Amount     float64   `json:"amount"`
 	Status     string    `json:"status"`
 	CreatedAt  time.Time `json:"created_at"`
 }
 
-// OrderStatusSummary represents order summary by status
+// OrderStatusSummary represents order summary by status. PeriodStart is
+// set when the summary is grouped by day or week.
 type OrderStatusSummary struct {
-	Status      string  `json:"status"`
-	OrderCount  int     `json:"order_count"`
-	TotalAmount float64 `json:"total_amount"`
+	PeriodStart *time.Time `json:"period_start,omitempty"`
+	Status      string     `json:"status"`
+	OrderCount  int        `json:"order_count"`
+	TotalAmount float64    `json:"total_amount"`
 }
 
 // TopCustomer represents top customer by spend
 type TopCustomer struct {
-	CustomerID  string  `json:"customer_id"`
-	TotalSpend  float64 `json:"total_spend"`
-	OrderCount  int     `json:"order_count"`
+	CustomerID string  `json:"customer_id"`
+	TotalSpend float64 `json:"total_spend"`
+	OrderCount int     `json:"order_count"`
 }
 
-// UpsertItem inserts or updates an item (idempotent)
-func (db *DB) UpsertItem(item *Item) error {
+// execFunc executes a statement written with "?" placeholders, on the
+// pool or in a transaction
+type execFunc func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+
+// queryFunc runs a query written with "?" placeholders, on the pool or in
+// a transaction
+type queryFunc func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+
+// Items and orders belong to the tenant of the context they are written
+// with, and every query below only reads the rows of the context's tenant;
+// see package tenant.
+
+// UpsertItem inserts or updates an item (idempotent). With the outbox, the
+// item is written in a transaction together with its event.
+func (db *DB) UpsertItem(ctx context.Context, item *Item) error {
+	if !db.outbox {
+		return db.upsertItem(ctx, db.ExecContext, item)
+	}
+	return db.WithTx(ctx, func(tx *Tx) error {
+		return tx.withItemEvents(ctx, []Item{*item}, func() error {
+			return db.upsertItem(ctx, tx.exec, item)
+		})
+	})
+}
+
+func (db *DB) upsertItem(ctx context.Context, exec execFunc, item *Item) error {
 	query := `
-		INSERT INTO items (external_id, title, body, user_id, created_at, updated_at)
-		VALUES (?, ?, ?, ?, NOW(), NOW())
-		ON DUPLICATE KEY UPDATE
-			title = VALUES(title),
-			body = VALUES(body),
-			user_id = VALUES(user_id),
-			updated_at = NOW()
-	`
-	_, err := db.Exec(query, item.ExternalID, item.Title, item.Body, item.UserID)
+		INSERT INTO items (tenant_id, external_id, title, body, user_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, NOW(), NOW())` + db.dialect.upsertItemsClause()
+	_, err := exec(ctx, query, tenant.From(ctx), item.ExternalID, item.Title, item.Body, item.UserID)
 	return err
 }
 
-// GetAllItems retrieves all items from database
-func (db *DB) GetAllItems() ([]Item, error) {
-	query := `SELECT id, external_id, title, body, user_id, created_at, updated_at FROM items ORDER BY created_at DESC`
-	rows, err := db.Query(query)
+// maxUpsertBatchSize keeps a multi-row upsert under MySQL's limit of
+// 65535 placeholders per statement (5 per row)
+const maxUpsertBatchSize = 13000
+
+// UpsertItemsBatch upserts items using multi-row upsert statements of at
+// most chunkSize rows. Chunks are not wrapped in a
+// transaction: on error, chunks before the failing one remain written.
+// With the outbox, each chunk is written in its own transaction together
+// with its events.
+func (db *DB) UpsertItemsBatch(ctx context.Context, items []Item, chunkSize int) error {
+	return forEachItemChunk(items, chunkSize, func(chunk []Item) error {
+		if !db.outbox {
+			return db.upsertItemsChunk(ctx, db.ExecContext, chunk)
+		}
+		return db.WithTx(ctx, func(tx *Tx) error {
+			return tx.withItemEvents(ctx, chunk, func() error {
+				return db.upsertItemsChunk(ctx, tx.exec, chunk)
+			})
+		})
+	})
+}
+
+// forEachItemChunk calls write with consecutive chunks of at most
+// chunkSize items, stopping at the first error
+func forEachItemChunk(items []Item, chunkSize int, write func(chunk []Item) error) error {
+	if chunkSize <= 0 || chunkSize > maxUpsertBatchSize {
+		chunkSize = maxUpsertBatchSize
+	}
+
+	for start := 0; start < len(items); start += chunkSize {
+		chunk := items[start:min(start+chunkSize, len(items))]
+		if err := write(chunk); err != nil {
+			return fmt.Errorf("failed to upsert items %d-%d: %w", start, start+len(chunk)-1, err)
+		}
+	}
+
+	return nil
+}
+
+// upsertItemsChunk upserts items in one multi-row statement
+func (db *DB) upsertItemsChunk(ctx context.Context, exec execFunc, items []Item) error {
+	query, args := db.upsertItemsQuery(tenant.From(ctx), items)
+	_, err := exec(ctx, query, args...)
+	return err
+}
+
+// upsertItemsQuery builds one multi-row upsert statement for items of
+// tenantID
+func (db *DB) upsertItemsQuery(tenantID string, items []Item) (string, []interface{}) {
+	rows := make([]string, len(items))
+	args := make([]interface{}, 0, len(items)*5)
+	for i, item := range items {
+		rows[i] = "(?, ?, ?, ?, ?, NOW(), NOW())"
+		args = append(args, tenantID, item.ExternalID, item.Title, item.Body, item.UserID)
+	}
+
+	query := `
+		INSERT INTO items (tenant_id, external_id, title, body, user_id, created_at, updated_at)
+		VALUES ` + strings.Join(rows, ", ") + db.dialect.upsertItemsClause()
+	return query, args
+}
+
+// ItemFilter narrows and orders an items query. Zero values mean no filter.
+type ItemFilter struct {
+	UserID        *int
+	ExternalID    string
+	CreatedFrom   *time.Time
+	CreatedTo     *time.Time
+	SortBy        string // one of ItemSortColumns, defaults to created_at
+	SortAscending bool
+
+	// IncludeDeleted also returns items the upstream no longer has
+	IncludeDeleted bool
+}
+
+// ItemSortColumns lists the columns items can be sorted by
+var ItemSortColumns = map[string]bool{
+	"id":          true,
+	"external_id": true,
+	"title":       true,
+	"user_id":     true,
+	"created_at":  true,
+	"updated_at":  true,
+}
+
+// GetAllItems retrieves all items from database, except deleted ones
+func (db *DB) GetAllItems(ctx context.Context) ([]Item, error) {
+	return db.GetItems(ctx, ItemFilter{})
+}
+
+// GetItems retrieves items matching the filter using a parameterized query
+func (db *DB) GetItems(ctx context.Context, filter ItemFilter) ([]Item, error) {
+	var items []Item
+	err := db.StreamItems(ctx, filter, func(item Item) error {
+		items = append(items, item)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return items, nil
+}
+
+// StreamItems calls fn with each item matching the filter as it is read,
+// without loading the whole result. An error from fn stops the query and
+// is returned.
+func (db *DB) StreamItems(ctx context.Context, filter ItemFilter, fn func(Item) error) error {
+	query := `SELECT ` + itemColumns + ` FROM items`
+
+	conditions := []string{"tenant_id = ?"}
+	args := []interface{}{tenant.From(ctx)}
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if filter.UserID != nil {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, *filter.UserID)
+	}
+	if filter.ExternalID != "" {
+		conditions = append(conditions, "external_id = ?")
+		args = append(args, filter.ExternalID)
+	}
+	if filter.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, *filter.CreatedTo)
+	}
+	query += " WHERE " + strings.Join(conditions, " AND ")
+
+	// Sort column is checked against a whitelist since it can't be a parameter
+	sortBy := "created_at"
+	if ItemSortColumns[filter.SortBy] {
+		sortBy = filter.SortBy
+	}
+	direction := "DESC"
+	if filter.SortAscending {
+		direction = "ASC"
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s", sortBy, direction)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
 	defer rows.Close()
 
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetItemByID retrieves a single item, returning ErrNotFound if it doesn't
+// exist or was deleted
+func (db *DB) GetItemByID(ctx context.Context, id int64) (*Item, error) {
+	query := `SELECT ` + itemColumns + ` FROM items WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL`
+
+	item, err := scanItem(db.QueryRowContext(ctx, query, id, tenant.From(ctx)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// itemLookupChunkSize bounds the IN list of a single items lookup
+const itemLookupChunkSize = 500
+
+// GetItemsByExternalIDs retrieves the stored items among externalIDs,
+// deleted ones included. IDs without a stored item are skipped.
+func (db *DB) GetItemsByExternalIDs(ctx context.Context, externalIDs []string) ([]Item, error) {
+	return db.getItemsByExternalIDs(ctx, db.QueryContext, externalIDs)
+}
+
+func (db *DB) getItemsByExternalIDs(ctx context.Context, queryRows queryFunc, externalIDs []string) ([]Item, error) {
 	var items []Item
+	for start := 0; start < len(externalIDs); start += itemLookupChunkSize {
+		end := start + itemLookupChunkSize
+		if end > len(externalIDs) {
+			end = len(externalIDs)
+		}
+		chunk := externalIDs[start:end]
+
+		args := make([]interface{}, 0, len(chunk)+1)
+		args = append(args, tenant.From(ctx))
+		for _, id := range chunk {
+			args = append(args, id)
+		}
+		query := `SELECT ` + itemColumns + ` FROM items WHERE tenant_id = ? AND external_id IN (?` +
+			strings.Repeat(", ?", len(chunk)-1) + `)`
+
+		rows, err := queryRows(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			item, err := scanItem(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+// SoftDeleteMissingItems marks every item whose external ID isn't in seen,
+// the IDs returned by a full sync, as deleted. It returns the external IDs
+// it deleted; items deleted before are left as they are.
+func (db *DB) SoftDeleteMissingItems(ctx context.Context, seen []string) ([]string, error) {
+	keep := make(map[string]bool, len(seen))
+	for _, id := range seen {
+		keep[id] = true
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT external_id FROM items WHERE tenant_id = ? AND deleted_at IS NULL`, tenant.From(ctx))
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
 	for rows.Next() {
-		var item Item
-		err := rows.Scan(&item.ID, &item.ExternalID, &item.Title, &item.Body, &item.UserID, &item.CreatedAt, &item.UpdatedAt)
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !keep[id] {
+			missing = append(missing, id)
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(missing); start += itemLookupChunkSize {
+		chunk := missing[start:min(start+itemLookupChunkSize, len(missing))]
+		now := time.Now()
+		args := make([]interface{}, 0, len(chunk)+3)
+		args = append(args, now, now, tenant.From(ctx))
+		for _, id := range chunk {
+			args = append(args, id)
+		}
+		query := `UPDATE items SET deleted_at = ?, updated_at = ? WHERE tenant_id = ? AND deleted_at IS NULL AND external_id IN (?` +
+			strings.Repeat(", ?", len(chunk)-1) + `)`
+
+		var err error
+		if !db.outbox {
+			_, err = db.ExecContext(ctx, query, args...)
+		} else {
+			err = db.WithTx(ctx, func(tx *Tx) error {
+				if _, err := tx.exec(ctx, query, args...); err != nil {
+					return err
+				}
+				return tx.recordItemEvents(ctx, chunk, func(Item) string { return EventItemDeleted })
+			})
+		}
+		if err != nil {
+			return missing[:start], fmt.Errorf("failed to mark items deleted: %w", err)
+		}
+	}
+	return missing, nil
+}
+
+// OrderStatuses lists the valid order statuses
+var OrderStatuses = map[string]bool{
+	"PENDING":   true,
+	"PAID":      true,
+	"CANCELLED": true,
+}
+
+// OrderFilter narrows an orders query. Zero values mean no filter.
+type OrderFilter struct {
+	Status      string
+	CustomerID  string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Limit       int
+	Offset      int
+}
+
+// GetOrders retrieves orders matching the filter, newest first
+func (db *DB) GetOrders(ctx context.Context, filter OrderFilter) ([]Order, error) {
+	query := `SELECT id, tenant_id, customer_id, amount, status, created_at FROM orders`
+
+	conditions := []string{"tenant_id = ?"}
+	args := []interface{}{tenant.From(ctx)}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.CustomerID != "" {
+		conditions = append(conditions, "customer_id = ?")
+		args = append(args, filter.CustomerID)
+	}
+	if filter.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, *filter.CreatedTo)
+	}
+	query += " WHERE " + strings.Join(conditions, " AND ")
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []Order
+	for rows.Next() {
+		var order Order
+		err := rows.Scan(&order.ID, &order.TenantID, &order.CustomerID, &order.Amount, &order.Status, &order.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
-		items = append(items, item)
+		orders = append(orders, order)
+	}
+
+	return orders, rows.Err()
+}
+
+// GetOrderByID retrieves a single order, returning ErrNotFound if it doesn't exist
+func (db *DB) GetOrderByID(ctx context.Context, id int64) (*Order, error) {
+	query := `SELECT id, tenant_id, customer_id, amount, status, created_at FROM orders WHERE id = ? AND tenant_id = ?`
+
+	var order Order
+	err := db.QueryRowContext(ctx, query, id, tenant.From(ctx)).Scan(&order.ID, &order.TenantID, &order.CustomerID, &order.Amount, &order.Status, &order.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+// CreateOrder inserts a new order for the context's tenant and sets its ID
+// and TenantID (and CreatedAt if unset). With the outbox, the order is
+// inserted in a transaction together with its event.
+func (db *DB) CreateOrder(ctx context.Context, order *Order) error {
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = time.Now()
+	}
+	order.TenantID = tenant.From(ctx)
+
+	query := `INSERT INTO orders (tenant_id, customer_id, amount, status, created_at) VALUES (?, ?, ?, ?, ?)`
+	args := []interface{}{order.TenantID, order.CustomerID, order.Amount, order.Status, order.CreatedAt}
+	if !db.outbox {
+		id, err := db.insert(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		order.ID = id
+		return nil
+	}
+
+	return db.WithTx(ctx, func(tx *Tx) error {
+		id, err := tx.insert(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		order.ID = id
+
+		event, err := newOutboxEvent(order.TenantID, AggregateOrder, strconv.FormatInt(id, 10), EventOrderCreated, order)
+		if err != nil {
+			return err
+		}
+		return tx.addOutboxEvents(ctx, []OutboxEvent{event})
+	})
+}
+
+// Order status summary groupings
+const (
+	GroupByDay  = "day"
+	GroupByWeek = "week"
+)
+
+// OrderStatusFilter selects the orders an order status summary covers.
+// Zero time bounds mean no bound; GroupBy is empty for one total per status,
+// or GroupByDay or GroupByWeek for a time series.
+type OrderStatusFilter struct {
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	GroupBy     string
+}
+
+// GetOrderStatusSummary returns order count and total amount by status over
+// the filter's window using a parameterized query
+func (db *DB) GetOrderStatusSummary(ctx context.Context, filter OrderStatusFilter) ([]OrderStatusSummary, error) {
+	var period string
+	switch filter.GroupBy {
+	case "":
+	case GroupByDay, GroupByWeek:
+		period = db.dialect.truncateDate("created_at", filter.GroupBy)
+	default:
+		return nil, fmt.Errorf("unsupported grouping %q", filter.GroupBy)
+	}
+
+	columns := "status, COUNT(*) as order_count, SUM(amount) as total_amount"
+	if period != "" {
+		columns = period + " as period_start, " + columns
+	}
+	query := `SELECT ` + columns + ` FROM orders`
+
+	conditions := []string{"tenant_id = ?"}
+	args := []interface{}{tenant.From(ctx)}
+	if filter.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, *filter.CreatedTo)
+	}
+	query += " WHERE " + strings.Join(conditions, " AND ")
+	if period != "" {
+		query += " GROUP BY period_start, status ORDER BY period_start, total_amount DESC"
+	} else {
+		query += " GROUP BY status ORDER BY total_amount DESC"
 	}
 
-	return items, rows.Err()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []OrderStatusSummary
+	for rows.Next() {
+		var summary OrderStatusSummary
+		dest := []interface{}{&summary.Status, &summary.OrderCount, &summary.TotalAmount}
+		if period != "" {
+			summary.PeriodStart = new(time.Time)
+			dest = append([]interface{}{summary.PeriodStart}, dest...)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, rows.Err()
+}
+
+// TopCustomersFilter limits a top customers query to orders created in a
+// time window. Zero values mean no bound.
+type TopCustomersFilter struct {
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Limit       int
 }
 
-// GetOrderStatusSummary returns order count and total amount by status for last 30 days
-func (db *DB) GetOrderStatusSummary() ([]OrderStatusSummary, error) {
+// GetTopCustomers returns the customers with the highest total spend over
+// the filter's window using a parameterized query
+func (db *DB) GetTopCustomers(ctx context.Context, filter TopCustomersFilter) ([]TopCustomer, error) {
+	query := `SELECT customer_id, SUM(amount) as total_spend, COUNT(*) as order_count FROM orders`
+
+	conditions := []string{"tenant_id = ?"}
+	args := []interface{}{tenant.From(ctx)}
+	if filter.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, *filter.CreatedTo)
+	}
+	query += " WHERE " + strings.Join(conditions, " AND ")
+	query += " GROUP BY customer_id ORDER BY total_spend DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var customers []TopCustomer
+	for rows.Next() {
+		var customer TopCustomer
+		err := rows.Scan(&customer.CustomerID, &customer.TotalSpend, &customer.OrderCount)
+		if err != nil {
+			return nil, err
+		}
+		customers = append(customers, customer)
+	}
+
+	return customers, rows.Err()
+}
+
+// DailyOrderStatusTotal represents order totals for one status on one day
+type DailyOrderStatusTotal struct {
+	Day         time.Time `json:"day"`
+	Status      string    `json:"status"`
+	OrderCount  int       `json:"order_count"`
+	TotalAmount float64   `json:"total_amount"`
+}
+
+// GetDailyOrderStatusTotals returns order totals per day and status for the
+// last n UTC calendar days, including today
+func (db *DB) GetDailyOrderStatusTotals(ctx context.Context, days int) ([]DailyOrderStatusTotal, error) {
 	query := `
-		SELECT 
+		SELECT
+			DATE(created_at) as day,
 			status,
 			COUNT(*) as order_count,
 			SUM(amount) as total_amount
-		FROM orders 
-		WHERE created_at >= DATE_SUB(NOW(), INTERVAL 30 DAY)
-		GROUP BY status
-		ORDER BY total_amount DESC
+		FROM orders
+		WHERE tenant_id = ? AND created_at >= ?
+		GROUP BY day, status
 	`
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query, tenant.From(ctx), startOfDaysAgo(time.Now(), days-1))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var summaries []OrderStatusSummary
+	var totals []DailyOrderStatusTotal
 	for rows.Next() {
-		var summary OrderStatusSummary
-		err := rows.Scan(&summary.Status, &summary.OrderCount, &summary.TotalAmount)
+		var total DailyOrderStatusTotal
+		err := rows.Scan(&total.Day, &total.Status, &total.OrderCount, &total.TotalAmount)
 		if err != nil {
 			return nil, err
 		}
-		summaries = append(summaries, summary)
+		totals = append(totals, total)
 	}
 
-	return summaries, rows.Err()
+	return totals, rows.Err()
+}
+
+// startOfDaysAgo returns midnight UTC the given number of days before
+// now's UTC date. Windows of calendar days start from it rather than from
+// CURDATE() or CURRENT_DATE, which follow the database server's time zone,
+// so they line up with the UTC day buckets of the analytics store.
+func startOfDaysAgo(now time.Time, days int) time.Time {
+	year, month, day := now.UTC().Date()
+	return time.Date(year, month, day-days, 0, 0, 0, 0, time.UTC)
 }
 
-// GetTopCustomers returns top 5 customers by total spend
-func (db *DB) GetTopCustomers() ([]TopCustomer, error) {
+// GetCustomerTotals returns total spend and order count for every customer
+func (db *DB) GetCustomerTotals(ctx context.Context) ([]TopCustomer, error) {
 	query := `
-		SELECT 
+		SELECT
 			customer_id,
 			SUM(amount) as total_spend,
 			COUNT(*) as order_count
-		FROM orders 
+		FROM orders
+		WHERE tenant_id = ?
 		GROUP BY customer_id
-		ORDER BY total_spend DESC
-		LIMIT 5
 	`
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query, tenant.From(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -169,4 +896,23 @@ func (db *DB) GetTopCustomers() ([]TopCustomer, error) {
 	}
 
 	return customers, rows.Err()
-}
\ No newline at end of file
+}
+
+// ListOrderTenants returns every tenant that has orders
+func (db *DB) ListOrderTenants(ctx context.Context) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT DISTINCT tenant_id FROM orders`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, id)
+	}
+	return tenants, rows.Err()
+}
@@ -1,7 +1,7 @@
 package database
 
 import (
-	"database/sql"
+	"context"
 	"testing"
 	"time"
 
@@ -103,7 +103,7 @@ func TestDatabaseConnection(t *testing.T) {
 		Name:     "invalid",
 	}
 
-	_, err := New(invalidCfg)
+	_, err := New(invalidCfg, nil)
 	assert.Error(t, err, "Should fail with invalid database config")
 }
 
@@ -123,16 +123,16 @@ func TestUpsertItem_Integration(t *testing.T) {
 	}
 
 	// Test insert
-	err := db.UpsertItem(item)
+	err := db.UpsertItem(context.Background(), item)
 	require.NoError(t, err)
 
 	// Test update (idempotent)
 	item.Title = "Updated Title"
-	err = db.UpsertItem(item)
+	err = db.UpsertItem(context.Background(), item)
 	require.NoError(t, err)
 
 	// Verify the item was updated
-	items, err := db.GetAllItems()
+	items, err := db.GetAllItems(context.Background())
 	require.NoError(t, err)
 	require.Len(t, items, 1)
 	assert.Equal(t, "Updated Title", items[0].Title)
@@ -153,12 +153,21 @@ func TestGetAllItems_Integration(t *testing.T) {
 	}
 
 	for _, item := range items {
-		err := db.UpsertItem(item)
+		err := db.UpsertItem(context.Background(), item)
 		require.NoError(t, err)
 	}
 
 	// Test retrieval
-	retrievedItems, err := db.GetAllItems()
+	retrievedItems, err := db.GetAllItems(context.Background())
 	require.NoError(t, err)
 	assert.Len(t, retrievedItems, 2)
-}
\ No newline at end of file
+}
+
+func TestStartOfDaysAgo(t *testing.T) {
+	// Late evening in New York is already the next day in UTC
+	newYork := time.FixedZone("EST", -5*60*60)
+	now := time.Date(2024, 3, 1, 21, 30, 0, 0, newYork)
+
+	assert.Equal(t, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), startOfDaysAgo(now, 0))
+	assert.Equal(t, time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC), startOfDaysAgo(now, 28))
+}
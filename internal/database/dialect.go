@@ -0,0 +1,198 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"api-gateway-backend/internal/config"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// Supported database drivers
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+)
+
+// dialect holds the SQL that differs between database drivers. Queries are
+// written with "?" placeholders and MySQL-compatible SQL elsewhere; only
+// the pieces below are driver specific.
+type dialect interface {
+	// dsn builds the driver connection string
+	dsn(cfg config.DatabaseConfig) string
+	// connector opens connections to dsn
+	connector(dsn string) (driver.Connector, error)
+	// rebind rewrites "?" placeholders into the driver's syntax
+	rebind(query string) string
+	// upsertItemsClause completes an INSERT INTO items so existing rows
+	// (by external_id) are updated, and restored if they were deleted
+	upsertItemsClause() string
+	// upsertClause completes a multi-row INSERT so rows conflicting on
+	// key have columns overwritten and updated_at bumped
+	upsertClause(key string, columns []string) string
+	// truncateDate is an expression for the date starting the day,
+	// (Monday-based) week or month that column falls in
+	truncateDate(column, unit string) string
+	// returningID is appended to an INSERT to get the new row's ID, or is
+	// empty if the driver supports LastInsertId
+	returningID() string
+	// fullTextMatch is a condition on items matching a full-text search of
+	// title and body, and fullTextScore the relevance of a match; each
+	// takes the search terms as one placeholder
+	fullTextMatch() string
+	fullTextScore() string
+	// missingFullTextIndex reports whether err is from a full-text search
+	// without the index it needs
+	missingFullTextIndex(err error) bool
+}
+
+// dialectFor returns the dialect for a driver name
+func dialectFor(driver string) (dialect, error) {
+	switch driver {
+	case "", DriverMySQL:
+		return mysqlDialect{}, nil
+	case DriverPostgres:
+		return postgresDialect{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+// mysqlDialect is the dialect for MySQL
+type mysqlDialect struct{}
+
+func (mysqlDialect) dsn(cfg config.DatabaseConfig) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
+}
+
+func (mysqlDialect) connector(dsn string) (driver.Connector, error) {
+	return mysql.MySQLDriver{}.OpenConnector(dsn)
+}
+
+func (mysqlDialect) rebind(query string) string { return query }
+
+func (mysqlDialect) upsertItemsClause() string {
+	return `
+		ON DUPLICATE KEY UPDATE
+			title = VALUES(title),
+			body = VALUES(body),
+			user_id = VALUES(user_id),
+			updated_at = NOW(),
+			deleted_at = NULL`
+}
+
+func (mysqlDialect) upsertClause(key string, columns []string) string {
+	set := make([]string, 0, len(columns)+1)
+	for _, c := range columns {
+		set = append(set, c+" = VALUES("+c+")")
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(append(set, "updated_at = NOW()"), ", ")
+}
+
+func (mysqlDialect) truncateDate(column, unit string) string {
+	switch unit {
+	case GroupByWeek:
+		return fmt.Sprintf("DATE(DATE_SUB(%[1]s, INTERVAL WEEKDAY(%[1]s) DAY))", column)
+	case groupByMonth:
+		return fmt.Sprintf("DATE(DATE_SUB(%[1]s, INTERVAL DAYOFMONTH(%[1]s) - 1 DAY))", column)
+	}
+	return fmt.Sprintf("DATE(%s)", column)
+}
+
+func (mysqlDialect) returningID() string { return "" }
+
+func (mysqlDialect) fullTextMatch() string {
+	return "MATCH(title, body) AGAINST (? IN NATURAL LANGUAGE MODE)"
+}
+
+func (d mysqlDialect) fullTextScore() string { return d.fullTextMatch() }
+
+// errFullTextIndexMissing is ER_FT_MATCHING_KEY_NOT_FOUND, returned when
+// the items table predates its FULLTEXT index
+const errFullTextIndexMissing = 1191
+
+func (mysqlDialect) missingFullTextIndex(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errFullTextIndexMissing
+}
+
+// postgresDialect is the dialect for PostgreSQL
+type postgresDialect struct{}
+
+func (postgresDialect) dsn(cfg config.DatabaseConfig) string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		pqQuote(cfg.Host), cfg.Port, pqQuote(cfg.User), pqQuote(cfg.Password), pqQuote(cfg.Name), pqQuote(cfg.SSLMode))
+}
+
+// pqQuote quotes a connection string value, so generated passwords with
+// spaces or quotes survive
+func pqQuote(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+func (postgresDialect) connector(dsn string) (driver.Connector, error) {
+	return pq.NewConnector(dsn)
+}
+
+// rebind numbers placeholders as $1, $2, ...
+func (postgresDialect) rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (postgresDialect) upsertItemsClause() string {
+	return `
+		ON CONFLICT (tenant_id, external_id) DO UPDATE SET
+			title = EXCLUDED.title,
+			body = EXCLUDED.body,
+			user_id = EXCLUDED.user_id,
+			updated_at = NOW(),
+			deleted_at = NULL`
+}
+
+func (postgresDialect) upsertClause(key string, columns []string) string {
+	set := make([]string, 0, len(columns)+1)
+	for _, c := range columns {
+		set = append(set, c+" = EXCLUDED."+c)
+	}
+	return " ON CONFLICT (" + key + ") DO UPDATE SET " + strings.Join(append(set, "updated_at = NOW()"), ", ")
+}
+
+func (postgresDialect) truncateDate(column, unit string) string {
+	if unit == GroupByWeek || unit == groupByMonth {
+		return fmt.Sprintf("CAST(date_trunc('%s', %s) AS DATE)", unit, column)
+	}
+	return fmt.Sprintf("CAST(%s AS DATE)", column)
+}
+
+func (postgresDialect) returningID() string { return " RETURNING id" }
+
+// itemsDocument is the text search document of an item, the expression of
+// the idx_items_search index
+const itemsDocument = "to_tsvector('simple', title || ' ' || COALESCE(body, ''))"
+
+func (postgresDialect) fullTextMatch() string {
+	return itemsDocument + " @@ plainto_tsquery('simple', ?)"
+}
+
+func (postgresDialect) fullTextScore() string {
+	return "ts_rank(" + itemsDocument + ", plainto_tsquery('simple', ?))"
+}
+
+// missingFullTextIndex is false, as PostgreSQL searches without an index
+func (postgresDialect) missingFullTextIndex(err error) bool { return false }
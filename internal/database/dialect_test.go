@@ -0,0 +1,71 @@
+package database
+
+import (
+	"testing"
+
+	"api-gateway-backend/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresDialect_Rebind(t *testing.T) {
+	d := postgresDialect{}
+	assert.Equal(t,
+		"SELECT id FROM items WHERE user_id = $1 AND created_at >= $2 LIMIT $3",
+		d.rebind("SELECT id FROM items WHERE user_id = ? AND created_at >= ? LIMIT ?"))
+}
+
+func TestMySQLDialect_RebindIsNoop(t *testing.T) {
+	query := "SELECT id FROM items WHERE user_id = ?"
+	assert.Equal(t, query, mysqlDialect{}.rebind(query))
+}
+
+func TestDialectFor(t *testing.T) {
+	d, err := dialectFor("")
+	assert.NoError(t, err)
+	assert.IsType(t, mysqlDialect{}, d)
+
+	d, err = dialectFor(DriverPostgres)
+	assert.NoError(t, err)
+	assert.IsType(t, postgresDialect{}, d)
+
+	_, err = dialectFor("sqlite")
+	assert.Error(t, err)
+}
+
+func TestDialect_TruncateDate(t *testing.T) {
+	assert.Equal(t, "DATE(created_at)", mysqlDialect{}.truncateDate("created_at", GroupByDay))
+	assert.Equal(t, "DATE(DATE_SUB(created_at, INTERVAL WEEKDAY(created_at) DAY))", mysqlDialect{}.truncateDate("created_at", GroupByWeek))
+	assert.Equal(t, "CAST(created_at AS DATE)", postgresDialect{}.truncateDate("created_at", GroupByDay))
+	assert.Equal(t, "CAST(date_trunc('week', created_at) AS DATE)", postgresDialect{}.truncateDate("created_at", GroupByWeek))
+	assert.Equal(t, "DATE(DATE_SUB(created_at, INTERVAL DAYOFMONTH(created_at) - 1 DAY))", mysqlDialect{}.truncateDate("created_at", groupByMonth))
+	assert.Equal(t, "CAST(date_trunc('month', created_at) AS DATE)", postgresDialect{}.truncateDate("created_at", groupByMonth))
+}
+
+func TestDialect_UpsertClause(t *testing.T) {
+	assert.Equal(t,
+		" ON DUPLICATE KEY UPDATE title = VALUES(title), completed = VALUES(completed), updated_at = NOW()",
+		mysqlDialect{}.upsertClause("external_id", []string{"title", "completed"}))
+	assert.Equal(t,
+		" ON CONFLICT (external_id) DO UPDATE SET title = EXCLUDED.title, completed = EXCLUDED.completed, updated_at = NOW()",
+		postgresDialect{}.upsertClause("external_id", []string{"title", "completed"}))
+}
+
+func TestPostgresDialect_DSNQuotesValues(t *testing.T) {
+	cfg := config.DatabaseConfig{Host: "db", Port: 5432, User: "api", Password: `it's a \secret`, Name: "gateway", SSLMode: "disable"}
+	dsn := postgresDialect{}.dsn(cfg)
+	assert.Equal(t, `host='db' port=5432 user='api' password='it\'s a \\secret' dbname='gateway' sslmode='disable'`, dsn)
+
+	_, err := postgresDialect{}.connector(dsn)
+	assert.NoError(t, err)
+}
+
+func TestRotatingConnector_SetPassword(t *testing.T) {
+	c, err := newRotatingConnector(mysqlDialect{}, config.DatabaseConfig{User: "api", Password: "old", Host: "db", Port: 3306, Name: "gateway"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c.setPassword("new")
+	assert.Contains(t, c.dialect.dsn(c.cfg), "api:new@tcp(db:3306)")
+}
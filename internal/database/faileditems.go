@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// FailedItem is an item whose upsert kept failing during syncs and retries
+// until it was given up on, kept as a dead letter for inspection. CreatedAt
+// is when it was first given up on and UpdatedAt the latest.
+type FailedItem struct {
+	ID            int64     `json:"id"`
+	TenantID      string    `json:"tenant_id"`
+	ExternalID    string    `json:"external_id"`
+	Title         string    `json:"title"`
+	Body          string    `json:"body"`
+	UserID        int       `json:"user_id"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error"`
+	FirstFailedAt time.Time `json:"first_failed_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// failedItemColumns are the failed_items columns scanFailedItem reads, in order
+const failedItemColumns = "id, tenant_id, external_id, title, body, user_id, attempts, last_error, first_failed_at, created_at, updated_at"
+
+// SaveFailedItem records a dead-lettered item, replacing the dead letter
+// of the same item if it was given up on before
+func (db *DB) SaveFailedItem(ctx context.Context, item *FailedItem) error {
+	columns := []string{"title", "body", "user_id", "attempts", "last_error", "first_failed_at"}
+	query := `
+		INSERT INTO failed_items (tenant_id, external_id, title, body, user_id, attempts, last_error, first_failed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)` + db.dialect.upsertClause("tenant_id, external_id", columns)
+	_, err := db.ExecContext(ctx, query, item.TenantID, item.ExternalID, item.Title, item.Body, item.UserID,
+		item.Attempts, item.LastError, item.FirstFailedAt)
+	return err
+}
+
+// ListFailedItems retrieves dead-lettered items of every tenant, most
+// recently given up on first
+func (db *DB) ListFailedItems(ctx context.Context, limit, offset int) ([]FailedItem, error) {
+	query := `SELECT ` + failedItemColumns + ` FROM failed_items ORDER BY updated_at DESC, id DESC LIMIT ? OFFSET ?`
+	rows, err := db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []FailedItem
+	for rows.Next() {
+		item, err := scanFailedItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+
+	return items, rows.Err()
+}
+
+// GetFailedItem retrieves a dead-lettered item by ID, or ErrNotFound
+func (db *DB) GetFailedItem(ctx context.Context, id int64) (*FailedItem, error) {
+	query := `SELECT ` + failedItemColumns + ` FROM failed_items WHERE id = ?`
+	item, err := scanFailedItem(db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return item, err
+}
+
+// DeleteFailedItem removes a dead-lettered item, or returns ErrNotFound if
+// there is none with that ID
+func (db *DB) DeleteFailedItem(ctx context.Context, id int64) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM failed_items WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// scanFailedItem scans a single failed_items row
+func scanFailedItem(row interface{ Scan(...interface{}) error }) (*FailedItem, error) {
+	var item FailedItem
+	if err := row.Scan(&item.ID, &item.TenantID, &item.ExternalID, &item.Title, &item.Body, &item.UserID,
+		&item.Attempts, &item.LastError, &item.FirstFailedAt, &item.CreatedAt, &item.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"api-gateway-backend/internal/tenant"
+)
+
+// ItemStatsFilter selects the item statistics to compute
+type ItemStatsFilter struct {
+	Days  int // calendar days of daily counts, including today
+	Users int // users with the most items to list
+}
+
+// ItemStats summarizes the items of a tenant
+type ItemStats struct {
+	TotalItems   int              `json:"total_items"`   // live items
+	DeletedItems int              `json:"deleted_items"` // items the upstream no longer has
+	ByUser       []UserItemCount  `json:"by_user"`
+	Daily        []DailyItemCount `json:"daily"`
+}
+
+// UserItemCount is the number of live items of one user
+type UserItemCount struct {
+	UserID    int `json:"user_id"`
+	ItemCount int `json:"item_count"`
+}
+
+// DailyItemCount is the number of items created on one day
+type DailyItemCount struct {
+	Day       time.Time `json:"day"`
+	ItemCount int       `json:"item_count"`
+}
+
+// GetItemStats counts live and deleted items, live items per user for the
+// users with the most, and items created per day over the last
+// filter.Days calendar days. Days without new items are left out.
+func (db *DB) GetItemStats(ctx context.Context, filter ItemStatsFilter) (*ItemStats, error) {
+	id := tenant.From(ctx)
+	stats := &ItemStats{ByUser: []UserItemCount{}, Daily: []DailyItemCount{}}
+
+	query := `
+		SELECT
+			COUNT(CASE WHEN deleted_at IS NULL THEN 1 END) as total_items,
+			COUNT(deleted_at) as deleted_items
+		FROM items
+		WHERE tenant_id = ?
+	`
+	if err := db.QueryRowContext(ctx, query, id).Scan(&stats.TotalItems, &stats.DeletedItems); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT COALESCE(user_id, 0) as user_id, COUNT(*) as item_count
+		FROM items
+		WHERE tenant_id = ? AND deleted_at IS NULL
+		GROUP BY user_id
+		ORDER BY item_count DESC, user_id
+		LIMIT ?
+	`
+	rows, err := db.QueryContext(ctx, query, id, filter.Users)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var count UserItemCount
+		if err := rows.Scan(&count.UserID, &count.ItemCount); err != nil {
+			return nil, err
+		}
+		stats.ByUser = append(stats.ByUser, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	day := db.dialect.truncateDate("created_at", GroupByDay)
+	query = `
+		SELECT ` + day + ` as day, COUNT(*) as item_count
+		FROM items
+		WHERE tenant_id = ? AND created_at >= ?
+		GROUP BY ` + day + `
+		ORDER BY day
+	`
+	rows, err = db.QueryContext(ctx, query, id, startOfDaysAgo(time.Now(), filter.Days-1))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var count DailyItemCount
+		if err := rows.Scan(&count.Day, &count.ItemCount); err != nil {
+			return nil, err
+		}
+		stats.Daily = append(stats.Daily, count)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"api-gateway-backend/internal/metrics"
+)
+
+// Query metrics, labelled by query name (see queryName)
+var (
+	queryDuration = metrics.NewHistogramVec("db_query_duration_seconds",
+		"Time taken by database queries until their first result.", metrics.DefaultBuckets, "query")
+	queryErrors = metrics.NewCounterVec("db_query_errors_total",
+		"Database queries that failed, not counting no rows or a cancelled request.", "query")
+)
+
+func init() {
+	metrics.Default.Register(queryDuration, queryErrors)
+}
+
+var (
+	// queryTable finds the table a statement reads or writes
+	queryTable = regexp.MustCompile(`(?i)\b(?:from|into|update)\s+([a-z_][a-z0-9_]*)`)
+	// stringLiteral and numberLiteral find literals that may hold data
+	stringLiteral = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'`)
+	numberLiteral = regexp.MustCompile(`(^|[^\w$.])-?\d+(?:\.\d+)?`)
+)
+
+// observe records a query that started at start and failed with err, if
+// any, and logs it if it took longer than the slow query threshold
+func (db *DB) observe(ctx context.Context, query string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	name := queryName(query)
+	queryDuration.Observe(elapsed.Seconds(), name)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, context.Canceled) {
+		queryErrors.Inc(name)
+	}
+
+	if db.slowQuery > 0 && elapsed >= db.slowQuery && db.logger != nil {
+		db.logger.FromContext(ctx).WithFields(map[string]interface{}{
+			"query":       name,
+			"sql":         redactSQL(query),
+			"duration_ms": elapsed.Milliseconds(),
+		}).Warn("Slow database query")
+	}
+}
+
+// queryName names a query by its statement and table, e.g. "select items",
+// so metrics have a label per kind of query rather than per query text
+func queryName(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	verb := strings.ToLower(fields[0])
+	if m := queryTable.FindStringSubmatch(query); m != nil {
+		return verb + " " + strings.ToLower(m[1])
+	}
+	return verb
+}
+
+// redactSQL collapses a query's whitespace and replaces any literals in it
+// with ?, so logged SQL never carries data. Arguments bound to
+// placeholders aren't logged at all.
+func redactSQL(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	query = stringLiteral.ReplaceAllString(query, "?")
+	return numberLiteral.ReplaceAllString(query, "${1}?")
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/logger"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryName(t *testing.T) {
+	assert.Equal(t, "select items", queryName("\n\t\tSELECT id, title FROM items WHERE user_id = ?"))
+	assert.Equal(t, "insert webhook_events", queryName("INSERT INTO webhook_events (event_id) VALUES (?)"))
+	assert.Equal(t, "update webhook_deliveries", queryName("UPDATE webhook_deliveries SET status = ?"))
+	assert.Equal(t, "select", queryName("SELECT 1"))
+	assert.Equal(t, "unknown", queryName("  "))
+}
+
+func TestRedactSQL(t *testing.T) {
+	assert.Equal(t,
+		"SELECT id FROM items WHERE title = ? AND user_id = ? AND t2.id = $1 LIMIT ?",
+		redactSQL("SELECT id FROM items\n\t\tWHERE title = 'it''s secret' AND user_id = 42 AND t2.id = $1 LIMIT 10"))
+}
+
+func TestObserve(t *testing.T) {
+	log, hook := logtest.NewNullLogger()
+	db := &DB{logger: &logger.Logger{Logger: log}, slowQuery: 50 * time.Millisecond}
+	ctx := context.Background()
+	query := "SELECT id FROM test_observe WHERE external_id = 'abc'"
+
+	db.observe(ctx, query, time.Now(), nil)
+	db.observe(ctx, query, time.Now(), sql.ErrNoRows)
+	db.observe(ctx, query, time.Now(), errors.New("connection reset"))
+	assert.Equal(t, uint64(3), queryDuration.Count("select test_observe"))
+	assert.Equal(t, float64(1), queryErrors.Value("select test_observe"), "only the real failure is an error")
+	assert.Empty(t, hook.AllEntries())
+
+	db.observe(ctx, query, time.Now().Add(-time.Second), nil)
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, "Slow database query", entry.Message)
+	assert.Equal(t, "SELECT id FROM test_observe WHERE external_id = ?", entry.Data["sql"])
+	assert.GreaterOrEqual(t, entry.Data["duration_ms"], int64(1000))
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"api-gateway-backend/internal/tenant"
+)
+
+// RefreshOrderStats rebuilds order_stats, the order count and total amount
+// per tenant, day and status, from orders in one transaction, so readers
+// see either the previous or the new summaries. It returns the number of
+// rows written.
+func (db *DB) RefreshOrderStats(ctx context.Context) (int64, error) {
+	day := db.dialect.truncateDate("created_at", GroupByDay)
+	var written int64
+	err := db.WithTx(ctx, func(tx *Tx) error {
+		if _, err := tx.exec(ctx, `DELETE FROM order_stats`); err != nil {
+			return fmt.Errorf("failed to clear order stats: %w", err)
+		}
+		result, err := tx.exec(ctx, `
+			INSERT INTO order_stats (tenant_id, day, status, order_count, total_amount, computed_at)
+			SELECT tenant_id, `+day+`, status, COUNT(*), SUM(amount), ?
+			FROM orders
+			GROUP BY tenant_id, `+day+`, status
+		`, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("failed to write order stats: %w", err)
+		}
+		written, err = result.RowsAffected()
+		return err
+	})
+	return written, err
+}
+
+// GetOrderStatsSummary returns order count and total amount by status like
+// GetOrderStatusSummary, but summed from order_stats over the whole days
+// the filter's window touches, with when they were computed. Orders since
+// then are not counted. ErrNotFound is returned if order_stats has nothing
+// for the tenant.
+func (db *DB) GetOrderStatsSummary(ctx context.Context, filter OrderStatusFilter) ([]OrderStatusSummary, time.Time, error) {
+	id := tenant.From(ctx)
+
+	var computedAt sql.NullTime
+	err := db.QueryRowContext(ctx, `SELECT MAX(computed_at) FROM order_stats WHERE tenant_id = ?`, id).Scan(&computedAt)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if !computedAt.Valid {
+		return nil, time.Time{}, ErrNotFound
+	}
+
+	var period string
+	switch filter.GroupBy {
+	case "":
+	case GroupByDay:
+		period = "day"
+	case GroupByWeek:
+		period = db.dialect.truncateDate("day", GroupByWeek)
+	default:
+		return nil, time.Time{}, fmt.Errorf("unsupported grouping %q", filter.GroupBy)
+	}
+
+	columns := "status, SUM(order_count) as order_count, SUM(total_amount) as total_amount"
+	if period != "" {
+		columns = period + " as period_start, " + columns
+	}
+	query := `SELECT ` + columns + ` FROM order_stats WHERE tenant_id = ?`
+	args := []interface{}{id}
+	if filter.CreatedFrom != nil {
+		query += " AND day >= ?"
+		args = append(args, dateOf(*filter.CreatedFrom))
+	}
+	if filter.CreatedTo != nil {
+		query += " AND day <= ?"
+		args = append(args, dateOf(*filter.CreatedTo))
+	}
+	if period != "" {
+		query += " GROUP BY period_start, status ORDER BY period_start, total_amount DESC"
+	} else {
+		query += " GROUP BY status ORDER BY total_amount DESC"
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+
+	var summaries []OrderStatusSummary
+	for rows.Next() {
+		var summary OrderStatusSummary
+		dest := []interface{}{&summary.Status, &summary.OrderCount, &summary.TotalAmount}
+		if period != "" {
+			summary.PeriodStart = new(time.Time)
+			dest = append([]interface{}{summary.PeriodStart}, dest...)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, time.Time{}, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, computedAt.Time, rows.Err()
+}
+
+// dateOf returns the calendar day of t, as compared with a DATE column
+func dateOf(t time.Time) string {
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Outbox aggregates, the kind of row an outbox event is about
+const (
+	AggregateItem  = "item"
+	AggregateOrder = "order"
+)
+
+// Outbox event types
+const (
+	EventItemCreated  = "item.created"
+	EventItemUpdated  = "item.updated"
+	EventItemDeleted  = "item.deleted"
+	EventOrderCreated = "order.created"
+)
+
+// OutboxEvent is a change to an item or order, recorded in the same
+// transaction as the change and kept until the relay job has published it,
+// so every committed change is published at least once. Payload is the
+// Item or Order as written.
+type OutboxEvent struct {
+	ID          int64           `json:"id"`
+	TenantID    string          `json:"tenant_id"`
+	Aggregate   string          `json:"aggregate"`
+	AggregateID string          `json:"aggregate_id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// EnableOutbox makes item and order writes record their changes in
+// outbox_events, for the relay job to publish
+func (db *DB) EnableOutbox() {
+	db.outbox = true
+}
+
+// newOutboxEvent builds an event about the aggregate with the given ID,
+// carrying payload as JSON
+func newOutboxEvent(tenantID, aggregate, aggregateID, eventType string, payload interface{}) (OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxEvent{}, fmt.Errorf("failed to marshal outbox event: %w", err)
+	}
+	return OutboxEvent{
+		TenantID:    tenantID,
+		Aggregate:   aggregate,
+		AggregateID: aggregateID,
+		Type:        eventType,
+		Payload:     data,
+		CreatedAt:   time.Now().UTC(),
+	}, nil
+}
+
+// withItemEvents runs write, which upserts items in t, and records an
+// outbox event for each item it creates or changes. Items written again
+// unchanged get none, so a sync rewriting the same data publishes nothing.
+// Without the outbox it only runs write.
+func (t *Tx) withItemEvents(ctx context.Context, items []Item, write func() error) error {
+	if !t.db.outbox {
+		return write()
+	}
+
+	stored, err := t.db.getItemsByExternalIDs(ctx, t.query, itemExternalIDs(items))
+	if err != nil {
+		return fmt.Errorf("failed to load stored items: %w", err)
+	}
+	before := make(map[string]Item, len(stored))
+	for _, item := range stored {
+		before[item.ExternalID] = item
+	}
+
+	if err := write(); err != nil {
+		return err
+	}
+
+	var changed []Item
+	for _, item := range items {
+		if old, ok := before[item.ExternalID]; !ok || old.ChangedBy(item) {
+			changed = append(changed, item)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	return t.recordItemEvents(ctx, itemExternalIDs(changed), func(item Item) string {
+		if _, ok := before[item.ExternalID]; ok {
+			return EventItemUpdated
+		}
+		return EventItemCreated
+	})
+}
+
+// recordItemEvents loads the items with the given external IDs as written
+// in t and records an outbox event of the type eventType returns for each
+func (t *Tx) recordItemEvents(ctx context.Context, externalIDs []string, eventType func(Item) string) error {
+	current, err := t.db.getItemsByExternalIDs(ctx, t.query, externalIDs)
+	if err != nil {
+		return fmt.Errorf("failed to reload written items: %w", err)
+	}
+
+	events := make([]OutboxEvent, len(current))
+	for i, item := range current {
+		events[i], err = newOutboxEvent(item.TenantID, AggregateItem, item.ExternalID, eventType(item), item)
+		if err != nil {
+			return err
+		}
+	}
+	return t.addOutboxEvents(ctx, events)
+}
+
+// addOutboxEvents records events in t, in one statement
+func (t *Tx) addOutboxEvents(ctx context.Context, events []OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	rows := make([]string, len(events))
+	args := make([]interface{}, 0, len(events)*6)
+	for i, e := range events {
+		rows[i] = "(?, ?, ?, ?, ?, ?)"
+		args = append(args, e.TenantID, e.Aggregate, e.AggregateID, e.Type, string(e.Payload), e.CreatedAt)
+	}
+	query := `INSERT INTO outbox_events (tenant_id, aggregate, aggregate_id, event_type, payload, created_at) VALUES ` +
+		strings.Join(rows, ", ")
+	if _, err := t.exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to record outbox events: %w", err)
+	}
+	return nil
+}
+
+// ListOutboxEvents returns up to limit unpublished outbox events of every
+// tenant, oldest first
+func (db *DB) ListOutboxEvents(ctx context.Context, limit int) ([]OutboxEvent, error) {
+	query := `SELECT id, tenant_id, aggregate, aggregate_id, event_type, payload, created_at
+		FROM outbox_events ORDER BY id LIMIT ?`
+	rows, err := db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		var payload string
+		if err := rows.Scan(&e.ID, &e.TenantID, &e.Aggregate, &e.AggregateID, &e.Type, &payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Payload = json.RawMessage(payload)
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// DeleteOutboxEvents removes outbox events once they are published
+func (db *DB) DeleteOutboxEvents(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := `DELETE FROM outbox_events WHERE id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+	_, err := db.ExecContext(ctx, query, args...)
+	return err
+}
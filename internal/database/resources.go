@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxPlaceholders is MySQL's limit of placeholders per statement
+const maxPlaceholders = 65535
+
+// User is a user synced from the external API
+type User struct {
+	ID          int64     `json:"id"`
+	ExternalID  string    `json:"external_id"`
+	Name        string    `json:"name"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	Phone       string    `json:"phone"`
+	Website     string    `json:"website"`
+	CompanyName string    `json:"company_name"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Comment is a comment on a post synced from the external API. PostID is
+// the post's external ID, matching items.external_id.
+type Comment struct {
+	ID         int64     `json:"id"`
+	ExternalID string    `json:"external_id"`
+	PostID     int       `json:"post_id"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Todo is a todo synced from the external API
+type Todo struct {
+	ID         int64     `json:"id"`
+	ExternalID string    `json:"external_id"`
+	UserID     int       `json:"user_id"`
+	Title      string    `json:"title"`
+	Completed  bool      `json:"completed"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// UpsertUsers upserts users by external_id in chunks of at most chunkSize
+func (db *DB) UpsertUsers(ctx context.Context, users []User, chunkSize int) error {
+	rows := make([][]interface{}, len(users))
+	for i, u := range users {
+		rows[i] = []interface{}{u.ExternalID, u.Name, u.Username, u.Email, u.Phone, u.Website, u.CompanyName}
+	}
+	columns := []string{"external_id", "name", "username", "email", "phone", "website", "company_name"}
+	return db.upsertRows(ctx, "users", columns, rows, chunkSize)
+}
+
+// UpsertComments upserts comments by external_id in chunks of at most
+// chunkSize
+func (db *DB) UpsertComments(ctx context.Context, comments []Comment, chunkSize int) error {
+	rows := make([][]interface{}, len(comments))
+	for i, c := range comments {
+		rows[i] = []interface{}{c.ExternalID, c.PostID, c.Name, c.Email, c.Body}
+	}
+	columns := []string{"external_id", "post_id", "name", "email", "body"}
+	return db.upsertRows(ctx, "comments", columns, rows, chunkSize)
+}
+
+// UpsertTodos upserts todos by external_id in chunks of at most chunkSize
+func (db *DB) UpsertTodos(ctx context.Context, todos []Todo, chunkSize int) error {
+	rows := make([][]interface{}, len(todos))
+	for i, t := range todos {
+		rows[i] = []interface{}{t.ExternalID, t.UserID, t.Title, t.Completed}
+	}
+	columns := []string{"external_id", "user_id", "title", "completed"}
+	return db.upsertRows(ctx, "todos", columns, rows, chunkSize)
+}
+
+// upsertRows writes rows into table with multi-row upserts keyed on
+// external_id, which must be the first of columns. Like UpsertItemsBatch,
+// chunks are not wrapped in a transaction.
+func (db *DB) upsertRows(ctx context.Context, table string, columns []string, rows [][]interface{}, chunkSize int) error {
+	if limit := maxPlaceholders / len(columns); chunkSize <= 0 || chunkSize > limit {
+		chunkSize = limit
+	}
+
+	placeholders := "(" + strings.Repeat("?, ", len(columns)) + "NOW(), NOW())"
+	insert := `INSERT INTO ` + table + ` (` + strings.Join(columns, ", ") + `, created_at, updated_at) VALUES `
+	conflict := db.dialect.upsertClause(columns[0], columns[1:])
+
+	for start := 0; start < len(rows); start += chunkSize {
+		chunk := rows[start:min(start+chunkSize, len(rows))]
+
+		values := make([]string, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*len(columns))
+		for i, row := range chunk {
+			values[i] = placeholders
+			args = append(args, row...)
+		}
+
+		query := insert + strings.Join(values, ", ") + conflict
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to upsert %s %d-%d: %w", table, start, start+len(chunk)-1, err)
+		}
+	}
+
+	return nil
+}
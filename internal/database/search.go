@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"api-gateway-backend/internal/tenant"
+)
+
+// minFullTextTerm is the shortest word a full-text index holds, InnoDB's
+// default innodb_ft_min_token_size. A search without one falls back to
+// LIKE, as the index would match nothing.
+const minFullTextTerm = 3
+
+// ItemSearch is a full-text search over item titles and bodies
+type ItemSearch struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+// ItemMatch is an item found by a search and its relevance; higher is
+// more relevant
+type ItemMatch struct {
+	Item  Item    `json:"item"`
+	Score float64 `json:"score"`
+}
+
+// SearchItems returns the live items whose title or body match the
+// search, most relevant first. It uses the full-text index on items,
+// falling back to substring matching where there is none or the query has
+// no word long enough to be indexed.
+func (db *DB) SearchItems(ctx context.Context, search ItemSearch) ([]ItemMatch, error) {
+	if hasFullTextTerm(search.Query) {
+		matches, err := db.searchItems(ctx, search, db.dialect.fullTextMatch(), db.dialect.fullTextScore(), search.Query)
+		if !db.dialect.missingFullTextIndex(err) {
+			return matches, err
+		}
+	}
+
+	// Title matches rank above body matches
+	pattern := "%" + escapeLike(strings.ToLower(search.Query)) + "%"
+	return db.searchItems(ctx, search,
+		`(LOWER(title) LIKE ? OR LOWER(COALESCE(body, '')) LIKE ?)`,
+		`(CASE WHEN LOWER(title) LIKE ? THEN 2 ELSE 0 END + CASE WHEN LOWER(COALESCE(body, '')) LIKE ? THEN 1 ELSE 0 END)`,
+		pattern, pattern)
+}
+
+// searchItems runs a search for the items matching the match condition,
+// ordered by the score expression; both take args as their placeholders
+func (db *DB) searchItems(ctx context.Context, search ItemSearch, match, score string, args ...interface{}) ([]ItemMatch, error) {
+	query := `SELECT ` + itemColumns + `, ` + score + ` AS score FROM items
+		WHERE tenant_id = ? AND deleted_at IS NULL AND ` + match + `
+		ORDER BY score DESC, id DESC
+		LIMIT ? OFFSET ?`
+
+	params := append([]interface{}{}, args...)
+	params = append(params, tenant.From(ctx))
+	params = append(params, args...)
+	params = append(params, search.Limit, search.Offset)
+
+	rows, err := db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []ItemMatch
+	for rows.Next() {
+		var m ItemMatch
+		item, err := scanItem(scanWithScore{rows, &m.Score})
+		if err != nil {
+			return nil, err
+		}
+		m.Item = item
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// scanWithScore scans a search row into scanItem's destinations followed
+// by the score column
+type scanWithScore struct {
+	row   interface{ Scan(...interface{}) error }
+	score *float64
+}
+
+func (s scanWithScore) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.score)...)
+}
+
+// hasFullTextTerm reports whether q has a word long enough to be indexed
+func hasFullTextTerm(q string) bool {
+	for _, word := range strings.Fields(q) {
+		if utf8.RuneCountInString(word) >= minFullTextTerm {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeLike escapes the LIKE wildcards in s, so it matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
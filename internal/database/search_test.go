@@ -0,0 +1,46 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasFullTextTerm(t *testing.T) {
+	assert.True(t, hasFullTextTerm("go gopher"))
+	assert.True(t, hasFullTextTerm("été"))
+	assert.False(t, hasFullTextTerm("a to be"))
+	assert.False(t, hasFullTextTerm(""))
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `50\% off\_now \\ later`, escapeLike(`50% off_now \ later`))
+}
+
+func TestMissingFullTextIndex(t *testing.T) {
+	missing := fmt.Errorf("query: %w", &mysql.MySQLError{Number: errFullTextIndexMissing, Message: "Can't find FULLTEXT index matching the column list"})
+	assert.True(t, mysqlDialect{}.missingFullTextIndex(missing))
+	assert.False(t, mysqlDialect{}.missingFullTextIndex(&mysql.MySQLError{Number: 1064}))
+	assert.False(t, mysqlDialect{}.missingFullTextIndex(nil))
+	assert.False(t, postgresDialect{}.missingFullTextIndex(errors.New("syntax error")))
+}
+
+func TestScanWithScore(t *testing.T) {
+	var score float64
+	row := fakeRow(func(dest ...interface{}) error {
+		*dest[len(dest)-1].(*float64) = 1.5
+		assert.Len(t, dest, 10)
+		return nil
+	})
+	_, err := scanItem(scanWithScore{row, &score})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, score)
+}
+
+// fakeRow scans with a function
+type fakeRow func(dest ...interface{}) error
+
+func (f fakeRow) Scan(dest ...interface{}) error { return f(dest...) }
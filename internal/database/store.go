@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// Store is the set of database operations used by the API handlers,
+// background jobs and analytics. *DB implements it; tests and alternative
+// backends can provide their own.
+type Store interface {
+	PingContext(ctx context.Context) error
+	Close() error
+
+	// Items
+	UpsertItem(ctx context.Context, item *Item) error
+	UpsertItemsBatch(ctx context.Context, items []Item, chunkSize int) error
+	// WithTx runs fn in a transaction, committed if fn returns nil
+	WithTx(ctx context.Context, fn func(tx *Tx) error) error
+	GetAllItems(ctx context.Context) ([]Item, error)
+	GetItems(ctx context.Context, filter ItemFilter) ([]Item, error)
+	StreamItems(ctx context.Context, filter ItemFilter, fn func(Item) error) error
+	GetItemByID(ctx context.Context, id int64) (*Item, error)
+	GetItemsByExternalIDs(ctx context.Context, externalIDs []string) ([]Item, error)
+	SearchItems(ctx context.Context, search ItemSearch) ([]ItemMatch, error)
+	SoftDeleteMissingItems(ctx context.Context, seen []string) ([]string, error)
+
+	// Dead-lettered items
+	SaveFailedItem(ctx context.Context, item *FailedItem) error
+	ListFailedItems(ctx context.Context, limit, offset int) ([]FailedItem, error)
+	GetFailedItem(ctx context.Context, id int64) (*FailedItem, error)
+	DeleteFailedItem(ctx context.Context, id int64) error
+
+	// Outbox
+	ListOutboxEvents(ctx context.Context, limit int) ([]OutboxEvent, error)
+	DeleteOutboxEvents(ctx context.Context, ids []int64) error
+
+	// Webhooks
+	ApplyItemsWebhook(ctx context.Context, eventID string, items []Item) (bool, error)
+	CreateWebhookSubscription(ctx context.Context, sub *WebhookSubscription) error
+	ListWebhookSubscriptions(ctx context.Context) ([]WebhookSubscription, error)
+	DisableWebhookSubscription(ctx context.Context, id int64) (*WebhookSubscription, error)
+	EnqueueWebhookDeliveries(ctx context.Context, deliveries []WebhookDelivery) error
+	ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error)
+	FinishWebhookDeliveryAttempt(ctx context.Context, delivery *WebhookDelivery) error
+	ListWebhookDeliveries(ctx context.Context, subscriptionID int64, limit, offset int) ([]WebhookDelivery, error)
+
+	// Synced resources
+	UpsertUsers(ctx context.Context, users []User, chunkSize int) error
+	UpsertComments(ctx context.Context, comments []Comment, chunkSize int) error
+	UpsertTodos(ctx context.Context, todos []Todo, chunkSize int) error
+
+	// Orders
+	GetOrders(ctx context.Context, filter OrderFilter) ([]Order, error)
+	GetOrderByID(ctx context.Context, id int64) (*Order, error)
+	CreateOrder(ctx context.Context, order *Order) error
+
+	// Analytics
+	GetOrderStatusSummary(ctx context.Context, filter OrderStatusFilter) ([]OrderStatusSummary, error)
+	GetTopCustomers(ctx context.Context, filter TopCustomersFilter) ([]TopCustomer, error)
+	GetCustomerDetail(ctx context.Context, customerID string, since time.Time) (*CustomerDetail, error)
+	GetDailyOrderStatusTotals(ctx context.Context, days int) ([]DailyOrderStatusTotal, error)
+	GetItemStats(ctx context.Context, filter ItemStatsFilter) (*ItemStats, error)
+	GetCustomerTotals(ctx context.Context) ([]TopCustomer, error)
+	ListOrderTenants(ctx context.Context) ([]string, error)
+	RefreshOrderStats(ctx context.Context) (int64, error)
+	GetOrderStatsSummary(ctx context.Context, filter OrderStatusFilter) ([]OrderStatusSummary, time.Time, error)
+
+	// API keys
+	CreateAPIKey(ctx context.Context, key *APIKey) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context, tenantID string) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64, tenantID string) (*APIKey, error)
+
+	// Sync history
+	CreateSyncRun(ctx context.Context, run *SyncRun) error
+	FinishSyncRun(ctx context.Context, run *SyncRun) error
+	ListSyncRuns(ctx context.Context, limit, offset int) ([]SyncRun, error)
+
+	// Audit log
+	CreateAuditEvent(ctx context.Context, event *AuditEvent) error
+	ListAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error)
+}
+
+var _ Store = (*DB)(nil)
@@ -0,0 +1,258 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"    // gave up after the last attempt
+	DeliveryCancelled = "cancelled" // subscription disabled before delivery
+)
+
+// WebhookSubscription is a callback URL notified of data changes. The
+// secret signs each delivery and is only returned at creation.
+type WebhookSubscription struct {
+	ID         int64      `json:"id"`
+	URL        string     `json:"url"`
+	Secret     string     `json:"-"`
+	Events     []string   `json:"events,omitempty"` // empty means every event
+	CreatedAt  time.Time  `json:"created_at"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+}
+
+// Subscribed reports whether the subscription is active and wants eventType
+func (s *WebhookSubscription) Subscribed(eventType string) bool {
+	if s.DisabledAt != nil {
+		return false
+	}
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event queued for one subscription, and the
+// outcome of its latest attempt
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	SubscriptionID int64      `json:"subscription_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus *int       `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+
+	// Target of the delivery, set by ListDueWebhookDeliveries
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// CreateWebhookSubscription inserts a subscription and sets its ID and
+// CreatedAt
+func (db *DB) CreateWebhookSubscription(ctx context.Context, sub *WebhookSubscription) error {
+	sub.CreatedAt = time.Now()
+
+	query := `INSERT INTO webhook_subscriptions (url, secret, events, created_at) VALUES (?, ?, ?, ?)`
+	id, err := db.insert(ctx, query, sub.URL, sub.Secret, strings.Join(sub.Events, ","), sub.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	sub.ID = id
+	return nil
+}
+
+// ListWebhookSubscriptions retrieves all subscriptions, including disabled
+// ones, newest first
+func (db *DB) ListWebhookSubscriptions(ctx context.Context) ([]WebhookSubscription, error) {
+	query := `SELECT id, url, secret, events, created_at, disabled_at FROM webhook_subscriptions ORDER BY created_at DESC, id DESC`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []WebhookSubscription
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+
+	return subs, rows.Err()
+}
+
+// DisableWebhookSubscription stops notifying a subscription, cancels its
+// pending deliveries and returns it, or ErrNotFound if no active
+// subscription has that ID
+func (db *DB) DisableWebhookSubscription(ctx context.Context, id int64) (*WebhookSubscription, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	disable := `UPDATE webhook_subscriptions SET disabled_at = ? WHERE id = ? AND disabled_at IS NULL`
+	result, err := db.txExecContext(ctx, tx, disable, time.Now(), id)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrNotFound
+	}
+
+	cancel := `UPDATE webhook_deliveries SET status = ? WHERE subscription_id = ? AND status = ?`
+	if _, err := db.txExecContext(ctx, tx, cancel, DeliveryCancelled, id, DeliveryPending); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, url, secret, events, created_at, disabled_at FROM webhook_subscriptions WHERE id = ?`
+	return scanWebhookSubscription(db.QueryRowContext(ctx, query, id))
+}
+
+// EnqueueWebhookDeliveries inserts pending deliveries, setting their IDs
+func (db *DB) EnqueueWebhookDeliveries(ctx context.Context, deliveries []WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, 0, ?, ?)
+	`
+	for i := range deliveries {
+		d := &deliveries[i]
+		d.Status = DeliveryPending
+		d.CreatedAt = time.Now()
+		if d.NextAttemptAt.IsZero() {
+			d.NextAttemptAt = d.CreatedAt
+		}
+
+		id, err := db.insert(ctx, query, d.SubscriptionID, d.EventID, d.EventType, d.Payload, d.Status, d.NextAttemptAt, d.CreatedAt)
+		if err != nil {
+			return err
+		}
+		d.ID = id
+	}
+	return nil
+}
+
+// ListDueWebhookDeliveries retrieves up to limit pending deliveries whose
+// next attempt is due at now, oldest first, with their target URL and
+// secret
+func (db *DB) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	query := `
+		SELECT d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+			d.response_status, d.last_error, d.next_attempt_at, d.created_at, d.delivered_at, s.url, s.secret
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.status = ? AND d.next_attempt_at <= ? AND s.disabled_at IS NULL
+		ORDER BY d.next_attempt_at, d.id
+		LIMIT ?
+	`
+	return db.queryWebhookDeliveries(ctx, query, true, DeliveryPending, now, limit)
+}
+
+// FinishWebhookDeliveryAttempt stores the outcome of a delivery attempt
+func (db *DB) FinishWebhookDeliveryAttempt(ctx context.Context, d *WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, response_status = ?, last_error = ?, next_attempt_at = ?, delivered_at = ?
+		WHERE id = ?
+	`
+	_, err := db.ExecContext(ctx, query, d.Status, d.Attempts, d.ResponseStatus, d.LastError, d.NextAttemptAt, d.DeliveredAt, d.ID)
+	return err
+}
+
+// ListWebhookDeliveries retrieves a subscription's deliveries, most recent
+// first
+func (db *DB) ListWebhookDeliveries(ctx context.Context, subscriptionID int64, limit, offset int) ([]WebhookDelivery, error) {
+	query := `
+		SELECT id, subscription_id, event_id, event_type, payload, status, attempts,
+			response_status, last_error, next_attempt_at, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE subscription_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+	return db.queryWebhookDeliveries(ctx, query, false, subscriptionID, limit, offset)
+}
+
+// queryWebhookDeliveries runs a webhook_deliveries query, also scanning
+// the subscription's url and secret when withTarget is set
+func (db *DB) queryWebhookDeliveries(ctx context.Context, query string, withTarget bool, args ...interface{}) ([]WebhookDelivery, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		var responseStatus sql.NullInt64
+		var lastError sql.NullString
+		var deliveredAt sql.NullTime
+		dest := []interface{}{&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+			&responseStatus, &lastError, &d.NextAttemptAt, &d.CreatedAt, &deliveredAt}
+		if withTarget {
+			dest = append(dest, &d.URL, &d.Secret)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if responseStatus.Valid {
+			status := int(responseStatus.Int64)
+			d.ResponseStatus = &status
+		}
+		d.LastError = lastError.String
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// scanWebhookSubscription scans a single webhook_subscriptions row
+func scanWebhookSubscription(row interface{ Scan(...interface{}) error }) (*WebhookSubscription, error) {
+	var sub WebhookSubscription
+	var events string
+	var disabledAt sql.NullTime
+	err := row.Scan(&sub.ID, &sub.URL, &sub.Secret, &events, &sub.CreatedAt, &disabledAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if events != "" {
+		sub.Events = strings.Split(events, ",")
+	}
+	if disabledAt.Valid {
+		sub.DisabledAt = &disabledAt.Time
+	}
+	return &sub, nil
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// SyncRun is the persisted record of one data sync run
+type SyncRun struct {
+	ID           int64      `json:"id"`
+	Trigger      string     `json:"trigger"`
+	RequestID    string     `json:"request_id"`
+	Status       string     `json:"status"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	SuccessCount int        `json:"success_count"`
+	ErrorCount   int        `json:"error_count"`
+	Errors       []string   `json:"errors,omitempty"`
+}
+
+// CreateSyncRun inserts a sync run when it starts and sets its ID
+func (db *DB) CreateSyncRun(ctx context.Context, run *SyncRun) error {
+	query := `INSERT INTO sync_runs (trigger_type, request_id, status, started_at) VALUES (?, ?, ?, ?)`
+	id, err := db.insert(ctx, query, run.Trigger, run.RequestID, run.Status, run.StartedAt)
+	if err != nil {
+		return err
+	}
+
+	run.ID = id
+	return nil
+}
+
+// FinishSyncRun stores the outcome of a sync run
+func (db *DB) FinishSyncRun(ctx context.Context, run *SyncRun) error {
+	errs, err := json.Marshal(run.Errors)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE sync_runs
+		SET status = ?, finished_at = ?, success_count = ?, error_count = ?, errors = ?
+		WHERE id = ?
+	`
+	_, err = db.ExecContext(ctx, query, run.Status, run.FinishedAt, run.SuccessCount, run.ErrorCount, string(errs), run.ID)
+	return err
+}
+
+// ListSyncRuns retrieves sync runs, most recent first
+func (db *DB) ListSyncRuns(ctx context.Context, limit, offset int) ([]SyncRun, error) {
+	query := `
+		SELECT id, trigger_type, request_id, status, started_at, finished_at, success_count, error_count, errors
+		FROM sync_runs
+		ORDER BY started_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []SyncRun
+	for rows.Next() {
+		var run SyncRun
+		var finishedAt sql.NullTime
+		var errs sql.NullString
+		if err := rows.Scan(&run.ID, &run.Trigger, &run.RequestID, &run.Status, &run.StartedAt,
+			&finishedAt, &run.SuccessCount, &run.ErrorCount, &errs); err != nil {
+			return nil, err
+		}
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+		if errs.Valid && errs.String != "" {
+			if err := json.Unmarshal([]byte(errs.String), &run.Errors); err != nil {
+				return nil, err
+			}
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ItemWriter writes items, either straight to the database (*DB) or within
+// a transaction (*Tx)
+type ItemWriter interface {
+	UpsertItem(ctx context.Context, item *Item) error
+	UpsertItemsBatch(ctx context.Context, items []Item, chunkSize int) error
+}
+
+var (
+	_ ItemWriter = (*DB)(nil)
+	_ ItemWriter = (*Tx)(nil)
+)
+
+// Tx is a transaction opened by WithTx. Each write runs in a savepoint, so
+// a failed one is undone on its own and the transaction carries on, as
+// PostgreSQL otherwise aborts a transaction at its first error. A Tx holds
+// one connection and must not be used by more than one goroutine.
+type Tx struct {
+	db *DB
+	tx *sql.Tx
+}
+
+// WithTx runs fn in a transaction, committing it if fn returns nil and
+// rolling it back otherwise
+func (db *DB) WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(&Tx{db: db, tx: tx}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UpsertItem inserts or updates an item within the transaction
+func (t *Tx) UpsertItem(ctx context.Context, item *Item) error {
+	return t.savepoint(ctx, func() error {
+		return t.withItemEvents(ctx, []Item{*item}, func() error {
+			return t.db.upsertItem(ctx, t.exec, item)
+		})
+	})
+}
+
+// UpsertItemsBatch upserts items within the transaction like
+// DB.UpsertItemsBatch, except that on error none of them are written
+func (t *Tx) UpsertItemsBatch(ctx context.Context, items []Item, chunkSize int) error {
+	return t.savepoint(ctx, func() error {
+		return forEachItemChunk(items, chunkSize, func(chunk []Item) error {
+			return t.withItemEvents(ctx, chunk, func() error {
+				return t.db.upsertItemsChunk(ctx, t.exec, chunk)
+			})
+		})
+	})
+}
+
+// exec executes a statement written with "?" placeholders
+func (t *Tx) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.db.txExecContext(ctx, t.tx, query, args...)
+}
+
+// query runs a query written with "?" placeholders
+func (t *Tx) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.db.txQueryContext(ctx, t.tx, query, args...)
+}
+
+// insert executes an INSERT and returns the new row's ID
+func (t *Tx) insert(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	if returning := t.db.dialect.returningID(); returning != "" {
+		var id int64
+		err := t.db.txQueryRowContext(ctx, t.tx, query+returning, args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := t.exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// savepoint runs fn, rolling back only its statements if it fails
+func (t *Tx) savepoint(ctx context.Context, fn func() error) error {
+	if _, err := t.tx.ExecContext(ctx, "SAVEPOINT item_write"); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if _, rollbackErr := t.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT item_write"); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+	_, err := t.tx.ExecContext(ctx, "RELEASE SAVEPOINT item_write")
+	return err
+}
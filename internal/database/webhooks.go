@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// webhookSourceItems is the webhook_events source for item deliveries
+const webhookSourceItems = "items"
+
+// ApplyItemsWebhook upserts items pushed by a webhook delivery and records
+// eventID in the same transaction, so a redelivered event is skipped while
+// a delivery that failed part way can be retried. With the outbox, the
+// items' events are recorded in the transaction too. It reports whether
+// the event was applied, or false if it had been applied before.
+func (db *DB) ApplyItemsWebhook(ctx context.Context, eventID string, items []Item) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Concurrent deliveries of the same event block on the primary key
+	// here until the first commits, then fail as duplicates
+	insert := `INSERT INTO webhook_events (event_id, source, item_count) VALUES (?, ?, ?)`
+	if _, err := db.txExecContext(ctx, tx, insert, eventID, webhookSourceItems, len(items)); err != nil {
+		tx.Rollback()
+		if seen, lookupErr := db.webhookEventExists(ctx, eventID); lookupErr == nil && seen {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to record webhook event: %w", err)
+	}
+
+	if len(items) > 0 {
+		t := &Tx{db: db, tx: tx}
+		err := t.withItemEvents(ctx, items, func() error {
+			return db.upsertItemsChunk(ctx, t.exec, items)
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to upsert items: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// webhookEventExists reports whether eventID has already been recorded
+func (db *DB) webhookEventExists(ctx context.Context, eventID string) (bool, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM webhook_events WHERE event_id = ?`, eventID).Scan(&n)
+	return n > 0, err
+}
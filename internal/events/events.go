@@ -0,0 +1,101 @@
+// Package events publishes item changes over Redis pub/sub and fans them
+// out to live subscribers on every instance.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"api-gateway-backend/internal/database"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// ItemsChannel is the Redis pub/sub channel item events are published on
+const ItemsChannel = "events:items"
+
+// Item event types
+const (
+	ItemCreated = database.EventItemCreated
+	ItemUpdated = database.EventItemUpdated
+	ItemDeleted = database.EventItemDeleted // only published through the outbox
+)
+
+// ItemEvent reports that an item was created or changed
+type ItemEvent struct {
+	Type      string        `json:"type"`
+	Item      database.Item `json:"item"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// ItemStore looks up stored items, to tell new items from changed ones
+type ItemStore interface {
+	GetItemsByExternalIDs(ctx context.Context, externalIDs []string) ([]database.Item, error)
+}
+
+// Publisher publishes messages on a Redis pub/sub channel
+type Publisher interface {
+	Publish(ctx context.Context, channel string, message interface{}) *goredis.IntCmd
+}
+
+// Snapshot loads the stored versions of items by external ID. Call it
+// before writing items and pass the result to PublishChanges afterwards.
+func Snapshot(ctx context.Context, db ItemStore, items []database.Item) (map[string]database.Item, error) {
+	stored, err := db.GetItemsByExternalIDs(ctx, externalIDs(items))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored items: %w", err)
+	}
+
+	before := make(map[string]database.Item, len(stored))
+	for _, item := range stored {
+		before[item.ExternalID] = item
+	}
+	return before, nil
+}
+
+// PublishChanges publishes an event for each of the written items that is
+// new or differs from its version in before. Changed items are reloaded so
+// events carry their IDs and timestamps. It returns the number published.
+func PublishChanges(ctx context.Context, db ItemStore, pub Publisher, before map[string]database.Item, written []database.Item) (int, error) {
+	var changed []database.Item
+	for _, item := range written {
+		if old, ok := before[item.ExternalID]; !ok || old.ChangedBy(item) {
+			changed = append(changed, item)
+		}
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+
+	current, err := db.GetItemsByExternalIDs(ctx, externalIDs(changed))
+	if err != nil {
+		return 0, fmt.Errorf("failed to reload changed items: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for i, item := range current {
+		event := ItemEvent{Type: ItemUpdated, Item: item, Timestamp: now}
+		if _, ok := before[item.ExternalID]; !ok {
+			event.Type = ItemCreated
+		}
+
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return i, err
+		}
+		if err := pub.Publish(ctx, ItemsChannel, payload).Err(); err != nil {
+			return i, fmt.Errorf("failed to publish item event: %w", err)
+		}
+	}
+	return len(current), nil
+}
+
+func externalIDs(items []database.Item) []string {
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ExternalID
+	}
+	return ids
+}
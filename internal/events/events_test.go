@@ -0,0 +1,238 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/tenant"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore serves items by external ID
+type fakeStore struct {
+	items   map[string]database.Item
+	lookups [][]string
+}
+
+func (s *fakeStore) GetItemsByExternalIDs(ctx context.Context, externalIDs []string) ([]database.Item, error) {
+	s.lookups = append(s.lookups, externalIDs)
+	var items []database.Item
+	for _, id := range externalIDs {
+		if item, ok := s.items[id]; ok {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// fakePublisher records published messages
+type fakePublisher struct {
+	messages []ItemEvent
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, channel string, message interface{}) *goredis.IntCmd {
+	var event ItemEvent
+	if err := json.Unmarshal(message.([]byte), &event); err != nil {
+		return goredis.NewIntResult(0, err)
+	}
+	p.messages = append(p.messages, event)
+	return goredis.NewIntResult(1, nil)
+}
+
+func TestPublishChanges(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{items: map[string]database.Item{
+		"1": {ID: 10, ExternalID: "1", Title: "Same", UserID: 1},
+		"2": {ID: 20, ExternalID: "2", Title: "Old", UserID: 1},
+	}}
+
+	written := []database.Item{
+		{ExternalID: "1", Title: "Same", UserID: 1},
+		{ExternalID: "2", Title: "New", UserID: 1},
+		{ExternalID: "3", Title: "Added", UserID: 2},
+	}
+	before, err := Snapshot(ctx, store, written)
+	require.NoError(t, err)
+	assert.Len(t, before, 2)
+
+	// Apply the write
+	store.items["2"] = database.Item{ID: 20, ExternalID: "2", Title: "New", UserID: 1}
+	store.items["3"] = database.Item{ID: 30, ExternalID: "3", Title: "Added", UserID: 2}
+
+	pub := &fakePublisher{}
+	published, err := PublishChanges(ctx, store, pub, before, written)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+
+	// Unchanged items aren't reloaded or published
+	assert.Equal(t, []string{"2", "3"}, store.lookups[1])
+	require.Len(t, pub.messages, 2)
+	assert.Equal(t, ItemUpdated, pub.messages[0].Type)
+	assert.Equal(t, int64(20), pub.messages[0].Item.ID)
+	assert.Equal(t, ItemCreated, pub.messages[1].Type)
+	assert.Equal(t, int64(30), pub.messages[1].Item.ID)
+}
+
+func TestPublishChanges_NothingChanged(t *testing.T) {
+	item := database.Item{ID: 10, ExternalID: "1", Title: "Same"}
+	store := &fakeStore{}
+	pub := &fakePublisher{}
+
+	published, err := PublishChanges(context.Background(), store, pub, map[string]database.Item{"1": item}, []database.Item{item})
+	require.NoError(t, err)
+	assert.Equal(t, 0, published)
+	assert.Empty(t, store.lookups)
+	assert.Empty(t, pub.messages)
+}
+
+func TestPublishChanges_Restored(t *testing.T) {
+	deletedAt := time.Now()
+	stored := database.Item{ID: 10, ExternalID: "1", Title: "Same", DeletedAt: &deletedAt}
+	store := &fakeStore{items: map[string]database.Item{"1": {ID: 10, ExternalID: "1", Title: "Same"}}}
+	pub := &fakePublisher{}
+
+	// The upstream returns a deleted item again, unchanged
+	published, err := PublishChanges(context.Background(), store, pub, map[string]database.Item{"1": stored}, []database.Item{{ExternalID: "1", Title: "Same"}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	require.Len(t, pub.messages, 1)
+	assert.Equal(t, ItemUpdated, pub.messages[0].Type)
+	assert.Nil(t, pub.messages[0].Item.DeletedAt)
+}
+
+func TestHub_FiltersByUser(t *testing.T) {
+	hub := NewHub(logger.New())
+	all := hub.Subscribe(tenant.Default, nil)
+	user2 := hub.Subscribe(tenant.Default, []int{2})
+
+	hub.Broadcast(ItemEvent{Item: database.Item{ID: 1, UserID: 1}})
+	hub.Broadcast(ItemEvent{Item: database.Item{ID: 2, UserID: 2}})
+
+	assert.Len(t, all.Events(), 2)
+	require.Len(t, user2.Events(), 1)
+	assert.Equal(t, int64(2), (<-user2.Events()).Item.ID)
+
+	user2.SetUserIDs([]int{3, 1})
+	assert.Equal(t, []int{1, 3}, user2.UserIDs())
+	hub.Broadcast(ItemEvent{Item: database.Item{ID: 3, UserID: 1}})
+	assert.Len(t, user2.Events(), 1)
+
+	hub.Unsubscribe(user2)
+	hub.Unsubscribe(user2)
+	<-user2.Events()
+	_, open := <-user2.Events()
+	assert.False(t, open)
+}
+
+func TestHub_FiltersByTenant(t *testing.T) {
+	hub := NewHub(logger.New())
+	def := hub.Subscribe(tenant.Default, nil)
+	acme := hub.Subscribe("acme", nil)
+
+	hub.Broadcast(ItemEvent{Item: database.Item{ID: 1}})
+	hub.Broadcast(ItemEvent{Item: database.Item{ID: 2, TenantID: tenant.Default}})
+	hub.Broadcast(ItemEvent{Item: database.Item{ID: 3, TenantID: "acme"}})
+
+	assert.Len(t, def.Events(), 2)
+	require.Len(t, acme.Events(), 1)
+	assert.Equal(t, int64(3), (<-acme.Events()).Item.ID)
+}
+
+func TestHub_DropsSlowSubscribers(t *testing.T) {
+	hub := NewHub(logger.New())
+	slow := hub.Subscribe(tenant.Default, nil)
+
+	for i := 0; i <= subscriptionBuffer; i++ {
+		hub.Broadcast(ItemEvent{Item: database.Item{ID: int64(i)}})
+	}
+
+	// The buffered events are still readable, then the channel is closed
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	assert.Equal(t, subscriptionBuffer, received)
+}
+
+func TestHub_Close(t *testing.T) {
+	hub := NewHub(logger.New())
+	sub := hub.Subscribe(tenant.Default, nil)
+	hub.Close()
+
+	_, open := <-sub.Events()
+	assert.False(t, open)
+
+	// Subscribing after Close yields a closed subscription
+	_, open = <-hub.Subscribe(tenant.Default, nil).Events()
+	assert.False(t, open)
+}
+
+func TestFromOutbox(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	msg, err := FromOutbox(database.OutboxEvent{
+		ID: 1, TenantID: "acme", Aggregate: database.AggregateItem, AggregateID: "7",
+		Type: ItemDeleted, Payload: []byte(`{"id":11,"external_id":"7","user_id":2}`), CreatedAt: createdAt,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ItemsChannel, msg.Channel)
+	assert.Equal(t, "acme:7", msg.Key)
+	var itemEvent ItemEvent
+	require.NoError(t, json.Unmarshal(msg.Payload, &itemEvent))
+	assert.Equal(t, ItemDeleted, itemEvent.Type)
+	assert.Equal(t, int64(11), itemEvent.Item.ID)
+	assert.Equal(t, 2, itemEvent.Item.UserID)
+	assert.True(t, createdAt.Equal(itemEvent.Timestamp))
+
+	msg, err = FromOutbox(database.OutboxEvent{
+		ID: 2, Aggregate: database.AggregateOrder, AggregateID: "12",
+		Type: OrderCreated, Payload: []byte(`{"id":12,"status":"PAID"}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, OrdersChannel, msg.Channel)
+	var orderEvent OrderEvent
+	require.NoError(t, json.Unmarshal(msg.Payload, &orderEvent))
+	assert.Equal(t, "PAID", orderEvent.Order.Status)
+
+	_, err = FromOutbox(database.OutboxEvent{ID: 3, Aggregate: "invoice"})
+	assert.Error(t, err)
+}
+
+func TestKafkaSink(t *testing.T) {
+	var topics []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, kafkaContentType, r.Header.Get("Content-Type"))
+		topics = append(topics, r.URL.Path)
+		if r.URL.Path == "/topics/events.orders" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error_code":50001,"message":"broker unavailable"}`))
+			return
+		}
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":1},{"partition":0,"offset":2}]}`))
+	}))
+	defer server.Close()
+
+	sink := NewKafkaSink(server.URL+"/", time.Second)
+	messages := []Message{
+		{Channel: ItemsChannel, Key: "default:1", Payload: []byte(`{}`)},
+		{Channel: ItemsChannel, Key: "default:2", Payload: []byte(`{}`)},
+		{Channel: OrdersChannel, Key: "default:3", Payload: []byte(`{}`)},
+		{Channel: ItemsChannel, Key: "default:4", Payload: []byte(`{}`)},
+	}
+
+	// Consecutive messages for a channel go in one request, and a failure
+	// stops the rest so order is kept
+	sent, err := sink.Publish(context.Background(), messages)
+	assert.Equal(t, 2, sent)
+	assert.ErrorContains(t, err, "broker unavailable")
+	assert.Equal(t, []string{"/topics/events.items", "/topics/events.orders"}, topics)
+}
@@ -0,0 +1,176 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/tenant"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// subscriptionBuffer is how many events a subscriber may fall behind
+// before it is dropped
+const subscriptionBuffer = 64
+
+// Subscriber subscribes to Redis pub/sub channels
+type Subscriber interface {
+	Subscribe(ctx context.Context, channels ...string) *goredis.PubSub
+}
+
+// Hub relays item events received from Redis to this instance's live
+// subscribers. Each instance runs one Redis subscription however many
+// clients are connected.
+type Hub struct {
+	logger *logger.Logger
+
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription receives the item events of its tenant matching its user
+// filter. Events is closed when the subscriber falls too far behind or the
+// hub closes.
+type Subscription struct {
+	events chan ItemEvent
+	tenant string
+
+	mu      sync.Mutex
+	userIDs map[int]bool // empty means every user
+}
+
+// NewHub creates a hub with no subscribers
+func NewHub(log *logger.Logger) *Hub {
+	return &Hub{logger: log, subs: make(map[*Subscription]struct{})}
+}
+
+// Run relays events published on ItemsChannel until ctx is done. go-redis
+// resubscribes on its own if the connection drops.
+func (h *Hub) Run(ctx context.Context, rdb Subscriber) {
+	pubsub := rdb.Subscribe(ctx, ItemsChannel)
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var event ItemEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				h.logger.WithError(err).Warn("Ignoring malformed item event")
+				continue
+			}
+			h.Broadcast(event)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for the events of tenantID's items of
+// userIDs, or of every user if none are given
+func (h *Hub) Subscribe(tenantID string, userIDs []int) *Subscription {
+	sub := &Subscription{events: make(chan ItemEvent, subscriptionBuffer), tenant: tenantID}
+	sub.SetUserIDs(userIDs)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(sub.events)
+		return sub
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes a subscriber. It is safe to call more than once.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Broadcast delivers event to every subscriber that wants it. A subscriber
+// whose buffer is full is dropped rather than blocking the others.
+func (h *Hub) Broadcast(event ItemEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		if !sub.wants(event.Item) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			h.logger.Warn("Dropping live subscriber that fell behind")
+			delete(h.subs, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Close drops every subscriber, so their connections end, and rejects new
+// ones
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Events returns the channel events are delivered on
+func (s *Subscription) Events() <-chan ItemEvent {
+	return s.events
+}
+
+// SetUserIDs replaces the user filter; no IDs means every user
+func (s *Subscription) SetUserIDs(userIDs []int) {
+	filter := make(map[int]bool, len(userIDs))
+	for _, id := range userIDs {
+		filter[id] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userIDs = filter
+}
+
+// UserIDs returns the user filter in ascending order, empty for every user
+func (s *Subscription) UserIDs() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int, 0, len(s.userIDs))
+	for id := range s.userIDs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (s *Subscription) wants(item database.Item) bool {
+	if itemTenant := item.TenantID; itemTenant != s.tenant && (itemTenant != "" || s.tenant != tenant.Default) {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.userIDs) == 0 || s.userIDs[item.UserID]
+}
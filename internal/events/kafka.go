@@ -0,0 +1,114 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// kafkaContentType is the Kafka REST Proxy v2 content type for records
+// with JSON keys and values
+const kafkaContentType = "application/vnd.kafka.json.v2+json"
+
+// KafkaSink produces messages to Kafka through a Kafka REST Proxy, one
+// topic per channel, e.g. events.items for ItemsChannel
+type KafkaSink struct {
+	client *http.Client
+	url    string
+}
+
+// NewKafkaSink creates a sink producing through the REST Proxy at baseURL
+func NewKafkaSink(baseURL string, timeout time.Duration) *KafkaSink {
+	return &KafkaSink{
+		client: &http.Client{Timeout: timeout},
+		url:    strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// KafkaTopic returns the topic a channel's messages are produced to, as
+// topic names can't contain colons
+func KafkaTopic(channel string) string {
+	return strings.ReplaceAll(channel, ":", ".")
+}
+
+// Publish produces each run of consecutive messages for the same channel
+// in one request, so their order is kept
+func (s *KafkaSink) Publish(ctx context.Context, messages []Message) (int, error) {
+	sent := 0
+	for sent < len(messages) {
+		end := sent + 1
+		for end < len(messages) && messages[end].Channel == messages[sent].Channel {
+			end++
+		}
+		if err := s.produce(ctx, KafkaTopic(messages[sent].Channel), messages[sent:end]); err != nil {
+			return sent, err
+		}
+		sent = end
+	}
+	return sent, nil
+}
+
+// kafkaRecord is a record in a REST Proxy produce request
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// produce sends messages to topic in one request. Any record the proxy
+// reports as failed fails the whole request, so it is sent again.
+func (s *KafkaSink) produce(ctx context.Context, topic string, messages []Message) error {
+	records := make([]kafkaRecord, len(messages))
+	for i, msg := range messages {
+		records[i] = kafkaRecord{Key: msg.Key, Value: msg.Payload}
+	}
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url+"/topics/"+url.PathEscape(topic), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var payload struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(data, &payload)
+		return fmt.Errorf("kafka: producing to %q returned %d: %s", topic, resp.StatusCode, payload.Message)
+	}
+
+	var payload struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return fmt.Errorf("kafka: invalid response for %q: %w", topic, err)
+	}
+	for _, offset := range payload.Offsets {
+		if offset.Error != "" {
+			return fmt.Errorf("kafka: producing to %q failed: %s", topic, offset.Error)
+		}
+	}
+	return nil
+}
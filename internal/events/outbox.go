@@ -0,0 +1,94 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"api-gateway-backend/internal/database"
+)
+
+// OrdersChannel is the Redis pub/sub channel order events are published on
+const OrdersChannel = "events:orders"
+
+// Order event types
+const (
+	OrderCreated = database.EventOrderCreated
+)
+
+// OrderEvent reports that an order was created
+type OrderEvent struct {
+	Type      string         `json:"type"`
+	Order     database.Order `json:"order"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// Message is an outbox event ready to publish on Channel. Key identifies
+// the item or order it is about, so Kafka keeps its events in order.
+type Message struct {
+	Channel string
+	Key     string
+	Payload []byte
+}
+
+// FromOutbox returns the message an outbox event is published as: an
+// ItemEvent on ItemsChannel or an OrderEvent on OrdersChannel, timestamped
+// when the change was made
+func FromOutbox(e database.OutboxEvent) (Message, error) {
+	msg := Message{Key: e.TenantID + ":" + e.AggregateID}
+
+	var event interface{}
+	switch e.Aggregate {
+	case database.AggregateItem:
+		var item database.Item
+		if err := json.Unmarshal(e.Payload, &item); err != nil {
+			return Message{}, fmt.Errorf("invalid item in outbox event %d: %w", e.ID, err)
+		}
+		msg.Channel = ItemsChannel
+		event = ItemEvent{Type: e.Type, Item: item, Timestamp: e.CreatedAt}
+	case database.AggregateOrder:
+		var order database.Order
+		if err := json.Unmarshal(e.Payload, &order); err != nil {
+			return Message{}, fmt.Errorf("invalid order in outbox event %d: %w", e.ID, err)
+		}
+		msg.Channel = OrdersChannel
+		event = OrderEvent{Type: e.Type, Order: order, Timestamp: e.CreatedAt}
+	default:
+		return Message{}, fmt.Errorf("outbox event %d: unknown aggregate %q", e.ID, e.Aggregate)
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return Message{}, err
+	}
+	msg.Payload = payload
+	return msg, nil
+}
+
+// Sink publishes relayed outbox messages in order. It returns how many of
+// messages it published before failing, all of them if the error is nil.
+type Sink interface {
+	Publish(ctx context.Context, messages []Message) (int, error)
+}
+
+// RedisSink publishes messages on Redis pub/sub, where the hub and other
+// subscribers receive them
+type RedisSink struct {
+	pub Publisher
+}
+
+// NewRedisSink creates a sink publishing through pub
+func NewRedisSink(pub Publisher) *RedisSink {
+	return &RedisSink{pub: pub}
+}
+
+// Publish publishes each message on its channel
+func (s *RedisSink) Publish(ctx context.Context, messages []Message) (int, error) {
+	for i, msg := range messages {
+		if err := s.pub.Publish(ctx, msg.Channel, msg.Payload).Err(); err != nil {
+			return i, fmt.Errorf("failed to publish to %s: %w", msg.Channel, err)
+		}
+	}
+	return len(messages), nil
+}
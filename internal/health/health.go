@@ -0,0 +1,185 @@
+// Package health keeps a rolling history of dependency checks in memory,
+// so the readiness probe can report how each dependency has been doing and
+// request handlers can avoid one that is known to be down instead of
+// waiting for it to time out on every request.
+package health
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Dependencies checked by the readiness probe
+const (
+	Database = "database"
+	Redis    = "redis"
+)
+
+// Dependency states
+const (
+	StateUp      = "up"
+	StateDown    = "down"
+	StateUnknown = "unknown" // not checked yet
+)
+
+// Upstream is the dependency name of the upstream API called name
+func Upstream(name string) string {
+	return "upstream:" + name
+}
+
+// Status summarizes the recent checks of one dependency
+type Status struct {
+	Name                string     `json:"name"`
+	State               string     `json:"state"`
+	Checks              int        `json:"checks"`       // in the window
+	SuccessRate         float64    `json:"success_rate"` // of the checks in the window, 0-1
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LatencyMS           float64    `json:"latency_ms"` // of the last check
+	LastError           string     `json:"last_error,omitempty"`
+	LastCheckedAt       *time.Time `json:"last_checked_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+}
+
+// Tracker records the outcome of the last checks of each dependency. A
+// dependency is down once its last downAfter checks failed, and up again
+// with its first success. A nil Tracker records nothing and reports every
+// dependency up.
+type Tracker struct {
+	window    int
+	downAfter int
+	now       func() time.Time
+
+	mu   sync.Mutex
+	deps map[string]*history
+}
+
+// history is the rolling window of one dependency's checks
+type history struct {
+	results             []bool // ring of the last window results, true for success
+	next                int    // where the next result goes
+	consecutiveFailures int
+	latency             time.Duration
+	lastError           string
+	lastCheckedAt       time.Time
+	lastSuccessAt       time.Time
+}
+
+// NewTracker creates a tracker keeping the last window checks of each
+// dependency, reporting one down after downAfter failures in a row.
+// Values below 1 are treated as 1.
+func NewTracker(window, downAfter int) *Tracker {
+	return &Tracker{
+		window:    max(window, 1),
+		downAfter: max(downAfter, 1),
+		now:       time.Now,
+		deps:      make(map[string]*history),
+	}
+}
+
+// Record adds the outcome of a check of the dependency called name; err is
+// nil for a success
+func (t *Tracker) Record(name string, err error, latency time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.deps[name]
+	if h == nil {
+		h = &history{results: make([]bool, 0, t.window)}
+		t.deps[name] = h
+	}
+
+	ok := err == nil
+	if len(h.results) < t.window {
+		h.results = append(h.results, ok)
+	} else {
+		h.results[h.next] = ok
+	}
+	h.next = (h.next + 1) % t.window
+
+	h.latency = latency
+	h.lastCheckedAt = t.now().UTC()
+	if ok {
+		h.consecutiveFailures = 0
+		h.lastError = ""
+		h.lastSuccessAt = h.lastCheckedAt
+	} else {
+		h.consecutiveFailures++
+		h.lastError = err.Error()
+	}
+}
+
+// Down reports whether the dependency called name is known to be down
+func (t *Tracker) Down(name string) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.deps[name]
+	return h != nil && h.consecutiveFailures >= t.downAfter
+}
+
+// Status summarizes the checks of the dependency called name
+func (t *Tracker) Status(name string) Status {
+	if t == nil {
+		return Status{Name: name, State: StateUnknown}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status(name)
+}
+
+// Statuses summarizes every dependency checked so far, by name
+func (t *Tracker) Statuses() []Status {
+	if t == nil {
+		return []Status{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	statuses := make([]Status, 0, len(t.deps))
+	for name := range t.deps {
+		statuses = append(statuses, t.status(name))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// status summarizes name's history; t.mu must be held
+func (t *Tracker) status(name string) Status {
+	h := t.deps[name]
+	if h == nil {
+		return Status{Name: name, State: StateUnknown}
+	}
+
+	succeeded := 0
+	for _, ok := range h.results {
+		if ok {
+			succeeded++
+		}
+	}
+	status := Status{
+		Name:                name,
+		State:               StateUp,
+		Checks:              len(h.results),
+		SuccessRate:         float64(succeeded) / float64(len(h.results)),
+		ConsecutiveFailures: h.consecutiveFailures,
+		LatencyMS:           float64(h.latency.Microseconds()) / 1000,
+		LastError:           h.lastError,
+	}
+	if h.consecutiveFailures >= t.downAfter {
+		status.State = StateDown
+	}
+	checkedAt := h.lastCheckedAt
+	status.LastCheckedAt = &checkedAt
+	if !h.lastSuccessAt.IsZero() {
+		successAt := h.lastSuccessAt
+		status.LastSuccessAt = &successAt
+	}
+	return status
+}
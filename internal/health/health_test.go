@@ -0,0 +1,64 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker_DownAfterConsecutiveFailures(t *testing.T) {
+	tr := NewTracker(4, 2)
+	refused := errors.New("connection refused")
+
+	assert.Equal(t, StateUnknown, tr.Status(Redis).State)
+	assert.False(t, tr.Down(Redis))
+
+	tr.Record(Redis, nil, time.Millisecond)
+	tr.Record(Redis, refused, time.Second)
+	assert.False(t, tr.Down(Redis), "one failure is not an outage")
+
+	tr.Record(Redis, refused, time.Second)
+	assert.True(t, tr.Down(Redis))
+	status := tr.Status(Redis)
+	assert.Equal(t, StateDown, status.State)
+	assert.Equal(t, 2, status.ConsecutiveFailures)
+	assert.Equal(t, "connection refused", status.LastError)
+	assert.Equal(t, 1000.0, status.LatencyMS)
+	assert.InDelta(t, 1.0/3, status.SuccessRate, 0.001)
+	assert.NotNil(t, status.LastSuccessAt)
+
+	// The first success brings it back
+	tr.Record(Redis, nil, time.Millisecond)
+	assert.False(t, tr.Down(Redis))
+	assert.Empty(t, tr.Status(Redis).LastError)
+}
+
+func TestTracker_RollingWindow(t *testing.T) {
+	tr := NewTracker(3, 3)
+	refused := errors.New("connection refused")
+
+	for _, err := range []error{refused, refused, nil, nil, nil} {
+		tr.Record(Database, err, 0)
+	}
+	status := tr.Status(Database)
+	assert.Equal(t, 3, status.Checks)
+	assert.Equal(t, 1.0, status.SuccessRate, "failures have left the window")
+
+	tr.Record(Upstream("default"), refused, 0)
+	var names []string
+	for _, s := range tr.Statuses() {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{Database, "upstream:default"}, names)
+	assert.Nil(t, tr.Status("upstream:default").LastSuccessAt)
+}
+
+func TestTracker_Nil(t *testing.T) {
+	var tr *Tracker
+	tr.Record(Redis, errors.New("connection refused"), 0)
+	assert.False(t, tr.Down(Redis))
+	assert.Equal(t, StateUnknown, tr.Status(Redis).State)
+	assert.Empty(t, tr.Statuses())
+}
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"api-gateway-backend/internal/database"
+)
+
+// Triggers recorded in the sync history and on job runs
+const (
+	TriggerScheduled = "scheduled"
+	TriggerStartup   = "startup"
+	TriggerManual    = "manual"
+	TriggerAsync     = "async"
+)
+
+// startSyncRun records the start of a sync run. History is best effort:
+// failures are logged and never fail the sync itself.
+func (m *Manager) startSyncRun(ctx context.Context, trigger, requestID string) *database.SyncRun {
+	run := &database.SyncRun{
+		Trigger:   trigger,
+		RequestID: requestID,
+		Status:    SyncRunning,
+		StartedAt: time.Now().UTC(),
+	}
+	if err := m.db.CreateSyncRun(ctx, run); err != nil {
+		m.logger.FromContext(ctx).WithError(err).Warn("Failed to record sync run")
+	}
+	return run
+}
+
+// finishSyncRun records the outcome of a sync run
+func (m *Manager) finishSyncRun(ctx context.Context, run *database.SyncRun, err error) {
+	if run.ID == 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	run.FinishedAt = &now
+	run.Status = SyncSucceeded
+	if err != nil {
+		run.Status = SyncFailed
+		if len(run.Errors) < maxSyncJobErrors {
+			run.Errors = append(run.Errors, err.Error())
+		}
+	}
+
+	// Record the outcome even when the run was cancelled or timed out
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if err := m.db.FinishSyncRun(ctx, run); err != nil {
+		m.logger.FromContext(ctx).WithError(err).Warn("Failed to record sync run outcome")
+	}
+}
@@ -0,0 +1,222 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/events"
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/redis"
+	"api-gateway-backend/internal/tenant"
+)
+
+const (
+	// ingestStream is the Redis Stream syncs publish fetched items to
+	ingestStream = "items:ingest"
+	// ingestGroup is the consumer group persisting the stream's items
+	ingestGroup = "persisters"
+	// ingestBlock is how long a consumer waits for new entries, and so
+	// how long Stop may wait for an idle consumer
+	ingestBlock = 2 * time.Second
+	// ingestClaimIdle is how long an entry stays unacknowledged before
+	// another consumer claims it, e.g. after its consumer died
+	ingestClaimIdle = time.Minute
+	// ingestBacklogPoll is how often a sync waiting on the backlog checks it
+	ingestBacklogPoll = time.Second
+)
+
+// publishItems publishes items to the ingest stream in batches, for the
+// consumers to persist. onBatch is called with each published batch as
+// succeeded. Before each batch it waits while the stream holds
+// ingestMaxBacklog entries or more, so a sync can't outrun the consumers;
+// if ctx expires first, its error is returned.
+func (m *Manager) publishItems(ctx context.Context, items []database.Item, onBatch func(batchResult)) error {
+	id := tenant.From(ctx)
+	for start := 0; start < len(items); start += m.ingestBatch {
+		if err := m.awaitBacklog(ctx); err != nil {
+			return err
+		}
+
+		batch := items[start:min(start+m.ingestBatch, len(items))]
+		values := make([]interface{}, len(batch))
+		for i, item := range batch {
+			item.TenantID = id
+			values[i] = item
+		}
+		if err := m.redis.AppendStream(ctx, ingestStream, values); err != nil {
+			return fmt.Errorf("failed to publish items: %w", err)
+		}
+		onBatch(batchResult{succeeded: len(batch)})
+	}
+	return nil
+}
+
+// awaitBacklog returns once the ingest stream holds fewer than
+// ingestMaxBacklog entries
+func (m *Manager) awaitBacklog(ctx context.Context) error {
+	logged := false
+	for {
+		n, err := m.redis.StreamLen(ctx, ingestStream)
+		if err != nil {
+			return fmt.Errorf("failed to read ingest backlog: %w", err)
+		}
+		if n < m.ingestBacklog {
+			return nil
+		}
+		if !logged {
+			m.logger.FromContext(ctx).WithField("backlog", n).Info("Ingest backlog full, waiting for consumers")
+			logged = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(ingestBacklogPoll):
+		}
+	}
+}
+
+// startConsumers starts the ingest consumers of this instance. Every
+// instance runs them, leader or not, so persisting scales out with replicas.
+func (m *Manager) startConsumers() {
+	for i := 0; i < m.ingestWorkers; i++ {
+		m.consumers.Add(1)
+		go m.consume(m.instance + "-" + strconv.Itoa(i))
+	}
+	m.logger.WithField("workers", m.ingestWorkers).Info("Ingest consumers started")
+}
+
+// consume reads batches of the ingest stream as the consumer called name
+// and persists them until Stop
+func (m *Manager) consume(name string) {
+	defer m.consumers.Done()
+	log := m.logger.WithField("consumer", name)
+
+	for m.consumersCtx.Err() == nil {
+		messages, err := m.redis.ReadStream(m.consumersCtx, ingestStream, ingestGroup, name, m.ingestBatch, ingestBlock, ingestClaimIdle)
+		if err != nil {
+			if m.consumersCtx.Err() == nil {
+				log.WithError(err).Warn("Failed to read ingest stream")
+				select {
+				case <-m.consumersCtx.Done():
+				case <-time.After(ingestBlock):
+				}
+			}
+			continue
+		}
+		if len(messages) > 0 {
+			m.persist(messages)
+		}
+	}
+}
+
+// persist upserts a batch read from the ingest stream and acknowledges it.
+// Items that fail are handed to the retry queue like a sync's. A batch
+// interrupted by Stop is left unacknowledged, to be claimed by another
+// consumer.
+func (m *Manager) persist(messages []redis.StreamMessage) {
+	if !m.begin() {
+		return
+	}
+	defer m.running.Done()
+
+	ctx, cancel := context.WithTimeout(m.ctx, 2*time.Minute)
+	defer cancel()
+
+	ctx = logger.ContextWithRequestID(ctx, logger.NewRequestID())
+	log := m.logger.FromContext(ctx)
+
+	// Items are persisted per tenant, as the database scopes writes by it
+	ids := make([]string, 0, len(messages))
+	byTenant := make(map[string][]database.Item)
+	for _, msg := range messages {
+		ids = append(ids, msg.ID)
+
+		var item database.Item
+		if err := json.Unmarshal(msg.Data, &item); err != nil {
+			log.WithError(err).WithField("entry", msg.ID).Warn("Dropping unreadable ingest entry")
+			continue
+		}
+		byTenant[item.TenantID] = append(byTenant[item.TenantID], item)
+	}
+
+	var written int
+	writtenTenants := make(map[string]bool)
+	for id, items := range byTenant {
+		tenantCtx := tenant.With(ctx, id)
+
+		var before map[string]database.Item
+		if !m.outbox || m.producer != nil {
+			var err error
+			before, err = events.Snapshot(tenantCtx, m.db, items)
+			if err != nil {
+				log.WithError(err).Warn("Failed to snapshot items, live updates skipped for this batch")
+			}
+		}
+
+		result := m.upsertBatch(tenantCtx, m.db, items)
+		if ctx.Err() != nil {
+			return
+		}
+		for _, failed := range result.failed {
+			log.WithError(failed.err).WithField("external_id", failed.item.ExternalID).Error("Failed to upsert item")
+		}
+		m.retryItemsLater(tenantCtx, result.failed)
+
+		written += result.succeeded
+		if result.succeeded > 0 {
+			writtenTenants[id] = true
+		}
+		if result.succeeded > 0 && before != nil {
+			written := succeededItems(items, result.failed)
+			if !m.outbox {
+				m.publishItemEvents(tenantCtx, before, written)
+			}
+			m.produceItemEvents(tenantCtx, before, written)
+		}
+	}
+
+	if err := m.redis.AckStream(ctx, ingestStream, ingestGroup, ids...); err != nil {
+		log.WithError(err).Warn("Failed to acknowledge ingest entries")
+	}
+
+	m.invalidateItems(ctx, writtenTenants)
+	log.WithFields(map[string]interface{}{
+		"written": written,
+		"failed":  len(messages) - written,
+	}).Debug("Persisted ingest batch")
+}
+
+// invalidateItems bumps the items cache version of each of tenants, as the
+// version is kept per tenant
+func (m *Manager) invalidateItems(ctx context.Context, tenants map[string]bool) {
+	for id := range tenants {
+		tenantCtx := tenant.With(ctx, id)
+		if _, err := m.versions.Bump(tenantCtx, "items"); err != nil {
+			m.logger.FromContext(tenantCtx).WithError(err).WithField("tenant_id", id).Warn("Failed to invalidate cache")
+		}
+	}
+}
+
+// succeededItems returns the items that aren't among failed
+func succeededItems(items []database.Item, failed []itemError) []database.Item {
+	if len(failed) == 0 {
+		return items
+	}
+
+	failedIDs := make(map[string]bool, len(failed))
+	for _, f := range failed {
+		failedIDs[f.item.ExternalID] = true
+	}
+	written := make([]database.Item, 0, len(items)-len(failed))
+	for _, item := range items {
+		if !failedIDs[item.ExternalID] {
+			written = append(written, item)
+		}
+	}
+	return written
+}
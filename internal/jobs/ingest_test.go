@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/cache"
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/redis"
+	"api-gateway-backend/internal/tenant"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (r *fakeRedis) AppendStream(ctx context.Context, stream string, values []interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, value := range values {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		r.streamed = append(r.streamed, data)
+	}
+	return nil
+}
+
+func (r *fakeRedis) StreamLen(ctx context.Context, stream string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.backlog, nil
+}
+
+func (r *fakeRedis) AckStream(ctx context.Context, stream, group string, ids ...string) error {
+	return nil
+}
+
+func (r *fakeRedis) Incr(ctx context.Context, key string) *goredis.IntCmd {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, _ := strconv.ParseInt(r.values[key], 10, 64)
+	r.values[key] = strconv.FormatInt(n+1, 10)
+	return goredis.NewIntResult(n+1, nil)
+}
+
+// fakeItemStore fails every write of the bad tenant's items
+type fakeItemStore struct {
+	database.Store
+	bad string
+}
+
+func (s *fakeItemStore) UpsertItem(ctx context.Context, item *database.Item) error {
+	if tenant.From(ctx) == s.bad {
+		return errors.New("deadlock found")
+	}
+	return nil
+}
+
+func (s *fakeItemStore) UpsertItemsBatch(ctx context.Context, items []database.Item, chunkSize int) error {
+	if tenant.From(ctx) == s.bad {
+		return errors.New("deadlock found")
+	}
+	return nil
+}
+
+func TestPersist_InvalidatesEachTenant(t *testing.T) {
+	m := New(nil, nil, nil, nil, nil, config.JobsConfig{IngestStream: true, Outbox: true}, logger.New())
+	rdb := newFakeRedis()
+	m.redis, m.db = rdb, &fakeItemStore{bad: "initech"}
+	m.versions = cache.NewVersions(rdb, logger.New())
+
+	var messages []redis.StreamMessage
+	for i, id := range []string{"acme", "globex", "acme", "initech", ""} {
+		data, err := json.Marshal(database.Item{ExternalID: strconv.Itoa(i), TenantID: id})
+		require.NoError(t, err)
+		messages = append(messages, redis.StreamMessage{ID: strconv.Itoa(i), Data: data})
+	}
+	m.persist(messages)
+
+	// Each tenant written to has its own items cache retired, and the
+	// tenant nothing was written for keeps its cache
+	assert.Equal(t, "1", rdb.values["acme/items:v"])
+	assert.Equal(t, "1", rdb.values["globex/items:v"])
+	assert.Equal(t, "1", rdb.values["items:v"])
+	assert.NotContains(t, rdb.values, "initech/items:v")
+}
+
+func TestPublishItems(t *testing.T) {
+	cfg := config.JobsConfig{IngestStream: true, IngestWorkers: 1, IngestBatchSize: 2, IngestMaxBacklog: 3}
+	m := New(nil, nil, nil, nil, nil, cfg, logger.New())
+	rdb := newFakeRedis()
+	m.redis = rdb
+	ctx := tenant.With(context.Background(), "acme")
+
+	items := []database.Item{{ExternalID: "1"}, {ExternalID: "2"}, {ExternalID: "3"}, {ExternalID: "4"}, {ExternalID: "5"}}
+	var batches []int
+	require.NoError(t, m.publishItems(ctx, items, func(result batchResult) {
+		batches = append(batches, result.succeeded)
+	}))
+	assert.Equal(t, []int{2, 2, 1}, batches)
+	if assert.Len(t, rdb.streamed, 5) {
+		var item database.Item
+		require.NoError(t, json.Unmarshal(rdb.streamed[4], &item))
+		assert.Equal(t, "5", item.ExternalID)
+		assert.Equal(t, "acme", item.TenantID)
+	}
+
+	// A full backlog holds the sync back until its context expires
+	rdb.backlog = 3
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	err := m.publishItems(ctx, items, func(batchResult) { t.Error("published despite a full backlog") })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Len(t, rdb.streamed, 5)
+}
+
+func TestSucceededItems(t *testing.T) {
+	items := []database.Item{{ExternalID: "1"}, {ExternalID: "2"}, {ExternalID: "3"}}
+
+	assert.Equal(t, items, succeededItems(items, nil))
+	written := succeededItems(items, []itemError{{item: items[1], err: errors.New("deadlock found")}})
+	assert.Equal(t, []database.Item{items[0], items[2]}, written)
+}
@@ -2,132 +2,613 @@ package jobs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"api-gateway-backend/internal/analytics"
+	"api-gateway-backend/internal/cache"
 	"api-gateway-backend/internal/client"
 	"api-gateway-backend/internal/config"
 	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/events"
+	"api-gateway-backend/internal/kafka"
 	"api-gateway-backend/internal/logger"
 	"api-gateway-backend/internal/redis"
+	"api-gateway-backend/internal/reporting"
+	"api-gateway-backend/internal/tenant"
+	"api-gateway-backend/internal/webhooks"
 
+	goredis "github.com/redis/go-redis/v9"
 	"github.com/robfig/cron/v3"
 )
 
+// lastSyncKey stores the time of the last successful sync, shared by all
+// instances so readiness reflects data freshness regardless of which
+// instance ran the sync
+const lastSyncKey = "sync:last_success"
+
+// defaultSyncBatchSize applies when no positive batch size is configured
+const defaultSyncBatchSize = 500
+
+// ErrStopped is returned when a job is requested after Stop was called
+var ErrStopped = errors.New("job manager is stopped")
+
 // Manager handles background jobs
 type Manager struct {
 	cron      *cron.Cron
-	db        *database.DB
-	redis     *redis.Client
-	client    *client.ExternalAPIClient
+	db        database.Store
+	redis     redis.CacheClient
+	versions  *cache.Versions
+	upstreams *client.Registry
+	webhooks  *webhooks.Notifier // nil disables outbound webhooks
+	analytics *analytics.Store
 	logger    *logger.Logger
+	reporter  reporting.Reporter
+	lockTTL   time.Duration
+	batchSize int
+	workers   int
+	atomic    bool                                                                  // write each sync in one transaction
+	maxErrors int                                                                   // failed items an atomic sync tolerates
+	withTx    func(ctx context.Context, fn func(w database.ItemWriter) error) error // runs fn in a transaction of db
 	ctx       context.Context
 	cancel    context.CancelFunc
+
+	// Resources fetched and written at once by the combined resource sync
+	resourceConcurrency int
+
+	// Items that fail to upsert during a sync are retried from a queue in
+	// Redis, then dead-lettered into the database
+	itemRetry     bool
+	retryAttempts int
+	retryDelay    time.Duration
+
+	// With stream ingestion syncs publish items to a Redis Stream, and
+	// consumers on every instance persist them until Stop
+	ingest        bool
+	ingestWorkers int
+	ingestBatch   int
+	ingestBacklog int64
+	consumersCtx  context.Context
+	stopConsumers context.CancelFunc
+	consumers     sync.WaitGroup
+
+	// With the outbox the database records item changes, and the relay job
+	// publishes them instead of syncs
+	outbox      bool
+	outboxBatch int
+	outboxSink  events.Sink
+
+	// producer, when set, produces item changes and sync completions to
+	// Kafka
+	producer eventProducer
+
+	// With leader election the scheduler only runs while this instance
+	// holds the leader lease. The lease is only touched by elect, and by
+	// Stop once elect has returned.
+	instance      string
+	election      bool
+	leaderTTL     time.Duration
+	acquireLeader func(ctx context.Context) (leaderLease, error)
+	electionCtx   context.Context
+	stopElection  context.CancelFunc
+	electionDone  chan struct{}
+	lease         leaderLease
+	renewedAt     time.Time
+	leading       atomic.Bool
+
+	mu        sync.Mutex
+	jobs      []scheduledJob
+	afterSync []func(ctx context.Context)
+	stopping  bool
+	running   sync.WaitGroup
 }
 
-// New creates a new job manager
-func New(db *database.DB, rdb *redis.Client, apiCfg config.ExternalAPIConfig, log *logger.Logger) *Manager {
+// New creates a new job manager. Syncs that change items publish events
+// through notifier, and failed runs are sent to reporter; both may be nil.
+func New(db database.Store, rdb *redis.Client, upstreams *client.Registry, notifier *webhooks.Notifier, reporter reporting.Reporter, jobsCfg config.JobsConfig, log *logger.Logger) *Manager {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Manager{
-		cron:   cron.New(cron.WithSeconds()),
-		db:     db,
-		redis:  rdb,
-		client: client.New(apiCfg),
-		logger: log,
-		ctx:    ctx,
-		cancel: cancel,
+	if reporter == nil {
+		reporter = reporting.Nop{}
+	}
+
+	lockTTL := time.Duration(jobsCfg.SyncLockTTL) * time.Second
+	if lockTTL <= 0 {
+		lockTTL = defaultSyncLockTTL
+	}
+
+	batchSize := jobsCfg.SyncBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultSyncBatchSize
+	}
+
+	workers := jobsCfg.SyncWorkers
+	if workers <= 0 {
+		workers = defaultSyncWorkers
+	}
+
+	resourceConcurrency := jobsCfg.ResourceSyncConcurrency
+	if resourceConcurrency <= 0 {
+		resourceConcurrency = defaultResourceSyncConcurrency
 	}
+
+	m := &Manager{
+		cron:      cron.New(cron.WithSeconds()),
+		db:        db,
+		redis:     rdb,
+		versions:  cache.NewVersions(rdb, log),
+		upstreams: upstreams,
+		webhooks:  notifier,
+		analytics: analytics.New(db, rdb),
+		logger:    log,
+		reporter:  reporter,
+		lockTTL:   lockTTL,
+		batchSize: batchSize,
+		workers:   workers,
+		atomic:    jobsCfg.SyncAtomic,
+		maxErrors: jobsCfg.SyncMaxErrors,
+		ctx:       ctx,
+		cancel:    cancel,
+
+		resourceConcurrency: resourceConcurrency,
+
+		itemRetry:     jobsCfg.ItemRetryEnabled,
+		retryAttempts: jobsCfg.ItemRetryMaxAttempts,
+		retryDelay:    time.Duration(jobsCfg.ItemRetryDelay) * time.Second,
+
+		ingest:        jobsCfg.IngestStream,
+		ingestWorkers: jobsCfg.IngestWorkers,
+		ingestBatch:   jobsCfg.IngestBatchSize,
+		ingestBacklog: int64(jobsCfg.IngestMaxBacklog),
+
+		outbox:      jobsCfg.Outbox,
+		outboxBatch: jobsCfg.OutboxBatchSize,
+
+		instance: newInstanceID(),
+	}
+	m.withTx = func(ctx context.Context, fn func(w database.ItemWriter) error) error {
+		return m.db.WithTx(ctx, func(tx *database.Tx) error { return fn(tx) })
+	}
+	if jobsCfg.OutboxKafkaURL != "" {
+		m.outboxSink = events.NewKafkaSink(jobsCfg.OutboxKafkaURL, time.Duration(jobsCfg.OutboxKafkaTimeout)*time.Second)
+	} else if rdb != nil {
+		m.outboxSink = events.NewRedisSink(rdb)
+	}
+	m.electionCtx, m.stopElection = context.WithCancel(ctx)
+	m.consumersCtx, m.stopConsumers = context.WithCancel(ctx)
+	if jobsCfg.LeaderElection && rdb != nil {
+		m.election = true
+		m.leaderTTL = time.Duration(jobsCfg.LeaderTTL) * time.Second
+		m.acquireLeader = func(ctx context.Context) (leaderLease, error) {
+			lock, err := rdb.AcquireLockAs(ctx, leaderKey, m.instance, m.leaderTTL)
+			if err != nil {
+				return nil, err
+			}
+			return lock, nil
+		}
+	}
+	m.registerBuiltins(jobsCfg)
+
+	return m
 }
 
-// Start starts the background jobs
+// Start starts the scheduler, or with leader election starts campaigning
+// for the lease and starts the scheduler once elected. Ingest consumers
+// start on every instance.
 func (m *Manager) Start() {
-	// Schedule data sync every 15 minutes
-	_, err := m.cron.AddFunc("0 */15 * * * *", func() {
-		if err := m.syncData(); err != nil {
-			m.logger.WithError(err).Error("Failed to sync data")
-		}
-	})
-	if err != nil {
-		m.logger.WithError(err).Error("Failed to schedule sync job")
+	if m.ingest {
+		m.startConsumers()
+	}
+	if m.election {
+		m.electionDone = make(chan struct{})
+		go m.elect()
+		m.logger.WithField("instance", m.instance).Info("Background jobs waiting for leader election")
 		return
 	}
+	m.startScheduler()
+}
 
+// startScheduler starts the scheduler and runs each registered job's
+// startup run
+func (m *Manager) startScheduler() {
 	m.cron.Start()
 	m.logger.Info("Background jobs started")
 
-	// Run initial sync
-	go func() {
-		if err := m.syncData(); err != nil {
-			m.logger.WithError(err).Error("Failed to perform initial sync")
+	m.mu.Lock()
+	jobs := append([]scheduledJob(nil), m.jobs...)
+	m.mu.Unlock()
+
+	for _, j := range jobs {
+		if j.onStart != nil {
+			go m.runJob(j.name, TriggerStartup, j.onStart)
 		}
-	}()
+	}
 }
 
-// Stop stops the background jobs
-func (m *Manager) Stop() {
-	m.cancel()
+// Stop stops scheduling new jobs and waits for running ones to finish.
+// If ctx expires first, running jobs are cancelled and ctx's error is
+// returned; a nil error means every job terminated cleanly.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	m.stopping = true
+	m.mu.Unlock()
+
+	m.stopElection()
+	if m.electionDone != nil {
+		<-m.electionDone
+	}
 	m.cron.Stop()
-	m.logger.Info("Background jobs stopped")
+	m.stopConsumers()
+	defer m.resign()
+
+	done := make(chan struct{})
+	go func() {
+		m.consumers.Wait()
+		m.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		m.cancel()
+		m.logger.Info("Background jobs stopped")
+		return nil
+	case <-ctx.Done():
+		m.cancel()
+		m.logger.Warn("Background jobs cancelled before completion")
+		return fmt.Errorf("background jobs did not finish in time: %w", ctx.Err())
+	}
 }
 
-// syncData fetches data from external API and stores in database
-func (m *Manager) syncData() error {
+// AfterSync registers fn to run after each sync that wrote items, once the
+// items cache has been invalidated, e.g. to warm it again. fn runs as part
+// of the sync, under its context and lock.
+func (m *Manager) AfterSync(fn func(ctx context.Context)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.afterSync = append(m.afterSync, fn)
+}
+
+// begin registers a job run, reporting false once Stop has been called
+func (m *Manager) begin() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopping {
+		return false
+	}
+	m.running.Add(1)
+	return true
+}
+
+// syncData fetches data from external API and stores in database. The
+// request ID tags every log line of the run and is forwarded upstream.
+// Every run is recorded in the sync history under trigger; when job is
+// non-nil, progress and the outcome are also recorded on it.
+func (m *Manager) syncData(trigger, requestID string, job *SyncJob) (err error) {
 	ctx, cancel := context.WithTimeout(m.ctx, 2*time.Minute)
 	defer cancel()
 
-	m.logger.Info("Starting data sync")
-	start := time.Now()
+	ctx = logger.ContextWithRequestID(ctx, requestID)
+	log := m.logger.FromContext(ctx)
 
-	// Fetch posts from external API
-	posts, err := m.client.FetchPosts(ctx)
+	if !m.begin() {
+		m.finishSyncJob(ctx, job, ErrStopped)
+		return ErrStopped
+	}
+	defer m.running.Done()
+	defer func() { m.finishSyncJob(ctx, job, err) }()
+
+	// Only one instance syncs at a time; the others skip this run
+	release, err := m.holdSyncLock(ctx, syncLockKey, cancel)
 	if err != nil {
-		return fmt.Errorf("failed to fetch posts: %w", err)
+		return err
 	}
+	defer release()
+
+	log.WithField("trigger", trigger).Info("Starting data sync")
+	start := time.Now()
+
+	run := m.startSyncRun(ctx, trigger, requestID)
+	defer func() { m.finishSyncRun(ctx, run, err) }()
 
-	m.logger.WithField("count", len(posts)).Info("Fetched posts from external API")
+	if job != nil {
+		startedAt := start.UTC()
+		job.Status = SyncRunning
+		job.StartedAt = &startedAt
+		m.saveSyncJob(ctx, job)
+	}
+
+	upstream, err := m.upstreams.Get(config.DefaultUpstream)
+	if err != nil {
+		return err
+	}
 
-	// Store posts in database (idempotent)
+	// Store items in database (idempotent) using parallel batch upserts,
+	// or in one transaction for an atomic sync, or hand them to the ingest
+	// consumers through the stream
 	var successCount, errorCount int
-	for _, post := range posts {
-		item := &database.Item{
-			ExternalID: strconv.Itoa(post.ID),
-			Title:      post.Title,
-			Body:       post.Body,
-			UserID:     post.UserID,
+	var failedItems []itemError
+	failedIDs := make(map[string]bool)
+	record := func(result batchResult) {
+		successCount += result.succeeded
+		failedItems = append(failedItems, result.failed...)
+		for _, failed := range result.failed {
+			log.WithError(failed.err).WithField("external_id", failed.item.ExternalID).Error("Failed to upsert item")
+			failedIDs[failed.item.ExternalID] = true
+			errorCount++
+			msg := fmt.Sprintf("item %s: %v", failed.item.ExternalID, failed.err)
+			if len(run.Errors) < maxSyncJobErrors {
+				run.Errors = append(run.Errors, msg)
+			}
+			if job != nil {
+				job.addError(msg)
+			}
 		}
+		run.SuccessCount, run.ErrorCount = successCount, errorCount
 
-		if err := m.db.UpsertItem(item); err != nil {
-			m.logger.WithError(err).WithField("external_id", item.ExternalID).Error("Failed to upsert item")
-			errorCount++
+		if job != nil {
+			job.Processed, job.Succeeded, job.Failed = successCount+errorCount, successCount, errorCount
+			m.saveSyncJob(ctx, job)
+		}
+	}
+
+	// Remember the stored versions so live subscribers only hear about
+	// items that actually change. With stream ingestion the consumers
+	// do this as they persist, and with the outbox the database does,
+	// unless Kafka needs the changes too.
+	var before map[string]database.Item
+	if !m.ingest && (!m.outbox || m.producer != nil) {
+		before = make(map[string]database.Item)
+	}
+	snapshot := func(items []database.Item) {
+		if before == nil {
+			return
+		}
+		stored, err := events.Snapshot(ctx, m.db, items)
+		if err != nil {
+			log.WithError(err).Warn("Failed to snapshot items, live updates skipped for this sync")
+			before = nil
+			return
+		}
+		for id, item := range stored {
+			before[id] = item
+		}
+	}
+
+	// Fetch posts from external API, writing each page as it arrives. An
+	// atomic sync writes them all in one transaction once every page is
+	// in, rather than hold it open across upstream calls.
+	var items []database.Item
+	var writeErr error
+	fetchErr := client.FetchPostPages(ctx, upstream, func(posts []client.PostResponse) error {
+		page := make([]database.Item, len(posts))
+		for i, post := range posts {
+			page[i] = database.Item{
+				ExternalID: strconv.Itoa(post.ID),
+				Title:      post.Title,
+				Body:       post.Body,
+				UserID:     post.UserID,
+			}
+		}
+		items = append(items, page...)
+		log.WithFields(map[string]interface{}{
+			"count": len(page),
+			"total": len(items),
+		}).Debug("Fetched page of posts")
+
+		if job != nil {
+			job.Total = len(items)
+			m.saveSyncJob(ctx, job)
+		}
+		if m.atomic {
+			return nil
+		}
+
+		snapshot(page)
+		if m.ingest {
+			writeErr = m.publishItems(ctx, page, record)
 		} else {
-			successCount++
+			writeErr = m.upsertItems(ctx, page, record)
+		}
+		return writeErr
+	})
+	if fetchErr == nil {
+		log.WithField("count", len(items)).Info("Fetched posts from external API")
+	}
+
+	err = writeErr
+	if m.atomic && fetchErr == nil {
+		snapshot(items)
+		err = m.writeItemsAtomic(ctx, items, run, job, record)
+		if err != nil {
+			successCount = 0
+		}
+		if errors.Is(err, errTooManyFailures) {
+			log.WithField("error_count", errorCount).Warn("Rolled back sync")
+			return fmt.Errorf("sync rolled back: %d items failed, more than the %d allowed", errorCount, m.maxErrors)
+		}
+	}
+	// Failed items are retried later, unless the sync was rolled back and
+	// the next one writes them all again
+	if !m.atomic || err == nil {
+		m.retryItemsLater(ctx, failedItems)
+	}
+	if err != nil {
+		return fmt.Errorf("sync interrupted after %d of %d items: %w", successCount+errorCount, len(items), err)
+	}
+	// Pages already written are kept, but without every page nothing can
+	// be marked deleted
+	if fetchErr != nil {
+		if written := successCount + errorCount; written > 0 {
+			return fmt.Errorf("sync interrupted after %d items: %w", written, fetchErr)
+		}
+		return fetchErr
+	}
+
+	m.dropItemRetries(ctx, items, failedIDs)
+
+	// Items the upstream no longer returns are kept for auditing but
+	// marked deleted. An empty fetch is more likely an upstream fault
+	// than every item being removed, so it deletes nothing.
+	var deleted []string
+	if len(items) > 0 {
+		seen := make([]string, len(items))
+		for i, item := range items {
+			seen[i] = item.ExternalID
+		}
+		deleted, err = m.db.SoftDeleteMissingItems(ctx, seen)
+		if err != nil {
+			log.WithError(err).Warn("Failed to mark removed items deleted")
+		}
+		if len(deleted) > 0 {
+			log.WithField("count", len(deleted)).Info("Marked items removed upstream as deleted")
 		}
 	}
 
 	// Invalidate cache after successful sync
-	if successCount > 0 {
-		if err := m.redis.InvalidatePattern(ctx, "items:*"); err != nil {
-			m.logger.WithError(err).Warn("Failed to invalidate cache")
+	if successCount > 0 || len(deleted) > 0 {
+		version, err := m.versions.Bump(ctx, "items")
+		if err != nil {
+			log.WithError(err).Warn("Failed to invalidate cache")
+		} else {
+			log.WithField("version", version).Debug("Invalidated items cache")
+		}
+
+		m.mu.Lock()
+		hooks := m.afterSync
+		m.mu.Unlock()
+		for _, fn := range hooks {
+			fn(ctx)
+		}
+
+		m.publishWebhook(ctx, webhooks.EventItemsSynced, map[string]interface{}{
+			"sync_run_id": run.ID,
+			"trigger":     trigger,
+			"count":       successCount,
+			"deleted":     len(deleted),
+		})
+	}
+
+	if successCount > 0 && before != nil {
+		written := make([]database.Item, 0, successCount)
+		for _, item := range items {
+			if !failedIDs[item.ExternalID] {
+				written = append(written, item)
+			}
+		}
+		if !m.outbox {
+			m.publishItemEvents(ctx, before, written)
+		}
+		m.produceItemEvents(ctx, before, written)
+	}
+
+	if errorCount == 0 {
+		if err := m.redis.Set(ctx, lastSyncKey, time.Now().UTC().Format(time.RFC3339), 0).Err(); err != nil {
+			log.WithError(err).Warn("Failed to record sync time")
 		}
 	}
 
 	duration := time.Since(start)
-	m.logger.WithFields(map[string]interface{}{
+	log.WithFields(map[string]interface{}{
 		"success_count": successCount,
 		"error_count":   errorCount,
 		"duration":      duration,
 	}).Info("Data sync completed")
 
+	m.produce(ctx, kafka.Event{
+		Type:     kafka.EventSyncCompleted,
+		TenantID: tenant.From(ctx),
+		Key:      tenant.From(ctx),
+		Data: kafka.SyncCompleted{
+			SyncRunID:  run.ID,
+			Trigger:    trigger,
+			Succeeded:  successCount,
+			Failed:     errorCount,
+			Deleted:    len(deleted),
+			DurationMS: duration.Milliseconds(),
+		},
+	})
+
 	if errorCount > 0 {
-		return fmt.Errorf("sync completed with %d errors out of %d items", errorCount, len(posts))
+		return fmt.Errorf("sync completed with %d errors out of %d items", errorCount, len(items))
+	}
+
+	return nil
+}
+
+// publishItemEvents publishes live update events for the written items
+// that changed. Like webhooks, live updates are best effort.
+func (m *Manager) publishItemEvents(ctx context.Context, before map[string]database.Item, written []database.Item) {
+	log := m.logger.FromContext(ctx)
+	published, err := events.PublishChanges(ctx, m.db, m.redis, before, written)
+	if err != nil {
+		log.WithError(err).Warn("Failed to publish item events")
+		return
+	}
+	if published > 0 {
+		log.WithField("count", published).Debug("Published item events")
+	}
+}
+
+// reconcileAnalytics rebuilds the Redis analytics aggregates from the database
+func (m *Manager) reconcileAnalytics() error {
+	if !m.begin() {
+		return ErrStopped
+	}
+	defer m.running.Done()
+
+	ctx, cancel := context.WithTimeout(m.ctx, time.Minute)
+	defer cancel()
+
+	start := time.Now()
+	if err := m.analytics.Reconcile(ctx); err != nil {
+		return err
 	}
 
+	m.logger.WithField("duration", time.Since(start)).Info("Analytics reconcile completed")
 	return nil
 }
 
-// SyncDataManual performs manual data sync (for /sync endpoint)
+// refreshOrderStats rebuilds the order_stats table from orders
+func (m *Manager) refreshOrderStats() error {
+	if !m.begin() {
+		return ErrStopped
+	}
+	defer m.running.Done()
+
+	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Minute)
+	defer cancel()
+
+	start := time.Now()
+	rows, err := m.db.RefreshOrderStats(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.logger.WithField("rows", rows).WithField("duration", time.Since(start)).Info("Order stats refresh completed")
+	return nil
+}
+
+// LastSuccessfulSync returns when data was last synced without errors. The
+// zero time is returned if no sync has been recorded.
+func (m *Manager) LastSuccessfulSync(ctx context.Context) (time.Time, error) {
+	value, err := m.redis.Get(ctx, lastSyncKey).Result()
+	if errors.Is(err, goredis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// SyncDataManual performs manual data sync (for /sync endpoint), logging
+// under the request ID carried by ctx
 func (m *Manager) SyncDataManual(ctx context.Context) error {
-	return m.syncData()
-}
\ No newline at end of file
+	return m.syncData(TriggerManual, logger.RequestIDFromContext(ctx), nil)
+}
@@ -0,0 +1,152 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"api-gateway-backend/internal/redis"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// leaderKey holds the ID of the instance leading the scheduler, as the
+// value of its lease
+const leaderKey = "jobs:leader"
+
+// leaderLease is the lease of the leading instance, a redis.Lock outside
+// tests
+type leaderLease interface {
+	Refresh(ctx context.Context) error
+	Release(ctx context.Context) error
+}
+
+// LeaderStatus reports which instance runs the scheduler
+type LeaderStatus struct {
+	Election bool   `json:"election"`         // false when every instance runs its own scheduler
+	Instance string `json:"instance"`         // this instance
+	Leader   string `json:"leader,omitempty"` // the leading instance, empty while there is none
+	IsLeader bool   `json:"is_leader"`        // whether this instance runs the scheduler
+}
+
+// newInstanceID names this process for leader election: its hostname, the
+// pod or container name in a deployment, and a random suffix so a restarted
+// process isn't mistaken for its predecessor
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "gateway"
+	}
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return host
+	}
+	return host + "-" + hex.EncodeToString(buf)
+}
+
+// elect campaigns for the leader lease until Stop, renewing it every third
+// of its TTL once held
+func (m *Manager) elect() {
+	defer close(m.electionDone)
+
+	ticker := time.NewTicker(m.leaderTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		m.campaign(m.electionCtx)
+		select {
+		case <-m.electionCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign renews the lease if this instance leads, stepping down if it
+// was taken over or couldn't be renewed before it expired, and otherwise
+// tries to take the lease, starting the scheduler if it does. A leader
+// that dies stops renewing, so another instance takes over within the TTL.
+func (m *Manager) campaign(ctx context.Context) {
+	log := m.logger.WithField("instance", m.instance)
+
+	if m.lease != nil {
+		err := m.lease.Refresh(ctx)
+		switch {
+		case err == nil:
+			m.renewedAt = time.Now()
+		case errors.Is(err, redis.ErrLockLost):
+			log.Warn("Leader lease taken over, stopping scheduler")
+			m.stepDown()
+		case time.Since(m.renewedAt) >= m.leaderTTL:
+			log.WithError(err).Error("Leader lease expired before it could be renewed, stopping scheduler")
+			m.stepDown()
+		case ctx.Err() == nil:
+			// The lease is still ours until it expires; retry next tick
+			log.WithError(err).Warn("Failed to renew leader lease")
+		}
+		return
+	}
+
+	lease, err := m.acquireLeader(ctx)
+	if errors.Is(err, redis.ErrLockNotAcquired) {
+		return
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			log.WithError(err).Warn("Failed to campaign for leader")
+		}
+		return
+	}
+
+	m.lease, m.renewedAt = lease, time.Now()
+	m.leading.Store(true)
+	log.Info("Elected leader, starting scheduler")
+	m.startScheduler()
+}
+
+// stepDown stops the scheduler after the lease was lost. Runs already in
+// progress finish under their own locks.
+func (m *Manager) stepDown() {
+	m.lease = nil
+	m.leading.Store(false)
+	m.cron.Stop()
+}
+
+// resign releases the lease on shutdown, so another instance takes over
+// without waiting for it to expire
+func (m *Manager) resign() {
+	if m.lease == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := m.lease.Release(ctx); err != nil && !errors.Is(err, redis.ErrLockLost) {
+		m.logger.WithError(err).Warn("Failed to release leader lease")
+	}
+	m.lease = nil
+	m.leading.Store(false)
+}
+
+// Leader reports which instance currently runs the scheduler. Without
+// leader election every instance runs its own and reports itself.
+func (m *Manager) Leader(ctx context.Context) (*LeaderStatus, error) {
+	status := &LeaderStatus{Election: m.election, Instance: m.instance}
+	if !m.election {
+		status.Leader, status.IsLeader = m.instance, true
+		return status, nil
+	}
+
+	leader, err := m.redis.Get(ctx, leaderKey).Result()
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		return nil, fmt.Errorf("failed to read leader: %w", err)
+	}
+	status.Leader = leader
+	status.IsLeader = m.leading.Load() && leader == m.instance
+	return status, nil
+}
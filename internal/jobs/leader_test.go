@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/redis"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLease is a leader lease whose renewals fail with refreshErr
+type fakeLease struct {
+	refreshErr error
+	released   bool
+}
+
+func (l *fakeLease) Refresh(context.Context) error { return l.refreshErr }
+
+func (l *fakeLease) Release(context.Context) error {
+	l.released = true
+	return nil
+}
+
+func TestCampaign(t *testing.T) {
+	m := New(nil, nil, nil, nil, nil, config.JobsConfig{}, logger.New())
+	m.election, m.leaderTTL = true, time.Minute
+
+	held := true
+	lease := &fakeLease{}
+	m.acquireLeader = func(context.Context) (leaderLease, error) {
+		if held {
+			return nil, redis.ErrLockNotAcquired
+		}
+		return lease, nil
+	}
+	ctx := context.Background()
+
+	// Another instance leads
+	m.campaign(ctx)
+	assert.False(t, m.leading.Load())
+
+	// Its lease expires and this instance takes over
+	held = false
+	m.campaign(ctx)
+	assert.True(t, m.leading.Load())
+
+	// A failed renewal keeps the lead until the lease would have expired
+	lease.refreshErr = errors.New("connection refused")
+	m.campaign(ctx)
+	assert.True(t, m.leading.Load())
+	m.renewedAt = time.Now().Add(-time.Minute)
+	m.campaign(ctx)
+	assert.False(t, m.leading.Load())
+
+	// A lease taken over by another instance ends the lead at once
+	m.campaign(ctx)
+	assert.True(t, m.leading.Load())
+	lease.refreshErr = redis.ErrLockLost
+	m.campaign(ctx)
+	assert.False(t, m.leading.Load())
+	assert.Nil(t, m.lease)
+
+	// Stopping releases a held lease
+	lease.refreshErr = nil
+	m.campaign(ctx)
+	assert.NoError(t, m.Stop(ctx))
+	assert.True(t, lease.released)
+	assert.False(t, m.leading.Load())
+}
+
+func TestLeader_WithoutElection(t *testing.T) {
+	m := New(nil, nil, nil, nil, nil, config.JobsConfig{LeaderElection: true, LeaderTTL: 15}, logger.New())
+
+	status, err := m.Leader(context.Background())
+	if assert.NoError(t, err) {
+		assert.False(t, status.Election, "no Redis to elect through")
+		assert.True(t, status.IsLeader)
+		assert.Equal(t, m.instance, status.Leader)
+	}
+}
@@ -0,0 +1,76 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"api-gateway-backend/internal/redis"
+)
+
+const (
+	// syncLockKey guards syncData so only one instance syncs at a time;
+	// resource syncs lock syncLockKey + ":" + resource
+	syncLockKey = "lock:sync"
+	// defaultSyncLockTTL applies when no positive TTL is configured
+	defaultSyncLockTTL = 30 * time.Second
+)
+
+// ErrSyncInProgress is returned when another sync holds the lock
+var ErrSyncInProgress = errors.New("sync already in progress")
+
+// holdSyncLock acquires the sync lock at key and renews it in the background until
+// the returned release func is called. If the lock is lost, cancel is called
+// so the run stops rather than racing the new holder.
+func (m *Manager) holdSyncLock(ctx context.Context, key string, cancel context.CancelFunc) (func(), error) {
+	lock, err := m.redis.AcquireLock(ctx, key, m.lockTTL)
+	if errors.Is(err, redis.ErrLockNotAcquired) {
+		return nil, ErrSyncInProgress
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire sync lock: %w", err)
+	}
+
+	log := m.logger.FromContext(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(lock.TTL() / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := lock.Refresh(ctx)
+				if errors.Is(err, redis.ErrLockLost) {
+					log.Error("Sync lock lost, aborting sync")
+					cancel()
+					return
+				}
+				if err != nil {
+					// The lock is still ours until it expires; retry next tick
+					log.WithError(err).Warn("Failed to refresh sync lock")
+				}
+			}
+		}
+	}()
+
+	release := func() {
+		close(done)
+		<-stopped
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+
+		if err := lock.Release(ctx); err != nil && !errors.Is(err, redis.ErrLockLost) {
+			log.WithError(err).Warn("Failed to release sync lock")
+		}
+	}
+	return release, nil
+}
@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/events"
+	"api-gateway-backend/internal/logger"
+)
+
+// outboxLockKey guards the outbox relay so events are published by one
+// instance at a time, in order
+const outboxLockKey = "lock:outbox"
+
+// relayOutbox publishes the events recorded in the outbox, oldest first, in
+// batches until it is empty. Events are deleted once published, so one
+// published just before a crash is published again: downstream consumers
+// get every change at least once and must tolerate duplicates.
+func (m *Manager) relayOutbox() error {
+	if !m.begin() {
+		return ErrStopped
+	}
+	defer m.running.Done()
+
+	ctx, cancel := context.WithTimeout(m.ctx, 2*time.Minute)
+	defer cancel()
+
+	ctx = logger.ContextWithRequestID(ctx, logger.NewRequestID())
+
+	release, err := m.holdSyncLock(ctx, outboxLockKey, cancel)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var relayed int
+	defer func() {
+		if relayed > 0 {
+			m.logger.FromContext(ctx).WithField("count", relayed).Info("Relayed outbox events")
+		}
+	}()
+
+	for ctx.Err() == nil {
+		batch, err := m.db.ListOutboxEvents(ctx, m.outboxBatch)
+		if err != nil {
+			return fmt.Errorf("failed to read outbox: %w", err)
+		}
+
+		n, err := m.relay(ctx, batch)
+		relayed += n
+		if err != nil {
+			return err
+		}
+		if len(batch) < m.outboxBatch {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+// relay publishes batch in order and deletes the events it published,
+// returning how many. An event that can't be decoded would block the
+// outbox forever, so it is logged and deleted unpublished.
+func (m *Manager) relay(ctx context.Context, batch []database.OutboxEvent) (int, error) {
+	log := m.logger.FromContext(ctx)
+
+	messages := make([]events.Message, 0, len(batch))
+	ids := make([]int64, 0, len(batch))
+	var dropped []int64
+	for _, e := range batch {
+		msg, err := events.FromOutbox(e)
+		if err != nil {
+			log.WithError(err).Error("Dropping unpublishable outbox event")
+			dropped = append(dropped, e.ID)
+			continue
+		}
+		messages = append(messages, msg)
+		ids = append(ids, e.ID)
+	}
+
+	sent, err := m.outboxSink.Publish(ctx, messages)
+	if err != nil {
+		err = fmt.Errorf("failed to publish outbox events: %w", err)
+	}
+	if deleteErr := m.db.DeleteOutboxEvents(ctx, append(dropped, ids[:sent]...)); deleteErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to delete published outbox events: %w", deleteErr))
+	}
+	return sent, err
+}
@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/events"
+	"api-gateway-backend/internal/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOutbox records the outbox events deleted
+type fakeOutbox struct {
+	database.Store
+	deleted []int64
+}
+
+func (s *fakeOutbox) DeleteOutboxEvents(ctx context.Context, ids []int64) error {
+	s.deleted = append(s.deleted, ids...)
+	return nil
+}
+
+// fakeSink publishes up to limit messages, then fails
+type fakeSink struct {
+	limit     int
+	published []events.Message
+}
+
+func (s *fakeSink) Publish(ctx context.Context, messages []events.Message) (int, error) {
+	for i, msg := range messages {
+		if len(s.published) == s.limit {
+			return i, errors.New("connection refused")
+		}
+		s.published = append(s.published, msg)
+	}
+	return len(messages), nil
+}
+
+func TestRelay(t *testing.T) {
+	batch := []database.OutboxEvent{
+		{ID: 1, TenantID: "acme", Aggregate: database.AggregateItem, AggregateID: "7", Type: database.EventItemCreated, Payload: []byte(`{"external_id":"7"}`)},
+		{ID: 2, Aggregate: "invoice", AggregateID: "1", Payload: []byte(`{}`)},
+		{ID: 3, TenantID: "acme", Aggregate: database.AggregateOrder, AggregateID: "12", Type: database.EventOrderCreated, Payload: []byte(`{"id":12}`)},
+	}
+
+	tests := []struct {
+		name    string
+		limit   int
+		sent    int
+		deleted []int64
+		wantErr bool
+	}{
+		{"all published", 10, 2, []int64{2, 1, 3}, false},
+		{"sink fails part way", 1, 1, []int64{2, 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(nil, nil, nil, nil, nil, config.JobsConfig{Outbox: true, OutboxBatchSize: 10}, logger.New())
+			store := &fakeOutbox{}
+			sink := &fakeSink{limit: tt.limit}
+			m.db, m.outboxSink = store, sink
+
+			sent, err := m.relay(context.Background(), batch)
+			assert.Equal(t, tt.sent, sent)
+			assert.Equal(t, tt.wantErr, err != nil)
+			// Unpublished events stay in the outbox for the next run, while
+			// the unknown aggregate is dropped either way
+			assert.Equal(t, tt.deleted, store.deleted)
+
+			require.NotEmpty(t, sink.published)
+			assert.Equal(t, events.ItemsChannel, sink.published[0].Channel)
+			assert.Equal(t, "acme:7", sink.published[0].Key)
+		})
+	}
+}
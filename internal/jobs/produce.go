@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"context"
+
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/kafka"
+	"api-gateway-backend/internal/tenant"
+)
+
+// eventProducer produces change events to Kafka; *kafka.Producer
+// implements it
+type eventProducer interface {
+	Produce(ctx context.Context, events ...kafka.Event) error
+}
+
+// ProduceTo makes syncs produce their item changes and completions with p.
+// A nil p, as kafka.NewProducer returns without brokers, produces nothing.
+func (m *Manager) ProduceTo(p *kafka.Producer) {
+	if p != nil {
+		m.producer = p
+	}
+}
+
+// produceItemEvents produces an item.updated event for each of the written
+// items that is new or differs from its version in before
+func (m *Manager) produceItemEvents(ctx context.Context, before map[string]database.Item, written []database.Item) {
+	if m.producer == nil {
+		return
+	}
+
+	id := tenant.From(ctx)
+	var changes []kafka.Event
+	for _, item := range written {
+		old, existed := before[item.ExternalID]
+		if existed && !old.ChangedBy(item) {
+			continue
+		}
+		changes = append(changes, kafka.Event{
+			Type:     kafka.EventItemUpdated,
+			TenantID: id,
+			Key:      id + ":" + item.ExternalID,
+			Data: kafka.ItemUpdated{
+				ExternalID: item.ExternalID,
+				Title:      item.Title,
+				Body:       item.Body,
+				UserID:     item.UserID,
+				Created:    !existed,
+			},
+		})
+	}
+	m.produce(ctx, changes...)
+}
+
+// produce produces events. Like webhooks, producing is best effort:
+// failures are logged and never fail the sync.
+func (m *Manager) produce(ctx context.Context, events ...kafka.Event) {
+	if m.producer == nil || len(events) == 0 {
+		return
+	}
+
+	log := m.logger.FromContext(ctx)
+	if err := m.producer.Produce(ctx, events...); err != nil {
+		log.WithError(err).Warn("Failed to produce Kafka events")
+		return
+	}
+	log.WithField("count", len(events)).Debug("Produced Kafka events")
+}
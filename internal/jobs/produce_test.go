@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/kafka"
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProducer records the events produced
+type fakeProducer struct {
+	produced []kafka.Event
+}
+
+func (p *fakeProducer) Produce(ctx context.Context, events ...kafka.Event) error {
+	p.produced = append(p.produced, events...)
+	return nil
+}
+
+func TestProduceItemEvents(t *testing.T) {
+	m := New(nil, nil, nil, nil, nil, config.JobsConfig{}, logger.New())
+	producer := &fakeProducer{}
+	m.producer = producer
+
+	before := map[string]database.Item{
+		"1": {ExternalID: "1", Title: "same", Body: "body", UserID: 1},
+		"2": {ExternalID: "2", Title: "old", Body: "body", UserID: 1},
+	}
+	written := []database.Item{
+		{ExternalID: "1", Title: "same", Body: "body", UserID: 1},
+		{ExternalID: "2", Title: "new", Body: "body", UserID: 1},
+		{ExternalID: "3", Title: "created", Body: "body", UserID: 2},
+	}
+
+	ctx := tenant.With(context.Background(), "acme")
+	m.produceItemEvents(ctx, before, written)
+
+	// The unchanged item produces nothing
+	require.Len(t, producer.produced, 2)
+	assert.Equal(t, kafka.Event{
+		Type:     kafka.EventItemUpdated,
+		TenantID: "acme",
+		Key:      "acme:2",
+		Data:     kafka.ItemUpdated{ExternalID: "2", Title: "new", Body: "body", UserID: 1},
+	}, producer.produced[0])
+	assert.Equal(t, "acme:3", producer.produced[1].Key)
+	assert.True(t, producer.produced[1].Data.(kafka.ItemUpdated).Created)
+}
+
+func TestProduceToNil(t *testing.T) {
+	m := New(nil, nil, nil, nil, nil, config.JobsConfig{}, logger.New())
+	m.ProduceTo(nil)
+	assert.Nil(t, m.producer)
+}
@@ -0,0 +1,419 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/logger"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+// JobFunc is the body of a scheduled job
+type JobFunc func() error
+
+// Job run statuses
+const (
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobSkipped   = "skipped" // another instance held the job's lock
+)
+
+const (
+	// jobPausedKeyPrefix marks a job as paused on every instance
+	jobPausedKeyPrefix = "jobs:paused:"
+	// jobLastRunKeyPrefix holds a job's last run, from whichever instance
+	// ran it
+	jobLastRunKeyPrefix = "jobs:last_run:"
+)
+
+// ErrJobNotFound is returned for a job name that isn't registered
+var ErrJobNotFound = errors.New("job not found")
+
+// JobRun is the outcome of a run of a job
+type JobRun struct {
+	Trigger    string    `json:"trigger"` // scheduled, startup or manual
+	Status     string    `json:"status"`  // succeeded, failed or skipped
+	Error      string    `json:"error,omitempty"`
+	Instance   string    `json:"instance"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// JobInfo describes a registered job
+type JobInfo struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule"`
+	Paused   bool       `json:"paused"`
+	PausedAt *time.Time `json:"paused_at,omitempty"`
+	NextRun  *time.Time `json:"next_run,omitempty"` // by the schedule, none while paused
+	LastRun  *JobRun    `json:"last_run,omitempty"`
+}
+
+// jobPause is the value of a job's paused marker
+type jobPause struct {
+	PausedAt time.Time `json:"paused_at"`
+}
+
+// cronParser parses six-field cron specs, as cron.WithSeconds does
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// scheduledJob is a job in the registry
+type scheduledJob struct {
+	name     string
+	schedule string
+	spec     cron.Schedule
+	run      JobFunc
+	onStart  JobFunc // optional run when the manager starts
+	entry    cron.EntryID
+}
+
+// addToCron parses schedule and adds the scheduled runs of the job called
+// name to the scheduler
+func (m *Manager) addToCron(name, schedule string, fn JobFunc) (cron.Schedule, cron.EntryID, error) {
+	spec, err := cronParser.Parse(schedule)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid schedule %q for job %q: %w", schedule, name, err)
+	}
+	entry := m.cron.Schedule(spec, cron.FuncJob(func() { m.runJob(name, TriggerScheduled, fn) }))
+	return spec, entry, nil
+}
+
+// Register schedules fn under name using a six-field cron spec (with
+// seconds). Jobs can be registered before or after Start; runs are drained
+// by Stop like the built-in jobs.
+func (m *Manager) Register(name, schedule string, fn JobFunc) error {
+	return m.register(name, schedule, fn, nil)
+}
+
+// register adds a job to the registry, optionally also running onStart
+// once when the manager starts
+func (m *Manager) register(name, schedule string, fn, onStart JobFunc) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, j := range m.jobs {
+		if j.name == name {
+			return fmt.Errorf("job %q is already registered", name)
+		}
+	}
+
+	spec, entry, err := m.addToCron(name, schedule, fn)
+	if err != nil {
+		return err
+	}
+
+	m.jobs = append(m.jobs, scheduledJob{name: name, schedule: schedule, spec: spec, run: fn, onStart: onStart, entry: entry})
+	return nil
+}
+
+// Reschedule moves the job called name to a new six-field cron spec. A run
+// already in progress finishes; later runs follow the new schedule.
+func (m *Manager) Reschedule(name, schedule string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.jobs {
+		j := &m.jobs[i]
+		if j.name != name {
+			continue
+		}
+		if j.schedule == schedule {
+			return nil
+		}
+
+		spec, entry, err := m.addToCron(name, schedule, j.run)
+		if err != nil {
+			return err
+		}
+		m.cron.Remove(j.entry)
+		j.schedule, j.spec, j.entry = schedule, spec, entry
+		return nil
+	}
+	return fmt.Errorf("job %q is not registered", name)
+}
+
+// ApplyConfig moves the built-in jobs to the schedules of a reloaded
+// configuration. Enabling or disabling a job takes a restart.
+func (m *Manager) ApplyConfig(cfg *config.Config) {
+	schedules := m.builtinSchedules(cfg.Jobs)
+
+	m.mu.Lock()
+	current := make(map[string]string, len(m.jobs))
+	for _, j := range m.jobs {
+		current[j.name] = j.schedule
+	}
+	m.mu.Unlock()
+
+	for name, schedule := range schedules {
+		prev, ok := current[name]
+		if !ok || prev == schedule {
+			continue
+		}
+		log := m.logger.WithField("job", name)
+		if err := m.Reschedule(name, schedule); err != nil {
+			log.WithError(err).Error("Failed to reschedule job")
+			continue
+		}
+		log.WithField("schedule", schedule).Info("Job rescheduled")
+	}
+}
+
+// builtinSchedules returns the schedule cfg sets for each built-in job, by
+// job name
+func (m *Manager) builtinSchedules(cfg config.JobsConfig) map[string]string {
+	schedules := map[string]string{
+		"sync":                cfg.SyncSchedule,
+		"analytics_reconcile": cfg.AnalyticsSchedule,
+		"order_stats":         cfg.OrderStatsSchedule,
+		"webhook_delivery":    cfg.WebhookDeliverySchedule,
+		"item_retry":          cfg.ItemRetrySchedule,
+		"outbox_relay":        cfg.OutboxRelaySchedule,
+	}
+	if cfg.ResourceSyncSchedule != "" {
+		schedules["sync_resources"] = cfg.ResourceSyncSchedule
+		return schedules
+	}
+	for _, r := range m.resourceSyncs(cfg) {
+		schedules["sync_"+r.name] = r.schedule
+	}
+	return schedules
+}
+
+// registerBuiltins registers the sync, analytics, order stats, item retry,
+// outbox relay and webhook delivery jobs that are enabled
+func (m *Manager) registerBuiltins(cfg config.JobsConfig) {
+	if cfg.SyncEnabled {
+		err := m.register("sync", cfg.SyncSchedule,
+			func() error { return m.syncData(TriggerScheduled, logger.NewRequestID(), nil) },
+			func() error { return m.syncData(TriggerStartup, logger.NewRequestID(), nil) },
+		)
+		if err != nil {
+			m.logger.WithError(err).Error("Failed to schedule sync job")
+		}
+	}
+
+	if cfg.AnalyticsEnabled {
+		err := m.register("analytics_reconcile", cfg.AnalyticsSchedule, m.reconcileAnalytics, m.reconcileAnalytics)
+		if err != nil {
+			m.logger.WithError(err).Error("Failed to schedule analytics reconcile job")
+		}
+	}
+
+	if cfg.OrderStatsEnabled {
+		if err := m.register("order_stats", cfg.OrderStatsSchedule, m.refreshOrderStats, nil); err != nil {
+			m.logger.WithError(err).Error("Failed to schedule order stats job")
+		}
+	}
+
+	if cfg.ResourceSyncEnabled && cfg.ResourceSyncSchedule != "" {
+		resources := m.resourceSyncs(cfg)
+		run := func() error { return m.syncResources(resources) }
+		if err := m.register("sync_resources", cfg.ResourceSyncSchedule, run, run); err != nil {
+			m.logger.WithError(err).Error("Failed to schedule resource sync job")
+		}
+	} else if cfg.ResourceSyncEnabled {
+		for _, r := range m.resourceSyncs(cfg) {
+			r := r
+			run := func() error { return m.syncResource(r) }
+			if err := m.register("sync_"+r.name, r.schedule, run, run); err != nil {
+				m.logger.WithError(err).WithField("resource", r.name).Error("Failed to schedule resource sync job")
+			}
+		}
+	}
+
+	if cfg.ItemRetryEnabled {
+		if err := m.register("item_retry", cfg.ItemRetrySchedule, m.retryItems, nil); err != nil {
+			m.logger.WithError(err).Error("Failed to schedule item retry job")
+		}
+	}
+
+	if cfg.Outbox {
+		if err := m.register("outbox_relay", cfg.OutboxRelaySchedule, m.relayOutbox, m.relayOutbox); err != nil {
+			m.logger.WithError(err).Error("Failed to schedule outbox relay job")
+		}
+	}
+
+	if cfg.WebhookDeliveryEnabled && m.webhooks != nil {
+		if err := m.register("webhook_delivery", cfg.WebhookDeliverySchedule, m.deliverWebhooks, nil); err != nil {
+			m.logger.WithError(err).Error("Failed to schedule webhook delivery job")
+		}
+	}
+}
+
+// Jobs lists the registered jobs in registration order. Pauses and last
+// runs are kept in Redis, so every instance reports the same.
+func (m *Manager) Jobs(ctx context.Context) ([]JobInfo, error) {
+	m.mu.Lock()
+	jobs := append([]scheduledJob(nil), m.jobs...)
+	m.mu.Unlock()
+
+	keys := make([]string, 0, 2*len(jobs))
+	for _, j := range jobs {
+		keys = append(keys, jobPausedKeyPrefix+j.name, jobLastRunKeyPrefix+j.name)
+	}
+	values, err := m.redis.MGetJSON(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job state: %w", err)
+	}
+
+	now := time.Now()
+	infos := make([]JobInfo, len(jobs))
+	for i, j := range jobs {
+		info := JobInfo{Name: j.name, Schedule: j.schedule}
+		if raw := values[2*i]; raw != nil {
+			var pause jobPause
+			if err := json.Unmarshal(raw, &pause); err == nil {
+				info.PausedAt = &pause.PausedAt
+			}
+			info.Paused = true
+		} else {
+			next := j.spec.Next(now).UTC()
+			info.NextRun = &next
+		}
+		if raw := values[2*i+1]; raw != nil {
+			var run JobRun
+			if err := json.Unmarshal(raw, &run); err == nil {
+				info.LastRun = &run
+			}
+		}
+		infos[i] = info
+	}
+	return infos, nil
+}
+
+// RunJob starts a run of the job called name in the background, whether
+// or not it's paused. The run takes the job's lock like a scheduled one.
+func (m *Manager) RunJob(name string) error {
+	j, ok := m.job(name)
+	if !ok {
+		return ErrJobNotFound
+	}
+	if !m.begin() {
+		return ErrStopped
+	}
+
+	go func() {
+		defer m.running.Done()
+		m.execute(name, TriggerManual, j.run)
+	}()
+	return nil
+}
+
+// PauseJob stops scheduled and startup runs of the job called name on
+// every instance until ResumeJob
+func (m *Manager) PauseJob(ctx context.Context, name string) error {
+	if _, ok := m.job(name); !ok {
+		return ErrJobNotFound
+	}
+	if err := m.redis.SetJSON(ctx, jobPausedKeyPrefix+name, jobPause{PausedAt: time.Now().UTC()}, 0); err != nil {
+		return fmt.Errorf("failed to pause job: %w", err)
+	}
+	return nil
+}
+
+// ResumeJob lets the job called name run on its schedule again
+func (m *Manager) ResumeJob(ctx context.Context, name string) error {
+	if _, ok := m.job(name); !ok {
+		return ErrJobNotFound
+	}
+	if err := m.redis.Del(ctx, jobPausedKeyPrefix+name).Err(); err != nil {
+		return fmt.Errorf("failed to resume job: %w", err)
+	}
+	return nil
+}
+
+// job returns the job called name
+func (m *Manager) job(name string) (scheduledJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, j := range m.jobs {
+		if j.name == name {
+			return j, true
+		}
+	}
+	return scheduledJob{}, false
+}
+
+// paused reports whether the job called name is paused. If Redis can't be
+// reached the job runs, as it did before pausing existed.
+func (m *Manager) paused(name string) bool {
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+
+	err := m.redis.Get(ctx, jobPausedKeyPrefix+name).Err()
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		m.logger.WithError(err).WithField("job", name).Warn("Failed to check whether job is paused")
+	}
+	return err == nil
+}
+
+// recordRun stores run as the last run of the job called name
+func (m *Manager) recordRun(name string, run *JobRun) {
+	run.FinishedAt = time.Now().UTC()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := m.redis.SetJSON(ctx, jobLastRunKeyPrefix+name, run, 0); err != nil {
+		m.logger.WithError(err).WithField("job", name).Warn("Failed to record job run")
+	}
+}
+
+// runJob runs a job and logs its outcome, unless Stop has been called;
+// see execute
+func (m *Manager) runJob(name, trigger string, fn JobFunc) {
+	if !m.begin() {
+		return
+	}
+	defer m.running.Done()
+	m.execute(name, trigger, fn)
+}
+
+// execute runs a job registered by begin and logs its outcome. Scheduled
+// and startup runs of a paused job are skipped. A run skipped because
+// another instance holds the job's lock is not an error. Failures and
+// panics are sent to the error reporter, and the outcome is recorded as the
+// job's last run.
+func (m *Manager) execute(name, trigger string, fn JobFunc) {
+	log := m.logger.WithField("job", name)
+	tags := map[string]string{"job": name}
+
+	if trigger != TriggerManual && m.paused(name) {
+		log.Debug("Job paused, skipping run")
+		return
+	}
+
+	run := &JobRun{Trigger: trigger, Instance: m.instance, StartedAt: time.Now().UTC()}
+	defer func() {
+		if r := recover(); r != nil {
+			log.WithField("panic", r).Error("Scheduled job panicked")
+			m.reporter.CapturePanic(r, nil, tags)
+			run.Status, run.Error = JobFailed, fmt.Sprint("panic: ", r)
+		}
+		if run.Status != "" {
+			m.recordRun(name, run)
+		}
+	}()
+
+	err := fn()
+	switch {
+	case err == nil:
+		run.Status = JobSucceeded
+	case errors.Is(err, ErrSyncInProgress):
+		log.Info("Job already running on another instance, skipping")
+		run.Status = JobSkipped
+	case errors.Is(err, ErrStopped):
+		log.Debug("Job manager stopping, skipping run")
+	default:
+		log.WithError(err).Error("Scheduled job failed")
+		m.reporter.CaptureError(err, nil, tags)
+		run.Status, run.Error = JobFailed, err.Error()
+	}
+}
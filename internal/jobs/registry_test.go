@@ -0,0 +1,256 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/redis"
+	"api-gateway-backend/internal/webhooks"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis keeps the keys the job registry uses, the item retry queue and
+// the ingest stream in memory
+type fakeRedis struct {
+	redis.CacheClient
+
+	mu       sync.Mutex
+	values   map[string]string
+	queued   map[string]queuedValue
+	streamed []json.RawMessage
+	backlog  int64 // reported stream length
+}
+
+// queuedValue is an entry of a delay queue
+type queuedValue struct {
+	value json.RawMessage
+	at    time.Time
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{values: make(map[string]string), queued: make(map[string]queuedValue)}
+}
+
+func (r *fakeRedis) Get(ctx context.Context, key string) *goredis.StringCmd {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value, ok := r.values[key]
+	if !ok {
+		return goredis.NewStringResult("", goredis.Nil)
+	}
+	return goredis.NewStringResult(value, nil)
+}
+
+func (r *fakeRedis) Del(ctx context.Context, keys ...string) *goredis.IntCmd {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range keys {
+		delete(r.values, key)
+	}
+	return goredis.NewIntResult(int64(len(keys)), nil)
+}
+
+func (r *fakeRedis) SetJSON(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[key] = string(data)
+	return nil
+}
+
+func (r *fakeRedis) MGetJSON(ctx context.Context, keys []string) ([]json.RawMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	values := make([]json.RawMessage, len(keys))
+	for i, key := range keys {
+		if value, ok := r.values[key]; ok {
+			values[i] = json.RawMessage(value)
+		}
+	}
+	return values, nil
+}
+
+func TestRegister(t *testing.T) {
+	m := New(nil, nil, nil, nil, nil, config.JobsConfig{}, logger.New())
+
+	assert.NoError(t, m.Register("cleanup", "0 0 * * * *", func() error { return nil }))
+	assert.Error(t, m.Register("cleanup", "0 0 * * * *", func() error { return nil }), "duplicate name")
+	assert.Error(t, m.Register("broken", "every hour", func() error { return nil }), "invalid schedule")
+	assert.Len(t, m.jobs, 1)
+}
+
+func TestNew_RegistersEnabledBuiltins(t *testing.T) {
+	cfg := config.JobsConfig{
+		SyncEnabled:       true,
+		SyncSchedule:      "0 */15 * * * *",
+		AnalyticsEnabled:  false,
+		AnalyticsSchedule: "0 */10 * * * *",
+	}
+	m := New(nil, nil, nil, nil, nil, cfg, logger.New())
+
+	if assert.Len(t, m.jobs, 1) {
+		assert.Equal(t, "sync", m.jobs[0].name)
+		assert.Equal(t, "0 */15 * * * *", m.jobs[0].schedule)
+	}
+}
+
+func TestNew_RegistersResourceSyncs(t *testing.T) {
+	cfg := config.JobsConfig{
+		ResourceSyncEnabled:  true,
+		UsersSyncSchedule:    "0 0 * * * *",
+		CommentsSyncSchedule: "0 20 * * * *",
+		TodosSyncSchedule:    "0 40 * * * *",
+	}
+	m := New(nil, nil, nil, nil, nil, cfg, logger.New())
+
+	var names []string
+	for _, j := range m.jobs {
+		names = append(names, j.name)
+		assert.NotNil(t, j.onStart)
+	}
+	assert.Equal(t, []string{"sync_users", "sync_comments", "sync_todos"}, names)
+}
+
+func TestNew_RegistersCombinedResourceSync(t *testing.T) {
+	cfg := config.JobsConfig{
+		ResourceSyncEnabled:  true,
+		UsersSyncSchedule:    "0 0 * * * *",
+		CommentsSyncSchedule: "0 20 * * * *",
+		TodosSyncSchedule:    "0 40 * * * *",
+		ResourceSyncSchedule: "0 30 * * * *",
+	}
+	m := New(nil, nil, nil, nil, nil, cfg, logger.New())
+
+	if assert.Len(t, m.jobs, 1) {
+		assert.Equal(t, "sync_resources", m.jobs[0].name)
+		assert.Equal(t, "0 30 * * * *", m.jobs[0].schedule)
+		assert.NotNil(t, m.jobs[0].onStart)
+	}
+	assert.Equal(t, defaultResourceSyncConcurrency, m.resourceConcurrency)
+}
+
+func TestNew_RegistersOrderStats(t *testing.T) {
+	cfg := config.JobsConfig{
+		OrderStatsEnabled:  true,
+		OrderStatsSchedule: "0 0 2 * * *",
+	}
+	m := New(nil, nil, nil, nil, nil, cfg, logger.New())
+
+	if assert.Len(t, m.jobs, 1) {
+		assert.Equal(t, "order_stats", m.jobs[0].name)
+		assert.Nil(t, m.jobs[0].onStart, "refreshed on its schedule only")
+	}
+}
+
+func TestNew_RegistersWebhookDeliveryWithNotifier(t *testing.T) {
+	cfg := config.JobsConfig{
+		WebhookDeliveryEnabled:  true,
+		WebhookDeliverySchedule: "*/15 * * * * *",
+	}
+
+	m := New(nil, nil, nil, nil, nil, cfg, logger.New())
+	assert.Empty(t, m.jobs, "no notifier to deliver through")
+
+	notifier := webhooks.NewNotifier(nil, config.WebhookConfig{}, logger.New())
+	m = New(nil, nil, nil, notifier, nil, cfg, logger.New())
+	if assert.Len(t, m.jobs, 1) {
+		assert.Equal(t, "webhook_delivery", m.jobs[0].name)
+		assert.Nil(t, m.jobs[0].onStart)
+	}
+}
+
+func TestApplyConfig_Reschedules(t *testing.T) {
+	cfg := &config.Config{Jobs: config.JobsConfig{
+		SyncEnabled:       true,
+		SyncSchedule:      "0 */15 * * * *",
+		AnalyticsSchedule: "0 */10 * * * *",
+	}}
+	m := New(nil, nil, nil, nil, nil, cfg.Jobs, logger.New())
+	entry := m.jobs[0].entry
+
+	// Unchanged schedules keep their cron entry
+	m.ApplyConfig(cfg)
+	assert.Equal(t, entry, m.jobs[0].entry)
+
+	cfg.Jobs.SyncSchedule = "0 */5 * * * *"
+	m.ApplyConfig(cfg)
+	if assert.Len(t, m.jobs, 1, "disabled jobs stay unregistered") {
+		assert.Equal(t, "0 */5 * * * *", m.jobs[0].schedule)
+		assert.NotEqual(t, entry, m.jobs[0].entry)
+		assert.Len(t, m.cron.Entries(), 1)
+	}
+
+	assert.Error(t, m.Reschedule("sync", "every five minutes"))
+	assert.Error(t, m.Reschedule("missing", "0 * * * * *"))
+	assert.Equal(t, "0 */5 * * * *", m.jobs[0].schedule)
+}
+
+func TestJobs_PauseRunResume(t *testing.T) {
+	m := New(nil, nil, nil, nil, nil, config.JobsConfig{}, logger.New())
+	m.redis = newFakeRedis()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	runs := 0
+	fail := errors.New("upstream unavailable")
+	ran := make(chan struct{}, 1)
+	require.NoError(t, m.Register("cleanup", "0 0 * * * *", func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		runs++
+		ran <- struct{}{}
+		return fail
+	}))
+
+	jobs, err := m.Jobs(ctx)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "0 0 * * * *", jobs[0].Schedule)
+	assert.False(t, jobs[0].Paused)
+	if assert.NotNil(t, jobs[0].NextRun) {
+		assert.Zero(t, jobs[0].NextRun.Minute())
+		assert.True(t, jobs[0].NextRun.After(time.Now()))
+	}
+	assert.Nil(t, jobs[0].LastRun)
+
+	// Paused jobs skip their scheduled runs, and have no next run
+	require.NoError(t, m.PauseJob(ctx, "cleanup"))
+	m.runJob("cleanup", TriggerScheduled, m.jobs[0].run)
+	assert.Zero(t, runs)
+	jobs, _ = m.Jobs(ctx)
+	assert.True(t, jobs[0].Paused)
+	assert.NotNil(t, jobs[0].PausedAt)
+	assert.Nil(t, jobs[0].NextRun)
+
+	// A manual run goes ahead regardless, and is recorded as the last run
+	require.NoError(t, m.RunJob("cleanup"))
+	<-ran
+	m.running.Wait()
+	jobs, _ = m.Jobs(ctx)
+	if assert.NotNil(t, jobs[0].LastRun) {
+		assert.Equal(t, TriggerManual, jobs[0].LastRun.Trigger)
+		assert.Equal(t, JobFailed, jobs[0].LastRun.Status)
+		assert.Equal(t, "upstream unavailable", jobs[0].LastRun.Error)
+		assert.Equal(t, m.instance, jobs[0].LastRun.Instance)
+	}
+
+	require.NoError(t, m.ResumeJob(ctx, "cleanup"))
+	m.runJob("cleanup", TriggerScheduled, m.jobs[0].run)
+	assert.Equal(t, 2, runs)
+
+	assert.ErrorIs(t, m.RunJob("missing"), ErrJobNotFound)
+	assert.ErrorIs(t, m.PauseJob(ctx, "missing"), ErrJobNotFound)
+	assert.ErrorIs(t, m.ResumeJob(ctx, "missing"), ErrJobNotFound)
+}
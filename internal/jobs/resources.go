@@ -0,0 +1,168 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"api-gateway-backend/internal/client"
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/logger"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// resourceSync copies one external API resource into its table
+type resourceSync struct {
+	name     string
+	schedule string
+	// sync fetches and upserts the resource, returning the rows written
+	sync func(ctx context.Context, upstream *client.Upstream) (int, error)
+}
+
+// resourceSyncs lists the resources synced besides posts
+func (m *Manager) resourceSyncs(cfg config.JobsConfig) []resourceSync {
+	return []resourceSync{
+		{name: "users", schedule: cfg.UsersSyncSchedule, sync: m.syncUsers},
+		{name: "comments", schedule: cfg.CommentsSyncSchedule, sync: m.syncComments},
+		{name: "todos", schedule: cfg.TodosSyncSchedule, sync: m.syncTodos},
+	}
+}
+
+// defaultResourceSyncConcurrency applies when no positive concurrency is
+// configured
+const defaultResourceSyncConcurrency = 3
+
+// syncResource runs r on its own schedule
+func (m *Manager) syncResource(r resourceSync) error {
+	return m.syncResources([]resourceSync{r})
+}
+
+// syncResources runs rs from the default upstream concurrently, at most
+// resourceConcurrency at a time. The first failure cancels the others, so
+// a run fails as a whole like a single sync does.
+func (m *Manager) syncResources(rs []resourceSync) error {
+	if !m.begin() {
+		return ErrStopped
+	}
+	defer m.running.Done()
+
+	ctx, cancel := context.WithTimeout(m.ctx, 2*time.Minute)
+	defer cancel()
+
+	ctx = logger.ContextWithRequestID(ctx, logger.NewRequestID())
+
+	upstream, err := m.upstreams.Get(config.DefaultUpstream)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(m.resourceConcurrency)
+	for _, r := range rs {
+		r := r
+		g.Go(func() error { return m.runResourceSync(ctx, r, upstream) })
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	if len(rs) > 1 {
+		m.logger.FromContext(ctx).WithFields(map[string]interface{}{
+			"resources": len(rs),
+			"duration":  time.Since(start),
+		}).Info("Resource syncs completed")
+	}
+	return nil
+}
+
+// runResourceSync runs r under its own lock, so with several replicas only
+// one instance syncs each resource per tick
+func (m *Manager) runResourceSync(ctx context.Context, r resourceSync, upstream *client.Upstream) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	log := m.logger.FromContext(ctx).WithField("resource", r.name)
+
+	release, err := m.holdSyncLock(ctx, syncLockKey+":"+r.name, cancel)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	start := time.Now()
+	count, err := r.sync(ctx, upstream)
+	if err != nil {
+		return fmt.Errorf("failed to sync %s: %w", r.name, err)
+	}
+
+	log.WithFields(map[string]interface{}{
+		"count":    count,
+		"duration": time.Since(start),
+	}).Info("Resource sync completed")
+	return nil
+}
+
+// syncUsers fetches and upserts users
+func (m *Manager) syncUsers(ctx context.Context, upstream *client.Upstream) (int, error) {
+	fetched, err := client.FetchUsers(ctx, upstream)
+	if err != nil {
+		return 0, err
+	}
+
+	users := make([]database.User, len(fetched))
+	for i, u := range fetched {
+		users[i] = database.User{
+			ExternalID:  strconv.Itoa(u.ID),
+			Name:        u.Name,
+			Username:    u.Username,
+			Email:       u.Email,
+			Phone:       u.Phone,
+			Website:     u.Website,
+			CompanyName: u.Company.Name,
+		}
+	}
+	return len(users), m.db.UpsertUsers(ctx, users, m.batchSize)
+}
+
+// syncComments fetches and upserts comments
+func (m *Manager) syncComments(ctx context.Context, upstream *client.Upstream) (int, error) {
+	fetched, err := client.FetchComments(ctx, upstream)
+	if err != nil {
+		return 0, err
+	}
+
+	comments := make([]database.Comment, len(fetched))
+	for i, c := range fetched {
+		comments[i] = database.Comment{
+			ExternalID: strconv.Itoa(c.ID),
+			PostID:     c.PostID,
+			Name:       c.Name,
+			Email:      c.Email,
+			Body:       c.Body,
+		}
+	}
+	return len(comments), m.db.UpsertComments(ctx, comments, m.batchSize)
+}
+
+// syncTodos fetches and upserts todos
+func (m *Manager) syncTodos(ctx context.Context, upstream *client.Upstream) (int, error) {
+	fetched, err := client.FetchTodos(ctx, upstream)
+	if err != nil {
+		return 0, err
+	}
+
+	todos := make([]database.Todo, len(fetched))
+	for i, t := range fetched {
+		todos[i] = database.Todo{
+			ExternalID: strconv.Itoa(t.ID),
+			UserID:     t.UserID,
+			Title:      t.Title,
+			Completed:  t.Completed,
+		}
+	}
+	return len(todos), m.db.UpsertTodos(ctx, todos, m.batchSize)
+}
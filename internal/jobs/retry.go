@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/tenant"
+)
+
+const (
+	// itemRetryQueue is the Redis delay queue of items whose upsert failed
+	itemRetryQueue = "items:retry"
+	// itemRetryBatchSize is how many due items one run retries at most
+	itemRetryBatchSize = 100
+	// maxItemRetryDelay caps the exponential backoff between attempts
+	maxItemRetryDelay = time.Hour
+	// maxItemErrorLength truncates stored upsert errors
+	maxItemErrorLength = 1024
+)
+
+// itemRetry is an item waiting in the retry queue
+type itemRetry struct {
+	Item          database.Item `json:"item"`
+	Attempts      int           `json:"attempts"` // failed upserts so far, the sync's included
+	LastError     string        `json:"last_error"`
+	FirstFailedAt time.Time     `json:"first_failed_at"`
+}
+
+// itemRetryID identifies an item in the retry queue, so a later failure of
+// the same item replaces its entry
+func itemRetryID(tenantID, externalID string) string {
+	return tenantID + ":" + externalID
+}
+
+// retryItemsLater queues items whose upsert failed during a sync for
+// another attempt. Queueing is best effort: a failure is logged and the
+// item waits for the next sync, as it did before retries existed.
+func (m *Manager) retryItemsLater(ctx context.Context, failed []itemError) {
+	if !m.itemRetry || len(failed) == 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, f := range failed {
+		item := f.item
+		item.TenantID = tenant.From(ctx)
+		m.retryOrGiveUp(ctx, &itemRetry{Item: item, FirstFailedAt: now}, f.err)
+	}
+	m.logger.FromContext(ctx).WithField("count", len(failed)).Info("Queued failed items for retry")
+}
+
+// dropItemRetries removes the items a sync wrote from the retry queue, so a
+// queued older version can't overwrite them
+func (m *Manager) dropItemRetries(ctx context.Context, items []database.Item, failedIDs map[string]bool) {
+	if !m.itemRetry {
+		return
+	}
+
+	id := tenant.From(ctx)
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		if !failedIDs[item.ExternalID] {
+			ids = append(ids, itemRetryID(id, item.ExternalID))
+		}
+	}
+	if err := m.redis.Dequeue(ctx, itemRetryQueue, ids...); err != nil {
+		m.logger.FromContext(ctx).WithError(err).Warn("Failed to drop synced items from the retry queue")
+	}
+}
+
+// retryItems upserts the items due in the retry queue. It holds the sync
+// lock, so it never races a sync writing the same items. Claimed items are
+// taken off the queue, so ones a crash interrupts are left to the next sync.
+func (m *Manager) retryItems() error {
+	if !m.begin() {
+		return ErrStopped
+	}
+	defer m.running.Done()
+
+	ctx, cancel := context.WithTimeout(m.ctx, 2*time.Minute)
+	defer cancel()
+
+	ctx = logger.ContextWithRequestID(ctx, logger.NewRequestID())
+	log := m.logger.FromContext(ctx)
+
+	release, err := m.holdSyncLock(ctx, syncLockKey, cancel)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	values, err := m.redis.ClaimDue(ctx, itemRetryQueue, time.Now(), itemRetryBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to claim item retries: %w", err)
+	}
+
+	var retried, failed int
+	retriedTenants := make(map[string]bool)
+	for i, value := range values {
+		var r itemRetry
+		if err := json.Unmarshal(value, &r); err != nil {
+			log.WithError(err).Warn("Dropping unreadable item retry")
+			continue
+		}
+		if ctx.Err() != nil {
+			// Put back what this run didn't get to, without counting it
+			// as an attempt
+			m.putBack(values[i:])
+			break
+		}
+
+		itemCtx := tenant.With(ctx, r.Item.TenantID)
+		if err := m.db.UpsertItem(itemCtx, &r.Item); err != nil {
+			failed++
+			m.retryOrGiveUp(itemCtx, &r, err)
+			continue
+		}
+		retried++
+		retriedTenants[r.Item.TenantID] = true
+	}
+
+	m.invalidateItems(ctx, retriedTenants)
+	if retried > 0 || failed > 0 {
+		log.WithFields(map[string]interface{}{
+			"retried": retried,
+			"failed":  failed,
+		}).Info("Retried failed items")
+	}
+	return ctx.Err()
+}
+
+// retryOrGiveUp records a failed upsert of r, queueing it for another
+// attempt after an exponential backoff or, once it has used its attempts,
+// dead-lettering it into the failed_items table
+func (m *Manager) retryOrGiveUp(ctx context.Context, r *itemRetry, err error) {
+	log := m.logger.FromContext(ctx).WithField("external_id", r.Item.ExternalID)
+
+	r.Attempts++
+	r.LastError = err.Error()
+	if len(r.LastError) > maxItemErrorLength {
+		r.LastError = r.LastError[:maxItemErrorLength]
+	}
+
+	if r.Attempts < m.retryAttempts {
+		at := time.Now().Add(m.itemBackoff(r.Attempts))
+		if err := m.enqueueRetry(ctx, r, at); err != nil {
+			log.WithError(err).Warn("Failed to queue item retry")
+		}
+		return
+	}
+
+	dead := &database.FailedItem{
+		TenantID:      r.Item.TenantID,
+		ExternalID:    r.Item.ExternalID,
+		Title:         r.Item.Title,
+		Body:          r.Item.Body,
+		UserID:        r.Item.UserID,
+		Attempts:      r.Attempts,
+		LastError:     r.LastError,
+		FirstFailedAt: r.FirstFailedAt,
+	}
+	if err := m.db.SaveFailedItem(ctx, dead); err != nil {
+		// Keep the item queued rather than lose it
+		log.WithError(err).Error("Failed to dead-letter item, retrying later")
+		if err := m.enqueueRetry(ctx, r, time.Now().Add(maxItemRetryDelay)); err != nil {
+			log.WithError(err).Warn("Failed to queue item retry")
+		}
+		return
+	}
+	log.WithField("attempts", r.Attempts).Warn("Item upsert failed, giving up")
+}
+
+// enqueueRetry queues r, due at at
+func (m *Manager) enqueueRetry(ctx context.Context, r *itemRetry, at time.Time) error {
+	return m.redis.EnqueueAt(ctx, itemRetryQueue, itemRetryID(r.Item.TenantID, r.Item.ExternalID), r, at)
+}
+
+// putBack returns claimed retries to the queue, due at once
+func (m *Manager) putBack(values []json.RawMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	for _, value := range values {
+		var r itemRetry
+		if err := json.Unmarshal(value, &r); err != nil {
+			continue
+		}
+		if err := m.enqueueRetry(ctx, &r, now); err != nil {
+			m.logger.WithError(err).Warn("Failed to return item retry to the queue")
+		}
+	}
+}
+
+// itemBackoff returns the wait after the given number of failed attempts
+func (m *Manager) itemBackoff(attempts int) time.Duration {
+	delay := m.retryDelay
+	for i := 1; i < attempts && delay < maxItemRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxItemRetryDelay {
+		delay = maxItemRetryDelay
+	}
+	return delay
+}
+
+// RequeueFailedItem moves the dead-lettered item with the given ID back to
+// the retry queue, due at once with a fresh set of attempts, and returns
+// it. database.ErrNotFound is returned if there is none.
+func (m *Manager) RequeueFailedItem(ctx context.Context, id int64) (*database.FailedItem, error) {
+	dead, err := m.db.GetFailedItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &itemRetry{
+		Item: database.Item{
+			TenantID:   dead.TenantID,
+			ExternalID: dead.ExternalID,
+			Title:      dead.Title,
+			Body:       dead.Body,
+			UserID:     dead.UserID,
+		},
+		LastError:     dead.LastError,
+		FirstFailedAt: dead.FirstFailedAt,
+	}
+	if err := m.enqueueRetry(ctx, r, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to queue item: %w", err)
+	}
+	if err := m.db.DeleteFailedItem(ctx, id); err != nil {
+		return nil, fmt.Errorf("failed to remove dead letter: %w", err)
+	}
+	return dead, nil
+}
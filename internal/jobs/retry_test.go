@@ -0,0 +1,115 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/logger"
+	"api-gateway-backend/internal/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (r *fakeRedis) EnqueueAt(ctx context.Context, queue, id string, value interface{}, at time.Time) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queued[queue+"/"+id] = queuedValue{value: data, at: at}
+	return nil
+}
+
+// fakeFailedItems keeps dead-lettered items in memory
+type fakeFailedItems struct {
+	database.Store
+	items map[int64]database.FailedItem
+}
+
+func (s *fakeFailedItems) SaveFailedItem(ctx context.Context, item *database.FailedItem) error {
+	s.items[int64(len(s.items)+1)] = *item
+	return nil
+}
+
+func (s *fakeFailedItems) GetFailedItem(ctx context.Context, id int64) (*database.FailedItem, error) {
+	item, ok := s.items[id]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	return &item, nil
+}
+
+func (s *fakeFailedItems) DeleteFailedItem(ctx context.Context, id int64) error {
+	if _, ok := s.items[id]; !ok {
+		return database.ErrNotFound
+	}
+	delete(s.items, id)
+	return nil
+}
+
+func TestItemBackoff(t *testing.T) {
+	m := New(nil, nil, nil, nil, nil, config.JobsConfig{ItemRetryDelay: 30}, logger.New())
+
+	assert.Equal(t, 30*time.Second, m.itemBackoff(1))
+	assert.Equal(t, time.Minute, m.itemBackoff(2))
+	assert.Equal(t, 2*time.Minute, m.itemBackoff(3))
+	assert.Equal(t, maxItemRetryDelay, m.itemBackoff(20))
+}
+
+func TestRetryOrGiveUp(t *testing.T) {
+	cfg := config.JobsConfig{ItemRetryEnabled: true, ItemRetryMaxAttempts: 3, ItemRetryDelay: 30}
+	m := New(nil, nil, nil, nil, nil, cfg, logger.New())
+	rdb, db := newFakeRedis(), &fakeFailedItems{items: make(map[int64]database.FailedItem)}
+	m.redis, m.db = rdb, db
+	ctx := tenant.With(context.Background(), "acme")
+	key := itemRetryQueue + "/acme:42"
+
+	// A failed sync upsert is queued for its second attempt
+	fail := errors.New("deadlock found")
+	m.retryItemsLater(ctx, []itemError{{item: database.Item{ExternalID: "42", Title: "Post"}, err: fail}})
+	queued, ok := rdb.queued[key]
+	require.True(t, ok)
+	var r itemRetry
+	require.NoError(t, json.Unmarshal(queued.value, &r))
+	assert.Equal(t, 1, r.Attempts)
+	assert.Equal(t, "acme", r.Item.TenantID)
+	assert.Equal(t, "deadlock found", r.LastError)
+	assert.WithinDuration(t, time.Now().Add(30*time.Second), queued.at, time.Second)
+
+	// Each failed retry backs off further
+	m.retryOrGiveUp(ctx, &r, fail)
+	assert.Equal(t, 2, r.Attempts)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), rdb.queued[key].at, time.Second)
+	assert.Empty(t, db.items)
+
+	// The last attempt dead-letters the item
+	delete(rdb.queued, key)
+	m.retryOrGiveUp(ctx, &r, fail)
+	assert.NotContains(t, rdb.queued, key)
+	if assert.Len(t, db.items, 1) {
+		dead := db.items[1]
+		assert.Equal(t, "acme", dead.TenantID)
+		assert.Equal(t, "42", dead.ExternalID)
+		assert.Equal(t, 3, dead.Attempts)
+		assert.Equal(t, r.FirstFailedAt, dead.FirstFailedAt)
+	}
+
+	// Requeueing moves it back to the queue, due at once with fresh attempts
+	item, err := m.RequeueFailedItem(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "42", item.ExternalID)
+	assert.Empty(t, db.items)
+	require.NoError(t, json.Unmarshal(rdb.queued[key].value, &r))
+	assert.Zero(t, r.Attempts)
+	assert.WithinDuration(t, time.Now(), rdb.queued[key].at, time.Second)
+
+	_, err = m.RequeueFailedItem(ctx, 1)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+}
@@ -0,0 +1,123 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"api-gateway-backend/internal/logger"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Sync job statuses
+const (
+	SyncQueued    = "queued"
+	SyncRunning   = "running"
+	SyncSucceeded = "succeeded"
+	SyncFailed    = "failed"
+)
+
+const (
+	syncJobKeyPrefix = "sync:job:"
+	syncJobTTL       = 24 * time.Hour
+
+	// maxSyncJobErrors caps the error messages kept on a job or run record
+	maxSyncJobErrors = 20
+)
+
+// ErrSyncJobNotFound is returned when a sync job ID is unknown or expired
+var ErrSyncJobNotFound = errors.New("sync job not found")
+
+// SyncJob tracks the status and progress of an asynchronous sync run
+type SyncJob struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Succeeded  int        `json:"succeeded"`
+	Failed     int        `json:"failed"`
+	Errors     []string   `json:"errors,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// addError records an error message, keeping at most maxSyncJobErrors
+func (j *SyncJob) addError(msg string) {
+	if len(j.Errors) < maxSyncJobErrors {
+		j.Errors = append(j.Errors, msg)
+	}
+}
+
+// StartSync queues a sync run in the background and returns its job
+// record. Progress is stored in Redis so any instance can serve polls.
+func (m *Manager) StartSync(ctx context.Context) (*SyncJob, error) {
+	job := &SyncJob{
+		ID:        logger.NewRequestID(),
+		Status:    SyncQueued,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := m.redis.SetJSON(ctx, syncJobKeyPrefix+job.ID, job, syncJobTTL); err != nil {
+		return nil, fmt.Errorf("failed to record sync job: %w", err)
+	}
+
+	// The run outlives the request, so only its request ID is carried over
+	requestID := logger.RequestIDFromContext(ctx)
+	queued := *job
+
+	go func() {
+		err := m.syncData(TriggerAsync, requestID, job)
+		if err != nil && !errors.Is(err, ErrSyncInProgress) && !errors.Is(err, ErrStopped) {
+			m.logger.WithError(err).WithField("job_id", job.ID).Error("Async sync failed")
+			m.reporter.CaptureError(err, nil, map[string]string{"job": "sync", "job_id": job.ID, "request_id": requestID})
+		}
+	}()
+
+	return &queued, nil
+}
+
+// GetSyncJob returns the current state of a sync job
+func (m *Manager) GetSyncJob(ctx context.Context, id string) (*SyncJob, error) {
+	var job SyncJob
+	if err := m.redis.GetJSON(ctx, syncJobKeyPrefix+id, &job); err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return nil, ErrSyncJobNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// saveSyncJob writes job progress to Redis. A nil job (a synchronous run)
+// is ignored, and write failures are only logged so they never fail a sync.
+func (m *Manager) saveSyncJob(ctx context.Context, job *SyncJob) {
+	if job == nil {
+		return
+	}
+	if err := m.redis.SetJSON(ctx, syncJobKeyPrefix+job.ID, job, syncJobTTL); err != nil {
+		m.logger.FromContext(ctx).WithError(err).WithField("job_id", job.ID).Warn("Failed to update sync job")
+	}
+}
+
+// finishSyncJob records the final status of a job
+func (m *Manager) finishSyncJob(ctx context.Context, job *SyncJob, err error) {
+	if job == nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	job.FinishedAt = &now
+	job.Status = SyncSucceeded
+	if err != nil {
+		job.Status = SyncFailed
+		job.addError(err.Error())
+	}
+
+	// The run's own context may already be cancelled or expired
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	m.saveSyncJob(ctx, job)
+}
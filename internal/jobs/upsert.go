@@ -0,0 +1,132 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"api-gateway-backend/internal/database"
+)
+
+// defaultSyncWorkers applies when no positive worker count is configured
+const defaultSyncWorkers = 4
+
+// errTooManyFailures abandons an atomic sync, rolling it back
+var errTooManyFailures = errors.New("too many items failed")
+
+// itemError is a failed upsert of a single item
+type itemError struct {
+	item database.Item
+	err  error
+}
+
+// batchResult is the outcome of writing one batch of items
+type batchResult struct {
+	succeeded int
+	failed    []itemError
+}
+
+// upsertItems writes items in batches across a bounded pool of workers.
+// onBatch is called once per finished batch from the calling goroutine, so
+// it needs no locking. When ctx is cancelled no further batches are
+// started and ctx's error is returned once running batches finish.
+func (m *Manager) upsertItems(ctx context.Context, items []database.Item, onBatch func(batchResult)) error {
+	batches := make(chan []database.Item)
+	results := make(chan batchResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < m.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				results <- m.upsertBatch(ctx, m.db, batch)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(batches)
+		for start := 0; start < len(items); start += m.batchSize {
+			select {
+			case batches <- items[start:min(start+m.batchSize, len(items))]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for result := range results {
+		onBatch(result)
+	}
+
+	return ctx.Err()
+}
+
+// upsertItemsAtomic writes items in batches within one transaction, so
+// readers see all of the sync or none of it. Batches are written one after
+// another, since a transaction holds a single connection. onBatch is called
+// after each batch and returns false to abandon the sync, rolling back
+// every batch with errTooManyFailures.
+func (m *Manager) upsertItemsAtomic(ctx context.Context, items []database.Item, onBatch func(batchResult) bool) error {
+	return m.withTx(ctx, func(tx database.ItemWriter) error {
+		for start := 0; start < len(items); start += m.batchSize {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !onBatch(m.upsertBatch(ctx, tx, items[start:min(start+m.batchSize, len(items))])) {
+				return errTooManyFailures
+			}
+		}
+		return nil
+	})
+}
+
+// writeItemsAtomic writes the items of an atomic sync, passing each batch
+// to record, and rolls the sync back once run has more than maxErrors
+// failed items. After a rollback nothing the run wrote was kept, so the
+// successes recorded on run and job are cleared.
+func (m *Manager) writeItemsAtomic(ctx context.Context, items []database.Item, run *database.SyncRun, job *SyncJob, record func(batchResult)) error {
+	err := m.upsertItemsAtomic(ctx, items, func(result batchResult) bool {
+		record(result)
+		return run.ErrorCount <= m.maxErrors
+	})
+	if err != nil {
+		run.SuccessCount = 0
+		if job != nil {
+			job.Succeeded = 0
+			m.saveSyncJob(ctx, job)
+		}
+	}
+	return err
+}
+
+// upsertBatch writes a batch with one multi-row statement through w. A
+// failed batch is retried row by row to isolate bad items.
+func (m *Manager) upsertBatch(ctx context.Context, w database.ItemWriter, batch []database.Item) batchResult {
+	err := w.UpsertItemsBatch(ctx, batch, m.batchSize)
+	if err == nil {
+		return batchResult{succeeded: len(batch)}
+	}
+	m.logger.FromContext(ctx).WithError(err).Warn("Batch upsert failed, retrying items individually")
+
+	var result batchResult
+	for i := range batch {
+		if ctx.Err() != nil {
+			break
+		}
+
+		item := &batch[i]
+		if err := w.UpsertItem(ctx, item); err != nil {
+			result.failed = append(result.failed, itemError{item: *item, err: err})
+		} else {
+			result.succeeded++
+		}
+	}
+	return result
+}
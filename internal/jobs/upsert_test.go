@@ -0,0 +1,154 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeItemTx is a transaction that fails writes of the bad items. A batch
+// holding a bad item fails as a whole, as one multi-row statement would.
+type fakeItemTx struct {
+	bad     map[string]bool
+	written []string
+	rows    []string // items written one at a time
+	onBatch func()   // called after each batch statement
+}
+
+func (tx *fakeItemTx) UpsertItem(ctx context.Context, item *database.Item) error {
+	tx.rows = append(tx.rows, item.ExternalID)
+	if tx.bad[item.ExternalID] {
+		return errors.New("value too long")
+	}
+	tx.written = append(tx.written, item.ExternalID)
+	return nil
+}
+
+func (tx *fakeItemTx) UpsertItemsBatch(ctx context.Context, items []database.Item, chunkSize int) error {
+	if tx.onBatch != nil {
+		defer tx.onBatch()
+	}
+	for _, item := range items {
+		if tx.bad[item.ExternalID] {
+			return errors.New("value too long")
+		}
+	}
+	for _, item := range items {
+		tx.written = append(tx.written, item.ExternalID)
+	}
+	return nil
+}
+
+// newAtomicManager returns a Manager writing atomic syncs through tx,
+// keeping the items in committed once a transaction commits
+func newAtomicManager(maxErrors int, tx *fakeItemTx, committed *[]string) *Manager {
+	m := New(nil, nil, nil, nil, nil, config.JobsConfig{SyncAtomic: true, SyncBatchSize: 2, SyncMaxErrors: maxErrors}, logger.New())
+	m.redis = newFakeRedis()
+	m.withTx = func(ctx context.Context, fn func(w database.ItemWriter) error) error {
+		tx.written = nil
+		if err := fn(tx); err != nil {
+			return err
+		}
+		*committed = append(*committed, tx.written...)
+		return nil
+	}
+	return m
+}
+
+// recordOn counts batch outcomes on run and job like syncData does
+func recordOn(run *database.SyncRun, job *SyncJob) func(batchResult) {
+	return func(result batchResult) {
+		run.SuccessCount += result.succeeded
+		run.ErrorCount += len(result.failed)
+		job.Succeeded, job.Failed = run.SuccessCount, run.ErrorCount
+	}
+}
+
+func TestWriteItemsAtomic(t *testing.T) {
+	items := []database.Item{{ExternalID: "1"}, {ExternalID: "2"}, {ExternalID: "3"}, {ExternalID: "4"}, {ExternalID: "5"}, {ExternalID: "6"}}
+
+	tests := []struct {
+		name      string
+		bad       []string
+		err       error
+		committed []string
+		succeeded int
+	}{
+		{"under the limit", []string{"2"}, nil, []string{"1", "3", "4", "5", "6"}, 5},
+		{"at the limit", []string{"2", "4"}, nil, []string{"1", "3", "5", "6"}, 4},
+		{"over the limit", []string{"2", "4", "6"}, errTooManyFailures, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &fakeItemTx{bad: make(map[string]bool)}
+			for _, id := range tt.bad {
+				tx.bad[id] = true
+			}
+			var committed []string
+			m := newAtomicManager(2, tx, &committed)
+			run, job := &database.SyncRun{}, &SyncJob{ID: "job"}
+
+			err := m.writeItemsAtomic(context.Background(), items, run, job, recordOn(run, job))
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.committed, committed)
+			assert.Equal(t, tt.succeeded, run.SuccessCount)
+			assert.Equal(t, tt.succeeded, job.Succeeded)
+			// Failed items are still reported after a rollback
+			assert.Equal(t, len(tt.bad), run.ErrorCount)
+			assert.Equal(t, len(tt.bad), job.Failed)
+		})
+	}
+}
+
+func TestWriteItemsAtomic_RowFallback(t *testing.T) {
+	items := []database.Item{{ExternalID: "1"}, {ExternalID: "2"}, {ExternalID: "3"}, {ExternalID: "4"}}
+	tx := &fakeItemTx{bad: map[string]bool{"2": true}}
+	var committed []string
+	m := newAtomicManager(1, tx, &committed)
+	run, job := &database.SyncRun{}, &SyncJob{ID: "job"}
+
+	var results []batchResult
+	record := recordOn(run, job)
+	require.NoError(t, m.writeItemsAtomic(context.Background(), items, run, job, func(result batchResult) {
+		results = append(results, result)
+		record(result)
+	}))
+
+	// Only the failed batch is retried row by row
+	assert.Equal(t, []string{"1", "2"}, tx.rows)
+	require.Len(t, results, 2)
+	assert.Equal(t, 1, results[0].succeeded)
+	require.Len(t, results[0].failed, 1)
+	assert.Equal(t, "2", results[0].failed[0].item.ExternalID)
+	assert.Equal(t, batchResult{succeeded: 2}, results[1])
+	assert.Equal(t, []string{"1", "3", "4"}, committed)
+}
+
+func TestWriteItemsAtomic_Cancelled(t *testing.T) {
+	items := []database.Item{{ExternalID: "1"}, {ExternalID: "2"}, {ExternalID: "3"}, {ExternalID: "4"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tx := &fakeItemTx{onBatch: cancel}
+	var committed []string
+	m := newAtomicManager(0, tx, &committed)
+	run, job := &database.SyncRun{}, &SyncJob{ID: "job"}
+
+	// The first batch is written, then the cancelled sync rolls it back
+	err := m.writeItemsAtomic(ctx, items, run, job, recordOn(run, job))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"1", "2"}, tx.written)
+	assert.Empty(t, committed)
+	assert.Zero(t, run.SuccessCount)
+	assert.Zero(t, job.Succeeded)
+}
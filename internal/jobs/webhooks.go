@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"api-gateway-backend/internal/logger"
+)
+
+// webhookLockKey guards webhook delivery so each due delivery is sent by
+// one instance only
+const webhookLockKey = "lock:webhooks"
+
+// deliverWebhooks sends outbound webhook deliveries that are due
+func (m *Manager) deliverWebhooks() error {
+	if !m.begin() {
+		return ErrStopped
+	}
+	defer m.running.Done()
+
+	ctx, cancel := context.WithTimeout(m.ctx, 2*time.Minute)
+	defer cancel()
+
+	ctx = logger.ContextWithRequestID(ctx, logger.NewRequestID())
+
+	release, err := m.holdSyncLock(ctx, webhookLockKey, cancel)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	delivered, err := m.webhooks.DeliverDue(ctx)
+	if delivered > 0 {
+		m.logger.FromContext(ctx).WithField("delivered", delivered).Info("Delivered webhooks")
+	}
+	return err
+}
+
+// publishWebhook queues an event for webhook subscribers. Notifications are
+// best effort: failures are logged and never fail the caller.
+func (m *Manager) publishWebhook(ctx context.Context, eventType string, data interface{}) {
+	if m.webhooks == nil {
+		return
+	}
+
+	log := m.logger.FromContext(ctx).WithField("event_type", eventType)
+	queued, err := m.webhooks.Publish(ctx, eventType, data)
+	if err != nil {
+		log.WithError(err).Warn("Failed to queue webhook deliveries")
+		return
+	}
+	if queued > 0 {
+		log.WithField("deliveries", queued).Debug("Queued webhook deliveries")
+	}
+}
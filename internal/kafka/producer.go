@@ -0,0 +1,168 @@
+// Package kafka produces the gateway's change events to a Kafka topic, for
+// data platforms to consume.
+package kafka
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"api-gateway-backend/internal/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Event types produced
+const (
+	EventItemUpdated   = "item.updated"   // a sync created or changed an item
+	EventSyncCompleted = "sync.completed" // a sync ran to completion
+)
+
+// schemaVersions is the version of each event type's Data schema. A change
+// consumers could trip over, like a removed or retyped field, bumps it.
+var schemaVersions = map[string]int{
+	EventItemUpdated:   1,
+	EventSyncCompleted: 1,
+}
+
+// source names the gateway in the envelope of every event
+const source = "api-gateway"
+
+// batchTimeout bounds how long the writer waits to fill a batch, so a
+// produce call isn't held up by the default of one second
+const batchTimeout = 10 * time.Millisecond
+
+// Envelope is the JSON value of every message. Consumers should check Type
+// and SchemaVersion before decoding Data, and can deduplicate by ID.
+type Envelope struct {
+	ID            string      `json:"id"`
+	Type          string      `json:"type"`
+	SchemaVersion int         `json:"schema_version"`
+	Source        string      `json:"source"`
+	TenantID      string      `json:"tenant_id"`
+	OccurredAt    time.Time   `json:"occurred_at"`
+	Data          interface{} `json:"data"`
+}
+
+// ItemUpdated is the Data of EventItemUpdated, version 1
+type ItemUpdated struct {
+	ExternalID string `json:"external_id"`
+	Title      string `json:"title"`
+	Body       string `json:"body"`
+	UserID     int    `json:"user_id"`
+	Created    bool   `json:"created"` // the item is new rather than changed
+}
+
+// SyncCompleted is the Data of EventSyncCompleted, version 1
+type SyncCompleted struct {
+	SyncRunID  int64  `json:"sync_run_id,omitempty"`
+	Trigger    string `json:"trigger"`
+	Succeeded  int    `json:"succeeded"`
+	Failed     int    `json:"failed"`
+	Deleted    int    `json:"deleted"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Event is an event to produce. Key partitions the topic, so the events of
+// one item stay in order.
+type Event struct {
+	Type     string
+	TenantID string
+	Key      string
+	Data     interface{}
+}
+
+// Producer writes events to the configured topic
+type Producer struct {
+	writer *kafka.Writer
+}
+
+// NewProducer creates a producer for cfg, or returns nil when no brokers
+// are configured. Brokers are only contacted when events are produced.
+func NewProducer(cfg config.KafkaConfig) *Producer {
+	if len(cfg.Brokers) == 0 {
+		return nil
+	}
+	return &Producer{writer: &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: batchTimeout,
+		WriteTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
+	}}
+}
+
+// Produce writes events in one batch, returning once the brokers have
+// acknowledged them
+func (p *Producer) Produce(ctx context.Context, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	messages := make([]kafka.Message, len(events))
+	for i, event := range events {
+		msg, err := newMessage(event, now)
+		if err != nil {
+			return err
+		}
+		messages[i] = msg
+	}
+
+	if err := p.writer.WriteMessages(ctx, messages...); err != nil {
+		return fmt.Errorf("failed to produce events: %w", err)
+	}
+	return nil
+}
+
+// Close flushes pending messages and closes the connections to the brokers
+func (p *Producer) Close() error {
+	return p.writer.Close()
+}
+
+// newMessage wraps event in its envelope
+func newMessage(event Event, occurredAt time.Time) (kafka.Message, error) {
+	version, ok := schemaVersions[event.Type]
+	if !ok {
+		return kafka.Message{}, fmt.Errorf("unknown event type %q", event.Type)
+	}
+	id, err := newEventID()
+	if err != nil {
+		return kafka.Message{}, err
+	}
+
+	value, err := json.Marshal(Envelope{
+		ID:            id,
+		Type:          event.Type,
+		SchemaVersion: version,
+		Source:        source,
+		TenantID:      event.TenantID,
+		OccurredAt:    occurredAt,
+		Data:          event.Data,
+	})
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("failed to marshal %s event: %w", event.Type, err)
+	}
+	return kafka.Message{
+		Key:   []byte(event.Key),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "type", Value: []byte(event.Type)},
+			{Key: "schema_version", Value: []byte(strconv.Itoa(version))},
+		},
+	}, nil
+}
+
+// newEventID returns a random event ID
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "evt_" + hex.EncodeToString(b), nil
+}
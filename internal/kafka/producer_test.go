@@ -0,0 +1,57 @@
+package kafka
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProducer(t *testing.T) {
+	assert.Nil(t, NewProducer(config.KafkaConfig{Topic: "gateway.events"}))
+
+	p := NewProducer(config.KafkaConfig{Brokers: []string{"kafka:9092"}, Topic: "gateway.events", WriteTimeout: 5})
+	require.NotNil(t, p)
+	assert.Equal(t, "gateway.events", p.writer.Topic)
+	assert.Equal(t, 5*time.Second, p.writer.WriteTimeout)
+}
+
+func TestNewMessage(t *testing.T) {
+	occurredAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg, err := newMessage(Event{
+		Type:     EventItemUpdated,
+		TenantID: "acme",
+		Key:      "acme:7",
+		Data:     ItemUpdated{ExternalID: "7", Title: "title", UserID: 1, Created: true},
+	}, occurredAt)
+	require.NoError(t, err)
+
+	assert.Equal(t, "acme:7", string(msg.Key))
+	require.Len(t, msg.Headers, 2)
+	assert.Equal(t, "type", msg.Headers[0].Key)
+	assert.Equal(t, EventItemUpdated, string(msg.Headers[0].Value))
+	assert.Equal(t, "schema_version", msg.Headers[1].Key)
+	assert.Equal(t, "1", string(msg.Headers[1].Value))
+
+	var envelope struct {
+		Envelope
+		Data ItemUpdated `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(msg.Value, &envelope))
+	assert.Regexp(t, "^evt_[0-9a-f]{32}$", envelope.ID)
+	assert.Equal(t, EventItemUpdated, envelope.Type)
+	assert.Equal(t, 1, envelope.SchemaVersion)
+	assert.Equal(t, "api-gateway", envelope.Source)
+	assert.Equal(t, "acme", envelope.TenantID)
+	assert.True(t, occurredAt.Equal(envelope.OccurredAt))
+	assert.Equal(t, ItemUpdated{ExternalID: "7", Title: "title", UserID: 1, Created: true}, envelope.Data)
+}
+
+func TestNewMessageUnknownType(t *testing.T) {
+	_, err := newMessage(Event{Type: "item.archived"}, time.Now())
+	assert.EqualError(t, err, `unknown event type "item.archived"`)
+}
@@ -1,14 +1,22 @@
 package logger
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"os"
 
 	"github.com/sirupsen/logrus"
 )
 
+// requestIDKey is the context.Context key for request IDs
+type requestIDKey struct{}
+
 // Logger wraps logrus.Logger
 type Logger struct {
 	*logrus.Logger
+
+	redact *redactHook
 }
 
 // New creates a new logger instance
@@ -16,19 +24,7 @@ func New() *Logger {
 	log := logrus.New()
 
 	// Set log level
-	logLevel := os.Getenv("LOG_LEVEL")
-	switch logLevel {
-	case "debug":
-		log.SetLevel(logrus.DebugLevel)
-	case "info":
-		log.SetLevel(logrus.InfoLevel)
-	case "warn":
-		log.SetLevel(logrus.WarnLevel)
-	case "error":
-		log.SetLevel(logrus.ErrorLevel)
-	default:
-		log.SetLevel(logrus.InfoLevel)
-	}
+	log.SetLevel(parseLevel(os.Getenv("LOG_LEVEL")))
 
 	// Set formatter
 	if os.Getenv("ENVIRONMENT") == "production" {
@@ -39,7 +35,62 @@ func New() *Logger {
 		})
 	}
 
-	return &Logger{Logger: log}
+	// Keep credentials out of every log line
+	redact := newRedactHook()
+	log.AddHook(redact)
+
+	return &Logger{Logger: log, redact: redact}
+}
+
+// RedactFields adds names to the fields whose values are replaced with
+// [REDACTED], on top of DefaultRedactedFields
+func (l *Logger) RedactFields(names ...string) {
+	if l.redact != nil {
+		l.redact.add(names...)
+	}
+}
+
+// Redacted reports whether values of a field called name are replaced with
+// [REDACTED], e.g. for an X-API-Key header
+func (l *Logger) Redacted(name string) bool {
+	return l.redact != nil && l.redact.sensitive(name)
+}
+
+// Levels are the level names SetLevelName accepts, most verbose first
+var Levels = []string{"debug", "info", "warn", "error"}
+
+// LevelName returns the name of the current log level, one of Levels
+func (l *Logger) LevelName() string {
+	switch l.GetLevel() {
+	case logrus.DebugLevel, logrus.TraceLevel:
+		return "debug"
+	case logrus.WarnLevel:
+		return "warn"
+	case logrus.ErrorLevel, logrus.FatalLevel, logrus.PanicLevel:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// SetLevelName changes the log level to debug, info, warn or error; any
+// other name means info. It's safe to call while logging.
+func (l *Logger) SetLevelName(name string) {
+	l.SetLevel(parseLevel(name))
+}
+
+// parseLevel maps a LOG_LEVEL name to its logrus level
+func parseLevel(name string) logrus.Level {
+	switch name {
+	case "debug":
+		return logrus.DebugLevel
+	case "warn":
+		return logrus.WarnLevel
+	case "error":
+		return logrus.ErrorLevel
+	default:
+		return logrus.InfoLevel
+	}
 }
 
 // WithField creates an entry with a single field
@@ -55,4 +106,33 @@ func (l *Logger) WithFields(fields logrus.Fields) *logrus.Entry {
 // WithError creates an entry with an error field
 func (l *Logger) WithError(err error) *logrus.Entry {
 	return l.Logger.WithError(err)
-}
\ No newline at end of file
+}
+
+// FromContext returns an entry tagged with the request ID carried by ctx,
+// so log lines from one request or job run can be correlated
+func (l *Logger) FromContext(ctx context.Context) *logrus.Entry {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return l.Logger.WithField("request_id", id)
+	}
+	return logrus.NewEntry(l.Logger)
+}
+
+// NewRequestID generates a random request ID
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
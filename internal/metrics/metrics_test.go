@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	errors := NewCounterVec("db_query_errors_total", "Queries that failed.", "query")
+	duration := NewHistogramVec("db_query_duration_seconds", "Query duration.", []float64{0.01, 0.1}, "query")
+	r := &Registry{}
+	r.Register(errors, duration)
+
+	errors.Inc(`select "items"`)
+	duration.Observe(0.005, "select items")
+	duration.Observe(0.05, "select items")
+	duration.Observe(2, "select items")
+
+	var out strings.Builder
+	require.NoError(t, r.Write(&out))
+	assert.Equal(t, `# HELP db_query_errors_total Queries that failed.
+# TYPE db_query_errors_total counter
+db_query_errors_total{query="select \"items\""} 1
+# HELP db_query_duration_seconds Query duration.
+# TYPE db_query_duration_seconds histogram
+db_query_duration_seconds_bucket{query="select items",le="0.01"} 1
+db_query_duration_seconds_bucket{query="select items",le="0.1"} 2
+db_query_duration_seconds_bucket{query="select items",le="+Inf"} 3
+db_query_duration_seconds_sum{query="select items"} 2.055
+db_query_duration_seconds_count{query="select items"} 3
+`, out.String())
+
+	assert.Equal(t, float64(1), errors.Value(`select "items"`))
+	assert.Equal(t, uint64(3), duration.Count("select items"))
+	assert.Panics(t, func() { duration.Observe(1) }, "missing label value")
+}
+
+func TestGaugeVec(t *testing.T) {
+	ejected := NewGaugeVec("upstream_backend_ejected", "Whether a backend is ejected.", "upstream", "backend")
+	r := &Registry{}
+	r.Register(ejected)
+
+	ejected.Set(1, "default", "https://a")
+	ejected.Set(1, "default", "https://b")
+	ejected.Set(0, "default", "https://a")
+
+	var out strings.Builder
+	require.NoError(t, r.Write(&out))
+	assert.Equal(t, `# HELP upstream_backend_ejected Whether a backend is ejected.
+# TYPE upstream_backend_ejected gauge
+upstream_backend_ejected{upstream="default",backend="https://a"} 0
+upstream_backend_ejected{upstream="default",backend="https://b"} 1
+`, out.String())
+	assert.Equal(t, float64(1), ejected.Value("default", "https://b"))
+}
@@ -0,0 +1,286 @@
+// Package oidc verifies tokens issued by an OpenID Connect provider such as
+// Keycloak, Auth0 or Google. The provider's signing keys are found through
+// issuer discovery and cached; keys the provider rotates in are fetched
+// the first time a token signed with one arrives.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// fetchTimeout bounds each discovery and JWKS request
+	fetchTimeout = 10 * time.Second
+
+	// minRefresh is how soon after a fetch an unknown key ID may trigger
+	// another, so tokens with made-up key IDs can't hammer the provider
+	minRefresh = time.Minute
+
+	// maxResponseSize caps discovery documents and key sets
+	maxResponseSize = 1 << 20
+)
+
+// ErrUnknownKey is returned for a key ID the provider doesn't publish
+var ErrUnknownKey = errors.New("oidc: unknown signing key")
+
+// Provider caches the signing keys of an OpenID Connect issuer. It is safe
+// for concurrent use.
+type Provider struct {
+	issuer string
+	ttl    time.Duration
+	client *http.Client
+	now    func() time.Time
+	group  singleflight.Group // one fetch at a time
+
+	mu          sync.Mutex
+	jwksURI     string // found by discovery on first use
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time // of keys
+	attemptedAt time.Time // of the last fetch, successful or not
+	err         error     // of the last fetch
+}
+
+// NewProvider returns a provider for issuer whose keys are refetched once
+// they are older than ttl. Nothing is fetched until the first Key call.
+func NewProvider(issuer string, ttl time.Duration) *Provider {
+	return &Provider{
+		issuer: strings.TrimSuffix(issuer, "/"),
+		ttl:    ttl,
+		client: &http.Client{Timeout: fetchTimeout},
+		now:    time.Now,
+	}
+}
+
+// Issuer returns the issuer tokens must name in their iss claim
+func (p *Provider) Issuer() string {
+	return p.issuer
+}
+
+// DiscoveryURL returns the issuer's OpenID Connect discovery document URL
+func (p *Provider) DiscoveryURL() string {
+	return p.issuer + "/.well-known/openid-configuration"
+}
+
+// Key returns the public key with ID kid. The key set is refetched when
+// it is older than the cache TTL, or when kid isn't in it, which picks up
+// rotated keys. Fetches are at least a minute apart, so neither tokens
+// with made-up key IDs nor an unreachable provider cause a fetch per
+// request; until one succeeds the cached keys are still used. Only a kid
+// the cached set lacks waits for the fetch: a cached key past its TTL is
+// returned while the set is refetched in the background.
+func (p *Provider) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	now := p.now()
+	key, ok := p.keys[kid]
+	fresh := ok && now.Sub(p.fetchedAt) < p.ttl
+	due := !fresh && now.Sub(p.attemptedAt) >= minRefresh
+	if due {
+		p.attemptedAt = now
+	}
+	err := p.err
+	p.mu.Unlock()
+
+	switch {
+	case fresh:
+		return key, nil
+	case ok:
+		if due {
+			p.refresh(now)
+		}
+		return key, nil
+	case !due && err != nil:
+		return nil, err
+	case !due:
+		return nil, ErrUnknownKey
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.refresh(now):
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return nil, ErrUnknownKey
+}
+
+// refresh starts refetching the key set, unless a fetch is already
+// running, and returns a channel that receives once it's done. The fetch
+// isn't tied to any one request, so callers giving up don't cancel it.
+func (p *Provider) refresh(now time.Time) <-chan singleflight.Result {
+	return p.group.DoChan("keys", func() (interface{}, error) {
+		err := p.fetchKeys(context.Background(), now)
+		p.mu.Lock()
+		p.err = err
+		p.mu.Unlock()
+		return nil, err
+	})
+}
+
+// fetchKeys replaces the cached keys with the provider's current set,
+// running discovery first if it hasn't succeeded yet. It holds the lock
+// only to read and store the results, never while fetching.
+func (p *Provider) fetchKeys(ctx context.Context, now time.Time) error {
+	p.mu.Lock()
+	jwksURI := p.jwksURI
+	p.mu.Unlock()
+
+	if jwksURI == "" {
+		var doc struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := p.fetch(ctx, p.DiscoveryURL(), &doc); err != nil {
+			return fmt.Errorf("oidc: discovery: %w", err)
+		}
+		if strings.TrimSuffix(doc.Issuer, "/") != p.issuer {
+			return fmt.Errorf("oidc: discovery: issuer %q does not match %q", doc.Issuer, p.issuer)
+		}
+		if doc.JWKSURI == "" {
+			return errors.New("oidc: discovery: no jwks_uri")
+		}
+		jwksURI = doc.JWKSURI
+		p.mu.Lock()
+		p.jwksURI = jwksURI
+		p.mu.Unlock()
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.fetch(ctx, jwksURI, &set); err != nil {
+		return fmt.Errorf("oidc: fetching keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Keys of types this package doesn't verify with are skipped
+		// rather than failing the whole set
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	p.mu.Lock()
+	p.keys = keys
+	p.fetchedAt = now
+	p.mu.Unlock()
+	return nil
+}
+
+// fetch GETs url and decodes its JSON body into v
+func (p *Provider) fetch(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", url, err)
+	}
+	return nil
+}
+
+// jwk is a JSON Web Key with the members of RSA and EC public keys
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey decodes an RSA or EC public key
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent out of range")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeInt decodes a base64url big-endian integer
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,166 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIssuer serves a discovery document, under any path so issuers that
+// don't match can be tested, and whatever key set is current
+type fakeIssuer struct {
+	*httptest.Server
+	keys    atomic.Value // []jwk
+	fetches atomic.Int32
+}
+
+func newFakeIssuer(t *testing.T, keys ...jwk) *fakeIssuer {
+	f := &fakeIssuer{}
+	f.keys.Store(keys)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/.well-known/openid-configuration") {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"issuer": f.URL, "jwks_uri": f.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		f.fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": f.keys.Load()})
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func rsaJWK(t *testing.T, kid string) (jwk, *rsa.PublicKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	enc := base64.RawURLEncoding
+	return jwk{
+		Kty: "RSA", Kid: kid, Use: "sig",
+		N: enc.EncodeToString(key.N.Bytes()),
+		E: enc.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}, &key.PublicKey
+}
+
+func TestProvider_Key(t *testing.T) {
+	k1, pub1 := rsaJWK(t, "k1")
+	k2, pub2 := rsaJWK(t, "k2")
+	idp := newFakeIssuer(t, k1)
+
+	now := time.Now()
+	p := NewProvider(idp.URL+"/", time.Hour)
+	p.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	key, err := p.Key(ctx, "k1")
+	require.NoError(t, err)
+	assert.True(t, pub1.Equal(key))
+
+	// Cached keys are served without a fetch
+	_, err = p.Key(ctx, "k1")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, idp.fetches.Load())
+
+	// A rotated-in key is fetched, but not more than once a minute
+	idp.keys.Store([]jwk{k1, k2})
+	_, err = p.Key(ctx, "k2")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	now = now.Add(minRefresh)
+	key, err = p.Key(ctx, "k2")
+	require.NoError(t, err)
+	assert.True(t, pub2.Equal(key))
+	assert.EqualValues(t, 2, idp.fetches.Load())
+
+	// Keys rotated out are dropped once the cache expires, after being
+	// served while the set is refetched
+	idp.keys.Store([]jwk{k2})
+	now = now.Add(time.Hour)
+	_, err = p.Key(ctx, "k1")
+	assert.NoError(t, err)
+	<-p.refresh(now)
+	_, err = p.Key(ctx, "k1")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// Cached keys outlive an unreachable provider
+	idp.Close()
+	now = now.Add(2 * time.Hour)
+	_, err = p.Key(ctx, "k2")
+	assert.NoError(t, err)
+}
+
+func TestProvider_SlowRefresh(t *testing.T) {
+	k1, pub1 := rsaJWK(t, "k1")
+	idp := newFakeIssuer(t, k1)
+
+	now := time.Now()
+	p := NewProvider(idp.URL, time.Hour)
+	p.now = func() time.Time { return now }
+	_, err := p.Key(context.Background(), "k1")
+	require.NoError(t, err)
+
+	// The provider stops answering
+	release := make(chan struct{})
+	defer close(release)
+	idp.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-release })
+
+	// An expired key is still served without waiting for the fetch
+	now = now.Add(2 * time.Hour)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		key, err := p.Key(context.Background(), "k1")
+		assert.NoError(t, err)
+		assert.True(t, pub1.Equal(key))
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Key blocked on the refresh")
+	}
+
+	// An unknown key waits, but only as long as its request does
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	now = now.Add(minRefresh)
+	_, err = p.Key(ctx, "k2")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestProvider_IssuerMismatch(t *testing.T) {
+	k1, _ := rsaJWK(t, "k1")
+	idp := newFakeIssuer(t, k1)
+
+	_, err := NewProvider(idp.URL+"/realms/other", time.Hour).Key(context.Background(), "k1")
+	assert.ErrorContains(t, err, "does not match")
+}
+
+func TestJWK_PublicKey(t *testing.T) {
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	enc := base64.RawURLEncoding
+	key, err := jwk{Kty: "EC", Crv: "P-256", X: enc.EncodeToString(ec.X.Bytes()), Y: enc.EncodeToString(ec.Y.Bytes())}.publicKey()
+	require.NoError(t, err)
+	assert.True(t, ec.PublicKey.Equal(key))
+
+	_, err = jwk{Kty: "EC", Crv: "P-256", X: enc.EncodeToString(ec.Y.Bytes()), Y: enc.EncodeToString(ec.X.Bytes())}.publicKey()
+	assert.Error(t, err)
+	_, err = jwk{Kty: "oct"}.publicKey()
+	assert.Error(t, err)
+}
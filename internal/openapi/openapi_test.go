@@ -0,0 +1,34 @@
+package openapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type example struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Secret    string     `json:"-"`
+	Tags      []string   `json:"tags,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+func TestSchemaFor(t *testing.T) {
+	s := SchemaFor(example{})
+
+	assert.Equal(t, "object", s.Type)
+	assert.Equal(t, []string{"id", "name", "created_at"}, s.Required)
+	assert.NotContains(t, s.Properties, "Secret")
+	assert.Equal(t, "int64", s.Properties["id"].Format)
+	assert.Equal(t, "array", s.Properties["tags"].Type)
+	assert.Equal(t, "date-time", s.Properties["created_at"].Format)
+	assert.True(t, s.Properties["deleted_at"].Nullable)
+}
+
+func TestPathFor(t *testing.T) {
+	assert.Equal(t, "/api/v1/items/{id}", PathFor("/api/v1/items/:id"))
+	assert.Equal(t, "/static/{path}", PathFor("/static/*path"))
+}
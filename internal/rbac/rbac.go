@@ -0,0 +1,80 @@
+// Package rbac defines the permissions routes require and the roles that
+// grant them. A role grants permissions by name, every permission of a
+// group with a wildcard such as admin:*, or every permission with *.
+package rbac
+
+import "strings"
+
+// Permissions
+const (
+	ItemsRead      = "items:read"
+	ItemsWrite     = "items:write"
+	OrdersRead     = "orders:read"
+	OrdersWrite    = "orders:write"
+	AnalyticsRead  = "analytics:read"
+	SyncRead       = "sync:read"
+	SyncTrigger    = "sync:trigger"
+	ProxyUse       = "proxy:use"
+	AdminAPIKeys   = "admin:api_keys"
+	AdminWebhooks  = "admin:webhooks"
+	AdminLogLevel  = "admin:log_level"
+	AdminAudit     = "admin:audit"
+	AdminJobs      = "admin:jobs"
+	AdminDebug     = "admin:debug"
+	allPermissions = "*"
+)
+
+// Permissions lists every permission
+func Permissions() []string {
+	return []string{
+		ItemsRead, ItemsWrite, OrdersRead, OrdersWrite, AnalyticsRead, SyncRead, SyncTrigger, ProxyUse,
+		AdminAPIKeys, AdminWebhooks, AdminLogLevel, AdminAudit, AdminJobs, AdminDebug,
+	}
+}
+
+// Roles maps role names to the permissions they grant
+type Roles map[string][]string
+
+// DefaultRoles returns the built-in roles: reader reads /api/v1, operator
+// also imports items, creates orders, triggers syncs and uses the proxy,
+// and admin holds every permission
+func DefaultRoles() Roles {
+	reader := []string{ItemsRead, OrdersRead, AnalyticsRead, SyncRead}
+	return Roles{
+		"reader":   reader,
+		"operator": append(append([]string{}, reader...), ItemsWrite, OrdersWrite, SyncTrigger, ProxyUse),
+		"admin":    {allPermissions},
+	}
+}
+
+// Allows reports whether any of the named roles grants perm
+func (r Roles) Allows(roles []string, perm string) bool {
+	for _, role := range roles {
+		for _, pattern := range r[role] {
+			if Grants(pattern, perm) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Grants reports whether pattern, a permission or wildcard, covers perm
+func Grants(pattern, perm string) bool {
+	if pattern == allPermissions || pattern == perm {
+		return true
+	}
+	group, ok := strings.CutSuffix(pattern, ":*")
+	return ok && strings.HasPrefix(perm, group+":")
+}
+
+// ValidPattern reports whether pattern is a known permission or a wildcard
+// covering at least one
+func ValidPattern(pattern string) bool {
+	for _, perm := range Permissions() {
+		if Grants(pattern, perm) {
+			return true
+		}
+	}
+	return false
+}
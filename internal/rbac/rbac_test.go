@@ -0,0 +1,45 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrants(t *testing.T) {
+	tests := []struct {
+		pattern, perm string
+		expected      bool
+	}{
+		{"sync:trigger", "sync:trigger", true},
+		{"sync:read", "sync:trigger", false},
+		{"admin:*", "admin:audit", true},
+		{"admin:*", "analytics:read", false},
+		{"adm:*", "admin:audit", false},
+		{"*", "admin:debug", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, Grants(tt.pattern, tt.perm), "%s covers %s", tt.pattern, tt.perm)
+	}
+}
+
+func TestRoles_Allows(t *testing.T) {
+	roles := DefaultRoles()
+
+	assert.True(t, roles.Allows([]string{"reader"}, AnalyticsRead))
+	assert.False(t, roles.Allows([]string{"reader"}, SyncTrigger))
+	assert.True(t, roles.Allows([]string{"reader", "operator"}, SyncTrigger))
+	assert.False(t, roles.Allows([]string{"operator"}, AdminAPIKeys))
+	assert.True(t, roles.Allows([]string{"admin"}, AdminAPIKeys))
+	assert.False(t, roles.Allows([]string{"unknown"}, ItemsRead))
+	assert.False(t, roles.Allows(nil, ItemsRead))
+}
+
+func TestValidPattern(t *testing.T) {
+	for _, pattern := range []string{"items:read", "admin:*", "*"} {
+		assert.True(t, ValidPattern(pattern), pattern)
+	}
+	for _, pattern := range []string{"items:delete", "billing:*", "", "admin"} {
+		assert.False(t, ValidPattern(pattern), pattern)
+	}
+}
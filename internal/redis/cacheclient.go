@@ -0,0 +1,42 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CacheClient is the subset of Client used by the API handlers, the cache
+// and background jobs, so tests can substitute their own implementation.
+// Commands return go-redis results; redis.NewStatusResult and friends
+// build them in mocks.
+type CacheClient interface {
+	Ping(ctx context.Context) *redis.StatusCmd
+	Get(ctx context.Context, key string) *redis.StringCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	Incr(ctx context.Context, key string) *redis.IntCmd
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+
+	GetJSON(ctx context.Context, key string, dest interface{}) error
+	SetJSON(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	MGetJSON(ctx context.Context, keys []string) ([]json.RawMessage, error)
+	MSetJSON(ctx context.Context, values map[string]interface{}, ttl time.Duration) error
+	InvalidatePattern(ctx context.Context, pattern string) (int64, error)
+	AllowRate(ctx context.Context, key string, rate float64, burst int) (*RateLimitResult, error)
+	PeekRate(ctx context.Context, key string, rate float64, burst int) (*RateLimitResult, error)
+	AcquireLock(ctx context.Context, key string, ttl time.Duration) (*Lock, error)
+	AcquireLockAs(ctx context.Context, key, owner string, ttl time.Duration) (*Lock, error)
+	EnqueueAt(ctx context.Context, queue, id string, value interface{}, at time.Time) error
+	ClaimDue(ctx context.Context, queue string, now time.Time, limit int) ([]json.RawMessage, error)
+	Dequeue(ctx context.Context, queue string, ids ...string) error
+	AppendStream(ctx context.Context, stream string, values []interface{}) error
+	ReadStream(ctx context.Context, stream, group, consumer string, count int, block, minIdle time.Duration) ([]StreamMessage, error)
+	AckStream(ctx context.Context, stream, group string, ids ...string) error
+	StreamLen(ctx context.Context, stream string) (int64, error)
+}
+
+var _ CacheClient = (*Client)(nil)
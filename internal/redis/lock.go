@@ -0,0 +1,98 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrLockNotAcquired is returned when a lock is already held elsewhere
+var ErrLockNotAcquired = errors.New("lock not acquired")
+
+// ErrLockLost is returned when a lock expired or was taken over before it
+// could be refreshed or released
+var ErrLockLost = errors.New("lock lost")
+
+// refreshLockScript extends the TTL of KEYS[1] only if it still holds the
+// caller's token (ARGV[1]). ARGV[2] is the TTL in milliseconds.
+var refreshLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLockScript deletes KEYS[1] only if it still holds ARGV[1]
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Lock is a distributed lock held in Redis. The random token ensures an
+// instance only ever refreshes or releases a lock it still owns.
+type Lock struct {
+	client *Client
+	key    string
+	token  string
+	ttl    time.Duration
+}
+
+// AcquireLock takes the lock at key with SET NX, returning
+// ErrLockNotAcquired if another holder has it. The lock expires after ttl
+// unless refreshed, so a crashed holder cannot block others forever.
+func (c *Client) AcquireLock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	return c.AcquireLockAs(ctx, key, hex.EncodeToString(buf), ttl)
+}
+
+// AcquireLockAs is AcquireLock with owner as the lock's value, so others can
+// read who holds it with GET. owner must be unique to the holder.
+func (c *Client) AcquireLockAs(ctx context.Context, key, owner string, ttl time.Duration) (*Lock, error) {
+	ok, err := c.SetNX(ctx, key, owner, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLockNotAcquired
+	}
+
+	return &Lock{client: c, key: key, token: owner, ttl: ttl}, nil
+}
+
+// Refresh extends the lock by its TTL
+func (l *Lock) Refresh(ctx context.Context) error {
+	n, err := refreshLockScript.Run(ctx, l.client.UniversalClient, []string{l.key}, l.token, l.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Release gives up the lock if it is still held
+func (l *Lock) Release(ctx context.Context) error {
+	n, err := releaseLockScript.Run(ctx, l.client.UniversalClient, []string{l.key}, l.token).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// TTL returns the lock's expiry period
+func (l *Lock) TTL() time.Duration {
+	return l.ttl
+}
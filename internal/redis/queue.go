@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// claimDueScript removes up to ARGV[2] entries scored at or before ARGV[1]
+// from the sorted set KEYS[1] and returns their values from the hash
+// KEYS[2], so each entry is handed to one caller only
+var claimDueScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+if #ids == 0 then
+	return {}
+end
+redis.call('ZREM', KEYS[1], unpack(ids))
+local values = redis.call('HMGET', KEYS[2], unpack(ids))
+redis.call('HDEL', KEYS[2], unpack(ids))
+return values
+`)
+
+// queueKeys returns the sorted set of due times and the hash of values
+// making up the delay queue named queue. The hash tag keeps both in one
+// cluster slot, as the scripts need.
+func queueKeys(queue string) (string, string) {
+	return "{" + queue + "}", "{" + queue + "}:data"
+}
+
+// EnqueueAt adds value to the delay queue named queue under id, due at at.
+// Enqueuing an id already queued replaces its value and due time.
+func (c *Client) EnqueueAt(ctx context.Context, queue, id string, value interface{}, at time.Time) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	due, values := queueKeys(queue)
+	_, err = c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, values, id, data)
+		pipe.ZAdd(ctx, due, redis.Z{Score: float64(at.UnixMilli()), Member: id})
+		return nil
+	})
+	return err
+}
+
+// ClaimDue removes up to limit entries due by now from the delay queue
+// named queue and returns their values. The claim is atomic, so with
+// several instances each entry is claimed once.
+func (c *Client) ClaimDue(ctx context.Context, queue string, now time.Time, limit int) ([]json.RawMessage, error) {
+	due, values := queueKeys(queue)
+	result, err := claimDueScript.Run(ctx, c.UniversalClient, []string{due, values}, now.UnixMilli(), limit).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make([]json.RawMessage, 0, len(result))
+	for _, v := range result {
+		if s, ok := v.(string); ok {
+			claimed = append(claimed, json.RawMessage(s))
+		}
+	}
+	return claimed, nil
+}
+
+// Dequeue removes ids from the delay queue named queue, if queued
+func (c *Client) Dequeue(ctx context.Context, queue string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	due, values := queueKeys(queue)
+	members := make([]interface{}, len(ids))
+	for i, id := range ids {
+		members[i] = id
+	}
+	_, err := c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, due, members...)
+		pipe.HDel(ctx, values, ids...)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript atomically refills and takes tokens from the bucket
+// stored at KEYS[1], using the Redis server clock so all replicas agree.
+// ARGV: refill rate (tokens/second), burst capacity, tokens to take.
+// Taking none only checks that one is available.
+// Returns: allowed (0/1), remaining tokens, retry-after ms, reset ms.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or burst
+local ts = tonumber(data[2]) or now
+tokens = math.min(burst, tokens + (now - ts) / 1000 * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - cost
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate * 1000)
+end
+
+local reset = math.ceil((burst - tokens) / rate * 1000)
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], reset + 1000)
+
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+// RateLimitResult is the outcome of a rate limit check
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // wait before the next request is allowed
+	ResetAfter time.Duration // wait until the bucket is full again
+}
+
+// AllowRate takes one token from the bucket at key, which refills at rate
+// tokens per second up to burst. The check is atomic across instances.
+func (c *Client) AllowRate(ctx context.Context, key string, rate float64, burst int) (*RateLimitResult, error) {
+	return c.takeRate(ctx, key, rate, burst, 1)
+}
+
+// PeekRate reports whether the bucket at key, as for AllowRate, has a token
+// left, without taking it
+func (c *Client) PeekRate(ctx context.Context, key string, rate float64, burst int) (*RateLimitResult, error) {
+	return c.takeRate(ctx, key, rate, burst, 0)
+}
+
+// takeRate runs tokenBucketScript, taking cost tokens when one is available
+func (c *Client) takeRate(ctx context.Context, key string, rate float64, burst, cost int) (*RateLimitResult, error) {
+	values, err := tokenBucketScript.Run(ctx, c.UniversalClient, []string{key}, rate, burst, cost).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return &RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      burst,
+		Remaining:  int(math.Max(0, float64(values[1]))),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
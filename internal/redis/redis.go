@@ -3,7 +3,9 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"api-gateway-backend/internal/config"
@@ -11,18 +13,44 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-// Client wraps redis.Client
+// defaultScanBatchSize applies when no positive SCAN batch size is configured
+const defaultScanBatchSize = 500
+
+// Redis deployment modes
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
+)
+
+// Client wraps a standalone, Sentinel-managed or Cluster Redis client
 type Client struct {
-	*redis.Client
+	redis.UniversalClient
+	scanBatchSize int
+	password      *password
+}
+
+// password is the Redis password, read each time a connection
+// authenticates so it can be rotated
+type password struct {
+	mu    sync.RWMutex
+	value string
 }
 
-// New creates a new Redis client
+// credentials returns the current username and password
+func (p *password) credentials() (string, string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return "", p.value
+}
+
+// New creates a new Redis client for the configured mode
 func New(cfg config.RedisConfig) (*Client, error) {
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
+	pw := &password{value: cfg.Password}
+	rdb, err := newUniversalClient(cfg, pw)
+	if err != nil {
+		return nil, err
+	}
 
 	// Test connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -32,7 +60,54 @@ func New(cfg config.RedisConfig) (*Client, error) {
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	return &Client{Client: rdb}, nil
+	scanBatchSize := cfg.ScanBatchSize
+	if scanBatchSize <= 0 {
+		scanBatchSize = defaultScanBatchSize
+	}
+
+	return &Client{UniversalClient: rdb, scanBatchSize: scanBatchSize, password: pw}, nil
+}
+
+// SetPassword switches to a rotated password, used by every connection
+// opened from now on. Open connections stay authenticated.
+func (c *Client) SetPassword(value string) {
+	c.password.mu.Lock()
+	defer c.password.mu.Unlock()
+	c.password.value = value
+}
+
+// newUniversalClient builds the go-redis client for cfg.Mode, reading the
+// password from pw
+func newUniversalClient(cfg config.RedisConfig, pw *password) (redis.UniversalClient, error) {
+	switch cfg.Mode {
+	case "", ModeStandalone:
+		return redis.NewClient(&redis.Options{
+			Addr:                fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+			CredentialsProvider: pw.credentials,
+			DB:                  cfg.DB,
+		}), nil
+	case ModeSentinel:
+		if cfg.MasterName == "" || len(cfg.Addrs) == 0 {
+			return nil, fmt.Errorf("sentinel mode requires REDIS_MASTER_NAME and REDIS_ADDRS")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:          cfg.MasterName,
+			SentinelAddrs:       cfg.Addrs,
+			SentinelPassword:    cfg.SentinelPassword,
+			CredentialsProvider: pw.credentials,
+			DB:                  cfg.DB,
+		}), nil
+	case ModeCluster:
+		if len(cfg.Addrs) == 0 {
+			return nil, fmt.Errorf("cluster mode requires REDIS_ADDRS")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:               cfg.Addrs,
+			CredentialsProvider: pw.credentials,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported Redis mode %q", cfg.Mode)
+	}
 }
 
 // SetJSON sets a JSON value in Redis with TTL
@@ -55,22 +130,118 @@ func (c *Client) GetJSON(ctx context.Context, key string, dest interface{}) erro
 	return json.Unmarshal([]byte(data), dest)
 }
 
-// InvalidatePattern deletes all keys matching a pattern
-func (c *Client) InvalidatePattern(ctx context.Context, pattern string) error {
-	keys, err := c.Keys(ctx, pattern).Result()
-	if err != nil {
-		return err
+// MSetJSON sets each value in values as JSON at its key with ttl, in one
+// pipelined round trip. It is pipelined rather than an MSET, which can't
+// set a TTL and in cluster mode needs every key in the same slot.
+func (c *Client) MSetJSON(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+	encoded := make(map[string][]byte, len(values))
+	for key, value := range values {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON for %s: %w", key, err)
+		}
+		encoded[key] = data
+	}
+
+	_, err := c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, data := range encoded {
+			pipe.Set(ctx, key, data, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// MGetJSON gets the JSON values at keys in one pipelined round trip. The
+// result has an entry per key, nil where the key isn't set.
+func (c *Client) MGetJSON(ctx context.Context, keys []string) ([]json.RawMessage, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, key)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
 	}
 
-	if len(keys) > 0 {
-		return c.Del(ctx, keys...).Err()
+	values := make([]json.RawMessage, len(keys))
+	for i, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[i] = data
+	}
+	return values, nil
+}
+
+// InvalidatePattern deletes all keys matching a pattern and returns how
+// many were deleted. It walks the keyspace with SCAN rather than KEYS so
+// Redis is never blocked, deleting each page of keys in one pipeline. In
+// cluster mode every master is scanned, since SCAN is per node.
+func (c *Client) InvalidatePattern(ctx context.Context, pattern string) (int64, error) {
+	cluster, ok := c.UniversalClient.(*redis.ClusterClient)
+	if !ok {
+		return c.invalidateNode(ctx, c.UniversalClient, pattern)
 	}
 
-	return nil
+	var mu sync.Mutex
+	var deleted int64
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		n, err := c.invalidateNode(ctx, node, pattern)
+		mu.Lock()
+		deleted += n
+		mu.Unlock()
+		return err
+	})
+	return deleted, err
+}
+
+// invalidateNode deletes the keys matching pattern on a single node
+func (c *Client) invalidateNode(ctx context.Context, node redis.UniversalClient, pattern string) (int64, error) {
+	var deleted int64
+	var cursor uint64
+	for {
+		keys, next, err := node.Scan(ctx, cursor, pattern, int64(c.scanBatchSize)).Result()
+		if err != nil {
+			return deleted, err
+		}
+
+		if len(keys) > 0 {
+			cmds, err := node.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for _, key := range keys {
+					pipe.Del(ctx, key)
+				}
+				return nil
+			})
+			if err != nil {
+				return deleted, err
+			}
+			for _, cmd := range cmds {
+				deleted += cmd.(*redis.IntCmd).Val()
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return deleted, nil
+		}
+	}
 }
 
 // Exists checks if a key exists
 func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
-	result, err := c.Client.Exists(ctx, key).Result()
+	result, err := c.UniversalClient.Exists(ctx, key).Result()
 	return result > 0, err
-}
\ No newline at end of file
+}
@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// streamField is the field of a stream entry holding its JSON value
+const streamField = "data"
+
+// StreamMessage is an entry read from a stream by a consumer group
+type StreamMessage struct {
+	ID   string
+	Data json.RawMessage
+}
+
+// AppendStream adds each of values to stream as an entry, in one round trip
+func (c *Client) AppendStream(ctx context.Context, stream string, values []interface{}) error {
+	_, err := c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, value := range values {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("failed to marshal JSON: %w", err)
+			}
+			pipe.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: []interface{}{streamField, data}})
+		}
+		return nil
+	})
+	return err
+}
+
+// ReadStream returns up to count entries of stream for consumer in group.
+// Entries another consumer read but didn't acknowledge within minIdle,
+// because it died or failed to persist them, are claimed first; otherwise
+// new entries are awaited for up to block. The group, and the stream, are
+// created on first use, starting from the stream's first entry.
+func (c *Client) ReadStream(ctx context.Context, stream, group, consumer string, count int, block, minIdle time.Duration) ([]StreamMessage, error) {
+	messages, err := c.readStream(ctx, stream, group, consumer, count, block, minIdle)
+	if err != nil && strings.HasPrefix(err.Error(), "NOGROUP") {
+		if err := c.XGroupCreateMkStream(ctx, stream, group, "0").Err(); err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return nil, err
+		}
+		messages, err = c.readStream(ctx, stream, group, consumer, count, block, minIdle)
+	}
+	return messages, err
+}
+
+// readStream is ReadStream for an existing group
+func (c *Client) readStream(ctx context.Context, stream, group, consumer string, count int, block, minIdle time.Duration) ([]StreamMessage, error) {
+	claimed, _, err := c.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    "0-0",
+		Count:    int64(count),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(claimed) > 0 {
+		return streamMessages(claimed), nil
+	}
+
+	streams, err := c.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    int64(count),
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []StreamMessage
+	for _, s := range streams {
+		messages = append(messages, streamMessages(s.Messages)...)
+	}
+	return messages, nil
+}
+
+// streamMessages converts entries written by AppendStream
+func streamMessages(entries []redis.XMessage) []StreamMessage {
+	messages := make([]StreamMessage, len(entries))
+	for i, entry := range entries {
+		data, _ := entry.Values[streamField].(string)
+		messages[i] = StreamMessage{ID: entry.ID, Data: json.RawMessage(data)}
+	}
+	return messages
+}
+
+// AckStream acknowledges entries of stream for group and deletes them, so
+// the stream's length is the backlog still to be handled
+func (c *Client) AckStream(ctx context.Context, stream, group string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, stream, group, ids...)
+		pipe.XDel(ctx, stream, ids...)
+		return nil
+	})
+	return err
+}
+
+// StreamLen returns the number of entries in stream
+func (c *Client) StreamLen(ctx context.Context, stream string) (int64, error) {
+	return c.XLen(ctx, stream).Result()
+}
@@ -0,0 +1,142 @@
+// Package reporting sends errors and panics to an error tracking service,
+// with the request or job they happened in attached, so failures are
+// grouped and alerted on rather than only logged.
+package reporting
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/logger"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Reporter sends errors to an error tracking service. Tags such as
+// request_id or job describe where the error happened; req is the request
+// being served, if any.
+type Reporter interface {
+	// CaptureError reports err
+	CaptureError(err error, req *http.Request, tags map[string]string)
+	// CapturePanic reports a value recovered from a panic. Call it from
+	// the deferred function that recovered, so the stack is the panic's.
+	CapturePanic(recovered interface{}, req *http.Request, tags map[string]string)
+	// Flush waits up to timeout for reports still being sent, reporting
+	// whether they all were
+	Flush(timeout time.Duration) bool
+}
+
+// New creates a Sentry reporter for cfg, or a reporter that discards
+// everything when no DSN is configured. environment is used when cfg
+// doesn't name one.
+func New(cfg config.ErrorReportingConfig, environment string) (Reporter, error) {
+	if cfg.DSN == "" {
+		return Nop{}, nil
+	}
+	if cfg.Environment == "" {
+		cfg.Environment = environment
+	}
+	return newSentry(cfg, nil)
+}
+
+// Nop is a Reporter that discards everything
+type Nop struct{}
+
+// CaptureError does nothing
+func (Nop) CaptureError(error, *http.Request, map[string]string) {}
+
+// CapturePanic does nothing
+func (Nop) CapturePanic(interface{}, *http.Request, map[string]string) {}
+
+// Flush returns true straight away
+func (Nop) Flush(time.Duration) bool { return true }
+
+// sentryReporter reports to Sentry
+type sentryReporter struct {
+	client *sentry.Client
+}
+
+// newSentry creates a Sentry client sending through transport, or over
+// HTTP when transport is nil
+func newSentry(cfg config.ErrorReportingConfig, transport sentry.Transport) (*sentryReporter, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:              cfg.DSN,
+		Environment:      cfg.Environment,
+		Release:          cfg.Release,
+		SampleRate:       cfg.SampleRate,
+		AttachStacktrace: true,
+		Transport:        transport,
+		BeforeSend:       maskEvent,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure Sentry: %w", err)
+	}
+	return &sentryReporter{client: client}, nil
+}
+
+// CaptureError reports err as an exception event
+func (s *sentryReporter) CaptureError(err error, req *http.Request, tags map[string]string) {
+	s.hub(req, tags).CaptureException(err)
+}
+
+// CapturePanic reports recovered as a fatal exception event
+func (s *sentryReporter) CapturePanic(recovered interface{}, req *http.Request, tags map[string]string) {
+	hub := s.hub(req, tags)
+	hub.Scope().SetLevel(sentry.LevelFatal)
+	hub.Recover(recovered)
+}
+
+// Flush waits for queued events to be sent
+func (s *sentryReporter) Flush(timeout time.Duration) bool {
+	return s.client.Flush(timeout)
+}
+
+// hub returns a hub whose scope carries tags and req, so concurrent
+// reports don't share context
+func (s *sentryReporter) hub(req *http.Request, tags map[string]string) *sentry.Hub {
+	scope := sentry.NewScope()
+	scope.SetTags(tags)
+	if req != nil {
+		scope.AddEventProcessor(func(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+			event.Request = safeRequest(req)
+			return event
+		})
+	}
+	return sentry.NewHub(s.client, scope)
+}
+
+// reportedHeaders are the request headers sent with events. Others, such
+// as X-API-Key and X-Admin-Token, may carry credentials.
+var reportedHeaders = []string{"Accept", "Content-Type", "User-Agent", "X-Request-ID"}
+
+// safeRequest describes req for an event without its body, cookies or
+// credentials
+func safeRequest(req *http.Request) *sentry.Request {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	headers := make(map[string]string, len(reportedHeaders))
+	for _, name := range reportedHeaders {
+		if value := req.Header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	return &sentry.Request{
+		URL:         scheme + "://" + req.Host + req.URL.Path,
+		Method:      req.Method,
+		QueryString: req.URL.RawQuery,
+		Headers:     headers,
+	}
+}
+
+// maskEvent masks credentials in error messages, as the logger does
+func maskEvent(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+	event.Message = logger.MaskCredentials(event.Message)
+	for i := range event.Exception {
+		event.Exception[i].Value = logger.MaskCredentials(event.Exception[i].Value)
+	}
+	return event
+}
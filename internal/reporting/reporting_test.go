@@ -0,0 +1,80 @@
+package reporting
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/config"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingTransport keeps events instead of sending them
+type recordingTransport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *recordingTransport) Configure(sentry.ClientOptions) {}
+
+func (t *recordingTransport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+func (t *recordingTransport) Flush(time.Duration) bool { return true }
+
+func TestSentry_CaptureError(t *testing.T) {
+	transport := &recordingTransport{}
+	r, err := newSentry(config.ErrorReportingConfig{
+		DSN:         "https://public@sentry.example.com/1",
+		Environment: "production",
+		SampleRate:  1,
+	}, transport)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	req := httptest.NewRequest("GET", "https://api.example.com/api/v1/items?limit=5", nil)
+	req.Header.Set("X-API-Key", "key_secret")
+	req.Header.Set("X-Request-ID", "req-1")
+	r.CaptureError(errors.New("dial postgres://api:s3cret@db/gateway: refused"), req, map[string]string{"request_id": "req-1"})
+
+	func() {
+		defer func() {
+			r.CapturePanic(recover(), nil, map[string]string{"job": "sync"})
+		}()
+		panic("boom")
+	}()
+
+	if !assert.Len(t, transport.events, 2) {
+		return
+	}
+	event := transport.events[0]
+	assert.Equal(t, "production", event.Environment)
+	assert.Equal(t, "req-1", event.Tags["request_id"])
+	if assert.NotEmpty(t, event.Exception) {
+		assert.Equal(t, "dial postgres://api:***@db/gateway: refused", event.Exception[0].Value)
+	}
+	if assert.NotNil(t, event.Request) {
+		assert.Equal(t, "https://api.example.com/api/v1/items", event.Request.URL)
+		assert.Equal(t, "limit=5", event.Request.QueryString)
+		assert.Equal(t, map[string]string{"X-Request-ID": "req-1"}, event.Request.Headers)
+	}
+
+	event = transport.events[1]
+	assert.Equal(t, sentry.LevelFatal, event.Level)
+	assert.Equal(t, "sync", event.Tags["job"])
+	assert.Nil(t, event.Request)
+}
+
+func TestNew_WithoutDSN(t *testing.T) {
+	r, err := New(config.ErrorReportingConfig{}, "production")
+	assert.NoError(t, err)
+	assert.Equal(t, Nop{}, r)
+}
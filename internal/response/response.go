@@ -0,0 +1,74 @@
+// Package response defines the envelope JSON API responses are sent in from
+// version 2 of the API: the result under "data", what it describes under
+// "meta" and, for a failed request, the error under "error".
+package response
+
+import (
+	"time"
+
+	"api-gateway-backend/internal/apierror"
+)
+
+// Envelope is the body of every version 2 JSON response
+type Envelope struct {
+	Data  interface{} `json:"data,omitempty"`
+	Meta  Meta        `json:"meta"`
+	Error *Error      `json:"error,omitempty"`
+}
+
+// Meta describes the data of a response and the request it answers
+type Meta struct {
+	Pagination *Pagination `json:"pagination,omitempty"`
+	Cache      *Cache      `json:"cache,omitempty"`
+	// Filters are the query parameters the data was selected by
+	Filters   map[string]interface{} `json:"filters,omitempty"`
+	Message   string                 `json:"message,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// Pagination describes the page of a list the data is
+type Pagination struct {
+	Count  int `json:"count"`
+	Limit  int `json:"limit,omitempty"`
+	Offset int `json:"offset"`
+}
+
+// Cache describes where the data came from
+type Cache struct {
+	Hit bool `json:"hit"`
+	// ComputedAt is when precomputed data was computed, if known
+	ComputedAt *time.Time `json:"computed_at,omitempty"`
+	// SyncedAt is when the data was last synced from the upstream, if ever
+	SyncedAt *time.Time `json:"synced_at,omitempty"`
+}
+
+// Error is a failed request's error. Message is the short summary, and
+// Cause the underlying error, if any.
+type Error struct {
+	Code    apierror.Code `json:"code"`
+	Message string        `json:"message"`
+	Cause   string        `json:"cause,omitempty"`
+	Details interface{}   `json:"details,omitempty"`
+}
+
+// OK returns the envelope of a successful response, timestamped now
+// unless meta already is
+func OK(data interface{}, meta Meta) Envelope {
+	if meta.Timestamp.IsZero() {
+		meta.Timestamp = time.Now().UTC()
+	}
+	return Envelope{Data: data, Meta: meta}
+}
+
+// Failure returns the envelope of a request that failed with err
+func Failure(err *apierror.Error, requestID string) Envelope {
+	e := &Error{Code: err.Code, Message: err.Message, Details: err.Details}
+	if err.Err != nil {
+		e.Cause = err.Err.Error()
+	}
+	return Envelope{
+		Meta:  Meta{RequestID: requestID, Timestamp: time.Now().UTC()},
+		Error: e,
+	}
+}
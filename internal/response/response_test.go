@@ -0,0 +1,56 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/apierror"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOK(t *testing.T) {
+	envelope := OK([]string{"a", "b"}, Meta{
+		Pagination: &Pagination{Count: 2, Limit: 10},
+		Filters:    map[string]interface{}{"q": "a"},
+	})
+	assert.WithinDuration(t, time.Now(), envelope.Meta.Timestamp, time.Second)
+
+	body, err := json.Marshal(envelope)
+	require.NoError(t, err)
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, []interface{}{"a", "b"}, got["data"])
+	assert.NotContains(t, got, "error")
+	meta := got["meta"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"count": 2.0, "limit": 10.0, "offset": 0.0}, meta["pagination"])
+	assert.Equal(t, map[string]interface{}{"q": "a"}, meta["filters"])
+	assert.NotContains(t, meta, "cache")
+
+	// A timestamp already set is kept
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Equal(t, at, OK(nil, Meta{Timestamp: at}).Meta.Timestamp)
+}
+
+func TestFailure(t *testing.T) {
+	err := apierror.New(http.StatusNotFound, apierror.CodeItemNotFound, "item not found").
+		Wrap(errors.New("no item with id 7")).
+		WithDetails(map[string]int{"id": 7})
+
+	body, marshalErr := json.Marshal(Failure(err, "req-1"))
+	require.NoError(t, marshalErr)
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &got))
+	assert.NotContains(t, got, "data")
+	assert.Equal(t, map[string]interface{}{
+		"code":    "ITEM_NOT_FOUND",
+		"message": "item not found",
+		"cause":   "no item with id 7",
+		"details": map[string]interface{}{"id": 7.0},
+	}, got["error"])
+	assert.Equal(t, "req-1", got["meta"].(map[string]interface{})["request_id"])
+}
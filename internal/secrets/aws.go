@@ -0,0 +1,178 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"api-gateway-backend/internal/config"
+)
+
+// awsService is the SigV4 service name of Secrets Manager
+const awsService = "secretsmanager"
+
+// awsSecrets reads secrets from AWS Secrets Manager
+type awsSecrets struct {
+	client   *http.Client
+	endpoint string
+	creds    awsCredentials
+	now      func() time.Time
+}
+
+// awsCredentials are the keys AWS requests are signed with
+type awsCredentials struct {
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+func newAWS(cfg config.SecretsConfig) *awsSecrets {
+	endpoint := cfg.AWSEndpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", cfg.AWSRegion)
+	}
+	return &awsSecrets{
+		client:   &http.Client{Timeout: fetchTimeout},
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/",
+		creds: awsCredentials{
+			region:          cfg.AWSRegion,
+			accessKeyID:     cfg.AWSAccessKeyID,
+			secretAccessKey: cfg.AWSSecretAccessKey,
+			sessionToken:    cfg.AWSSessionToken,
+		},
+		now: time.Now,
+	}
+}
+
+// Fetch reads the current version of the secret named path. A secret
+// string holding a JSON object yields its fields; any other string is a
+// single unnamed value.
+func (a *awsSecrets) Fetch(ctx context.Context, path string) (map[string]string, error) {
+	body, err := json.Marshal(map[string]string{"SecretId": path})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signV4(req, body, a.creds, awsService, a.now())
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("aws: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("aws: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var payload struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(data, &payload)
+		return nil, fmt.Errorf("aws: reading %q returned %d: %s %s", path, resp.StatusCode, payload.Type, payload.Message)
+	}
+
+	var payload struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("aws: invalid response for %q: %w", path, err)
+	}
+	if payload.SecretString == nil {
+		return nil, fmt.Errorf("aws: secret %q has no string value", path)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(*payload.SecretString), &fields); err != nil {
+		return map[string]string{"": *payload.SecretString}, nil
+	}
+	values := make(map[string]string, len(fields))
+	for key, value := range fields {
+		if s, ok := value.(string); ok {
+			values[key] = s
+		} else {
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	return values, nil
+}
+
+// signV4 signs req, with body as its payload, using AWS Signature Version 4.
+// Every header already set on req is signed, along with Host and the
+// X-Amz-Date (and X-Amz-Security-Token) headers added here.
+func signV4(req *http.Request, body []byte, creds awsCredentials, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + creds.region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), date)
+	key = hmacSHA256(key, creds.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,248 @@
+// Package secrets fetches credentials from a secrets manager, so they
+// don't have to be set in plaintext, and refreshes them so rotated values
+// are picked up without a restart.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/logger"
+)
+
+// fetchTimeout bounds each request to the secrets manager
+const fetchTimeout = 10 * time.Second
+
+// Provider reads secrets from a secrets manager
+type Provider interface {
+	// Fetch returns the key-value pairs stored in the secret at path. A
+	// secret holding a single unnamed value has it under the empty key.
+	Fetch(ctx context.Context, path string) (map[string]string, error)
+}
+
+// NewProvider creates the provider cfg selects, or returns nil when no
+// provider is configured
+func NewProvider(cfg config.SecretsConfig) (Provider, error) {
+	switch cfg.Provider {
+	case config.SecretsNone:
+		return nil, nil
+	case config.SecretsVault:
+		return newVault(cfg), nil
+	case config.SecretsAWS:
+		return newAWS(cfg), nil
+	default:
+		return nil, fmt.Errorf("unsupported secrets provider %q", cfg.Provider)
+	}
+}
+
+// parseRef splits a <path>#<key> reference
+func parseRef(ref string) (path, key string) {
+	path, key, _ = strings.Cut(ref, "#")
+	return path, key
+}
+
+// lookup picks the value named key out of the secret at path. Without a
+// key the secret must hold a single value.
+func lookup(path, key string, values map[string]string) (string, error) {
+	if key != "" {
+		value, ok := values[key]
+		if !ok {
+			return "", fmt.Errorf("secret %q has no key %q", path, key)
+		}
+		return value, nil
+	}
+	if len(values) != 1 {
+		return "", fmt.Errorf("secret %q holds %d values, name one with %s#<key>", path, len(values), path)
+	}
+	for _, value := range values {
+		return value, nil
+	}
+	return "", nil
+}
+
+// Manager resolves secret references and, while running, refreshes them,
+// notifying watchers when a value has been rotated
+type Manager struct {
+	provider Provider
+	interval time.Duration
+	log      *logger.Logger
+
+	mu      sync.Mutex
+	secrets map[string]*secret // by reference
+}
+
+// secret is a resolved reference and who to tell when it changes
+type secret struct {
+	value    string
+	watchers []func(string)
+}
+
+// NewManager creates a manager fetching from provider every interval.
+// provider may be nil when no references are used.
+func NewManager(provider Provider, interval time.Duration, log *logger.Logger) *Manager {
+	return &Manager{
+		provider: provider,
+		interval: interval,
+		log:      log,
+		secrets:  make(map[string]*secret),
+	}
+}
+
+// Get returns the value ref points to, fetching it the first time
+func (m *Manager) Get(ctx context.Context, ref string) (string, error) {
+	m.mu.Lock()
+	s, ok := m.secrets[ref]
+	m.mu.Unlock()
+	if ok {
+		return s.value, nil
+	}
+
+	if m.provider == nil {
+		return "", fmt.Errorf("secret %q: no secrets provider is configured", ref)
+	}
+	value, err := m.fetch(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.secrets[ref]; ok {
+		return s.value, nil
+	}
+	m.secrets[ref] = &secret{value: value}
+	return value, nil
+}
+
+// Watch calls fn with ref's new value each time a refresh finds it
+// rotated. ref must have been resolved with Get or Resolve; an empty ref
+// is ignored.
+func (m *Manager) Watch(ref string, fn func(value string)) {
+	if ref == "" {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.secrets[ref]; ok {
+		s.watchers = append(s.watchers, fn)
+	}
+}
+
+// Resolve fetches every secret cfg references and sets the setting each
+// stands in for, e.g. Database.Password from Database.PasswordRef
+func (m *Manager) Resolve(ctx context.Context, cfg *config.Config) error {
+	resolve := func(what, ref string, dest *string) error {
+		if ref == "" {
+			return nil
+		}
+		value, err := m.Get(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", what, err)
+		}
+		*dest = value
+		return nil
+	}
+
+	if err := resolve("database password", cfg.Database.PasswordRef, &cfg.Database.Password); err != nil {
+		return err
+	}
+	if err := resolve("redis password", cfg.Redis.PasswordRef, &cfg.Redis.Password); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(cfg.ExternalAPI.Upstreams))
+	for name := range cfg.ExternalAPI.Upstreams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		u := cfg.ExternalAPI.Upstreams[name]
+		what := fmt.Sprintf("upstream %q", name)
+		if err := resolve(what+" auth token", u.AuthTokenRef, &u.AuthToken); err != nil {
+			return err
+		}
+		if err := resolve(what+" auth password", u.AuthPasswordRef, &u.AuthPassword); err != nil {
+			return err
+		}
+		if err := resolve(what+" OAuth2 client secret", u.OAuthClientSecretRef, &u.OAuthClientSecret); err != nil {
+			return err
+		}
+		cfg.ExternalAPI.Upstreams[name] = u
+	}
+	return nil
+}
+
+// Run refreshes the resolved secrets every interval until ctx is done. It
+// returns straight away if refreshing is disabled or nothing was resolved.
+func (m *Manager) Run(ctx context.Context) {
+	m.mu.Lock()
+	empty := len(m.secrets) == 0
+	m.mu.Unlock()
+	if m.provider == nil || m.interval <= 0 || empty {
+		return
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Refresh(ctx)
+		}
+	}
+}
+
+// Refresh fetches every resolved secret again and notifies the watchers of
+// those that changed. A secret that can't be fetched keeps its last value.
+func (m *Manager) Refresh(ctx context.Context) {
+	m.mu.Lock()
+	refs := make([]string, 0, len(m.secrets))
+	for ref := range m.secrets {
+		refs = append(refs, ref)
+	}
+	m.mu.Unlock()
+	sort.Strings(refs)
+
+	for _, ref := range refs {
+		value, err := m.fetch(ctx, ref)
+		if err != nil {
+			m.log.WithError(err).WithField("secret_ref", ref).Warn("Failed to refresh secret, keeping the current value")
+			continue
+		}
+
+		m.mu.Lock()
+		s := m.secrets[ref]
+		changed := s.value != value
+		s.value = value
+		watchers := append([]func(string){}, s.watchers...)
+		m.mu.Unlock()
+
+		if changed {
+			m.log.WithField("secret_ref", ref).Info("Secret rotated")
+			for _, fn := range watchers {
+				fn(value)
+			}
+		}
+	}
+}
+
+// fetch reads ref from the provider
+func (m *Manager) fetch(ctx context.Context, ref string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	path, key := parseRef(ref)
+	values, err := m.provider.Fetch(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	return lookup(path, key, values)
+}
@@ -0,0 +1,192 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/logger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProvider serves secrets from memory
+type fakeProvider struct {
+	secrets map[string]map[string]string
+	err     error
+	fetches int
+}
+
+func (p *fakeProvider) Fetch(ctx context.Context, path string) (map[string]string, error) {
+	p.fetches++
+	if p.err != nil {
+		return nil, p.err
+	}
+	values, ok := p.secrets[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return values, nil
+}
+
+func TestSignV4(t *testing.T) {
+	// The get-vanilla case of the AWS Signature Version 4 test suite
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	creds := awsCredentials{
+		region:          "us-east-1",
+		accessKeyID:     "AKIDEXAMPLE",
+		secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	signV4(req, nil, creds, "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+}
+
+func TestVault_Fetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" || r.Header.Get("X-Vault-Namespace") != "team" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/gateway/db":
+			w.Write([]byte(`{"data":{"data":{"password":"s3cret","port":5432},"metadata":{"version":3}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	v := newVault(config.SecretsConfig{VaultAddr: server.URL + "/", VaultToken: "root", VaultMount: "kv", VaultNamespace: "team"})
+	values, err := v.Fetch(context.Background(), "gateway/db")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"password": "s3cret", "port": "5432"}, values)
+
+	_, err = v.Fetch(context.Background(), "gateway/missing")
+	assert.EqualError(t, err, `vault: secret "gateway/missing" not found`)
+
+	v.token = "wrong"
+	_, err = v.Fetch(context.Background(), "gateway/db")
+	assert.EqualError(t, err, `vault: reading "gateway/db" returned 403: permission denied`)
+}
+
+func TestAWS_Fetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.Equal(t, "token", r.Header.Get("X-Amz-Security-Token"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=AKID/20240102/eu-west-1/secretsmanager/aws4_request, "+
+				"SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, Signature="),
+			r.Header.Get("Authorization"))
+
+		var body struct{ SecretId string }
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch body.SecretId {
+		case "prod/db":
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"username":"api","password":"s3cret"}`})
+		case "prod/redis":
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": "plain"})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"Secrets Manager can't find the specified secret."}`))
+		}
+	}))
+	defer server.Close()
+
+	a := newAWS(config.SecretsConfig{
+		AWSRegion:          "eu-west-1",
+		AWSEndpoint:        server.URL,
+		AWSAccessKeyID:     "AKID",
+		AWSSecretAccessKey: "secret",
+		AWSSessionToken:    "token",
+	})
+	a.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	values, err := a.Fetch(context.Background(), "prod/db")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"username": "api", "password": "s3cret"}, values)
+
+	values, err = a.Fetch(context.Background(), "prod/redis")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"": "plain"}, values)
+
+	_, err = a.Fetch(context.Background(), "prod/missing")
+	assert.ErrorContains(t, err, "returned 400: ResourceNotFoundException")
+}
+
+func TestManager_ResolveAndRefresh(t *testing.T) {
+	provider := &fakeProvider{secrets: map[string]map[string]string{
+		"db":    {"username": "api", "password": "one"},
+		"redis": {"": "plain"},
+		"crm":   {"token": "t1"},
+	}}
+	m := NewManager(provider, time.Minute, logger.New())
+
+	cfg := &config.Config{
+		Database: config.DatabaseConfig{Password: "env", PasswordRef: "db#password"},
+		Redis:    config.RedisConfig{PasswordRef: "redis"},
+		ExternalAPI: config.ExternalAPIConfig{Upstreams: map[string]config.UpstreamConfig{
+			"crm":     {AuthTokenRef: "crm#token"},
+			"default": {AuthToken: "static"},
+		}},
+	}
+	if !assert.NoError(t, m.Resolve(context.Background(), cfg)) {
+		return
+	}
+	assert.Equal(t, "one", cfg.Database.Password)
+	assert.Equal(t, "plain", cfg.Redis.Password)
+	assert.Equal(t, "t1", cfg.ExternalAPI.Upstreams["crm"].AuthToken)
+	assert.Equal(t, "static", cfg.ExternalAPI.Upstreams["default"].AuthToken)
+
+	var rotated []string
+	m.Watch("db#password", func(v string) { rotated = append(rotated, "db:"+v) })
+	m.Watch("crm#token", func(v string) { rotated = append(rotated, "crm:"+v) })
+
+	// Only changed secrets notify their watchers
+	provider.secrets["db"]["password"] = "two"
+	m.Refresh(context.Background())
+	assert.Equal(t, []string{"db:two"}, rotated)
+
+	// Failed refreshes keep the current values
+	provider.err = errors.New("unavailable")
+	m.Refresh(context.Background())
+	assert.Equal(t, []string{"db:two"}, rotated)
+	value, err := m.Get(context.Background(), "db#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "two", value)
+}
+
+func TestManager_ResolveErrors(t *testing.T) {
+	provider := &fakeProvider{secrets: map[string]map[string]string{
+		"db": {"username": "api", "password": "one"},
+	}}
+
+	tests := []struct {
+		ref     string
+		wantErr string
+	}{
+		{"db#missing", `database password: secret "db" has no key "missing"`},
+		{"db", `database password: secret "db" holds 2 values, name one with db#<key>`},
+		{"other#password", "database password: not found"},
+	}
+	for _, tt := range tests {
+		m := NewManager(provider, time.Minute, logger.New())
+		cfg := &config.Config{Database: config.DatabaseConfig{PasswordRef: tt.ref}}
+		assert.EqualError(t, m.Resolve(context.Background(), cfg), tt.wantErr, tt.ref)
+	}
+
+	m := NewManager(nil, time.Minute, logger.New())
+	_, err := m.Get(context.Background(), "db#password")
+	assert.EqualError(t, err, `secret "db#password": no secrets provider is configured`)
+}
@@ -0,0 +1,97 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"api-gateway-backend/internal/config"
+)
+
+// vault reads secrets from a HashiCorp Vault KV version 2 secrets engine
+type vault struct {
+	client    *http.Client
+	addr      string
+	token     string
+	mount     string
+	namespace string
+}
+
+func newVault(cfg config.SecretsConfig) *vault {
+	return &vault{
+		client:    &http.Client{Timeout: fetchTimeout},
+		addr:      strings.TrimSuffix(cfg.VaultAddr, "/"),
+		token:     cfg.VaultToken,
+		mount:     strings.Trim(cfg.VaultMount, "/"),
+		namespace: cfg.VaultNamespace,
+	}
+}
+
+// Fetch reads the latest version of the secret at path
+func (v *vault) Fetch(ctx context.Context, path string) (map[string]string, error) {
+	endpoint := v.addr + "/v1/" + v.mount + "/data/" + escapePath(strings.Trim(path, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("vault: secret %q not found", path)
+	case resp.StatusCode != http.StatusOK:
+		var payload struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.Unmarshal(body, &payload)
+		return nil, fmt.Errorf("vault: reading %q returned %d: %s", path, resp.StatusCode, strings.Join(payload.Errors, "; "))
+	}
+
+	var payload struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("vault: invalid response for %q: %w", path, err)
+	}
+	if payload.Data.Data == nil {
+		return nil, fmt.Errorf("vault: secret %q has been deleted", path)
+	}
+
+	values := make(map[string]string, len(payload.Data.Data))
+	for key, value := range payload.Data.Data {
+		if s, ok := value.(string); ok {
+			values[key] = s
+		} else {
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	return values, nil
+}
+
+// escapePath escapes each segment of a slash-separated path
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
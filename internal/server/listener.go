@@ -0,0 +1,213 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+const (
+	// listenFDsEnv tells a child process how many listener FDs it inherited
+	// (compatible with systemd socket activation)
+	listenFDsEnv = "LISTEN_FDS"
+
+	// listenFDStart is the first inherited FD (after stdin, stdout, stderr)
+	listenFDStart = 3
+
+	// readyFDEnv names the FD a child process reports its readiness on
+	readyFDEnv = "RESTART_READY_FD"
+)
+
+// Listen returns a TCP listener for each of addrs, in order, reusing the
+// listeners inherited from a parent process during a graceful restart when
+// present. Inherited listeners are matched to addrs by the address they
+// listen on, so a restart that changes the ports or which servers run
+// still gets each server its own socket. Addresses without one are bound
+// fresh, and inherited listeners no address claims are closed.
+func Listen(addrs ...string) ([]net.Listener, error) {
+	inherited, err := inheritedListeners()
+	if err != nil {
+		return nil, err
+	}
+
+	lns := make([]net.Listener, len(addrs))
+	fail := func(err error) ([]net.Listener, error) {
+		for _, ln := range append(lns, inherited...) {
+			if ln != nil {
+				ln.Close()
+			}
+		}
+		return nil, err
+	}
+
+	for i, addr := range addrs {
+		for j, ln := range inherited {
+			if listensOn(ln, addr) {
+				lns[i] = ln
+				inherited = append(inherited[:j], inherited[j+1:]...)
+				break
+			}
+		}
+	}
+	for _, ln := range inherited {
+		ln.Close()
+	}
+	inherited = nil
+
+	for i, addr := range addrs {
+		if lns[i] != nil {
+			continue
+		}
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fail(err)
+		}
+		lns[i] = ln
+	}
+
+	return lns, nil
+}
+
+// inheritedListeners returns the listeners a parent process handed off
+func inheritedListeners() ([]net.Listener, error) {
+	n, _ := strconv.Atoi(os.Getenv(listenFDsEnv))
+	os.Unsetenv(listenFDsEnv)
+
+	lns := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		f := os.NewFile(uintptr(listenFDStart+i), "listener")
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, ln := range lns {
+				ln.Close()
+			}
+			return nil, fmt.Errorf("failed to use inherited listener: %w", err)
+		}
+		lns = append(lns, ln)
+	}
+	return lns, nil
+}
+
+// listensOn reports whether ln listens on addr. An address without a host
+// matches a listener on every interface.
+func listensOn(ln net.Listener, addr string) bool {
+	got, ok := ln.Addr().(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	want, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil || want.Port != got.Port {
+		return false
+	}
+	if want.IP == nil || want.IP.IsUnspecified() {
+		return got.IP.IsUnspecified()
+	}
+	return want.IP.Equal(got.IP)
+}
+
+// Restart starts a new copy of the current binary that inherits lns, so
+// the listening sockets are never closed, and waits up to timeout for it
+// to call Ready. The caller should then drain in-flight requests and exit;
+// new connections queue on the shared sockets until the child starts
+// accepting them. If the child exits or isn't ready in time, it is stopped
+// and an error returned, and the caller should carry on serving.
+func Restart(timeout time.Duration, lns ...net.Listener) (*os.Process, error) {
+	files := make([]*os.File, 0, len(lns))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, ln := range lns {
+		tcpLn, ok := ln.(*net.TCPListener)
+		if !ok {
+			return nil, fmt.Errorf("listener of type %T cannot be handed off", ln)
+		}
+
+		f, err := tcpLn.File()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get listener file: %w", err)
+		}
+		files = append(files, f)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve executable: %w", err)
+	}
+
+	// The child writes to the pipe once started; the read end sees EOF
+	// instead if it exits first
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create readiness pipe: %w", err)
+	}
+	defer ready.Close()
+	files = append(files, readyW)
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = append(os.Environ(),
+		listenFDsEnv+"="+strconv.Itoa(len(lns)),
+		readyFDEnv+"="+strconv.Itoa(listenFDStart+len(lns)),
+	)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start new process: %w", err)
+	}
+	readyW.Close()
+	files = files[:len(files)-1]
+
+	if err := awaitReady(ready, timeout); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		if cmd.ProcessState != nil && cmd.ProcessState.Exited() {
+			err = fmt.Errorf("%w (%s)", err, cmd.ProcessState)
+		}
+		return nil, err
+	}
+	return cmd.Process, nil
+}
+
+// awaitReady waits up to timeout for a child to report readiness on r
+func awaitReady(r *os.File, timeout time.Duration) error {
+	if err := r.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return fmt.Errorf("failed to wait for new process: %w", err)
+	}
+	n, err := r.Read(make([]byte, 1))
+	switch {
+	case n == 1:
+		return nil
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return fmt.Errorf("new process not ready after %s", timeout)
+	default:
+		return errors.New("new process exited before it was ready")
+	}
+}
+
+// Ready tells the parent process that started this one with Restart that
+// it has started serving, so the parent can drain and exit. It does
+// nothing if this process wasn't started by Restart.
+func Ready() error {
+	fd, err := strconv.Atoi(os.Getenv(readyFDEnv))
+	os.Unsetenv(readyFDEnv)
+	if err != nil {
+		return nil
+	}
+
+	f := os.NewFile(uintptr(fd), "ready")
+	defer f.Close()
+	if _, err := f.Write([]byte{1}); err != nil {
+		return fmt.Errorf("failed to report readiness: %w", err)
+	}
+	return nil
+}
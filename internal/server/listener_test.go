@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListensOn(t *testing.T) {
+	wildcard, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer wildcard.Close()
+	loopback, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer loopback.Close()
+
+	wildcardPort := strconv.Itoa(wildcard.Addr().(*net.TCPAddr).Port)
+	loopbackPort := strconv.Itoa(loopback.Addr().(*net.TCPAddr).Port)
+	tests := []struct {
+		name string
+		ln   net.Listener
+		addr string
+		want bool
+	}{
+		{"port only", wildcard, ":" + wildcardPort, true},
+		{"unspecified host", wildcard, "0.0.0.0:" + wildcardPort, true},
+		{"other port", wildcard, ":" + loopbackPort, false},
+		{"specific host on every interface", wildcard, "127.0.0.1:" + wildcardPort, false},
+		{"same host", loopback, "127.0.0.1:" + loopbackPort, true},
+		{"every interface on one host", loopback, ":" + loopbackPort, false},
+		{"unresolvable", loopback, "bad address", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, listensOn(tt.ln, tt.addr))
+		})
+	}
+}
+
+func TestListen(t *testing.T) {
+	lns, err := Listen("127.0.0.1:0", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.Len(t, lns, 2)
+	for _, ln := range lns {
+		assert.True(t, listensOn(ln, ln.Addr().String()))
+		ln.Close()
+	}
+}
+
+func TestReady(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+
+	// Ready closes the FD it reports on, so it gets its own
+	fd, err := syscall.Dup(int(w.Fd()))
+	require.NoError(t, err)
+	w.Close()
+	t.Setenv(readyFDEnv, strconv.Itoa(fd))
+
+	require.NoError(t, Ready())
+	assert.NoError(t, awaitReady(r, time.Second))
+	_, set := os.LookupEnv(readyFDEnv)
+	assert.False(t, set)
+
+	// Without a parent waiting there's nothing to report
+	assert.NoError(t, Ready())
+}
+
+func TestAwaitReady_NotReady(t *testing.T) {
+	// A child that exits closes its end without writing
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	w.Close()
+	assert.ErrorContains(t, awaitReady(r, time.Second), "exited")
+
+	// A child that hangs is given up on
+	r, w, err = os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+	assert.ErrorContains(t, awaitReady(r, 20*time.Millisecond), "not ready")
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"api-gateway-backend/internal/config"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLS holds the certificates HTTPS is served with
+type TLS struct {
+	// Config is set as the http.Server's TLSConfig; ServeTLS adds HTTP/2
+	// to the negotiated protocols
+	Config *tls.Config
+
+	autocert *autocert.Manager // nil when serving certificate files
+}
+
+// NewTLS loads the certificate files cfg names, or sets up obtaining and
+// renewing certificates for its autocert domains from Let's Encrypt
+func NewTLS(cfg config.TLSConfig) (*TLS, error) {
+	if len(cfg.AutocertDomains) > 0 {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+			Email:      cfg.AutocertEmail,
+		}
+		tlsCfg := m.TLSConfig()
+		tlsCfg.MinVersion = tls.VersionTLS12
+		return &TLS{Config: tlsCfg, autocert: m}, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return &TLS{
+		Config: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		},
+	}, nil
+}
+
+// RedirectHandler redirects plain HTTP requests to the same URL over HTTPS
+// on httpsPort. With autocert it first answers Let's Encrypt's HTTP-01
+// challenges, so the redirect listener should be on port 80.
+func (t *TLS) RedirectHandler(httpsPort string) http.Handler {
+	h := redirectToHTTPS(httpsPort)
+	if t.autocert != nil {
+		return t.autocert.HTTPHandler(h)
+	}
+	return h
+}
+
+// redirectToHTTPS redirects to https://<host>[:httpsPort]<request URI>,
+// permanently and keeping the method
+func redirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if host == "" {
+			http.Error(w, "Host header required", http.StatusBadRequest)
+			return
+		}
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+}
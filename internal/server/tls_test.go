@@ -0,0 +1,119 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSelfSigned writes a self-signed certificate for localhost and its
+// key to a temporary directory, returning their paths
+func writeSelfSigned(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewTLS_ServesHTTP2(t *testing.T) {
+	certFile, keyFile := writeSelfSigned(t)
+	certs, err := NewTLS(config.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	srv := &http.Server{
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(r.Proto)) }),
+		TLSConfig: certs.Config,
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	go srv.ServeTLS(ln, "", "")
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "HTTP/2.0", resp.Proto)
+
+	_, err = NewTLS(config.TLSConfig{CertFile: certFile, KeyFile: certFile})
+	assert.ErrorContains(t, err, "failed to load TLS certificate")
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		httpsPort string
+		status    int
+		location  string
+	}{
+		{"default port", http.MethodGet, "http://api.example.com/api/v1/items?limit=5", "443", http.StatusMovedPermanently, "https://api.example.com/api/v1/items?limit=5"},
+		{"custom port", http.MethodHead, "http://api.example.com:8080/healthz", "8443", http.StatusMovedPermanently, "https://api.example.com:8443/healthz"},
+		{"keeps method", http.MethodPost, "http://api.example.com/api/v1/orders", "443", http.StatusPermanentRedirect, "https://api.example.com/api/v1/orders"},
+		{"IPv6", http.MethodGet, "http://[::1]:80/", "8443", http.StatusMovedPermanently, "https://[::1]:8443/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := (&TLS{}).RedirectHandler(tt.httpsPort)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package signing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	ts := "1700000000"
+	at := time.Unix(1700000000, 0)
+	body := []byte(`{"customer":"acme"}`)
+	sig := Sign("secret", ts, "POST", "/api/v1/orders?dry_run=1", body)
+
+	assert.NoError(t, Verify("secret", ts, sig, "POST", "/api/v1/orders?dry_run=1", body, time.Minute, at))
+	assert.NoError(t, Verify("secret", ts, sig, "post", "/api/v1/orders?dry_run=1", body, time.Minute, at.Add(-30*time.Second)))
+	assert.Error(t, Verify("other", ts, sig, "POST", "/api/v1/orders?dry_run=1", body, time.Minute, at))
+	assert.Error(t, Verify("secret", ts, sig, "PUT", "/api/v1/orders?dry_run=1", body, time.Minute, at))
+	assert.Error(t, Verify("secret", ts, sig, "POST", "/api/v1/orders", body, time.Minute, at))
+	assert.Error(t, Verify("secret", ts, sig, "POST", "/api/v1/orders?dry_run=1", []byte(`{}`), time.Minute, at))
+	assert.Error(t, Verify("secret", ts, sig, "POST", "/api/v1/orders?dry_run=1", body, time.Minute, at.Add(2*time.Minute)))
+	assert.Error(t, Verify("secret", ts, sig[len("sha256="):], "POST", "/api/v1/orders?dry_run=1", body, time.Minute, at))
+	assert.Error(t, Verify("secret", "", sig, "POST", "/api/v1/orders?dry_run=1", body, time.Minute, at))
+}
+
+func TestStringToSign(t *testing.T) {
+	assert.Equal(t,
+		"1700000000\nGET\n/api/v1/items?page=2\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		StringToSign("1700000000", "get", "/api/v1/items?page=2", nil))
+}
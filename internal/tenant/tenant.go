@@ -0,0 +1,40 @@
+// Package tenant carries the tenant a request acts for through its
+// context. The database and caches scope every read and write of tenant
+// data to the tenant of the context they are given, so code handling a
+// request can't reach another tenant's rows or cache entries, and work
+// done outside a request, such as syncs, acts for Default.
+package tenant
+
+import (
+	"context"
+	"regexp"
+)
+
+// Default owns data written without a tenant, such as synced items, and is
+// the tenant of clients given none
+const Default = "default"
+
+// validID limits IDs to characters safe in cache keys and log fields
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Valid reports whether id can name a tenant: up to 64 lower-case letters,
+// digits, hyphens and underscores, starting with a letter or digit
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// key is the context key of the tenant
+type key struct{}
+
+// With returns ctx acting for tenant id
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// From returns the tenant ctx acts for, Default if none was set
+func From(ctx context.Context) string {
+	if id, ok := ctx.Value(key{}).(string); ok && id != "" {
+		return id
+	}
+	return Default
+}
@@ -0,0 +1,23 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrom(t *testing.T) {
+	assert.Equal(t, Default, From(context.Background()))
+	assert.Equal(t, "acme", From(With(context.Background(), "acme")))
+	assert.Equal(t, Default, From(With(context.Background(), "")))
+}
+
+func TestValid(t *testing.T) {
+	for _, id := range []string{"acme", "acme-eu_2", "7"} {
+		assert.True(t, Valid(id), id)
+	}
+	for _, id := range []string{"", "Acme", "-acme", "acme:v1", "acme/items"} {
+		assert.False(t, Valid(id), id)
+	}
+}
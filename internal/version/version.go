@@ -0,0 +1,70 @@
+// Package version reports which build of the gateway is running. The
+// values are set at build time with -ldflags, e.g.
+//
+//	go build -ldflags "-X api-gateway-backend/internal/version.Version=v1.2.0 \
+//		-X api-gateway-backend/internal/version.Commit=$(git rev-parse HEAD) \
+//		-X api-gateway-backend/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// as make build and the Dockerfile do.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags -X at build time
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build info. A commit or build time not set with ldflags
+// is taken from the VCS information go build stamps into binaries built
+// from a git checkout, and is "unknown" otherwise.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+	if info.Commit == "" || info.BuildTime == "" {
+		if build, ok := debug.ReadBuildInfo(); ok {
+			for _, s := range build.Settings {
+				switch {
+				case s.Key == "vcs.revision" && info.Commit == "":
+					info.Commit = s.Value
+				case s.Key == "vcs.time" && info.BuildTime == "":
+					info.BuildTime = s.Value
+				}
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
+
+// Fields returns the build info as log fields
+func (i Info) Fields() map[string]interface{} {
+	return map[string]interface{}{
+		"version":    i.Version,
+		"commit":     i.Commit,
+		"build_time": i.BuildTime,
+		"go_version": i.GoVersion,
+	}
+}
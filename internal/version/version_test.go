@@ -0,0 +1,27 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	defer func(v, c, b string) { Version, Commit, BuildTime = v, c, b }(Version, Commit, BuildTime)
+
+	Version, Commit, BuildTime = "v1.2.0", "abc123", "2024-01-01T00:00:00Z"
+	assert.Equal(t, Info{
+		Version:   "v1.2.0",
+		Commit:    "abc123",
+		BuildTime: "2024-01-01T00:00:00Z",
+		GoVersion: runtime.Version(),
+	}, Get())
+
+	// Test binaries carry no VCS stamp, so unset values are unknown
+	Version, Commit, BuildTime = "dev", "", ""
+	info := Get()
+	assert.Equal(t, "dev", info.Version)
+	assert.Equal(t, "unknown", info.Commit)
+	assert.Equal(t, "unknown", info.BuildTime)
+}
@@ -0,0 +1,246 @@
+// Package webhooks signs webhook payloads and notifies subscribers of data
+// changes.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/logger"
+)
+
+// Event types subscribers are notified of
+const (
+	EventItemsSynced  = "items.synced"  // a data sync upserted or deleted items
+	EventItemsUpdated = "items.updated" // an upstream webhook pushed items
+)
+
+// EventTypes lists every event type, for validating subscriptions
+var EventTypes = []string{EventItemsSynced, EventItemsUpdated}
+
+// Headers sent with each outbound delivery besides the signature
+const (
+	EventIDHeader   = "X-Webhook-ID"
+	EventTypeHeader = "X-Webhook-Event"
+)
+
+const (
+	// maxRetryDelay caps the exponential backoff between attempts
+	maxRetryDelay = time.Hour
+
+	// maxErrorLength truncates errors recorded on a delivery
+	maxErrorLength = 1024
+
+	// defaultBatchSize applies when no positive batch size is configured
+	defaultBatchSize = 100
+)
+
+// Event is the JSON body of a delivery. The ID is shared by every
+// subscription's delivery of the event and stays the same across retries,
+// so receivers can deduplicate.
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Notifier queues events for subscribers and delivers them. Publishing
+// only writes to the database; deliveries are sent by DeliverDue, which the
+// background jobs run on a schedule.
+type Notifier struct {
+	db          database.Store
+	client      *http.Client
+	logger      *logger.Logger
+	maxAttempts int
+	retryDelay  time.Duration
+	batchSize   int
+	now         func() time.Time
+}
+
+// NewNotifier creates a notifier with the delivery settings in cfg
+func NewNotifier(db database.Store, cfg config.WebhookConfig, log *logger.Logger) *Notifier {
+	batchSize := cfg.DeliveryBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	return &Notifier{
+		db:          db,
+		client:      &http.Client{Timeout: time.Duration(cfg.DeliveryTimeout) * time.Second},
+		logger:      log,
+		maxAttempts: cfg.DeliveryMaxAttempts,
+		retryDelay:  time.Duration(cfg.DeliveryRetryDelay) * time.Second,
+		batchSize:   batchSize,
+		now:         time.Now,
+	}
+}
+
+// Publish queues an event of eventType for every active subscription that
+// wants it and returns the number of deliveries queued
+func (n *Notifier) Publish(ctx context.Context, eventType string, data interface{}) (int, error) {
+	subs, err := n.db.ListWebhookSubscriptions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+
+	var deliveries []database.WebhookDelivery
+	var event Event
+	var payload []byte
+	for _, sub := range subs {
+		if !sub.Subscribed(eventType) {
+			continue
+		}
+		if payload == nil {
+			id, err := newEventID()
+			if err != nil {
+				return 0, err
+			}
+			event = Event{ID: id, Type: eventType, CreatedAt: n.now().UTC(), Data: data}
+			if payload, err = json.Marshal(event); err != nil {
+				return 0, fmt.Errorf("failed to marshal webhook event: %w", err)
+			}
+		}
+		deliveries = append(deliveries, database.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			EventType:      eventType,
+			Payload:        string(payload),
+		})
+	}
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+
+	if err := n.db.EnqueueWebhookDeliveries(ctx, deliveries); err != nil {
+		return 0, fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+	return len(deliveries), nil
+}
+
+// DeliverDue sends up to one batch of deliveries whose next attempt is due
+// and records each outcome. It returns how many were delivered.
+func (n *Notifier) DeliverDue(ctx context.Context) (int, error) {
+	due, err := n.db.ListDueWebhookDeliveries(ctx, n.now(), n.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due webhook deliveries: %w", err)
+	}
+
+	delivered := 0
+	for i := range due {
+		if ctx.Err() != nil {
+			return delivered, ctx.Err()
+		}
+
+		d := &due[i]
+		n.attempt(ctx, d)
+		if err := n.db.FinishWebhookDeliveryAttempt(ctx, d); err != nil {
+			return delivered, fmt.Errorf("failed to record webhook delivery %d: %w", d.ID, err)
+		}
+		if d.Status == database.DeliverySucceeded {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// attempt sends d once and updates its status, scheduling a retry with
+// exponential backoff until the last attempt fails
+func (n *Notifier) attempt(ctx context.Context, d *database.WebhookDelivery) {
+	log := n.logger.FromContext(ctx).WithFields(map[string]interface{}{
+		"delivery_id":     d.ID,
+		"subscription_id": d.SubscriptionID,
+		"event_id":        d.EventID,
+	})
+
+	d.Attempts++
+	status, err := n.send(ctx, d)
+	if status != 0 {
+		d.ResponseStatus = &status
+	}
+
+	now := n.now()
+	if err == nil {
+		d.Status = database.DeliverySucceeded
+		d.LastError = ""
+		d.DeliveredAt = &now
+		log.Debug("Delivered webhook")
+		return
+	}
+
+	d.LastError = err.Error()
+	if len(d.LastError) > maxErrorLength {
+		d.LastError = d.LastError[:maxErrorLength]
+	}
+	if d.Attempts >= n.maxAttempts {
+		d.Status = database.DeliveryFailed
+		log.WithError(err).WithField("attempts", d.Attempts).Warn("Webhook delivery failed, giving up")
+		return
+	}
+
+	d.NextAttemptAt = now.Add(n.backoff(d.Attempts))
+	log.WithError(err).WithField("attempts", d.Attempts).Info("Webhook delivery failed, will retry")
+}
+
+// send POSTs the signed payload, returning the response status and an
+// error unless the subscriber answered 2xx
+func (n *Notifier) send(ctx context.Context, d *database.WebhookDelivery) (int, error) {
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(n.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "api-gateway-backend-webhooks")
+	req.Header.Set(EventIDHeader, d.EventID)
+	req.Header.Set(EventTypeHeader, d.EventType)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(d.Secret, timestamp, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber returned %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait after the given number of failed attempts
+func (n *Notifier) backoff(attempts int) time.Duration {
+	delay := n.retryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// newEventID returns a random event ID
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "evt_" + hex.EncodeToString(b), nil
+}
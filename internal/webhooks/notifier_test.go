@@ -0,0 +1,191 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway-backend/internal/config"
+	"api-gateway-backend/internal/database"
+	"api-gateway-backend/internal/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps subscriptions and deliveries in memory. Methods the
+// notifier doesn't use panic through the nil embedded Store.
+type fakeStore struct {
+	database.Store
+
+	subs       []database.WebhookSubscription
+	queued     []database.WebhookDelivery
+	due        []database.WebhookDelivery
+	dueLimit   int
+	finished   []database.WebhookDelivery
+	enqueueErr error
+}
+
+func (s *fakeStore) ListWebhookSubscriptions(ctx context.Context) ([]database.WebhookSubscription, error) {
+	return s.subs, nil
+}
+
+func (s *fakeStore) EnqueueWebhookDeliveries(ctx context.Context, deliveries []database.WebhookDelivery) error {
+	s.queued = append(s.queued, deliveries...)
+	return s.enqueueErr
+}
+
+func (s *fakeStore) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]database.WebhookDelivery, error) {
+	s.dueLimit = limit
+	return s.due, nil
+}
+
+func (s *fakeStore) FinishWebhookDeliveryAttempt(ctx context.Context, delivery *database.WebhookDelivery) error {
+	s.finished = append(s.finished, *delivery)
+	return nil
+}
+
+func newTestNotifier(store *fakeStore, now time.Time) *Notifier {
+	n := NewNotifier(store, config.WebhookConfig{
+		DeliveryTimeout:     5,
+		DeliveryMaxAttempts: 3,
+		DeliveryRetryDelay:  30,
+	}, logger.New())
+	n.now = func() time.Time { return now }
+	return n
+}
+
+func TestPublish_QueuesForSubscribedEndpoints(t *testing.T) {
+	disabledAt := time.Now()
+	store := &fakeStore{subs: []database.WebhookSubscription{
+		{ID: 1},
+		{ID: 2, Events: []string{EventItemsSynced}},
+		{ID: 3, Events: []string{EventItemsUpdated}},
+		{ID: 4, DisabledAt: &disabledAt},
+	}}
+	n := newTestNotifier(store, time.Now())
+
+	queued, err := n.Publish(context.Background(), EventItemsSynced, map[string]int{"count": 5})
+	require.NoError(t, err)
+	assert.Equal(t, 2, queued)
+	require.Len(t, store.queued, 2)
+	assert.Equal(t, int64(1), store.queued[0].SubscriptionID)
+	assert.Equal(t, int64(2), store.queued[1].SubscriptionID)
+
+	// Every subscriber receives the same event
+	assert.Equal(t, store.queued[0].EventID, store.queued[1].EventID)
+	assert.Equal(t, store.queued[0].Payload, store.queued[1].Payload)
+
+	var event Event
+	require.NoError(t, json.Unmarshal([]byte(store.queued[0].Payload), &event))
+	assert.Equal(t, store.queued[0].EventID, event.ID)
+	assert.Equal(t, EventItemsSynced, event.Type)
+	assert.Equal(t, map[string]interface{}{"count": float64(5)}, event.Data)
+}
+
+func TestPublish_NoSubscribers(t *testing.T) {
+	store := &fakeStore{}
+	n := newTestNotifier(store, time.Now())
+
+	queued, err := n.Publish(context.Background(), EventItemsUpdated, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, queued)
+	assert.Empty(t, store.queued)
+}
+
+func TestDeliverDue_SignsAndRecordsSuccess(t *testing.T) {
+	now := time.Now()
+	payload := `{"id":"evt_1","type":"items.synced"}`
+
+	var verifyErr error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = Verify("secret", r.Header.Get(TimestampHeader), r.Header.Get(SignatureHeader), body, time.Minute, now)
+		assert.Equal(t, "evt_1", r.Header.Get(EventIDHeader))
+		assert.Equal(t, EventItemsSynced, r.Header.Get(EventTypeHeader))
+		assert.Equal(t, payload, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	store := &fakeStore{due: []database.WebhookDelivery{{
+		ID: 7, EventID: "evt_1", EventType: EventItemsSynced, Payload: payload, URL: server.URL, Secret: "secret",
+	}}}
+	n := newTestNotifier(store, now)
+
+	delivered, err := n.DeliverDue(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, verifyErr)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, defaultBatchSize, store.dueLimit)
+
+	require.Len(t, store.finished, 1)
+	d := store.finished[0]
+	assert.Equal(t, database.DeliverySucceeded, d.Status)
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, http.StatusNoContent, *d.ResponseStatus)
+	assert.NotNil(t, d.DeliveredAt)
+}
+
+func TestDeliverDue_RetriesWithBackoffThenGivesUp(t *testing.T) {
+	now := time.Now()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	store := &fakeStore{due: []database.WebhookDelivery{
+		{ID: 1, Payload: "{}", URL: server.URL, Secret: "s", Status: database.DeliveryPending},
+		{ID: 2, Payload: "{}", URL: server.URL, Secret: "s", Status: database.DeliveryPending, Attempts: 1},
+		{ID: 3, Payload: "{}", URL: server.URL, Secret: "s", Status: database.DeliveryPending, Attempts: 2},
+	}}
+	n := newTestNotifier(store, now)
+
+	delivered, err := n.DeliverDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	require.Len(t, store.finished, 3)
+	first, second, last := store.finished[0], store.finished[1], store.finished[2]
+
+	assert.Equal(t, database.DeliveryPending, first.Status)
+	assert.Equal(t, now.Add(30*time.Second), first.NextAttemptAt)
+	assert.Equal(t, "subscriber returned 503", first.LastError)
+
+	assert.Equal(t, database.DeliveryPending, second.Status)
+	assert.Equal(t, now.Add(time.Minute), second.NextAttemptAt)
+
+	assert.Equal(t, database.DeliveryFailed, last.Status)
+	assert.Equal(t, 3, last.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, *last.ResponseStatus)
+}
+
+func TestBackoff_Capped(t *testing.T) {
+	n := &Notifier{retryDelay: 30 * time.Second}
+	assert.Equal(t, 30*time.Second, n.backoff(1))
+	assert.Equal(t, 4*time.Minute, n.backoff(4))
+	assert.Equal(t, maxRetryDelay, n.backoff(20))
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	ts := "1700000000"
+	at := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"evt_1"}`)
+	sig := Sign("secret", ts, body)
+
+	assert.NoError(t, Verify("secret", ts, sig, body, time.Minute, at))
+	assert.Error(t, Verify("other", ts, sig, body, time.Minute, at))
+	assert.Error(t, Verify("secret", ts, sig, []byte(`{}`), time.Minute, at))
+	assert.Error(t, Verify("secret", ts, sig, body, time.Minute, now))
+	assert.Error(t, Verify("secret", ts, sig[len("sha256="):], body, time.Minute, at))
+	assert.Error(t, Verify("secret", "", sig, body, time.Minute, at))
+}
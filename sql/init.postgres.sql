@@ -0,0 +1,202 @@
+-- PostgreSQL schema (DB_DRIVER=postgres); mirrors init.sql
+
+-- Items table for storing external API data
+CREATE TABLE IF NOT EXISTS items (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    external_id VARCHAR(255) NOT NULL,
+    title VARCHAR(500) NOT NULL,
+    body TEXT,
+    user_id INT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL, -- set when the upstream no longer returns the item
+    UNIQUE (tenant_id, external_id)
+);
+CREATE INDEX IF NOT EXISTS idx_items_user_id ON items (user_id);
+CREATE INDEX IF NOT EXISTS idx_items_created_at ON items (created_at);
+CREATE INDEX IF NOT EXISTS idx_items_deleted_at ON items (deleted_at);
+-- GET /api/v1/items/search
+CREATE INDEX IF NOT EXISTS idx_items_search ON items USING GIN (to_tsvector('simple', title || ' ' || COALESCE(body, '')));
+
+-- Users, comments and todos synced alongside items (posts)
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    external_id VARCHAR(255) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    username VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    phone VARCHAR(64),
+    website VARCHAR(255),
+    company_name VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id BIGSERIAL PRIMARY KEY,
+    external_id VARCHAR(255) NOT NULL UNIQUE,
+    post_id INT NOT NULL,
+    name VARCHAR(500) NOT NULL,
+    email VARCHAR(255),
+    body TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments (post_id);
+
+CREATE TABLE IF NOT EXISTS todos (
+    id BIGSERIAL PRIMARY KEY,
+    external_id VARCHAR(255) NOT NULL UNIQUE,
+    user_id INT NOT NULL,
+    title VARCHAR(500) NOT NULL,
+    completed BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_todos_user_id ON todos (user_id);
+
+-- Orders table for analytics
+CREATE TABLE IF NOT EXISTS orders (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    customer_id VARCHAR(36) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    status VARCHAR(16) NOT NULL CHECK (status IN ('PENDING', 'PAID', 'CANCELLED')),
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_orders_tenant_created_at ON orders (tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders (customer_id);
+CREATE INDEX IF NOT EXISTS idx_orders_status ON orders (status);
+CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders (created_at);
+
+-- Insert sample orders data for testing
+INSERT INTO orders (customer_id, amount, status, created_at) VALUES
+('customer-1', 100.50, 'PAID', NOW() - INTERVAL '5 days'),
+('customer-2', 250.75, 'PENDING', NOW() - INTERVAL '10 days'),
+('customer-1', 75.25, 'PAID', NOW() - INTERVAL '15 days'),
+('customer-3', 500.00, 'CANCELLED', NOW() - INTERVAL '20 days'),
+('customer-2', 150.00, 'PAID', NOW() - INTERVAL '25 days'),
+('customer-4', 300.25, 'PAID', NOW() - INTERVAL '3 days'),
+('customer-1', 125.50, 'PENDING', NOW() - INTERVAL '7 days'),
+('customer-5', 200.00, 'PAID', NOW() - INTERVAL '12 days'),
+('customer-3', 450.75, 'PAID', NOW() - INTERVAL '18 days'),
+('customer-2', 175.25, 'CANCELLED', NOW() - INTERVAL '22 days');
+
+-- Order count and total amount per tenant, day and status, rebuilt from
+-- orders by the order stats job for analytics over large order tables
+CREATE TABLE IF NOT EXISTS order_stats (
+    tenant_id VARCHAR(64) NOT NULL,
+    day DATE NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    order_count INT NOT NULL,
+    total_amount DECIMAL(15,2) NOT NULL,
+    computed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (tenant_id, day, status)
+);
+
+-- API keys table for per-client gateway authentication
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    key_prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    roles VARCHAR(1024) NOT NULL DEFAULT '',
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP NULL
+);
+
+-- Sync run history for operators
+CREATE TABLE IF NOT EXISTS sync_runs (
+    id BIGSERIAL PRIMARY KEY,
+    trigger_type VARCHAR(32) NOT NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NULL,
+    success_count INT NOT NULL DEFAULT 0,
+    error_count INT NOT NULL DEFAULT 0,
+    errors TEXT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_sync_runs_started_at ON sync_runs (started_at);
+
+-- Webhook deliveries already applied, so redeliveries are skipped
+CREATE TABLE IF NOT EXISTS webhook_events (
+    event_id VARCHAR(255) PRIMARY KEY,
+    source VARCHAR(64) NOT NULL,
+    item_count INT NOT NULL DEFAULT 0,
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_events_received_at ON webhook_events (received_at);
+
+-- Callback URLs notified when synced data changes
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    events VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    disabled_at TIMESTAMP NULL
+);
+
+-- Outbound webhook deliveries and the outcome of their latest attempt
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id BIGINT NOT NULL,
+    event_id VARCHAR(64) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    response_status INT NULL,
+    last_error TEXT NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status_next_attempt ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_created ON webhook_deliveries (subscription_id, created_at);
+
+-- Mutating actions, who made them and the fields they changed
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(64) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    target VARCHAR(255) NOT NULL DEFAULT '',
+    changes TEXT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action_created ON audit_events (action, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_created ON audit_events (actor, created_at);
+
+-- Items whose upsert kept failing during syncs and retries, given up on
+CREATE TABLE IF NOT EXISTS failed_items (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    external_id VARCHAR(255) NOT NULL,
+    title VARCHAR(500) NOT NULL,
+    body TEXT,
+    user_id INT,
+    attempts INT NOT NULL,
+    last_error TEXT NOT NULL,
+    first_failed_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant_id, external_id)
+);
+CREATE INDEX IF NOT EXISTS idx_failed_items_updated_at ON failed_items (updated_at);
+
+-- Item and order changes recorded in the same transaction as the change,
+-- until the outbox relay job has published them
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    aggregate VARCHAR(32) NOT NULL,
+    aggregate_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
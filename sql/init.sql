@@ -5,24 +5,67 @@ USE api_gateway;
 -- Items table for storing external API data
 CREATE TABLE IF NOT EXISTS items (
     id BIGINT AUTO_INCREMENT PRIMARY KEY,
-    external_id VARCHAR(255) NOT NULL UNIQUE,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    external_id VARCHAR(255) NOT NULL,
     title VARCHAR(500) NOT NULL,
     body TEXT,
     user_id INT,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-    INDEX idx_external_id (external_id),
+    deleted_at DATETIME NULL, -- set when the upstream no longer returns the item
+    UNIQUE KEY uq_tenant_external_id (tenant_id, external_id),
+    FULLTEXT INDEX ft_items_title_body (title, body), -- GET /api/v1/items/search
     INDEX idx_user_id (user_id),
-    INDEX idx_created_at (created_at)
+    INDEX idx_created_at (created_at),
+    INDEX idx_deleted_at (deleted_at)
+);
+
+-- Users, comments and todos synced alongside items (posts)
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    external_id VARCHAR(255) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    username VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    phone VARCHAR(64),
+    website VARCHAR(255),
+    company_name VARCHAR(255),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    external_id VARCHAR(255) NOT NULL UNIQUE,
+    post_id INT NOT NULL,
+    name VARCHAR(500) NOT NULL,
+    email VARCHAR(255),
+    body TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_post_id (post_id)
+);
+
+CREATE TABLE IF NOT EXISTS todos (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    external_id VARCHAR(255) NOT NULL UNIQUE,
+    user_id INT NOT NULL,
+    title VARCHAR(500) NOT NULL,
+    completed BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_user_id (user_id)
 );
 
 -- Orders table for Part 3 SQL queries
 CREATE TABLE IF NOT EXISTS orders (
     id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
     customer_id VARCHAR(36) NOT NULL,
     amount DECIMAL(10,2) NOT NULL,
     status ENUM('PENDING', 'PAID', 'CANCELLED') NOT NULL,
     created_at DATETIME NOT NULL,
+    INDEX idx_tenant_created_at (tenant_id, created_at),
     INDEX idx_customer_id (customer_id),
     INDEX idx_status (status),
     INDEX idx_created_at (created_at)
@@ -39,4 +82,123 @@ INSERT INTO orders (customer_id, amount, status, created_at) VALUES
 ('customer-1', 125.50, 'PENDING', DATE_SUB(NOW(), INTERVAL 7 DAY)),
 ('customer-5', 200.00, 'PAID', DATE_SUB(NOW(), INTERVAL 12 DAY)),
 ('customer-3', 450.75, 'PAID', DATE_SUB(NOW(), INTERVAL 18 DAY)),
-('customer-2', 175.25, 'CANCELLED', DATE_SUB(NOW(), INTERVAL 22 DAY));
\ No newline at end of file
+('customer-2', 175.25, 'CANCELLED', DATE_SUB(NOW(), INTERVAL 22 DAY));
+
+-- Order count and total amount per tenant, day and status, rebuilt from
+-- orders by the order stats job for analytics over large order tables
+CREATE TABLE IF NOT EXISTS order_stats (
+    tenant_id VARCHAR(64) NOT NULL,
+    day DATE NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    order_count INT NOT NULL,
+    total_amount DECIMAL(15,2) NOT NULL,
+    computed_at DATETIME NOT NULL,
+    PRIMARY KEY (tenant_id, day, status)
+);
+
+-- API keys table for per-client gateway authentication
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    key_prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    roles VARCHAR(1024) NOT NULL DEFAULT '',
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at DATETIME NULL,
+    INDEX idx_key_hash (key_hash)
+);
+
+-- Sync run history for operators
+CREATE TABLE IF NOT EXISTS sync_runs (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    trigger_type VARCHAR(32) NOT NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME NULL,
+    success_count INT NOT NULL DEFAULT 0,
+    error_count INT NOT NULL DEFAULT 0,
+    errors TEXT NULL,
+    INDEX idx_started_at (started_at)
+);
+
+-- Webhook deliveries already applied, so redeliveries are skipped
+CREATE TABLE IF NOT EXISTS webhook_events (
+    event_id VARCHAR(255) PRIMARY KEY,
+    source VARCHAR(64) NOT NULL,
+    item_count INT NOT NULL DEFAULT 0,
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_received_at (received_at)
+);
+
+-- Callback URLs notified when synced data changes
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    events VARCHAR(1024) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    disabled_at DATETIME NULL
+);
+
+-- Outbound webhook deliveries and the outcome of their latest attempt
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    subscription_id BIGINT NOT NULL,
+    event_id VARCHAR(64) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload MEDIUMTEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    response_status INT NULL,
+    last_error TEXT NULL,
+    next_attempt_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at DATETIME NULL,
+    INDEX idx_status_next_attempt (status, next_attempt_at),
+    INDEX idx_subscription_created (subscription_id, created_at)
+);
+
+-- Mutating actions, who made them and the fields they changed
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    action VARCHAR(64) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    target VARCHAR(255) NOT NULL DEFAULT '',
+    changes MEDIUMTEXT NULL,
+    created_at DATETIME NOT NULL,
+    INDEX idx_created_at (created_at),
+    INDEX idx_action_created (action, created_at),
+    INDEX idx_actor_created (actor, created_at)
+);
+
+-- Items whose upsert kept failing during syncs and retries, given up on
+CREATE TABLE IF NOT EXISTS failed_items (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    external_id VARCHAR(255) NOT NULL,
+    title VARCHAR(500) NOT NULL,
+    body TEXT,
+    user_id INT,
+    attempts INT NOT NULL,
+    last_error TEXT NOT NULL,
+    first_failed_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_tenant_external_id (tenant_id, external_id),
+    INDEX idx_updated_at (updated_at)
+);
+
+-- Item and order changes recorded in the same transaction as the change,
+-- until the outbox relay job has published them
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    aggregate VARCHAR(32) NOT NULL,
+    aggregate_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload MEDIUMTEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
@@ -84,7 +84,7 @@ test_with_timing() {
 
 # Check if server is running
 echo -e "${COLOR_BLUE}🔍 Checking if server is running...${COLOR_NC}"
-if ! curl -s "$BASE_URL/health" > /dev/null; then
+if ! curl -s "$BASE_URL/healthz" > /dev/null; then
     echo -e "${COLOR_RED}❌ Server is not running at $BASE_URL${COLOR_NC}"
     echo "Please start the server first:"
     echo "  make docker-up"
@@ -97,7 +97,8 @@ echo ""
 
 # Test 1: Health Check
 echo -e "${COLOR_BLUE}📋 Test 1: Health Check${COLOR_NC}"
-test_endpoint "GET" "/health" "Health check endpoint"
+test_endpoint "GET" "/healthz" "Liveness probe"
+test_endpoint "GET" "/readyz" "Readiness probe"
 
 # Test 2: Data Synchronization
 echo -e "${COLOR_BLUE}📋 Test 2: Data Synchronization${COLOR_NC}"
@@ -142,7 +143,7 @@ echo "All tests completed!"
 echo ""
 echo "Additional testing commands:"
 echo "  # Manual testing:"
-echo "  curl -X GET $BASE_URL/health"
+echo "  curl -X GET $BASE_URL/readyz"
 echo "  curl -X POST $BASE_URL/api/v1/sync"
 echo "  curl -X GET $BASE_URL/api/v1/items"
 echo ""